@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/suggest"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/spf13/cobra"
+)
+
+var (
+	analyzeDeep   bool
+	analyzeFormat string
+)
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "Suggest tables whose data could be excluded",
+	Long: `Suggest tables whose data could be excluded from dumps, scored by how
+likely their contents are disposable. By default suggestions come from the
+exclusion rules (defaults, global and project config)
+matching table names.
+
+With --deep, row operation counts from
+performance_schema.table_io_waits_summary_by_table are factored in: tables
+with many writes that are (almost) never read, such as logs nobody queries,
+are suggested even when their names match no rule, and name matches that
+the application reads heavily score lower. The counts cover the time since
+the server started and include reads by earlier dumps. Without SELECT on
+performance_schema, or when it has recorded nothing, only names are used.`,
+	RunE: runAnalyze,
+}
+
+func init() {
+	analyzeCmd.Flags().BoolVar(&analyzeDeep, "deep", false, "Factor performance_schema read/write counts into the suggestions")
+	analyzeCmd.Flags().StringVar(&analyzeFormat, "format", "table", "Output format: table or json")
+	analyzeCmd.Flags().StringArrayVarP(&configFiles, "config", "c", nil, "Config file path (repeatable, merged in order)")
+	rootCmd.AddCommand(analyzeCmd)
+}
+
+// analyzeReport is the JSON output of the analyze command
+type analyzeReport struct {
+	Database    string               `json:"database"`
+	IOStats     bool                 `json:"io_stats"`
+	IOStatsNote string               `json:"io_stats_note,omitempty"`
+	Suggestions []suggest.Suggestion `json:"suggestions"`
+}
+
+func runAnalyze(cmd *cobra.Command, args []string) error {
+	if analyzeFormat != "table" && analyzeFormat != "json" {
+		return fmt.Errorf("unsupported --format %q (supported: table, json)", analyzeFormat)
+	}
+
+	resolvePassword()
+
+	if user == "" {
+		return fmt.Errorf("database user is required (use -u or --user)")
+	}
+	if dbName == "" {
+		return fmt.Errorf("database name is required (use -d or --database)")
+	}
+
+	excludeConfig, err := buildExcludeConfig()
+	if err != nil {
+		return err
+	}
+
+	conn := &database.Connection{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		Database: dbName,
+	}
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return err
+	}
+
+	db, err := conn.ConnectContext(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	inspector, err := newInspector(cmd.Context(), db)
+	if err != nil {
+		return err
+	}
+	tablesInfo, err := inspector.GetAllTablesInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get table information: %w", err)
+	}
+
+	report := analyzeReport{Database: dbName}
+
+	// IO statistics are optional; without them the name rules still apply
+	var ioStats map[string]database.TableIO
+	if analyzeDeep {
+		ioStats, err = inspector.GetTableIOStats()
+		switch {
+		case err != nil:
+			report.IOStatsNote = fmt.Sprintf("IO statistics unavailable (%v); using name rules only", err)
+		case len(ioStats) == 0:
+			report.IOStatsNote = "performance_schema has no IO statistics for this database; using name rules only"
+		default:
+			report.IOStats = true
+		}
+	}
+
+	levels, err := ruleConfidence(excludeConfig)
+	if err != nil {
+		return err
+	}
+	matcher := patterns.NewMatcher(excludeConfig).WithConfidence(levels)
+	metrics := make([]suggest.Metrics, 0, len(tablesInfo))
+	for _, info := range tablesInfo {
+		m := suggest.Metrics{Table: info.Name, DataSize: info.DataSize}
+		switch rule := matcher.MatchingRule(info.Name); rule {
+		case "":
+		case "exact":
+			m.NameRule = fmt.Sprintf("%q (exact)", info.Name)
+		default:
+			m.NameRule = fmt.Sprintf("%q", rule)
+		}
+		if io, ok := ioStats[info.Name]; ok {
+			m.IO = &suggest.IOStats{Reads: io.Reads, Writes: io.Writes}
+		}
+		metrics = append(metrics, m)
+	}
+	report.Suggestions = suggest.Rank(metrics)
+	for i, s := range report.Suggestions {
+		level := config.ConfidenceMedium
+		if s.Source != suggest.SourceIO {
+			level, _ = matcher.Confidence(s.Table)
+		}
+		report.Suggestions[i].Confidence = string(level)
+	}
+
+	if analyzeFormat == "json" {
+		if report.Suggestions == nil {
+			report.Suggestions = []suggest.Suggestion{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	printAnalyze(report)
+	return nil
+}
+
+// printAnalyze prints the suggestions as a table
+func printAnalyze(report analyzeReport) {
+	if report.IOStatsNote != "" {
+		ui.PrintInfo(report.IOStatsNote)
+	}
+	if len(report.Suggestions) == 0 {
+		fmt.Printf("\nNo exclusion suggestions for database '%s'\n", report.Database)
+		return
+	}
+
+	nameWidth := 24
+	for _, s := range report.Suggestions {
+		nameWidth = max(nameWidth, ui.DisplayWidth(s.Table))
+	}
+	nameWidth = min(nameWidth, 40)
+
+	fmt.Printf("\nExclusion suggestions for database '%s':\n\n", report.Database)
+	fmt.Printf("%s %5s  %-7s %-10s %10s  %s\n", ui.PadRight("Table", nameWidth), "Score", "Source", "Confidence", "Data", "Reasons")
+	fmt.Println(strings.Repeat(ui.Sym().Rule, min(nameWidth+91, ui.LineWidth(120))))
+
+	var ioOnly []string
+	for _, s := range report.Suggestions {
+		fmt.Printf("%s %5d  %-7s %-10s %10s  %s\n",
+			ui.PadRight(ui.Truncate(s.Table, nameWidth), nameWidth),
+			s.Score,
+			s.Source,
+			s.Confidence,
+			database.FormatBytes(s.DataSize),
+			strings.Join(s.Reasons, "; "),
+		)
+		if s.Source == suggest.SourceIO {
+			ioOnly = append(ioOnly, s.Table)
+		}
+	}
+
+	fmt.Printf("\nTotal: %d suggestions\n", len(report.Suggestions))
+	if len(ioOnly) > 0 {
+		fmt.Println("\nTo exclude the tables found from IO statistics, add them to your config")
+		fmt.Println("(medium confidence: suggested in the selector, excluded with --auto-threshold medium):")
+		fmt.Println("\nexclude:\n  exact:")
+		for _, table := range ioOnly {
+			fmt.Printf("    - %s\n", table)
+		}
+		fmt.Println("confidence:")
+		for _, table := range ioOnly {
+			fmt.Printf("  %s: medium\n", table)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/ui/prompt"
+)
+
+var (
+	// assumeYes gives every prompt its safe answer; assumeAnswers answers
+	// single prompts (key=answer)
+	assumeYes     bool
+	assumeAnswers []string
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Give every prompt its safe answer, for scripts; prompts that delete or replace data still need --assume (each answer applied is printed)")
+	rootCmd.PersistentFlags().StringArrayVar(&assumeAnswers, "assume", nil, "Answer one prompt, as key=yes|no, or reconcile=config|saved (repeatable; keys: "+strings.Join(prompt.Keys(), ", ")+")")
+}
+
+// configurePrompts applies --yes and --assume
+func configurePrompts() error {
+	return prompt.Configure(assumeYes, assumeAnswers)
+}
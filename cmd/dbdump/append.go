@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/donefile"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/tags"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/spf13/cobra"
+)
+
+// appendSinceLast dumps only the rows of append_tables past the last dump
+var appendSinceLast bool
+
+func init() {
+	dumpCmd.Flags().BoolVar(&appendSinceLast, "append-since-last", false, "Dump only the rows of append_tables added since the last dump in the output directory")
+}
+
+// validateAppendFlags rejects options an increment can't honor: it holds
+// nothing but rows, in one file its successor can checksum
+func validateAppendFlags(args []string, maxPartSize int64) error {
+	switch {
+	case !appendSinceLast:
+		return nil
+	case len(args) > 0, planFile != "":
+		return fmt.Errorf("--append-since-last dumps the tables of append_tables and takes no table selection")
+	case schemaDelta:
+		return fmt.Errorf("--append-since-last cannot be combined with --schema-delta")
+	case storeDir != "":
+		return fmt.Errorf("--append-since-last cannot be combined with --store")
+	case maxPartSize > 0:
+		return fmt.Errorf("--append-since-last cannot be combined with --max-file-size")
+	case verifyMode != "":
+		return fmt.Errorf("--append-since-last cannot be combined with --verify")
+	}
+	return nil
+}
+
+// loadAppendTables reads append_tables from the global config, then the
+// project config, whose entries replace those of the same table
+func loadAppendTables() (map[string]string, error) {
+	tables := make(map[string]string)
+	err := eachConfig(func(source string, cfg *config.Config) {
+		maps.Copy(tables, cfg.AppendTables)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// watermarkProblem returns why a table's watermark column can't be used,
+// or ""; the column must also lead an index so the ranges of increments
+// don't scan the table
+func watermarkProblem(ctx context.Context, inspector *database.Inspector, table, column string, columns []database.ColumnInfo, masked []database.MaskedTable) (string, error) {
+	if problem := database.WatermarkProblem(columns, column); problem != "" {
+		return fmt.Sprintf("%s: %s", table, problem), nil
+	}
+	for _, t := range masked {
+		if t.Table == table && (slices.Contains(t.MaskedColumnNames(), column) || t.NulledColumns()[column] != "") {
+			return fmt.Sprintf("%s: watermark column %s is masked", table, column), nil
+		}
+	}
+	indexed, err := inspector.IsLeadingIndexColumn(ctx, table, column)
+	if err != nil {
+		return "", err
+	}
+	if !indexed {
+		return fmt.Sprintf("%s: watermark column %s is not the first column of an index", table, column), nil
+	}
+	return "", nil
+}
+
+// readWatermark reads a table's watermark and schema fingerprint
+func readWatermark(ctx context.Context, inspector *database.Inspector, table, column string) (metadata.Watermark, error) {
+	through, rows, err := inspector.GetWatermark(ctx, table, column)
+	if err != nil {
+		return metadata.Watermark{}, err
+	}
+	create, err := inspector.GetCreateTable(table)
+	if err != nil {
+		return metadata.Watermark{}, err
+	}
+	return metadata.Watermark{
+		Table:   table,
+		Column:  column,
+		Through: through,
+		Rows:    rows,
+		Schema:  database.TableFingerprint(create),
+	}, nil
+}
+
+// withRange returns masked with the rows of table limited to a watermark
+// range, adding the table if it isn't masked
+func withRange(masked []database.MaskedTable, table string, columns []database.ColumnInfo, r database.AppendRange) []database.MaskedTable {
+	index := slices.IndexFunc(masked, func(t database.MaskedTable) bool {
+		return t.Table == table
+	})
+	if index < 0 {
+		plain, _ := database.NewMaskedTable(table, columns, nil, nil)
+		masked = append(masked, plain)
+		index = len(masked) - 1
+	}
+	masked[index].Range = &r
+	return masked
+}
+
+// appendBase makes a dump the base of a chain of increments: the tables of
+// append_tables whose data is dumped in full are read up to their current
+// watermark through the masking path, so the next increment starts exactly
+// where the dump stops. It returns the masked tables and the chain for the
+// sidecar, nil when no table of append_tables is dumped.
+func appendBase(ctx context.Context, inspector *database.Inspector, all []database.TableInfo, excludes, skipped []string, masked []database.MaskedTable, maxPartSize int64) ([]database.MaskedTable, *metadata.Chain, error) {
+	tables, err := loadAppendTables()
+	if err != nil || len(tables) == 0 {
+		return masked, nil, err
+	}
+	if maxPartSize > 0 || storeDir != "" {
+		diag.Warnf("append_tables is ignored with --max-file-size and --store: increments continue from a single dump file")
+		return masked, nil, nil
+	}
+
+	exists := make(map[string]bool, len(all))
+	for _, info := range all {
+		exists[info.Name] = true
+	}
+
+	chain := &metadata.Chain{}
+	var problems []string
+	for _, table := range slices.Sorted(maps.Keys(tables)) {
+		column := tables[table]
+		switch {
+		case !exists[table]:
+			problems = append(problems, fmt.Sprintf("%s: no such table", table))
+			continue
+		case slices.Contains(excludes, table), slices.Contains(skipped, table):
+			diag.Warnf("the data of %s isn't dumped in full, so increments won't include it", table)
+			continue
+		}
+
+		columns, err := inspector.GetColumns(ctx, table)
+		if err != nil {
+			return nil, nil, err
+		}
+		problem, err := watermarkProblem(ctx, inspector, table, column, columns, masked)
+		if err != nil {
+			return nil, nil, err
+		}
+		if problem != "" {
+			problems = append(problems, problem)
+			continue
+		}
+
+		watermark, err := readWatermark(ctx, inspector, table, column)
+		if err != nil {
+			return nil, nil, err
+		}
+		chain.Watermarks = append(chain.Watermarks, watermark)
+		masked = withRange(masked, table, columns, database.AppendRange{Column: column, Through: watermark.Through})
+	}
+
+	if len(problems) > 0 {
+		return nil, nil, &dberrors.ErrConfigInvalid{Source: "append_tables", Problems: problems}
+	}
+	if len(chain.Watermarks) == 0 {
+		return masked, nil, nil
+	}
+	return masked, chain, nil
+}
+
+// lastChainDump returns the newest dump of the database in dir that a
+// chain can continue from, and its sidecar
+func lastChainDump(dir string) (string, *metadata.Metadata, error) {
+	dumps, sidecars, err := findDumps(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	var path string
+	var last *metadata.Metadata
+	for _, dump := range dumps {
+		meta := sidecars[dump.Path]
+		if meta.Chain != nil && (last == nil || meta.CreatedAt.After(last.CreatedAt)) {
+			path, last = dump.Path, meta
+		}
+	}
+	if last == nil {
+		return "", nil, fmt.Errorf("no dump of %s with append_tables in %s to continue from; take a base dump first (without --append-since-last)", dbName, dir)
+	}
+	return path, last, nil
+}
+
+// nextWatermarks checks that the tables of a chain can be continued and
+// returns their watermarks past the previous ones. A changed schema, or a
+// different row count up to the previous watermark (rows inserted behind
+// it, or deleted), breaks the chain.
+func nextWatermarks(ctx context.Context, inspector *database.Inspector, previous *metadata.Chain, masked []database.MaskedTable) ([]metadata.Watermark, []database.MaskedTable, error) {
+	tables, err := loadAppendTables()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, table := range slices.Sorted(maps.Keys(tables)) {
+		if _, ok := previous.Watermark(table); !ok {
+			diag.Warnf("%s is in append_tables but not in the chain; take a fresh base dump to include it", table)
+		}
+	}
+
+	var watermarks []metadata.Watermark
+	for _, last := range previous.Watermarks {
+		if column, ok := tables[last.Table]; ok && column != last.Column {
+			return nil, nil, fmt.Errorf("the watermark column of %s changed from %s to %s; take a fresh base dump (without --append-since-last)", last.Table, last.Column, column)
+		}
+		columns, err := inspector.GetColumns(ctx, last.Table)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(columns) == 0 {
+			return nil, nil, fmt.Errorf("%s no longer exists; take a fresh base dump (without --append-since-last)", last.Table)
+		}
+
+		watermark, err := readWatermark(ctx, inspector, last.Table, last.Column)
+		if err != nil {
+			return nil, nil, err
+		}
+		if watermark.Schema != last.Schema {
+			return nil, nil, fmt.Errorf("the schema of %s changed since the last dump of the chain; take a fresh base dump (without --append-since-last)", last.Table)
+		}
+		behind, err := inspector.CountThrough(ctx, last.Table, last.Column, last.Through)
+		if err != nil {
+			return nil, nil, err
+		}
+		if behind != last.Rows {
+			return nil, nil, fmt.Errorf("%s has %d rows up to %s %s, but had %d: rows were inserted behind the watermark or deleted, so %s is not append-only; take a fresh base dump (without --append-since-last)",
+				last.Table, behind, last.Column, last.Through, last.Rows, last.Column)
+		}
+
+		watermark.From = last.Through
+		watermarks = append(watermarks, watermark)
+		masked = withRange(masked, last.Table, columns, database.AppendRange{Column: last.Column, From: watermark.From, Through: watermark.Through})
+	}
+	return watermarks, masked, nil
+}
+
+// runAppendIncrement dumps the rows added to the tables of a chain since
+// its last dump into a file of their own, to be restored on top of it
+func runAppendIncrement(cmd *cobra.Command, conn *database.Connection, inspector *database.Inspector, tuned performance) error {
+	ctx := cmd.Context()
+	previousPath, previous, err := lastChainDump(filepath.Dir(outputFile))
+	if err != nil {
+		return err
+	}
+	previousSHA, _, err := donefile.Checksum(previousPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the last dump of the chain: %w", err)
+	}
+
+	allTables, err := inspector.GetAllTablesInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get table information: %w", err)
+	}
+	masked, err := maskedTables(ctx, inspector, allTables, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	watermarks, ranged, err := nextWatermarks(ctx, inspector, previous.Chain, masked)
+	if err != nil {
+		return err
+	}
+	// Only the chain's tables are in an increment
+	var chained []database.MaskedTable
+	var infos []database.TableInfo
+	for _, table := range ranged {
+		if table.Range != nil {
+			chained = append(chained, table)
+		}
+	}
+	for _, info := range allTables {
+		if _, ok := previous.Chain.Watermark(info.Name); ok {
+			infos = append(infos, info)
+		}
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Continuing the chain from %s", filepath.Base(previousPath)))
+	for _, watermark := range watermarks {
+		ui.PrintInfo(fmt.Sprintf("%s: rows with %s", watermark.Table, database.AppendRange{Column: watermark.Column, From: watermark.From, Through: watermark.Through}))
+	}
+	if dryRun {
+		fmt.Printf("Would write the increment to %s\n", outputFile)
+		return nil
+	}
+
+	serverVersion, err := inspector.GetServerVersion()
+	if err != nil {
+		diag.Warnf("%v", err)
+	}
+	timeZones := checkTimeZones(inspector)
+	serverVariables := captureVariables(inspector)
+
+	progress := &dumpProgress{}
+	dumper := database.NewDumper(withEvents(&database.DumpOptions{
+		Connection:   conn,
+		OutputFile:   outputFile,
+		ShowProgress: progressEnabled(),
+		OnProgress:   progress.update,
+		Compress:     compressOutput,
+		SyncPolicy:   syncPolicy,
+		Masked:       chained,
+		Increment:    true,
+		Header:       dumpHeader(conn, serverVersion, timeZones, serverVariables),
+		Context:      ctx,
+		Performance:  tuned.settings,
+		KeepPartial:  keepPartial,
+	}))
+	result, err := dumper.Dump()
+	progress.finish(err == nil)
+	if err != nil {
+		ui.PrintError(err)
+		return err
+	}
+	lastDump = result
+	recordEventResult(result)
+
+	meta := buildMetadata(conn, serverVersion, infos, nil, nil, nil, result)
+	meta.Tags = dumpTags
+	meta.TimeZones = timeZones
+	meta.ServerVariables = serverVariables
+	meta.Performance = tuned.metadata()
+	recordMasks(meta, chained)
+	meta.Chain = &metadata.Chain{
+		Base:           previous.Chain.Base,
+		Previous:       filepath.Base(previousPath),
+		PreviousSHA256: previousSHA,
+		Sequence:       previous.Chain.Sequence + 1,
+		Watermarks:     watermarks,
+	}
+	sidecar := metadata.SidecarPath(result.OutputFile)
+	if err := metadata.Write(sidecar, meta); err != nil {
+		// Without its sidecar the increment can't be chained or restored
+		return err
+	}
+
+	reportWarnings()
+	ui.PrintSummary(result.OutputFile, 0, result.Duration, sizeDisplay(result), tags.Format(dumpTags))
+	ui.PrintInfo(fmt.Sprintf("Increment %d of the chain based on %s", meta.Chain.Sequence, meta.Chain.Base))
+	if err := writeDoneFile(result, sidecar); err != nil {
+		return err
+	}
+	if jsonResult != nil {
+		return writeJSON(dumpJSONView(result, nil))
+	}
+	return nil
+}
+
+// describeChain lists the tables of a base dump's chain for the dry run
+func describeChain(chain *metadata.Chain) string {
+	tables := make([]string, len(chain.Watermarks))
+	for i, watermark := range chain.Watermarks {
+		tables[i] = fmt.Sprintf("%s (%s)", watermark.Table, database.AppendRange{Column: watermark.Column, Through: watermark.Through})
+	}
+	return strings.Join(tables, ", ")
+}
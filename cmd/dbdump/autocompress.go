@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/tuning"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// Modes of --compression
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+	compressionAuto = "auto"
+)
+
+// Limits of the sample --compression=auto takes at dump start, which keep
+// it within about two seconds
+const (
+	compressionSampleSize   = 4 << 20
+	compressionSampleWithin = time.Second
+	compressionCodecBudget  = 250 * time.Millisecond
+
+	// compressionMinData is the data below which the choice saves less
+	// time than sampling takes
+	compressionMinData = 64 << 20
+)
+
+var (
+	compressionMode string
+	optimizeFor     string
+)
+
+func init() {
+	dumpCmd.Flags().StringVar(&compressionMode, "compression", "", "Output compression: none, gzip, or auto (measure the codecs on a sample of the data at dump start)")
+	dumpCmd.Flags().StringVar(&optimizeFor, "optimize-for", string(tuning.GoalTime), "What --compression=auto optimizes: time (fastest dump) or size (smallest output)")
+}
+
+// validateCompression checks --compression and --optimize-for
+func validateCompression() error {
+	switch compressionMode {
+	case "", compressionNone, compressionGzip, compressionAuto:
+	default:
+		return &dberrors.ErrConfigInvalid{
+			Source:   "--compression",
+			Problems: []string{fmt.Sprintf("unknown mode %q (supported: none, gzip, auto)", compressionMode)},
+		}
+	}
+	switch tuning.Goal(optimizeFor) {
+	case tuning.GoalTime, tuning.GoalSize:
+	default:
+		return &dberrors.ErrConfigInvalid{
+			Source:   "--optimize-for",
+			Problems: []string{fmt.Sprintf("unknown goal %q (supported: time, size)", optimizeFor)},
+		}
+	}
+
+	if compressionMode != compressionAuto {
+		return nil
+	}
+	switch {
+	case schemaDelta:
+		return fmt.Errorf("--compression=auto cannot be combined with --schema-delta, which writes plain SQL")
+	case storeDir != "":
+		return fmt.Errorf("--compression=auto cannot be combined with --store, which keeps dumps uncompressed")
+	case appendSinceLast:
+		return fmt.Errorf("--compression=auto cannot be combined with --append-since-last; use --compress for compressed increments")
+	}
+	return nil
+}
+
+// chooseCompression settles --compression=auto before the dump starts: it
+// dumps the start of the largest table with data, compresses it with each
+// codec and takes the one the estimates favour for --optimize-for. Output
+// that must be gzip (--compress, a .gz name) only gets its level chosen.
+// Small databases, and those whose size is unknown, aren't sampled and get
+// gzip at the configured level. It returns the output name, with .gz
+// appended when the choice compresses.
+func chooseCompression(options database.DumpOptions, tuned *performance, tables []database.TableInfo, excludes, skipped []string, sizesKnown bool, maxPartSize int64) (string, error) {
+	if compressionMode != compressionAuto {
+		return outputFile, nil
+	}
+	if dryRun {
+		ui.PrintInfo("Compression: chosen from a sample of the data when the dump starts (--compression=auto)")
+		return outputFile, nil
+	}
+
+	fallback := tuning.Codec{Name: "gzip", Level: tuned.settings.CompressionLevel}
+	var largest *database.TableInfo
+	var total int64
+	for i, table := range tables {
+		if table.Engine == "" || slices.Contains(excludes, table.Name) || slices.Contains(skipped, table.Name) {
+			continue
+		}
+		total += table.DataSize
+		if largest == nil || table.DataSize > largest.DataSize {
+			largest = &tables[i]
+		}
+	}
+	switch {
+	case !sizesKnown:
+		ui.PrintInfo("Compression: gzip (--compression=auto: table sizes unknown, not sampled)")
+		return applyCodec(fallback, tuned, maxPartSize)
+	case largest == nil || total < compressionMinData:
+		ui.PrintInfo(fmt.Sprintf("Compression: gzip (--compression=auto: only %s of data, not sampled)", database.FormatBytes(total)))
+		return applyCodec(fallback, tuned, maxPartSize)
+	}
+
+	options.Performance = tuned.settings
+	sample, err := database.NewDumper(&options).SampleData(largest.Name, compressionSampleSize, compressionSampleWithin)
+	if err != nil {
+		if options.Context != nil && options.Context.Err() != nil {
+			return "", err
+		}
+		diag.Warnf("compression not sampled, using gzip: %v", err)
+		return applyCodec(fallback, tuned, maxPartSize)
+	}
+	if len(sample.Data) == 0 {
+		ui.PrintInfo(fmt.Sprintf("Compression: gzip (--compression=auto: %s gave no data to sample)", largest.Name))
+		return applyCodec(fallback, tuned, maxPartSize)
+	}
+
+	profile := tuning.CodecProfile{
+		Size:     total,
+		DumpRate: sample.Rate(),
+		Workers:  min(tuned.settings.CompressionWorkers, runtime.NumCPU()),
+	}
+	for _, codec := range tuning.Codecs() {
+		if compressOutput && !codec.Compressed() {
+			continue
+		}
+		profile.Codecs = append(profile.Codecs, tuning.MeasureCodec(sample.Data, codec, compressionCodecBudget))
+	}
+	goal := tuning.Goal(optimizeFor)
+	estimates, chosen := tuning.ChooseCodec(profile, goal)
+
+	dumpRate := "too fast to time"
+	if profile.DumpRate > 0 {
+		dumpRate = database.FormatBytes(int64(profile.DumpRate)) + "/s"
+	}
+	ui.PrintInfo(fmt.Sprintf("Compression: %s (--compression=auto, optimizing for %s)", estimates[chosen].Codec.Name, goal))
+	fmt.Printf("    Sampled %s of %s: the dump writes %s\n", database.FormatBytes(int64(len(sample.Data))), largest.Name, dumpRate)
+	for i, estimate := range estimates {
+		fmt.Printf("    %s\n", describeCodecEstimate(estimate, profile.Workers, i == chosen))
+	}
+	return applyCodec(estimates[chosen].Codec, tuned, maxPartSize)
+}
+
+// describeCodecEstimate renders a line of the numbers a codec was chosen by
+func describeCodecEstimate(estimate tuning.CodecEstimate, workers int, chosen bool) string {
+	var line strings.Builder
+	marker := " "
+	if chosen {
+		marker = "*"
+	}
+	fmt.Fprintf(&line, "%s %-6s", marker, estimate.Codec.Name)
+	if estimate.Codec.Compressed() {
+		fmt.Fprintf(&line, "  %4.1fx at %s/s", 1/estimate.Ratio, database.FormatBytes(int64(estimate.Rate)))
+		if workers > 1 {
+			fmt.Fprintf(&line, " per worker (%d)", workers)
+		}
+	}
+	fmt.Fprintf(&line, ": ~%s, %s", estimate.Time.Round(time.Second), database.FormatBytes(estimate.Size))
+	return line.String()
+}
+
+// applyCodec sets the compression of the dump to the chosen codec
+func applyCodec(codec tuning.Codec, tuned *performance, maxPartSize int64) (string, error) {
+	compressOutput = codec.Compressed()
+	if !compressOutput {
+		return outputFile, nil
+	}
+	tuned.settings.CompressionLevel = codec.Level
+	if strings.HasSuffix(outputFile, ".gz") {
+		return outputFile, nil
+	}
+	path := outputFile + ".gz"
+	if err := validateOutputPaths(path, maxPartSize > 0); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/awsauth"
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// authAWSIAM is the profile auth value selecting RDS IAM authentication
+const authAWSIAM = "aws-iam"
+
+var (
+	awsIAMAuth  bool
+	awsRegion   string
+	awsCABundle string
+
+	// awsIAMActive records that IAM authentication is in use, for error hints
+	awsIAMActive bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&awsIAMAuth, "aws-iam-auth", false, "Authenticate to RDS/Aurora with an IAM auth token from the AWS credential chain (implies TLS)")
+	rootCmd.PersistentFlags().StringVar(&awsRegion, "aws-region", "", "AWS region for --aws-iam-auth (default: AWS config, or the region in the RDS host name)")
+	rootCmd.PersistentFlags().StringVar(&awsCABundle, "aws-ca-bundle", "", "CA bundle for --aws-iam-auth TLS (default: the RDS global bundle, downloaded and cached)")
+}
+
+// applyAWSIAMAuth sets up IAM authentication on conn when --aws-iam-auth is
+// given, or when a saved profile for the same database and server has
+// auth: aws-iam. Tokens are minted per session and per client invocation,
+// since each is only accepted for 15 minutes.
+func applyAWSIAMAuth(cmd *cobra.Command, conn *database.Connection) error {
+	enabled, region := awsIAMAuth, awsRegion
+	if !cmd.Flags().Changed("aws-iam-auth") {
+		profiles, err := config.LoadProfiles()
+		if err != nil {
+			return err
+		}
+		profile := profiles.FindByTarget(conn.Database, func(profile *config.ConnectionProfile) bool {
+			return database.SameServer(profile.Host, profile.Port, conn.Host, conn.Port)
+		})
+		if profile != nil && profile.Auth == authAWSIAM {
+			enabled = true
+			if region == "" {
+				region = profile.Region
+			}
+		}
+	}
+	if !enabled {
+		return nil
+	}
+
+	ctx := cmd.Context()
+	source, err := awsauth.NewTokenSource(ctx, conn.Host, conn.Port, conn.User, region)
+	if err != nil {
+		return fmt.Errorf("AWS IAM authentication: %w", err)
+	}
+
+	caFile := awsCABundle
+	if caFile == "" {
+		caFile, err = awsauth.CABundlePath(ctx)
+		if err != nil {
+			return fmt.Errorf("AWS IAM authentication: %w", err)
+		}
+	}
+
+	if conn.Password != "" {
+		ui.PrintWarning("Ignoring the password: IAM authentication uses generated tokens")
+	}
+	conn.TokenSource = source.Token
+	conn.CAFile = caFile
+	awsIAMActive = true
+	ui.PrintInfo(fmt.Sprintf("Using AWS IAM authentication (%s)", source.Region()))
+
+	return nil
+}
+
+// awsIAMHint explains the usual causes of a rejected IAM auth token
+const awsIAMHint = "the IAM auth token was rejected: check that the AWS identity has rds-db:connect for this database user " +
+	"(arn:aws:rds-db:<region>:<account>:dbuser:<resource-id>/<user>), that the user was created WITH AWSAuthenticationPlugin, " +
+	"and that IAM authentication is enabled on the instance"
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/bench"
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchStrategies []string
+	benchSample     int
+	benchTimeout    = units.Duration{Value: 30 * time.Minute}
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Compare dump strategies on this database",
+	Long: `Dump the database under each named strategy into a temporary directory and
+compare wall time, CPU time (dbdump and mysqldump) and output size, then
+recommend one. The first strategy is the baseline. Strategies run one after
+another in the order given, so the first also warms the server's caches.
+
+Built-in strategies (custom ones are defined under bench_strategies in the
+config file):
+` + builtinStrategyList() + `
+
+Exclusion and only rules from the config apply as for a dump. --sample limits
+the benchmark to the N largest tables. All output is deleted afterwards, and
+strategies that don't fit in --bench-timeout are reported as timed out.`,
+	RunE: runBench,
+}
+
+func init() {
+	benchCmd.Flags().StringSliceVar(&benchStrategies, "strategies", []string{"default", "compress"}, "Strategies to compare, baseline first")
+	benchCmd.Flags().IntVar(&benchSample, "sample", 0, "Only dump the N largest tables, skipping all others (0 for the whole database)")
+	benchCmd.Flags().Var(&benchTimeout, "bench-timeout", "Time limit for the whole benchmark (e.g. 10m)")
+	benchCmd.Flags().StringArrayVarP(&configFiles, "config", "c", nil, "Config file path (repeatable, merged in order)")
+
+	rootCmd.AddCommand(benchCmd)
+}
+
+// builtinStrategyList lists the built-in strategies for the help text
+func builtinStrategyList() string {
+	var lines []string
+	for _, strategy := range bench.Builtins() {
+		lines = append(lines, fmt.Sprintf("  %-14s %s", strategy.Name, strategy.Description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	if err := database.CheckMySQLDump(); err != nil {
+		return fmt.Errorf("mysqldump is required: %w", err)
+	}
+
+	resolvePassword()
+
+	if user == "" {
+		return fmt.Errorf("database user is required (use -u or --user)")
+	}
+	if dbName == "" {
+		return fmt.Errorf("database name is required (use -d or --database)")
+	}
+	if benchSample < 0 {
+		return fmt.Errorf("--sample must not be negative")
+	}
+	if benchTimeout.Value <= 0 {
+		return fmt.Errorf("--bench-timeout must be positive")
+	}
+
+	custom, err := loadBenchStrategies()
+	if err != nil {
+		return err
+	}
+	strategies, err := bench.Resolve(benchStrategies, custom)
+	if err != nil {
+		return &dberrors.ErrConfigInvalid{Source: "--strategies", Err: err}
+	}
+
+	conn := &database.Connection{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		Database: dbName,
+	}
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return err
+	}
+
+	db, err := conn.ConnectContext(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	inspector, err := newInspector(cmd.Context(), db)
+	if err != nil {
+		return err
+	}
+	tablesInfo, err := inspector.GetAllTablesInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get table information: %w", err)
+	}
+	sizesKnown := reportDegraded(inspector, tablesInfo)
+
+	_, sel, err := applySelectionRules(tablesInfo, nil)
+	if err != nil {
+		return err
+	}
+	excludes := sel.Excludes()
+	skipped := sel.Skipped
+
+	if benchSample > 0 {
+		if !sizesKnown {
+			return fmt.Errorf("--sample needs table sizes to pick the largest tables: %s", inspector.Degraded())
+		}
+		excludes, skipped = sampleLargest(sel.All, excludes, skipped, benchSample)
+	}
+
+	dir, err := os.MkdirTemp("", "dbdump-bench-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			diag.Warnf("failed to remove %s: %v", dir, err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), benchTimeout.Value)
+	defer cancel()
+
+	ui.PrintInfo(fmt.Sprintf("Benchmarking %d strategies on %s (%d tables with data, limit %s)",
+		len(strategies), dbName, len(sel.All)-len(excludes)-len(skipped), benchTimeout.String()))
+
+	results := make([]bench.Result, 0, len(strategies))
+	for _, strategy := range strategies {
+		result := runStrategy(ctx, conn, strategy, excludes, skipped, dir)
+		switch {
+		case result.TimedOut:
+			ui.PrintWarning(fmt.Sprintf("%s: timed out", strategy.Name))
+		case result.Error != "":
+			ui.PrintWarning(fmt.Sprintf("%s: %s", strategy.Name, result.Error))
+		default:
+			ui.PrintSuccess(fmt.Sprintf("%s: %s, %s", strategy.Name, result.Wall.Round(10*time.Millisecond), database.FormatBytes(result.Size)))
+		}
+		results = append(results, result)
+	}
+
+	printBenchResults(results)
+	return nil
+}
+
+// runStrategy dumps once with a strategy and measures it
+func runStrategy(ctx context.Context, conn *database.Connection, strategy bench.Strategy, excludes, skipped []string, dir string) bench.Result {
+	result := bench.Result{Strategy: strategy.Name}
+	if ctx.Err() != nil {
+		result.TimedOut = true
+		return result
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Running %s", strategy.Name))
+	// Each run's output is deleted before the next one starts
+	output := filepath.Join(dir, "dump.sql")
+	cpuBefore, cpuKnown := bench.CPUTime()
+	started := time.Now()
+
+	dumper := database.NewDumper(&database.DumpOptions{
+		Connection:    conn,
+		ExcludeTables: excludes,
+		SkipTables:    skipped,
+		OutputFile:    output,
+		ExtraArgs:     strategy.MySQLDumpArgs,
+		Context:       ctx,
+	})
+	dumped, err := dumper.Dump()
+
+	result.Wall = time.Since(started)
+	if cpuAfter, ok := bench.CPUTime(); ok && cpuKnown {
+		result.CPU = cpuAfter - cpuBefore
+	}
+	if err := os.Remove(output); err != nil && !errors.Is(err, os.ErrNotExist) {
+		diag.Warnf("failed to remove benchmark output: %v", err)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		result.TimedOut = true
+	case err != nil:
+		result.Error = err.Error()
+	default:
+		result.Size = dumped.FileSize
+	}
+	return result
+}
+
+// sampleLargest limits the benchmark to the data of the n largest tables
+// with data; all other tables are skipped entirely
+func sampleLargest(tables []database.TableInfo, excludes, skipped []string, n int) ([]string, []string) {
+	without := make(map[string]bool, len(excludes)+len(skipped))
+	for _, table := range append(append([]string{}, excludes...), skipped...) {
+		without[table] = true
+	}
+
+	var candidates []database.TableInfo
+	for _, info := range tables {
+		if !without[info.Name] && info.Engine != "" {
+			candidates = append(candidates, info)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].DataSize > candidates[j].DataSize })
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	keep := make(map[string]bool, len(candidates))
+	for _, info := range candidates {
+		keep[info.Name] = true
+	}
+	var sampleSkipped []string
+	for _, info := range tables {
+		if !keep[info.Name] {
+			sampleSkipped = append(sampleSkipped, info.Name)
+		}
+	}
+	return nil, sampleSkipped
+}
+
+// printBenchResults prints the comparison table and the recommendation
+func printBenchResults(results []bench.Result) {
+	baseline := results[0]
+
+	fmt.Println()
+	fmt.Printf("%-20s %12s %12s %12s  %s\n", "Strategy", "Wall", "CPU", "Size", "vs "+baseline.Strategy)
+	fmt.Println(strings.Repeat(ui.Sym().Rule, 80))
+	for _, result := range results {
+		if !result.OK() {
+			status := "timed out"
+			if !result.TimedOut {
+				status = "failed"
+			}
+			fmt.Printf("%-20s %12s %12s %12s  %s\n", ui.Truncate(result.Strategy, 20), "-", "-", "-", status)
+			continue
+		}
+
+		cpu := "-"
+		if result.CPU > 0 {
+			cpu = result.CPU.Round(10 * time.Millisecond).String()
+		}
+		compared := "baseline"
+		if result.Strategy != baseline.Strategy {
+			compared = "-"
+			if baseline.OK() && baseline.Wall > 0 {
+				compared = fmt.Sprintf("%+.0f%% time", 100*float64(result.Wall-baseline.Wall)/float64(baseline.Wall))
+				if baseline.Size > 0 {
+					compared += fmt.Sprintf(", %+.0f%% size", 100*float64(result.Size-baseline.Size)/float64(baseline.Size))
+				}
+			}
+		}
+		fmt.Printf("%-20s %12s %12s %12s  %s\n",
+			ui.Truncate(result.Strategy, 20),
+			result.Wall.Round(10*time.Millisecond),
+			cpu,
+			database.FormatBytes(result.Size),
+			compared,
+		)
+	}
+	fmt.Println()
+
+	best, reason := bench.Recommend(results)
+	if best == "" {
+		ui.PrintWarning("No strategy completed; try a larger --bench-timeout or a smaller --sample")
+		return
+	}
+	ui.PrintSuccess(fmt.Sprintf("Recommendation: %s (%s)", best, reason))
+}
+
+// loadBenchStrategies reads custom strategies from the project config, then
+// the global config; a project strategy shadows a global one of the same name
+func loadBenchStrategies() ([]bench.Strategy, error) {
+	var strategies []bench.Strategy
+	var problems []string
+
+	add := func(source string, cfg *config.Config) {
+		for _, custom := range cfg.BenchStrategies {
+			if custom.Name == "" {
+				problems = append(problems, fmt.Sprintf("%s: bench strategy without a name", source))
+				continue
+			}
+			for _, arg := range custom.MySQLDumpArgs {
+				if !strings.HasPrefix(arg, "--") {
+					problems = append(problems, fmt.Sprintf("%s: bench strategy %s: mysqldump argument %q must be a --long option", source, custom.Name, arg))
+				}
+			}
+			strategies = append(strategies, bench.Strategy{
+				Name:          custom.Name,
+				Description:   custom.Description,
+				MySQLDumpArgs: custom.MySQLDumpArgs,
+			})
+		}
+	}
+
+	globalConfig, err := config.LoadGlobalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load global config: %w", err)
+	}
+	if globalConfig != nil {
+		add("global config", globalConfig)
+	}
+	if len(configFiles) > 0 {
+		projectConfig, err := loadProjectConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file: %w", err)
+		}
+		add(configSource(), projectConfig)
+	}
+
+	if len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "bench_strategies", Problems: problems}
+	}
+	return strategies, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/budget"
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/units"
+)
+
+var (
+	timeBudgetFlag units.Duration
+	timeBudget     time.Duration
+)
+
+func init() {
+	dumpCmd.Flags().Var(&timeBudgetFlag, "time-budget", "Dump the data of priority_tables first, then the smallest tables, and start no table that would end past this time (e.g. 10m)")
+}
+
+// validateBudgetFlags checks --time-budget against the other flags
+func validateBudgetFlags() error {
+	timeBudget = 0
+	switch {
+	case timeBudgetFlag.Value == 0:
+		return nil
+	case timeBudgetFlag.Value < 0:
+		return &dberrors.ErrConfigInvalid{Source: "--time-budget", Problems: []string{"must be positive"}}
+	case appendSinceLast:
+		return fmt.Errorf("--time-budget cannot be combined with --append-since-last")
+	case schemaDelta:
+		return fmt.Errorf("--time-budget cannot be combined with --schema-delta (a schema delta has no data)")
+	case verifyMode == "restore":
+		return fmt.Errorf("--time-budget cannot be combined with --verify=restore (tables left out never match their checksums)")
+	}
+	timeBudget = timeBudgetFlag.Value
+	return nil
+}
+
+// budgetOrder returns the tables whose data mysqldump dumps, in the order
+// --time-budget dumps them: priority_tables first, then smallest first
+func budgetOrder(tablesInfo []database.TableInfo, excludes []string) ([]string, error) {
+	if timeBudget == 0 {
+		return nil, nil
+	}
+	estimates := make(map[string]int64, len(tablesInfo))
+	var names []string
+	for _, info := range tablesInfo {
+		// Views have no engine, and no data of their own
+		if info.Engine != "" && !slices.Contains(excludes, info.Name) {
+			estimates[info.Name] = info.DataSize
+			names = append(names, info.Name)
+		}
+	}
+	slices.Sort(names)
+
+	priorities, err := loadPriorityTables()
+	if err != nil {
+		return nil, err
+	}
+	var first []string
+	for _, entry := range priorities {
+		matched := []string{entry}
+		if patterns.IsPattern(entry) {
+			matched = patterns.Expand(entry, names)
+		}
+		matched = slices.DeleteFunc(matched, func(name string) bool {
+			_, ok := estimates[name]
+			return !ok
+		})
+		if len(matched) == 0 {
+			diag.Warnf("priority_tables entry %q matches no table whose data is dumped", entry)
+		}
+		first = append(first, matched...)
+	}
+	return budget.Order(estimates, first), nil
+}
+
+// loadPriorityTables reads priority_tables from the global config, then the
+// project config, whose entries come first
+func loadPriorityTables() ([]string, error) {
+	var priorities []string
+	err := eachConfig(func(source string, cfg *config.Config) {
+		priorities = slices.Concat(cfg.PriorityTables, priorities)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return priorities, nil
+}
+
+// describeBudgetOrder summarizes the order of a budgeted dump for the dry run
+func describeBudgetOrder(order []string) string {
+	const shown = 10
+	if len(order) <= shown {
+		return strings.Join(order, ", ")
+	}
+	return fmt.Sprintf("%s … (%d more)", strings.Join(order[:shown], ", "), len(order)-shown)
+}
+
+// recordOverBudget marks the tables left out by --time-budget in the
+// sidecar and returns the error that makes the dump exit with warnings,
+// or nil when every table fit
+func recordOverBudget(meta *metadata.Metadata, result *database.DumpResult) error {
+	if len(result.OverBudget) == 0 {
+		return nil
+	}
+	meta.OverBudgetTables = result.OverBudget
+	for i := range meta.Tables {
+		if slices.Contains(result.OverBudget, meta.Tables[i].Name) {
+			meta.Tables[i].DataIncluded = false
+		}
+	}
+	return &dberrors.ErrOverBudget{Tables: result.OverBudget}
+}
+
+// printOverBudget lists the tables left out by --time-budget
+func printOverBudget(tables []string) {
+	if len(tables) == 0 {
+		return
+	}
+	ui.PrintWarning(fmt.Sprintf("Data of %d tables left out to stay within --time-budget %s (structure kept): %s",
+		len(tables), timeBudget, strings.Join(tables, ", ")))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/charset"
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/transform"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// prepareCharsetConversion checks that the tables can be converted to the
+// target character set and returns the transform that rewrites their DDL
+func prepareCharsetConversion(inspector *database.Inspector, tablesInfo []database.TableInfo, target string) (transform.Func, error) {
+	cs, err := inspector.GetCharacterSet(target)
+	if err != nil {
+		return nil, &dberrors.ErrConfigInvalid{Source: "--convert-charset", Err: err}
+	}
+
+	collations, err := loadCollationMapping()
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for source, mapped := range collations {
+		if !cs.Collations[mapped] {
+			problems = append(problems, fmt.Sprintf("collation mapping %s → %s: %s is not a %s collation", source, mapped, mapped, target))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "charset.collations", Problems: problems}
+	}
+
+	columns, err := inspector.GetCharsetColumns()
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := inspector.GetIndexColumns()
+	if err != nil {
+		return nil, err
+	}
+	rowFormats, err := inspector.GetRowFormats()
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string]bool, len(tablesInfo))
+	for _, info := range tablesInfo {
+		tables[info.Name] = true
+	}
+
+	if problems := charset.CheckOverflow(target, cs.MaxLen, columns, indexes, rowFormats, tables); len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "--convert-charset " + target, Problems: problems}
+	}
+	for _, part := range indexes {
+		if tables[part.Table] && part.Expression != "" {
+			diag.Warnf("%s: functional index %s on %s is not checked against the key length limits of %s",
+				part.Table, part.Index, part.Expression, target)
+		}
+	}
+
+	converted := 0
+	for _, col := range columns {
+		if tables[col.Table] && col.CharSet != target && col.CharSet != "binary" {
+			converted++
+		}
+	}
+	ui.PrintInfo(fmt.Sprintf("Converting structure to %s (%d columns change character set)", target, converted))
+
+	mapping := charset.NewMapping(target, cs.DefaultCollation, collations, cs.Collations)
+	return charset.NewConverter(target, mapping).Transform, nil
+}
+
+// loadCollationMapping merges the collation mappings from the global and project config
+func loadCollationMapping() (map[string]string, error) {
+	collations := make(map[string]string)
+
+	globalConfig, err := config.LoadGlobalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load global config: %w", err)
+	}
+	if globalConfig != nil {
+		for source, target := range globalConfig.Charset.Collations {
+			collations[source] = target
+		}
+	}
+
+	if len(configFiles) > 0 {
+		projectConfig, err := loadProjectConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file: %w", err)
+		}
+		for source, target := range projectConfig.Charset.Collations {
+			collations[source] = target
+		}
+	}
+
+	return collations, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+// compressOutput gzips the dump
+var compressOutput bool
+
+// applyCompression decides whether the dump is gzipped: with --compress or
+// --compression=gzip, or when the output name ends in .gz. Either flag
+// appends .gz to a name without it; --compression=auto decides later.
+func applyCompression(path string) (string, error) {
+	if compressionMode == compressionGzip {
+		compressOutput = true
+	}
+	switch {
+	case strings.HasSuffix(path, ".zst"):
+		return "", fmt.Errorf("zstd output is not supported; use --compress (or an output name ending in .gz) for gzip")
+	case strings.HasSuffix(path, ".gz"):
+		compressOutput = true
+	case compressOutput:
+		path += ".gz"
+	}
+
+	if compressOutput && compressionMode == compressionNone {
+		return "", fmt.Errorf("--compression=none cannot be combined with --compress or an output name ending in .gz")
+	}
+	if compressOutput && schemaDelta {
+		return "", fmt.Errorf("--schema-delta writes plain SQL for review and cannot be compressed")
+	}
+	return path, nil
+}
+
+// outputCompression names the compression of the dump for the sidecar
+func outputCompression() string {
+	if compressOutput {
+		return "gzip"
+	}
+	return ""
+}
+
+// sizeDisplay describes the size of a finished dump, with the SQL size and
+// compression ratio for compressed output
+func sizeDisplay(result *database.DumpResult) string {
+	if !compressOutput || result.FileSize <= 0 {
+		return result.FileSizeDisplay
+	}
+	return fmt.Sprintf("%s, %s of SQL compressed %.1fx", result.FileSizeDisplay,
+		database.FormatBytes(result.UncompressedSize), float64(result.UncompressedSize)/float64(result.FileSize))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/spf13/cobra"
+)
+
+var (
+	autoThresholdFlag string
+	autoThreshold     = config.ConfidenceHigh
+)
+
+// addThresholdFlag registers --auto-threshold on a command that selects
+// tables without the selector
+func addThresholdFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&autoThresholdFlag, "auto-threshold", string(config.ConfidenceHigh), "Lowest rule confidence whose matches are excluded without the selector: high, medium or low")
+}
+
+// validateAutoThreshold parses --auto-threshold
+func validateAutoThreshold() error {
+	level, err := config.ParseConfidence(autoThresholdFlag)
+	if err != nil {
+		return &dberrors.ErrConfigInvalid{Source: "--auto-threshold", Problems: []string{err.Error()}}
+	}
+	autoThreshold = level
+	return nil
+}
+
+// warnedConfidence are the confidence entries ruleConfidence has warned
+// about, as the rules are built more than once per run
+var warnedConfidence = make(map[string]bool)
+
+// ruleConfidence reads the confidence of the exclude rules from the global
+// and project configs. Rules given on the command line are high whatever
+// the configs say: naming a table or pattern there is as sure as it gets.
+func ruleConfidence(rules config.ExcludeConfig) (map[string]config.Confidence, error) {
+	settings := make(map[string]string)
+	merge := func(cfg *config.Config) {
+		for rule, level := range cfg.Confidence {
+			settings[rule] = level
+		}
+	}
+	if !database.IsSystemDatabase(dbName) {
+		globalConfig, err := config.LoadGlobalConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load global config: %w", err)
+		}
+		if globalConfig != nil {
+			merge(globalConfig)
+		}
+	}
+	if len(configFiles) > 0 {
+		projectConfig, err := loadProjectConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file: %w", err)
+		}
+		merge(projectConfig)
+	}
+
+	levels := make(map[string]config.Confidence)
+	var problems []string
+	for _, rule := range slices.Sorted(maps.Keys(settings)) {
+		level, err := config.ParseConfidence(settings[rule])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", rule, err))
+			continue
+		}
+		// Patterns are matched as normalizePatterns fixed them
+		fixed, _ := patterns.Normalize(rule)
+		switch {
+		case slices.Contains(rules.Exact, rule) || slices.Contains(rules.Patterns, rule):
+			levels[rule] = level
+		case slices.Contains(rules.Patterns, fixed):
+			levels[fixed] = level
+		case !warnedConfidence[rule]:
+			warnedConfidence[rule] = true
+			diag.Warnf("confidence of %q: no exclude rule is written that way, so it has no effect", rule)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "confidence", Problems: problems}
+	}
+
+	inline, err := parseExcludeJSON()
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range slices.Concat(excludeTables, excludePattern, inline.Exact, inline.Patterns) {
+		fixed, _ := patterns.Normalize(rule)
+		delete(levels, rule)
+		delete(levels, fixed)
+	}
+	if excludeAllData {
+		delete(levels, "*")
+	}
+	return levels, nil
+}
+
+// thresholdExcludes returns the pre-selected tables whose rules reach
+// --auto-threshold, saying which ones keep their data because they don't
+func thresholdExcludes(sel *planner.Selection) []string {
+	if below := sel.Below(autoThreshold); len(below) > 0 {
+		ui.PrintInfo(fmt.Sprintf("Keeping the data of %d table(s) matched only by rules below %s confidence: %s (--auto-threshold %s excludes them too)",
+			len(below), autoThreshold, strings.Join(below, ", "), lowestConfidence(sel, below)))
+	}
+	return sel.AtLeast(autoThreshold)
+}
+
+// lowestConfidence returns the weakest confidence among tables
+func lowestConfidence(sel *planner.Selection, tables []string) config.Confidence {
+	lowest := config.ConfidenceHigh
+	for _, table := range tables {
+		if level := sel.ConfidenceOf(table); !level.AtLeast(lowest) {
+			lowest = level
+		}
+	}
+	return lowest
+}
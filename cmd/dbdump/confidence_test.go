@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// TestRuleConfidence locks down where the confidence of each exclude rule
+// comes from: the project config over the global one, rules named on the
+// command line always high, and the pattern as normalized
+func TestRuleConfidence(t *testing.T) {
+	savedDB, savedConfigs, savedExact, savedPatterns, savedJSON, savedAll, savedOutput :=
+		dbName, configFiles, excludeTables, excludePattern, excludeJSON, excludeAllData, diag.Output
+	defer func() {
+		dbName, configFiles, excludeTables, excludePattern, excludeJSON, excludeAllData, diag.Output =
+			savedDB, savedConfigs, savedExact, savedPatterns, savedJSON, savedAll, savedOutput
+		warnedConfidence = make(map[string]bool)
+		diag.Default.Reset()
+	}()
+	var warnings bytes.Buffer
+	diag.Output = &warnings
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfig := func(path, confidence string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("confidence:\n"+confidence), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(filepath.Join(home, ".dbdump.yaml"), "  sessions: low\n  \"*_log\": low\n  audit_*: low\n  \"*\": low\n")
+	project := filepath.Join(t.TempDir(), ".dbdump.yaml")
+	writeConfig(project, "  \"*_log\": medium\n  \"'cache_*'\": medium\n  jobs: medium\n  gone_*: low\n")
+
+	rules := config.ExcludeConfig{
+		Exact:    []string{"sessions", "jobs"},
+		Patterns: []string{"*_log", "audit_*", "cache_*", "*"},
+	}
+	tests := []struct {
+		name     string
+		db       string
+		project  bool
+		exact    []string
+		patterns []string
+		json     string
+		all      bool
+		want     map[string]config.Confidence
+	}{
+		{
+			name: "global only",
+			db:   "shop",
+			want: map[string]config.Confidence{"sessions": "low", "*_log": "low", "audit_*": "low", "*": "low"},
+		},
+		{
+			name:    "project over global",
+			db:      "shop",
+			project: true,
+			want:    map[string]config.Confidence{"sessions": "low", "*_log": "medium", "audit_*": "low", "cache_*": "medium", "jobs": "medium", "*": "low"},
+		},
+		{
+			name:     "command line rules are high",
+			db:       "shop",
+			project:  true,
+			exact:    []string{"sessions"},
+			patterns: []string{" *_log "},
+			json:     `{"patterns":["audit_*"]}`,
+			want:     map[string]config.Confidence{"cache_*": "medium", "jobs": "medium", "*": "low"},
+		},
+		{
+			name: "--exclude-all-data is high",
+			db:   "shop",
+			all:  true,
+			want: map[string]config.Confidence{"sessions": "low", "*_log": "low", "audit_*": "low"},
+		},
+		{
+			name: "system databases ignore the global config",
+			db:   "mysql",
+			want: map[string]config.Confidence{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbName, excludeTables, excludePattern, excludeJSON, excludeAllData = tt.db, tt.exact, tt.patterns, tt.json, tt.all
+			configFiles = nil
+			if tt.project {
+				configFiles = []string{project}
+			}
+			got, err := ruleConfidence(rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ruleConfidence =\n %v, want\n %v", got, tt.want)
+			}
+		})
+	}
+	if !strings.Contains(warnings.String(), `confidence of "gone_*": no exclude rule is written that way`) {
+		t.Errorf("no warning about the confidence of a rule that doesn't exist:\n%s", warnings.String())
+	}
+
+	// Unknown levels are a config error naming every rule with one
+	writeConfig(project, "  sessions: certain\n  jobs: maybe\n")
+	dbName, configFiles, excludeTables, excludePattern, excludeJSON = "shop", []string{project}, nil, nil, ""
+	_, err := ruleConfidence(rules)
+	var invalid *dberrors.ErrConfigInvalid
+	if !errors.As(err, &invalid) || len(invalid.Problems) != 2 {
+		t.Errorf("ruleConfidence = %v, want both unknown levels reported", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/spf13/cobra"
+)
+
+// excludeJSON holds inline exclusion rules given with --exclude-json
+var excludeJSON string
+
+func init() {
+	for _, cmd := range []*cobra.Command{dumpCmd, planCmd} {
+		cmd.Flags().StringVar(&excludeJSON, "exclude-json", "", `Exclusion rules as inline JSON, e.g. '{"exact":["logs"],"patterns":["tmp_*"]}'`)
+	}
+}
+
+// loadProjectConfig loads the configs given with --config, merged in order
+// after the configs each extends; nil when none is given
+func loadProjectConfig() (*config.Config, error) {
+	if len(configFiles) == 0 {
+		return nil, nil
+	}
+	return config.LoadConfigs(configFiles)
+}
+
+// configSource names the configs given with --config in messages
+func configSource() string {
+	names := make([]string, len(configFiles))
+	for i, file := range configFiles {
+		names[i] = config.SourceName(file)
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseExcludeJSON parses --exclude-json; unknown keys are rejected so a
+// typo doesn't silently exclude nothing
+func parseExcludeJSON() (config.ExcludeConfig, error) {
+	var rules config.ExcludeConfig
+	if excludeJSON == "" {
+		return rules, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(excludeJSON)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return rules, &dberrors.ErrConfigInvalid{
+			Source: "--exclude-json",
+			Err:    fmt.Errorf("failed to parse exclusion rules: %w", err),
+		}
+	}
+	return rules, nil
+}
+
+// validateConfigInput checks --exclude-json before connecting, and rejects
+// --config - when the interactive selector would run, since the config
+// consumes stdin
+func validateConfigInput(args []string) error {
+	if _, err := parseExcludeJSON(); err != nil {
+		return err
+	}
+	if stdin := slices.Index(configFiles, config.StdinPath); stdin >= 0 && slices.Contains(configFiles[stdin+1:], config.StdinPath) {
+		return &dberrors.ErrConfigInvalid{Source: "--config", Problems: []string{"--config - can only be given once"}}
+	}
+	if !slices.Contains(configFiles, config.StdinPath) || autoMode || len(args) > 0 || planFile != "" {
+		return nil
+	}
+	return &dberrors.ErrConfigInvalid{
+		Source:   "--config",
+		Problems: []string{"--config - reads the config from stdin, which the interactive table selector needs; add --auto or name the tables to dump"},
+	}
+}
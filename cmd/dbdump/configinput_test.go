@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+func TestParseExcludeJSON(t *testing.T) {
+	saved := excludeJSON
+	defer func() { excludeJSON = saved }()
+
+	tests := []struct {
+		name    string
+		json    string
+		want    config.ExcludeConfig
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "both", json: `{"exact":["logs"],"patterns":["tmp_*"]}`, want: config.ExcludeConfig{Exact: []string{"logs"}, Patterns: []string{"tmp_*"}}},
+		{name: "exact only", json: `{"exact":["logs","cache"]}`, want: config.ExcludeConfig{Exact: []string{"logs", "cache"}}},
+		{name: "unknown key", json: `{"pattern":["tmp_*"]}`, wantErr: `unknown field "pattern"`},
+		{name: "not JSON", json: `exact: [logs]`, wantErr: "failed to parse exclusion rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			excludeJSON = tt.json
+			got, err := parseExcludeJSON()
+			if tt.wantErr != "" {
+				var invalid *dberrors.ErrConfigInvalid
+				if !errors.As(err, &invalid) || invalid.Source != "--exclude-json" || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseExcludeJSON() error = %v, want %q from --exclude-json", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseExcludeJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfigInput(t *testing.T) {
+	savedConfigs, savedAuto, savedPlan, savedJSON := configFiles, autoMode, planFile, excludeJSON
+	defer func() {
+		configFiles, autoMode, planFile, excludeJSON = savedConfigs, savedAuto, savedPlan, savedJSON
+	}()
+
+	tests := []struct {
+		name    string
+		configs []string
+		auto    bool
+		args    []string
+		plan    string
+		json    string
+		wantErr string
+	}{
+		{name: "config file", configs: []string{".dbdump.yaml"}},
+		{name: "stdin with --auto", configs: []string{"-"}, auto: true},
+		{name: "stdin with tables", configs: []string{"-"}, args: []string{"users"}},
+		{name: "stdin with a plan", configs: []string{"-"}, plan: "plan.json"},
+		{name: "stdin for the selector", configs: []string{"-"}, wantErr: "which the interactive table selector needs"},
+		{name: "stdin twice", configs: []string{"-", "base.yaml", "-"}, auto: true, wantErr: "can only be given once"},
+		{name: "bad JSON", auto: true, json: `{"exact":"logs"}`, wantErr: "--exclude-json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFiles, autoMode, planFile, excludeJSON = tt.configs, tt.auto, tt.plan, tt.json
+			err := validateConfigInput(tt.args)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateConfigInput() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateConfigInput() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestStdinConfigWithAuto pipes a generated config in, as --config - --auto
+// reads it, and merges it with inline rules
+func TestStdinConfigWithAuto(t *testing.T) {
+	savedDB, savedConfigs, savedAuto, savedJSON, savedExact, savedPatterns, savedAll, savedStdin :=
+		dbName, configFiles, autoMode, excludeJSON, excludeTables, excludePattern, excludeAllData, os.Stdin
+	defer func() {
+		dbName, configFiles, autoMode, excludeJSON, excludeTables, excludePattern, excludeAllData, os.Stdin =
+			savedDB, savedConfigs, savedAuto, savedJSON, savedExact, savedPatterns, savedAll, savedStdin
+	}()
+	savedOrigins := ruleOrigins
+	defer func() { ruleOrigins = savedOrigins }()
+	ruleOrigins = make(config.Origins)
+	t.Setenv("HOME", t.TempDir())
+
+	const generated = "exclude:\n  exact: [billing_events]\n  patterns: [\"svc_*_queue\"]\n"
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(generated); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	os.Stdin = r
+
+	dbName, configFiles, autoMode = "shop", []string{config.StdinPath}, true
+	excludeJSON = `{"exact":["audit"],"patterns":["tmp_*"]}`
+	excludeTables, excludePattern, excludeAllData = nil, nil, false
+
+	if err := validateConfigInput(nil); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := buildExcludeConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"billing_events", "audit"} {
+		if !slices.Contains(rules.Exact, table) {
+			t.Errorf("exact rules %v lack %s", rules.Exact, table)
+		}
+	}
+	for _, pattern := range []string{"svc_*_queue", "tmp_*"} {
+		if !slices.Contains(rules.Patterns, pattern) {
+			t.Errorf("patterns %v lack %s", rules.Patterns, pattern)
+		}
+	}
+	if got := configSource(); got != "stdin" {
+		t.Errorf("configSource() = %q, want stdin", got)
+	}
+	if got := string(config.StdinConfig()); got != generated {
+		t.Errorf("StdinConfig() = %q, want the piped config", got)
+	}
+}
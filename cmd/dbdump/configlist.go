@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/ui/prompt"
+	"github.com/helgesverre/dbdump/internal/ui/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configListFormat string
+	showSecrets      bool
+)
+
+func init() {
+	configListCmd.Flags().StringVar(&configListFormat, "format", "table", "Output format: table, csv or json")
+	configListCmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "Include stored passwords in plain text (asks for confirmation)")
+}
+
+// profileView is the listing of a profile; the password is only set with --show-secrets
+type profileView struct {
+	Name           string   `json:"name"`
+	Host           string   `json:"host"`
+	Port           int      `json:"port"`
+	User           string   `json:"user"`
+	Database       string   `json:"database,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	PasswordSource string   `json:"password_source"`
+	Password       string   `json:"password,omitempty"`
+}
+
+func runConfigList(cmd *cobra.Command, args []string) error {
+	format, err := table.ParseFormat(configListFormat)
+	if err != nil {
+		return err
+	}
+
+	profiles, err := config.LoadProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to load profiles: %w", err)
+	}
+
+	if showSecrets {
+		ok, err := prompt.Confirm(prompt.ShowSecrets, "Show stored passwords in plain text?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	views := make([]profileView, len(profiles.Profiles))
+	for i, profile := range profiles.Profiles {
+		views[i] = profileView{
+			Name:           profile.Name,
+			Host:           profile.Host,
+			Port:           profile.Port,
+			User:           profile.User,
+			Database:       profile.Database,
+			Tags:           profile.Tags,
+			PasswordSource: passwordSource(profile),
+		}
+		if showSecrets {
+			views[i].Password = profile.Password
+		}
+	}
+
+	// JSON keeps the profile fields typed rather than as table cells
+	if format == table.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(views)
+	}
+
+	if len(views) == 0 && format == table.Text {
+		fmt.Println("No saved profiles found")
+		return nil
+	}
+
+	out := table.New(
+		table.Column{Title: "Name", MinWidth: 20},
+		table.Column{Title: "Host:Port", Key: "host", MinWidth: 28},
+		table.Column{Title: "User", MinWidth: 16},
+		table.Column{Title: "Database", MinWidth: 20},
+		table.Column{Title: "Tags", MinWidth: 16},
+		table.Column{Title: "Password"},
+	)
+	for _, view := range views {
+		secret := view.PasswordSource
+		if showSecrets && view.Password != "" {
+			secret = view.Password
+		}
+		out.Row(
+			view.Name,
+			fmt.Sprintf("%s:%d", view.Host, view.Port),
+			view.User,
+			valueOrDash(view.Database),
+			valueOrDash(strings.Join(view.Tags, ",")),
+			secret,
+		)
+	}
+
+	if format == table.CSV {
+		return out.Render(os.Stdout, format)
+	}
+	fmt.Println("\nSaved connection profiles:")
+	fmt.Println()
+	if err := out.Render(os.Stdout, format); err != nil {
+		return err
+	}
+	fmt.Printf("\nTotal: %d profiles\n", len(views))
+
+	return nil
+}
+
+// passwordSource describes where a profile's password comes from
+func passwordSource(profile config.ConnectionProfile) string {
+	if profile.Password != "" {
+		return "stored"
+	}
+	return "env"
+}
+
+// valueOrDash returns s, or "-" when it is empty
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
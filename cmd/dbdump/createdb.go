@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/ui"
+)
+
+var (
+	addCreateDatabase bool
+	addDropDatabase   bool
+)
+
+func init() {
+	dumpCmd.Flags().BoolVar(&addCreateDatabase, "add-create-database", false, "Start the dump with CREATE DATABASE IF NOT EXISTS and USE, so it restores onto a fresh server")
+	dumpCmd.Flags().BoolVar(&addDropDatabase, "add-drop-database", false, "Drop the database before creating it (implies --add-create-database; restoring removes tables not in the dump)")
+}
+
+// createDatabaseHeader returns the CREATE DATABASE and USE statements for
+// --add-create-database, or "" when it isn't set
+func createDatabaseHeader(inspector *database.Inspector, conn *database.Connection) (string, error) {
+	if !addCreateDatabase && !addDropDatabase {
+		return "", nil
+	}
+	if addDropDatabase {
+		ui.PrintWarning(fmt.Sprintf("--add-drop-database: restoring this dump drops the whole %s database first, including tables that are not in the dump", conn.Database))
+	}
+
+	charset, collation, err := inspector.GetDatabaseDefaults()
+	if err != nil {
+		return "", err
+	}
+	return database.CreateDatabaseStatements(conn.Database, charset, collation, addDropDatabase), nil
+}
+
+// renameCreatedDatabase makes -d the target of a dump that creates its own
+// database: unless --rename-database already maps the dump's database, it
+// is renamed to the target so CREATE DATABASE and USE don't write elsewhere
+func renameCreatedDatabase(databases map[string]string, header *dumpfile.Header) {
+	if header == nil || !header.CreatesDatabase || header.Database == "" || header.Database == dbName {
+		return
+	}
+	if _, ok := databases[header.Database]; ok {
+		return
+	}
+	ui.PrintInfo(fmt.Sprintf("The dump creates database %s; restoring it as %s", header.Database, dbName))
+	databases[header.Database] = dbName
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/spf13/cobra"
+)
+
+var databasesFormat string
+
+var databasesCmd = &cobra.Command{
+	Use:   "databases",
+	Short: "List the databases on the server",
+	Long: `List the databases visible to the user with their table count and size.
+System schemas (mysql, sys, information_schema, performance_schema) are
+marked; dumping one needs --system-database.`,
+	RunE: runDatabases,
+}
+
+func init() {
+	databasesCmd.Flags().StringVar(&databasesFormat, "format", "table", "Output format: table or json")
+	rootCmd.AddCommand(databasesCmd)
+}
+
+func runDatabases(cmd *cobra.Command, args []string) error {
+	if databasesFormat != "table" && databasesFormat != "json" {
+		return fmt.Errorf("unsupported --format %q (supported: table, json)", databasesFormat)
+	}
+
+	resolvePassword()
+
+	if user == "" {
+		return fmt.Errorf("database user is required (use -u or --user)")
+	}
+
+	conn := &database.Connection{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+	}
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return err
+	}
+
+	db, err := conn.ConnectContext(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	inspector, err := newInspector(cmd.Context(), db)
+	if err != nil {
+		return err
+	}
+	databases, err := inspector.ListDatabases()
+	if err != nil {
+		return err
+	}
+
+	if databasesFormat == "json" {
+		if databases == nil {
+			databases = []database.DatabaseInfo{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(databases)
+	}
+
+	nameWidth := 24
+	for _, info := range databases {
+		nameWidth = max(nameWidth, ui.DisplayWidth(info.Name))
+	}
+	nameWidth = min(nameWidth, 64)
+
+	fmt.Printf("\nDatabases on %s:\n\n", host)
+	fmt.Printf("%s %8s %12s  %s\n", ui.PadRight("Database", nameWidth), "Tables", "Size", "Notes")
+	fmt.Println(strings.Repeat("-", nameWidth+40))
+	for _, info := range databases {
+		notes := ""
+		if info.System {
+			notes = "system schema"
+		}
+		fmt.Printf("%s %8d %12s  %s\n", ui.PadRight(ui.Truncate(info.Name, nameWidth), nameWidth), info.Tables, database.FormatBytes(info.Size), notes)
+	}
+
+	fmt.Printf("\nTotal: %d databases\n", len(databases))
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dedupe"
+	"github.com/helgesverre/dbdump/internal/metadata"
+)
+
+var (
+	dedupeIdentical bool
+
+	// dedupeRegistry tracks the tables dumped so far in a run with
+	// --dedupe-identical
+	dedupeRegistry *dedupe.Registry
+)
+
+func init() {
+	dumpCmd.Flags().BoolVar(&dedupeIdentical, "dedupe-identical", false, "With several databases, dump tables identical to one already dumped in the run as a copy of it (those databases must then be restored in the same order)")
+}
+
+// startDedupe sets up --dedupe-identical for a run over databases
+func startDedupe(inspector *database.Inspector, databases []string) error {
+	if !dedupeIdentical {
+		return nil
+	}
+	all, err := inspector.ListBaseTables()
+	if err != nil {
+		return err
+	}
+	tables := make(map[string][]string, len(databases))
+	for _, name := range databases {
+		tables[name] = all[name]
+	}
+	dedupeRegistry = dedupe.NewRegistry(tables)
+	return nil
+}
+
+// dedupePlan is what --dedupe-identical does in one database's dump
+type dedupePlan struct {
+	copies []database.TableCopy
+	saved  map[string]int64
+
+	// sources are the candidate tables dumped in full, which later
+	// databases can copy once this dump succeeded
+	sources map[string]dedupe.Fingerprint
+}
+
+// planDedupe fingerprints the tables dumped in full by mysqldump whose name
+// is found in other databases of the run. Those identical to a table
+// already dumped are copied from it, when that is smaller than their data.
+func planDedupe(ctx context.Context, inspector *database.Inspector, all []database.TableInfo, excludes, skipped []string, masked []database.MaskedTable) (*dedupePlan, error) {
+	plan := &dedupePlan{saved: make(map[string]int64), sources: make(map[string]dedupe.Fingerprint)}
+	if dedupeRegistry == nil {
+		return plan, nil
+	}
+
+	read := maskedNames(masked)
+	for _, info := range all {
+		name := info.Name
+		if !dedupeRegistry.Candidate(name) || slices.Contains(excludes, name) || slices.Contains(skipped, name) || slices.Contains(read, name) {
+			continue
+		}
+		fingerprint, err := contentFingerprint(inspector, name)
+		if err != nil {
+			return nil, err
+		}
+		source, ok := dedupeRegistry.Source(name, fingerprint)
+		if !ok {
+			plan.sources[name] = fingerprint
+			continue
+		}
+
+		columns, err := inspector.GetColumns(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		copied := database.NewTableCopy(name, source.Database, columns)
+		if saved := source.Bytes - int64(len(copied.Statement())); saved > 0 {
+			plan.copies = append(plan.copies, copied)
+			plan.saved[name] = saved
+		}
+	}
+	return plan, nil
+}
+
+// contentFingerprint reads what decides whether two tables are identical:
+// the schema fingerprint, the exact row count and CHECKSUM TABLE
+func contentFingerprint(inspector *database.Inspector, table string) (dedupe.Fingerprint, error) {
+	create, err := inspector.GetCreateTable(table)
+	if err != nil {
+		return dedupe.Fingerprint{}, err
+	}
+	rows, checksum, err := inspector.ChecksumTable(table)
+	if err != nil {
+		return dedupe.Fingerprint{}, err
+	}
+	return dedupe.Fingerprint{Schema: database.TableFingerprint(create), Rows: rows, Checksum: checksum}, nil
+}
+
+// copiedFrom maps each copied table to the database it is copied from
+func (p *dedupePlan) copiedFrom() map[string]string {
+	from := make(map[string]string, len(p.copies))
+	for _, c := range p.copies {
+		from[c.Table] = c.From
+	}
+	return from
+}
+
+// tables returns the names of the copied tables
+func (p *dedupePlan) tables() []string {
+	names := make([]string, len(p.copies))
+	for i, c := range p.copies {
+		names[i] = c.Table
+	}
+	return names
+}
+
+// record adds the plan to the run once the dump succeeded (or was planned,
+// for a dry run): its copies, and its candidate tables as sources with
+// their size in the dump; tables missing from sizes aren't sources
+func (p *dedupePlan) record(sizes map[string]int64) {
+	if dedupeRegistry == nil {
+		return
+	}
+	for _, c := range p.copies {
+		dedupeRegistry.AddCopy(dbName, dedupe.Copy{Table: c.Table, From: c.From, Saved: p.saved[c.Table]})
+	}
+	for table, fingerprint := range p.sources {
+		if size, ok := sizes[table]; ok {
+			dedupeRegistry.AddSource(dbName, table, fingerprint, size)
+		}
+	}
+}
+
+// dumpedSizes returns the size of each table's data in a finished dump;
+// none when the dump is partial, since restore refuses it
+func dumpedSizes(result *database.DumpResult, truncated []metadata.TruncatedTable) map[string]int64 {
+	sizes := make(map[string]int64, len(result.TableTimings))
+	if len(truncated) > 0 {
+		return sizes
+	}
+	for _, timing := range result.TableTimings {
+		sizes[timing.Table] = timing.Bytes
+	}
+	return sizes
+}
+
+// estimatedSizes returns each table's data size on the server, for a dry run
+func estimatedSizes(tables []database.TableInfo) map[string]int64 {
+	sizes := make(map[string]int64, len(tables))
+	for _, info := range tables {
+		sizes[info.Name] = info.DataSize
+	}
+	return sizes
+}
+
+// recordCopies notes the copied tables in the sidecar
+func recordCopies(meta *metadata.Metadata, plan *dedupePlan) {
+	from := plan.copiedFrom()
+	for i := range meta.Tables {
+		meta.Tables[i].CopyOf = from[meta.Tables[i].Name]
+	}
+}
+
+// dedupeSummary describes what --dedupe-identical saved in a run, or ""
+func dedupeSummary(report *runReport) string {
+	copies, saved := 0, int64(0)
+	for _, entry := range report.Databases {
+		copies += len(entry.Deduplicated)
+		saved += entry.SavedBytes
+	}
+	if copies == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d identical tables dumped as copies, saving about %s; restore the databases in the order above", copies, database.FormatBytes(saved))
+}
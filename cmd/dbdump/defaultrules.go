@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/history"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// defaultRules returns the built-in exclusion rules, pinned by
+// default_rules_version in the project config, or else the global config
+func defaultRules() (*config.DefaultConfig, error) {
+	version, source := 0, ""
+	if globalConfig, err := config.LoadGlobalConfig(); err == nil && globalConfig != nil && globalConfig.DefaultRulesVersion != 0 {
+		version, source = globalConfig.DefaultRulesVersion, "~/.dbdump.yaml"
+	}
+	if len(configFiles) > 0 {
+		if projectConfig, err := loadProjectConfig(); err == nil && projectConfig.DefaultRulesVersion != 0 {
+			version, source = projectConfig.DefaultRulesVersion, configSource()
+		}
+	}
+
+	defaults, err := config.LoadDefaultsVersion(version)
+	if err != nil {
+		return nil, &dberrors.ErrConfigInvalid{Source: source, Problems: []string{"default_rules_version: " + err.Error()}}
+	}
+	return defaults, nil
+}
+
+// checkDefaultRules returns the version of the built-in rules the dump
+// applies (0 for system databases, which get none), and tells when it
+// differs from the version the last dump of the database used, with the
+// rules that changed
+func checkDefaultRules(conn *database.Connection) (int, error) {
+	if database.IsSystemDatabase(conn.Database) {
+		return 0, nil
+	}
+	defaults, err := defaultRules()
+	if err != nil {
+		return 0, err
+	}
+	if defaults.Version != config.DefaultRulesVersion {
+		ui.PrintInfo(fmt.Sprintf("Built-in exclusions pinned to v%d by default_rules_version (this release has v%d)", defaults.Version, config.DefaultRulesVersion))
+	}
+
+	entries, err := history.Load()
+	if err != nil {
+		diag.Warnf("%v", err)
+		return defaults.Version, nil
+	}
+	last := history.LastRulesVersion(history.ForConnection(entries, conn))
+	if last == 0 || last == defaults.Version {
+		return defaults.Version, nil
+	}
+
+	previous, err := config.LoadDefaultsVersion(last)
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("The last dump of %s used built-in exclusions v%d, which this release doesn't ship; now using v%d", conn.Database, last, defaults.Version))
+		return defaults.Version, nil
+	}
+	added, removed := config.DiffRules(previous.DefaultExcludes, defaults.DefaultExcludes)
+	if len(added) == 0 && len(removed) == 0 {
+		return defaults.Version, nil
+	}
+	var changes []string
+	for _, rule := range added {
+		changes = append(changes, "+ "+rule)
+	}
+	for _, rule := range removed {
+		changes = append(changes, "- "+rule)
+	}
+	ui.PrintWarning(fmt.Sprintf("Built-in exclusions changed since the last dump of %s (v%d → v%d): %s; pin the old ones with default_rules_version: %d",
+		conn.Database, last, defaults.Version, strings.Join(changes, ", "), last))
+	return defaults.Version, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/history"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// TestCheckDefaultRules runs checkDefaultRules over synthetic dump
+// histories: the notice names the rules that changed since the last dump
+// of the database, and default_rules_version pins an older version
+func TestCheckDefaultRules(t *testing.T) {
+	savedConfigs, savedOutput := configFiles, diag.Output
+	defer func() {
+		configFiles, diag.Output = savedConfigs, savedOutput
+		diag.Default.Reset()
+	}()
+	var output bytes.Buffer
+	diag.Output = &output
+
+	conn := &database.Connection{Host: "127.0.0.1", Port: 3306, Database: "shop"}
+	entry := func(db string, version int) history.Entry {
+		return history.Entry{Host: "localhost", Port: 3306, Database: db, RulesVersion: version}
+	}
+
+	tests := []struct {
+		name     string
+		history  []history.Entry
+		global   int // default_rules_version in ~/.dbdump.yaml
+		project  int // default_rules_version in --config
+		db       string
+		want     int
+		warnings []string // substrings of the one warning, none if empty
+		wantErr  bool
+	}{
+		{name: "first dump", want: config.DefaultRulesVersion},
+		{name: "same version", history: []history.Entry{entry("shop", 3)}, want: 3},
+		{
+			name:    "from v1",
+			history: []history.Entry{entry("shop", 1)},
+			want:    3,
+			warnings: []string{
+				"last dump of shop (v1 → v3)",
+				"+ audits", "+ cache_locks", "+ pulse_entries", "+ pulse_*",
+				"default_rules_version: 1",
+			},
+		},
+		{
+			name:     "latest versioned entry",
+			history:  []history.Entry{entry("shop", 1), entry("shop", 2), entry("shop", 0)},
+			want:     3,
+			warnings: []string{"(v2 → v3)", "+ pulse_aggregates"},
+		},
+		{
+			name:    "other databases ignored",
+			history: []history.Entry{entry("shop", 3), entry("blog", 1)},
+			want:    3,
+		},
+		{
+			name:    "other servers ignored",
+			history: []history.Entry{{Host: "db.example.com", Port: 3306, Database: "shop", RulesVersion: 1}},
+			want:    3,
+		},
+		{
+			name:     "version not shipped",
+			history:  []history.Entry{entry("shop", 9)},
+			want:     3,
+			warnings: []string{"used built-in exclusions v9", "doesn't ship", "now using v3"},
+		},
+		{name: "pinned globally", history: []history.Entry{entry("shop", 1)}, global: 1, want: 1},
+		{
+			name:     "pinned by project over global",
+			history:  []history.Entry{entry("shop", 3)},
+			global:   1,
+			project:  2,
+			want:     2,
+			warnings: []string{"(v3 → v2)", "- pulse_entries", "- pulse_*", "default_rules_version: 3"},
+		},
+		{name: "pinned to unknown version", global: 7, wantErr: true},
+		{name: "system database", db: "mysql", history: []history.Entry{entry("mysql", 1)}, global: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diag.Default.Reset()
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			writePin := func(path string, version int) {
+				t.Helper()
+				if err := os.WriteFile(path, []byte("default_rules_version: "+strconv.Itoa(version)+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.global != 0 {
+				writePin(filepath.Join(home, ".dbdump.yaml"), tt.global)
+			}
+			configFiles = nil
+			if tt.project != 0 {
+				project := filepath.Join(t.TempDir(), ".dbdump.yaml")
+				writePin(project, tt.project)
+				configFiles = []string{project}
+			}
+			for _, e := range tt.history {
+				if err := history.Append(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := *conn
+			if tt.db != "" {
+				c.Database = tt.db
+			}
+			version, err := checkDefaultRules(&c)
+			if tt.wantErr {
+				var invalid *dberrors.ErrConfigInvalid
+				if !errors.As(err, &invalid) {
+					t.Fatalf("err = %v, want ErrConfigInvalid", err)
+				}
+				if !strings.Contains(err.Error(), "v1, v2, v3") {
+					t.Errorf("error %q doesn't list the shipped versions", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.want {
+				t.Errorf("version = %d, want %d", version, tt.want)
+			}
+
+			warnings := diag.Warnings()
+			if len(tt.warnings) == 0 {
+				if len(warnings) != 0 {
+					t.Errorf("warnings = %q, want none", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 {
+				t.Fatalf("warnings = %q, want one", warnings)
+			}
+			for _, want := range tt.warnings {
+				if !strings.Contains(warnings[0].Message, want) {
+					t.Errorf("warning %q doesn't mention %q", warnings[0].Message, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/verify"
+	"github.com/spf13/cobra"
+)
+
+var checkReadOnly bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment for required and optional tools",
+	Long: `Check that mysqldump and the mysql client are available, whether Docker can be
+used for --verify=restore, and (when connection flags are given) that the
+database is reachable.
+
+--check-read-only verifies that a read-only session (--read-only-source)
+really rejects writes, by attempting a no-op DELETE inside a transaction that
+is always rolled back.`,
+	RunE: runDoctor,
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&checkReadOnly, "check-read-only", false, "Verify that read-only sessions reject writes (attempts a rolled-back no-op write)")
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	fmt.Println("\nChecking environment:")
+
+	failed := false
+
+	// Required: mysqldump
+	if err := database.CheckMySQLDump(); err != nil {
+		fmt.Printf("  %s mysqldump: %v\n", ui.FailureMark(), err)
+		failed = true
+	} else {
+		fmt.Printf("  %s mysqldump: %s\n", ui.SuccessMark(), toolVersion("mysqldump"))
+	}
+
+	// Optional: mysql client
+	if _, err := exec.LookPath("mysql"); err != nil {
+		fmt.Println("  - mysql client: not found in PATH (optional)")
+	} else {
+		fmt.Printf("  %s mysql client: %s\n", ui.SuccessMark(), toolVersion("mysql"))
+	}
+
+	// Optional: Docker, used by --verify=restore
+	if err := verify.CheckDocker(); err != nil {
+		fmt.Printf("  - docker: %v (--verify=restore will be skipped)\n", err)
+	} else {
+		fmt.Printf("  %s docker: available (--verify=restore supported)\n", ui.SuccessMark())
+	}
+
+	// Connection check, only when enough flags are given
+	resolvePassword()
+	if user != "" && dbName != "" {
+		conn := &database.Connection{
+			Host:     host,
+			Port:     port,
+			User:     user,
+			Password: password,
+			Database: dbName,
+		}
+
+		db, err := conn.ConnectContext(cmd.Context())
+		if err != nil {
+			fmt.Printf("  %s connection to %s:%d/%s: %v\n", ui.FailureMark(), host, port, dbName, err)
+			failed = true
+		} else {
+			version, err := database.NewInspector(db).WithContext(cmd.Context()).GetServerVersion()
+			if err != nil {
+				version = "unknown version"
+			}
+			fmt.Printf("  %s connection to %s:%d/%s: %s\n", ui.SuccessMark(), host, port, dbName, version)
+			if !checkTableInformation(cmd.Context(), db) {
+				failed = true
+			}
+			if err := db.Close(); err != nil {
+				diag.Warnf("failed to close database connection: %v", err)
+			}
+
+			if checkReadOnly && !checkReadOnlySession(cmd.Context(), conn) {
+				failed = true
+			}
+		}
+	} else {
+		fmt.Println("  - connection: skipped (pass -u and -d to test)")
+	}
+
+	fmt.Println()
+
+	if failed {
+		return fmt.Errorf("one or more required checks failed")
+	}
+	return nil
+}
+
+// checkTableInformation reads the table list and reports which metadata
+// source answered
+func checkTableInformation(ctx context.Context, db *sql.DB) bool {
+	inspector, err := newInspector(ctx, db)
+	if err != nil {
+		fmt.Printf("  %s table information: %v\n", ui.FailureMark(), err)
+		return false
+	}
+	tablesInfo, err := inspector.GetAllTablesInfo()
+	if err != nil {
+		fmt.Printf("  %s table information: %v\n", ui.FailureMark(), err)
+		return false
+	}
+
+	if reason := inspector.Degraded(); reason != "" {
+		fmt.Printf("  - table information: %d tables from %s (%s)\n", len(tablesInfo), inspector.Source(), reason)
+	} else {
+		fmt.Printf("  %s table information: %d tables from %s\n", ui.SuccessMark(), len(tablesInfo), inspector.Source())
+	}
+	return true
+}
+
+// checkReadOnlySession opens a read-only session and checks that a write is rejected
+func checkReadOnlySession(ctx context.Context, conn *database.Connection) bool {
+	readOnly := *conn
+	readOnly.ReadOnly = true
+
+	db, err := readOnly.ConnectContext(ctx)
+	if err != nil {
+		fmt.Printf("  %s read-only session: %v\n", ui.FailureMark(), err)
+		return false
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	tablesInfo, err := database.NewInspector(db).WithContext(ctx).GetAllTablesInfo()
+	if err != nil {
+		fmt.Printf("  %s read-only session: %v\n", ui.FailureMark(), err)
+		return false
+	}
+
+	// Views have no engine; test against a base table
+	table := ""
+	for _, info := range tablesInfo {
+		if info.Engine != "" {
+			table = info.Name
+			break
+		}
+	}
+	if table == "" {
+		fmt.Println("  - read-only session: skipped (the database has no tables to test against)")
+		return true
+	}
+
+	if err := database.CheckReadOnly(db, table); err != nil {
+		fmt.Printf("  %s read-only session: %v\n", ui.FailureMark(), err)
+		return false
+	}
+	fmt.Printf("  %s read-only session: writes are rejected\n", ui.SuccessMark())
+	return true
+}
+
+// toolVersion returns the first line of a tool's --version output
+func toolVersion(name string) string {
+	out, err := exec.Command(name, "--version").Output()
+	if err != nil {
+		return "available"
+	}
+	return strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/donefile"
+)
+
+// doneFile is the completion signal written with --done-file
+var doneFile string
+
+func init() {
+	dumpCmd.Flags().StringVar(&doneFile, "done-file", "", "Write this JSON file (output path, checksum, time) once the dump and its sidecar are fully written; removed at startup")
+}
+
+// prepareDoneFile rejects --done-file where there is no single dump file to
+// signal, and removes a done-file left by an earlier run
+func prepareDoneFile() error {
+	if doneFile == "" {
+		return nil
+	}
+	if storeDir != "" {
+		return fmt.Errorf("--done-file cannot be combined with --store, which leaves no dump file behind")
+	}
+	abs, err := filepath.Abs(doneFile)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	doneFile = abs
+	return donefile.Clear(donefile.OS, doneFile)
+}
+
+// writeDoneFile signals that the dump is complete; it must be the last
+// thing a successful dump writes. sidecar is empty when none was written.
+func writeDoneFile(result *database.DumpResult, sidecar string) error {
+	if doneFile == "" {
+		return nil
+	}
+	signal := donefile.Signal{
+		OutputFile:  result.OutputFile,
+		Sidecar:     sidecar,
+		CompletedAt: time.Now().UTC(),
+	}
+	if len(result.Parts) > 0 {
+		for _, part := range result.Parts {
+			signal.Parts = append(signal.Parts, donefile.Part{Path: part.Path, Size: part.Size, SHA256: part.SHA256})
+			signal.Size += part.Size
+		}
+	} else {
+		sum, size, err := donefile.Checksum(result.OutputFile)
+		if err != nil {
+			return fmt.Errorf("failed to checksum the dump for --done-file: %w", err)
+		}
+		signal.SHA256, signal.Size = sum, size
+	}
+	return donefile.Write(donefile.OS, doneFile, signal)
+}
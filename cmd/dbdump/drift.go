@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/prompt"
+)
+
+var strictPlan bool
+
+func init() {
+	dumpCmd.Flags().BoolVar(&strictPlan, "strict-plan", false, "Abort if tables appeared or disappeared between selecting the tables and dumping them")
+}
+
+// newTableAction is what the selection rules do with a table that was not
+// there when the dump was planned
+type newTableAction int
+
+const (
+	newTableDumped   newTableAction = iota // no rule covers it: dumped fully
+	newTableExcluded                       // an exclusion rule excludes its data
+	newTableSkipped                        // outside the only rules: skipped entirely
+)
+
+// classifyNewTable applies the selection rules to a table that appeared
+// after planning; rule is the exclusion rule for newTableExcluded
+func classifyNewTable(sel *planner.Selection, table string) (action newTableAction, rule string) {
+	switch {
+	case sel.Only != nil && !sel.Only.Matches(table):
+		return newTableSkipped, ""
+	case sel.Data != nil && sel.Data.Matches(table):
+		return newTableExcluded, sel.Data.MatchingRule(table)
+	}
+	return newTableDumped, ""
+}
+
+// tableDrift is how the tables changed between planning and execution,
+// with the rules applied to the new ones
+type tableDrift struct {
+	removed  []string          // planned tables that no longer exist
+	dumped   []string          // new tables no rule covers
+	excluded []string          // new tables whose data a rule excludes
+	skipped  []string          // new tables outside the only rules
+	rules    map[string]string // the rule excluding each of excluded
+}
+
+// diffTables compares the planned tables with the current ones and decides
+// what happens to each new table; both lists keep their order
+func diffTables(planned, current []string, sel *planner.Selection) tableDrift {
+	wasPlanned := make(map[string]bool, len(planned))
+	for _, table := range planned {
+		wasPlanned[table] = true
+	}
+	exists := make(map[string]bool, len(current))
+	for _, table := range current {
+		exists[table] = true
+	}
+
+	drift := tableDrift{rules: make(map[string]string)}
+	for _, table := range current {
+		if wasPlanned[table] {
+			continue
+		}
+		switch action, rule := classifyNewTable(sel, table); action {
+		case newTableSkipped:
+			drift.skipped = append(drift.skipped, table)
+		case newTableExcluded:
+			drift.excluded = append(drift.excluded, table)
+			drift.rules[table] = rule
+		default:
+			drift.dumped = append(drift.dumped, table)
+		}
+	}
+	for _, table := range planned {
+		if !exists[table] {
+			drift.removed = append(drift.removed, table)
+		}
+	}
+	return drift
+}
+
+// added returns the new tables
+func (d tableDrift) added() []string {
+	return slices.Concat(d.dumped, d.excluded, d.skipped)
+}
+
+// empty reports whether the tables are unchanged
+func (d tableDrift) empty() bool {
+	return len(d.removed) == 0 && len(d.added()) == 0
+}
+
+// checkTableDrift lists the tables again right before the dump and brings
+// the selection up to date: new tables go through the rules (in interactive
+// mode the user decides about those no rule covers), removed tables are
+// dropped from the selection. It returns the updated exclusions and both
+// table snapshots; with --strict-plan any change is an error.
+func checkTableDrift(inspector *database.Inspector, sel *planner.Selection, excludes []string, plannedAt time.Time, interactive bool) ([]string, *metadata.TableSnapshots, error) {
+	current, err := inspector.ListTables()
+	if err != nil {
+		return nil, nil, err
+	}
+	planned := make([]string, len(sel.All))
+	for i, info := range sel.All {
+		planned[i] = info.Name
+	}
+	snapshots := &metadata.TableSnapshots{Planned: planned, PlannedAt: plannedAt, Executed: current, ExecutedAt: time.Now()}
+
+	drift := diffTables(planned, current, sel)
+	if drift.empty() {
+		return excludes, snapshots, nil
+	}
+	if strictPlan {
+		return nil, nil, &dberrors.ErrTablesChanged{Added: drift.added(), Removed: drift.removed}
+	}
+
+	if len(drift.removed) > 0 {
+		ui.PrintWarning(fmt.Sprintf("%d tables were dropped since the dump was planned and are left out: %s",
+			len(drift.removed), strings.Join(drift.removed, ", ")))
+	}
+	for _, table := range drift.skipped {
+		ui.PrintInfo(fmt.Sprintf("New table %s appeared since the dump was planned; skipping it (not selected)", table))
+	}
+	for _, table := range drift.excluded {
+		ui.PrintInfo(fmt.Sprintf("New table %s appeared since the dump was planned; excluding its data (matches exclusion rule %s)", table, drift.rules[table]))
+	}
+	sel.PreSelected = planner.AppendMissing(sel.PreSelected, drift.excluded...)
+	if len(drift.dumped) > 0 {
+		question := fmt.Sprintf("%d new tables appeared since the tables were selected and match no rule: %s. Dump their data?",
+			len(drift.dumped), strings.Join(drift.dumped, ", "))
+		if !interactive {
+			ui.PrintWarning(fmt.Sprintf("%d new tables appeared since the dump was planned and match no rule; their data will be dumped: %s",
+				len(drift.dumped), strings.Join(drift.dumped, ", ")))
+		} else if ok, err := prompt.Confirm(prompt.NewTables, question); err != nil {
+			return nil, nil, err
+		} else if !ok {
+			drift.excluded = append(drift.excluded, drift.dumped...)
+			drift.dumped = nil
+		}
+	}
+
+	// New tables get what information is available; their sizes only
+	// matter for the estimate
+	for _, table := range drift.added() {
+		info, err := inspector.GetTableInfo(table)
+		if err != nil {
+			info = &database.TableInfo{Name: table, SizeUnknown: true, SizeDisplay: database.SizeUnavailable}
+		}
+		sel.All = append(sel.All, *info)
+		if !slices.Contains(drift.skipped, table) {
+			sel.Tables = append(sel.Tables, *info)
+		}
+	}
+	sel.All = planner.WithoutTables(sel.All, drift.removed)
+	sel.Tables = planner.WithoutTables(sel.Tables, drift.removed)
+	sel.Skipped = planner.AppendMissing(withoutNames(sel.Skipped, drift.removed), drift.skipped...)
+	return planner.AppendMissing(withoutNames(excludes, drift.removed), drift.excluded...), snapshots, nil
+}
+
+// withoutNames returns names without those in remove
+func withoutNames(names, remove []string) []string {
+	var rest []string
+	for _, name := range names {
+		if !slices.Contains(remove, name) {
+			rest = append(rest, name)
+		}
+	}
+	return rest
+}
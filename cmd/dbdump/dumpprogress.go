@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui"
+)
+
+// dumpProgress shows the data phase as "table X of Y" with the SQL written
+// against the estimate from table statistics
+type dumpProgress struct {
+	tracker  *ui.ProgressTracker
+	table    string
+	estimate int64
+}
+
+// update moves the progress bar; it is created on the first update so
+// nothing is drawn while the structure is dumped
+func (p *dumpProgress) update(progress database.DumpProgress) {
+	if p.tracker == nil {
+		p.estimate = progress.EstimatedBytes
+		if p.estimate <= 0 {
+			p.estimate = -1 // unknown sizes: a spinner with the bytes written
+		}
+		p.tracker = ui.NewProgressTracker("Dumping data", p.estimate)
+	}
+	if progress.Table != p.table {
+		p.table = progress.Table
+		p.tracker.Describe(fmt.Sprintf("Dumping %s (table %d of %d)", progress.Table, progress.Index, progress.Count))
+	}
+
+	// Statistics underestimate some tables; hold the bar short of the end
+	// until the dump is done
+	bytes := progress.Bytes
+	if p.estimate > 0 {
+		bytes = min(bytes, p.estimate*99/100)
+	}
+	_ = p.tracker.Set64(bytes)
+}
+
+// finish completes the progress bar, if one was drawn
+func (p *dumpProgress) finish(succeeded bool) {
+	if p.tracker == nil {
+		return
+	}
+	if !succeeded {
+		_ = p.tracker.Clear()
+		fmt.Println()
+		return
+	}
+	if p.estimate > 0 {
+		_ = p.tracker.Set64(p.estimate)
+	}
+	_ = p.tracker.Finish()
+}
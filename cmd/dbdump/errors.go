@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+// Exit codes returned by the CLI
+const (
+	exitGeneric            = 1
+	exitConfigInvalid      = 2
+	exitConnectionFailed   = 3
+	exitMySQLDumpNotFound  = 4
+	exitVerificationFailed = 5
+	exitWarnings           = 6
+	exitMySQLDumpRejected  = 7
+	exitMySQLDumpFailed    = 8
+	exitPartialFailure     = 9
+	exitInterrupted        = 130
+)
+
+// interrupted reports whether err stems from Ctrl+C or SIGTERM: a stopped
+// dump, or a query, connection or picker cancelled with the command context
+func interrupted(err error) bool {
+	return errors.Is(err, dberrors.ErrDumpInterrupted) || errors.Is(err, context.Canceled)
+}
+
+// classifyError maps an error to an exit code and an optional hint for the user
+func classifyError(err error) (int, string) {
+	var connErr *dberrors.ErrConnectionFailed
+	var verifyErr *dberrors.ErrVerificationFailed
+	var configErr *dberrors.ErrConfigInvalid
+	var restoreErr *database.RestoreError
+	var chainErr *chainRestoreError
+	var outputErr *dberrors.ErrOutputPath
+	var fullErr *dberrors.ErrOutputFull
+	var defErr *dberrors.ErrTableDefChanged
+	var warningsErr *dberrors.ErrWarnings
+	var dumpErr *dberrors.ErrMySQLDumpFailed
+	var partialErr *dberrors.ErrPartialFailure
+	var changedErr *dberrors.ErrTablesChanged
+	var budgetErr *dberrors.ErrOverBudget
+
+	switch {
+	case interrupted(err):
+		return exitInterrupted, "the operation was interrupted; any output it produced is incomplete"
+	case errors.Is(err, dberrors.ErrMySQLDumpNotFound):
+		return exitMySQLDumpNotFound, "install the MySQL client tools (mysqldump) and make sure they are on your PATH"
+	case errors.As(err, &connErr) && awsIAMActive && connErr.Code == 1045:
+		return exitConnectionFailed, awsIAMHint
+	case errors.As(err, &connErr):
+		return exitConnectionFailed, connectionHint(connErr.Code)
+	case errors.As(err, &verifyErr):
+		return exitVerificationFailed, "the dump did not pass verification; do not rely on it until the cause is fixed"
+	case errors.As(err, &chainErr) && errors.As(err, &restoreErr) && chainErr.Increment:
+		return exitGeneric, fmt.Sprintf("the dumps before %[1]s were applied; after fixing the problem, resume with --increment-only --start-offset %[2]d on %[1]s, then restore the increments after it with --increment-only", filepath.Base(chainErr.File), restoreErr.Offset)
+	case errors.As(err, &chainErr) && errors.As(err, &restoreErr):
+		return exitGeneric, fmt.Sprintf("after fixing the problem, resume with --start-offset %d on %s, then restore its increments in order with --increment-only", restoreErr.Offset, filepath.Base(chainErr.File))
+	case errors.As(err, &restoreErr):
+		return exitGeneric, fmt.Sprintf("after fixing the problem, resume with --start-offset %d", restoreErr.Offset)
+	case errors.As(err, &defErr):
+		return exitGeneric, "a schema migration ran during the dump; retry once it has finished, or raise --table-def-retries (split dumps are not restarted)"
+	case errors.As(err, &dumpErr) && dumpErr.Usage:
+		return exitMySQLDumpRejected, "the installed mysqldump does not support an option dbdump passes; install a newer MySQL client (or one matching the server's flavor)"
+	case errors.As(err, &dumpErr) && keepPartial:
+		return exitMySQLDumpFailed, "mysqldump failed partway through; the incomplete output was kept as *.partial for inspection"
+	case errors.As(err, &dumpErr):
+		return exitMySQLDumpFailed, "mysqldump failed partway through and its output was removed; rerun with --keep-partial to keep it for inspection"
+	case errors.As(err, &changedErr):
+		return exitGeneric, "a migration ran while the dump was being planned; rerun to plan against the current tables, or drop --strict-plan to apply the rules to new tables"
+	case errors.As(err, &fullErr) && fullErr.NoSpace:
+		return exitGeneric, "free up space, or write the dump to another disk with -o/--output"
+	case errors.As(err, &fullErr):
+		return exitGeneric, "split the dump into smaller files with --max-file-size (e.g. 4000MB for FAT32), or write it to another filesystem"
+	case errors.As(err, &outputErr):
+		return exitGeneric, "choose another location with -o/--output or fix the directory permissions"
+	case errors.As(err, &configErr):
+		return exitConfigInvalid, "check the configuration file and flag values"
+	case errors.As(err, &partialErr):
+		return exitPartialFailure, "the other databases were dumped; the run report lists what failed and why"
+	case errors.As(err, &budgetErr):
+		return exitWarnings, "the dump is valid but lacks the data of the tables listed above; raise --time-budget or list the tables that matter in priority_tables"
+	case errors.As(err, &warningsErr):
+		return exitWarnings, "the run finished, but --warnings-as-errors treats the warnings listed above as a failure"
+	}
+
+	return exitGeneric, ""
+}
+
+// connectionHint returns a hint for common MySQL connection error numbers
+func connectionHint(code uint16) string {
+	switch code {
+	case 1044, 1045:
+		return "check the user name and password (DBDUMP_MYSQL_PWD or MYSQL_PWD)"
+	case 1049:
+		return "the database does not exist; check the -d/--database value"
+	case 1130:
+		return "the server does not allow connections from this host for this user"
+	case 0:
+		return "check that the server is running and reachable at the given host and port"
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+// TestClassifyError checks the exit code of each error class once wrapped
+// the way the call sites wrap them
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain", errors.New("boom"), exitGeneric},
+		{"interrupted", fmt.Errorf("%w: mysqldump data: %w", dberrors.ErrDumpInterrupted, context.Canceled), exitInterrupted},
+		{"cancelled query", fmt.Errorf("list tables: %w", context.Canceled), exitInterrupted},
+		{"mysqldump not found", fmt.Errorf("%w: %w", dberrors.ErrMySQLDumpNotFound, exec.ErrNotFound), exitMySQLDumpNotFound},
+		{"connection", &dberrors.ErrConnectionFailed{Code: 1045, Err: errors.New("denied")}, exitConnectionFailed},
+		{"verification", &dberrors.ErrVerificationFailed{Checks: []string{"footer"}}, exitVerificationFailed},
+		{"config", &dberrors.ErrConfigInvalid{Problems: []string{"bad"}}, exitConfigInvalid},
+		{"mysqldump rejected", &dberrors.ErrMySQLDumpFailed{Phase: "data", Usage: true, Err: errors.New("exit 7")}, exitMySQLDumpRejected},
+		{"mysqldump failed", &dberrors.ErrMySQLDumpFailed{Phase: "data", Err: errors.New("exit 2")}, exitMySQLDumpFailed},
+		{"interrupted mysqldump", &dberrors.ErrMySQLDumpFailed{Phase: "data", Err: fmt.Errorf("%w: %w", dberrors.ErrDumpInterrupted, context.Canceled)}, exitInterrupted},
+		{"partial", &dberrors.ErrPartialFailure{Failed: 1, Total: 2}, exitPartialFailure},
+		{"warnings", &dberrors.ErrWarnings{Count: 2}, exitWarnings},
+		{"over budget", &dberrors.ErrOverBudget{Tables: []string{"audit_log"}}, exitWarnings},
+		{"output full", &dberrors.ErrOutputFull{Path: "shop.sql", NoSpace: true, Err: errors.New("ENOSPC")}, exitGeneric},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, err := range []error{tt.err, fmt.Errorf("dump shop: %w", tt.err)} {
+				if got, _ := classifyError(err); got != tt.want {
+					t.Errorf("classifyError(%q) = %d, want %d", err, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestClassifyRestoreError checks that --start-offset is only suggested
+// where restore accepts it: increments need --increment-only with it
+func TestClassifyRestoreError(t *testing.T) {
+	failed := &database.RestoreError{Offset: 4096, Line: 12, Err: errors.New("duplicate key")}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"single dump", failed, "resume with --start-offset 4096"},
+		{"base of a chain", &chainRestoreError{File: "/dumps/shop.sql", Err: failed},
+			"resume with --start-offset 4096 on shop.sql, then restore its increments in order with --increment-only"},
+		{"increment", &chainRestoreError{File: "/dumps/shop.inc2.sql", Increment: true, Err: failed},
+			"resume with --increment-only --start-offset 4096 on shop.inc2.sql"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, hint := classifyError(fmt.Errorf("restore: %w", tt.err))
+			if code != exitGeneric || !strings.Contains(hint, tt.want) {
+				t.Errorf("classifyError() = %d, %q, want a hint with %q", code, hint, tt.want)
+			}
+		})
+	}
+}
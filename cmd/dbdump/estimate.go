@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui"
+)
+
+// defaultSizeWarningFactor is how far the dump size may differ from the estimate before warning
+const defaultSizeWarningFactor = 3.0
+
+// minEstimateForWarning avoids noisy warnings for tiny dumps, where DDL dominates
+const minEstimateForWarning = 1024 * 1024
+
+// checkSizeEstimate prints a notice when the dump size differs from the
+// estimate by more than the configured factor in either direction
+func checkSizeEstimate(estimate, actual int64) {
+	factor := sizeWarningFactor()
+	if estimate < minEstimateForWarning || actual <= 0 || factor <= 1 {
+		return
+	}
+
+	ratio := float64(actual) / float64(estimate)
+	switch {
+	case ratio > factor:
+		ui.PrintWarning(fmt.Sprintf("Dump is %.1fx larger than estimated (%s vs %s). Likely causes: stale table statistics undercounting rows, or BLOB/binary columns expanding as hex.",
+			ratio, database.FormatBytes(actual), database.FormatBytes(estimate)))
+	case ratio < 1/factor:
+		ui.PrintWarning(fmt.Sprintf("Dump is %.1fx smaller than estimated (%s vs %s). Likely causes: stale statistics after large deletes (fragmented tables report more data than they hold), or more tables excluded than expected.",
+			1/ratio, database.FormatBytes(actual), database.FormatBytes(estimate)))
+	default:
+		return
+	}
+	ui.PrintInfo("Running ANALYZE TABLE on the largest tables refreshes the statistics the estimate is based on")
+}
+
+// sizeWarningFactor returns size_warning_factor from the project or global config
+func sizeWarningFactor() float64 {
+	if len(configFiles) > 0 {
+		if projectConfig, err := loadProjectConfig(); err == nil && projectConfig.SizeWarningFactor > 0 {
+			return projectConfig.SizeWarningFactor
+		}
+	}
+	if globalConfig, err := config.LoadGlobalConfig(); err == nil && globalConfig != nil && globalConfig.SizeWarningFactor > 0 {
+		return globalConfig.SizeWarningFactor
+	}
+	return defaultSizeWarningFactor
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+func TestCheckSizeEstimate(t *testing.T) {
+	savedConfigs, savedOutput := configFiles, diag.Output
+	defer func() {
+		configFiles, diag.Output = savedConfigs, savedOutput
+		diag.Default.Reset()
+	}()
+	diag.Output = &strings.Builder{}
+	configFiles = nil
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	const mb = 1024 * 1024
+	tests := []struct {
+		name     string
+		config   string
+		estimate int64
+		actual   int64
+		want     string // start of the warning, "" for none
+	}{
+		{name: "close", estimate: 100 * mb, actual: 250 * mb},
+		{name: "larger", estimate: 100 * mb, actual: 350 * mb, want: "Dump is 3.5x larger than estimated (350.0 MB vs 100.0 MB)"},
+		{name: "smaller", estimate: 100 * mb, actual: 25 * mb, want: "Dump is 4.0x smaller than estimated (25.0 MB vs 100.0 MB)"},
+		{name: "tiny estimate", estimate: mb - 1, actual: 100 * mb},
+		{name: "no output", estimate: 100 * mb, actual: 0},
+		{name: "configured factor", config: "size_warning_factor: 2\n", estimate: 100 * mb, actual: 250 * mb, want: "Dump is 2.5x larger"},
+		{name: "factor of one disables", config: "size_warning_factor: 1\n", estimate: 100 * mb, actual: 900 * mb},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diag.Default.Reset()
+			configPath := filepath.Join(home, ".dbdump.yaml")
+			_ = os.Remove(configPath)
+			if tt.config != "" {
+				if err := os.WriteFile(configPath, []byte(tt.config), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			checkSizeEstimate(tt.estimate, tt.actual)
+
+			warnings := diag.Default.Warnings()
+			if tt.want == "" {
+				if len(warnings) > 0 {
+					t.Errorf("unexpected warning %q", warnings[0].Message)
+				}
+				return
+			}
+			if len(warnings) != 1 || !strings.HasPrefix(warnings[0].Message, tt.want) {
+				t.Errorf("warnings = %+v, want one starting with %q", warnings, tt.want)
+			}
+		})
+	}
+}
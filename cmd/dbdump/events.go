@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/events"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+var (
+	eventsFD   int
+	eventsFile string
+
+	// eventStream receives the run's events; nil without --events-fd or
+	// --events-file
+	eventStream   *events.Stream
+	eventResults  []events.Result
+	eventWarnings atomic.Int64
+)
+
+func init() {
+	dumpCmd.Flags().IntVar(&eventsFD, "events-fd", 0, "Write the run's events as newline-delimited JSON to this open file descriptor (3 or above)")
+	dumpCmd.Flags().StringVar(&eventsFile, "events-file", "", "Write the run's events as newline-delimited JSON to this file (or named pipe)")
+}
+
+// startEvents opens the event stream of --events-fd or --events-file and
+// sends run_started; human output goes on as before
+func startEvents() error {
+	if eventsFD == 0 && eventsFile == "" {
+		return nil
+	}
+	if eventsFD != 0 && eventsFile != "" {
+		return &dberrors.ErrConfigInvalid{Source: "--events-fd", Problems: []string{"cannot be combined with --events-file"}}
+	}
+
+	var out *os.File
+	if eventsFile != "" {
+		file, err := os.OpenFile(eventsFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open --events-file: %w", err)
+		}
+		out = file
+	} else {
+		if eventsFD < 3 {
+			return &dberrors.ErrConfigInvalid{Source: "--events-fd", Problems: []string{"must be 3 or above: 0-2 are stdin, stdout and stderr"}}
+		}
+		out = os.NewFile(uintptr(eventsFD), "events")
+		if _, err := out.Stat(); err != nil {
+			return &dberrors.ErrConfigInvalid{Source: "--events-fd", Problems: []string{fmt.Sprintf("file descriptor %d is not open", eventsFD)}}
+		}
+	}
+
+	beginEvents(out)
+	return nil
+}
+
+// beginEvents starts the event stream on out, passing warnings on to it,
+// and sends run_started
+func beginEvents(out io.WriteCloser) {
+	eventStream = events.NewStream(out)
+	diag.OnWarning = func(message string) {
+		eventWarnings.Add(1)
+		eventStream.Send(events.TypeWarning, events.Warning{Message: message})
+	}
+
+	started := events.RunStarted{
+		DbdumpVersion: Version,
+		Host:          host,
+		Databases:     []string{},
+		PID:           os.Getpid(),
+	}
+	if !allDatabases {
+		started.Databases = append(started.Databases, dbName)
+	}
+	eventStream.Send(events.TypeRunStarted, started)
+}
+
+// finishEvents sends run_completed with the command's outcome and closes
+// the stream, once the reader has taken every event
+func finishEvents(err error, code int) {
+	if eventStream == nil {
+		return
+	}
+
+	completed := events.RunCompleted{
+		Status:   statusOK,
+		ExitCode: code,
+		Results:  append([]events.Result{}, eventResults...),
+		Warnings: int(eventWarnings.Load()),
+	}
+	var budgetErr *dberrors.ErrOverBudget
+	switch {
+	case err == nil || errors.As(err, &budgetErr):
+	case interrupted(err):
+		completed.Status = statusInterrupted
+	default:
+		completed.Status = statusFailed
+	}
+	if err != nil {
+		completed.Error = err.Error()
+	}
+	eventStream.Send(events.TypeRunCompleted, completed)
+
+	diag.OnWarning = nil
+	if err := eventStream.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	eventStream = nil
+}
+
+// sendPlan sends plan_ready with what the dump does with each table
+func sendPlan(dp *planner.DumpPlan) {
+	if eventStream == nil {
+		return
+	}
+	ready := events.PlanReady{
+		Database:   dp.Database,
+		OutputFile: dp.OutputFile,
+		DryRun:     dryRun,
+		Tables:     make([]events.TablePlan, 0, len(dp.Tables)),
+	}
+	if dp.SizesKnown {
+		estimate := dp.EstimatedSize
+		ready.EstimatedSize = &estimate
+	}
+	for _, table := range dp.Tables {
+		ready.Tables = append(ready.Tables, events.TablePlan{
+			Name:        table.Name,
+			Disposition: table.Disposition,
+			DataSize:    table.DataSize,
+			Rule:        table.Rule,
+			Confidence:  string(table.Confidence),
+		})
+	}
+	eventStream.Send(events.TypePlanReady, ready)
+}
+
+// withEvents makes the dump report its phases, tables and progress to the
+// event stream, keeping the progress bar as it was
+func withEvents(options *database.DumpOptions) *database.DumpOptions {
+	if eventStream == nil {
+		return options
+	}
+	name := options.Connection.Database
+
+	show, update := options.ShowProgress, options.OnProgress
+	options.ShowProgress = true
+	options.OnProgress = func(progress database.DumpProgress) {
+		if show && update != nil {
+			update(progress)
+		}
+		eventStream.Progress(events.BytesProgress{
+			Database:       name,
+			Table:          progress.Table,
+			Index:          progress.Index,
+			Count:          progress.Count,
+			Bytes:          progress.Bytes,
+			EstimatedBytes: progress.EstimatedBytes,
+		})
+	}
+	options.OnPhase = func(phase string) {
+		eventStream.Send(events.TypePhaseStarted, events.PhaseStarted{Database: name, Phase: phase})
+	}
+	options.OnTableDone = func(timing database.TableTiming) {
+		eventStream.Send(events.TypeTableCompleted, events.TableCompleted{
+			Database:   name,
+			Table:      timing.Table,
+			Bytes:      timing.Bytes,
+			DurationMs: timing.Duration.Milliseconds(),
+		})
+	}
+	return options
+}
+
+// recordEventResult adds a finished dump to run_completed
+func recordEventResult(result *database.DumpResult) {
+	if eventStream == nil {
+		return
+	}
+	finished := events.Result{
+		Database:         dbName,
+		OutputFile:       result.OutputFile,
+		DurationMs:       result.Duration.Milliseconds(),
+		FileSize:         result.FileSize,
+		UncompressedSize: result.UncompressedSize,
+		ExcludedTables:   append([]string{}, result.ExcludedTables...),
+		OverBudgetTables: result.OverBudget,
+	}
+	for _, part := range result.Parts {
+		finished.Parts = append(finished.Parts, part.Path)
+	}
+	eventResults = append(eventResults, finished)
+}
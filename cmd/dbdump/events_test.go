@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/events"
+	"github.com/helgesverre/dbdump/internal/plan"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// slowMySQLDump stands in for mysqldump, writing the data of three tables
+// with $FAKE_MYSQLDUMP_PAUSE seconds between them
+const slowMySQLDump = `#!/bin/sh
+for arg; do
+	case $arg in
+	--help) exit 0 ;;
+	--version) echo "mysqldump  Ver 8.0.36 for Linux"; exit 0 ;;
+	esac
+done
+case " $* " in
+*" --triggers "*) ;;
+*" --no-create-info "*)
+	printf 'INSERT INTO \140users\140 VALUES (1,\047a@example.com\047);\n'
+	sleep "$FAKE_MYSQLDUMP_PAUSE"
+	printf 'INSERT INTO \140orders\140 VALUES (1,1),(2,1);\n'
+	sleep "$FAKE_MYSQLDUMP_PAUSE"
+	printf 'INSERT INTO \140logs\140 VALUES (1,\047x\047);\n'
+	;;
+*)
+	for table in users orders logs; do
+		printf 'CREATE TABLE \140%s\140 (\140id\140 int);\n' "$table"
+	done
+	;;
+esac
+`
+
+// streamedEvent is an event as a reader decodes it from the stream
+type streamedEvent struct {
+	events.Event
+	Data json.RawMessage `json:"data"`
+}
+
+// TestEventStream runs a dump with the fake mysqldump and reads its events
+// from a pipe, once as they come and once only after the dump ended: the
+// dump never waits for the reader, progress is dropped while the reader
+// lags behind, and every other event arrives in order
+func TestEventStream(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake mysqldump is a shell script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mysqldump"), []byte(slowMySQLDump), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	savedDB, savedHost, savedAll, savedOutput, savedResults := dbName, host, allDatabases, diag.Output, eventResults
+	defer func() {
+		dbName, host, allDatabases, diag.Output, eventResults = savedDB, savedHost, savedAll, savedOutput, savedResults
+		diag.OnWarning = nil
+		diag.Default.Reset()
+	}()
+	dbName, host, allDatabases, diag.Output = "shop", "db", false, io.Discard
+
+	tests := []struct {
+		name    string
+		stalled bool    // the reader only starts once the dump has ended
+		pause   float64 // seconds between tables
+	}{
+		{name: "live reader", pause: 1.2},
+		{name: "stalled reader", stalled: true, pause: 0.2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FAKE_MYSQLDUMP_PAUSE", formatSeconds(tt.pause))
+			diag.Default.Reset()
+			eventResults = nil
+			eventWarnings.Store(0)
+
+			reader, writer := io.Pipe()
+			start := make(chan struct{})
+			received := make(chan []streamedEvent)
+			go func() {
+				<-start
+				var got []streamedEvent
+				scanner := bufio.NewScanner(reader)
+				for scanner.Scan() {
+					var event streamedEvent
+					if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+						t.Errorf("line %q is not an event: %v", scanner.Text(), err)
+					}
+					got = append(got, event)
+				}
+				received <- got
+			}()
+			if !tt.stalled {
+				close(start)
+			}
+
+			output := filepath.Join(t.TempDir(), "shop.sql")
+			beginEvents(writer)
+			sendPlan(&planner.DumpPlan{Database: "shop", OutputFile: output, Tables: []planner.Table{
+				{TableInfo: database.TableInfo{Name: "logs"}, Disposition: plan.DispositionFull},
+				{TableInfo: database.TableInfo{Name: "orders"}, Disposition: plan.DispositionFull},
+				{TableInfo: database.TableInfo{Name: "users"}, Disposition: plan.DispositionFull},
+			}})
+			diag.Warnf("a warning before the dump")
+
+			dumped := make(chan error, 1)
+			go func() {
+				dumper := database.NewDumper(withEvents(&database.DumpOptions{
+					Connection: &database.Connection{Host: "db", Port: 3306, User: "app", Database: "shop"},
+					OutputFile: output,
+				}))
+				result, err := dumper.Dump()
+				if err == nil {
+					recordEventResult(result)
+				}
+				dumped <- err
+			}()
+			var err error
+			select {
+			case err = <-dumped:
+			case <-time.After(30 * time.Second):
+				t.Fatal("the dump waited for the reader")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.stalled {
+				close(start)
+			}
+			finishEvents(nil, 0)
+			got := <-received
+
+			checkEventOrder(t, got)
+			progress := 0
+			var last time.Time
+			for _, event := range got {
+				if event.Type != events.TypeBytesProgress {
+					continue
+				}
+				progress++
+				if !last.IsZero() && event.Time.Sub(last) < events.ProgressInterval {
+					t.Errorf("progress events %v apart, want at least %v", event.Time.Sub(last), events.ProgressInterval)
+				}
+				last = event.Time
+			}
+			switch {
+			case tt.stalled && progress != 1:
+				// The first progress event waits in the queue, and the
+				// others are dropped while it does
+				t.Errorf("%d progress events for a stalled reader, want 1", progress)
+			case !tt.stalled && progress < 2:
+				t.Errorf("%d progress events over %d pauses of %vs, want at least 2", progress, 2, tt.pause)
+			}
+		})
+	}
+}
+
+// checkEventOrder checks the ordering guarantees of a stream: numbered from
+// 1 without gaps, run_started first and run_completed last, the plan before
+// the phases, progress only in the data phase, and no event other than
+// progress missing
+func checkEventOrder(t *testing.T, got []streamedEvent) {
+	t.Helper()
+	var types []events.Type
+	for i, event := range got {
+		if event.Seq != int64(i+1) {
+			t.Errorf("event %d has seq %d", i+1, event.Seq)
+		}
+		if event.Version != events.SchemaVersion {
+			t.Errorf("event %d has version %d", i+1, event.Version)
+		}
+		if event.Type != events.TypeBytesProgress {
+			types = append(types, event.Type)
+		}
+	}
+	want := []events.Type{
+		events.TypeRunStarted,
+		events.TypePlanReady,
+		events.TypeWarning,
+		events.TypePhaseStarted, // structure
+		events.TypePhaseStarted, // data
+		events.TypeTableCompleted,
+		events.TypeTableCompleted,
+		events.TypeTableCompleted,
+		events.TypePhaseStarted, // objects
+		events.TypeRunCompleted,
+	}
+	if !slices.Equal(types, want) {
+		t.Fatalf("events %v, want %v (progress aside)", types, want)
+	}
+
+	var phases, tables []string
+	inData := false
+	for _, event := range got {
+		switch event.Type {
+		case events.TypePhaseStarted:
+			var phase events.PhaseStarted
+			decode(t, event, &phase)
+			phases = append(phases, phase.Phase)
+			inData = phase.Phase == "data"
+		case events.TypeTableCompleted:
+			var table events.TableCompleted
+			decode(t, event, &table)
+			tables = append(tables, table.Table)
+		case events.TypeBytesProgress:
+			if !inData {
+				t.Errorf("progress event %d outside the data phase", event.Seq)
+			}
+		case events.TypeRunCompleted:
+			var completed events.RunCompleted
+			decode(t, event, &completed)
+			if completed.Status != statusOK || len(completed.Results) != 1 || completed.Warnings != 1 {
+				t.Errorf("run_completed = %+v", completed)
+			}
+		}
+	}
+	if want := []string{"structure", "data", "objects"}; !slices.Equal(phases, want) {
+		t.Errorf("phases %v, want %v", phases, want)
+	}
+	if want := []string{"users", "orders", "logs"}; !slices.Equal(tables, want) {
+		t.Errorf("tables completed %v, want %v", tables, want)
+	}
+}
+
+// decode reads the payload of an event
+func decode(t *testing.T, event streamedEvent, data any) {
+	t.Helper()
+	if err := json.Unmarshal(event.Data, data); err != nil {
+		t.Fatalf("%s event: %v", event.Type, err)
+	}
+}
+
+// formatSeconds writes seconds for sleep(1)
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', -1, 64)
+}
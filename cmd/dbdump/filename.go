@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/planner"
+)
+
+// filenameFormat returns the timestamp format of generated dump file names
+// from filename_timestamp in the global config, overridden field by field
+// by the project config
+func filenameFormat() (planner.NameFormat, error) {
+	settings, source := config.FilenameTimestampConfig{}, ""
+	if globalConfig, err := config.LoadGlobalConfig(); err == nil && globalConfig != nil {
+		settings, source = globalConfig.FilenameTimestamp, "~/.dbdump.yaml"
+	}
+	if len(configFiles) > 0 {
+		if projectConfig, err := loadProjectConfig(); err == nil {
+			project := projectConfig.FilenameTimestamp
+			if project.Zone != "" {
+				settings.Zone, source = project.Zone, configSource()
+			}
+			if project.Format != "" {
+				settings.Format, source = project.Format, configSource()
+			}
+		}
+	}
+
+	format, err := planner.ParseNameFormat(settings.Zone, settings.Format)
+	if err != nil {
+		return format, &dberrors.ErrConfigInvalid{Source: source, Problems: []string{"filename_timestamp: " + err.Error()}}
+	}
+	return format, nil
+}
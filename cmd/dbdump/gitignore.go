@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/ignorefile"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/ui/prompt"
+)
+
+// checkGitignore makes sure a dump written inside a git work tree is ignored,
+// adding a pattern to .gitignore (and .dockerignore, if the repo has one)
+// with --update-gitignore or after confirmation
+func checkGitignore(outputFile string, generatedName bool) {
+	if !gitignoreCheckEnabled() {
+		return
+	}
+
+	root := ignorefile.FindRepoRoot(filepath.Dir(outputFile))
+	if root == "" {
+		return
+	}
+
+	ignored, err := ignorefile.IsIgnored(root, outputFile)
+	if err != nil {
+		diag.Warnf("could not check whether the dump is git-ignored: %v", err)
+		return
+	}
+	if ignored {
+		return
+	}
+
+	pattern := dumpIgnorePattern(outputFile, generatedName)
+	gitignorePath := filepath.Join(root, ".gitignore")
+
+	if !updateGitignore {
+		ui.PrintWarning(fmt.Sprintf("The dump is inside the git repository at %s and is not ignored — it could be committed by accident", root))
+		confirmed, err := prompt.Confirm(prompt.Gitignore, fmt.Sprintf("Add %q to %s?", pattern, gitignorePath))
+		if err != nil {
+			ui.PrintInfo(fmt.Sprintf("Run with --update-gitignore to add %q to .gitignore, or set gitignore_check: false in your config", pattern))
+			return
+		}
+		if !confirmed {
+			return
+		}
+	}
+
+	if added, err := ignorefile.AppendPattern(gitignorePath, pattern); err != nil {
+		diag.Warnf("%v", err)
+	} else if added {
+		ui.PrintSuccess(fmt.Sprintf("Added %q to %s", pattern, gitignorePath))
+	}
+
+	// .dockerignore patterns are anchored at the context root, so match any directory
+	dockerignorePath := filepath.Join(root, ".dockerignore")
+	if _, err := os.Stat(dockerignorePath); err == nil {
+		if added, err := ignorefile.AppendPattern(dockerignorePath, "**/"+pattern); err != nil {
+			diag.Warnf("%v", err)
+		} else if added {
+			ui.PrintSuccess(fmt.Sprintf("Added %q to %s", "**/"+pattern, dockerignorePath))
+		}
+	}
+}
+
+// dumpIgnorePattern returns the ignore pattern for a dump file. Generated names
+// ({database}_{timestamp}.sql) get a pattern covering every dump of the
+// database; explicit names are matched as given. Both also cover the sidecar
+// and split parts.
+func dumpIgnorePattern(outputFile string, generatedName bool) string {
+	if generatedName {
+		return dbName + "_*.sql*"
+	}
+	return filepath.Base(outputFile) + "*"
+}
+
+// gitignoreCheckEnabled reports whether the check is enabled; either config
+// file can disable it with gitignore_check: false
+func gitignoreCheckEnabled() bool {
+	if globalConfig, err := config.LoadGlobalConfig(); err == nil && globalConfig != nil && !globalConfig.GitignoreCheckEnabled() {
+		return false
+	}
+	if len(configFiles) > 0 {
+		if projectConfig, err := loadProjectConfig(); err == nil && !projectConfig.GitignoreCheckEnabled() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/gitlabel"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/tags"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+var labelFromGit bool
+
+// migrationTables are the tables migration tools record applied migrations
+// in, in the order they are looked for
+var migrationTables = []string{
+	"migrations",                  // Laravel
+	"schema_migrations",           // Rails, golang-migrate
+	"django_migrations",           // Django
+	"doctrine_migration_versions", // Doctrine
+	"flyway_schema_history",       // Flyway
+	"knex_migrations",             // Knex
+	"SequelizeMeta",               // Sequelize
+	"__EFMigrationsHistory",       // Entity Framework
+	"goose_db_version",            // goose
+}
+
+func init() {
+	dumpCmd.Flags().BoolVar(&labelFromGit, "label-from-git", false, "Tag the dump with the branch, commit, dirty flag and nearest tag of the git repository dbdump runs in")
+}
+
+// gitLabelsEnabled reports whether dumps get git labels: --label-from-git,
+// or labels.git in the global or project config
+func gitLabelsEnabled() bool {
+	if labelFromGit {
+		return true
+	}
+	enabled := false
+	if globalConfig, err := config.LoadGlobalConfig(); err == nil && globalConfig != nil && globalConfig.Labels.Git != nil {
+		enabled = *globalConfig.Labels.Git
+	}
+	if len(configFiles) > 0 {
+		if projectConfig, err := loadProjectConfig(); err == nil && projectConfig.Labels.Git != nil {
+			enabled = *projectConfig.Labels.Git
+		}
+	}
+	return enabled
+}
+
+// applyGitLabels adds the git labels of the working directory to the dump's
+// tags; tags given with --tag win. Outside a repository, or without git,
+// nothing is added and nothing is said.
+func applyGitLabels(dumpTags map[string]string) map[string]string {
+	if !gitLabelsEnabled() {
+		return dumpTags
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return dumpTags
+	}
+	labels := gitlabel.Read(dir)
+	if labels == nil {
+		return dumpTags
+	}
+
+	if dumpTags == nil {
+		dumpTags = make(map[string]string)
+	}
+	for key, value := range labels.Tags() {
+		if _, given := dumpTags[key]; given {
+			continue
+		}
+		// A branch name can be longer than a tag value may be
+		if _, _, err := tags.ParseTag(key + "=" + value); err != nil {
+			continue
+		}
+		dumpTags[key] = value
+	}
+	return dumpTags
+}
+
+// captureMigrations counts the applied migrations of the source along with
+// the git labels, for restore to compare with the target's
+func captureMigrations(inspector *database.Inspector, tablesInfo []database.TableInfo) *metadata.Migrations {
+	if !gitLabelsEnabled() {
+		return nil
+	}
+	for _, name := range migrationTables {
+		for _, info := range tablesInfo {
+			if !strings.EqualFold(info.Name, name) {
+				continue
+			}
+			rows, err := inspector.CountRows(info.Name)
+			if err != nil {
+				diag.Warnf("migration state not recorded: %v", err)
+				return nil
+			}
+			return &metadata.Migrations{Table: info.Name, Rows: rows}
+		}
+	}
+	return nil
+}
+
+// checkTargetMigrations warns when the target has applied a different
+// number of migrations than the source had when the dump was taken: the
+// restore puts the schema back at the dump's state, whatever the code
+// working on the target expects
+func checkTargetMigrations(ctx context.Context, inputFile string, target *database.Connection) {
+	meta, err := metadata.LoadForDump(inputFile)
+	if err != nil || meta == nil || meta.Migrations == nil {
+		return
+	}
+
+	// A target that doesn't exist yet, or has no migrations table, has no
+	// state to lose
+	db, err := target.ConnectContext(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+	rows, err := database.NewInspector(db).WithContext(ctx).CountRows(meta.Migrations.Table)
+	if err != nil || rows == meta.Migrations.Rows {
+		return
+	}
+
+	code := ""
+	if description := gitlabel.Describe(meta.Tags); description != "" {
+		code = ", taken at " + description
+	}
+	diag.Warnf("%s has %d migration(s) in %s but %d in the dump%s: the restore puts the schema at the dump's migration state",
+		target.Database, rows, meta.Migrations.Table, meta.Migrations.Rows, code)
+	if rows > meta.Migrations.Rows {
+		fmt.Printf("    Run the migrations after restoring to bring the schema up to date\n")
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/history"
+	"github.com/helgesverre/dbdump/internal/tags"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/ui/table"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show previous dump runs",
+	Long: `Show previous dump runs recorded in ~/.config/dbdump/history.jsonl.
+Use -d (and -H/-P) to limit the list to one database.`,
+	RunE: runHistory,
+}
+
+var historyDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show schema changes between the last two dumps of a database",
+	RunE:  runHistoryDiff,
+}
+
+var (
+	historyTagSpecs []string
+	historyFormat   string
+)
+
+func init() {
+	historyCmd.Flags().StringArrayVar(&historyTagSpecs, "tag", []string{}, "Only show dumps with this key=value tag (repeatable, all must match)")
+	historyCmd.Flags().StringVar(&historyFormat, "format", "table", "Output format: table, csv or json")
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyDiffCmd)
+}
+
+// recordHistory appends a finished dump to the history and reports schema
+// changes since the previous dump of the same database
+func recordHistory(conn *database.Connection, tablesInfo []database.TableInfo, result *database.DumpResult, rulesVersion int) {
+	entries, err := history.Load()
+	if err != nil {
+		diag.Warnf("%v", err)
+	}
+
+	entry := history.Entry{
+		Time:           time.Now().UTC(),
+		Host:           conn.Host,
+		Port:           conn.Port,
+		Instance:       conn.Instance,
+		Database:       conn.Database,
+		OutputFile:     result.OutputFile,
+		FileSize:       result.FileSize,
+		DurationMillis: result.Duration.Milliseconds(),
+		ExcludedTables: result.ExcludedTables,
+		Schema:         result.SchemaFingerprints,
+		TableSizes:     make(map[string]int64, len(tablesInfo)),
+		Tags:           dumpTags,
+		RulesVersion:   rulesVersion,
+	}
+	for _, info := range tablesInfo {
+		if !info.SizeUnknown {
+			entry.TableSizes[info.Name] = info.TotalSize
+		}
+	}
+	if len(entry.TableSizes) == 0 {
+		entry.TableSizes = nil
+	}
+
+	if err := history.Append(entry); err != nil {
+		diag.Warnf("%v", err)
+		return
+	}
+
+	previous := history.ForConnection(entries, conn)
+	if len(previous) == 0 {
+		return
+	}
+	last := previous[len(previous)-1]
+	if last.Schema == nil || entry.Schema == nil {
+		return
+	}
+
+	if diff := history.DiffSchemas(last.Schema, entry.Schema); !diff.Empty() {
+		ui.PrintInfo(fmt.Sprintf("Schema changed since last dump: %s — run `dbdump history diff -d %s` for details", diff.Summary(), conn.Database))
+	}
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	format, err := table.ParseFormat(historyFormat)
+	if err != nil {
+		return err
+	}
+
+	entries, err := history.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+
+	if dbName != "" {
+		entries = history.ForDatabase(entries, host, port, dbName)
+	}
+
+	filter, err := tags.Parse(historyTagSpecs)
+	if err != nil {
+		return err
+	}
+	if len(filter) > 0 {
+		var tagged []history.Entry
+		for _, entry := range entries {
+			if tags.Matches(entry.Tags, filter) {
+				tagged = append(tagged, entry)
+			}
+		}
+		entries = tagged
+	}
+
+	if len(entries) == 0 && format == table.Text {
+		fmt.Println("No dump history found")
+		return nil
+	}
+
+	out := table.New(
+		table.Column{Title: "Time"},
+		table.Column{Title: "Database", MaxWidth: 40, Flex: true},
+		table.Column{Title: "Size", Align: table.Right, MinWidth: 10},
+		table.Column{Title: "Duration", Align: table.Right},
+		table.Column{Title: "Output"},
+		table.Column{Title: "Tags"},
+	)
+	for _, entry := range entries {
+		out.Row(
+			entry.Time.Local().Format("2006-01-02 15:04"),
+			historyServer(entry),
+			database.FormatBytes(entry.FileSize),
+			(time.Duration(entry.DurationMillis) * time.Millisecond).Round(time.Second).String(),
+			entry.OutputFile,
+			tags.Format(entry.Tags),
+		)
+	}
+
+	if format != table.Text {
+		return out.Render(os.Stdout, format)
+	}
+	fmt.Println()
+	if err := out.Render(os.Stdout, format); err != nil {
+		return err
+	}
+	fmt.Printf("\nTotal: %d run(s)\n", len(entries))
+
+	return nil
+}
+
+func runHistoryDiff(cmd *cobra.Command, args []string) error {
+	if dbName == "" {
+		return fmt.Errorf("database name is required (use -d or --database)")
+	}
+
+	entries, err := history.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+
+	var withSchema []history.Entry
+	for _, entry := range history.ForDatabase(entries, host, port, dbName) {
+		if entry.Schema != nil {
+			withSchema = append(withSchema, entry)
+		}
+	}
+	if len(withSchema) < 2 {
+		return fmt.Errorf("need at least two recorded dumps of %s to compare (found %d)", dbName, len(withSchema))
+	}
+
+	older := withSchema[len(withSchema)-2]
+	newer := withSchema[len(withSchema)-1]
+	diff := history.DiffSchemas(older.Schema, newer.Schema)
+
+	fmt.Printf("\nSchema changes in '%s' between %s and %s:\n\n",
+		dbName,
+		older.Time.Local().Format("2006-01-02 15:04"),
+		newer.Time.Local().Format("2006-01-02 15:04"))
+
+	if diff.Empty() {
+		fmt.Println("  No changes")
+		fmt.Println()
+		return nil
+	}
+
+	for _, table := range diff.Added {
+		fmt.Printf("  + %s (added)\n", table)
+	}
+	for _, table := range diff.Removed {
+		fmt.Printf("  - %s (removed)\n", table)
+	}
+	for _, table := range diff.Altered {
+		fmt.Printf("  ~ %s (altered)\n", table)
+	}
+	fmt.Printf("\n%s\n\n", diff.Summary())
+
+	return nil
+}
+
+// historyServer names the database of an entry as database@host:port, or
+// database@instance for a dump through a proxy
+func historyServer(entry history.Entry) string {
+	if entry.Instance != "" {
+		return entry.Database + "@" + entry.Instance
+	}
+	return fmt.Sprintf("%s@%s:%d", entry.Database, entry.Host, entry.Port)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var inspectFormat string
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <dump file>",
+	Short: "Describe what a dump file contains without restoring it",
+	Long: `Read a dump file (plain, gzip or zstd, or a split dump) as a stream and report
+its source database and tool versions from the header, the tables with their row
+counts, the charset, and any views, triggers, routines and events.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInspect,
+}
+
+func init() {
+	inspectCmd.Flags().StringVar(&inspectFormat, "format", "table", "Output format: table or json")
+	rootCmd.AddCommand(inspectCmd)
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	if inspectFormat != "table" && inspectFormat != "json" {
+		return fmt.Errorf("unsupported --format %q (supported: table, json)", inspectFormat)
+	}
+
+	contents, err := dumpfile.Inspect(args[0])
+	if err != nil {
+		return err
+	}
+
+	if inspectFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(contents)
+	}
+
+	printContents(contents)
+	return nil
+}
+
+// printContents prints the inspection result as text
+func printContents(c *dumpfile.Contents) {
+	size := database.FormatBytes(c.DecompressedSize)
+	if c.Compression != "" {
+		size = fmt.Sprintf("%s %s, %s uncompressed", database.FormatBytes(c.FileSize), c.Compression, size)
+	}
+	if c.Parts > 0 {
+		size += fmt.Sprintf(", %d parts", c.Parts)
+	}
+
+	fmt.Printf("\n%s (%s)\n\n", c.Path, size)
+
+	source := valueOrDash(c.Database)
+	if c.Host != "" {
+		source += " on " + c.Host
+	}
+	if c.ServerVersion != "" {
+		source += " (server " + c.ServerVersion + ")"
+	}
+	writtenBy := valueOrDash(c.DumpTool)
+	if c.DbdumpVersion != "" {
+		writtenBy = "dbdump " + c.DbdumpVersion + " with " + writtenBy
+	}
+	charset := valueOrDash(c.Charset)
+	if len(c.TableCharsets) > 0 {
+		charset += " (tables: " + strings.Join(c.TableCharsets, ", ") + ")"
+	}
+
+	fmt.Printf("  %-12s %s\n", "Source:", source)
+	fmt.Printf("  %-12s %s\n", "Written by:", writtenBy)
+	if c.Tags != "" {
+		fmt.Printf("  %-12s %s\n", "Tags:", c.Tags)
+	}
+	fmt.Printf("  %-12s %s\n", "Charset:", charset)
+	if !c.Completed {
+		fmt.Printf("  %-12s %s\n", "Completed:", "no completion marker; the dump may be truncated (see dbdump verify)")
+	}
+	fmt.Printf("  %-12s %d views, %d triggers, %d routines, %d events\n", "Objects:", c.Views, c.Triggers, c.Routines, c.Events)
+	fmt.Println()
+
+	if len(c.Tables) == 0 {
+		fmt.Println("No tables found")
+		return
+	}
+
+	nameWidth := 40
+	for _, table := range c.Tables {
+		nameWidth = max(nameWidth, ui.DisplayWidth(table.Name))
+	}
+	nameWidth = min(nameWidth, max(20, ui.LineWidth(80)-30))
+
+	fmt.Printf("%s %-10s %14s\n", ui.PadRight("Table", nameWidth), "Contents", "Rows")
+	fmt.Println(strings.Repeat(ui.Sym().Rule, nameWidth+26))
+
+	withData := 0
+	var rows int64
+	for _, table := range c.Tables {
+		kind := "structure"
+		switch {
+		case table.View:
+			kind = "view"
+		case table.HasData():
+			kind = "data"
+			withData++
+			rows += table.Rows
+		}
+		count := "-"
+		if table.HasData() {
+			count = fmt.Sprintf("%d", table.Rows)
+		}
+		fmt.Printf("%s %-10s %14s\n", ui.PadRight(ui.Truncate(table.Name, nameWidth), nameWidth), kind, count)
+	}
+
+	fmt.Printf("\nTotal: %d tables, %d with data (%d rows)\n", len(c.Tables)-c.Views, withData, rows)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/plan"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/units"
+)
+
+var (
+	metadataTimeout = units.Duration{Value: 10 * time.Second}
+	metadataSource  string
+)
+
+func init() {
+	rootCmd.PersistentFlags().Var(&metadataTimeout, "metadata-timeout", "Time allowed for reading table sizes from information_schema before falling back to cheaper sources (e.g. 30s, 2m; 0 for no limit)")
+	rootCmd.PersistentFlags().StringVar(&metadataSource, "metadata-source", string(database.MetadataAuto), "Where table information is read from: auto, information_schema or show (SHOW TABLES and SHOW TABLE STATUS, for proxies like ProxySQL or Vitess)")
+}
+
+// newInspector returns an inspector honoring --metadata-timeout and
+// --metadata-source whose queries are cancelled with ctx. It also reads how
+// the server compares table names, for the selection rules.
+func newInspector(ctx context.Context, db *sql.DB) (*database.Inspector, error) {
+	source, err := database.ParseMetadataSource(metadataSource)
+	if err != nil {
+		return nil, &dberrors.ErrConfigInvalid{Source: "--metadata-source", Err: err}
+	}
+	inspector := database.NewInspector(db).WithContext(ctx).WithMetadataTimeout(metadataTimeout.Value).WithMetadataSource(source)
+	detectNameCase(inspector)
+	return inspector, nil
+}
+
+// reportDegraded prints a notice when table information came from a
+// fallback source and reports whether table sizes are known
+func reportDegraded(inspector *database.Inspector, tablesInfo []database.TableInfo) bool {
+	if verbose {
+		ui.PrintInfo(fmt.Sprintf("Table information read from %s", inspector.Source()))
+	}
+	if reason := inspector.Degraded(); reason != "" {
+		ui.PrintWarning("Table information is degraded: " + reason)
+	}
+	if !database.SizesKnown(tablesInfo) {
+		ui.PrintInfo("Table sizes are unknown: size estimates, size checks and size history are skipped, and storage engine rules can't apply")
+		return false
+	}
+	return true
+}
+
+// inspection is what a dump learns about the tables before selecting them
+type inspection struct {
+	planner    *planner.Planner
+	sel        *planner.Selection
+	sizesKnown bool
+	count      int // tables in the database
+}
+
+// inspectTables reads the table information and applies the selection
+// rules, or the plan when one is given
+func inspectTables(inspector *database.Inspector, args []string, dumpPlan *plan.Plan) (*inspection, error) {
+	tablesInfo, err := inspector.GetAllTablesInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table information: %w", err)
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Found %d tables", len(tablesInfo)))
+	found := &inspection{
+		sizesKnown: reportDegraded(inspector, tablesInfo),
+		count:      len(tablesInfo),
+	}
+
+	if dumpPlan == nil {
+		found.planner, found.sel, err = applySelectionRules(tablesInfo, args)
+		if err != nil {
+			return nil, err
+		}
+		return found, nil
+	}
+
+	// A plan has decided the structure levels already
+	levels, err := planStructureLevels(dumpPlan)
+	if err != nil {
+		return nil, err
+	}
+	found.planner = &planner.Planner{Rules: planner.Rules{Levels: levels}}
+	if found.sel, err = planSelection(dumpPlan, tablesInfo); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// selectWhileInspecting runs the table selector while the tables are read.
+// The names come first (SHOW TABLES), pre-selected by the name rules; sizes,
+// engines and every other rule follow. Tables the user toggled keep their
+// state, and confirming waits for the final update, so the exclusions are
+// the same as if the selector had started after inspection.
+//
+// With pre_analyze the largest tables' statistics are refreshed before the
+// sizes are read, unless the session is read-only.
+func selectWhileInspecting(ctx context.Context, inspector *database.Inspector, readOnly bool) (*inspection, []string, error) {
+	top, budget, err := preAnalyzeSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+	if top > 0 && readOnly {
+		ui.PrintInfo("pre_analyze is skipped: ANALYZE TABLE is not run on read-only sources")
+		top = 0
+	}
+
+	var found *inspection
+	load := func(ctx context.Context, update func(ui.TableUpdate)) error {
+		scoped := *inspector
+		inspector := scoped.WithContext(ctx)
+
+		names, err := inspector.ListTables()
+		if err != nil {
+			return fmt.Errorf("failed to list tables: %w", err)
+		}
+		tables, preSelected, suggested, err := nameSelection(names)
+		if err != nil {
+			return err
+		}
+		update(ui.TableUpdate{Tables: tables, PreSelected: preSelected, Suggested: suggested})
+
+		var analyzed map[string]bool
+		if top > 0 {
+			analyzed, err = preAnalyze(ctx, inspector, top, budget, func(status string) {
+				update(ui.TableUpdate{Tables: tables, PreSelected: preSelected, Suggested: suggested, Status: status})
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if found, err = inspectTables(inspector, nil, nil); err != nil {
+			return err
+		}
+		sel := found.sel
+		markAnalyzed(sel.Tables, analyzed)
+		reasons := sel.Explain()
+		// High-confidence rules tick their tables; medium ones are only
+		// marked, and low ones explained in the detail view
+		preSelected, reconcile, err := markSavedSelection(sel.Tables, sel.AtLeast(config.ConfidenceHigh), reasons)
+		if err != nil {
+			return err
+		}
+		update(ui.TableUpdate{
+			Tables:      sel.Tables,
+			PreSelected: preSelected,
+			Suggested:   sel.WithConfidence(config.ConfidenceMedium),
+			Reasons:     reasons,
+			SampleRows:  sel.Samples.Counts(sel.Samples.Sampled(sel.Tables)),
+			Final:       true,
+			Reconcile:   reconcile,
+		})
+		return nil
+	}
+
+	selected, err := ui.RunInteractiveSelection(ctx, nil, nil, ui.SelectionOptions{
+		FetchColumns: inspector.GetColumns,
+		FetchRows:    inspector.PreviewRows,
+		Load:         load,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	saveSelection(selected)
+	return found, selected, nil
+}
+
+// nameSelection applies the only and exclusion rules to bare table names,
+// before sizes and engines are known, returning the tables, those to tick
+// and those to mark as suggested
+func nameSelection(names []string) ([]database.TableInfo, []string, []string, error) {
+	p, err := newPlanner(nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tables := make([]database.TableInfo, len(names))
+	for i, name := range names {
+		tables[i] = database.TableInfo{Name: name, SizeUnknown: true, SizeDisplay: database.SizeUnavailable}
+	}
+	// The notices wait for the full selection, which repeats them
+	sel, err := p.Select(tables)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return sel.Tables, sel.AtLeast(config.ConfidenceHigh), sel.WithConfidence(config.ConfidenceMedium), nil
+}
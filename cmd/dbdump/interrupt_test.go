@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain runs the test binary as dbdump when the interrupt tests start it
+// as a child process, so they can send it a real Ctrl+C
+func TestMain(m *testing.M) {
+	if os.Getenv("DBDUMP_TEST_MAIN") == "1" {
+		os.Args = append([]string{"dbdump"}, os.Args[1:]...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// interruptBound is how long dbdump may take to exit after Ctrl+C
+const interruptBound = 5 * time.Second
+
+// stallingServer accepts MySQL connections and stalls at a stage: before
+// the handshake, or at the first query after a successful login. reached
+// receives a value when a connection gets there.
+type stallingServer struct {
+	listener  net.Listener
+	handshake bool
+	reached   chan struct{}
+}
+
+// startStallingServer listens on a local port until the test ends
+func startStallingServer(t *testing.T, handshake bool) *stallingServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &stallingServer{listener: listener, handshake: handshake, reached: make(chan struct{}, 16)}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+// port is the port the server listens on
+func (s *stallingServer) port() string {
+	return strconv.Itoa(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// serve speaks just enough of the protocol to log any user in and answer
+// pings; the stall lasts until the client hangs up
+func (s *stallingServer) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	stall := func() {
+		s.reached <- struct{}{}
+		_, _ = io.Copy(io.Discard, conn)
+	}
+	if !s.handshake {
+		stall()
+		return
+	}
+
+	// Protocol 10 handshake offering mysql_native_password, then OK for
+	// whatever the client answers
+	scramble := []byte("abcdefghijklmnopqrst")
+	greeting := []byte{10}
+	greeting = append(greeting, "8.0.36\x00"...)
+	greeting = append(greeting, 1, 0, 0, 0)
+	greeting = append(greeting, scramble[:8]...)
+	greeting = append(greeting, 0)
+	const capabilities = 0x0001 | 0x0008 | 0x0200 | 0x2000 | 0x8000 | 0x80000
+	greeting = binary.LittleEndian.AppendUint16(greeting, capabilities&0xffff)
+	greeting = append(greeting, 255, 2, 0)
+	greeting = binary.LittleEndian.AppendUint16(greeting, capabilities>>16)
+	greeting = append(greeting, 21)
+	greeting = append(greeting, make([]byte, 10)...)
+	greeting = append(greeting, scramble[8:]...)
+	greeting = append(greeting, 0)
+	greeting = append(greeting, "mysql_native_password\x00"...)
+	ok := []byte{0, 0, 0, 2, 0, 0, 0}
+
+	if writePacket(conn, 0, greeting) != nil {
+		return
+	}
+	if _, err := readPacket(conn); err != nil || writePacket(conn, 2, ok) != nil {
+		return
+	}
+	for {
+		payload, err := readPacket(conn)
+		if err != nil || len(payload) == 0 {
+			return
+		}
+		switch payload[0] {
+		case 0x01: // COM_QUIT
+			return
+		case 0x03: // COM_QUERY
+			stall()
+			return
+		default: // COM_PING and the rest
+			if writePacket(conn, 1, ok) != nil {
+				return
+			}
+		}
+	}
+}
+
+// writePacket writes a protocol packet: length, sequence number, payload
+func writePacket(conn net.Conn, seq byte, payload []byte) error {
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}
+	_, err := conn.Write(append(header, payload...))
+	return err
+}
+
+// readPacket reads a protocol packet's payload
+func readPacket(conn net.Conn) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	_, err := io.ReadFull(conn, payload)
+	return payload, err
+}
+
+// TestInterrupt starts dbdump against a server that stalls at each stage,
+// presses Ctrl+C once it is there, and checks that dbdump exits promptly
+// with the interrupted exit code, leaving nothing in its output directory
+func TestInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs SIGINT")
+	}
+	tests := []struct {
+		name      string
+		handshake bool
+		args      []string
+	}{
+		{name: "list while connecting", args: []string{"list"}},
+		{name: "list during inspection", handshake: true, args: []string{"list"}},
+		{name: "dump while connecting", args: []string{"dump", "--auto", "--no-progress"}},
+		{name: "dump during inspection", handshake: true, args: []string{"dump", "--auto", "--no-progress"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startStallingServer(t, tt.handshake)
+			work := t.TempDir()
+			out := filepath.Join(work, "out")
+			if err := os.Mkdir(out, 0755); err != nil {
+				t.Fatal(err)
+			}
+			bin := fakeClientTools(t)
+
+			args := append(tt.args, "-H", "127.0.0.1", "-P", server.port(), "-u", "app", "-d", "shop")
+			if tt.args[0] == "dump" {
+				args = append(args, "-o", filepath.Join(out, "shop.sql"))
+			}
+			cmd := exec.Command(os.Args[0], args...)
+			cmd.Dir = work
+			cmd.Env = append(os.Environ(), "DBDUMP_TEST_MAIN=1", "HOME="+work, "XDG_CONFIG_HOME="+work,
+				"PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"), "MYSQL_PWD=secret", "DBDUMP_MYSQL_PWD=secret")
+			var stderr bytes.Buffer
+			cmd.Stdout, cmd.Stderr = io.Discard, &stderr
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			exited := make(chan error, 1)
+			go func() {
+				exited <- cmd.Wait()
+			}()
+
+			select {
+			case <-server.reached:
+			case err := <-exited:
+				t.Fatalf("dbdump exited before reaching the stage: %v\n%s", err, stderr.String())
+			case <-time.After(30 * time.Second):
+				_ = cmd.Process.Kill()
+				t.Fatalf("dbdump never reached the stage\n%s", stderr.String())
+			}
+			if err := cmd.Process.Signal(os.Interrupt); err != nil {
+				t.Fatal(err)
+			}
+
+			var err error
+			select {
+			case err = <-exited:
+			case <-time.After(interruptBound):
+				_ = cmd.Process.Kill()
+				<-exited
+				t.Fatalf("dbdump still running %v after Ctrl+C\n%s", interruptBound, stderr.String())
+			}
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitInterrupted {
+				t.Errorf("exit = %v, want code %d\n%s", err, exitInterrupted, stderr.String())
+			}
+			if !strings.Contains(stderr.String(), "interrupted") {
+				t.Errorf("stderr doesn't say the run was interrupted:\n%s", stderr.String())
+			}
+
+			entries, err := os.ReadDir(out)
+			if err != nil || len(entries) != 0 {
+				t.Errorf("output directory holds %v, %v; want nothing", entries, err)
+			}
+		})
+	}
+}
+
+// fakeClientTools returns a directory with a mysqldump that only reports
+// its version, so the dump command gets as far as connecting
+func fakeClientTools(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'mysqldump  Ver 8.0.36 for Linux on x86_64'\n"
+	if err := os.WriteFile(filepath.Join(dir, "mysqldump"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/units"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// defaultJobsConfig is the project config dbdump run reads without -c
+const defaultJobsConfig = "dbdump.yaml"
+
+var (
+	parallelJobs int
+
+	// outputDir is where generated dump names are written (set by jobs)
+	outputDir string
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <job>...",
+	Short: "Run dump jobs defined in the project config",
+	Long: `Run the named dump jobs from the jobs: section of the project config
+(dbdump.yaml unless -c is given), one after another, and report the outcome
+of each. A job names a profile or connection, its exclude/only rules, sample
+sizes and output settings; the job named defaults supplies whatever the
+others leave unset. Jobs always run non-interactively, as with --auto.
+
+Connection flags given on the command line override those of every job.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeJobNames,
+	RunE:              runJobs,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the project config, including its jobs",
+	Long: `Check the project config (dbdump.yaml unless -c is given): exclusion and
+only patterns, and for each job its connection, profile, rules and options
+with the defaults job applied.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigValidate,
+}
+
+func init() {
+	runCmd.Flags().StringArrayVarP(&configFiles, "config", "c", nil, "Project config with the jobs, repeatable and merged in order (default: "+defaultJobsConfig+")")
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what each job would dump without dumping")
+	runCmd.Flags().BoolVar(&dumpJSON, "json", false, "Write the run report to stdout as JSON; messages go to stderr")
+	runCmd.Flags().BoolVar(&noProgress, "no-progress", false, "Disable progress indicator (same as --progress none)")
+	runCmd.Flags().StringVar(&reportFile, "report-file", "", "Also write the run report as JSON to this file")
+	runCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stop at the first job that fails instead of continuing")
+	runCmd.Flags().IntVar(&parallelJobs, "parallel-jobs", 1, "Run up to this many jobs at once, each in its own dbdump process")
+	configValidateCmd.Flags().StringArrayVarP(&configFiles, "config", "c", nil, "Project config to check, repeatable and merged in order (default: "+defaultJobsConfig+")")
+
+	rootCmd.AddCommand(runCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
+
+// loadJobsConfig loads the project configs named by -c, or dbdump.yaml
+func loadJobsConfig() (*config.Config, error) {
+	if len(configFiles) == 0 {
+		configFiles = []string{defaultJobsConfig}
+	}
+	return loadProjectConfig()
+}
+
+func runJobs(cmd *cobra.Command, args []string) error {
+	if err := validateParallelJobs(); err != nil {
+		return err
+	}
+	if dumpJSON {
+		startJSONOutput()
+	}
+	projectConfig, err := loadJobsConfig()
+	if err != nil {
+		return err
+	}
+	source := configSource()
+	if problems := jobProblems(projectConfig); len(problems) > 0 {
+		return &dberrors.ErrConfigInvalid{Source: source, Problems: problems}
+	}
+
+	if !cmd.Flags().Changed("parallel-jobs") {
+		tuned, err := resolvePerformance(jobsHost(projectConfig, args), ".")
+		if err != nil {
+			return err
+		}
+		parallelJobs = tuned.settings.DumpParallelism
+	}
+
+	jobs := make(map[string]config.Job, len(args))
+	report := &runReport{StartedAt: time.Now().UTC()}
+	for _, name := range args {
+		if _, seen := jobs[name]; seen {
+			continue
+		}
+		job, err := projectConfig.Job(name)
+		if err != nil {
+			return &dberrors.ErrConfigInvalid{Source: source, Problems: []string{err.Error()}}
+		}
+		jobs[name] = job
+		report.Databases = append(report.Databases, databaseReport{Job: name, Database: job.Database, Status: statusSkipped})
+	}
+
+	if parallelJobs > 1 && len(report.Databases) > 1 {
+		return reportRun(cmd, report, func() error {
+			return runJobProcesses(cmd, report)
+		})
+	}
+
+	saved := saveJobFlags()
+	defer saved.restore()
+	return reportRun(cmd, report, func() error {
+		return dumpEach(cmd, report, func(entry *databaseReport) error {
+			saved.restore()
+			if err := applyJob(cmd, jobs[entry.Job]); err != nil {
+				return err
+			}
+			entry.Database = dbName
+			ui.SetProgressMode(ui.CurrentProgressMode(), progressInterval.Value, entry.Job)
+			return nil
+		})
+	})
+}
+
+// jobFlags holds the flag values a job changes, so that every job starts
+// from those given on the command line
+type jobFlags struct {
+	host, user, password, dbName, awsRegion string
+	port                                    int
+	awsIAMAuth                              bool
+	profileName                             string
+	activeProfile                           *config.ConnectionProfile
+
+	outputFile, outputDir, verifyMode string
+	compressOutput, autoMode          bool
+	maxFileSize                       units.Size
+
+	excludeTables, excludePattern, onlyTables, onlyPattern []string
+	includeTables, includePattern                          []string
+	sampleFlags, skipEngines, tagSpecs                     []string
+}
+
+func saveJobFlags() jobFlags {
+	return jobFlags{
+		host: host, user: user, password: password, dbName: dbName, awsRegion: awsRegion,
+		port: port, awsIAMAuth: awsIAMAuth, profileName: profileName, activeProfile: activeProfile,
+		outputFile: outputFile, outputDir: outputDir, verifyMode: verifyMode,
+		compressOutput: compressOutput, autoMode: autoMode, maxFileSize: maxFileSize,
+		excludeTables: excludeTables, excludePattern: excludePattern,
+		onlyTables: onlyTables, onlyPattern: onlyPattern,
+		includeTables: includeTables, includePattern: includePattern,
+		sampleFlags: sampleFlags, skipEngines: skipEngines, tagSpecs: tagSpecs,
+	}
+}
+
+func (f jobFlags) restore() {
+	host, user, password, dbName, awsRegion = f.host, f.user, f.password, f.dbName, f.awsRegion
+	port, awsIAMAuth, profileName, activeProfile = f.port, f.awsIAMAuth, f.profileName, f.activeProfile
+	outputFile, outputDir, verifyMode = f.outputFile, f.outputDir, f.verifyMode
+	compressOutput, autoMode, maxFileSize = f.compressOutput, f.autoMode, f.maxFileSize
+	excludeTables, excludePattern = slices.Clone(f.excludeTables), slices.Clone(f.excludePattern)
+	onlyTables, onlyPattern = slices.Clone(f.onlyTables), slices.Clone(f.onlyPattern)
+	includeTables, includePattern = slices.Clone(f.includeTables), slices.Clone(f.includePattern)
+	sampleFlags, skipEngines, tagSpecs = slices.Clone(f.sampleFlags), slices.Clone(f.skipEngines), slices.Clone(f.tagSpecs)
+}
+
+// applyJob sets the flags from a job. Its profile is applied first and its
+// own connection fields override the profile; flags given on the command
+// line override both.
+func applyJob(cmd *cobra.Command, job config.Job) error {
+	flags := cmd.Flags()
+	if job.Profile != "" && !flags.Changed("profile") {
+		profileName = job.Profile
+	}
+	if err := applyProfile(cmd); err != nil {
+		return err
+	}
+	if job.Host != "" && !flags.Changed("host") {
+		host = job.Host
+	}
+	if job.Port != 0 && !flags.Changed("port") {
+		port = job.Port
+	}
+	if job.User != "" && !flags.Changed("user") {
+		user = job.User
+	}
+	if job.Database != "" && !flags.Changed("database") {
+		dbName = job.Database
+	}
+
+	autoMode = true
+	excludeTables = append(excludeTables, job.Exclude.Exact...)
+	excludePattern = append(excludePattern, job.Exclude.Patterns...)
+	onlyTables = append(onlyTables, job.Only.Exact...)
+	onlyPattern = append(onlyPattern, job.Only.Patterns...)
+	includeTables = append(includeTables, job.Include.Exact...)
+	includePattern = append(includePattern, job.Include.Patterns...)
+	for _, table := range slices.Sorted(maps.Keys(job.Sample)) {
+		sampleFlags = append(sampleFlags, fmt.Sprintf("%s=%d", table, job.Sample[table]))
+	}
+	for _, key := range slices.Sorted(maps.Keys(job.Tags)) {
+		tagSpecs = append(tagSpecs, key+"="+job.Tags[key])
+	}
+	if job.SkipEngines != nil {
+		skipEngines = job.SkipEngines
+	}
+
+	outputFile, outputDir = job.Output, job.OutputDir
+	if job.Compress != nil {
+		compressOutput = *job.Compress
+	}
+	if job.MaxFileSize != "" {
+		if err := maxFileSize.Set(job.MaxFileSize); err != nil {
+			return err
+		}
+	}
+	if job.Verify != "" {
+		verifyMode = job.Verify
+	}
+	return nil
+}
+
+// jobProblems checks the jobs of a project config: the config's own checks,
+// profiles that don't exist and patterns that don't parse
+func jobProblems(projectConfig *config.Config) []string {
+	problems := projectConfig.JobProblems()
+
+	var profiles *config.ProfilesConfig
+	for _, name := range projectConfig.JobNames() {
+		job, err := projectConfig.Job(name)
+		if err != nil {
+			continue
+		}
+		if job.Profile != "" {
+			if profiles == nil {
+				if profiles, err = config.LoadProfiles(); err != nil {
+					return append(problems, err.Error())
+				}
+			}
+			if _, err := profiles.GetProfile(job.Profile); err != nil {
+				problems = append(problems, fmt.Sprintf("jobs.%s: no profile named %q", name, job.Profile))
+			}
+		}
+		for _, rules := range []struct {
+			what  string
+			rules config.ExcludeConfig
+		}{{"exclude", job.Exclude}, {"include", job.Include}, {"only", job.Only}} {
+			normalized := normalizePatterns("jobs."+name+" "+rules.what, rules.rules)
+			if err := patterns.Validate(normalized, "jobs."+name+" "+rules.what+" patterns"); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	return problems
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	projectConfig, err := loadJobsConfig()
+	if err != nil {
+		return err
+	}
+
+	ruleOrigins.Merge(projectConfig.Origins)
+	problems := jobProblems(projectConfig)
+	if _, performanceProblems := projectConfig.Performance.Settings(); len(performanceProblems) > 0 {
+		problems = append(problems, performanceProblems...)
+	}
+	for _, rules := range []struct {
+		what  string
+		rules config.ExcludeConfig
+	}{{"exclude", projectConfig.Exclude}, {"include", projectConfig.Include}, {"only", projectConfig.Only}} {
+		normalized := normalizePatterns(rules.what, rules.rules)
+		if err := patterns.Validate(normalized, rules.what+" patterns"); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, rule := range slices.Sorted(maps.Keys(projectConfig.Confidence)) {
+		if _, err := config.ParseConfidence(projectConfig.Confidence[rule]); err != nil {
+			problems = append(problems, fmt.Sprintf("confidence of %s: %v", rule, err))
+		}
+	}
+	if len(problems) > 0 {
+		return &dberrors.ErrConfigInvalid{Source: configSource(), Problems: problems}
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("%s is valid (%d jobs)", configSource(), len(projectConfig.JobNames())))
+	return nil
+}
+
+// completeJobNames completes dbdump run arguments with the jobs of the
+// project config
+func completeJobNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	projectConfig, err := loadJobsConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, name := range projectConfig.JobNames() {
+		if strings.HasPrefix(name, toComplete) && !slices.Contains(args, name) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// runJobProcesses runs each job in its own dbdump process, up to
+// --parallel-jobs at a time, since a dump's settings are process-wide. Each
+// process reports its job as JSON; its messages and progress share stderr.
+func runJobProcesses(cmd *cobra.Command, report *runReport) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot run jobs in parallel: %w", err)
+	}
+	common := jobProcessArgs(cmd)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		stopErr error
+	)
+	slots := make(chan struct{}, parallelJobs)
+	for i := range report.Databases {
+		entry := &report.Databases[i]
+		slots <- struct{}{}
+		mu.Lock()
+		stop := stopErr != nil || cmd.Context().Err() != nil
+		mu.Unlock()
+		if stop {
+			<-slots
+			break
+		}
+		ui.PrintInfo(fmt.Sprintf("[%d/%d] Starting %s", i+1, len(report.Databases), entry.Job))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			outcome := runJobProcess(cmd, executable, append(slices.Clone(common), entry.Job), entry)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case entry.Status == statusInterrupted && stopErr == nil:
+				stopErr = outcome
+			case entry.Status == statusFailed && failFast && stopErr == nil:
+				stopErr = outcome
+			}
+		}()
+	}
+	wg.Wait()
+	if stopErr != nil && cmd.Context().Err() != nil {
+		return fmt.Errorf("%w: %w", dberrors.ErrDumpInterrupted, cmd.Context().Err())
+	}
+	return stopErr
+}
+
+// runJobProcess runs one job in a child dbdump and fills in its entry from
+// the run report the child writes
+func runJobProcess(cmd *cobra.Command, executable string, args []string, entry *databaseReport) error {
+	child := exec.CommandContext(cmd.Context(), executable, args...)
+	child.Cancel = func() error {
+		return child.Process.Signal(os.Interrupt)
+	}
+	child.Env = os.Environ()
+	if password != "" {
+		child.Env = append(child.Env, "DBDUMP_MYSQL_PWD="+password)
+	}
+	child.Env = append(child.Env, parallelJobsEnv+"="+strconv.Itoa(parallelJobs))
+	var stdout bytes.Buffer
+	child.Stdout = &stdout
+	child.Stderr = os.Stderr
+
+	started := time.Now()
+	runErr := child.Run()
+	entry.DurationMs = time.Since(started).Milliseconds()
+
+	var childReport runReport
+	if err := json.Unmarshal(stdout.Bytes(), &childReport); err != nil || len(childReport.Databases) != 1 {
+		entry.Status = statusFailed
+		if runErr == nil {
+			runErr = fmt.Errorf("job %s wrote no run report", entry.Job)
+		}
+		entry.Error = runErr.Error()
+		return runErr
+	}
+	*entry = childReport.Databases[0]
+	if entry.Status == statusOK {
+		return nil
+	}
+	return fmt.Errorf("job %s: %s", entry.Job, entry.Error)
+}
+
+// jobProcessArgs returns the arguments each job process is started with:
+// the flags given to this run, minus those only the parent acts on. The
+// password is passed in the environment instead.
+func jobProcessArgs(cmd *cobra.Command) []string {
+	args := []string{"run", "--json", "--parallel-jobs", "1"}
+	if !cmd.Flags().Changed("progress") && !noProgress {
+		args = append(args, "--progress", string(ui.ProgressPlain))
+	}
+	skip := map[string]bool{"json": true, "parallel-jobs": true, "report-file": true, "password": true, "config": true}
+	for _, file := range configFiles {
+		args = append(args, "--config", file)
+	}
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if skip[flag.Name] {
+			return
+		}
+		if values, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range values.GetSlice() {
+				args = append(args, "--"+flag.Name, value)
+			}
+			return
+		}
+		if flag.Value.Type() == "bool" {
+			args = append(args, "--"+flag.Name+"="+flag.Value.String())
+			return
+		}
+		args = append(args, "--"+flag.Name, flag.Value.String())
+	})
+	return args
+}
+
+// jobsHost returns the host --auto-tune picks the number of parallel jobs
+// for: a remote one if any of the jobs dumps from a remote server
+func jobsHost(projectConfig *config.Config, names []string) string {
+	for _, name := range names {
+		if job, err := projectConfig.Job(name); err == nil && job.Host != "" && database.NormalizeHost(job.Host) != "localhost" {
+			return job.Host
+		}
+	}
+	return host
+}
+
+// validateParallelJobs rejects a --parallel-jobs below 1
+func validateParallelJobs() error {
+	if parallelJobs < 1 {
+		return &dberrors.ErrConfigInvalid{Source: "--parallel-jobs", Problems: []string{"must be at least 1, got " + strconv.Itoa(parallelJobs)}}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/plan"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/triggers"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// jsonResult receives the one JSON document written by --json; nil when
+// the output is for people
+var jsonResult io.Writer
+
+// startJSONOutput keeps stdout for the JSON result and sends everything
+// else printed during the run (messages, progress, warnings) to stderr
+func startJSONOutput() {
+	jsonResult = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+// writeJSON writes v as the run's JSON result
+func writeJSON(v any) error {
+	encoder := json.NewEncoder(jsonResult)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to write JSON output: %w", err)
+	}
+	return nil
+}
+
+// tableView is a table as written by list --json
+type tableView struct {
+	Name        string `json:"name"`
+	RowCount    int64  `json:"row_count"`
+	DataSize    int64  `json:"data_size"`
+	IndexSize   int64  `json:"index_size"`
+	TotalSize   int64  `json:"total_size"`
+	Engine      string `json:"engine,omitempty"`
+	SizeUnknown bool   `json:"size_unknown,omitempty"`
+}
+
+func tableViews(tablesInfo []database.TableInfo) []tableView {
+	views := make([]tableView, len(tablesInfo))
+	for i, info := range tablesInfo {
+		views[i] = tableView{
+			Name:        info.Name,
+			RowCount:    info.RowCount,
+			DataSize:    info.DataSize,
+			IndexSize:   info.IndexSize,
+			TotalSize:   info.TotalSize,
+			Engine:      info.Engine,
+			SizeUnknown: info.SizeUnknown,
+		}
+	}
+	return views
+}
+
+// plannedTable is one table in dump --dry-run --json
+type plannedTable struct {
+	Name       string            `json:"name"`
+	RowCount   int64             `json:"row_count"`
+	DataSize   int64             `json:"data_size"`
+	TotalSize  int64             `json:"total_size"`
+	Structure  string            `json:"structure,omitempty"`
+	SampleRows int               `json:"sample_rows,omitempty"`
+	Masked     []string          `json:"masked_columns,omitempty"`
+	Nulled     map[string]string `json:"null_columns,omitempty"`
+	CopyOf     string            `json:"copy_of,omitempty"`
+	Rule       string            `json:"rule,omitempty"`
+	Confidence string            `json:"confidence,omitempty"`
+}
+
+// dryRunView is the dump plan written by dump --dry-run --json
+type dryRunView struct {
+	Database      string         `json:"database"`
+	OutputFile    string         `json:"output_file"`
+	Parts         bool           `json:"parts,omitempty"`
+	EstimatedSize *int64         `json:"estimated_size"`
+	Included      []plannedTable `json:"included"`
+	Excluded      []plannedTable `json:"excluded"`
+	Skipped       []plannedTable `json:"skipped"`
+	Commands      []commandView  `json:"commands,omitempty"`
+
+	TriggerEffects []triggers.Effect `json:"trigger_effects,omitempty"`
+}
+
+// commandView is a mysqldump invocation in dump --dry-run --json
+type commandView struct {
+	Phase string   `json:"phase"`
+	Args  []string `json:"args"`
+}
+
+// dryRunJSON describes the dump plan for --dry-run --json
+func dryRunJSON(dp *planner.DumpPlan) dryRunView {
+	view := dryRunView{
+		Database:   dp.Database,
+		OutputFile: dp.OutputFile,
+		Parts:      dp.Parts,
+		Included:   []plannedTable{},
+		Excluded:   []plannedTable{},
+		Skipped:    []plannedTable{},
+	}
+	if dp.SizesKnown {
+		estimate := dp.EstimatedSize
+		view.EstimatedSize = &estimate
+	}
+	for _, table := range dp.Tables {
+		planned := plannedTable{
+			Name:       table.Name,
+			RowCount:   table.RowCount,
+			DataSize:   table.DataSize,
+			TotalSize:  table.TotalSize,
+			Structure:  string(table.Structure),
+			SampleRows: table.SampleRows,
+			Masked:     table.Masked,
+			Nulled:     table.Nulled,
+			CopyOf:     table.CopyOf,
+			Rule:       table.Rule,
+			Confidence: string(table.Confidence),
+		}
+		switch table.Disposition {
+		case plan.DispositionSkipped:
+			view.Skipped = append(view.Skipped, planned)
+		case plan.DispositionStructureOnly:
+			view.Excluded = append(view.Excluded, planned)
+		default:
+			view.Included = append(view.Included, planned)
+		}
+	}
+	for _, command := range dp.Commands {
+		view.Commands = append(view.Commands, commandView{Phase: command.Phase, Args: command.Args})
+	}
+	return view
+}
+
+// dumpView is the summary written by dump --json once the dump is done
+type dumpView struct {
+	Database         string         `json:"database"`
+	OutputFile       string         `json:"output_file"`
+	Parts            []string       `json:"parts,omitempty"`
+	DurationMs       int64          `json:"duration_ms"`
+	FileSize         int64          `json:"file_size"`
+	UncompressedSize int64          `json:"uncompressed_size"`
+	ExcludedTables   []string       `json:"excluded_tables"`
+	SkippedTables    []string       `json:"skipped_tables"`
+	OverBudgetTables []string       `json:"over_budget_tables,omitempty"`
+	Warnings         []diag.Warning `json:"warnings"`
+}
+
+func dumpJSONView(result *database.DumpResult, skipped []string) dumpView {
+	view := dumpView{
+		Database:         dbName,
+		OutputFile:       result.OutputFile,
+		DurationMs:       result.Duration.Milliseconds(),
+		FileSize:         result.FileSize,
+		UncompressedSize: result.UncompressedSize,
+		ExcludedTables:   append([]string{}, result.ExcludedTables...),
+		SkippedTables:    append([]string{}, skipped...),
+		OverBudgetTables: result.OverBudget,
+		Warnings:         append([]diag.Warning{}, diag.Warnings()...),
+	}
+	for _, part := range result.Parts {
+		view.Parts = append(view.Parts, part.Path)
+	}
+	return view
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/table"
+	"github.com/helgesverre/dbdump/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintFormat    string
+	lintPacket    units.Size
+	lintListRules bool
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <dump file>",
+	Short: "Check a dump file for statements other servers won't restore",
+	Long: `Stream through a dump file (plain, gzip or zstd, or a split dump) once and
+report what restores on the source's kind of server but can fail elsewhere:
+INSERT statements over common max_allowed_packet defaults, zero dates under a
+strict sql_mode, latin1 mixed with utf8, ROW_FORMAT and TABLESPACE clauses,
+and collations older or other servers don't have.
+
+Each finding names the rule, the first line and table it was seen at, how many
+lines matched, and what to change. Findings of severity "error" make the
+command fail; --rules lists the rules.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if lintListRules {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: runLint,
+}
+
+func init() {
+	lintCmd.Flags().StringVar(&lintFormat, "format", "table", "Output format: table or json")
+	lintCmd.Flags().Var(&lintPacket, "max-allowed-packet", "The target's max_allowed_packet (default: check against common server defaults)")
+	lintCmd.Flags().BoolVar(&lintListRules, "rules", false, "List the lint rules and exit")
+	rootCmd.AddCommand(lintCmd)
+}
+
+// lintReport is the JSON output of dbdump lint
+type lintReport struct {
+	File     string                 `json:"file"`
+	Findings []dumpfile.LintFinding `json:"findings"`
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	if lintFormat != "table" && lintFormat != "json" {
+		return fmt.Errorf("unsupported --format %q (supported: table, json)", lintFormat)
+	}
+	if lintListRules {
+		return printLintRules()
+	}
+
+	findings, err := dumpfile.Lint(args[0], dumpfile.LintOptions{MaxAllowedPacket: lintPacket.Bytes})
+	if err != nil {
+		return err
+	}
+
+	if lintFormat == "json" {
+		report := lintReport{File: args[0], Findings: findings}
+		if report.Findings == nil {
+			report.Findings = []dumpfile.LintFinding{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printLintFindings(findings)
+		if len(findings) == 0 {
+			ui.PrintSuccess("No portability problems found")
+		}
+	}
+
+	if errors := countLintErrors(findings); errors > 0 {
+		return &dberrors.ErrVerificationFailed{
+			Checks: []string{"lint"},
+			Err:    fmt.Errorf("%d finding(s) of severity error", errors),
+		}
+	}
+	return nil
+}
+
+// runLintVerification lints a finished dump for --verify. Findings are
+// reported but don't fail the dump: they depend on the target, which the
+// dump doesn't know.
+func runLintVerification(dumpFile string) {
+	findings, err := dumpfile.Lint(dumpFile, dumpfile.LintOptions{})
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Skipping the lint check: %v", err))
+		return
+	}
+	if len(findings) == 0 {
+		ui.PrintSuccess("No portability problems found")
+		return
+	}
+	printLintFindings(findings)
+	ui.PrintInfo("These may fail on other servers; see dbdump lint --rules")
+}
+
+// printLintFindings prints each finding with its suggestion
+func printLintFindings(findings []dumpfile.LintFinding) {
+	for _, finding := range findings {
+		message := fmt.Sprintf("line %d: %s [%s]", finding.Line, finding.Message, finding.Rule)
+		if finding.Lines > 1 {
+			message = fmt.Sprintf("line %d (and %d more in `%s`): %s [%s]", finding.Line, finding.Lines-1, finding.Table, finding.Message, finding.Rule)
+		}
+		switch finding.Severity {
+		case dumpfile.LintError:
+			ui.PrintFailure(message)
+		case dumpfile.LintWarning:
+			ui.PrintWarning(message)
+		default:
+			ui.PrintInfo(message)
+		}
+		fmt.Printf("    %s\n", finding.Suggestion)
+	}
+}
+
+// countLintErrors returns the number of findings of severity error
+func countLintErrors(findings []dumpfile.LintFinding) int {
+	count := 0
+	for _, finding := range findings {
+		if finding.Severity == dumpfile.LintError {
+			count++
+		}
+	}
+	return count
+}
+
+// printLintRules lists the rules of dbdump lint
+func printLintRules() error {
+	if lintFormat == "json" {
+		type rule struct {
+			ID          string                `json:"id"`
+			Severity    dumpfile.LintSeverity `json:"severity"`
+			Description string                `json:"description"`
+			Suggestion  string                `json:"suggestion"`
+		}
+		rules := make([]rule, len(dumpfile.LintRules))
+		for i, r := range dumpfile.LintRules {
+			rules[i] = rule{r.ID, r.Severity, r.Description, r.Suggestion}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rules)
+	}
+
+	out := table.New(
+		table.Column{Title: "Rule"},
+		table.Column{Title: "Severity"},
+		table.Column{Title: "Checks", MaxWidth: 80, Flex: true},
+	)
+	for _, rule := range dumpfile.LintRules {
+		out.Row(rule.ID, string(rule.Severity), rule.Description)
+	}
+	return out.Render(os.Stdout, table.Text)
+}
@@ -1,19 +1,46 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/helgesverre/dbdump/internal/config"
 	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/metadata"
 	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/plan"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/redact"
+	"github.com/helgesverre/dbdump/internal/tags"
+	"github.com/helgesverre/dbdump/internal/transform"
+	"github.com/helgesverre/dbdump/internal/triggers"
 	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/ui/table"
+	"github.com/helgesverre/dbdump/internal/units"
 	"github.com/spf13/cobra"
 )
 
+// Build information, set via -ldflags at build time
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
 var (
 	// Connection flags
 	host     string
@@ -23,35 +50,88 @@ var (
 	dbName   string
 
 	// Dump flags
-	outputFile     string
-	configFile     string
-	excludeTables  []string
-	excludePattern []string
-	autoMode       bool
-	noProgress     bool
-	dryRun         bool
+	outputFile      string
+	configFiles     []string
+	excludeTables   []string
+	excludePattern  []string
+	onlyTables      []string
+	onlyPattern     []string
+	includeTables   []string
+	includePattern  []string
+	excludeAllData  bool
+	autoMode        bool
+	noProgress      bool
+	dryRun          bool
+	verifyMode      string
+	verifyImage     string
+	convertCharset  string
+	skipEngines     []string
+	keepEngineDDL   bool
+	maxFileSize     units.Size
+	verbose         bool
+	updateGitignore bool
+	readOnlySource  bool
+	keepPartial     bool
+	dumpJSON        bool
+
+	// List flags
+	listFormat string
+	listJSON   bool
 )
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	// One context for the whole command: Ctrl+C or SIGTERM cancels
+	// connecting, inspection, the table picker and mysqldump alike. Once it
+	// fires the handler is released, so a second Ctrl+C kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if code := finish(err, os.Stderr); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// finish ends a run: it sends run_completed and prints the command's error
+// with its hint, the password masked in both, and returns the exit code
+func finish(err error, stderr io.Writer) int {
+	if err == nil {
+		err = finishWarnings()
+	}
+	code, hint := 0, ""
+	if err != nil {
+		err = redact.Error(err, password)
+		code, hint = classifyError(err)
+	}
+	finishEvents(err, code)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		if hint != "" {
+			fmt.Fprintf(stderr, "Hint: %s\n", hint)
+		}
 	}
+	return code
 }
 
 var rootCmd = &cobra.Command{
-	Use:   "dbdump",
-	Short: "Intelligent MySQL database dumping tool",
+	Use:           "dbdump",
+	SilenceErrors: true, // errors are printed by main with hints
+	SilenceUsage:  true, // usage is only useful for flag errors, which name the flag
+	Short:         "Intelligent MySQL database dumping tool",
 	Long: `dbdump is a CLI tool for intelligent MySQL database dumping.
 It excludes noisy table data while preserving structure, making database
 dumps faster and more manageable for development environments.`,
 }
 
 var dumpCmd = &cobra.Command{
-	Use:   "dump",
+	Use:   "dump [tables...]",
 	Short: "Dump database with intelligent exclusions",
 	Long: `Dump a MySQL database, excluding data from noisy tables (like audit logs,
-sessions, cache) while preserving their structure.`,
+sessions, cache) while preserving their structure.
+
+Tables given as arguments (names or globs such as "order_*") limit the dump to
+those tables, like --only, and skip the interactive selector.`,
 	RunE: runDump,
 }
 
@@ -84,156 +164,736 @@ func init() {
 
 	// Dump command flags
 	dumpCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: {database}_{timestamp}.sql)")
-	dumpCmd.Flags().StringVarP(&configFile, "config", "c", "", "Config file path")
-	dumpCmd.Flags().StringArrayVar(&excludeTables, "exclude", []string{}, "Exclude specific table data (repeatable)")
-	dumpCmd.Flags().StringArrayVar(&excludePattern, "exclude-pattern", []string{}, "Exclude tables matching pattern (repeatable)")
+	addSelectionFlags(dumpCmd)
 	dumpCmd.Flags().BoolVar(&autoMode, "auto", false, "Use smart defaults without interaction")
-	dumpCmd.Flags().BoolVar(&noProgress, "no-progress", false, "Disable progress indicator")
+	dumpCmd.Flags().BoolVar(&noProgress, "no-progress", false, "Disable progress indicator (same as --progress none)")
+	dumpCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show a per-table timing breakdown after the dump")
+	dumpCmd.Flags().BoolVar(&updateGitignore, "update-gitignore", false, "Add the dump to .gitignore without asking when it is written inside a git repository")
 	dumpCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be dumped without dumping")
+	dumpCmd.Flags().StringVar(&verifyMode, "verify", "", "Verify the dump after writing it (order: check the statement order and lint for portability; restore: also replay into a throwaway Docker container)")
+	dumpCmd.Flags().BoolVar(&keepPartial, "keep-partial", false, "Keep the output of a dump that fails mid-stream as <output>.partial instead of removing it")
+	dumpCmd.Flags().BoolVar(&dumpJSON, "json", false, "Write the result (or the --dry-run plan) to stdout as JSON; messages go to stderr")
+	dumpCmd.Flags().StringVar(&verifyImage, "verify-image", "", "Container image for --verify=restore (default: matches the source server version)")
+
+	// List command flags
+	listCmd.Flags().StringVar(&listFormat, "format", "table", "Output format: table, csv or json")
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "Output the tables as JSON (same as --format json)")
 
 	// Add commands
 	rootCmd.AddCommand(dumpCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(doctorCmd)
 	configCmd.AddCommand(configListCmd)
 }
 
+// addSelectionFlags registers the flags that decide what a dump contains,
+// shared by dump and plan
+func addSelectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&configFiles, "config", "c", nil, "Config file path (repeatable, merged in order)")
+	cmd.Flags().StringArrayVar(&excludeTables, "exclude", []string{}, "Exclude specific table data (repeatable)")
+	cmd.Flags().StringArrayVar(&excludePattern, "exclude-pattern", []string{}, "Exclude tables matching pattern (repeatable)")
+	cmd.Flags().StringArrayVar(&includeTables, "include", []string{}, "Dump data only for this table; all others are structure only (repeatable)")
+	cmd.Flags().StringArrayVar(&includePattern, "include-pattern", []string{}, "Dump data only for tables matching pattern; all others are structure only (repeatable)")
+	cmd.Flags().BoolVar(&excludeAllData, "exclude-all-data", false, "Exclude the data of every table: a structure-only dump (samples still apply)")
+	cmd.Flags().StringArrayVar(&onlyTables, "only", []string{}, "Dump only this table, skipping all others entirely (repeatable)")
+	cmd.Flags().StringArrayVar(&onlyPattern, "only-pattern", []string{}, "Dump only tables matching pattern, skipping all others entirely (repeatable)")
+	cmd.Flags().BoolVar(&readOnlySource, "read-only-source", false, "Open the inspection connection in read-only mode (default on for profiles tagged production)")
+	cmd.Flags().StringSliceVar(&skipEngines, "skip-engines", []string{}, "Skip tables using these storage engines entirely (e.g. FEDERATED,BLACKHOLE)")
+	cmd.Flags().BoolVar(&keepEngineDDL, "skip-engines-keep-structure", false, "With --skip-engines, keep the structure of skipped tables and only skip their data")
+	cmd.Flags().Var(&maxFileSize, "max-file-size", "Split the output into numbered parts of at most this size (e.g. 2GB, 1.5GiB)")
+	cmd.Flags().StringVar(&convertCharset, "convert-charset", "", "Convert table and column character sets to this one (e.g. utf8mb4)")
+	cmd.Flags().BoolVarP(&compressOutput, "compress", "z", false, "Gzip the output (.sql.gz); implied by an output name ending in .gz")
+	cmd.Flags().StringArrayVar(&sampleFlags, "sample", []string{}, "Dump only the last N rows of a table's data, as table=N (repeatable, patterns allowed)")
+	addThresholdFlag(cmd)
+}
+
 func runDump(cmd *cobra.Command, args []string) error {
+	if err := applyProfile(cmd); err != nil {
+		return err
+	}
+	if dumpJSON {
+		startJSONOutput()
+	}
+	if err := startEvents(); err != nil {
+		return err
+	}
+	if multiDatabase() {
+		return runMultiDump(cmd, args)
+	}
+	if err := validateMultiFlags(cmd); err != nil {
+		return err
+	}
+	return dumpDatabase(cmd, args)
+}
+
+// dumpDatabase dumps the database named by -d
+func dumpDatabase(cmd *cobra.Command, args []string) (err error) {
 	// Check mysqldump availability
-	if err := database.CheckMySQLDump(); err != nil {
-		return fmt.Errorf("mysqldump is required but not found in PATH")
+	if err := checkDumpTool(); err != nil {
+		return err
 	}
 
-	// Get password from environment if not provided
-	// Check custom dbdump variable first, then fall back to standard MySQL variable
-	if password == "" {
-		password = os.Getenv("DBDUMP_MYSQL_PWD")
-		if password == "" {
-			password = os.Getenv("MYSQL_PWD")
+	resolvePassword()
+
+	// A reviewed plan decides the target and everything the dump contains
+	var dumpPlan *plan.Plan
+	if planFile != "" {
+		var err error
+		if dumpPlan, err = loadDumpPlan(cmd, args); err != nil {
+			return err
 		}
 	}
 
-	// Validate required flags
-	if user == "" {
-		return fmt.Errorf("database user is required (use -u or --user)")
+	maxPartSize, err := validateDumpFlags(args)
+	if err != nil {
+		return err
 	}
-	if dbName == "" {
-		return fmt.Errorf("database name is required (use -d or --database)")
+
+	// An interrupted dump continues in its own output file
+	resumable, err := loadResume(cmd, args)
+	if err != nil {
+		return err
+	}
+	generatedName, err := resolveOutputFile(resumable)
+	if err != nil {
+		return err
 	}
 
-	// Create connection
-	conn := &database.Connection{
-		Host:     host,
-		Port:     port,
-		User:     user,
-		Password: password,
-		Database: dbName,
+	// Fail before connecting (and before the selector) if the dump can't be written
+	if err := validateOutputPaths(outputFile, maxPartSize > 0); err != nil {
+		return err
+	}
+	outputFS, err := checkOutputFilesystem(outputFile, maxPartSize)
+	if err != nil {
+		return err
+	}
+	if err := validateStoreFlags(maxPartSize); err != nil {
+		return err
+	}
+	tuned, err := resolvePerformance(host, filepath.Dir(outputFile))
+	if err != nil {
+		return err
 	}
+	if err := prepareDoneFile(); err != nil {
+		return err
+	}
+
+	// Record the run for stats_export, whatever its outcome
+	run := &usageRun{started: time.Now()}
+	defer func() {
+		run.finish(err)
+	}()
 
 	// Connect to database for inspection (this also tests the connection)
-	db, err := conn.Connect()
+	conn, db, err := connectSource(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return err
 	}
 	defer func() {
 		if err := db.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to close database connection: %v\n", err)
+			diag.Warnf("failed to close database connection: %v", err)
 		}
 	}()
+	if err := checkStopReplica(cmd.Context(), conn, db); err != nil {
+		return err
+	}
 
-	ui.PrintSuccess("Connected to database")
-
-	// Get table information
-	inspector := database.NewInspector(db)
-	tablesInfo, err := inspector.GetAllTablesInfo()
+	// Get table information; the interactive selector starts right away
+	// and reads it while the user looks around
+	inspector, err := newInspector(cmd.Context(), db)
 	if err != nil {
-		return fmt.Errorf("failed to get table information: %w", err)
+		return err
 	}
-
-	ui.PrintInfo(fmt.Sprintf("Found %d tables", len(tablesInfo)))
-
-	// Build exclude list
-	excludeConfig, err := buildExcludeConfig()
+	if detectProxy(inspector, conn) && autoTune {
+		// Tuned for a local server before connecting
+		if tuned, err = resolvePerformance(host, filepath.Dir(outputFile)); err != nil {
+			return err
+		}
+	}
+	if err := resumable.checkSource(inspector); err != nil {
+		return err
+	}
+	rulesVersion, err := checkDefaultRules(conn)
 	if err != nil {
 		return err
 	}
-
-	// Match tables against patterns
-	matcher := patterns.NewMatcher(excludeConfig)
-	tableNames := make([]string, len(tablesInfo))
-	for i, info := range tablesInfo {
-		tableNames[i] = info.Name
+	if appendSinceLast {
+		return runAppendIncrement(cmd, conn, inspector, tuned)
+	}
+	interactive := !autoMode && len(args) == 0 && dumpPlan == nil && !schemaDelta
+	if interactive && jsonResult != nil {
+		return fmt.Errorf("--json needs a non-interactive selection: use --auto, table arguments or --plan")
 	}
-	preSelected := matcher.FilterTables(tableNames)
 
+	var found *inspection
 	var finalExcludes []string
-
-	if autoMode {
-		// Auto mode: use pattern-matched excludes
-		finalExcludes = preSelected
-		ui.PrintInfo(fmt.Sprintf("Auto mode: excluding %d tables based on patterns", len(finalExcludes)))
-	} else {
-		// Interactive mode
-		selected, err := ui.RunInteractiveSelection(tablesInfo, preSelected)
+	if interactive {
+		found, finalExcludes, err = selectWhileInspecting(cmd.Context(), inspector, conn.ReadOnly)
 		if err != nil {
 			return fmt.Errorf("interactive selection failed: %w", err)
 		}
-		finalExcludes = selected
+	} else if found, err = inspectTables(inspector, args, dumpPlan); err != nil {
+		return err
+	}
+	run.tables = found.count
+	plannedAt := time.Now()
+
+	sel, sizesKnown := found.sel, found.sizesKnown
+	allTables, skippedTables, tablesInfo := sel.All, sel.Skipped, sel.Tables
+
+	// Schema deltas contain no data, so there is nothing to select
+	if schemaDelta {
+		return runSchemaDelta(cmd.Context(), conn, allTables, skippedTables)
 	}
 
-	// Generate output filename if not provided
-	if outputFile == "" {
-		timestamp := time.Now().Format("20060102_150405")
-		outputFile = fmt.Sprintf("%s_%s.sql", dbName, timestamp)
+	// The selector chose while the tables were read
+	if !interactive {
+		if finalExcludes, err = chooseExcludes(sel, args, dumpPlan); err != nil {
+			return err
+		}
 	}
+	finalExcludes = sel.Decide(finalExcludes)
 
-	// Make output path absolute
-	outputFile, err = filepath.Abs(outputFile)
+	// The selector may have been open for minutes: bring the selection up to
+	// date with the tables that exist now
+	finalExcludes, snapshots, err := checkTableDrift(inspector, sel, finalExcludes, plannedAt, interactive)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+		return err
 	}
+	allTables, skippedTables, tablesInfo = sel.All, sel.Skipped, sel.Tables
+	samples := sel.Samples.Counts(finalExcludes)
 
-	if dryRun {
-		fmt.Println("\nDry run - would exclude the following tables:")
-		for _, table := range finalExcludes {
-			fmt.Printf("  - %s\n", table)
+	// Check the conversion before dumping so overflowing columns fail fast
+	var charsetTransform transform.Func
+	if convertCharset != "" {
+		charsetTransform, err = prepareCharsetConversion(inspector, tablesInfo, convertCharset)
+		if err != nil {
+			return err
+		}
+	}
+	packetLimit := checkPacketLimit(cmd.Context(), inspector, tablesInfo, finalExcludes, samples)
+	timeZones := checkTimeZones(inspector)
+	serverVariables := captureVariables(inspector)
+	migrations := captureMigrations(inspector, tablesInfo)
+
+	// Masks are checked against the tables' columns before anything is written
+	masked, err := maskedTables(cmd.Context(), inspector, allTables, finalExcludes, skippedTables, samples)
+	if err != nil {
+		return err
+	}
+	twins, err := caseTwinTables(cmd.Context(), inspector, allTables, finalExcludes, skippedTables, masked)
+	if err != nil {
+		return err
+	}
+	masked = append(masked, twins...)
+	masked, chain, err := appendBase(cmd.Context(), inspector, allTables, finalExcludes, skippedTables, masked, maxPartSize)
+	if err != nil {
+		return err
+	}
+	deduped, err := planDedupe(cmd.Context(), inspector, allTables, finalExcludes, skippedTables, masked)
+	if err != nil {
+		return err
+	}
+	// A resumed dump keeps the compression of the file it continues
+	if resumable.outputFile() == "" {
+		outputFile, err = chooseCompression(database.DumpOptions{
+			Connection:             conn,
+			ServerMaxAllowedPacket: packetLimit,
+			DefaultCharacterSet:    convertCharset,
+			ExtraArgs:              tzUTCArgs(),
+			Context:                cmd.Context(),
+			Native:                 nativeDump,
+		}, &tuned, tablesInfo, finalExcludes, skippedTables, sizesKnown, maxPartSize)
+		if err != nil {
+			return err
 		}
-		fmt.Printf("\nWould create dump file: %s\n", outputFile)
+	}
+
+	planned := found.planner.Plan(planner.Input{
+		Selection:   sel,
+		Excludes:    finalExcludes,
+		Masked:      maskedColumns(masked),
+		Nulled:      nulledColumns(masked),
+		Copies:      deduped.copiedFrom(),
+		Native:      maskedNames(masked),
+		SizesKnown:  sizesKnown,
+		OutputFile:  outputFile,
+		MaxFileSize: maxPartSize,
+		Dump: database.DumpOptions{
+			Connection:             conn,
+			ServerMaxAllowedPacket: packetLimit,
+			DefaultCharacterSet:    convertCharset,
+			ExtraArgs:              tzUTCArgs(),
+			Performance:            tuned.settings,
+			Native:                 nativeDump,
+		},
+	})
+	sendPlan(planned)
+	triggerEffects := checkTriggerEffects(inspector, planned)
+	structureFilter := transformFilter(structureTransform(planned.Levels, finalExcludes, skippedTables), charsetTransform)
+	budgetedOrder, err := budgetOrder(tablesInfo, planned.Unstreamed())
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		deduped.record(estimatedSizes(allTables))
+	}
+	if dryRun && jsonResult != nil {
+		view := dryRunJSON(planned)
+		view.TriggerEffects = triggerEffects
+		return writeJSON(view)
+	}
+	if dryRun {
+		printDryRun(planned)
+		printDryRunSteps(chain, budgetedOrder)
 		return nil
 	}
 
+	serverVersion, err := inspector.GetServerVersion()
+	if err != nil {
+		diag.Warnf("%v", err)
+	}
+	createStatements, err := createDatabaseHeader(inspector, conn)
+	if err != nil {
+		return err
+	}
+
+	sampled, err := tableSamples(inspector, finalExcludes, samples)
+	if err != nil {
+		return err
+	}
+	sampled = maskSamples(masked, sampled)
+
+	// Stop the replica at the requested point; replication resumes however
+	// the dump ends
+	pin, err := pinReplica(cmd.Context(), conn, db)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if resumeErr := resumeReplica(pin); resumeErr != nil && err == nil {
+			err = resumeErr
+		}
+	}()
+
+	// Record checksums for a sample of tables before dumping so the restored
+	// copy can be compared against them
+	var checksums []metadata.TableChecksum
+	if verifyMode == "restore" {
+		checksums, verifyMode = prepareRestoreVerification(inspector, tablesInfo, planned.Unstreamed())
+	}
+
 	// Perform the dump
-	ui.PrintInfo(fmt.Sprintf("Starting dump to %s", outputFile))
+	// Without table sizes there is no estimate, and no size check after the dump
+	var estimate int64
+	if planned.SizesKnown {
+		estimate = planned.EstimatedSize
+		ui.PrintInfo(fmt.Sprintf("Starting dump to %s (estimated %s)", outputFile, database.FormatBytes(estimate)))
+		if advice := filesystemAdvice(outputFS, estimate, maxPartSize, compressOutput); advice != "" {
+			ui.PrintWarning(advice)
+		}
+	} else {
+		ui.PrintInfo(fmt.Sprintf("Starting dump to %s", outputFile))
+	}
+	if verbose {
+		tuned.print()
+	}
+
+	resumeFrom, resumeFile, err := resumable.start(outputFile, planned.Excludes(), planned.Skipped())
+	if err != nil {
+		return err
+	}
 
-	dumper := database.NewDumper(&database.DumpOptions{
+	progress := &dumpProgress{}
+	dumper := database.NewDumper(withEvents(&database.DumpOptions{
 		Connection:    conn,
-		ExcludeTables: finalExcludes,
+		ExcludeTables: planned.Excludes(),
+		SkipTables:    planned.Skipped(),
 		OutputFile:    outputFile,
-		ShowProgress:  !noProgress,
 		DryRun:        dryRun,
-	})
+
+		ShowProgress:   progressEnabled(),
+		TableEstimates: planned.Estimates(),
+		OnProgress:     progress.update,
+
+		MaxFileSize:  maxPartSize,
+		MaxTableSize: maxTableSize.Bytes,
+		Compress:     compressOutput,
+		SyncPolicy:   syncPolicy,
+		Samples:      sampled,
+		Masked:       masked,
+		Copies:       deduped.copies,
+
+		ServerMaxAllowedPacket: packetLimit,
+		ExtraArgs:              tzUTCArgs(),
+
+		SampleStatements:  sampleStatements,
+		SampleValueLength: sampleValueLength,
+		SampleFile:        samplePath(outputFile),
+
+		DefaultCharacterSet: convertCharset,
+		StructureFilter:     structureFilter,
+
+		Header:      dumpHeader(conn, serverVersion, timeZones, serverVariables) + createStatements,
+		Context:     cmd.Context(),
+		Performance: tuned.settings,
+
+		TableDefRetries: tableDefRetries,
+		KeepPartial:     keepPartial,
+		Native:          nativeDump,
+		TimeBudget:      timeBudget,
+		BudgetOrder:     budgetedOrder,
+		BeforeRetry:     tableDefRetryHook(inspector, sel, finalExcludes, skippedTables),
+		OnCheckpoint:    resumable.checkpoint,
+		Resume:          resumeFrom,
+		ResumeFile:      resumeFile,
+	}))
 
 	result, err := dumper.Dump()
+	progress.finish(err == nil)
 	if err != nil {
 		ui.PrintError(err)
+		reportTableDefChange(inspector, err)
+		resumable.failed(dumper)
 		return err
 	}
+	resumable.finish()
+	run.result = result
+	lastDump = result
+	recordEventResult(result)
+	checkTimeZoneChange(inspector, timeZones)
+	checksums = recheckChecksums(inspector, checksums)
+
+	// Truncated tables make the dump partial; name it so
+	truncated := truncatedTables(result)
+	if len(truncated) > 0 {
+		if err := markPartial(result); err != nil {
+			return err
+		}
+		for _, table := range truncated {
+			ui.PrintWarning(fmt.Sprintf("Data of %s truncated at %s (%d rows) by --max-table-size", table.Table, database.FormatBytes(table.Bytes), table.Rows))
+		}
+	}
 
-	// Print summary
-	ui.PrintSummary(result.OutputFile, len(result.ExcludedTables), result.Duration, result.FileSizeDisplay)
+	// Write metadata sidecar next to the dump
+	meta := buildMetadata(conn, serverVersion, allTables, finalExcludes, skippedTables, samples, result)
+	meta.Checksums = checksums
+	meta.EstimatedSize = estimate
+	meta.Tags = dumpTags
+	meta.Migrations = migrations
+	meta.StdinConfig = string(config.StdinConfig())
+	meta.TruncatedTables = truncated
+	meta.StatementSampling = statementSampling(outputFile)
+	meta.LargestStatement = result.LargestStatement
+	meta.MaxAllowedPacket = packetLimit
+	meta.ReplicaGTID = stopReplicaAt
+	meta.TableSnapshots = snapshots
+	meta.TimeZones = timeZones
+	meta.ServerVariables = serverVariables
+	meta.RulesVersion = rulesVersion
+	meta.Performance = tuned.metadata()
+	recordMasks(meta, masked)
+	recordCopies(meta, deduped)
+	recordTriggerEffects(meta, triggerEffects)
+	overBudget := recordOverBudget(meta, result)
+	deduped.record(dumpedSizes(result, truncated))
+	if chain != nil {
+		chain.Base = filepath.Base(result.OutputFile)
+		meta.Chain = chain
+	}
+	sidecar := metadata.SidecarPath(result.OutputFile)
+	if err := metadata.Write(sidecar, meta); err != nil {
+		diag.Warnf("%v", err)
+		sidecar = ""
+	}
+
+	checkSizeEstimate(estimate, result.UncompressedSize)
+	checkGitignore(result.OutputFile, generatedName)
+	recordHistory(conn, allTables, result, rulesVersion)
+
+	printDumpSummary(result)
+	if err := verifyDump(cmd.Context(), result, meta, triggerEffects); err != nil {
+		return err
+	}
+	if storeDir != "" {
+		if err := storeDump(result.OutputFile, meta); err != nil {
+			return err
+		}
+	}
+	if err := writeDoneFile(result, sidecar); err != nil {
+		return err
+	}
+	if jsonResult != nil {
+		if err := writeJSON(dumpJSONView(result, skippedTables)); err != nil {
+			return err
+		}
+	}
 
+	return overBudget
+}
+
+// connectSource connects to the database to dump, through a proxy or with
+// an IAM token when configured
+func connectSource(cmd *cobra.Command) (*database.Connection, *sql.DB, error) {
+	conn := &database.Connection{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		Database: dbName,
+		ReadOnly: resolveReadOnly(cmd),
+	}
+	applyProxy(conn)
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return nil, nil, err
+	}
+
+	db, err := conn.ConnectContext(cmd.Context())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if conn.Proxy != "" {
+		ui.PrintSuccess("Connected to database on " + serverLabel(conn))
+	} else {
+		ui.PrintSuccess("Connected to database")
+	}
+	if conn.ReadOnly {
+		ui.PrintInfo("Inspection session is read-only")
+	}
+	return conn, db, nil
+}
+
+// printDumpSummary prints the warnings of the run and what the dump wrote
+func printDumpSummary(result *database.DumpResult) {
+	reportWarnings()
+	ui.PrintSummary(result.OutputFile, len(result.ExcludedTables), result.Duration, sizeDisplay(result), tags.Format(dumpTags))
+	if len(result.Parts) > 0 {
+		ui.PrintInfo(fmt.Sprintf("Split into %d parts: %s … %s", len(result.Parts),
+			filepath.Base(result.Parts[0].Path), filepath.Base(result.Parts[len(result.Parts)-1].Path)))
+	}
+	if maxTableSize.Bytes > 0 {
+		printTableSizes(result.TableSizes, 10)
+	}
+	printOverBudget(result.OverBudget)
+	if verbose {
+		ui.PrintTimingBreakdown(result.TableTimings, result.StructureDuration, result.DataDuration, 10)
+		printSpillStats()
+	}
+}
+
+// verifyDump runs the checks --verify asks for on the finished dump, and
+// the trigger order check whenever triggers write to dumped tables
+func verifyDump(ctx context.Context, result *database.DumpResult, meta *metadata.Metadata, effects []triggers.Effect) error {
+	if verifyMode != "" {
+		if err := runOrderVerification(result.OutputFile); err != nil {
+			return err
+		}
+		runLintVerification(result.OutputFile)
+	}
+	if err := verifyTriggerOrder(result.OutputFile, effects); err != nil {
+		return err
+	}
+	if verifyMode == "restore" {
+		return runRestoreVerification(ctx, result.OutputFile, meta)
+	}
 	return nil
 }
 
-func runList(cmd *cobra.Command, args []string) error {
-	// Get password from environment if not provided
-	// Check custom dbdump variable first, then fall back to standard MySQL variable
-	if password == "" {
-		password = os.Getenv("DBDUMP_MYSQL_PWD")
-		if password == "" {
-			password = os.Getenv("MYSQL_PWD")
+// validateDumpFlags checks the dump's flags against each other before
+// anything is read or connected to, returning the --max-file-size part size
+func validateDumpFlags(args []string) (int64, error) {
+	if user == "" {
+		return 0, fmt.Errorf("database user is required (use -u or --user)")
+	}
+	if dbName == "" {
+		return 0, fmt.Errorf("database name is required (use -d or --database)")
+	}
+	if err := checkSystemDatabase(); err != nil {
+		return 0, err
+	}
+	if verifyMode != "" && verifyMode != "restore" && verifyMode != "order" {
+		return 0, fmt.Errorf("unsupported --verify mode %q (supported: order, restore)", verifyMode)
+	}
+	maxPartSize, err := parseMaxFileSize()
+	if err != nil {
+		return 0, err
+	}
+	if err := validateSchemaDeltaFlags(); err != nil {
+		return 0, err
+	}
+	if err := validateAppendFlags(args, maxPartSize); err != nil {
+		return 0, err
+	}
+	if dumpTags, err = tags.Parse(tagSpecs); err != nil {
+		return 0, err
+	}
+	dumpTags = applyGitLabels(dumpTags)
+	if err := validateConfigInput(args); err != nil {
+		return 0, err
+	}
+	if err := validateSampleFlags(); err != nil {
+		return 0, err
+	}
+	if err := validateStopReplicaFlags(); err != nil {
+		return 0, err
+	}
+	if err := validateNativeFlags(); err != nil {
+		return 0, err
+	}
+	if err := validateBudgetFlags(); err != nil {
+		return 0, err
+	}
+	if err := validateSyncPolicy(); err != nil {
+		return 0, err
+	}
+	if err := validateProxyFlags(); err != nil {
+		return 0, err
+	}
+	if err := validateResumeFlags(); err != nil {
+		return 0, err
+	}
+	if err := validateAutoThreshold(); err != nil {
+		return 0, err
+	}
+	if err := validateTriggerMode(); err != nil {
+		return 0, err
+	}
+	if err := validateCompression(); err != nil {
+		return 0, err
+	}
+	if err := validatePatterns(); err != nil {
+		return 0, err
+	}
+	if verifyMode == "restore" && maxTableSize.Bytes > 0 {
+		return 0, fmt.Errorf("--verify=restore cannot be combined with --max-table-size (truncated tables never match their checksums)")
+	}
+	if verifyMode == "restore" && convertCharset != "" {
+		return 0, fmt.Errorf("--verify=restore cannot be combined with --convert-charset (checksums change when data is transcoded)")
+	}
+	return maxPartSize, nil
+}
+
+// parseMaxFileSize validates --max-file-size, returning 0 when the dump is not split
+func parseMaxFileSize() (int64, error) {
+	if maxFileSize.Bytes == 0 {
+		return 0, nil
+	}
+	if maxFileSize.Bytes < 1024*1024 {
+		return 0, &dberrors.ErrConfigInvalid{Source: "--max-file-size", Problems: []string{"must be at least 1MiB"}}
+	}
+	return maxFileSize.Bytes, nil
+}
+
+// dumpHeader returns the comment lines written at the top of the dump, which
+// identify the source and the tools for restore and `dbdump inspect`
+func dumpHeader(conn *database.Connection, serverVersion string, zones *metadata.TimeZones, serverVariables map[string]string) string {
+	var header strings.Builder
+	tool := strings.Join(strings.Fields(dumpToolName()), " ")
+	fmt.Fprintf(&header, "-- dbdump %s (%s)\n", Version, tool)
+	if nativeDump {
+		fmt.Fprintf(&header, "-- Note: %s\n", database.NativeLimitation)
+	}
+	fmt.Fprintf(&header, "-- Host: %s    Database: %s\n", serverLabel(conn), conn.Database)
+	fmt.Fprintf(&header, "-- Server version: %s\n", serverVersion)
+	header.WriteString(timeZoneHeader(zones))
+	header.WriteString(variablesHeader(serverVariables))
+	header.WriteString(tagHeader(dumpTags))
+	return header.String()
+}
+
+// buildMetadata assembles the sidecar content for a finished dump
+func buildMetadata(conn *database.Connection, serverVersion string, tablesInfo []database.TableInfo, excludes, skipped []string, samples map[string]int, result *database.DumpResult) *metadata.Metadata {
+	excluded := make(map[string]bool, len(excludes))
+	for _, table := range excludes {
+		excluded[table] = true
+	}
+	isSkipped := make(map[string]bool, len(skipped))
+	for _, table := range skipped {
+		isSkipped[table] = true
+	}
+
+	timings := make(map[string]database.TableTiming, len(result.TableTimings))
+	for _, timing := range result.TableTimings {
+		timings[timing.Table] = timing
+	}
+
+	tables := make([]metadata.Table, 0, len(tablesInfo))
+	for _, info := range tablesInfo {
+		tables = append(tables, metadata.Table{
+			Name:         info.Name,
+			RowCount:     info.RowCount,
+			DataSize:     info.DataSize,
+			IndexSize:    info.IndexSize,
+			DataIncluded: !excluded[info.Name] && !isSkipped[info.Name],
+			Skipped:      isSkipped[info.Name],
+			SampleRows:   samples[info.Name],
+			DumpMillis:   timings[info.Name].Duration.Milliseconds(),
+			DumpBytes:    timings[info.Name].Bytes,
+		})
+	}
+
+	return &metadata.Metadata{
+		ToolVersion: Version,
+		CreatedAt:   time.Now().UTC(),
+		Source: metadata.Source{
+			Host:          conn.Host,
+			Port:          conn.Port,
+			Database:      conn.Database,
+			ServerVersion: serverVersion,
+			Proxy:         conn.Proxy,
+			Instance:      conn.Instance,
+		},
+		OutputFile:     result.OutputFile,
+		FileSize:       result.FileSize,
+		Compression:    outputCompression(),
+		SQLSize:        result.UncompressedSize,
+		DurationMillis: result.Duration.Milliseconds(),
+		PhaseMillis: &metadata.PhaseMillis{
+			Structure: result.StructureDuration.Milliseconds(),
+			Data:      result.DataDuration.Milliseconds(),
+		},
+		Tables:         tables,
+		ExcludedTables: excludes,
+		SkippedTables:  skipped,
+		Parts:          metadataParts(result.Parts),
+		Schema:         result.SchemaFingerprints,
+	}
+}
+
+// metadataParts converts the parts of a split dump for the sidecar
+func metadataParts(parts []dumpfile.Part) []metadata.Part {
+	if len(parts) == 0 {
+		return nil
+	}
+
+	converted := make([]metadata.Part, len(parts))
+	for i, part := range parts {
+		converted[i] = metadata.Part{
+			File:   filepath.Base(part.Path),
+			Size:   part.Size,
+			SHA256: part.SHA256,
 		}
 	}
+	return converted
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	if err := applyProfile(cmd); err != nil {
+		return err
+	}
+	resolvePassword()
+
+	format, err := table.ParseFormat(listFormat)
+	if err != nil {
+		return err
+	}
+	if listJSON {
+		format = table.JSON
+	}
+	if format != table.Text {
+		startJSONOutput()
+	}
 
 	// Validate required flags
 	if user == "" {
@@ -251,88 +911,125 @@ func runList(cmd *cobra.Command, args []string) error {
 		Password: password,
 		Database: dbName,
 	}
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return err
+	}
 
 	// Connect to database
-	db, err := conn.Connect()
+	db, err := conn.ConnectContext(cmd.Context())
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	defer func() {
 		if err := db.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to close database connection: %v\n", err)
+			diag.Warnf("failed to close database connection: %v", err)
 		}
 	}()
 
 	// Get table information
-	inspector := database.NewInspector(db)
+	inspector, err := newInspector(cmd.Context(), db)
+	if err != nil {
+		return err
+	}
 	tablesInfo, err := inspector.GetAllTablesInfo()
 	if err != nil {
 		return fmt.Errorf("failed to get table information: %w", err)
 	}
+	sizesKnown := reportDegraded(inspector, tablesInfo)
 
-	// Print table information
-	fmt.Printf("\nTables in database '%s':\n\n", dbName)
-	fmt.Printf("%-40s %12s %15s\n", "Table Name", "Size", "Rows")
-	fmt.Println(strings.Repeat("-", 70))
-
-	for _, info := range tablesInfo {
-		fmt.Printf("%-40s %12s %15d\n", info.Name, info.SizeDisplay, info.RowCount)
+	var runs []map[string]int64
+	if listTrend && !sizesKnown {
+		ui.PrintInfo("Skipping --trend: current table sizes are unknown")
+	} else if listTrend {
+		runs = loadSizeHistory()
 	}
 
-	fmt.Printf("\nTotal: %d tables\n", len(tablesInfo))
-
-	return nil
-}
+	// The name column gives way on narrow terminals and grows for long names
+	columns := []table.Column{
+		{Title: "Table Name", Key: "table", MinWidth: 40, MaxWidth: 60, Flex: true},
+		{Title: "Size", Align: table.Right, MinWidth: 12},
+		{Title: "Rows", Align: table.Right, MinWidth: 15},
+	}
+	if len(runs) > 0 {
+		columns = append(columns, table.Column{Title: "Trend"})
+	}
+	out := table.New(columns...)
 
-func runConfigList(cmd *cobra.Command, args []string) error {
-	profiles, err := config.LoadProfiles()
-	if err != nil {
-		return fmt.Errorf("failed to load profiles: %w", err)
+	var totalSize, totalRows int64
+	for _, info := range tablesInfo {
+		rowCount := strconv.FormatInt(info.RowCount, 10)
+		if info.SizeUnknown {
+			rowCount = "-"
+		}
+		trend := ""
+		if len(runs) > 0 {
+			trend = formatTrend(runs, info)
+		}
+		out.Row(info.Name, info.SizeDisplay, rowCount, trend)
+		totalSize += info.TotalSize
+		totalRows += info.RowCount
+	}
+	if sizesKnown {
+		out.Totals(fmt.Sprintf("%d tables", len(tablesInfo)), database.FormatBytes(totalSize), strconv.FormatInt(totalRows, 10))
+	} else {
+		out.Totals(fmt.Sprintf("%d tables", len(tablesInfo)), database.SizeUnavailable, "-")
 	}
 
-	if len(profiles.Profiles) == 0 {
-		fmt.Println("No saved profiles found")
-		return nil
+	switch format {
+	case table.Text:
+		fmt.Printf("\nTables in database '%s':\n\n", dbName)
+		return out.Render(os.Stdout, format)
+	case table.JSON:
+		return writeJSON(tableViews(tablesInfo))
 	}
+	return out.Render(jsonResult, format)
+}
 
-	fmt.Println("\nSaved connection profiles:")
-	for _, profile := range profiles.Profiles {
-		fmt.Printf("  %s\n", profile.Name)
-		fmt.Printf("    Host: %s:%d\n", profile.Host, profile.Port)
-		fmt.Printf("    User: %s\n", profile.User)
-		if profile.Database != "" {
-			fmt.Printf("    Database: %s\n", profile.Database)
+// resolvePassword fills in the password from the environment if not provided
+// Checks the custom dbdump variable first, then falls back to the standard MySQL variable
+func resolvePassword() {
+	if password == "" {
+		password = os.Getenv("DBDUMP_MYSQL_PWD")
+		if password == "" {
+			password = os.Getenv("MYSQL_PWD")
 		}
-		fmt.Println()
 	}
-
-	return nil
 }
 
+// ruleOrigins records where each exclude and include rule came from, for
+// the reasons the dry run and plans give
+var ruleOrigins = make(config.Origins)
+
 func buildExcludeConfig() (config.ExcludeConfig, error) {
 	var excludeConfig config.ExcludeConfig
 
-	// Load defaults
-	defaults, err := config.LoadDefaults()
-	if err != nil {
-		return excludeConfig, fmt.Errorf("failed to load defaults: %w", err)
-	}
+	// The default and global rules target application tables and make no
+	// sense for the server's own schemas; only explicit rules apply there
+	if !database.IsSystemDatabase(dbName) {
+		// Load defaults, of the pinned version if any
+		defaults, err := defaultRules()
+		if err != nil {
+			return excludeConfig, err
+		}
 
-	// Start with defaults
-	excludeConfig = defaults.DefaultExcludes
+		// Start with defaults
+		excludeConfig = defaults.DefaultExcludes
+		ruleOrigins.Add("exclude", excludeConfig, fmt.Sprintf("built-in rules v%d", defaults.Version))
 
-	// Load global config if it exists
-	globalConfig, err := config.LoadGlobalConfig()
-	if err != nil {
-		return excludeConfig, fmt.Errorf("failed to load global config: %w", err)
-	}
-	if globalConfig != nil {
-		excludeConfig = config.MergeExcludes(defaults, globalConfig)
+		// Load global config if it exists
+		globalConfig, err := config.LoadGlobalConfig()
+		if err != nil {
+			return excludeConfig, fmt.Errorf("failed to load global config: %w", err)
+		}
+		if globalConfig != nil {
+			excludeConfig = config.MergeExcludes(defaults, globalConfig)
+			ruleOrigins.Merge(globalConfig.Origins)
+		}
 	}
 
 	// Load project config if provided (overrides global)
-	if configFile != "" {
-		projectConfig, err := config.LoadConfig(configFile)
+	if len(configFiles) > 0 {
+		projectConfig, err := loadProjectConfig()
 		if err != nil {
 			return excludeConfig, fmt.Errorf("failed to load config file: %w", err)
 		}
@@ -341,15 +1038,278 @@ func buildExcludeConfig() (config.ExcludeConfig, error) {
 			DefaultExcludes: excludeConfig,
 		}
 		excludeConfig = config.MergeExcludes(tempDefaults, projectConfig)
+		ruleOrigins.Merge(projectConfig.Origins)
+	}
+
+	// Add inline rules from --exclude-json
+	inlineRules, err := parseExcludeJSON()
+	if err != nil {
+		return excludeConfig, err
 	}
+	excludeConfig.Exact = append(excludeConfig.Exact, inlineRules.Exact...)
+	excludeConfig.Patterns = append(excludeConfig.Patterns, inlineRules.Patterns...)
+	ruleOrigins.Add("exclude", inlineRules, "--exclude-json")
 
 	// Add CLI-specified excludes
 	if len(excludeTables) > 0 {
 		excludeConfig.Exact = append(excludeConfig.Exact, excludeTables...)
+		ruleOrigins.Add("exclude", config.ExcludeConfig{Exact: excludeTables}, "--exclude")
 	}
 	if len(excludePattern) > 0 {
 		excludeConfig.Patterns = append(excludeConfig.Patterns, excludePattern...)
+		ruleOrigins.Add("exclude", config.ExcludeConfig{Patterns: excludePattern}, "--exclude-pattern")
+	}
+	if excludeAllData {
+		excludeConfig.Patterns = append(excludeConfig.Patterns, "*")
+		ruleOrigins.Add("exclude", config.ExcludeConfig{Patterns: []string{"*"}}, "--exclude-all-data")
+	}
+
+	excludeConfig = normalizePatterns("exclude", excludeConfig)
+	if err := patterns.Validate(excludeConfig, "exclude patterns"); err != nil {
+		return excludeConfig, err
 	}
 
 	return excludeConfig, nil
 }
+
+// buildOnlyConfig builds the positive selection rules from the global config,
+// project config and CLI flags. An empty result means only mode is off.
+func buildOnlyConfig() (config.ExcludeConfig, error) {
+	var onlyConfig config.ExcludeConfig
+
+	globalConfig, err := config.LoadGlobalConfig()
+	if err != nil {
+		return onlyConfig, fmt.Errorf("failed to load global config: %w", err)
+	}
+	if globalConfig != nil {
+		onlyConfig.Exact = append(onlyConfig.Exact, globalConfig.Only.Exact...)
+		onlyConfig.Patterns = append(onlyConfig.Patterns, globalConfig.Only.Patterns...)
+	}
+
+	if len(configFiles) > 0 {
+		projectConfig, err := loadProjectConfig()
+		if err != nil {
+			return onlyConfig, fmt.Errorf("failed to load config file: %w", err)
+		}
+		onlyConfig.Exact = append(onlyConfig.Exact, projectConfig.Only.Exact...)
+		onlyConfig.Patterns = append(onlyConfig.Patterns, projectConfig.Only.Patterns...)
+	}
+
+	onlyConfig.Exact = append(onlyConfig.Exact, onlyTables...)
+	onlyConfig.Patterns = append(onlyConfig.Patterns, onlyPattern...)
+
+	onlyConfig = normalizePatterns("only", onlyConfig)
+	if err := patterns.Validate(onlyConfig, "only patterns"); err != nil {
+		return onlyConfig, err
+	}
+
+	return onlyConfig, nil
+}
+
+// buildIncludeConfig builds the include rules from the global config,
+// project config and CLI flags. An empty result means include mode is off.
+func buildIncludeConfig() (config.ExcludeConfig, error) {
+	var includeConfig config.ExcludeConfig
+
+	globalConfig, err := config.LoadGlobalConfig()
+	if err != nil {
+		return includeConfig, fmt.Errorf("failed to load global config: %w", err)
+	}
+	if globalConfig != nil {
+		includeConfig.Exact = append(includeConfig.Exact, globalConfig.Include.Exact...)
+		includeConfig.Patterns = append(includeConfig.Patterns, globalConfig.Include.Patterns...)
+		ruleOrigins.Merge(globalConfig.Origins)
+	}
+
+	if len(configFiles) > 0 {
+		projectConfig, err := loadProjectConfig()
+		if err != nil {
+			return includeConfig, fmt.Errorf("failed to load config file: %w", err)
+		}
+		includeConfig.Exact = append(includeConfig.Exact, projectConfig.Include.Exact...)
+		includeConfig.Patterns = append(includeConfig.Patterns, projectConfig.Include.Patterns...)
+		ruleOrigins.Merge(projectConfig.Origins)
+	}
+
+	includeConfig.Exact = append(includeConfig.Exact, includeTables...)
+	includeConfig.Patterns = append(includeConfig.Patterns, includePattern...)
+	ruleOrigins.Add("include", config.ExcludeConfig{Exact: includeTables}, "--include")
+	ruleOrigins.Add("include", config.ExcludeConfig{Patterns: includePattern}, "--include-pattern")
+
+	includeConfig = normalizePatterns("include", includeConfig)
+	if err := patterns.Validate(includeConfig, "include patterns"); err != nil {
+		return includeConfig, err
+	}
+	if excludeAllData && !includeConfig.IsEmpty() {
+		return includeConfig, &dberrors.ErrConfigInvalid{
+			Source:   "--exclude-all-data",
+			Problems: []string{"cannot be combined with include rules, which it would override"},
+		}
+	}
+
+	return includeConfig, nil
+}
+
+// normalizedPatterns are the patterns normalizePatterns has warned about,
+// as the rules are built more than once per run
+var normalizedPatterns = make(map[string]bool)
+
+// normalizePatterns fixes the mistakes patterns.Normalize knows about in a
+// section's patterns, warning about each along with where the pattern came
+// from; the fixed patterns keep that origin
+func normalizePatterns(section string, rules config.ExcludeConfig) config.ExcludeConfig {
+	normalized := rules
+	normalized.Patterns = make([]string, len(rules.Patterns))
+	for i, pattern := range rules.Patterns {
+		fixed, notes := patterns.Normalize(pattern)
+		origin := ruleOrigins.Of(section, pattern)
+		if normalizedPatterns[section+":"+pattern] {
+			notes = nil
+		}
+		normalizedPatterns[section+":"+pattern] = true
+		for _, note := range notes {
+			if origin != "" {
+				diag.Warnf("%s pattern %q (%s): %s", section, pattern, origin, note)
+			} else {
+				diag.Warnf("%s pattern %q: %s", section, pattern, note)
+			}
+		}
+		if fixed != pattern && origin != "" {
+			ruleOrigins.Add(section, config.ExcludeConfig{Patterns: []string{fixed}}, origin)
+		}
+		normalized.Patterns[i] = fixed
+	}
+	return normalized
+}
+
+// validatePatterns checks the exclude, include and only patterns of the
+// configs and flags before connecting, so a typo fails (or is warned about)
+// up front rather than once the tables are listed
+func validatePatterns() error {
+	if _, err := buildDataMatcher(); err != nil {
+		return err
+	}
+	_, err := buildOnlyConfig()
+	return err
+}
+
+// buildDataMatcher returns the matcher of data-excluded tables: the
+// exclusion rules, inverted by the include rules when there are any
+func buildDataMatcher() (*patterns.Matcher, error) {
+	excludeConfig, err := buildExcludeConfig()
+	if err != nil {
+		return nil, err
+	}
+	includeConfig, err := buildIncludeConfig()
+	if err != nil {
+		return nil, err
+	}
+	levels, err := ruleConfidence(excludeConfig)
+	if err != nil {
+		return nil, err
+	}
+	return patterns.NewMatcher(excludeConfig).WithConfidence(levels).WithIncludes(includeConfig), nil
+}
+
+// printDryRunSteps prints what a dry run would do besides writing the
+// planned tables
+func printDryRunSteps(chain *metadata.Chain, budgetedOrder []string) {
+	if verifyMode != "" {
+		fmt.Printf("Would verify the dump (%s)\n", verifyMode)
+	}
+	if stopReplicaAt != "" {
+		fmt.Printf("Would stop the replica right after %s and resume it after the dump\n", stopReplicaAt)
+	}
+	if nativeDump {
+		fmt.Printf("Would dump natively, without mysqldump: %s\n", database.NativeLimitation)
+	}
+	if chain != nil {
+		fmt.Printf("Would start a chain for --append-since-last: %s\n", describeChain(chain))
+	}
+	if timeBudget > 0 {
+		fmt.Printf("Would dump data table by table within %s, in this order: %s\n", timeBudget, describeBudgetOrder(budgetedOrder))
+	}
+}
+
+// printDryRun prints the dump plan as a table of what is dumped of each
+// table, with the rule that excluded or skipped it
+func printDryRun(dp *planner.DumpPlan) {
+	out := table.New(
+		table.Column{Title: "Table", MaxWidth: 60, Flex: true},
+		table.Column{Title: "Contents"},
+		table.Column{Title: "Notes"},
+	)
+	counts := make(map[string]int)
+	masked := 0
+	add := func(t planner.Table, contents string) {
+		counts[contents]++
+		var notes []string
+		if t.Rule != "" {
+			notes = append(notes, t.Rule)
+		}
+		if t.Structure != "" {
+			notes = append(notes, "structure: "+string(t.Structure))
+		}
+		if len(t.Masked) > 0 {
+			notes = append(notes, "masked: "+strings.Join(t.Masked, ", "))
+		}
+		if len(t.Nulled) > 0 {
+			var nulled []string
+			for _, column := range slices.Sorted(maps.Keys(t.Nulled)) {
+				nulled = append(nulled, column+" as "+t.Nulled[column])
+			}
+			notes = append(notes, "null: "+strings.Join(nulled, ", "))
+		}
+		if len(t.Masked)+len(t.Nulled) > 0 {
+			masked++
+		}
+		if t.CopyOf != "" {
+			notes = append(notes, "copy of "+t.CopyOf+"."+t.Name)
+		}
+		out.Row(t.Name, contents, strings.Join(notes, "; "))
+	}
+
+	// Fully dumped tables first, then data-excluded and skipped ones
+	for _, disposition := range []string{plan.DispositionFull, plan.DispositionStructureOnly, plan.DispositionSkipped} {
+		for _, t := range dp.Tables {
+			switch {
+			case t.Disposition != disposition:
+			case disposition == plan.DispositionFull:
+				add(t, "full")
+			case t.SampleRows > 0:
+				add(t, fmt.Sprintf("sampled (%d rows)", t.SampleRows))
+			case disposition == plan.DispositionStructureOnly:
+				add(t, "structure only")
+			default:
+				add(t, "skipped")
+			}
+		}
+	}
+
+	fmt.Print("\nDry run - dump plan:\n\n")
+	_ = out.Render(os.Stdout, table.Text)
+	fmt.Printf("\n%d dumped fully, %d structure only (data excluded), %d sampled, %d skipped entirely\n",
+		counts["full"], counts["structure only"], dp.Count(plan.DispositionStructureOnly)-counts["structure only"], counts["skipped"])
+	if masked > 0 {
+		fmt.Printf("%d tables read through the masking path instead of mysqldump\n", masked)
+	}
+	if dp.IncludeMode {
+		fmt.Println("Include mode: only tables matching an include rule keep their data; an exclude rule matching the same table wins")
+	}
+
+	if dp.SizesKnown {
+		fmt.Printf("\nEstimated dump size: %s\n", database.FormatBytes(dp.EstimatedSize))
+	} else {
+		fmt.Printf("\nEstimated dump size: %s (table sizes unknown)\n", database.SizeUnavailable)
+	}
+	if dp.Parts {
+		fmt.Printf("\nWould create dump parts of at most %s: %s\n", database.FormatBytes(dp.MaxFileSize), dp.OutputFile+", …")
+	} else {
+		fmt.Printf("\nWould create dump file: %s\n", dp.OutputFile)
+	}
+	if verbose {
+		for _, command := range dp.Commands {
+			fmt.Printf("Would run (%s): mysqldump %s\n", command.Phase, strings.Join(command.Args, " "))
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/metadata"
+)
+
+// loadMaskRules reads mask from the global config, then the project config,
+// whose entries replace those of the same column. The result maps tables to
+// their masked columns and the strategy or literal of each.
+func loadMaskRules() (map[string]map[string]string, error) {
+	rules := make(map[string]map[string]string)
+	var problems []string
+
+	err := eachConfig(func(source string, cfg *config.Config) {
+		for _, key := range slices.Sorted(maps.Keys(cfg.Mask)) {
+			table, column, ok := strings.Cut(key, ".")
+			if !ok || table == "" || column == "" {
+				problems = append(problems, fmt.Sprintf("%s: mask key %q: expected table.column", source, key))
+				continue
+			}
+			if rules[table] == nil {
+				rules[table] = make(map[string]string)
+			}
+			rules[table][column] = cfg.Mask[key]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "mask", Problems: problems}
+	}
+	return rules, nil
+}
+
+// loadNullColumns reads null_columns from the global config, then the
+// project config, whose entries replace those of the same column
+func loadNullColumns() (map[string]config.NullColumns, error) {
+	nulls := make(map[string]config.NullColumns)
+	err := eachConfig(func(source string, cfg *config.Config) {
+		for table, columns := range cfg.NullColumns {
+			if nulls[table] == nil {
+				nulls[table] = make(config.NullColumns)
+			}
+			maps.Copy(nulls[table], columns)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nulls, nil
+}
+
+// eachConfig calls add with the global config, then the project config,
+// when there are any
+func eachConfig(add func(source string, cfg *config.Config)) error {
+	globalConfig, err := config.LoadGlobalConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load global config: %w", err)
+	}
+	if globalConfig != nil {
+		add("global config", globalConfig)
+	}
+	if len(configFiles) > 0 {
+		projectConfig, err := loadProjectConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config file: %w", err)
+		}
+		add(configSource(), projectConfig)
+	}
+	return nil
+}
+
+// maskedTables checks the mask rules and null_columns against the database
+// before the dump starts and returns the tables whose data goes through the
+// masking path: those dumped fully, and data-excluded tables that are
+// sampled. Unknown tables or columns, masks that don't fit their column and
+// NOT NULL columns without a placeholder are an error.
+func maskedTables(ctx context.Context, inspector *database.Inspector, all []database.TableInfo, excludes, skipped []string, samples map[string]int) ([]database.MaskedTable, error) {
+	rules, err := loadMaskRules()
+	if err != nil {
+		return nil, err
+	}
+	nulls, err := loadNullColumns()
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 && len(nulls) == 0 {
+		return nil, nil
+	}
+
+	exists := make(map[string]bool, len(all))
+	for _, info := range all {
+		exists[info.Name] = true
+	}
+
+	var masked []database.MaskedTable
+	var problems []string
+	tables := slices.Concat(slices.Collect(maps.Keys(rules)), slices.Collect(maps.Keys(nulls)))
+	slices.Sort(tables)
+	for _, table := range slices.Compact(tables) {
+		_, sampled := samples[table]
+		switch {
+		case !exists[table]:
+			problems = append(problems, fmt.Sprintf("%s: no such table", table))
+			continue
+		case slices.Contains(skipped, table), slices.Contains(excludes, table) && !sampled:
+			// No data is dumped, so there is nothing to mask
+			continue
+		}
+
+		columns, err := inspector.GetColumns(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		maskedTable, tableProblems := database.NewMaskedTable(table, columns, rules[table], nulls[table])
+		problems = append(problems, tableProblems...)
+		masked = append(masked, maskedTable)
+	}
+
+	if len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "mask", Problems: problems}
+	}
+	return masked, nil
+}
+
+// maskSamples moves the samples of masked tables into the masked tables,
+// so their sampled rows are masked too, and returns the remaining samples
+func maskSamples(masked []database.MaskedTable, samples []database.TableSample) []database.TableSample {
+	var rest []database.TableSample
+	for _, sample := range samples {
+		index := slices.IndexFunc(masked, func(table database.MaskedTable) bool {
+			return table.Table == sample.Table
+		})
+		if index < 0 {
+			rest = append(rest, sample)
+			continue
+		}
+		masked[index].Sample = &sample
+	}
+	return rest
+}
+
+// maskedColumns maps each masked table to its masked columns
+func maskedColumns(masked []database.MaskedTable) map[string][]string {
+	columns := make(map[string][]string, len(masked))
+	for _, table := range masked {
+		columns[table.Table] = table.MaskedColumnNames()
+	}
+	return columns
+}
+
+// nulledColumns maps each table with null_columns to its columns and their
+// placeholders
+func nulledColumns(masked []database.MaskedTable) map[string]map[string]string {
+	columns := make(map[string]map[string]string)
+	for _, table := range masked {
+		if nulled := table.NulledColumns(); nulled != nil {
+			columns[table.Table] = nulled
+		}
+	}
+	return columns
+}
+
+// maskedNames returns the names of the masked tables
+func maskedNames(masked []database.MaskedTable) []string {
+	names := make([]string, len(masked))
+	for i, table := range masked {
+		names[i] = table.Table
+	}
+	return names
+}
+
+// recordMasks notes the masked columns and null_columns of each table in
+// the sidecar
+func recordMasks(meta *metadata.Metadata, masked []database.MaskedTable) {
+	columns, nulled := maskedColumns(masked), nulledColumns(masked)
+	for i := range meta.Tables {
+		meta.Tables[i].MaskedColumns = columns[meta.Tables[i].Name]
+		meta.Tables[i].NullColumns = nulled[meta.Tables[i].Name]
+	}
+}
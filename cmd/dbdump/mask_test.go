@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+// TestMaskedTablesNullColumns checks null_columns from the global and the
+// project config before a dump: placeholders are picked per column, and
+// columns that can't take one stop the dump before it starts
+func TestMaskedTablesNullColumns(t *testing.T) {
+	saved := configFiles
+	defer func() { configFiles = saved }()
+
+	tables := []database.TableInfo{{Name: "users"}, {Name: "orders"}}
+	columns := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"column_name", "column_type", "data_type", "nullable", "max_length", "extra", "expression", "has_default"}).
+			AddRow("id", "int", "int", false, 0, "", "", false).
+			AddRow("phone", "varchar(20)", "varchar", true, 20, "", "", false).
+			AddRow("status", "varchar(10)", "varchar", false, 10, "", "", true).
+			AddRow("email", "varchar(50)", "varchar", false, 50, "", "", false)
+	}
+
+	tests := []struct {
+		name     string
+		global   string
+		project  string
+		excludes []string
+		query    bool // whether the columns of users are read
+		want     map[string]string
+		wantErr  string
+	}{
+		{
+			name:    "global and project merged",
+			global:  "null_columns:\n  users: [phone]\n",
+			project: "null_columns:\n  users:\n    status: ~\n    email: nobody@example.invalid\n",
+			query:   true,
+			want:    map[string]string{"phone": "NULL", "status": "DEFAULT", "email": "'nobody@example.invalid'"},
+		},
+		{
+			name:    "project literal replaces the global placeholder",
+			global:  "null_columns:\n  users: [email]\n",
+			project: "null_columns:\n  users:\n    email: redacted\n",
+			query:   true,
+			want:    map[string]string{"email": "'redacted'"},
+		},
+		{
+			name:    "NOT NULL column without a default",
+			project: "null_columns:\n  users: [email]\n",
+			query:   true,
+			wantErr: "users.email is NOT NULL without a default",
+		},
+		{
+			name:    "unknown table",
+			project: "null_columns:\n  customers: [email]\n",
+			wantErr: "customers: no such table",
+		},
+		{
+			name:     "data excluded",
+			project:  "null_columns:\n  users: [email]\n",
+			excludes: []string{"users"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home, dir := t.TempDir(), t.TempDir()
+			t.Setenv("HOME", home)
+			if tt.global != "" {
+				if err := os.WriteFile(filepath.Join(home, ".dbdump.yaml"), []byte(tt.global), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			project := filepath.Join(dir, ".dbdump.yaml")
+			if err := os.WriteFile(project, []byte(tt.project), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			configFiles = []string{project}
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = db.Close() }()
+			if tt.query {
+				mock.ExpectQuery(`information_schema\.columns`).WithArgs("users").WillReturnRows(columns())
+			}
+
+			masked, err := maskedTables(context.Background(), database.NewInspector(db), tables, tt.excludes, nil, nil)
+			if tt.wantErr != "" {
+				var invalid *dberrors.ErrConfigInvalid
+				if !errors.As(err, &invalid) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("maskedTables() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+			if tt.want == nil {
+				if len(masked) != 0 {
+					t.Errorf("maskedTables() = %v, want none", maskedNames(masked))
+				}
+				return
+			}
+			if got := nulledColumns(masked)["users"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("placeholders = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/spill"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/units"
+)
+
+// maxMemory is the ceiling on statement text held in memory
+var maxMemory units.Size
+
+func init() {
+	_ = maxMemory.Set("256MiB")
+	rootCmd.PersistentFlags().Var(&maxMemory, "max-memory", "Memory for statements held while they are transformed or replayed (e.g. 512MiB; 0 for no limit); larger ones spill to the temporary directory")
+}
+
+// configureMemory applies --max-memory to the shared spill pool
+func configureMemory() {
+	spill.Default.SetLimit(maxMemory.Bytes)
+}
+
+// printSpillStats says how many statements outgrew --max-memory, if any
+func printSpillStats() {
+	spills, bytes := spill.Default.Stats()
+	if spills == 0 {
+		return
+	}
+	ui.PrintInfo(fmt.Sprintf("%d statement(s) over --max-memory spilled to disk (%s)", spills, database.FormatBytes(bytes)))
+}
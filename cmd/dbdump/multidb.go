@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/ui/table"
+	"github.com/spf13/cobra"
+)
+
+// Statuses of a database in a run report
+const (
+	statusOK          = "ok"
+	statusFailed      = "failed"
+	statusInterrupted = "interrupted"
+	statusSkipped     = "skipped"
+)
+
+var (
+	allDatabases bool
+	reportFile   string
+	failFast     bool
+
+	// lastDump is the result of the last database dumped, for the run report
+	lastDump *database.DumpResult
+)
+
+func init() {
+	dumpCmd.Flags().BoolVar(&allDatabases, "all-databases", false, "Dump every database on the server, each to its own file (needs --auto; system schemas only with --system-database)")
+	dumpCmd.Flags().StringVar(&reportFile, "report-file", "", "With several databases, also write the run report as JSON to this file")
+	dumpCmd.Flags().BoolVar(&failFast, "fail-fast", false, "With several databases, stop at the first one that fails instead of continuing")
+}
+
+// runReport is the outcome of a run over several databases or jobs
+type runReport struct {
+	Host       string           `json:"host,omitempty"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	Databases  []databaseReport `json:"databases"`
+}
+
+// databaseReport is the outcome for one database, or one job of dbdump run
+type databaseReport struct {
+	Job        string   `json:"job,omitempty"`
+	Database   string   `json:"database"`
+	Status     string   `json:"status"`
+	DurationMs int64    `json:"duration_ms"`
+	Output     string   `json:"output,omitempty"`
+	Size       int64    `json:"size"`
+	Warnings   []string `json:"warnings,omitempty"`
+	Error      string   `json:"error,omitempty"`
+
+	// Deduplicated are the tables dumped as copies of an identical table
+	// in a database before it (--dedupe-identical), as table=database
+	Deduplicated []string `json:"deduplicated,omitempty"`
+	SavedBytes   int64    `json:"saved_bytes,omitempty"`
+}
+
+// label names the entry in messages: the job, or else the database
+func (entry databaseReport) label() string {
+	if entry.Job != "" {
+		return entry.Job
+	}
+	return entry.Database
+}
+
+// multiDatabase reports whether the dump covers several databases:
+// --all-databases, or a -d pattern with * or ?
+func multiDatabase() bool {
+	return allDatabases || strings.ContainsAny(dbName, "*?")
+}
+
+// validateMultiFlags rejects the run report flags on a single-database dump
+func validateMultiFlags(cmd *cobra.Command) error {
+	for _, name := range []string{"report-file", "fail-fast", "dedupe-identical"} {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s applies to --all-databases or a -d pattern (e.g. -d 'tenant_*')", name)
+		}
+	}
+	return nil
+}
+
+// runMultiDump dumps each matching database in turn with --auto, isolating
+// failures unless --fail-fast is set, and reports the outcome of each. The
+// report is printed and written even when the run is interrupted, with the
+// databases not yet started listed as skipped.
+func runMultiDump(cmd *cobra.Command, args []string) (err error) {
+	switch {
+	case allDatabases && dbName != "":
+		return fmt.Errorf("--all-databases cannot be combined with -d/--database")
+	case !autoMode && !dryRun:
+		return fmt.Errorf("dumping several databases needs --auto (the table selector works on one database)")
+	case outputFile != "":
+		return fmt.Errorf("-o/--output cannot be used with several databases; each is written to {database}_{timestamp}.sql")
+	case resumeDump:
+		return fmt.Errorf("--resume continues the dump of one database; run it with -d for each interrupted database")
+	case planFile != "":
+		return fmt.Errorf("--plan describes a single database and cannot be used with several")
+	case len(args) > 0:
+		return fmt.Errorf("table arguments cannot be used with several databases")
+	case stopReplicaAt != "":
+		return fmt.Errorf("--stop-replica-at-gtid pins the dump of one database and cannot be used with several")
+	case storeDir != "":
+		return fmt.Errorf("--store keeps the dump of one database and cannot be used with several")
+	case doneFile != "":
+		return fmt.Errorf("--done-file signals the dump of one database and cannot be used with several")
+	}
+
+	names, err := matchingDatabases(cmd)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return &dberrors.ErrConfigInvalid{Source: "-d", Problems: []string{fmt.Sprintf("no databases match %q", dbName)}}
+	}
+
+	report := &runReport{Host: host, StartedAt: time.Now().UTC()}
+	for _, name := range names {
+		report.Databases = append(report.Databases, databaseReport{Database: name, Status: statusSkipped})
+	}
+
+	pattern := dbName
+	defer func() {
+		dbName = pattern
+		dedupeRegistry = nil
+	}()
+
+	return reportRun(cmd, report, func() error {
+		return dumpEach(cmd, report, func(entry *databaseReport) error {
+			dbName, outputFile = entry.Database, ""
+			return nil
+		})
+	})
+}
+
+// reportRun runs dumpAll, which fills in the report's entries, then prints
+// the report (or writes it as JSON with --json) and turns it into the run's
+// result: nil only when every entry was dumped
+func reportRun(cmd *cobra.Command, report *runReport, dumpAll func() error) (err error) {
+	// With --json the run report is the result; each entry's own output
+	// goes to stderr with the other messages
+	result := jsonResult
+	jsonResult = nil
+
+	defer func() {
+		report.FinishedAt = time.Now().UTC()
+		jsonResult = result
+		if result != nil {
+			if jsonErr := writeJSON(report); jsonErr != nil && err == nil {
+				err = jsonErr
+			}
+		} else {
+			printRunReport(report)
+		}
+		if reportFile != "" {
+			if writeErr := writeRunReport(reportFile, report); writeErr != nil {
+				diag.Warnf("%v", writeErr)
+			}
+		}
+	}()
+
+	// Each entry gets its own warning summary; afterwards all of them are
+	// recorded again so --warnings-as-errors sees the whole run
+	defer func() {
+		for _, entry := range report.Databases {
+			for _, warning := range entry.Warnings {
+				diag.Record(entry.label() + ": " + warning)
+			}
+		}
+		warningsReported = true
+	}()
+
+	stopErr := dumpAll()
+	if stopErr == nil && cmd.Context().Err() != nil {
+		stopErr = fmt.Errorf("%w: %w", dberrors.ErrDumpInterrupted, cmd.Context().Err())
+	}
+
+	failed, skipped := 0, 0
+	for _, entry := range report.Databases {
+		switch entry.Status {
+		case statusFailed, statusInterrupted:
+			failed++
+		case statusSkipped:
+			skipped++
+		}
+	}
+	switch {
+	case stopErr != nil && interrupted(stopErr):
+		return stopErr
+	case failed > 0 || skipped > 0:
+		return &dberrors.ErrPartialFailure{Failed: failed, Skipped: skipped, Total: len(report.Databases)}
+	}
+	return nil
+}
+
+// dumpEach dumps the report's entries in turn, isolating failures unless
+// --fail-fast is set; prepare sets up the flags for an entry. It returns the
+// error that stopped the run early, if any.
+func dumpEach(cmd *cobra.Command, report *runReport, prepare func(entry *databaseReport) error) error {
+	var stopErr error
+	for i := range report.Databases {
+		entry := &report.Databases[i]
+		if cmd.Context().Err() != nil || stopErr != nil {
+			break
+		}
+
+		fmt.Println()
+		ui.PrintInfo(fmt.Sprintf("[%d/%d] Dumping %s", i+1, len(report.Databases), entry.label()))
+		lastDump = nil
+		diag.Default.Reset()
+		warningsReported = false
+		started := time.Now()
+
+		dumpErr := prepare(entry)
+		if dumpErr == nil {
+			dumpErr = dumpDatabase(cmd, nil)
+		}
+
+		entry.DurationMs = time.Since(started).Milliseconds()
+		for _, warning := range diag.Warnings() {
+			entry.Warnings = append(entry.Warnings, warning.Message)
+		}
+		if lastDump != nil {
+			entry.Output = lastDump.OutputFile
+			entry.Size = lastDump.FileSize
+		}
+		if dedupeRegistry != nil {
+			for _, c := range dedupeRegistry.Copies(entry.Database) {
+				entry.Deduplicated = append(entry.Deduplicated, c.Table+"="+c.From)
+				entry.SavedBytes += c.Saved
+			}
+		}
+
+		// A dump that left tables out to keep within --time-budget is
+		// valid; the tables it lacks are listed with its warnings
+		var budgetErr *dberrors.ErrOverBudget
+		switch {
+		case dumpErr == nil:
+			entry.Status = statusOK
+		case errors.As(dumpErr, &budgetErr):
+			entry.Status = statusOK
+			entry.Warnings = append(entry.Warnings, fmt.Sprintf("%s: %s", budgetErr.Error(), strings.Join(budgetErr.Tables, ", ")))
+		case interrupted(dumpErr):
+			entry.Status = statusInterrupted
+			entry.Error = dumpErr.Error()
+			stopErr = dumpErr
+		default:
+			entry.Status = statusFailed
+			entry.Error = dumpErr.Error()
+			if failFast {
+				stopErr = dumpErr
+			}
+		}
+	}
+	return stopErr
+}
+
+// matchingDatabases lists the databases on the server that the run covers,
+// leaving out system schemas unless --system-database is set, and sets up
+// --dedupe-identical over them
+func matchingDatabases(cmd *cobra.Command) ([]string, error) {
+	resolvePassword()
+	if user == "" {
+		return nil, fmt.Errorf("database user is required (use -u or --user)")
+	}
+
+	conn := &database.Connection{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+	}
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return nil, err
+	}
+
+	db, err := conn.ConnectContext(cmd.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	inspector, err := newInspector(cmd.Context(), db)
+	if err != nil {
+		return nil, err
+	}
+	databases, err := inspector.ListDatabases()
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := patterns.NewMatcher(config.ExcludeConfig{Patterns: []string{dbName}})
+	var names []string
+	for _, info := range databases {
+		if !allDatabases && !matcher.Matches(info.Name) {
+			continue
+		}
+		if info.System && !systemDatabase {
+			continue
+		}
+		names = append(names, info.Name)
+	}
+	if err := startDedupe(inspector, names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// printRunReport prints one line per database, or per job of dbdump run
+// (whose report has no single host)
+func printRunReport(report *runReport) {
+	title, kind, heading := "Database", "databases", "Run report for "+report.Host
+	if report.Host == "" {
+		title, kind, heading = "Job", "jobs", "Run report"
+	}
+	out := table.New(
+		table.Column{Title: title, MinWidth: 24, MaxWidth: 64},
+		table.Column{Title: "Status", MinWidth: 12},
+		table.Column{Title: "Duration", Align: table.Right, MinWidth: 10},
+		table.Column{Title: "Size", Align: table.Right, MinWidth: 12},
+		table.Column{Title: "Warnings", Align: table.Right},
+		table.Column{Title: "Error", MinWidth: 10, Flex: true},
+	)
+	counts := make(map[string]int)
+	for _, entry := range report.Databases {
+		counts[entry.Status]++
+		size, duration := "", ""
+		if entry.Status != statusSkipped {
+			duration = ui.FormatDuration(time.Duration(entry.DurationMs) * time.Millisecond)
+		}
+		if entry.Output != "" {
+			size = database.FormatBytes(entry.Size)
+		}
+		name := entry.label()
+		if entry.Job != "" && entry.Database != "" {
+			name += " (" + entry.Database + ")"
+		}
+		out.Row(name, entry.Status, duration, size, strconv.Itoa(len(entry.Warnings)), entry.Error)
+	}
+
+	fmt.Printf("\n%s:\n\n", heading)
+	_ = out.Render(os.Stdout, table.Text)
+	fmt.Printf("\nTotal: %d %s, %d ok, %d failed, %d interrupted, %d skipped\n",
+		len(report.Databases), kind, counts[statusOK], counts[statusFailed], counts[statusInterrupted], counts[statusSkipped])
+	if summary := dedupeSummary(report); summary != "" {
+		fmt.Println(summary)
+	}
+}
+
+// writeRunReport writes the report as JSON
+func writeRunReport(path string, report *runReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write run report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// nameCase is how the connected server compares table names, read by
+// newInspector. Servers that don't say are taken as case-sensitive, the
+// default on Linux.
+var nameCase = sqlident.CaseSensitive
+
+// detectNameCase reads the server's lower_case_table_names into nameCase
+func detectNameCase(inspector *database.Inspector) {
+	if detected, err := inspector.GetNameCase(); err == nil {
+		nameCase = detected
+	}
+}
+
+// caseTwinTables returns the tables whose names differ from a data-excluded
+// or skipped table's only by case. mysqldump matches --ignore-table
+// case-insensitively, so it would leave them out too: twins of tables
+// without data are read through the masking path (with nothing to mask),
+// and twins of skipped tables, which mysqldump would drop entirely, are
+// reported.
+func caseTwinTables(ctx context.Context, inspector *database.Inspector, all []database.TableInfo, excludes, skipped []string, masked []database.MaskedTable) ([]database.MaskedTable, error) {
+	if nativeDump || nameCase.Folds() {
+		return nil, nil
+	}
+	names := make([]string, len(all))
+	for i, info := range all {
+		names[i] = info.Name
+	}
+	twins := sqlident.Twins(names)
+	if len(twins) == 0 {
+		return nil, nil
+	}
+
+	handled := slices.Concat(excludes, skipped, maskedNames(masked))
+	var added []database.MaskedTable
+	for _, name := range names {
+		if slices.Contains(handled, name) {
+			continue
+		}
+		var ignoredBy, skippedBy []string
+		for _, twin := range twins[name] {
+			switch {
+			case slices.Contains(skipped, twin):
+				skippedBy = append(skippedBy, twin)
+			case slices.Contains(excludes, twin) || slices.ContainsFunc(masked, func(t database.MaskedTable) bool { return t.Table == twin }):
+				ignoredBy = append(ignoredBy, twin)
+			}
+		}
+		if len(skippedBy) > 0 {
+			diag.Warnf("%s differs from the skipped %s only by case; mysqldump leaves out both, so its structure and triggers won't be in the dump",
+				name, strings.Join(skippedBy, ", "))
+			continue
+		}
+		if len(ignoredBy) == 0 {
+			continue
+		}
+
+		columns, err := inspector.GetColumns(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		table, _ := database.NewMaskedTable(name, columns, nil, nil)
+		added = append(added, table)
+		ui.PrintInfo(fmt.Sprintf("Dumping the data of %s separately: its name differs from %s only by case, which mysqldump's --ignore-table doesn't tell apart",
+			name, strings.Join(ignoredBy, ", ")))
+	}
+	return added, nil
+}
+
+// checkTargetNameCase refuses to restore a dump with tables whose names
+// differ only by case into a server that folds table names, where they
+// would overwrite each other
+func checkTargetNameCase(ctx context.Context, inputFile string, target *database.Connection) error {
+	meta, err := metadata.LoadForDump(inputFile)
+	if err != nil || meta == nil {
+		return nil
+	}
+	names := make([]string, len(meta.Tables))
+	for i, t := range meta.Tables {
+		names[i] = t.Name
+	}
+	collisions := sqlident.Collisions(names)
+	if len(collisions) == 0 {
+		return nil
+	}
+
+	// The target database may not exist yet
+	server := *target
+	server.Database = ""
+	db, err := server.ConnectContext(ctx)
+	if err != nil {
+		diag.Warnf("could not check how the target compares table names: %v", err)
+		return nil
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	targetCase, err := database.NewInspector(db).WithContext(ctx).GetNameCase()
+	if err != nil {
+		diag.Warnf("%v", err)
+		return nil
+	}
+	if !targetCase.Folds() {
+		return nil
+	}
+	groups := make([]string, len(collisions))
+	for i, group := range collisions {
+		groups[i] = strings.Join(group, "/")
+	}
+	return fmt.Errorf("the dump has tables whose names differ only by case (%s), but the target has %s and would restore them into one table",
+		strings.Join(groups, ", "), targetCase)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+var nativeDump bool
+
+func init() {
+	dumpCmd.Flags().BoolVar(&nativeDump, "native", false, "Dump over dbdump's own connection instead of running mysqldump (no triggers, events or routines)")
+}
+
+// checkDumpTool checks that mysqldump is available, unless the dump doesn't need it
+func checkDumpTool() error {
+	if nativeDump {
+		return nil
+	}
+	if err := database.CheckMySQLDump(); err != nil {
+		return fmt.Errorf("mysqldump is required (or use --native): %w", err)
+	}
+	return nil
+}
+
+// validateNativeFlags rejects options that only mysqldump implements
+func validateNativeFlags() error {
+	if nativeDump && convertCharset != "" {
+		return fmt.Errorf("--native cannot be combined with --convert-charset (the data is transcoded by mysqldump)")
+	}
+	if nativeDump && skipTZUTC {
+		return fmt.Errorf("--native always dumps TIMESTAMP values in UTC and cannot be combined with --skip-tz-utc")
+	}
+	return nil
+}
+
+// dumpToolName describes what writes the dump, for its header
+func dumpToolName() string {
+	if nativeDump {
+		return "native"
+	}
+	return toolVersion("mysqldump")
+}
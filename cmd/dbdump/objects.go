@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/spf13/cobra"
+)
+
+var objectsFormat string
+
+var objectsCmd = &cobra.Command{
+	Use:   "objects",
+	Short: "List stored procedures, functions, triggers and events",
+	Long: `List the programmable objects of a database (stored procedures, functions,
+triggers with their table, and events) with their definer, creation date and
+body size, read from information_schema.
+
+Objects whose definer account doesn't exist on the server are flagged: they
+fail when run, and restoring them elsewhere needs SUPER or SET_USER_ID.
+Routines declared SQL SECURITY DEFINER, which run with the definer's
+privileges, are flagged as well. Checking definers needs SELECT on mysql.user.`,
+	RunE: runObjects,
+}
+
+func init() {
+	objectsCmd.Flags().StringVar(&objectsFormat, "format", "table", "Output format: table or json")
+	rootCmd.AddCommand(objectsCmd)
+}
+
+// objectsReport is the JSON output of the objects command
+type objectsReport struct {
+	Database        string                `json:"database"`
+	DefinersChecked bool                  `json:"definers_checked"`
+	Objects         []database.ObjectInfo `json:"objects"`
+}
+
+func runObjects(cmd *cobra.Command, args []string) error {
+	if objectsFormat != "table" && objectsFormat != "json" {
+		return fmt.Errorf("unsupported --format %q (supported: table, json)", objectsFormat)
+	}
+
+	resolvePassword()
+
+	if user == "" {
+		return fmt.Errorf("database user is required (use -u or --user)")
+	}
+	if dbName == "" {
+		return fmt.Errorf("database name is required (use -d or --database)")
+	}
+
+	conn := &database.Connection{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		Database: dbName,
+	}
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return err
+	}
+
+	db, err := conn.ConnectContext(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	inspector, err := newInspector(cmd.Context(), db)
+	if err != nil {
+		return err
+	}
+	objects, err := inspector.GetObjects()
+	if err != nil {
+		return err
+	}
+
+	report := objectsReport{Database: dbName, Objects: objects}
+	if len(objects) > 0 {
+		if err := inspector.CheckDefiners(objects); err != nil {
+			diag.Warnf("definers not checked: %v", err)
+		} else {
+			report.DefinersChecked = true
+		}
+	}
+
+	if objectsFormat == "json" {
+		if report.Objects == nil {
+			report.Objects = []database.ObjectInfo{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	printObjects(report)
+	return nil
+}
+
+// printObjects prints the object listing as a table
+func printObjects(report objectsReport) {
+	if len(report.Objects) == 0 {
+		fmt.Printf("\nNo routines, triggers or events in database '%s'\n", report.Database)
+		return
+	}
+
+	nameWidth := 24
+	for _, object := range report.Objects {
+		nameWidth = max(nameWidth, ui.DisplayWidth(object.Name))
+	}
+	nameWidth = min(nameWidth, 40)
+
+	fmt.Printf("\nObjects in database '%s':\n\n", report.Database)
+	fmt.Printf("%-10s %s %-20s %-24s %-10s %10s  %s\n", "Type", ui.PadRight("Name", nameWidth), "Table", "Definer", "Created", "Body", "Notes")
+	fmt.Println(strings.Repeat(ui.Sym().Rule, min(nameWidth+100, ui.LineWidth(120))))
+
+	missing, securityDefiner := 0, 0
+	for _, object := range report.Objects {
+		created := "-"
+		if !object.Created.IsZero() {
+			created = object.Created.Format("2006-01-02")
+		}
+		body := database.FormatBytes(object.BodySize)
+		if object.BodyHidden {
+			body = "hidden"
+		}
+
+		var notes []string
+		if object.DefinerMissing != nil && *object.DefinerMissing {
+			notes = append(notes, "definer does not exist")
+			missing++
+		}
+		if object.SecurityDefiner {
+			notes = append(notes, "SQL SECURITY DEFINER")
+			securityDefiner++
+		}
+		if object.Timing != "" {
+			notes = append(notes, object.Timing)
+		}
+
+		fmt.Printf("%-10s %s %-20s %-24s %-10s %10s  %s\n",
+			object.Type,
+			ui.PadRight(ui.Truncate(object.Name, nameWidth), nameWidth),
+			ui.Truncate(valueOrDash(object.Table), 20),
+			ui.Truncate(object.Definer, 24),
+			created,
+			body,
+			strings.Join(notes, ", "),
+		)
+	}
+
+	fmt.Printf("\nTotal: %d objects\n", len(report.Objects))
+	if missing > 0 {
+		ui.PrintWarning(fmt.Sprintf("%d object(s) have a definer that doesn't exist on this server; they fail when run, and restoring them needs SUPER or SET_USER_ID", missing))
+	}
+	if securityDefiner > 0 {
+		ui.PrintInfo(fmt.Sprintf("%d routine(s) run with their definer's privileges (SQL SECURITY DEFINER)", securityDefiner))
+	}
+}
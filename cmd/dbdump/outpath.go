@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// resolveOutputFile sets outputFile to the absolute path of the dump: the
+// file a resumed dump continues, -o, or a generated name, with the
+// compression's extension. It reports whether the name was generated.
+func resolveOutputFile(resumable *resumeRun) (bool, error) {
+	if resumed := resumable.outputFile(); resumed != "" {
+		outputFile = resumed
+	}
+
+	generated := outputFile == ""
+	if generated {
+		format, err := filenameFormat()
+		if err != nil {
+			return false, err
+		}
+		outputFile = planner.OutputName(outputDir, dbName, planner.OutputKind(schemaDelta, appendSinceLast), format, time.Now())
+	}
+	var err error
+	if outputFile, err = applyCompression(outputFile); err != nil {
+		return false, err
+	}
+
+	outputFile, err = filepath.Abs(outputFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	return generated, nil
+}
+
+// validateOutputPaths checks before connecting that the dump and its sidecar
+// can be written: the directory exists and accepts new files, and existing
+// files at the target paths can be overwritten
+func validateOutputPaths(output string, split bool) error {
+	dir := filepath.Dir(output)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return &dberrors.ErrOutputPath{Path: dir, Op: "stat output directory", Err: err}
+	}
+	if !info.IsDir() {
+		return &dberrors.ErrOutputPath{Path: dir, Op: "stat output directory", Err: errors.New("not a directory")}
+	}
+
+	// Permission bits don't tell the whole story (ACLs, read-only mounts), so
+	// create and remove a real file
+	probe, err := os.CreateTemp(dir, ".dbdump-probe-*")
+	if err != nil {
+		return &dberrors.ErrOutputPath{Path: dir, Op: "create probe file", Err: err}
+	}
+	probePath := probe.Name()
+	if err := probe.Close(); err != nil {
+		diag.Warnf("failed to close probe file: %v", err)
+	}
+	if err := os.Remove(probePath); err != nil {
+		return &dberrors.ErrOutputPath{Path: probePath, Op: "remove probe file", Err: err}
+	}
+
+	dump := output
+	if split {
+		dump = dumpfile.PartPath(output, 1)
+	}
+	for _, path := range []string{dump, metadata.SidecarPath(output)} {
+		if err := checkOverwritable(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkOverwritable verifies that an existing file at path can be replaced;
+// a missing file is fine since the directory was already probed
+func checkOverwritable(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return &dberrors.ErrOutputPath{Path: path, Op: "stat existing file", Err: err}
+	}
+	if info.IsDir() {
+		return &dberrors.ErrOutputPath{Path: path, Op: "overwrite existing file", Err: errors.New("is a directory")}
+	}
+
+	// Opening without O_TRUNC leaves the existing contents untouched
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return &dberrors.ErrOutputPath{Path: path, Op: "open existing file for writing", Err: err}
+	}
+	if err := file.Close(); err != nil {
+		diag.Warnf("failed to close %s: %v", path, err)
+	}
+	return nil
+}
+
+// suggestedPartSize is the --max-file-size suggested for filesystems with a
+// file size limit; it stays clear of FAT32's 4GiB
+const suggestedPartSize = "4000MB"
+
+// checkOutputFilesystem detects the filesystem the dump goes to and refuses
+// parts larger than it can hold
+func checkOutputFilesystem(output string, maxPartSize int64) (fileutil.Filesystem, error) {
+	fs := fileutil.DetectFilesystem(filepath.Dir(output))
+	if maxPartSize > 0 && !fs.Fits(maxPartSize) {
+		return fs, &dberrors.ErrConfigInvalid{Source: "--max-file-size", Problems: []string{
+			fmt.Sprintf("%s holds files of at most %s; use --max-file-size %s or less", fs.Name(), database.FormatBytes(fs.MaxFileSize), suggestedPartSize),
+		}}
+	}
+	return fs, nil
+}
+
+// filesystemAdvice returns a warning when a dump of the estimated size won't
+// fit in a single file on fs, or "" when it will (or the dump is split)
+func filesystemAdvice(fs fileutil.Filesystem, estimate, maxPartSize int64, compress bool) string {
+	if maxPartSize > 0 || estimate == 0 || fs.Fits(estimate) {
+		return ""
+	}
+	verdict := "will not fit"
+	if compress {
+		// The estimate is of the uncompressed dump
+		verdict = "may not fit"
+	}
+	return fmt.Sprintf("The dump (estimated %s) %s on %s, which holds files of at most %s; split it with --max-file-size %s",
+		database.FormatBytes(estimate), verdict, fs.Name(), database.FormatBytes(fs.MaxFileSize), suggestedPartSize)
+}
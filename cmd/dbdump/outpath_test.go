@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+)
+
+func TestFilesystemAdvice(t *testing.T) {
+	fat32 := fileutil.NewFilesystem("vfat")
+	const gb = 1000 * 1000 * 1000
+
+	tests := []struct {
+		name     string
+		fs       fileutil.Filesystem
+		estimate int64
+		maxPart  int64
+		compress bool
+		want     string
+	}{
+		{
+			name: "too large for fat32", fs: fat32, estimate: 6 * gb,
+			want: "The dump (estimated 5.6 GB) will not fit on vfat, which holds files of at most 4.0 GB; split it with --max-file-size 4000MB",
+		},
+		{
+			name: "compressed may fit", fs: fat32, estimate: 6 * gb, compress: true,
+			want: "The dump (estimated 5.6 GB) may not fit on vfat, which holds files of at most 4.0 GB; split it with --max-file-size 4000MB",
+		},
+		{name: "fits", fs: fat32, estimate: 3 * gb},
+		{name: "already split", fs: fat32, estimate: 6 * gb, maxPart: 2 * gb},
+		{name: "size unknown", fs: fat32},
+		{name: "no limit", fs: fileutil.NewFilesystem("exfat"), estimate: 600 * gb},
+		{name: "unknown filesystem", fs: fileutil.Filesystem{}, estimate: 600 * gb},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filesystemAdvice(tt.fs, tt.estimate, tt.maxPart, tt.compress); got != tt.want {
+				t.Errorf("filesystemAdvice() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOutputFilesystem(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "shop.sql")
+	detected := fileutil.DetectFilesystem(filepath.Dir(output))
+
+	for _, maxPart := range []int64{0, 1 << 20, 8 << 30} {
+		fs, err := checkOutputFilesystem(output, maxPart)
+		if fs != detected {
+			t.Errorf("checkOutputFilesystem() = %+v, want %+v", fs, detected)
+		}
+		var invalid *dberrors.ErrConfigInvalid
+		switch {
+		case detected.Fits(maxPart) && err != nil:
+			t.Errorf("--max-file-size %d: %v", maxPart, err)
+		case !detected.Fits(maxPart) && !errors.As(err, &invalid):
+			t.Errorf("--max-file-size %d on %s: %v, want ErrConfigInvalid", maxPart, detected.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// checkPacketLimit reads the server's max_allowed_packet and warns about
+// dumped tables whose rows could exceed it, before mysqldump fails on them
+// deep into the dump. It returns the limit, or 0 when it can't be read.
+func checkPacketLimit(ctx context.Context, inspector *database.Inspector, tablesInfo []database.TableInfo, excludes []string, samples map[string]int) int64 {
+	limit, err := inspector.GetMaxAllowedPacket()
+	if err != nil {
+		diag.Warnf("%v", err)
+		return 0
+	}
+	if limit < database.ClientMaxAllowedPacket {
+		ui.PrintInfo(fmt.Sprintf("Server max_allowed_packet is %s; mysqldump's is lowered to match", database.FormatBytes(limit)))
+	}
+
+	// Only tables with data in the dump matter
+	excluded := make(map[string]bool, len(excludes))
+	for _, table := range excludes {
+		_, sampled := samples[table]
+		excluded[table] = !sampled
+	}
+	var dumped []database.TableInfo
+	for _, info := range tablesInfo {
+		if !excluded[info.Name] {
+			dumped = append(dumped, info)
+		}
+	}
+
+	risks, err := inspector.PacketRisks(ctx, dumped, limit)
+	if err != nil {
+		diag.Warnf("%v", err)
+		return limit
+	}
+	for _, risk := range risks {
+		message := fmt.Sprintf("rows of %s average %s (about %s hex-encoded), close to the server's max_allowed_packet of %s",
+			risk.Table, database.FormatBytes(risk.AvgRowLength), database.FormatBytes(2*risk.AvgRowLength), database.FormatBytes(limit))
+		if len(risk.LargeColumns) > 0 {
+			message += "; the largest rows come from " + strings.Join(risk.LargeColumns, ", ")
+		}
+		diag.Warnf("%s. Exclude it, dump part of it with --sample %s=N, or raise max_allowed_packet on the server", message, risk.Table)
+	}
+	return limit
+}
+
+// checkTargetPacketLimit refuses to restore a dump whose largest statement,
+// as recorded in its metadata, exceeds the target server's max_allowed_packet.
+// Without metadata, or when the target can't be asked, the restore goes ahead.
+func checkTargetPacketLimit(ctx context.Context, inputFile string, target *database.Connection) error {
+	meta, err := metadata.LoadForDump(inputFile)
+	if err != nil || meta == nil || meta.LargestStatement == 0 {
+		return nil
+	}
+
+	// The target database may not exist yet
+	server := *target
+	server.Database = ""
+	db, err := server.ConnectContext(ctx)
+	if err != nil {
+		diag.Warnf("could not check the target's max_allowed_packet: %v", err)
+		return nil
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	limit, err := database.NewInspector(db).WithContext(ctx).GetMaxAllowedPacket()
+	if err != nil {
+		diag.Warnf("%v", err)
+		return nil
+	}
+	if meta.LargestStatement <= limit {
+		return nil
+	}
+	return fmt.Errorf("the dump's largest statement is %s but the target server's max_allowed_packet is %s; raise it first (SET GLOBAL max_allowed_packet = %d, then reconnect)",
+		database.FormatBytes(meta.LargestStatement), database.FormatBytes(limit), roundUpMiB(meta.LargestStatement))
+}
+
+// roundUpMiB rounds a size up to the next whole MiB with one MiB to spare,
+// a value to suggest for max_allowed_packet
+func roundUpMiB(size int64) int64 {
+	const mib = 1 << 20
+	return (size/mib + 2) * mib
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+func TestCheckPacketLimit(t *testing.T) {
+	savedOutput := diag.Output
+	defer func() {
+		diag.Output = savedOutput
+		diag.Default.Reset()
+	}()
+
+	tables := []database.TableInfo{
+		{Name: "attachments", RowCount: 10, DataSize: 20 << 20},
+		{Name: "archive", RowCount: 10, DataSize: 20 << 20},
+		{Name: "users", RowCount: 1000, DataSize: 1 << 20},
+	}
+	packet := func(size int64) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"@@max_allowed_packet"}).AddRow(size)
+	}
+	columns := func(name, columnType string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"column_name", "column_type", "data_type", "nullable", "max_length", "extra", "expression", "has_default"}).
+			AddRow(name, columnType, columnType, true, 0, "", "", false)
+	}
+
+	tests := []struct {
+		name     string
+		excludes []string
+		samples  map[string]int
+		expect   func(mock sqlmock.Sqlmock)
+		want     int64
+		warnings []string // tables warned about
+	}{
+		{
+			name: "large server",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`@@max_allowed_packet`).WillReturnRows(packet(1 << 30))
+			},
+			want: 1 << 30,
+		},
+		{
+			name: "small server",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`@@max_allowed_packet`).WillReturnRows(packet(16 << 20))
+				mock.ExpectQuery(`information_schema\.columns`).WithArgs("attachments").WillReturnRows(columns("body", "longblob"))
+				mock.ExpectQuery(`information_schema\.columns`).WithArgs("archive").WillReturnRows(columns("data", "mediumblob"))
+			},
+			want:     16 << 20,
+			warnings: []string{"attachments", "archive"},
+		},
+		{
+			// Excluded data can't exceed the limit, sampled data can
+			name:     "excluded and sampled",
+			excludes: []string{"attachments", "archive"},
+			samples:  map[string]int{"archive": 100},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`@@max_allowed_packet`).WillReturnRows(packet(16 << 20))
+				mock.ExpectQuery(`information_schema\.columns`).WithArgs("archive").WillReturnRows(columns("data", "mediumblob"))
+			},
+			want:     16 << 20,
+			warnings: []string{"archive"},
+		},
+		{
+			name: "unreadable",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`@@max_allowed_packet`).WillReturnError(errors.New("denied"))
+			},
+			want:     0,
+			warnings: []string{"failed to get max_allowed_packet"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diag.Default.Reset()
+			var stderr bytes.Buffer
+			diag.Output = &stderr
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+			tt.expect(mock)
+
+			got := checkPacketLimit(context.Background(), database.NewInspector(db), tables, tt.excludes, tt.samples)
+			if got != tt.want {
+				t.Errorf("checkPacketLimit() = %d, want %d", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			warnings := diag.Default.Warnings()
+			if len(warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %+v, want %d", warnings, len(tt.warnings))
+			}
+			for i, want := range tt.warnings {
+				if !strings.Contains(warnings[i].Message, want) {
+					t.Errorf("warning %d = %q, want it to mention %s", i, warnings[i].Message, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundUpMiB(t *testing.T) {
+	const mib = 1 << 20
+	for size, want := range map[int64]int64{
+		1:              2 * mib,
+		mib - 1:        2 * mib,
+		mib:            3 * mib,
+		70*mib + 12345: 72 * mib,
+		1<<30 + 1:      1026 * mib,
+	} {
+		if got := roundUpMiB(size); got != want {
+			t.Errorf("roundUpMiB(%d) = %d, want %d", size, got, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/tuning"
+	"github.com/helgesverre/dbdump/internal/ui"
+)
+
+// autoTune picks the performance settings the config leaves unset from the
+// conditions of the dump
+var autoTune bool
+
+// parallelJobsEnv tells the job processes of dbdump run how many of them
+// run at once, for --auto-tune to share the machine between them
+const parallelJobsEnv = "DBDUMP_PARALLEL_JOBS"
+
+func init() {
+	const usage = "Pick buffer sizes, compression and parallel jobs from the CPUs, available memory, output disk and server location (the config's performance settings still apply)"
+	dumpCmd.Flags().BoolVar(&autoTune, "auto-tune", false, usage)
+	runCmd.Flags().BoolVar(&autoTune, "auto-tune", false, usage)
+}
+
+// performance is the settings of a dump and, with --auto-tune, the
+// conditions they were picked for
+type performance struct {
+	settings   tuning.Settings
+	conditions *tuning.Conditions
+}
+
+// resolvePerformance returns the defaults, or with --auto-tune the settings
+// picked for a server on host writing to dir, overridden field by field by
+// the performance section of the global config, then the project config
+func resolvePerformance(host, dir string) (performance, error) {
+	var chosen performance
+	if autoTune {
+		jobs, _ := strconv.Atoi(os.Getenv(parallelJobsEnv))
+		conditions := tuning.Detect(localServer(host), dir, jobs)
+		chosen.settings, chosen.conditions = tuning.Auto(conditions), &conditions
+	}
+
+	if globalConfig, err := config.LoadGlobalConfig(); err == nil && globalConfig != nil {
+		settings, problems := globalConfig.Performance.Settings()
+		if len(problems) > 0 {
+			return chosen, &dberrors.ErrConfigInvalid{Source: "~/.dbdump.yaml", Problems: problems}
+		}
+		chosen.settings = chosen.settings.Override(settings)
+	}
+	if len(configFiles) > 0 {
+		if projectConfig, err := loadProjectConfig(); err == nil {
+			settings, problems := projectConfig.Performance.Settings()
+			if len(problems) > 0 {
+				return chosen, &dberrors.ErrConfigInvalid{Source: configSource(), Problems: problems}
+			}
+			chosen.settings = chosen.settings.Override(settings)
+		}
+	}
+	chosen.settings = chosen.settings.Filled()
+	return chosen, nil
+}
+
+// print shows the settings, and what they were tuned for, with -v
+func (p performance) print() {
+	ui.PrintInfo("Performance: " + p.settings.String())
+	if p.conditions != nil {
+		ui.PrintInfo("Auto-tuned for " + p.conditions.String())
+	}
+}
+
+// metadata records the settings in the sidecar so a dump's speed can be
+// reproduced
+func (p performance) metadata() *metadata.Performance {
+	recorded := &metadata.Performance{
+		WriterBuffer:       p.settings.WriterBuffer,
+		CompressionLevel:   p.settings.CompressionLevel,
+		CompressionWorkers: p.settings.CompressionWorkers,
+		DumpParallelism:    p.settings.DumpParallelism,
+		NetBuffer:          p.settings.NetBuffer,
+	}
+	if c := p.conditions; c != nil {
+		recorded.AutoTuned = &metadata.TuningConditions{
+			Local:           c.Local,
+			CPUs:            c.CPUs,
+			Disk:            string(c.Disk),
+			AvailableMemory: c.AvailableMemory,
+			Jobs:            c.Jobs,
+		}
+	}
+	return recorded
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/plan"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/structure"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	planOutput      string
+	planDestination string
+	planFile        string
+	planAllowDrift  bool
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan [tables...]",
+	Short: "Write the effective dump plan for review",
+	Long: `Resolve the same rules as 'dbdump dump --auto' and write the result as a YAML
+plan: the target database (without secrets), every table with its disposition
+(full, structure-only or skipped) and the rule responsible, estimated sizes,
+transforms and the destination.
+
+Run the reviewed plan with 'dbdump dump --plan plan.yaml'. The dump refuses to
+run when tables exist that are not in the plan, unless --plan-allow-drift is
+given (new tables are then skipped entirely).`,
+	RunE: runPlan,
+}
+
+// planConflicts are dump flags that would change what a plan does
+var planConflicts = []string{
+	"config", "exclude", "exclude-pattern", "exclude-json", "only", "only-pattern",
+	"skip-engines", "skip-engines-keep-structure", "convert-charset",
+	"max-file-size", "output", "sample",
+}
+
+func init() {
+	planCmd.Flags().StringVarP(&planOutput, "output", "o", "", "Plan file to write")
+	planCmd.Flags().StringVar(&planDestination, "destination", "", "Dump file the plan writes (default: {database}_{timestamp}.sql at dump time)")
+	addSelectionFlags(planCmd)
+	_ = planCmd.MarkFlagRequired("output")
+
+	dumpCmd.Flags().StringVar(&planFile, "plan", "", "Execute a plan written by 'dbdump plan' instead of selecting tables")
+	dumpCmd.Flags().BoolVar(&planAllowDrift, "plan-allow-drift", false, "With --plan, skip tables that are not in the plan instead of refusing to run")
+
+	rootCmd.AddCommand(planCmd)
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	resolvePassword()
+
+	// Validate required flags
+	if user == "" {
+		return fmt.Errorf("database user is required (use -u or --user)")
+	}
+	if dbName == "" {
+		return fmt.Errorf("database name is required (use -d or --database)")
+	}
+	if err := checkSystemDatabase(); err != nil {
+		return err
+	}
+	if _, err := parseMaxFileSize(); err != nil {
+		return err
+	}
+	if err := validateAutoThreshold(); err != nil {
+		return err
+	}
+
+	destination := planDestination
+	if destination != "" {
+		compressed, err := applyCompression(destination)
+		if err != nil {
+			return err
+		}
+		abs, err := filepath.Abs(compressed)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		destination = abs
+	}
+
+	conn := &database.Connection{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		Database: dbName,
+		ReadOnly: resolveReadOnly(cmd),
+	}
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return err
+	}
+
+	db, err := conn.ConnectContext(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	inspector, err := newInspector(cmd.Context(), db)
+	if err != nil {
+		return err
+	}
+	tablesInfo, err := inspector.GetAllTablesInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get table information: %w", err)
+	}
+	sizesKnown := reportDegraded(inspector, tablesInfo)
+
+	p, sel, err := applySelectionRules(tablesInfo, args)
+	if err != nil {
+		return err
+	}
+
+	// Report overflowing columns now rather than when the plan is executed
+	if convertCharset != "" {
+		if _, err := prepareCharsetConversion(inspector, sel.Tables, convertCharset); err != nil {
+			return err
+		}
+	}
+
+	planned := p.Plan(planner.Input{
+		Selection:  sel,
+		Excludes:   sel.Decide(thresholdExcludes(sel)),
+		SizesKnown: sizesKnown,
+		OutputFile: destination,
+		Dump:       database.DumpOptions{Connection: conn, DefaultCharacterSet: convertCharset},
+	})
+
+	written := buildPlan(conn, planned)
+	written.Transforms.ConvertCharset = convertCharset
+	written.Destination = plan.Destination{Output: destination, MaxFileSize: maxFileSize.String(), Compress: compressOutput}
+
+	if err := plan.Write(planOutput, written); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Plan written to %s", planOutput))
+	ui.PrintInfo(fmt.Sprintf("%d full, %d structure only (%d sampled), %d skipped, about %s",
+		planned.Count(plan.DispositionFull), planned.Count(plan.DispositionStructureOnly), len(planned.Samples),
+		planned.Count(plan.DispositionSkipped), database.FormatBytes(written.EstimatedBytes)))
+
+	return nil
+}
+
+// buildPlan records the disposition of every planned table and the rule
+// responsible
+func buildPlan(conn *database.Connection, planned *planner.DumpPlan) *plan.Plan {
+	p := &plan.Plan{
+		ToolVersion: Version,
+		CreatedAt:   time.Now().UTC(),
+		Target: plan.Target{
+			Host:     conn.Host,
+			Port:     conn.Port,
+			User:     conn.User,
+			Database: conn.Database,
+		},
+	}
+
+	for _, planned := range planned.Tables {
+		table := plan.Table{
+			Name:        planned.Name,
+			Disposition: planned.Disposition,
+			Rule:        planned.Rule,
+			Rows:        planned.RowCount,
+		}
+		switch planned.Disposition {
+		case plan.DispositionSkipped:
+			if table.Rule == "" {
+				table.Rule = "not matched by only rules"
+			}
+		case plan.DispositionStructureOnly:
+			table.Structure = string(planned.Structure)
+			table.Sample = planned.SampleRows
+		default:
+			table.EstimatedBytes = planned.DataSize
+		}
+		p.Tables = append(p.Tables, table)
+	}
+
+	p.EstimatedBytes = planned.EstimatedSize
+
+	return p
+}
+
+// loadDumpPlan loads the plan given with --plan and applies its target,
+// transforms and destination to the dump flags
+func loadDumpPlan(cmd *cobra.Command, args []string) (*plan.Plan, error) {
+	if len(args) > 0 {
+		return nil, fmt.Errorf("--plan cannot be combined with table arguments")
+	}
+	for _, name := range planConflicts {
+		if cmd.Flags().Changed(name) {
+			return nil, fmt.Errorf("--plan cannot be combined with --%s (the plan decides it)", name)
+		}
+	}
+
+	p, err := plan.Load(planFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Connection flags may repeat the plan's target but not change it
+	var mismatches []string
+	if cmd.Flags().Changed("host") && !database.SameServer(host, port, p.Target.Host, p.Target.Port) {
+		mismatches = append(mismatches, fmt.Sprintf("host %s:%d (plan: %s:%d)", host, port, p.Target.Host, p.Target.Port))
+	}
+	if cmd.Flags().Changed("port") && port != p.Target.Port {
+		mismatches = append(mismatches, fmt.Sprintf("port %d (plan: %d)", port, p.Target.Port))
+	}
+	if cmd.Flags().Changed("user") && user != p.Target.User {
+		mismatches = append(mismatches, fmt.Sprintf("user %s (plan: %s)", user, p.Target.User))
+	}
+	if cmd.Flags().Changed("database") && dbName != p.Target.Database {
+		mismatches = append(mismatches, fmt.Sprintf("database %s (plan: %s)", dbName, p.Target.Database))
+	}
+	if len(mismatches) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{
+			Source:   planFile,
+			Problems: []string{"connection flags differ from the plan's target: " + strings.Join(mismatches, ", ")},
+		}
+	}
+
+	host, port, user, dbName = p.Target.Host, p.Target.Port, p.Target.User, p.Target.Database
+	convertCharset = p.Transforms.ConvertCharset
+	maxFileSize = units.Size{}
+	if p.Destination.MaxFileSize != "" {
+		if err := maxFileSize.Set(p.Destination.MaxFileSize); err != nil {
+			return nil, &dberrors.ErrConfigInvalid{Source: planFile, Problems: []string{"max_file_size: " + err.Error()}}
+		}
+	}
+	outputFile = p.Destination.Output
+	compressOutput = p.Destination.Compress
+
+	return p, nil
+}
+
+// planStructureLevels returns the structure levels recorded in a plan
+func planStructureLevels(p *plan.Plan) (map[string]structure.Level, error) {
+	levels := make(map[string]structure.Level)
+	for _, table := range p.Tables {
+		if table.Structure == "" || table.Disposition != plan.DispositionStructureOnly {
+			continue
+		}
+		level, err := structure.ParseLevel(table.Structure)
+		if err != nil {
+			return nil, &dberrors.ErrConfigInvalid{Source: planFile, Problems: []string{table.Name + ": " + err.Error()}}
+		}
+		if level != structure.Full {
+			levels[table.Name] = level
+		}
+	}
+	return levels, nil
+}
+
+// planSelection turns a plan into a table selection for the live tables,
+// refusing to run when tables exist that the plan doesn't cover
+func planSelection(p *plan.Plan, tablesInfo []database.TableInfo) (*planner.Selection, error) {
+	live := make([]string, len(tablesInfo))
+	for i, info := range tablesInfo {
+		live[i] = info.Name
+	}
+
+	added, removed := p.Drift(live)
+	if len(added) > 0 && !planAllowDrift {
+		return nil, &dberrors.ErrConfigInvalid{
+			Source: planFile,
+			Problems: []string{fmt.Sprintf("%d tables are not in the plan: %s (re-run 'dbdump plan' or pass --plan-allow-drift to skip them)",
+				len(added), strings.Join(added, ", "))},
+		}
+	}
+	if len(added) > 0 {
+		ui.PrintWarning(fmt.Sprintf("Skipping %d tables not in the plan: %s", len(added), strings.Join(added, ", ")))
+	}
+	if len(removed) > 0 {
+		ui.PrintWarning(fmt.Sprintf("%d planned tables no longer exist: %s", len(removed), strings.Join(removed, ", ")))
+	}
+
+	// Tables that appear during the dump aren't in the plan either
+	var planned config.ExcludeConfig
+	for _, table := range p.Tables {
+		planned.Exact = append(planned.Exact, table.Name)
+	}
+	sel := &planner.Selection{All: tablesInfo, Only: patterns.NewMatcher(planned), Reasons: make(map[string]string)}
+	skipped := make(map[string]bool)
+	for _, name := range added {
+		skipped[name] = true
+		sel.Reasons[name] = "not in the plan"
+	}
+	for _, table := range p.Tables {
+		if table.Rule != "" {
+			sel.Reasons[table.Name] = table.Rule
+		}
+		switch table.Disposition {
+		case plan.DispositionSkipped:
+			skipped[table.Name] = true
+		case plan.DispositionStructureOnly:
+			sel.PreSelected = append(sel.PreSelected, table.Name)
+			if table.Sample > 0 {
+				exact := config.ExcludeConfig{Exact: []string{table.Name}}
+				sel.Samples = append(sel.Samples, planner.SampleRule{Matcher: patterns.NewMatcher(exact), Rows: table.Sample})
+			}
+		}
+	}
+
+	for _, info := range tablesInfo {
+		if skipped[info.Name] {
+			sel.Skipped = append(sel.Skipped, info.Name)
+		} else {
+			sel.Tables = append(sel.Tables, info)
+		}
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Executing plan %s: %d tables dumped, %d structure only, %d skipped",
+		planFile, len(sel.Tables), len(sel.PreSelected), len(sel.Skipped)))
+
+	return sel, nil
+}
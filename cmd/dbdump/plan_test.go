@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/plan"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/structure"
+)
+
+// TestPlanRoundTrip writes the plan of a dump, loads it back and checks
+// that executing it selects the tables the way they were planned
+func TestPlanRoundTrip(t *testing.T) {
+	savedFile, savedDrift := planFile, planAllowDrift
+	defer func() { planFile, planAllowDrift = savedFile, savedDrift }()
+
+	conn := &database.Connection{Host: "db.internal", Port: 3306, User: "reader", Password: "secret", Database: "shop"}
+	planned := &planner.DumpPlan{
+		Database:      "shop",
+		EstimatedSize: 6000,
+		Tables: []planner.Table{
+			{TableInfo: database.TableInfo{Name: "users", RowCount: 10, DataSize: 4000}, Disposition: plan.DispositionFull},
+			{TableInfo: database.TableInfo{Name: "orders", RowCount: 20, DataSize: 2000}, Disposition: plan.DispositionFull, Rule: "include orders"},
+			{TableInfo: database.TableInfo{Name: "sessions", RowCount: 900, DataSize: 90000}, Disposition: plan.DispositionStructureOnly, Rule: "exclude sessions", Structure: structure.Minimal},
+			{TableInfo: database.TableInfo{Name: "audit_log", RowCount: 5000}, Disposition: plan.DispositionStructureOnly, Rule: "exclude *_log", SampleRows: 100},
+			{TableInfo: database.TableInfo{Name: "tmp_import"}, Disposition: plan.DispositionSkipped, Rule: "skip tmp_*"},
+			{TableInfo: database.TableInfo{Name: "legacy"}, Disposition: plan.DispositionSkipped},
+		},
+	}
+
+	planFile = filepath.Join(t.TempDir(), "plan.yaml")
+	if err := plan.Write(planFile, buildPlan(conn, planned)); err != nil {
+		t.Fatal(err)
+	}
+	p, err := plan.Load(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Target != (plan.Target{Host: "db.internal", Port: 3306, User: "reader", Database: "shop"}) {
+		t.Errorf("target = %+v", p.Target)
+	}
+	if p.EstimatedBytes != 6000 || p.Tables[0].EstimatedBytes != 4000 || p.Tables[2].EstimatedBytes != 0 {
+		t.Errorf("estimates = %d, %d, %d; want 6000, 4000 and none for a structure-only table",
+			p.EstimatedBytes, p.Tables[0].EstimatedBytes, p.Tables[2].EstimatedBytes)
+	}
+	if rule := p.Tables[5].Rule; rule != "not matched by only rules" {
+		t.Errorf("rule of a table skipped by only rules = %q", rule)
+	}
+
+	levels, err := planStructureLevels(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]structure.Level{"sessions": structure.Minimal}; !reflect.DeepEqual(levels, want) {
+		t.Errorf("planStructureLevels() = %v, want %v", levels, want)
+	}
+
+	live := make([]database.TableInfo, len(planned.Tables))
+	for i, table := range planned.Tables {
+		live[i] = table.TableInfo
+	}
+	sel, err := planSelection(p, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for _, info := range sel.Tables {
+		tables = append(tables, info.Name)
+	}
+	if want := []string{"users", "orders", "sessions", "audit_log"}; !reflect.DeepEqual(tables, want) {
+		t.Errorf("tables = %q, want %q", tables, want)
+	}
+	if want := []string{"sessions", "audit_log"}; !reflect.DeepEqual(sel.PreSelected, want) {
+		t.Errorf("structure only = %q, want %q", sel.PreSelected, want)
+	}
+	if want := []string{"tmp_import", "legacy"}; !reflect.DeepEqual(sel.Skipped, want) {
+		t.Errorf("skipped = %q, want %q", sel.Skipped, want)
+	}
+	if rows := sel.Samples.Rows("audit_log"); rows != 100 {
+		t.Errorf("sampled rows of audit_log = %d, want 100", rows)
+	}
+	if rows := sel.Samples.Rows("sessions"); rows != 0 {
+		t.Errorf("sampled rows of sessions = %d, want 0", rows)
+	}
+	if reason := sel.Reasons["sessions"]; reason != "exclude sessions" {
+		t.Errorf("reason for sessions = %q", reason)
+	}
+}
+
+func TestPlanSelectionDrift(t *testing.T) {
+	savedFile, savedDrift := planFile, planAllowDrift
+	defer func() { planFile, planAllowDrift = savedFile, savedDrift }()
+	planFile = "plan.yaml"
+
+	p := &plan.Plan{
+		Target: plan.Target{Database: "shop"},
+		Tables: []plan.Table{
+			{Name: "users", Disposition: plan.DispositionFull},
+			{Name: "orders", Disposition: plan.DispositionFull},
+		},
+	}
+	live := []database.TableInfo{{Name: "users"}, {Name: "carts"}, {Name: "coupons"}}
+
+	planAllowDrift = false
+	_, err := planSelection(p, live)
+	var invalid *dberrors.ErrConfigInvalid
+	if !errors.As(err, &invalid) || !strings.Contains(invalid.Problems[0], "2 tables are not in the plan: carts, coupons") {
+		t.Fatalf("planSelection() = %v, want a refusal naming carts and coupons", err)
+	}
+
+	// Allowed drift skips the new tables; a dropped table is only noted
+	planAllowDrift = true
+	sel, err := planSelection(p, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sel.Tables) != 1 || sel.Tables[0].Name != "users" {
+		t.Errorf("tables = %v, want users", sel.Tables)
+	}
+	if want := []string{"carts", "coupons"}; !reflect.DeepEqual(sel.Skipped, want) {
+		t.Errorf("skipped = %q, want %q", sel.Skipped, want)
+	}
+	if reason := sel.Reasons["carts"]; reason != "not in the plan" {
+		t.Errorf("reason for carts = %q", reason)
+	}
+	// Tables created during the dump aren't in the plan either
+	if sel.Only.Matches("carts") || !sel.Only.Matches("orders") {
+		t.Errorf("only rules don't follow the plan")
+	}
+}
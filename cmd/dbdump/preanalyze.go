@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/units"
+)
+
+const (
+	// defaultPreAnalyzeBudget is the time pre_analyze gets without a budget
+	defaultPreAnalyzeBudget = 30 * time.Second
+
+	// preAnalyzeWorkers is how many ANALYZE TABLE statements run at once
+	preAnalyzeWorkers = 4
+)
+
+// preAnalyzeSettings returns pre_analyze from the project config, or else
+// the global config; top is 0 when it is off
+func preAnalyzeSettings() (top int, budget time.Duration, err error) {
+	settings, source := config.PreAnalyzeConfig{}, ""
+	if globalConfig, err := config.LoadGlobalConfig(); err == nil && globalConfig != nil && globalConfig.PreAnalyze.Top != 0 {
+		settings, source = globalConfig.PreAnalyze, "~/.dbdump.yaml"
+	}
+	if len(configFiles) > 0 {
+		if projectConfig, err := loadProjectConfig(); err == nil && projectConfig.PreAnalyze.Top != 0 {
+			settings, source = projectConfig.PreAnalyze, configSource()
+		}
+	}
+
+	if settings.Top < 0 {
+		return 0, 0, &dberrors.ErrConfigInvalid{Source: source, Problems: []string{fmt.Sprintf("pre_analyze.top must not be negative, got %d", settings.Top)}}
+	}
+	budget = defaultPreAnalyzeBudget
+	if settings.Budget != "" {
+		if budget, err = units.ParseDuration(settings.Budget); err != nil || budget <= 0 {
+			if err == nil {
+				err = fmt.Errorf("must be positive")
+			}
+			return 0, 0, &dberrors.ErrConfigInvalid{Source: source, Problems: []string{"pre_analyze.budget: " + err.Error()}}
+		}
+	}
+	return settings.Top, budget, nil
+}
+
+// preAnalyze runs ANALYZE TABLE on the top largest base tables, a few at a
+// time, until they are all done or the budget is spent, and returns the
+// tables it refreshed. Tables the user may not analyze are skipped. status
+// receives progress for the selector.
+func preAnalyze(ctx context.Context, inspector *database.Inspector, top int, budget time.Duration, status func(string)) (map[string]bool, error) {
+	tablesInfo, err := inspector.GetAllTablesInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table information: %w", err)
+	}
+	var candidates []database.TableInfo
+	for _, info := range tablesInfo {
+		if info.Engine != "" && !info.SizeUnknown {
+			candidates = append(candidates, info)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].DataSize > candidates[j].DataSize })
+	candidates = candidates[:min(top, len(candidates))]
+	if len(candidates) == 0 {
+		ui.PrintInfo("pre_analyze is skipped: no table sizes are known")
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	var mu sync.Mutex
+	analyzed := make(map[string]bool)
+	var denied, failed []string
+	next, done := 0, 0
+
+	var wg sync.WaitGroup
+	for range min(preAnalyzeWorkers, len(candidates)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if next == len(candidates) || ctx.Err() != nil {
+					mu.Unlock()
+					return
+				}
+				table := candidates[next].Name
+				next++
+				mu.Unlock()
+
+				err := inspector.AnalyzeTable(ctx, table)
+
+				mu.Lock()
+				switch {
+				case err == nil:
+					analyzed[table] = true
+					done++
+					status(fmt.Sprintf("Refreshed statistics of %s (%d of %d)", table, done, len(candidates)))
+				case ctx.Err() != nil:
+					// Cut off by the budget, or the selector was left
+				case database.IsPermissionError(err):
+					denied = append(denied, table)
+				default:
+					failed = append(failed, table)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return nil, ctx.Err()
+	}
+
+	summary := fmt.Sprintf("pre_analyze refreshed the statistics of %d of the %d largest tables", len(analyzed), len(candidates))
+	if len(denied) > 0 {
+		summary += fmt.Sprintf(", %d skipped without the privilege to analyze them", len(denied))
+	}
+	if len(failed) > 0 {
+		summary += fmt.Sprintf(", %d failed", len(failed))
+	}
+	if missed := len(candidates) - len(analyzed) - len(denied) - len(failed); missed > 0 {
+		summary += fmt.Sprintf(", %d not finished within %s", missed, budget)
+	}
+	ui.PrintInfo(summary)
+	return analyzed, nil
+}
+
+// markAnalyzed flags the tables whose statistics pre_analyze refreshed
+func markAnalyzed(tables []database.TableInfo, analyzed map[string]bool) {
+	for i := range tables {
+		tables[i].Analyzed = analyzed[tables[i].Name]
+	}
+}
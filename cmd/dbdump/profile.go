@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileName string
+	profileTags []string
+
+	// activeProfile is the profile selected with --profile, if any
+	activeProfile *config.ConnectionProfile
+)
+
+var configSaveCmd = &cobra.Command{
+	Use:   "save <name>",
+	Short: "Save the connection flags as a profile",
+	Long: `Save -H, -P, -u and -d as a connection profile for use with --profile.
+A password is only stored when given with -p; otherwise it is read from
+MYSQL_PWD when the profile is used. Saving under an existing name replaces
+that profile.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runConfigSave,
+}
+
+var configRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a saved connection profile",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigRemove,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{dumpCmd, listCmd} {
+		cmd.Flags().StringVar(&profileName, "profile", "", "Connect with a saved profile (see dbdump config list); explicit flags override its values")
+	}
+	configSaveCmd.Flags().StringSliceVar(&profileTags, "tag", []string{}, "Tag the profile (repeatable, e.g. production)")
+
+	configCmd.AddCommand(configSaveCmd)
+	configCmd.AddCommand(configRemoveCmd)
+}
+
+// applyProfile fills the connection flags from --profile, leaving any flag
+// given on the command line as it is. A stored password takes precedence
+// over MYSQL_PWD, an auth: aws-iam profile enables --aws-iam-auth, and a
+// proxy: profile sets --proxy and --instance.
+func applyProfile(cmd *cobra.Command) error {
+	if profileName == "" {
+		return nil
+	}
+
+	profiles, err := config.LoadProfiles()
+	if err != nil {
+		return err
+	}
+	profile, err := findProfile(profiles, profileName, "--profile")
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	if !flags.Changed("host") && profile.Host != "" {
+		host = profile.Host
+	}
+	if !flags.Changed("port") && profile.Port != 0 {
+		port = profile.Port
+	}
+	if !flags.Changed("user") {
+		user = profile.User
+	}
+	if !flags.Changed("password") {
+		password = profile.Password
+	}
+	if !flags.Changed("database") {
+		dbName = profile.Database
+	}
+	if !flags.Changed("aws-iam-auth") && profile.Auth == authAWSIAM {
+		awsIAMAuth = true
+		if awsRegion == "" {
+			awsRegion = profile.Region
+		}
+	}
+	if err := database.CheckProxy(profile.Proxy); err != nil {
+		return &dberrors.ErrConfigInvalid{Source: "profile " + profile.Name, Problems: []string{err.Error()}}
+	}
+	if !flags.Changed("proxy") && profile.Proxy != "" {
+		proxyKind = profile.Proxy
+		if !flags.Changed("instance") {
+			proxyInstance = profile.Instance
+		}
+	}
+
+	activeProfile = profile
+	return nil
+}
+
+// findProfile looks up a profile by name, listing the saved names when
+// there is no such profile; source names where the name came from
+func findProfile(profiles *config.ProfilesConfig, name, source string) (*config.ConnectionProfile, error) {
+	if profile, err := profiles.GetProfile(name); err == nil {
+		return profile, nil
+	}
+
+	problem := fmt.Sprintf("no profile named %q; no profiles are saved yet (create one with dbdump config save <name>)", name)
+	if len(profiles.Profiles) > 0 {
+		names := make([]string, len(profiles.Profiles))
+		for i, profile := range profiles.Profiles {
+			names[i] = profile.Name
+		}
+		problem = fmt.Sprintf("no profile named %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	return nil, &dberrors.ErrConfigInvalid{Source: source, Problems: []string{problem}}
+}
+
+func runConfigSave(cmd *cobra.Command, args []string) error {
+	name := strings.TrimSpace(args[0])
+	if name == "" {
+		return fmt.Errorf("profile name cannot be empty")
+	}
+	if user == "" {
+		return fmt.Errorf("database user is required (use -u or --user)")
+	}
+	if err := validateProxyFlags(); err != nil {
+		return err
+	}
+
+	profile := config.ConnectionProfile{
+		Name:     name,
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		Database: dbName,
+		Tags:     profileTags,
+		Proxy:    proxyKind,
+		Instance: proxyInstance,
+	}
+	if awsIAMAuth {
+		profile.Auth = authAWSIAM
+		profile.Region = awsRegion
+		profile.Password = ""
+	}
+
+	replaced := false
+	err := config.UpdateProfiles(func(profiles *config.ProfilesConfig) error {
+		_, err := profiles.GetProfile(name)
+		replaced = err == nil
+		profiles.AddProfile(profile)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+
+	if replaced {
+		ui.PrintSuccess(fmt.Sprintf("Updated profile %s", profile))
+	} else {
+		ui.PrintSuccess(fmt.Sprintf("Saved profile %s", profile))
+	}
+	if profile.Password != "" {
+		ui.PrintInfo("The password is stored in plain text in the profiles file (readable only by you)")
+	}
+	return nil
+}
+
+func runConfigRemove(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	err := config.UpdateProfiles(func(profiles *config.ProfilesConfig) error {
+		if _, err := findProfile(profiles, name, "config remove"); err != nil {
+			return err
+		}
+		return profiles.RemoveProfile(name)
+	})
+	if err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Removed profile %s", name))
+	return nil
+}
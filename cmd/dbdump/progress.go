@@ -0,0 +1,45 @@
+package main
+
+import (
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	progressFlag     string
+	progressInterval = units.Duration{Value: 5 * time.Second}
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&progressFlag, "progress", string(ui.ProgressAuto), "Progress output: auto (bars on a terminal, plain lines otherwise), bar, plain (one \"db: 42% (1.2 GB/2.9 GB)\" line per update, for CI logs and concurrent runs) or none")
+	rootCmd.PersistentFlags().Var(&progressInterval, "progress-interval", "Minimum time between plain progress lines of one operation (e.g. 10s)")
+	rootCmd.PersistentPreRunE = configureProgress
+}
+
+// configureProgress applies --progress and --no-progress, --max-memory, and
+// the prompt answers, before a command runs
+func configureProgress(cmd *cobra.Command, args []string) error {
+	configureMemory()
+	if err := configurePrompts(); err != nil {
+		return err
+	}
+
+	mode, err := ui.ParseProgressMode(progressFlag)
+	if err != nil {
+		return &dberrors.ErrConfigInvalid{Source: "--progress", Err: err}
+	}
+	if noProgress {
+		mode = ui.ProgressNone
+	}
+	ui.SetProgressMode(mode, progressInterval.Value, dbName)
+	return nil
+}
+
+// progressEnabled reports whether progress should be shown at all
+func progressEnabled() bool {
+	return ui.CurrentProgressMode() != ui.ProgressNone
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// proxyKind is the local proxy the connection goes through, and
+	// proxyInstance the server behind it
+	proxyKind     string
+	proxyInstance string
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{dumpCmd, configSaveCmd} {
+		cmd.Flags().StringVar(&proxyKind, "proxy", "", "The host is a local proxy to a remote server: cloudsql (Cloud SQL Auth Proxy) or generic")
+		cmd.Flags().StringVar(&proxyInstance, "instance", "", "Name of the server behind --proxy, shown in headers, summaries and history (e.g. project:region:instance)")
+	}
+}
+
+// validateProxyFlags checks --proxy and --instance
+func validateProxyFlags() error {
+	if err := database.CheckProxy(proxyKind); err != nil {
+		return &dberrors.ErrConfigInvalid{Source: "--proxy", Problems: []string{err.Error()}}
+	}
+	if proxyInstance != "" && proxyKind == "" {
+		return fmt.Errorf("--instance names the server behind a proxy and needs --proxy")
+	}
+	return nil
+}
+
+// localServer reports whether the server on host runs on this machine, for
+// --auto-tune: a loopback address that isn't a proxy
+func localServer(host string) bool {
+	return proxyKind == "" && database.NormalizeHost(host) == "localhost"
+}
+
+// applyProxy marks conn as going through the proxy of --proxy, if any
+func applyProxy(conn *database.Connection) {
+	conn.Proxy, conn.Instance = proxyKind, proxyInstance
+}
+
+// detectProxy looks for a Cloud SQL Auth Proxy behind a loopback address
+// without a proxy hint, reporting whether one was found
+func detectProxy(inspector *database.Inspector, conn *database.Connection) bool {
+	if conn.Proxy != "" || database.NormalizeHost(conn.Host) != "localhost" {
+		return false
+	}
+	kind, err := inspector.DetectProxy()
+	if err != nil {
+		diag.Warnf("%v", err)
+		return false
+	}
+	if kind == "" {
+		return false
+	}
+	proxyKind, conn.Proxy = kind, kind
+	ui.PrintInfo(fmt.Sprintf("%s:%d is %s; set proxy: %s and instance: on the profile (or --proxy and --instance) to name the instance",
+		conn.Host, conn.Port, database.ProxyName(kind), kind))
+	return true
+}
+
+// serverLabel names the server of conn for headers and summaries: the host,
+// or behind a proxy the instance and the proxy's address
+func serverLabel(conn *database.Connection) string {
+	switch {
+	case conn.Proxy == "":
+		return conn.Host
+	case conn.Instance == "":
+		return fmt.Sprintf("%s:%d (%s)", conn.Host, conn.Port, database.ProxyName(conn.Proxy))
+	}
+	return fmt.Sprintf("%s (through %s on %s:%d)", conn.Instance, database.ProxyName(conn.Proxy), conn.Host, conn.Port)
+}
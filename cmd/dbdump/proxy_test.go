@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/history"
+)
+
+// TestProxyHeuristics checks what a proxy hint changes: the server is
+// tuned as a remote one, replica detection is skipped where the proxy
+// blocks it, and the instance names the server
+func TestProxyHeuristics(t *testing.T) {
+	savedKind, savedInstance, savedAutoTune, savedConfigs, savedStop := proxyKind, proxyInstance, autoTune, configFiles, stopReplicaAt
+	defer func() {
+		proxyKind, proxyInstance, autoTune, configFiles, stopReplicaAt = savedKind, savedInstance, savedAutoTune, savedConfigs, savedStop
+	}()
+	t.Setenv("HOME", t.TempDir())
+	autoTune, configFiles, stopReplicaAt = true, nil, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"
+
+	tests := []struct {
+		name        string
+		host        string
+		proxy       string
+		instance    string
+		wantLocal   bool
+		wantReplica bool // whether the replica status is read
+		wantLabel   string
+		wantHistory string
+	}{
+		{
+			name:        "local server",
+			host:        "127.0.0.1",
+			wantLocal:   true,
+			wantReplica: true,
+			wantLabel:   "127.0.0.1",
+			wantHistory: "shop@127.0.0.1:3307",
+		},
+		{
+			name:        "remote server",
+			host:        "db.example.com",
+			wantReplica: true,
+			wantLabel:   "db.example.com",
+			wantHistory: "shop@db.example.com:3307",
+		},
+		{
+			name:        "cloud sql",
+			host:        "127.0.0.1",
+			proxy:       database.ProxyCloudSQL,
+			instance:    "acme:europe-west1:main",
+			wantLabel:   "acme:europe-west1:main (through the Cloud SQL Auth Proxy on 127.0.0.1:3307)",
+			wantHistory: "shop@acme:europe-west1:main",
+		},
+		{
+			name:        "cloud sql without instance",
+			host:        "localhost",
+			proxy:       database.ProxyCloudSQL,
+			wantLabel:   "localhost:3307 (the Cloud SQL Auth Proxy)",
+			wantHistory: "shop@localhost:3307",
+		},
+		{
+			name:        "tunnel",
+			host:        "127.0.0.1",
+			proxy:       database.ProxyGeneric,
+			instance:    "db-primary",
+			wantReplica: true,
+			wantLabel:   "db-primary (through a local proxy on 127.0.0.1:3307)",
+			wantHistory: "shop@db-primary",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyKind, proxyInstance = tt.proxy, tt.instance
+			if err := validateProxyFlags(); err != nil {
+				t.Fatal(err)
+			}
+			conn := &database.Connection{Host: tt.host, Port: 3307, Database: "shop"}
+			applyProxy(conn)
+
+			tuned, err := resolvePerformance(tt.host, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tuned.conditions == nil || tuned.conditions.Local != tt.wantLocal {
+				t.Errorf("tuned for %v, want local %v", tuned.conditions, tt.wantLocal)
+			}
+
+			if got := serverLabel(conn); got != tt.wantLabel {
+				t.Errorf("serverLabel() = %q, want %q", got, tt.wantLabel)
+			}
+			entry := history.Entry{Host: conn.Host, Port: conn.Port, Instance: conn.Instance, Database: conn.Database}
+			if got := historyServer(entry); got != tt.wantHistory {
+				t.Errorf("historyServer() = %q, want %q", got, tt.wantHistory)
+			}
+
+			err = checkReplicaProxy(conn)
+			if tt.wantReplica {
+				if err != nil {
+					t.Errorf("checkReplicaProxy() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "Cloud SQL") {
+				t.Errorf("checkReplicaProxy() = %v, want a refusal naming Cloud SQL", err)
+			}
+			// Refused before a statement is run: there is no database to run it on
+			if err := checkStopReplica(context.Background(), conn, nil); err == nil {
+				t.Error("checkStopReplica() accepted --stop-replica-at-gtid")
+			}
+			if pin, err := pinReplica(context.Background(), conn, nil); err == nil || pin != nil {
+				t.Errorf("pinReplica() = %v, %v; want a refusal", pin, err)
+			}
+		})
+	}
+}
+
+func TestValidateProxyFlags(t *testing.T) {
+	savedKind, savedInstance := proxyKind, proxyInstance
+	defer func() {
+		proxyKind, proxyInstance = savedKind, savedInstance
+	}()
+
+	tests := []struct {
+		kind, instance string
+		wantErr        string
+	}{
+		{kind: "", instance: ""},
+		{kind: "cloudsql", instance: "acme:europe-west1:main"},
+		{kind: "generic", instance: ""},
+		{kind: "ssh", wantErr: "unknown proxy"},
+		{kind: "", instance: "db-primary", wantErr: "needs --proxy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind+"/"+tt.instance, func(t *testing.T) {
+			proxyKind, proxyInstance = tt.kind, tt.instance
+			err := validateProxyFlags()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateProxyFlags() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateProxyFlags() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
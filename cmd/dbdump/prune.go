@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/retention"
+	"github.com/helgesverre/dbdump/internal/store"
+	"github.com/helgesverre/dbdump/internal/tags"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/ui/prompt"
+	"github.com/helgesverre/dbdump/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneKeepLast   int
+	pruneKeepWithin units.Duration
+	pruneKeepTags   []string
+	pruneDryRun     bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune [directory]",
+	Short: "Delete old dumps according to a retention policy",
+	Long: `Delete dumps (with their parts and metadata sidecars) in a directory that fall
+outside the retention policy. Dumps are found through their .meta.json sidecars
+and limits apply per source database; use -d to prune only one database.
+
+Dumps matching a --keep-tag rule follow that rule instead of --keep-last and
+--keep-within: "purpose=release" keeps them forever, "purpose=release:3" keeps
+the three most recent.
+
+With --store, the policy applies to the snapshots of a content-addressed store
+(see dbdump store) and chunks no remaining snapshot uses are deleted.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPrune,
+}
+
+func init() {
+	pruneCmd.Flags().IntVar(&pruneKeepLast, "keep-last", 0, "Keep the N most recent dumps of each database")
+	pruneCmd.Flags().Var(&pruneKeepWithin, "keep-within", "Keep dumps younger than this (e.g. 72h, 30d, 2w)")
+	pruneCmd.Flags().StringArrayVar(&pruneKeepTags, "keep-tag", []string{}, "Retention for dumps with a tag: key=value (forever) or key=value:N (repeatable)")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show what would be deleted without deleting")
+	pruneCmd.Flags().StringVar(&storeDir, "store", "", "Prune the snapshots of this store instead of a directory")
+	rootCmd.AddCommand(pruneCmd)
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	policy, err := buildRetentionPolicy()
+	if err != nil {
+		return err
+	}
+
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	var st *store.Store
+	if storeDir != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("give either a directory or --store, not both")
+		}
+		if st, err = openStore(); err != nil {
+			return err
+		}
+		dir = st.Dir()
+	}
+
+	var dumps []retention.Dump
+	var sidecars map[string]*metadata.Metadata
+	if st != nil {
+		dumps, err = findSnapshots(st)
+	} else {
+		dumps, sidecars, err = findDumps(dir)
+	}
+	if err != nil {
+		return err
+	}
+	if len(dumps) == 0 {
+		fmt.Printf("No dumps with metadata found in %s\n", dir)
+		return nil
+	}
+
+	decisions := retention.Apply(dumps, policy, time.Now())
+	var doomed []retention.Decision
+	fmt.Println()
+	for _, decision := range decisions {
+		action := "keep  "
+		if !decision.Keep {
+			action = "delete"
+			doomed = append(doomed, decision)
+		}
+		fmt.Printf("  %s  %s  %s  (%s)\n", action,
+			decision.Dump.CreatedAt.Local().Format("2006-01-02 15:04"),
+			filepath.Base(decision.Dump.Path), decision.Reason)
+	}
+	fmt.Println()
+
+	if len(doomed) == 0 {
+		ui.PrintSuccess("Nothing to prune")
+		return nil
+	}
+	if pruneDryRun {
+		ui.PrintInfo(fmt.Sprintf("Would delete %d dump(s)", len(doomed)))
+		return nil
+	}
+	ok, err := prompt.Confirm(prompt.PruneDelete, fmt.Sprintf("Delete %d dump(s)?", len(doomed)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		ui.PrintInfo("Nothing deleted")
+		return nil
+	}
+
+	deleted := 0
+	for _, decision := range doomed {
+		if st != nil {
+			err = st.Remove(decision.Dump.Path)
+		} else {
+			err = deleteDump(decision.Dump.Path, sidecars[decision.Dump.Path])
+		}
+		if err != nil {
+			diag.Warnf("%v", err)
+			continue
+		}
+		deleted++
+	}
+	ui.PrintSuccess(fmt.Sprintf("Deleted %d dump(s)", deleted))
+
+	if st != nil {
+		chunks, freed, err := st.Collect()
+		if err != nil {
+			return fmt.Errorf("failed to delete unused chunks: %w", err)
+		}
+		ui.PrintSuccess(fmt.Sprintf("Deleted %d unused chunk(s), freeing %s", chunks, database.FormatBytes(freed)))
+	}
+	return nil
+}
+
+// findSnapshots lists a store's snapshots as retention candidates, with the
+// snapshot ID as path
+func findSnapshots(st *store.Store) ([]retention.Dump, error) {
+	snapshots, err := st.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	var dumps []retention.Dump
+	for _, snapshot := range snapshots {
+		meta := snapshot.Metadata
+		if meta == nil {
+			diag.Warnf("skipping snapshot %s: it has no metadata", snapshot.ID)
+			continue
+		}
+		if dbName != "" && (meta.Source.Database != dbName || !database.SameServer(meta.Source.Host, meta.Source.Port, host, port)) {
+			continue
+		}
+		dumps = append(dumps, retention.Dump{
+			Path:      snapshot.ID,
+			Source:    fmt.Sprintf("%s:%d/%s", database.NormalizeHost(meta.Source.Host), meta.Source.Port, meta.Source.Database),
+			CreatedAt: snapshot.CreatedAt,
+			Tags:      meta.Tags,
+		})
+	}
+	return dumps, nil
+}
+
+// buildRetentionPolicy validates the retention flags
+func buildRetentionPolicy() (retention.Policy, error) {
+	policy := retention.Policy{KeepLast: pruneKeepLast}
+	var problems []string
+
+	if pruneKeepLast < 0 {
+		problems = append(problems, "--keep-last must not be negative")
+	}
+	policy.KeepWithin = pruneKeepWithin.Value
+	for _, spec := range pruneKeepTags {
+		rule, err := parseTagRule(spec)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("--keep-tag: %v", err))
+			continue
+		}
+		policy.TagRules = append(policy.TagRules, rule)
+	}
+	if policy.KeepLast == 0 && policy.KeepWithin == 0 && len(problems) == 0 {
+		problems = append(problems, "set --keep-last or --keep-within; without them every untagged dump would be deleted")
+	}
+
+	if len(problems) > 0 {
+		return policy, &dberrors.ErrConfigInvalid{Source: "prune flags", Problems: problems}
+	}
+	return policy, nil
+}
+
+// parseTagRule parses key=value (keep forever) or key=value:N
+func parseTagRule(spec string) (retention.TagRule, error) {
+	rule := retention.TagRule{}
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		if n, err := strconv.Atoi(spec[i+1:]); err == nil {
+			if n <= 0 {
+				return rule, fmt.Errorf("count in %q must be positive (omit it to keep forever)", spec)
+			}
+			rule.Keep = n
+			spec = spec[:i]
+		}
+	}
+
+	key, value, err := tags.ParseTag(spec)
+	if err != nil {
+		return rule, err
+	}
+	rule.Tags = map[string]string{key: value}
+	return rule, nil
+}
+
+// findDumps returns the dumps in dir that have a metadata sidecar (of the
+// selected database, if -d is given), with the chain of increments each
+// belongs to, and maps each dump to its sidecar
+func findDumps(dir string) ([]retention.Dump, map[string]*metadata.Metadata, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+metadata.SidecarSuffix))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var dumps []retention.Dump
+	sidecars := make(map[string]*metadata.Metadata)
+	for _, sidecar := range matches {
+		meta, err := metadata.Load(sidecar)
+		if err != nil {
+			diag.Warnf("skipping %s: %v", sidecar, err)
+			continue
+		}
+		if dbName != "" && (meta.Source.Database != dbName || !database.SameServer(meta.Source.Host, meta.Source.Port, host, port)) {
+			continue
+		}
+
+		path := strings.TrimSuffix(sidecar, metadata.SidecarSuffix)
+		dump := retention.Dump{
+			Path:      path,
+			Source:    fmt.Sprintf("%s:%d/%s", database.NormalizeHost(meta.Source.Host), meta.Source.Port, meta.Source.Database),
+			CreatedAt: meta.CreatedAt,
+			Tags:      meta.Tags,
+		}
+		if meta.Chain != nil {
+			dump.Chain = meta.Chain.Base
+		}
+		dumps = append(dumps, dump)
+		sidecars[path] = meta
+	}
+	return dumps, sidecars, nil
+}
+
+// deleteDump removes a dump, its parts and finally its sidecar, so a failed
+// deletion leaves the dump discoverable for the next prune
+func deleteDump(path string, meta *metadata.Metadata) error {
+	files := []string{path}
+	for _, part := range meta.Parts {
+		files = append(files, filepath.Join(filepath.Dir(path), part.File))
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %w", file, err)
+		}
+	}
+	if err := os.Remove(metadata.SidecarPath(path)); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", metadata.SidecarPath(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/spf13/cobra"
+)
+
+// resolveReadOnly decides whether the inspection connection is read-only: an
+// explicit --read-only-source wins, otherwise it is on when the --profile in
+// use, or a saved profile for the same database and server, is tagged
+// "production". Behind a proxy the same local address can reach different
+// instances, so a profile naming another instance doesn't count.
+func resolveReadOnly(cmd *cobra.Command) bool {
+	if cmd.Flags().Changed("read-only-source") {
+		return readOnlySource
+	}
+	if activeProfile != nil && activeProfile.HasTag("production") {
+		return true
+	}
+
+	profiles, err := config.LoadProfiles()
+	if err != nil {
+		diag.Warnf("%v", err)
+		return false
+	}
+
+	profile := profiles.FindByTarget(dbName, func(profile *config.ConnectionProfile) bool {
+		if proxyInstance != "" && profile.Instance != "" && profile.Instance != proxyInstance {
+			return false
+		}
+		return database.SameServer(profile.Host, profile.Port, host, port)
+	})
+	return profile != nil && profile.HasTag("production")
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/ui/prompt"
+)
+
+// Names of the two selection sources, as shown to the user
+const (
+	sourceConfig = "config"
+	sourceSaved  = "saved selection"
+)
+
+// reconcileSides maps the answers of --assume reconcile to the sources
+var reconcileSides = map[string]string{
+	"config":         sourceConfig,
+	"project-config": sourceConfig,
+	"saved":          sourceSaved,
+}
+
+// selectionConflictWinner returns selection_conflict from the project or
+// global config: which source --auto follows when they disagree
+func selectionConflictWinner() (string, error) {
+	winner, source := "", ""
+	if globalConfig, err := config.LoadGlobalConfig(); err == nil && globalConfig != nil && globalConfig.SelectionConflict != "" {
+		winner, source = globalConfig.SelectionConflict, "~/.dbdump.yaml"
+	}
+	if len(configFiles) > 0 {
+		if projectConfig, err := loadProjectConfig(); err == nil && projectConfig.SelectionConflict != "" {
+			winner, source = projectConfig.SelectionConflict, configSource()
+		}
+	}
+
+	switch winner {
+	case "", "config":
+		return sourceConfig, nil
+	case "saved":
+		return sourceSaved, nil
+	}
+	return "", &dberrors.ErrConfigInvalid{
+		Source:   source,
+		Problems: []string{fmt.Sprintf("selection_conflict must be \"config\" or \"saved\", got %q", winner)},
+	}
+}
+
+// selectionDisagreement is where the config rules and the selection last
+// confirmed in the table selector for this database disagree
+type selectionDisagreement struct {
+	saved      *config.SavedSelection
+	names      []string
+	remembered patterns.SelectionSource
+	conflicts  []patterns.SelectionConflict
+}
+
+// diffSavedSelection compares the exclusions of the config rules with the
+// saved selection, returning nil when there is none or they agree
+func diffSavedSelection(tables []database.TableInfo, preSelected []string) *selectionDisagreement {
+	saved, err := config.LoadSelection(host, port, dbName)
+	if err != nil {
+		diag.Warnf("%v", err)
+		return nil
+	}
+	if saved == nil {
+		return nil
+	}
+
+	names := make([]string, len(tables))
+	for i, info := range tables {
+		names[i] = info.Name
+	}
+	rules := patterns.SelectionSource{Name: sourceConfig, Excluded: preSelected}
+	remembered := patterns.SelectionSource{Name: sourceSaved, Excluded: saved.Excluded}
+	conflicts := patterns.DiffSelections(names, rules, remembered)
+	if len(conflicts) == 0 {
+		return nil
+	}
+	return &selectionDisagreement{saved: saved, names: names, remembered: remembered, conflicts: conflicts}
+}
+
+// follow returns the exclusions of the source that wins, given preSelected
+// from the config rules
+func (d *selectionDisagreement) follow(winner string, preSelected []string) []string {
+	if winner == sourceSaved {
+		return patterns.ResolveSelections(d.names, d.remembered, nil)
+	}
+	return preSelected
+}
+
+// question is the reconcile prompt for the disagreement
+func (d *selectionDisagreement) question() string {
+	return fmt.Sprintf("The config and the selection saved on %s disagree about %d tables. Follow the config or the saved selection?",
+		d.saved.SavedAt.Local().Format("2006-01-02 15:04"), len(d.conflicts))
+}
+
+// reconcileSavedSelection returns the data exclusions --auto starts from:
+// those of the source --assume reconcile or else selection_conflict names
+// when the config rules and the saved selection disagree, with a warning
+// listing the tables
+func reconcileSavedSelection(tables []database.TableInfo, preSelected []string) ([]string, error) {
+	disagreement := diffSavedSelection(tables, preSelected)
+	if disagreement == nil {
+		return preSelected, nil
+	}
+
+	winner, err := selectionConflictWinner()
+	if err != nil {
+		return nil, err
+	}
+	decidedBy := "selection_conflict"
+	// --auto asks nothing, so --yes has nothing to answer here
+	if answer, ok := prompt.Assumed(prompt.Reconcile, disagreement.question(), ""); ok {
+		winner, decidedBy = reconcileSides[answer], "--assume reconcile"
+	}
+	tablesList := make([]string, len(disagreement.conflicts))
+	for i, conflict := range disagreement.conflicts {
+		tablesList[i] = fmt.Sprintf("%s (%s excludes data)", conflict.Table, conflict.ExcludedBy)
+	}
+	ui.PrintWarning(fmt.Sprintf("The config and the selection saved on %s disagree about %d tables; following the %s (%s): %s",
+		disagreement.saved.SavedAt.Local().Format("2006-01-02 15:04"), len(tablesList), winner, decidedBy, strings.Join(tablesList, ", ")))
+
+	return disagreement.follow(winner, preSelected), nil
+}
+
+// reconcileAnswer is the --assume reconcile answer naming a source
+func reconcileAnswer(source string) string {
+	if source == sourceSaved {
+		return "saved"
+	}
+	return "config"
+}
+
+// markSavedSelection returns the exclusions the table selector starts from
+// and, when the config rules and the saved selection disagree, the
+// reconciliation the selector asks before showing the list. An answer given
+// up front (--assume reconcile, or --yes following selection_conflict)
+// settles it without asking. The selector is already drawn, so instead of
+// printing, each disagreement and how it was settled is noted in reasons,
+// shown next to the table.
+func markSavedSelection(tables []database.TableInfo, preSelected []string, reasons map[string]string) ([]string, *ui.SelectionReconcile, error) {
+	disagreement := diffSavedSelection(tables, preSelected)
+	if disagreement == nil {
+		return preSelected, nil, nil
+	}
+
+	winner, err := selectionConflictWinner()
+	if err != nil {
+		return nil, nil, err
+	}
+	answer, flag, settled := prompt.Answer(prompt.Reconcile, reconcileAnswer(winner))
+	for _, conflict := range disagreement.conflicts {
+		note := fmt.Sprintf("the %s excludes its data, the %s dumps it", conflict.ExcludedBy, conflict.DumpedBy)
+		if settled {
+			note += fmt.Sprintf("; following the %s (%s)", reconcileSides[answer], flag)
+		}
+		if existing := reasons[conflict.Table]; existing != "" {
+			note = existing + "; " + note
+		}
+		reasons[conflict.Table] = note
+	}
+	if settled {
+		return disagreement.follow(reconcileSides[answer], preSelected), nil, nil
+	}
+	return preSelected, &ui.SelectionReconcile{
+		First:     sourceConfig,
+		Second:    sourceSaved,
+		Conflicts: disagreement.conflicts,
+	}, nil
+}
+
+// saveSelection remembers the exclusions confirmed in the table selector
+func saveSelection(excluded []string) {
+	err := config.SaveSelection(config.SavedSelection{
+		Host:     host,
+		Port:     port,
+		Database: dbName,
+		Excluded: excluded,
+		SavedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		diag.Warnf("failed to save selection: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// sentinel is a password that must never reach any output. It holds the
+// characters that end a naive DSN match (@, :, /, parentheses).
+const sentinel = "S3ntinel@p:a/ss(w)rd"
+
+// sentinelConnection is a connection with the sentinel password
+func sentinelConnection() database.Connection {
+	return database.Connection{Host: "db.internal", Port: 3306, User: "app", Password: sentinel, Database: "shop"}
+}
+
+// checkNoSentinel fails if out contains the sentinel or its distinctive part
+func checkNoSentinel(t *testing.T, surface, out string) {
+	t.Helper()
+	for _, leak := range []string{sentinel, "S3ntinel", "ss(w)rd"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("%s leaks the password (%q):\n%s", surface, leak, out)
+			return
+		}
+	}
+}
+
+func TestPasswordNeverPrinted(t *testing.T) {
+	conn := sentinelConnection()
+	profile := config.ConnectionProfile{Name: "prod", Host: conn.Host, Port: conn.Port, User: conn.User, Password: sentinel}
+
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s"} {
+		checkNoSentinel(t, "Connection with "+verb, fmt.Sprintf(verb, conn))
+		checkNoSentinel(t, "*Connection with "+verb, fmt.Sprintf(verb, &conn))
+		checkNoSentinel(t, "ConnectionProfile with "+verb, fmt.Sprintf(verb, profile))
+	}
+
+	var logs bytes.Buffer
+	slog.New(slog.NewTextHandler(&logs, nil)).Info("connecting", "connection", conn)
+	slog.New(slog.NewJSONHandler(&logs, nil)).Info("connecting", "connection", conn)
+	checkNoSentinel(t, "slog", logs.String())
+	if !strings.Contains(logs.String(), "db.internal") {
+		t.Errorf("slog output lacks the host:\n%s", logs.String())
+	}
+}
+
+func TestPasswordNotInMetadata(t *testing.T) {
+	conn := sentinelConnection()
+	tables := []database.TableInfo{{Name: "users", RowCount: 1}}
+	meta := buildMetadata(&conn, "8.0.36", tables, nil, nil, nil, &database.DumpResult{OutputFile: "shop.sql"})
+
+	path := filepath.Join(t.TempDir(), "shop.sql.meta.json")
+	if err := metadata.Write(path, meta); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNoSentinel(t, "metadata sidecar", string(data))
+}
+
+// TestPasswordNotInErrors runs errors that echo the DSN, as the driver's
+// do, through what main does with them: the NDJSON events, warnings and the
+// error printed to stderr
+func TestPasswordNotInErrors(t *testing.T) {
+	conn := sentinelConnection()
+	dsnErr := fmt.Errorf("failed to open database: invalid DSN %s: %w", conn.DSN(), errors.New("bad param"))
+	if !strings.Contains(dsnErr.Error(), sentinel) {
+		t.Fatal("the test error doesn't echo the password; the test proves nothing")
+	}
+
+	savedPassword, savedEventsFile, savedOutput, savedReported := password, eventsFile, diag.Output, warningsReported
+	defer func() {
+		password, eventsFile, diag.Output, warningsReported = savedPassword, savedEventsFile, savedOutput, savedReported
+		diag.Default.Reset()
+	}()
+	password = sentinel
+	eventsFile = filepath.Join(t.TempDir(), "events.ndjson")
+	var warnings bytes.Buffer
+	diag.Output = &warnings
+	warningsReported = true // keep the summary off stdout
+
+	if err := startEvents(); err != nil {
+		t.Fatal(err)
+	}
+	diag.Warnf("retrying: %v", dsnErr)
+	diag.Record("recorded: " + dsnErr.Error())
+
+	var stderr bytes.Buffer
+	code := finish(&dberrors.ErrConnectionFailed{Code: 1045, Err: dsnErr}, &stderr)
+	if want, _ := classifyError(&dberrors.ErrConnectionFailed{Code: 1045}); code != want {
+		t.Errorf("finish = %d, want the connection failure's code %d: masking must keep the error type", code, want)
+	}
+
+	events, err := os.ReadFile(eventsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNoSentinel(t, "NDJSON events", string(events))
+	checkNoSentinel(t, "warnings", warnings.String())
+	checkNoSentinel(t, "stderr", stderr.String())
+	for _, warning := range diag.Warnings() {
+		checkNoSentinel(t, "recorded warning", warning.Message)
+	}
+
+	// The messages are still there, masked
+	if !strings.Contains(string(events), `"type":"run_completed"`) || !strings.Contains(stderr.String(), "app:********@tcp(db.internal:3306)/shop") {
+		t.Errorf("masked error missing:\nevents: %s\nstderr: %s", events, stderr.String())
+	}
+	var lines int
+	for _, line := range bytes.Split(bytes.TrimSpace(events), []byte("\n")) {
+		if !json.Valid(line) {
+			t.Errorf("event isn't JSON: %s", line)
+		}
+		lines++
+	}
+	if lines < 4 {
+		t.Errorf("%d events, want run_started, two warnings and run_completed", lines)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// maxPrintedRenameIssues is how many rename issues are listed after a restore
+const maxPrintedRenameIssues = 20
+
+var (
+	renameDatabases []string
+	renamePrefixes  []string
+)
+
+func init() {
+	restoreCmd.Flags().StringArrayVar(&renameDatabases, "rename-database", nil, "Rename a database while restoring, as old=new (repeatable)")
+	restoreCmd.Flags().StringArrayVar(&renamePrefixes, "rename-prefix", nil, "Rename a table name prefix while restoring, as old=new (repeatable)")
+}
+
+// restoreRenamer builds the renamer for the --rename-* flags and a dump that
+// creates its own database, or nil when nothing is renamed
+func restoreRenamer(header *dumpfile.Header) (*dumpfile.Renamer, error) {
+	var problems []string
+	databases := make(map[string]string)
+	for _, value := range renameDatabases {
+		old, renamed, err := splitRename(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("--rename-database %q: %v", value, err))
+			continue
+		}
+		if _, ok := databases[old]; ok {
+			problems = append(problems, fmt.Sprintf("--rename-database %q: %s is renamed more than once", value, old))
+			continue
+		}
+		databases[old] = renamed
+	}
+	renameCreatedDatabase(databases, header)
+
+	var prefixes []dumpfile.PrefixRename
+	for _, value := range renamePrefixes {
+		old, renamed, err := splitRename(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("--rename-prefix %q: %v", value, err))
+			continue
+		}
+		prefixes = append(prefixes, dumpfile.PrefixRename{Old: old, New: renamed})
+	}
+
+	if len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "rename flags", Problems: problems}
+	}
+	if len(databases) == 0 && len(prefixes) == 0 {
+		return nil, nil
+	}
+	return dumpfile.NewRenamer(databases, prefixes), nil
+}
+
+// splitRename parses an old=new pair
+func splitRename(value string) (string, string, error) {
+	old, renamed, ok := strings.Cut(value, "=")
+	if !ok {
+		return "", "", fmt.Errorf("expected old=new")
+	}
+	old, renamed = strings.TrimSpace(old), strings.TrimSpace(renamed)
+	if old == "" || renamed == "" {
+		return "", "", fmt.Errorf("both the old and the new name are required")
+	}
+	if old == renamed {
+		return "", "", fmt.Errorf("old and new name are the same")
+	}
+	return old, renamed, nil
+}
+
+// printRenameSummary describes what was renamed and lists the places where
+// an old name was left unchanged because it couldn't be translated safely
+func printRenameSummary(renamer *dumpfile.Renamer) {
+	for _, value := range renameDatabases {
+		ui.PrintSuccess(fmt.Sprintf("Renamed database %s", strings.Replace(value, "=", " → ", 1)))
+	}
+	for _, value := range renamePrefixes {
+		ui.PrintSuccess(fmt.Sprintf("Renamed table prefix %s", strings.Replace(value, "=", " → ", 1)))
+	}
+
+	issues := renamer.Issues()
+	if len(issues) == 0 {
+		return
+	}
+	total := len(issues) + renamer.DroppedIssues()
+	diag.Warnf("%d place(s) in the dump mention an old name that was not rewritten; check them after the restore", total)
+	for i, issue := range issues {
+		if i == maxPrintedRenameIssues {
+			fmt.Printf("  ... and %d more\n", total-i)
+			break
+		}
+		fmt.Printf("  line %d: %s\n", issue.Line, issue.Message)
+	}
+}
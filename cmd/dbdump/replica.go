@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/prompt"
+	"github.com/helgesverre/dbdump/internal/units"
+)
+
+var (
+	stopReplicaAt      string
+	stopReplicaTimeout = units.Duration{Value: 10 * time.Minute}
+	stopReplicaConfirm bool
+)
+
+func init() {
+	dumpCmd.Flags().StringVar(&stopReplicaAt, "stop-replica-at-gtid", "", "Advanced: stop the replica being dumped right after this GTID set, dump it, then resume replication")
+	dumpCmd.Flags().Var(&stopReplicaTimeout, "stop-replica-timeout", "Time allowed for the replica to reach --stop-replica-at-gtid (0 for no limit)")
+	dumpCmd.Flags().BoolVar(&stopReplicaConfirm, "stop-replica-confirm", false, "Stop replication for --stop-replica-at-gtid without asking")
+}
+
+// validateStopReplicaFlags checks the --stop-replica-* flags before connecting
+func validateStopReplicaFlags() error {
+	if stopReplicaAt == "" {
+		if stopReplicaConfirm {
+			return fmt.Errorf("--stop-replica-confirm is only used with --stop-replica-at-gtid")
+		}
+		return nil
+	}
+	if schemaDelta {
+		return fmt.Errorf("--stop-replica-at-gtid cannot be combined with --schema-delta")
+	}
+	return database.ValidateGTIDSet(stopReplicaAt)
+}
+
+// checkStopReplica refuses --stop-replica-at-gtid on a server that is not a
+// replica and asks for confirmation, before the tables are selected. A dry
+// run only checks.
+func checkStopReplica(ctx context.Context, conn *database.Connection, db *sql.DB) error {
+	if stopReplicaAt == "" {
+		return nil
+	}
+	if err := checkReplicaProxy(conn); err != nil {
+		return err
+	}
+	status, err := database.CheckReplica(ctx, database.NewReplicaControl(db))
+	if err != nil {
+		return err
+	}
+	if dryRun || stopReplicaConfirm {
+		return nil
+	}
+
+	ok, err := prompt.Confirm(prompt.StopReplica, fmt.Sprintf("Stop replication from %s on %s right after %s for the dump? It resumes when the dump ends",
+		status.Source, serverLabel(conn), stopReplicaAt))
+	if err != nil {
+		return fmt.Errorf("%w, or use --stop-replica-confirm", err)
+	}
+	if !ok {
+		return fmt.Errorf("replication not stopped; nothing dumped")
+	}
+	return nil
+}
+
+// pinReplica stops the replica at --stop-replica-at-gtid, printing what it
+// waits for; the caller must resume the returned pin (nil without the flag)
+func pinReplica(ctx context.Context, conn *database.Connection, db *sql.DB) (*database.ReplicaPin, error) {
+	if stopReplicaAt == "" {
+		return nil, nil
+	}
+	// The proxy may have been detected after checkStopReplica
+	if err := checkReplicaProxy(conn); err != nil {
+		return nil, err
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Waiting for the replica to apply %s", stopReplicaAt))
+	var lastReport time.Time
+	pin, err := database.PinReplica(ctx, database.NewReplicaControl(db), stopReplicaAt, database.PinOptions{
+		Timeout: stopReplicaTimeout.Value,
+		OnProgress: func(missing string) {
+			if !progressEnabled() || time.Since(lastReport) < progressInterval.Value {
+				return
+			}
+			lastReport = time.Now()
+			ui.PrintInfo(fmt.Sprintf("Replica still has to apply %s", missing))
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop the replica at %s: %w", stopReplicaAt, err)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Replica stopped right after %s", stopReplicaAt))
+	return pin, nil
+}
+
+// checkReplicaProxy refuses --stop-replica-at-gtid through a proxy that
+// blocks replica detection, before any statement fails on it
+func checkReplicaProxy(conn *database.Connection) error {
+	if conn.ControlsReplication() {
+		return nil
+	}
+	return fmt.Errorf("--stop-replica-at-gtid can't be used through %s: Cloud SQL doesn't let users read the replica status or stop replication", database.ProxyName(conn.Proxy))
+}
+
+// resumeReplica resumes replication after the dump, whatever its outcome
+func resumeReplica(pin *database.ReplicaPin) error {
+	if pin == nil {
+		return nil
+	}
+	if err := pin.Resume(); err != nil {
+		ui.PrintFailure(fmt.Sprintf("Replication was NOT resumed on %s; run START REPLICA SQL_THREAD there", host))
+		return fmt.Errorf("failed to resume replication: %w", err)
+	}
+	if pin.WasRunning() {
+		ui.PrintSuccess("Replication resumed")
+	} else {
+		ui.PrintInfo("Replication left stopped, as it was before the dump")
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/ui/prompt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Restore flags
+	startOffset     int64
+	allowSameSource bool
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore a dump file into a database",
+	Long: `Restore a dump file (plain, gzip- or zstd-compressed) into a database using the mysql
+client, with progress reporting. When a statement fails, the byte offset and
+line number are reported so the restore can be resumed with --start-offset
+after fixing the problem. Offsets always refer to the uncompressed SQL.
+
+--table restores only some tables (their structure, data and triggers);
+--interactive picks them in the table selector.
+
+An increment written with dbdump dump --append-since-last is restored with
+the base dump and the increments before it, in order, after checking that
+their checksums and watermark ranges line up; --increment-only applies it
+alone onto a database holding the rest of the chain.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestore,
+}
+
+func init() {
+	restoreCmd.Flags().Int64Var(&startOffset, "start-offset", 0, "Resume from this byte offset (skips forward to the next statement boundary)")
+	restoreCmd.Flags().BoolVar(&noProgress, "no-progress", false, "Disable progress indicator (same as --progress none)")
+	restoreCmd.Flags().BoolVar(&allowSameSource, "allow-same-source", false, "Allow restoring into the database the dump was taken from without confirmation")
+
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	if err := database.CheckMySQLClient(); err != nil {
+		return fmt.Errorf("mysql client is required: %w", err)
+	}
+
+	resolvePassword()
+
+	// Validate required flags
+	if user == "" {
+		return fmt.Errorf("database user is required (use -u or --user)")
+	}
+	if dbName == "" {
+		return fmt.Errorf("target database name is required (use -d or --database)")
+	}
+	if startOffset < 0 {
+		return fmt.Errorf("--start-offset must not be negative")
+	}
+	inputFile, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	inputFile, size, err := resolveDumpInput(inputFile)
+	if err != nil {
+		return err
+	}
+	// An increment is restored after the base dump and increments before it
+	files, chainSize, err := restoreChain(inputFile)
+	if err != nil {
+		return err
+	}
+	if len(files) > 1 {
+		size = chainSize
+	}
+
+	// A dump that creates its own database is restored without a default
+	// database, so the target need not exist; a resumed restore has
+	// skipped those statements and connects to the target as usual
+	header, err := dumpfile.ReadHeader(files[0])
+	if err != nil {
+		return fmt.Errorf("failed to read dump header: %w", err)
+	}
+	renamer, err := restoreRenamer(header)
+	if err != nil {
+		return err
+	}
+	filter, err := restoreFilter(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+
+	conn := &database.Connection{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		Database: dbName,
+	}
+
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := checkPartialDump(file); err != nil {
+			return err
+		}
+	}
+	if err := checkSameSource(inputFile, conn); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := checkTargetPacketLimit(cmd.Context(), file, conn); err != nil {
+			return err
+		}
+	}
+	if err := checkIncrementTarget(cmd.Context(), inputFile, conn); err != nil {
+		return err
+	}
+	if err := checkTargetNameCase(cmd.Context(), inputFile, conn); err != nil {
+		return err
+	}
+	checkTargetTimeZone(cmd.Context(), inputFile, conn)
+	checkTargetVariables(cmd.Context(), inputFile, conn)
+	checkTargetMigrations(cmd.Context(), inputFile, conn)
+
+	if startOffset > 0 {
+		ui.PrintInfo(fmt.Sprintf("Resuming from byte offset %d (next statement boundary)", startOffset))
+	}
+	ui.PrintInfo(fmt.Sprintf("Restoring %s into %s", inputFile, dbName))
+
+	var progress *ui.ProgressTracker
+	var lastDescribe time.Time
+	currentTable := ""
+	started := time.Now()
+	var restored int64 // file bytes of the chain's files already restored
+
+	options := &database.RestoreOptions{
+		Connection:  conn,
+		InputFile:   inputFile,
+		StartOffset: startOffset,
+		Renamer:     renamer,
+		Filter:      filter,
+		Context:     cmd.Context(),
+
+		CreatesDatabase: header.CreatesDatabase && startOffset == 0,
+	}
+
+	if !progressEnabled() {
+		options.OnTable = func(table string) {
+			ui.PrintInfo(fmt.Sprintf("Restoring %s", table))
+		}
+	} else {
+		progress = ui.NewProgressTracker("Restoring", size)
+		options.OnTable = func(table string) {
+			currentTable = table
+			progress.Describe(fmt.Sprintf("Restoring %s", table))
+		}
+		options.OnProgress = func(fileBytes, sqlBytes int64) {
+			_ = progress.Set64(restored + fileBytes)
+
+			// For compressed input the bar tracks compressed bytes; show the
+			// SQL throughput alongside, refreshed at most twice per second
+			if fileBytes != sqlBytes && time.Since(lastDescribe) > 500*time.Millisecond {
+				lastDescribe = time.Now()
+				rate := float64(sqlBytes) / time.Since(started).Seconds()
+				progress.Describe(fmt.Sprintf("Restoring %s (%s/s SQL)", currentTable, database.FormatBytes(int64(rate))))
+			}
+		}
+	}
+
+	var sent, resumedAt int64
+	for i, file := range files {
+		options.InputFile = file
+		options.CreatesDatabase = options.CreatesDatabase && i == 0
+		result, restoreErr := database.NewRestorer(options).Restore()
+		if restoreErr != nil {
+			err = restoreErr
+			if len(files) > 1 {
+				err = &chainRestoreError{File: file, Increment: i > 0, Err: restoreErr}
+			}
+			break
+		}
+		sent += result.BytesSent
+		if i == 0 {
+			resumedAt = result.StartOffset
+		}
+		if info, statErr := os.Stat(file); statErr == nil {
+			restored += info.Size()
+		}
+	}
+	if progress != nil {
+		_ = progress.Finish()
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	ui.PrintSuccess(fmt.Sprintf("Restore complete: %s of SQL applied to %s", database.FormatBytes(sent), dbName))
+	if len(files) > 1 {
+		ui.PrintSuccess(fmt.Sprintf("Applied %s and %d increment(s)", filepath.Base(files[0]), len(files)-1))
+	}
+	if resumedAt > 0 {
+		ui.PrintSuccess(fmt.Sprintf("Resumed at byte offset %d", resumedAt))
+	}
+	ui.PrintSuccess(fmt.Sprintf("Duration: %s", time.Since(started).Round(time.Second)))
+	if renamer != nil {
+		printRenameSummary(renamer)
+	}
+	if filter != nil {
+		reportRestoreFilter(filter)
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// resolveDumpInput resolves the restore input to a single dump file or a split
+// dump (given by its base name or any part) and returns its total size. The
+// parts of a split dump are checked against the sidecar's part list.
+func resolveDumpInput(inputFile string) (string, int64, error) {
+	parts, err := dumpfile.FindParts(inputFile)
+	if err != nil {
+		return "", 0, fmt.Errorf("cannot read dump file: %w", err)
+	}
+
+	if parts == nil {
+		info, err := os.Stat(inputFile)
+		if err != nil {
+			return "", 0, fmt.Errorf("cannot read dump file: %w", err)
+		}
+		return inputFile, info.Size(), nil
+	}
+
+	base := dumpfile.BasePath(inputFile)
+	var size int64
+	sizes := make(map[string]int64, len(parts))
+	for _, part := range parts {
+		info, err := os.Stat(part)
+		if err != nil {
+			return "", 0, fmt.Errorf("cannot read dump part: %w", err)
+		}
+		sizes[filepath.Base(part)] = info.Size()
+		size += info.Size()
+	}
+
+	meta, err := metadata.LoadForDump(base)
+	if err != nil {
+		diag.Warnf("%v", err)
+	}
+	if meta != nil && len(meta.Parts) > 0 {
+		for _, part := range meta.Parts {
+			actual, ok := sizes[part.File]
+			if !ok {
+				return "", 0, fmt.Errorf("split dump is incomplete: %s is missing (%d parts expected)", part.File, len(meta.Parts))
+			}
+			if actual != part.Size {
+				return "", 0, fmt.Errorf("split dump part %s is %d bytes, expected %d (truncated or modified)", part.File, actual, part.Size)
+			}
+		}
+		if len(parts) != len(meta.Parts) {
+			return "", 0, fmt.Errorf("split dump has %d parts but its metadata lists %d", len(parts), len(meta.Parts))
+		}
+	} else {
+		ui.PrintWarning("No metadata for this split dump; only the part numbering can be checked for completeness")
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Restoring split dump: %d parts, %s", len(parts), database.FormatBytes(size)))
+	return base, size, nil
+}
+
+// defaultNamePattern matches the default dump file name {database}_{timestamp}.sql,
+// with the legacy or the iso8601-basic timestamp
+var defaultNamePattern = regexp.MustCompile(`^(.+)_\d{8}(?:_\d{6}|T\d{6}(?:Z|[+-]\d{4}))\.sql(?:\.gz)?$`)
+
+// checkSameSource guards against restoring a dump over the database it was taken from.
+// With a metadata sidecar the host, port and database must all match and an
+// explicit confirmation (or --allow-same-source) is required. Without one, only
+// the database name from the dump header or file name is compared and a
+// warning is printed.
+func checkSameSource(inputFile string, target *database.Connection) error {
+	meta, err := metadata.LoadForDump(inputFile)
+	if err != nil {
+		diag.Warnf("%v", err)
+	}
+
+	if meta != nil {
+		source := meta.Source
+		if source.Database != target.Database || !database.SameServer(source.Host, source.Port, target.Host, target.Port) {
+			return nil
+		}
+
+		age := time.Since(meta.CreatedAt)
+		message := fmt.Sprintf("This dump was taken from this exact database (%s:%d/%s) %s ago, on %s (now %s) — restoring will revert it to that point",
+			source.Host, source.Port, source.Database, formatAge(age),
+			meta.CreatedAt.Local().Format("2006-01-02 15:04"), time.Now().Format("2006-01-02 15:04"))
+
+		ui.PrintWarning(message)
+		if allowSameSource {
+			return nil
+		}
+
+		confirmed, err := prompt.Confirm(prompt.RestoreSameSource, "Restore anyway?")
+		if err != nil {
+			return fmt.Errorf("refusing to restore into the source database: %w, or use --allow-same-source", err)
+		}
+		if !confirmed {
+			return fmt.Errorf("restore cancelled")
+		}
+		return nil
+	}
+
+	// No metadata: fall back to comparing database names only
+	sourceDB := ""
+	if header, err := dumpfile.ReadHeader(inputFile); err == nil && header.Database != "" {
+		sourceDB = header.Database
+	} else if match := defaultNamePattern.FindStringSubmatch(filepath.Base(inputFile)); match != nil {
+		sourceDB = match[1]
+	}
+
+	if sourceDB != "" && sourceDB == target.Database {
+		ui.PrintWarning(fmt.Sprintf("This dump appears to come from a database named %q, the same as the target; if that is the same server, restoring will revert it", sourceDB))
+	}
+
+	return nil
+}
+
+// formatAge formats a duration as a rough age ("43 days", "5 hours", "12 minutes")
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+}
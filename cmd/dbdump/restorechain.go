@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/donefile"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// incrementOnly restores an increment alone, on top of a target that has
+// the rest of its chain
+var incrementOnly bool
+
+func init() {
+	restoreCmd.Flags().BoolVar(&incrementOnly, "increment-only", false, "Apply only the given increment, onto a database holding the dumps before it")
+}
+
+// chainRestoreError is a restore failure in one file of a chain. Increments
+// are resumed alone with --increment-only, so the hint names the file.
+type chainRestoreError struct {
+	File      string
+	Increment bool // the file is an increment, not the base dump
+	Err       error
+}
+
+func (e *chainRestoreError) Error() string {
+	return fmt.Sprintf("%s: %v", filepath.Base(e.File), e.Err)
+}
+
+func (e *chainRestoreError) Unwrap() error {
+	return e.Err
+}
+
+// restoreChain returns the files to restore for inputFile: the base dump
+// and every increment up to inputFile when it is an increment, or just
+// inputFile. The files of a chain are checked against the checksums their
+// successors recorded, and each increment's ranges must start where the
+// dump before it stopped; overlapping or missing ranges are an error.
+func restoreChain(inputFile string) ([]string, int64, error) {
+	meta, err := metadata.LoadForDump(inputFile)
+	if err != nil {
+		diag.Warnf("%v", err)
+	}
+	increment := meta != nil && meta.Chain != nil && meta.Chain.Sequence > 0
+	if incrementOnly && !increment {
+		return nil, 0, fmt.Errorf("--increment-only needs an increment written with --append-since-last")
+	}
+	if !increment || incrementOnly {
+		return []string{inputFile}, 0, nil
+	}
+	if startOffset > 0 || len(restoreTables) > 0 || restoreInteractive {
+		return nil, 0, fmt.Errorf("%s is an increment restored with the dumps before it; --start-offset, --table and --interactive need --increment-only", filepath.Base(inputFile))
+	}
+
+	files := []string{inputFile}
+	dir := filepath.Dir(inputFile)
+	for meta.Chain.Sequence > 0 {
+		previous := filepath.Join(dir, meta.Chain.Previous)
+		sum, _, err := donefile.Checksum(previous)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s continues %s, which can't be read: %w", filepath.Base(files[len(files)-1]), meta.Chain.Previous, err)
+		}
+		if sum != meta.Chain.PreviousSHA256 {
+			return nil, 0, fmt.Errorf("%s changed after %s was taken from it (SHA-256 mismatch)", meta.Chain.Previous, filepath.Base(files[len(files)-1]))
+		}
+		before, err := metadata.LoadForDump(previous)
+		if err != nil {
+			return nil, 0, err
+		}
+		if before == nil || before.Chain == nil {
+			return nil, 0, fmt.Errorf("%s has no chain in its sidecar", meta.Chain.Previous)
+		}
+		if err := checkChainLink(before.Chain, meta.Chain); err != nil {
+			return nil, 0, fmt.Errorf("%s does not continue %s: %w", filepath.Base(files[len(files)-1]), meta.Chain.Previous, err)
+		}
+		files = append(files, previous)
+		meta = before
+	}
+	slices.Reverse(files)
+
+	var size int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot read dump file: %w", err)
+		}
+		size += info.Size()
+	}
+	ui.PrintInfo(fmt.Sprintf("Restoring the chain of %s: %s and %d increment(s), %s", filepath.Base(inputFile), filepath.Base(files[0]), len(files)-1, database.FormatBytes(size)))
+	return files, size, nil
+}
+
+// checkChainLink checks that next follows previous directly: same base,
+// next sequence number, and for every table a range starting exactly at
+// the previous watermark
+func checkChainLink(previous, next *metadata.Chain) error {
+	if previous.Base != next.Base || previous.Sequence+1 != next.Sequence {
+		return fmt.Errorf("it is dump %d of the chain based on %s, not %d of %s", previous.Sequence, previous.Base, next.Sequence-1, next.Base)
+	}
+	for _, watermark := range next.Watermarks {
+		last, ok := previous.Watermark(watermark.Table)
+		switch {
+		case !ok:
+			return fmt.Errorf("%s is not in it", watermark.Table)
+		case last.Column != watermark.Column:
+			return fmt.Errorf("%s is tracked by %s, not %s", watermark.Table, last.Column, watermark.Column)
+		case watermark.From != last.Through:
+			return fmt.Errorf("%s starts after %s %s, but the dump before it ends at %s (overlapping or missing rows)", watermark.Table, watermark.Column, watermark.From, last.Through)
+		}
+	}
+	return nil
+}
+
+// checkIncrementTarget checks, for --increment-only, that the target holds
+// each table of the increment up to exactly where the increment starts:
+// rows past that would be restored twice, and a lower watermark means an
+// earlier increment is missing
+func checkIncrementTarget(ctx context.Context, inputFile string, target *database.Connection) error {
+	if !incrementOnly {
+		return nil
+	}
+	meta, err := metadata.LoadForDump(inputFile)
+	if err != nil {
+		return err
+	}
+
+	db, err := target.ConnectContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the target: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+	inspector, err := newInspector(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, watermark := range meta.Chain.Watermarks {
+		current, _, err := inspector.GetWatermark(ctx, watermark.Table, watermark.Column)
+		if err != nil {
+			return err
+		}
+		if current != watermark.From {
+			return fmt.Errorf("%s in %s ends at %s %q, but the increment starts after %q (overlapping or missing rows); restore the chain from its base instead",
+				watermark.Table, target.Database, watermark.Column, current, watermark.From)
+		}
+	}
+	return nil
+}
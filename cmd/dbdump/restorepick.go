@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/ui/prompt"
+)
+
+var (
+	restoreTables      []string
+	restoreInteractive bool
+)
+
+func init() {
+	restoreCmd.Flags().StringArrayVar(&restoreTables, "table", nil, "Restore only this table or view: its structure, data and triggers (repeatable)")
+	restoreCmd.Flags().BoolVar(&restoreInteractive, "interactive", false, "Scan the dump and pick the tables to restore in the table selector")
+}
+
+// restoreFilter returns the filter of a selective restore, letting the user
+// pick the tables with --interactive, or nil to restore everything
+func restoreFilter(ctx context.Context, inputFile string) (*dumpfile.TableFilter, error) {
+	if len(restoreTables) > 0 {
+		return dumpfile.NewTableFilter(restoreTables), nil
+	}
+	if !restoreInteractive {
+		return nil, nil
+	}
+	if !ui.IsInteractive() {
+		return nil, fmt.Errorf("--interactive needs a terminal; use --table to choose the tables to restore")
+	}
+
+	var contents *dumpfile.Contents
+	load := func(ctx context.Context, update func(ui.TableUpdate)) error {
+		update(ui.TableUpdate{Status: "Scanning " + inputFile})
+		var err error
+		if contents, err = dumpfile.Inspect(inputFile); err != nil {
+			return err
+		}
+		tables := restoreCandidates(contents)
+		names := make([]string, len(tables))
+		for i, table := range tables {
+			names[i] = table.Name
+		}
+		update(ui.TableUpdate{Tables: tables, PreSelected: names, Final: true})
+		return nil
+	}
+	selected, err := ui.RunInteractiveSelection(ctx, nil, nil, ui.SelectionOptions{
+		Title:    "Select the tables to RESTORE (structure, data and triggers)",
+		Selected: "of data to restore",
+		Load:     load,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no tables selected; nothing to restore")
+	}
+	if !contents.Completed {
+		diag.Warnf("%s has no \"Dump completed on\" marker and may be truncated", inputFile)
+	}
+
+	selected = inDumpOrder(contents, selected)
+	if err := confirmRestoreSelection(selected, len(restoreCandidates(contents))); err != nil {
+		return nil, err
+	}
+	restoreTables = selected
+	return dumpfile.NewTableFilter(selected), nil
+}
+
+// restoreCandidates lists the tables and views of a dump for the selector,
+// with the size of their data in the SQL
+func restoreCandidates(contents *dumpfile.Contents) []database.TableInfo {
+	tables := make([]database.TableInfo, 0, len(contents.Tables))
+	for _, t := range contents.Tables {
+		info := database.TableInfo{
+			Name:        t.Name,
+			RowCount:    t.Rows,
+			DataSize:    t.DataSize,
+			TotalSize:   t.DataSize,
+			SizeDisplay: database.FormatBytes(t.DataSize),
+		}
+		switch {
+		case t.View:
+			info.Comment = "view"
+		case !t.Structure:
+			info.Comment = "data only"
+		case !t.HasData():
+			info.Comment = "structure only"
+		}
+		tables = append(tables, info)
+	}
+	return tables
+}
+
+// inDumpOrder sorts the selected tables in the order of the dump
+func inDumpOrder(contents *dumpfile.Contents, selected []string) []string {
+	chosen := make(map[string]bool, len(selected))
+	for _, table := range selected {
+		chosen[table] = true
+	}
+	ordered := make([]string, 0, len(selected))
+	for _, t := range contents.Tables {
+		if chosen[t.Name] {
+			ordered = append(ordered, t.Name)
+		}
+	}
+	return ordered
+}
+
+// confirmRestoreSelection names the target and what the restore replaces
+// there, and asks before going on
+func confirmRestoreSelection(selected []string, total int) error {
+	ui.PrintWarning(fmt.Sprintf("Restoring %d of %d tables into %s on %s:%d drops and recreates them there; their current data is lost",
+		len(selected), total, dbName, host, port))
+	confirmed, err := prompt.Confirm(prompt.RestoreTables, fmt.Sprintf("Restore %s into %s?", strings.Join(selected, ", "), dbName))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return fmt.Errorf("restore cancelled")
+	}
+	return nil
+}
+
+// tableFlags returns the --table flags repeating a selection
+func tableFlags(tables []string) string {
+	flags := make([]string, len(tables))
+	for i, table := range tables {
+		flags[i] = "--table " + shellQuote(table)
+	}
+	return strings.Join(flags, " ")
+}
+
+// reportRestoreFilter notes the chosen tables the dump didn't have, and
+// prints the flags that repeat an interactive selection
+func reportRestoreFilter(filter *dumpfile.TableFilter) {
+	if missing := filter.Missing(); len(missing) > 0 {
+		diag.Warnf("the dump has no statements for %s", strings.Join(missing, ", "))
+	}
+	if restoreInteractive {
+		ui.PrintInfo("Same selection without the picker: " + tableFlags(restoreTables))
+	}
+}
+
+// shellQuote quotes an argument for a POSIX shell unless it is made only of
+// characters the shell leaves alone
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.,:/@%+=") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/resume"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// resumeDump continues the interrupted dump of the database
+var resumeDump bool
+
+func init() {
+	dumpCmd.Flags().BoolVar(&resumeDump, "resume", false, "Continue the interrupted dump of the database after the last phase it completed")
+}
+
+// resumeIgnoredFlags don't change what a dump writes, so a resumed dump may
+// be given other values; the output is compared on its own
+var resumeIgnoredFlags = map[string]bool{
+	"resume": true, "password": true, "output": true, "verbose": true,
+	"progress": true, "no-progress": true, "json": true, "progress-interval": true,
+}
+
+// resumeRun keeps the state of a single-file dump at each checkpoint, so a
+// failed run can be continued with --resume
+type resumeRun struct {
+	path      string
+	state     resume.State
+	previous  *resume.State // left by an earlier run
+	resumed   bool          // previous is the dump this run continues
+	inspector *database.Inspector
+	written   bool
+	disabled  bool
+}
+
+// validateResumeFlags checks --resume against the other flags
+func validateResumeFlags() error {
+	if !resumeDump {
+		return nil
+	}
+	switch {
+	case maxFileSize.Bytes > 0:
+		return fmt.Errorf("--resume cannot be combined with --max-file-size (split dumps are not resumable)")
+	case appendSinceLast:
+		return fmt.Errorf("--resume cannot be combined with --append-since-last (an increment has a single phase)")
+	case schemaDelta:
+		return fmt.Errorf("--resume cannot be combined with --schema-delta (a schema delta has a single phase)")
+	case dryRun:
+		return fmt.Errorf("--resume cannot be combined with --dry-run")
+	}
+	return nil
+}
+
+// loadResume reads the state an earlier run left for the database. With
+// --resume it must be there and match this dump's flags; the output name
+// is then the interrupted dump's.
+func loadResume(cmd *cobra.Command, args []string) (*resumeRun, error) {
+	dir := outputDir
+	if outputFile != "" {
+		dir = filepath.Dir(outputFile)
+	}
+	run := &resumeRun{
+		path:  resume.Path(dir, dbName),
+		state: resume.State{Host: host, Port: port, Database: dbName, Flags: dumpFlags(cmd, args)},
+	}
+	previous, err := resume.Load(run.path)
+	if err != nil {
+		return nil, err
+	}
+	run.previous = previous
+
+	if !resumeDump {
+		if previous != nil {
+			ui.PrintInfo(fmt.Sprintf("%s holds an interrupted dump of %s; --resume continues it instead of starting over", filepath.Base(previous.Kept), dbName))
+		}
+		return run, nil
+	}
+	if previous == nil {
+		return nil, fmt.Errorf("there is no interrupted dump of %s to resume in %s", dbName, filepath.Clean(dir))
+	}
+	if !database.SameServer(previous.Host, previous.Port, host, port) {
+		return nil, fmt.Errorf("the interrupted dump of %s was taken from %s:%d, not %s:%d", dbName, previous.Host, previous.Port, host, port)
+	}
+	if outputFile != "" {
+		if path, err := filepath.Abs(outputFile); err == nil && path != previous.OutputFile {
+			return nil, fmt.Errorf("the interrupted dump of %s is written to %s, not %s", dbName, previous.OutputFile, path)
+		}
+	}
+	if difference := flagDifference(previous.Flags, run.state.Flags); difference != "" {
+		return nil, fmt.Errorf("--resume needs the flags of the interrupted dump: %s", difference)
+	}
+	if _, err := os.Stat(previous.Kept); err != nil {
+		return nil, fmt.Errorf("the output of the interrupted dump is gone: %w", err)
+	}
+	run.resumed = true
+	run.state.SchemaDigest = previous.SchemaDigest
+	return run, nil
+}
+
+// dumpFlags lists the flags and arguments that decide what a dump writes
+func dumpFlags(cmd *cobra.Command, args []string) []string {
+	var flags []string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if !resumeIgnoredFlags[flag.Name] {
+			flags = append(flags, "--"+flag.Name+"="+flag.Value.String())
+		}
+	})
+	return append(flags, args...)
+}
+
+// flagDifference describes how two flag lists differ, or returns ""
+func flagDifference(before, now []string) string {
+	var problems []string
+	for _, flag := range before {
+		if !slices.Contains(now, flag) {
+			problems = append(problems, flag+" is missing")
+		}
+	}
+	for _, flag := range now {
+		if !slices.Contains(before, flag) {
+			problems = append(problems, flag+" was not given before")
+		}
+	}
+	return strings.Join(problems, ", ")
+}
+
+// outputFile returns the output of the interrupted dump, or "" when this
+// run starts a new one
+func (r *resumeRun) outputFile() string {
+	if !r.resumed {
+		return ""
+	}
+	return r.previous.OutputFile
+}
+
+// checkSource refuses to continue when the source's schema changed since
+// the interrupted dump wrote its structure
+func (r *resumeRun) checkSource(inspector *database.Inspector) error {
+	r.inspector = inspector
+	if !r.resumed {
+		return nil
+	}
+	digest, err := inspector.SchemaDigest()
+	if err != nil {
+		return fmt.Errorf("can't tell whether the schema changed since the interrupted dump: %w", err)
+	}
+	if digest != r.previous.SchemaDigest {
+		return fmt.Errorf("the schema of %s changed since the interrupted dump of %s wrote its structure, so it can't be continued; run the dump again without --resume",
+			dbName, filepath.Base(r.previous.OutputFile))
+	}
+	return nil
+}
+
+// start records the output file and table selection of this run, returning
+// the checkpoint and kept file to continue from with --resume. A selection
+// other than the interrupted dump's can't be continued.
+func (r *resumeRun) start(output string, excluded, skipped []string) (*database.Checkpoint, string, error) {
+	r.state.OutputFile, r.state.Kept = output, output
+	r.state.Excluded, r.state.Skipped = slices.Sorted(slices.Values(excluded)), slices.Sorted(slices.Values(skipped))
+	if !r.resumed {
+		return nil, "", nil
+	}
+	if !slices.Equal(r.state.Excluded, r.previous.Excluded) || !slices.Equal(r.state.Skipped, r.previous.Skipped) {
+		return nil, "", fmt.Errorf("the tables selected differ from those of the interrupted dump of %s, so it can't be continued", dbName)
+	}
+	ui.PrintInfo(fmt.Sprintf("Resuming after the %s phase of the interrupted dump (%s written)",
+		r.previous.Checkpoint.Phase, database.FormatBytes(r.previous.Checkpoint.Offset)))
+	checkpoint := r.previous.Checkpoint
+	return &checkpoint, r.previous.Kept, nil
+}
+
+// checkpoint saves the state at the end of a phase, taking the schema
+// digest after the structure phase of a new dump
+func (r *resumeRun) checkpoint(checkpoint database.Checkpoint) {
+	if r.disabled {
+		return
+	}
+	if r.state.SchemaDigest == "" {
+		digest, err := r.inspector.SchemaDigest()
+		if err != nil {
+			diag.Warnf("this dump can't be resumed if it fails: %v", err)
+			r.disabled = true
+			return
+		}
+		r.state.SchemaDigest = digest
+	}
+	if !r.written && !r.resumed && r.previous != nil && r.previous.Kept != r.state.OutputFile {
+		// Started over; the earlier output can't be continued any more
+		if err := os.Remove(r.previous.Kept); err != nil && !os.IsNotExist(err) {
+			diag.Warnf("failed to remove the output of the interrupted dump: %v", err)
+		}
+	}
+
+	r.state.Checkpoint = checkpoint
+	if err := resume.Write(r.path, &r.state); err != nil {
+		diag.Warnf("%v", err)
+		r.disabled = true
+		return
+	}
+	r.written = true
+}
+
+// failed records where the output of a failed dump was kept, or removes
+// the state if nothing can be continued
+func (r *resumeRun) failed(dumper *database.Dumper) {
+	if !r.written {
+		return
+	}
+	checkpoint, kept := dumper.Resumable()
+	if checkpoint == nil {
+		r.finish()
+		return
+	}
+	r.state.Checkpoint, r.state.Kept = *checkpoint, kept
+	if err := resume.Write(r.path, &r.state); err != nil {
+		diag.Warnf("%v", err)
+		return
+	}
+	ui.PrintInfo(fmt.Sprintf("Kept the output up to the end of the %s phase as %s; run the same command with --resume to continue from there",
+		checkpoint.Phase, filepath.Base(kept)))
+}
+
+// finish removes the state once the dump is complete
+func (r *resumeRun) finish() {
+	if !r.written && !r.resumed {
+		return
+	}
+	if err := resume.Remove(r.path); err != nil {
+		diag.Warnf("%v", err)
+	}
+}
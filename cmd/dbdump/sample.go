@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/ui"
+)
+
+var (
+	sampleStatements  int
+	sampleValueLength int
+)
+
+func init() {
+	dumpCmd.Flags().IntVar(&sampleStatements, "sample-statements", 0, "Debug: copy the first and last N statements of each table, and any that are not valid UTF-8, to <output>.samples.txt")
+	dumpCmd.Flags().IntVar(&sampleValueLength, "sample-value-length", 32, "With --sample-statements, cut string values in the samples after this many characters")
+}
+
+// validateSampleFlags checks the statement sampling flags
+func validateSampleFlags() error {
+	if sampleStatements < 0 {
+		return fmt.Errorf("--sample-statements must not be negative")
+	}
+	if sampleValueLength < 0 {
+		return fmt.Errorf("--sample-value-length must not be negative")
+	}
+	return nil
+}
+
+// samplePath returns where statement samples are written for a dump
+func samplePath(outputFile string) string {
+	return outputFile + ".samples.txt"
+}
+
+// statementSampling records --sample-statements in the metadata, or nil
+func statementSampling(outputFile string) *metadata.StatementSampling {
+	if sampleStatements == 0 {
+		return nil
+	}
+	ui.PrintInfo(fmt.Sprintf("Statement samples written to %s", samplePath(outputFile)))
+	return &metadata.StatementSampling{
+		PerTable:    sampleStatements,
+		ValueLength: sampleValueLength,
+		File:        samplePath(outputFile),
+	}
+}
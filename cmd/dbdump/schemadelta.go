@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/history"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+var (
+	schemaDelta bool
+	schemaBase  string
+)
+
+func init() {
+	dumpCmd.Flags().BoolVar(&schemaDelta, "schema-delta", false, "Write only schema changes since --base (new tables, and DROP+CREATE for altered ones)")
+	dumpCmd.Flags().StringVar(&schemaBase, "base", "", "Baseline dump file or sidecar for --schema-delta")
+}
+
+// validateSchemaDeltaFlags rejects flag combinations that make no sense for a schema delta
+func validateSchemaDeltaFlags() error {
+	if !schemaDelta {
+		if schemaBase != "" {
+			return fmt.Errorf("--base is only used with --schema-delta")
+		}
+		return nil
+	}
+	if schemaBase == "" {
+		return fmt.Errorf("--schema-delta requires --base <dump or sidecar>")
+	}
+	if verifyMode != "" || maxFileSize.Bytes != 0 || convertCharset != "" {
+		return fmt.Errorf("--schema-delta cannot be combined with --verify, --max-file-size or --convert-charset")
+	}
+	return nil
+}
+
+// loadBaselineSchema returns the table fingerprints of a baseline, read from
+// its sidecar when available and otherwise by scanning the dump
+func loadBaselineSchema(path string) (map[string]string, error) {
+	if strings.HasSuffix(path, metadata.SidecarSuffix) {
+		meta, err := metadata.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if meta.Schema == nil {
+			return nil, fmt.Errorf("sidecar %s has no schema fingerprints (written by an older dbdump); pass the dump file instead", path)
+		}
+		return meta.Schema, nil
+	}
+
+	if meta, err := metadata.LoadForDump(dumpfile.BasePath(path)); err == nil && meta != nil && meta.Schema != nil {
+		return meta.Schema, nil
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Scanning %s for table definitions", path))
+	file, err := dumpfile.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			diag.Warnf("failed to close baseline dump: %v", err)
+		}
+	}()
+
+	fingerprinter := database.NewSchemaFingerprinter()
+	if _, err := io.Copy(fingerprinter, file); err != nil {
+		return nil, fmt.Errorf("failed to read baseline dump: %w", err)
+	}
+	return fingerprinter.Fingerprints(), nil
+}
+
+// runSchemaDelta writes CREATE TABLE statements for tables added since the
+// baseline and DROP+CREATE blocks for altered ones; unchanged tables are omitted
+func runSchemaDelta(ctx context.Context, conn *database.Connection, allTables []database.TableInfo, skipped []string) error {
+	baseline, err := loadBaselineSchema(schemaBase)
+	if err != nil {
+		return err
+	}
+
+	ui.PrintInfo("Reading current table definitions")
+	current := database.NewSchemaFingerprinter().CaptureStatements()
+	dumper := database.NewDumper(&database.DumpOptions{
+		Connection: conn,
+		SkipTables: skipped,
+		OutputFile: outputFile,
+		Context:    ctx,
+		Native:     nativeDump,
+	})
+	if err := dumper.DumpStructure(current); err != nil {
+		return fmt.Errorf("failed to read table definitions: %w", err)
+	}
+	statements, order := current.Statements()
+
+	diff := history.DiffSchemas(baseline, current.Fingerprints())
+
+	// Tables outside the selection still exist and were not removed
+	exists := make(map[string]bool, len(allTables))
+	for _, info := range allTables {
+		exists[info.Name] = true
+	}
+	var removed []string
+	for _, table := range diff.Removed {
+		if !exists[table] {
+			removed = append(removed, table)
+		}
+	}
+	diff.Removed = removed
+
+	if diff.Empty() {
+		ui.PrintSuccess("No schema changes since the baseline; nothing to write")
+		return nil
+	}
+	ui.PrintInfo(fmt.Sprintf("Schema changes since the baseline: %s", diff.Summary()))
+
+	if dryRun {
+		printSchemaDelta(diff)
+		fmt.Printf("\nWould create schema delta: %s\n", outputFile)
+		return nil
+	}
+
+	if err := writeSchemaDelta(outputFile, conn.Database, diff, statements, order); err != nil {
+		return err
+	}
+	ui.PrintSuccess(fmt.Sprintf("Schema delta written to %s", outputFile))
+	if len(diff.Altered) > 0 {
+		ui.PrintWarning(fmt.Sprintf("%d altered tables are dropped and recreated by the delta; their data is lost when it is applied", len(diff.Altered)))
+	}
+	return nil
+}
+
+// printSchemaDelta lists the changes for --dry-run
+func printSchemaDelta(diff history.SchemaDiff) {
+	fmt.Println()
+	for _, table := range diff.Added {
+		fmt.Printf("  + %s (created)\n", table)
+	}
+	for _, table := range diff.Altered {
+		fmt.Printf("  ~ %s (dropped and recreated)\n", table)
+	}
+	for _, table := range diff.Removed {
+		fmt.Printf("  - %s (removed, not dropped)\n", table)
+	}
+}
+
+// writeSchemaDelta writes the delta file with restrictive permissions
+func writeSchemaDelta(path, dbName string, diff history.SchemaDiff, statements map[string]string, order []string) (err error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close output file: %w", closeErr)
+		}
+	}()
+
+	w := bufio.NewWriter(file)
+
+	fmt.Fprintf(w, "-- dbdump schema delta for `%s`, generated %s\n", dbName, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "-- Baseline: %s\n", schemaBase)
+	fmt.Fprintf(w, "-- %s\n", diff.Summary())
+	if len(diff.Removed) > 0 {
+		fmt.Fprintln(w, "--")
+		fmt.Fprintln(w, "-- Removed since the baseline (not dropped by this file):")
+		for _, table := range diff.Removed {
+			fmt.Fprintf(w, "--   %s\n", table)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "SET NAMES utf8mb4;")
+	fmt.Fprintln(w, "SET FOREIGN_KEY_CHECKS=0;")
+
+	added := make(map[string]bool, len(diff.Added))
+	for _, table := range diff.Added {
+		added[table] = true
+	}
+	altered := make(map[string]bool, len(diff.Altered))
+	for _, table := range diff.Altered {
+		altered[table] = true
+	}
+
+	// Keep mysqldump's order so the file reads like the structure phase
+	for _, table := range order {
+		quoted := sqlident.Quote(table)
+		switch {
+		case added[table]:
+			fmt.Fprintf(w, "\n-- New table %s\n", quoted)
+			fmt.Fprint(w, statements[table])
+		case altered[table]:
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "-- ============================================================")
+			fmt.Fprintf(w, "-- WARNING: %s changed since the baseline. This block drops and\n", quoted)
+			fmt.Fprintf(w, "-- recreates it, so all rows in %s are lost. Review before applying.\n", quoted)
+			fmt.Fprintln(w, "-- ============================================================")
+			fmt.Fprintf(w, "DROP TABLE IF EXISTS %s;\n", quoted)
+			fmt.Fprint(w, statements[table])
+			fmt.Fprintf(w, "-- End of %s\n", quoted)
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "SET FOREIGN_KEY_CHECKS=1;")
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/plan"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/ui"
+)
+
+// newPlanner builds the planner of a dump from the configs and flags, with
+// the positional table arguments
+func newPlanner(args []string) (*planner.Planner, error) {
+	// Build the data exclusion rules, inverted by any include rules
+	matcher, err := buildDataMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// Positive selection: tables not matching the only rules are skipped entirely,
+	// and data exclusion rules apply to the remaining tables
+	onlyConfig, err := buildOnlyConfig()
+	if err != nil {
+		return nil, err
+	}
+	samples, err := loadSampleRules()
+	if err != nil {
+		return nil, err
+	}
+	structureRules, err := loadStructureRules()
+	if err != nil {
+		return nil, err
+	}
+
+	return &planner.Planner{Rules: planner.Rules{
+		Data:                matcher,
+		Only:                onlyConfig,
+		Args:                args,
+		ExcludeNames:        excludeTables,
+		OnlyNames:           onlyTables,
+		IncludeNames:        includeTables,
+		SkipEngines:         skipEngines,
+		KeepEngineStructure: keepEngineDDL,
+		Samples:             samples,
+		Structure:           structureRules,
+		Origins:             ruleOrigins,
+		Case:                nameCase,
+	}}, nil
+}
+
+// applySelectionRules applies the configured rules and positional table
+// arguments to the tables found in the database
+func applySelectionRules(tablesInfo []database.TableInfo, args []string) (*planner.Planner, *planner.Selection, error) {
+	p, err := newPlanner(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	sel, err := p.Select(tablesInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+	printNotices(sel)
+	return p, sel, nil
+}
+
+// chooseExcludes returns the tables whose data is excluded without the
+// selector: those the rules pre-select sure enough for --auto-threshold. In
+// auto mode selection_conflict may prefer a saved selection that disagrees;
+// tables named on the command line (or in a plan) are an explicit selection.
+func chooseExcludes(sel *planner.Selection, args []string, dumpPlan *plan.Plan) ([]string, error) {
+	excludes := thresholdExcludes(sel)
+	if !autoMode {
+		return excludes, nil
+	}
+	if len(args) == 0 && dumpPlan == nil {
+		var err error
+		if excludes, err = reconcileSavedSelection(sel.Tables, excludes); err != nil {
+			return nil, err
+		}
+	}
+	ui.PrintInfo(fmt.Sprintf("Auto mode: excluding %d tables based on patterns", len(excludes)))
+	return excludes, nil
+}
+
+// printNotices prints what the selection rules found worth telling
+func printNotices(sel *planner.Selection) {
+	for _, notice := range sel.Notices {
+		if notice.Warning {
+			ui.PrintWarning(notice.Text)
+		} else {
+			ui.PrintInfo(notice.Text)
+		}
+	}
+}
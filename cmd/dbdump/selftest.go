@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/selftest"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/verify"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selftestDocker       bool
+	selftestDockerImage  string
+	selftestSkip         []string
+	selftestPrintFixture bool
+)
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check the whole dump and restore pipeline against a disposable schema",
+	Long: `Load a small fixture schema (blobs, 4-byte UTF-8, non-ASCII names, a generated
+column, foreign keys, a trigger and a view) into a new scratch database,
+dump it with exclusions, check the dump, restore it into a second scratch
+database, compare table checksums, and drop both databases again. Real
+data is never read or written.
+
+The connection flags need CREATE and DROP privileges for new databases
+(named dbdump_selftest_<random>, or -d and -d_restored). With --docker a
+throwaway server is started instead. The dump and restore run this dbdump
+binary with a temporary home directory, so user configs and history
+neither apply nor record anything.
+
+Stages: setup, dump, verify, restore, compare, cleanup. Each can be left
+out with --skip; stages that need a skipped one are skipped too. With
+--skip setup, -d names a database the fixture is already loaded into.`,
+	Args: cobra.NoArgs,
+	RunE: runSelftest,
+}
+
+func init() {
+	selftestCmd.Flags().BoolVar(&selftestDocker, "docker", false, "Run against a throwaway server in Docker instead of the connection flags")
+	selftestCmd.Flags().StringVar(&selftestDockerImage, "docker-image", "mysql:8.0", "Image of the --docker server (e.g. mysql:8.4, mariadb:10.11)")
+	selftestCmd.Flags().StringSliceVar(&selftestSkip, "skip", nil, "Stages to leave out: setup, dump, verify, restore, compare or cleanup (repeatable)")
+	selftestCmd.Flags().BoolVar(&selftestPrintFixture, "print-fixture", false, "Print the fixture schema as SQL and exit")
+	rootCmd.AddCommand(selftestCmd)
+}
+
+// selftestRun is the state the stages of one selftest share
+type selftestRun struct {
+	conn     *database.Connection // no default database
+	scratch  string
+	restored string
+	dir      string
+	dumpFile string
+	server   *verify.Server
+
+	// created are the databases this run created, which cleanup drops
+	created []string
+}
+
+func runSelftest(cmd *cobra.Command, args []string) error {
+	if selftestPrintFixture {
+		_, err := io.WriteString(os.Stdout, selftest.FixtureSQL())
+		return err
+	}
+
+	run := &selftestRun{}
+	stages := run.stages()
+	skip := make(map[string]bool, len(selftestSkip))
+	for _, name := range selftestSkip {
+		if !slices.Contains(selftest.StageNames(stages), name) {
+			return fmt.Errorf("unknown stage %q for --skip (stages: %s)", name, strings.Join(selftest.StageNames(stages), ", "))
+		}
+		skip[name] = true
+	}
+	if skip["setup"] && dbName == "" {
+		return fmt.Errorf("--skip setup needs -d naming a database the fixture is loaded into (see --print-fixture)")
+	}
+	if skip["setup"] && selftestDocker {
+		return fmt.Errorf("--skip setup cannot be combined with --docker, whose server starts empty")
+	}
+
+	if err := run.connect(cmd.Context()); err != nil {
+		return err
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Self-test against %s@%s:%d in %s", run.conn.User, run.conn.Host, run.conn.Port, run.scratch))
+	results := selftest.Run(cmd.Context(), stages, skip, printSelftestResult)
+
+	var failed []string
+	for _, result := range results {
+		if result.Status == selftest.Failed {
+			failed = append(failed, result.Stage)
+		}
+	}
+	if skip["cleanup"] {
+		run.printKept()
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("self-test failed: %s", strings.Join(failed, ", "))
+	}
+	ui.PrintSuccess("Self-test passed")
+	return nil
+}
+
+// connect picks the server, from --docker or the connection flags, and
+// the scratch database names
+func (r *selftestRun) connect(ctx context.Context) error {
+	if selftestDocker {
+		if err := verify.CheckDocker(); err != nil {
+			return fmt.Errorf("--docker needs Docker: %w", err)
+		}
+		ui.PrintInfo(fmt.Sprintf("Starting %s container", selftestDockerImage))
+		server, err := verify.StartServer(ctx, selftestDockerImage, 3*time.Minute)
+		if err != nil {
+			return err
+		}
+		r.server = server
+		host, port, user, password = server.Host, server.Port, "root", ""
+	} else {
+		resolvePassword()
+		if user == "" {
+			return fmt.Errorf("database user is required (use -u or --user), or use --docker")
+		}
+	}
+	r.conn = &database.Connection{Host: host, Port: port, User: user, Password: password}
+
+	r.scratch = dbName
+	if r.scratch == "" {
+		suffix := make([]byte, 4)
+		_, _ = rand.Read(suffix)
+		r.scratch = "dbdump_selftest_" + hex.EncodeToString(suffix)
+	}
+	r.restored = r.scratch + "_restored"
+	return nil
+}
+
+// stages lists the selftest's stages in order
+func (r *selftestRun) stages() []selftest.Stage {
+	return []selftest.Stage{
+		{Name: "setup", Reusable: true, Run: r.setup},
+		{Name: "dump", Needs: []string{"setup"}, Run: r.dump},
+		{Name: "verify", Needs: []string{"dump"}, Run: r.verifyDump},
+		{Name: "restore", Needs: []string{"dump"}, Run: r.restore},
+		{Name: "compare", Needs: []string{"restore"}, Run: r.compare},
+		{Name: "cleanup", Always: true, Run: r.cleanup},
+	}
+}
+
+// printSelftestResult prints one stage's outcome as it finishes
+func printSelftestResult(result selftest.Result) {
+	switch result.Status {
+	case selftest.Passed:
+		ui.PrintSuccess(fmt.Sprintf("%-8s passed (%s)", result.Stage, ui.FormatDuration(result.Duration)))
+	case selftest.Failed:
+		ui.PrintFailure(fmt.Sprintf("%-8s failed: %v", result.Stage, result.Err))
+	default:
+		ui.PrintInfo(fmt.Sprintf("%-8s skipped (%s)", result.Stage, result.Reason))
+	}
+}
+
+// printKept says what --skip cleanup left behind
+func (r *selftestRun) printKept() {
+	if len(r.created) > 0 {
+		ui.PrintInfo(fmt.Sprintf("Kept databases: %s", strings.Join(r.created, ", ")))
+	}
+	if r.dir != "" {
+		ui.PrintInfo(fmt.Sprintf("Kept dump directory: %s", r.dir))
+	}
+	if r.server != nil {
+		ui.PrintInfo(fmt.Sprintf("Kept %s container on %s:%d (user root, no password)", r.server.Image, r.server.Host, r.server.Port))
+	}
+}
+
+// open connects to the server with database as the default database
+func (r *selftestRun) open(ctx context.Context, database string) (*sql.DB, error) {
+	conn := *r.conn
+	conn.Database = database
+	return conn.ConnectContext(ctx)
+}
+
+// createDatabase creates a scratch database, failing if it exists
+func (r *selftestRun) createDatabase(ctx context.Context, name string) error {
+	db, err := r.open(ctx, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	if _, err := db.ExecContext(ctx, "CREATE DATABASE "+sqlident.Quote(name)+" CHARACTER SET utf8mb4"); err != nil {
+		return fmt.Errorf("failed to create database %s: %w", name, err)
+	}
+	r.created = append(r.created, name)
+	return nil
+}
+
+// setup creates the scratch database and loads the fixture into it
+func (r *selftestRun) setup(ctx context.Context) error {
+	statements, err := selftest.FixtureStatements()
+	if err != nil {
+		return err
+	}
+	if err := r.createDatabase(ctx, r.scratch); err != nil {
+		return err
+	}
+	db, err := r.open(ctx, r.scratch)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	// One connection, so session settings stay in effect
+	session, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+	for _, statement := range append([]string{"SET NAMES utf8mb4", "SET time_zone = '+00:00'"}, statements...) {
+		if _, err := session.ExecContext(ctx, statement); err != nil {
+			first, _, _ := strings.Cut(statement, "\n")
+			return fmt.Errorf("failed to load the fixture at %q: %w", first, err)
+		}
+	}
+	return nil
+}
+
+// dbdump runs this dbdump binary against the selftest's server, with a
+// temporary home directory, and returns its error with its output
+func (r *selftestRun) dbdump(ctx context.Context, args ...string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	home := filepath.Join(r.dir, "home")
+	if err := os.MkdirAll(home, 0o700); err != nil {
+		return err
+	}
+
+	args = append(args, "-H", r.conn.Host, "-P", strconv.Itoa(r.conn.Port), "-u", r.conn.User, "--progress", "none")
+	child := exec.CommandContext(ctx, executable, args...)
+	child.Cancel = func() error {
+		return child.Process.Signal(os.Interrupt)
+	}
+	child.Env = append(os.Environ(), "HOME="+home, "USERPROFILE="+home,
+		"DBDUMP_MYSQL_PWD="+r.conn.Password, "MYSQL_PWD="+r.conn.Password)
+	var output bytes.Buffer
+	child.Stdout = &output
+	child.Stderr = &output
+	if err := child.Run(); err != nil {
+		return fmt.Errorf("dbdump %s: %w\n%s", args[0], err, indentOutput(output.String()))
+	}
+	return nil
+}
+
+// indentOutput indents a child's output under the failure it explains
+func indentOutput(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "    " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dump dumps the scratch database, excluding one more table by flag than
+// the built-in rules do
+func (r *selftestRun) dump(ctx context.Context) error {
+	dir, err := os.MkdirTemp("", "dbdump-selftest-*")
+	if err != nil {
+		return err
+	}
+	r.dir = dir
+	file := filepath.Join(dir, "selftest.sql")
+	if err := r.dbdump(ctx, "dump", "--auto", "-d", r.scratch, "--exclude", selftest.ExcludedByFlag, "-o", file); err != nil {
+		return err
+	}
+	r.dumpFile = file
+	return nil
+}
+
+// verifyDump checks the dump is complete and holds what the exclusions
+// say: every table's structure, data only where not excluded, the views
+// and the triggers
+func (r *selftestRun) verifyDump(ctx context.Context) error {
+	contents, err := dumpfile.Inspect(r.dumpFile)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	if !contents.Completed {
+		problems = append(problems, `no "Dump completed on" marker`)
+	}
+	tables := make(map[string]dumpfile.TableContents, len(contents.Tables))
+	for _, t := range contents.Tables {
+		tables[t.Name] = t
+	}
+	for _, name := range selftest.Tables {
+		t := tables[name]
+		excluded := name == selftest.ExcludedByDefault || name == selftest.ExcludedByFlag
+		switch {
+		case !t.Structure:
+			problems = append(problems, fmt.Sprintf("no structure for %s", name))
+		case excluded && t.HasData():
+			problems = append(problems, fmt.Sprintf("data for excluded %s", name))
+		case !excluded && !t.HasData():
+			problems = append(problems, fmt.Sprintf("no data for %s", name))
+		}
+	}
+	for _, name := range selftest.Views {
+		if !tables[name].View {
+			problems = append(problems, fmt.Sprintf("no view %s", name))
+		}
+	}
+
+	// The fixture's dump is small enough to search whole
+	file, err := dumpfile.Open(r.dumpFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	text, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	for _, name := range selftest.Triggers {
+		if !bytes.Contains(text, []byte("TRIGGER `"+name+"`")) {
+			problems = append(problems, fmt.Sprintf("no trigger %s", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// restore restores the dump into a second scratch database
+func (r *selftestRun) restore(ctx context.Context) error {
+	if err := database.CheckMySQLClient(); err != nil {
+		return fmt.Errorf("mysql client is required: %w", err)
+	}
+	if err := r.createDatabase(ctx, r.restored); err != nil {
+		return err
+	}
+	return r.dbdump(ctx, "restore", r.dumpFile, "-d", r.restored)
+}
+
+// compare checks the restored database against the scratch one: equal
+// checksums and row counts for tables with data, empty excluded tables,
+// and the same views, triggers and foreign keys
+func (r *selftestRun) compare(ctx context.Context) error {
+	db, err := r.open(ctx, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	var problems []string
+	for _, table := range selftest.Tables {
+		source, err := tableChecksum(ctx, db, r.scratch, table)
+		if err != nil {
+			return err
+		}
+		restored, err := tableChecksum(ctx, db, r.restored, table)
+		if err != nil {
+			return err
+		}
+		if table == selftest.ExcludedByDefault || table == selftest.ExcludedByFlag {
+			if restored.rows != 0 {
+				problems = append(problems, fmt.Sprintf("excluded %s has %d rows", table, restored.rows))
+			}
+			continue
+		}
+		switch {
+		case source.rows == 0:
+			problems = append(problems, fmt.Sprintf("%s is empty in %s", table, r.scratch))
+		case restored != source:
+			problems = append(problems, fmt.Sprintf("%s differs: %d rows (checksum %d) restored, %d (checksum %d) dumped",
+				table, restored.rows, restored.checksum, source.rows, source.checksum))
+		}
+	}
+
+	counts := []struct {
+		what  string
+		query string
+		want  int
+	}{
+		{"views", "SELECT COUNT(*) FROM information_schema.VIEWS WHERE TABLE_SCHEMA = ?", len(selftest.Views)},
+		{"triggers", "SELECT COUNT(*) FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = ?", len(selftest.Triggers)},
+		{"foreign keys", "SELECT COUNT(*) FROM information_schema.REFERENTIAL_CONSTRAINTS WHERE CONSTRAINT_SCHEMA = ?", selftest.ForeignKeys},
+	}
+	for _, count := range counts {
+		var got int
+		if err := db.QueryRowContext(ctx, count.query, r.restored).Scan(&got); err != nil {
+			return fmt.Errorf("failed to count %s: %w", count.what, err)
+		}
+		if got != count.want {
+			problems = append(problems, fmt.Sprintf("%d %s restored, want %d", got, count.what, count.want))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checksum is a table's CHECKSUM TABLE value and row count
+type checksum struct {
+	checksum int64
+	rows     int64
+}
+
+// tableChecksum reads the checksum and row count of schema.table
+func tableChecksum(ctx context.Context, db *sql.DB, schema, table string) (checksum, error) {
+	quoted := sqlident.QuoteQualified(schema, table)
+	var result checksum
+	var name string
+	var value sql.NullInt64
+	if err := db.QueryRowContext(ctx, "CHECKSUM TABLE "+quoted).Scan(&name, &value); err != nil {
+		return result, fmt.Errorf("failed to checksum %s.%s: %w", schema, table, err)
+	}
+	result.checksum = value.Int64
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+quoted).Scan(&result.rows); err != nil {
+		return result, fmt.Errorf("failed to count rows of %s.%s: %w", schema, table, err)
+	}
+	return result, nil
+}
+
+// cleanup drops the databases this run created, and removes the dump
+// directory and the --docker server
+func (r *selftestRun) cleanup(ctx context.Context) error {
+	var errs []error
+	if len(r.created) > 0 {
+		db, err := r.open(context.WithoutCancel(ctx), "")
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			for _, name := range slices.Backward(r.created) {
+				if _, err := db.ExecContext(context.WithoutCancel(ctx), "DROP DATABASE IF EXISTS "+sqlident.Quote(name)); err != nil {
+					errs = append(errs, fmt.Errorf("failed to drop database %s: %w", name, err))
+				}
+			}
+			_ = db.Close()
+		}
+	}
+	if r.dir != "" {
+		if err := os.RemoveAll(r.dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if r.server != nil {
+		if err := r.server.Remove(); err != nil {
+			diag.Warnf("%v", err)
+		}
+	}
+	return errors.Join(errs...)
+}
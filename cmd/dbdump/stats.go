@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/stats"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsCompare string
+	statsTop     int
+	statsJSON    bool
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show table growth since an earlier dump",
+	Long: `Compare live table sizes and row estimates with the per-table numbers
+recorded in an earlier dump's metadata sidecar.
+
+--compare accepts either the sidecar itself (dump.sql.meta.json) or the dump
+file, in which case the sidecar next to it is used.`,
+	RunE: runStats,
+}
+
+func init() {
+	statsCmd.Flags().StringVar(&statsCompare, "compare", "", "Old dump or metadata sidecar to compare against (required)")
+	statsCmd.Flags().IntVar(&statsTop, "top", 10, "Number of growers and shrinkers to show")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "Output the comparison as JSON")
+	_ = statsCmd.MarkFlagRequired("compare")
+
+	rootCmd.AddCommand(statsCmd)
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	old, err := loadCompareMetadata(statsCompare)
+	if err != nil {
+		return err
+	}
+
+	resolvePassword()
+
+	if dbName == "" {
+		dbName = old.Source.Database
+	}
+	if user == "" {
+		return fmt.Errorf("database user is required (use -u or --user)")
+	}
+	if dbName == "" {
+		return fmt.Errorf("database name is required (use -d or --database)")
+	}
+
+	conn := &database.Connection{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		Database: dbName,
+	}
+
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return err
+	}
+
+	db, err := conn.ConnectContext(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	inspector, err := newInspector(cmd.Context(), db)
+	if err != nil {
+		return err
+	}
+	tablesInfo, err := inspector.GetAllTablesInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get table information: %w", err)
+	}
+	if !database.SizesKnown(tablesInfo) {
+		return fmt.Errorf("cannot compare sizes: %s", inspector.Degraded())
+	}
+
+	cmp := stats.Compare(old, tablesInfo)
+	cmp.Database = dbName
+
+	if statsJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(cmp)
+	}
+
+	printComparison(cmp, statsTop)
+	return nil
+}
+
+// loadCompareMetadata loads a sidecar given either its own path or the dump's path
+func loadCompareMetadata(path string) (*metadata.Metadata, error) {
+	if strings.HasSuffix(path, metadata.SidecarSuffix) {
+		return metadata.Load(path)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+
+	meta, err := metadata.LoadForDump(path)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, fmt.Errorf("no metadata sidecar found for %s (expected %s); per-table numbers are only recorded for dumps made with the sidecar",
+			path, metadata.SidecarPath(path))
+	}
+
+	return meta, nil
+}
+
+// printComparison prints a human-readable growth report
+func printComparison(cmp *stats.Comparison, top int) {
+	fmt.Printf("\nTable growth in '%s' since %s:\n", cmp.Database, cmp.Since.Local().Format("2006-01-02 15:04"))
+
+	printDeltaSection("Top growers", cmp.Growers, top)
+	printDeltaSection("Top shrinkers", cmp.Shrinkers, top)
+	printDeltaSection("New tables", cmp.Added, 0)
+	printDeltaSection("Removed tables", cmp.Removed, 0)
+
+	fmt.Printf("\nTotal size: %s → %s (%s)\n",
+		database.FormatBytes(cmp.OldTotalSize),
+		database.FormatBytes(cmp.NewTotalSize),
+		formatSignedBytes(cmp.NewTotalSize-cmp.OldTotalSize))
+
+	if cmp.ProjectedDumpSize > 0 {
+		fmt.Printf("Dump size:  %s → ~%s projected with the same exclusions\n",
+			database.FormatBytes(cmp.OldDumpSize),
+			database.FormatBytes(cmp.ProjectedDumpSize))
+	}
+	fmt.Println()
+}
+
+// printDeltaSection prints one section of the report; limit 0 shows all rows
+func printDeltaSection(title string, deltas []stats.TableDelta, limit int) {
+	if len(deltas) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s:\n", title)
+	fmt.Printf("  %-40s %12s %12s %12s %9s %14s\n", "Table", "Before", "After", "Change", "%", "Rows Δ")
+	fmt.Println("  " + strings.Repeat("-", 104))
+
+	for i, delta := range deltas {
+		if limit > 0 && i == limit {
+			fmt.Printf("  … and %d more\n", len(deltas)-limit)
+			break
+		}
+
+		percent := "new"
+		if delta.OldSize > 0 {
+			percent = fmt.Sprintf("%+.1f%%", delta.SizePercent)
+		}
+		name := delta.Name
+		if !delta.DataIncluded {
+			name += " (structure only)"
+		}
+
+		fmt.Printf("  %-40s %12s %12s %12s %9s %+14d\n",
+			name,
+			database.FormatBytes(delta.OldSize),
+			database.FormatBytes(delta.NewSize),
+			formatSignedBytes(delta.SizeDelta),
+			percent,
+			delta.RowsDelta)
+	}
+}
+
+// formatSignedBytes formats a byte delta with an explicit sign
+func formatSignedBytes(n int64) string {
+	if n < 0 {
+		return "-" + database.FormatBytes(-n)
+	}
+	return "+" + database.FormatBytes(n)
+}
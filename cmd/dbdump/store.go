@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/store"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/ui/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	storeDir    string
+	storeOutput string
+	storeFormat string
+)
+
+var storeCmd = &cobra.Command{
+	Use:   "store",
+	Short: "List and extract dumps kept in a content-addressed store",
+	Long: `Dumps written with dbdump dump --store are cut into one chunk per table
+section, named by its SHA-256, so tables that didn't change between dumps are
+stored once. Each dump is a snapshot listing its chunks; extract reassembles
+the SQL file and verifies every chunk. Use dbdump prune --store to delete old
+snapshots and the chunks only they used.`,
+}
+
+var storeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the snapshots in the store",
+	Args:  cobra.NoArgs,
+	RunE:  runStoreList,
+}
+
+var storeExtractCmd = &cobra.Command{
+	Use:   "extract <snapshot>",
+	Short: "Reassemble a snapshot into a plain SQL file",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runStoreExtract,
+}
+
+func init() {
+	dumpCmd.Flags().StringVar(&storeDir, "store", "", "Keep the dump in this content-addressed store instead of a plain file (see dbdump store)")
+
+	storeCmd.PersistentFlags().StringVar(&storeDir, "store", "", "Store directory (default ~/.dbdump/store)")
+	storeListCmd.Flags().StringVar(&storeFormat, "format", "table", "Output format: table, csv or json")
+	storeExtractCmd.Flags().StringVarP(&storeOutput, "output", "o", "", "File to write (default <snapshot>.sql)")
+	storeCmd.AddCommand(storeListCmd, storeExtractCmd)
+	rootCmd.AddCommand(storeCmd)
+}
+
+// validateStoreFlags rejects output options the store can't hold: chunks
+// are cut from one uncompressed file
+func validateStoreFlags(maxPartSize int64) error {
+	switch {
+	case storeDir == "":
+		return nil
+	case compressOutput:
+		return fmt.Errorf("--store keeps dumps uncompressed and cannot be combined with --compress or a .gz output name")
+	case maxPartSize > 0:
+		return fmt.Errorf("--store cannot be combined with --max-file-size")
+	case schemaDelta:
+		return fmt.Errorf("--store cannot be combined with --schema-delta")
+	}
+	return nil
+}
+
+// openStore opens the store given with --store, or the default one
+func openStore() (*store.Store, error) {
+	dir := storeDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".dbdump", "store")
+	}
+	return store.Open(dir)
+}
+
+// storeDump moves a finished dump and its sidecar into the store as a
+// snapshot named after the dump file
+func storeDump(path string, meta *metadata.Metadata) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	id := strings.TrimSuffix(filepath.Base(path), ".sql")
+	snapshot, stats, err := st.Add(path, id, meta)
+	if err != nil {
+		return fmt.Errorf("failed to store the dump (kept as %s): %w", path, err)
+	}
+
+	for _, file := range []string{path, metadata.SidecarPath(path)} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			diag.Warnf("stored the dump, but failed to delete %s: %v", file, err)
+		}
+	}
+	ui.PrintSuccess(fmt.Sprintf("Stored as snapshot %s in %s: %d chunks, %d new (%s of %s)",
+		snapshot.ID, st.Dir(), stats.Chunks, stats.NewChunks, database.FormatBytes(stats.NewBytes), database.FormatBytes(snapshot.Size)))
+	return nil
+}
+
+func runStoreList(cmd *cobra.Command, args []string) error {
+	format, err := table.ParseFormat(storeFormat)
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	snapshots, err := st.Snapshots()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 && format == table.Text {
+		fmt.Printf("No snapshots in %s\n", st.Dir())
+		return nil
+	}
+
+	out := table.New(
+		table.Column{Title: "Snapshot", MaxWidth: 50, Flex: true},
+		table.Column{Title: "Time"},
+		table.Column{Title: "Database", MaxWidth: 40, Flex: true},
+		table.Column{Title: "Size", Align: table.Right, MinWidth: 10},
+		table.Column{Title: "Chunks", Align: table.Right},
+	)
+	var total, stored int64
+	seen := make(map[string]bool)
+	for _, snapshot := range snapshots {
+		source := ""
+		if meta := snapshot.Metadata; meta != nil {
+			source = fmt.Sprintf("%s@%s:%d", meta.Source.Database, meta.Source.Host, meta.Source.Port)
+		}
+		out.Row(
+			snapshot.ID,
+			snapshot.CreatedAt.Local().Format("2006-01-02 15:04"),
+			source,
+			database.FormatBytes(snapshot.Size),
+			fmt.Sprintf("%d", len(snapshot.Chunks)),
+		)
+		total += snapshot.Size
+		for _, ref := range snapshot.Chunks {
+			if !seen[ref.SHA256] {
+				seen[ref.SHA256] = true
+				stored += ref.Size
+			}
+		}
+	}
+
+	if format != table.Text {
+		return out.Render(os.Stdout, format)
+	}
+	fmt.Println()
+	if err := out.Render(os.Stdout, format); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d snapshot(s) with %s of SQL, stored in %s of chunks\n",
+		len(snapshots), database.FormatBytes(total), database.FormatBytes(stored))
+	return nil
+}
+
+func runStoreExtract(cmd *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	snapshot, err := st.Snapshot(args[0])
+	if err != nil {
+		return err
+	}
+
+	output := storeOutput
+	if output == "" {
+		output = snapshot.ID + ".sql"
+	}
+	output, err = filepath.Abs(output)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	// Write next to the target and rename, so a corrupt chunk never leaves
+	// a truncated dump under the requested name
+	tmp, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".tmp-*")
+	if err != nil {
+		return &dberrors.ErrOutputPath{Path: filepath.Dir(output), Op: "create output file", Err: err}
+	}
+	renamed := false
+	defer func() {
+		if !renamed {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := st.Extract(snapshot, tmp); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to extract snapshot %s: %w", snapshot.ID, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	renamed = true
+
+	// The sidecar lets restore check the dump as if it had been written directly
+	if meta := snapshot.Metadata; meta != nil {
+		meta.OutputFile = output
+		if err := metadata.Write(metadata.SidecarPath(output), meta); err != nil {
+			diag.Warnf("%v", err)
+		}
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Extracted snapshot %s to %s (%s, %d chunks verified)",
+		snapshot.ID, output, database.FormatBytes(snapshot.Size), len(snapshot.Chunks)))
+	return nil
+}
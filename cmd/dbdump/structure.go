@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/structure"
+	"github.com/helgesverre/dbdump/internal/transform"
+)
+
+// loadStructureRules reads structure_rules from the project config, then
+// the global config, so project rules take precedence
+func loadStructureRules() (planner.StructureRules, error) {
+	var rules planner.StructureRules
+	var problems []string
+
+	add := func(source string, cfg *config.Config) {
+		for _, rule := range cfg.StructureRules {
+			level, err := structure.ParseLevel(rule.Structure)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", source, err))
+				continue
+			}
+			if rule.Match == "" {
+				problems = append(problems, fmt.Sprintf("%s: rule for %s structure has no match", source, level))
+				continue
+			}
+			match := patterns.NameRule(rule.Match)
+			var invalid *dberrors.ErrConfigInvalid
+			if err := patterns.Validate(match, source); errors.As(err, &invalid) {
+				problems = append(problems, fmt.Sprintf("%s: %s", source, invalid.Problems[0]))
+				continue
+			}
+			rules = append(rules, planner.StructureRule{Matcher: patterns.NewMatcher(match), Level: level})
+		}
+	}
+
+	if len(configFiles) > 0 {
+		projectConfig, err := loadProjectConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file: %w", err)
+		}
+		add(configSource(), projectConfig)
+	}
+	globalConfig, err := config.LoadGlobalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load global config: %w", err)
+	}
+	if globalConfig != nil {
+		add("global config", globalConfig)
+	}
+
+	if len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "structure_rules", Problems: problems}
+	}
+	return rules, nil
+}
+
+// structureTransform returns the transform applying levels, or nil when no
+// table is reduced. Foreign keys to tables that are excluded or skipped as
+// well are dropped at the no-indexes level.
+func structureTransform(levels map[string]structure.Level, excludes, skipped []string) transform.Func {
+	if len(levels) == 0 {
+		return nil
+	}
+	excluded := make(map[string]bool, len(excludes)+len(skipped))
+	for _, table := range append(append([]string{}, excludes...), skipped...) {
+		excluded[table] = true
+	}
+	return structure.Transform(levels, excluded)
+}
+
+// transformFilter returns a stream filter running the non-nil transforms,
+// in order, on each statement, or nil when there are none
+func transformFilter(funcs ...transform.Func) func(io.Writer) io.WriteCloser {
+	var active []transform.Func
+	for _, fn := range funcs {
+		if fn != nil {
+			active = append(active, fn)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	return func(w io.Writer) io.WriteCloser {
+		return transform.NewWriter(w, active...)
+	}
+}
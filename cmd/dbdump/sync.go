@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+)
+
+var (
+	syncPolicyFlag string
+	syncPolicy     dumpfile.SyncPolicy
+)
+
+func init() {
+	dumpCmd.Flags().StringVar(&syncPolicyFlag, "sync-policy", "end", "When to sync the output to disk: end (once it is complete), interval:SIZE (also every SIZE, e.g. interval:64MB) or none")
+}
+
+// validateSyncPolicy parses --sync-policy
+func validateSyncPolicy() error {
+	policy, err := dumpfile.ParseSyncPolicy(syncPolicyFlag)
+	if err != nil {
+		return &dberrors.ErrConfigInvalid{Source: "--sync-policy", Problems: []string{err.Error()}}
+	}
+	syncPolicy = policy
+	return nil
+}
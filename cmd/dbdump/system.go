@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/ui"
+)
+
+// systemDatabase allows dumping the server's own schemas
+var systemDatabase bool
+
+func init() {
+	dumpCmd.Flags().BoolVar(&systemDatabase, "system-database", false, "Allow dumping a system database (mysql, sys, information_schema, performance_schema)")
+	planCmd.Flags().BoolVar(&systemDatabase, "system-database", false, "Allow planning a dump of a system database (mysql, sys, information_schema, performance_schema)")
+}
+
+// checkSystemDatabase refuses a system database as the dump target unless
+// --system-database is given, and warns about its contents when it is
+func checkSystemDatabase() error {
+	if !database.IsSystemDatabase(dbName) {
+		return nil
+	}
+	if !systemDatabase {
+		return &dberrors.ErrConfigInvalid{
+			Source:   "--database",
+			Problems: []string{fmt.Sprintf("%s is a system database holding accounts, grants and server internals; pass --system-database to dump it anyway", dbName)},
+		}
+	}
+	ui.PrintWarning(fmt.Sprintf("Dumping system database %s: it can contain account names, password hashes and grants; keep the dump private", dbName))
+	return nil
+}
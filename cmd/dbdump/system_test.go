@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+func TestCheckSystemDatabase(t *testing.T) {
+	savedDB, savedSystem := dbName, systemDatabase
+	defer func() {
+		dbName, systemDatabase = savedDB, savedSystem
+		diag.Default.Reset()
+	}()
+
+	tests := []struct {
+		name    string
+		db      string
+		allow   bool
+		refused bool
+		warned  bool
+	}{
+		{name: "application database", db: "shop"},
+		{name: "application database with the flag", db: "shop", allow: true},
+		{name: "mysql refused", db: "mysql", refused: true},
+		{name: "sys refused", db: "sys", refused: true},
+		{name: "any case refused", db: "Performance_Schema", refused: true},
+		{name: "mysql allowed", db: "mysql", allow: true, warned: true},
+		{name: "information_schema allowed", db: "information_schema", allow: true, warned: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diag.Default.Reset()
+			dbName, systemDatabase = tt.db, tt.allow
+
+			err := checkSystemDatabase()
+			var invalid *dberrors.ErrConfigInvalid
+			if refused := errors.As(err, &invalid); refused != tt.refused {
+				t.Fatalf("checkSystemDatabase() = %v, refused %v, want %v", err, refused, tt.refused)
+			}
+			if tt.refused && !strings.Contains(err.Error(), "--system-database") {
+				t.Errorf("refusal doesn't name --system-database: %v", err)
+			}
+
+			warnings := diag.Default.Warnings()
+			if warned := len(warnings) > 0; warned != tt.warned {
+				t.Fatalf("warnings = %+v, want a warning: %v", warnings, tt.warned)
+			}
+			if tt.warned && !strings.Contains(warnings[0].Message, "password hashes") {
+				t.Errorf("warning = %q", warnings[0].Message)
+			}
+		})
+	}
+}
+
+// TestSystemDatabaseRules checks that the default and global rules, which
+// target application tables, are skipped for a system database while
+// project and flag rules still apply
+func TestSystemDatabaseRules(t *testing.T) {
+	savedDB, savedConfigs, savedJSON, savedExact, savedPatterns, savedAll, savedOrigins :=
+		dbName, configFiles, excludeJSON, excludeTables, excludePattern, excludeAllData, ruleOrigins
+	defer func() {
+		dbName, configFiles, excludeJSON, excludeTables, excludePattern, excludeAllData, ruleOrigins =
+			savedDB, savedConfigs, savedJSON, savedExact, savedPatterns, savedAll, savedOrigins
+	}()
+	t.Setenv("HOME", t.TempDir())
+	configFiles, excludeJSON, excludePattern, excludeAllData = nil, "", nil, false
+	excludeTables = []string{"general_log"}
+
+	for _, db := range []string{"shop", "mysql"} {
+		ruleOrigins = make(config.Origins)
+		dbName = db
+		rules, err := buildExcludeConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hasDefaults := len(rules.Exact)+len(rules.Patterns) > 1
+		if system := db == "mysql"; hasDefaults == system {
+			t.Errorf("%s: rules = %+v, default rules applied: %v", db, rules, hasDefaults)
+		}
+		if !slices.Contains(rules.Exact, "general_log") {
+			t.Errorf("%s: --exclude rule missing from %+v", db, rules)
+		}
+	}
+}
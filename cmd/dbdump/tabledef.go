@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/ui"
+)
+
+var tableDefRetries int
+
+func init() {
+	dumpCmd.Flags().IntVar(&tableDefRetries, "table-def-retries", 2, "Restart a dump phase this many times when a table is altered mid-dump (0 to fail immediately)")
+}
+
+// tableDefRetryHook returns the dumper's BeforeRetry callback: it refreshes
+// the table list and reconciles the exclude and skip lists with it
+func tableDefRetryHook(inspector *database.Inspector, sel *planner.Selection, excludes, skips []string) func(string) ([]string, []string, error) {
+	known := make(map[string]bool, len(sel.All))
+	for _, info := range sel.All {
+		known[info.Name] = true
+	}
+
+	return func(table string) ([]string, []string, error) {
+		tablesInfo, err := inspector.GetAllTablesInfo()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var uncovered []string
+		for _, info := range tablesInfo {
+			if known[info.Name] {
+				continue
+			}
+			known[info.Name] = true
+
+			switch action, rule := classifyNewTable(sel, info.Name); action {
+			case newTableSkipped:
+				skips = append(skips, info.Name)
+				ui.PrintInfo(fmt.Sprintf("New table %s appeared during the dump; skipping it (not selected)", info.Name))
+			case newTableExcluded:
+				excludes = append(excludes, info.Name)
+				ui.PrintInfo(fmt.Sprintf("New table %s appeared during the dump; excluding its data (matches exclusion rule %s)",
+					info.Name, rule))
+			default:
+				uncovered = append(uncovered, info.Name)
+			}
+		}
+
+		if len(uncovered) > 0 {
+			ui.PrintWarning(fmt.Sprintf("%d new tables appeared during the dump and match no rule; their data will be dumped: %s",
+				len(uncovered), strings.Join(uncovered, ", ")))
+		}
+		return excludes, skips, nil
+	}
+}
+
+// reportTableDefChange prints the current definition of a table that kept
+// changing during the dump, so the migration responsible can be identified
+func reportTableDefChange(inspector *database.Inspector, err error) {
+	var defErr *dberrors.ErrTableDefChanged
+	if !errors.As(err, &defErr) || defErr.Table == "" {
+		return
+	}
+
+	ddl, ddlErr := inspector.GetCreateTable(defErr.Table)
+	if ddlErr != nil {
+		ui.PrintWarning(fmt.Sprintf("Could not read the current definition of %s: %v", defErr.Table, ddlErr))
+		return
+	}
+	fmt.Printf("\nCurrent definition of %s:\n%s\n", defErr.Table, ddl)
+}
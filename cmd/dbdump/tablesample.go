@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// sampleFlags are the --sample table=N values
+var sampleFlags []string
+
+// loadSampleRules reads --sample, then sample from the project config,
+// then the global config, so the first matching rule wins in that order
+func loadSampleRules() (planner.SampleRules, error) {
+	var rules planner.SampleRules
+	var problems []string
+
+	add := func(source, match string, rows int) {
+		if rows <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: sample of %s must be at least 1 row", source, match))
+			return
+		}
+		pattern := patterns.NameRule(match)
+		var invalid *dberrors.ErrConfigInvalid
+		if err := patterns.Validate(pattern, source); errors.As(err, &invalid) {
+			problems = append(problems, fmt.Sprintf("%s: %s", source, invalid.Problems[0]))
+			return
+		}
+		rules = append(rules, planner.SampleRule{Matcher: patterns.NewMatcher(pattern), Rows: rows})
+	}
+	addConfig := func(source string, cfg *config.Config) {
+		matches := make([]string, 0, len(cfg.Sample))
+		for match := range cfg.Sample {
+			matches = append(matches, match)
+		}
+		// Exact names before patterns, so a table's own rule wins
+		sort.Slice(matches, func(i, j int) bool {
+			iPattern, jPattern := strings.ContainsAny(matches[i], "*?"), strings.ContainsAny(matches[j], "*?")
+			if iPattern != jPattern {
+				return jPattern
+			}
+			return matches[i] < matches[j]
+		})
+		for _, match := range matches {
+			add(source, match, cfg.Sample[match])
+		}
+	}
+
+	for _, value := range sampleFlags {
+		table, count, ok := strings.Cut(value, "=")
+		rows, err := strconv.Atoi(count)
+		if !ok || table == "" || err != nil {
+			problems = append(problems, fmt.Sprintf("--sample %q: expected table=N", value))
+			continue
+		}
+		add("--sample", table, rows)
+	}
+	if len(configFiles) > 0 {
+		projectConfig, err := loadProjectConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file: %w", err)
+		}
+		addConfig(configSource(), projectConfig)
+	}
+	globalConfig, err := config.LoadGlobalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load global config: %w", err)
+	}
+	if globalConfig != nil {
+		addConfig("global config", globalConfig)
+	}
+
+	if len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "sample", Problems: problems}
+	}
+	return rules, nil
+}
+
+// tableSamples looks up the primary key of each sampled table so its most
+// recent rows are dumped; tables without one get an arbitrary LIMIT
+func tableSamples(inspector *database.Inspector, excludes []string, counts map[string]int) ([]database.TableSample, error) {
+	var samples []database.TableSample
+	for _, table := range excludes {
+		rows, ok := counts[table]
+		if !ok {
+			continue
+		}
+		key, err := inspector.GetPrimaryKey(table)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 {
+			diag.Warnf("table %s has no primary key; sampling %d arbitrary rows instead of the last ones", table, rows)
+		}
+		samples = append(samples, database.TableSample{Table: table, Rows: rows, OrderBy: key})
+	}
+	return samples, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/units"
+)
+
+var (
+	maxTableSize units.Size
+	allowPartial bool
+)
+
+func init() {
+	dumpCmd.Flags().Var(&maxTableSize, "max-table-size", "Stop dumping a table's data once it reaches this size (e.g. 500MB); the dump is named .partial.sql")
+	restoreCmd.Flags().BoolVar(&allowPartial, "allow-partial", false, "Restore a dump whose tables were truncated by --max-table-size")
+}
+
+// truncatedTables returns the tables the size limit cut off, for the metadata
+func truncatedTables(result *database.DumpResult) []metadata.TruncatedTable {
+	var truncated []metadata.TruncatedTable
+	for _, size := range result.TableSizes {
+		if size.Truncated {
+			truncated = append(truncated, metadata.TruncatedTable{Table: size.Table, Bytes: size.Bytes, Rows: size.Rows})
+		}
+	}
+	return truncated
+}
+
+// markPartial renames the output of a dump with truncated tables to its
+// .partial.sql name so it isn't mistaken for a complete dump
+func markPartial(result *database.DumpResult) error {
+	base := dumpfile.PartialPath(result.OutputFile)
+	if len(result.Parts) == 0 {
+		if err := os.Rename(result.OutputFile, base); err != nil {
+			return fmt.Errorf("failed to rename partial dump: %w", err)
+		}
+		result.OutputFile = base
+		return syncRenames(base)
+	}
+
+	for i := range result.Parts {
+		path := dumpfile.PartPath(base, i+1)
+		if err := os.Rename(result.Parts[i].Path, path); err != nil {
+			return fmt.Errorf("failed to rename partial dump part: %w", err)
+		}
+		result.Parts[i].Path = path
+	}
+	result.OutputFile = base
+	return syncRenames(base)
+}
+
+// syncRenames makes the renames of markPartial durable, unless the sync
+// policy is none
+func syncRenames(base string) error {
+	if syncPolicy.Never {
+		return nil
+	}
+	if err := fileutil.SyncDir(filepath.Dir(base)); err != nil {
+		return fmt.Errorf("failed to sync the output directory: %w", err)
+	}
+	return nil
+}
+
+// printTableSizes prints the n tables with the most data and flags the
+// truncated ones
+func printTableSizes(sizes []database.TableSize, n int) {
+	if len(sizes) == 0 {
+		return
+	}
+	shown := sizes[:min(n, len(sizes))]
+
+	fmt.Printf("\nLargest tables (data written):\n")
+	fmt.Printf("  %-40s %12s %14s\n", "Table", "Size", "Rows")
+	fmt.Println("  " + strings.Repeat("-", 70))
+	for _, size := range shown {
+		note := ""
+		if size.Truncated {
+			note = "  truncated"
+		}
+		fmt.Printf("  %-40s %12s %14d%s\n", ui.Truncate(size.Table, 40), database.FormatBytes(size.Bytes), size.Rows, note)
+	}
+	fmt.Println()
+}
+
+// checkPartialDump refuses a dump with truncated tables unless
+// --allow-partial is given
+func checkPartialDump(inputFile string) error {
+	reason := ""
+	if dumpfile.IsPartial(inputFile) {
+		reason = "its name marks it as incomplete"
+	}
+	if meta, err := metadata.LoadForDump(inputFile); err == nil && meta != nil && len(meta.TruncatedTables) > 0 {
+		reason = fmt.Sprintf("the data of %d table(s) was truncated at --max-table-size", len(meta.TruncatedTables))
+	}
+	if reason == "" {
+		return nil
+	}
+
+	if !allowPartial {
+		return fmt.Errorf("refusing to restore a partial dump: %s (use --allow-partial to restore it anyway)", reason)
+	}
+	ui.PrintWarning(fmt.Sprintf("Restoring a partial dump: %s", reason))
+	return nil
+}
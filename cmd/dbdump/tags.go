@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/helgesverre/dbdump/internal/tags"
+)
+
+var (
+	tagSpecs []string
+
+	// dumpTags are the validated --tag values of the current dump
+	dumpTags map[string]string
+)
+
+func init() {
+	dumpCmd.Flags().StringArrayVar(&tagSpecs, "tag", []string{}, "Label the dump with key=value, recorded in the sidecar, SQL header and history (repeatable)")
+}
+
+// tagHeader returns the SQL comment embedding the tags at the top of the dump
+func tagHeader(dumpTags map[string]string) string {
+	if len(dumpTags) == 0 {
+		return ""
+	}
+	return "-- dbdump tags: " + tags.Format(dumpTags) + "\n"
+}
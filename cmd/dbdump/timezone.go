@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/timezone"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+var skipTZUTC bool
+
+func init() {
+	dumpCmd.Flags().BoolVar(&skipTZUTC, "skip-tz-utc", false, "Dump TIMESTAMP values in the server's time zone instead of UTC (mysqldump --skip-tz-utc)")
+}
+
+// tzUTCArgs returns the mysqldump arguments for the --skip-tz-utc choice
+func tzUTCArgs() []string {
+	if skipTZUTC {
+		return []string{"--skip-tz-utc"}
+	}
+	return nil
+}
+
+// checkTimeZones compares the server's time zone with the client's and
+// says what that means for TIMESTAMP values. It returns the facts for the
+// header and sidecar, or nil when the server's zone can't be read.
+func checkTimeZones(inspector *database.Inspector) *metadata.TimeZones {
+	server, err := inspector.GetTimeZone()
+	if err != nil {
+		diag.Warnf("%v", err)
+		return nil
+	}
+	now := time.Now()
+	client := timezone.Client(now)
+	zones := &metadata.TimeZones{
+		Server:       server.Name,
+		ServerOffset: timezone.FormatOffset(server.Offset),
+		Client:       client.Name,
+		ClientOffset: timezone.FormatOffset(client.Offset),
+		TZUTC:        !skipTZUTC,
+	}
+	if timezone.Same(server, client, now) {
+		return zones
+	}
+
+	message := fmt.Sprintf("Server time zone %s differs from this machine's %s", server, client)
+	if zones.TZUTC {
+		ui.PrintInfo(message + "; TIMESTAMP values are dumped in UTC (--tz-utc is in effect), so they restore unchanged anywhere")
+	} else {
+		diag.Warnf("%s, and --skip-tz-utc dumps TIMESTAMP values in the server's zone: restored on a server in another zone they shift", message)
+	}
+	return zones
+}
+
+// checkTimeZoneChange re-reads the server's UTC offset after the dump and
+// records whether it changed while the dump ran (a daylight-saving
+// transition), which makes TIMESTAMP values dumped in local time ambiguous
+func checkTimeZoneChange(inspector *database.Inspector, zones *metadata.TimeZones) {
+	if zones == nil {
+		return
+	}
+	server, err := inspector.GetTimeZone()
+	if err != nil {
+		diag.Warnf("%v", err)
+		return
+	}
+	offset := timezone.FormatOffset(server.Offset)
+	if offset == zones.ServerOffset {
+		return
+	}
+	zones.OffsetChanged = true
+	message := fmt.Sprintf("The server's UTC offset changed from %s to %s during the dump (a daylight-saving transition in %s)", zones.ServerOffset, offset, zones.Server)
+	if zones.TZUTC {
+		ui.PrintInfo(message + "; TIMESTAMP values were dumped in UTC and are not affected")
+	} else {
+		diag.Warnf("%s; with --skip-tz-utc, TIMESTAMP values from the repeated or skipped hour are ambiguous", message)
+	}
+}
+
+// timeZoneHeader returns the dump header line recording the time zones
+func timeZoneHeader(zones *metadata.TimeZones) string {
+	if zones == nil {
+		return ""
+	}
+	values := "TIMESTAMP values in UTC"
+	if !zones.TZUTC {
+		values = "TIMESTAMP values in server time (--skip-tz-utc)"
+	}
+	return fmt.Sprintf("-- Time zones: server %s (%s), client %s (%s); %s\n",
+		zones.Server, zones.ServerOffset, zones.Client, zones.ClientOffset, values)
+}
+
+// checkTargetTimeZone warns when the target server's time zone differs
+// from the source's recorded in the dump's metadata. Dumps in UTC restore
+// TIMESTAMP values unchanged; dumps taken with --skip-tz-utc don't.
+func checkTargetTimeZone(ctx context.Context, inputFile string, target *database.Connection) {
+	meta, err := metadata.LoadForDump(inputFile)
+	if err != nil || meta == nil || meta.TimeZones == nil {
+		return
+	}
+	zones := meta.TimeZones
+
+	// The target database may not exist yet
+	server := *target
+	server.Database = ""
+	db, err := server.ConnectContext(ctx)
+	if err != nil {
+		diag.Warnf("could not check the target's time zone: %v", err)
+		return
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	current, err := database.NewInspector(db).WithContext(ctx).GetTimeZone()
+	if err != nil {
+		diag.Warnf("%v", err)
+		return
+	}
+	offset, _ := timezone.ParseOffset(zones.ServerOffset)
+	source := timezone.Server(zones.Server, "", offset)
+	if timezone.Same(source, current, time.Now()) {
+		return
+	}
+
+	if zones.TZUTC {
+		ui.PrintInfo(fmt.Sprintf("Target time zone %s differs from the source's %s; TIMESTAMP values were dumped in UTC and restore unchanged", current, source))
+		return
+	}
+	diag.Warnf("the dump was taken with --skip-tz-utc on a server in %s, but the target is in %s: TIMESTAMP values will shift. Set the target's time_zone to %s for the restore, or dump again without --skip-tz-utc",
+		source, current, zones.ServerOffset)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/history"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+var (
+	listTrend     bool
+	listTrendRuns int
+)
+
+func init() {
+	listCmd.Flags().BoolVar(&listTrend, "trend", false, "Show each table's size across recent dumps as a sparkline with the change since the oldest")
+	listCmd.Flags().IntVar(&listTrendRuns, "trend-runs", 10, "Number of recent dumps to include in --trend")
+}
+
+// loadSizeHistory returns the table sizes recorded by recent dumps of the
+// selected database, oldest first, or nil (after a note) when there are none
+func loadSizeHistory() []map[string]int64 {
+	entries, err := history.Load()
+	if err != nil {
+		diag.Warnf("%v", err)
+		return nil
+	}
+
+	runs := history.SizeHistory(history.ForDatabase(entries, host, port, dbName), max(1, listTrendRuns))
+	if len(runs) == 0 {
+		ui.PrintInfo(fmt.Sprintf("No recorded dumps of '%s' with table sizes; the trend column appears after the next dump", dbName))
+	}
+	return runs
+}
+
+// formatTrend renders a table's sparkline (recorded runs plus its current
+// size) followed by the change since the oldest run, or "new" for tables
+// added since
+func formatTrend(runs []map[string]int64, info database.TableInfo) string {
+	trend := history.Trend(runs, info.Name, info.TotalSize)
+	spark := ui.PadRight(ui.Sparkline(trend.Sizes), len(runs)+1)
+	if trend.New {
+		return spark + " " + fmt.Sprintf("%8s", "new")
+	}
+	change, ok := trend.Change()
+	if !ok {
+		return spark + " " + fmt.Sprintf("%8s", "-")
+	}
+	return spark + " " + fmt.Sprintf("%+7.1f%%", change*100)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/plan"
+	"github.com/helgesverre/dbdump/internal/planner"
+	"github.com/helgesverre/dbdump/internal/triggers"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// Modes of --restore-trigger-mode
+const (
+	triggerModeKeep     = "keep"
+	triggerModeDefer    = "defer"
+	triggerModeNoteOnly = "note-only"
+)
+
+var restoreTriggerMode string
+
+func init() {
+	dumpCmd.Flags().StringVar(&restoreTriggerMode, "restore-trigger-mode", triggerModeKeep, "For triggers that write tables without data: keep (warn), defer (check they are created after all data) or note-only (sidecar only)")
+}
+
+// validateTriggerMode checks --restore-trigger-mode
+func validateTriggerMode() error {
+	switch restoreTriggerMode {
+	case triggerModeKeep, triggerModeDefer, triggerModeNoteOnly:
+		return nil
+	}
+	return &dberrors.ErrConfigInvalid{
+		Source:   "--restore-trigger-mode",
+		Problems: []string{fmt.Sprintf("unknown mode %q (supported: keep, defer, note-only)", restoreTriggerMode)},
+	}
+}
+
+// checkTriggerEffects finds the triggers through which writes to tables
+// with data reach tables whose data is excluded or skipped, and reports
+// them as --restore-trigger-mode says. The dump creates triggers after all
+// data, so restoring it fires none of them; the rows written afterwards do.
+func checkTriggerEffects(inspector *database.Inspector, dp *planner.DumpPlan) []triggers.Effect {
+	// Native dumps have no triggers
+	if nativeDump {
+		return nil
+	}
+	found, err := inspector.GetTriggers()
+	if err != nil {
+		diag.Warnf("triggers not checked for writes to excluded tables: %v", err)
+		return nil
+	}
+	if len(found) == 0 {
+		return nil
+	}
+
+	var data, excluded, skipped, hidden []string
+	for _, table := range dp.Tables {
+		switch table.Disposition {
+		case plan.DispositionSkipped:
+			skipped = append(skipped, table.Name)
+		case plan.DispositionStructureOnly:
+			excluded = append(excluded, table.Name)
+		default:
+			data = append(data, table.Name)
+		}
+	}
+	for _, trigger := range found {
+		if trigger.Body == "" {
+			hidden = append(hidden, trigger.Name)
+		}
+	}
+	if len(hidden) > 0 {
+		diag.Warnf("the bodies of %d trigger(s) are hidden from this user, so what they write is unknown: %s", len(hidden), strings.Join(hidden, ", "))
+	}
+
+	effects := triggers.Effects(found, dp.Database, data, excluded, skipped)
+	if len(effects) == 0 {
+		return nil
+	}
+	switch restoreTriggerMode {
+	case triggerModeKeep:
+		for _, effect := range effects {
+			diag.Warnf("%s (--restore-trigger-mode)", describeTriggerEffect(effect))
+		}
+	case triggerModeDefer:
+		ui.PrintInfo(fmt.Sprintf("%d trigger chain(s) write tables without data; the dump creates triggers after all data, which is checked once it is written:", len(effects)))
+		for _, effect := range effects {
+			fmt.Printf("    %s\n", describeTriggerEffect(effect))
+		}
+	case triggerModeNoteOnly:
+		ui.PrintInfo(fmt.Sprintf("%d trigger chain(s) write tables without data; they are listed in the sidecar", len(effects)))
+	}
+	return effects
+}
+
+// describeTriggerEffect says what the restored database does when the
+// table of an effect is written
+func describeTriggerEffect(effect triggers.Effect) string {
+	through := "trigger " + effect.Chain[0]
+	if len(effect.Chain) > 1 {
+		through = "triggers " + strings.Join(effect.Chain, " → ")
+	}
+	if effect.Skipped {
+		return fmt.Sprintf("writes to %s reach %s through %s, but %s is not in the dump: they fail once it is restored",
+			effect.Table, effect.Target, through, effect.Target)
+	}
+	return fmt.Sprintf("writes to %s reach %s through %s: the restored database fills %s, whose data is excluded, from then on",
+		effect.Table, effect.Target, through, effect.Target)
+}
+
+// verifyTriggerOrder checks, for --restore-trigger-mode=defer, that the
+// finished dump creates its triggers after all data; --verify checks this
+// already
+func verifyTriggerOrder(dumpFile string, effects []triggers.Effect) error {
+	if restoreTriggerMode != triggerModeDefer || len(effects) == 0 || verifyMode != "" {
+		return nil
+	}
+	return runOrderVerification(dumpFile)
+}
+
+// recordTriggerEffects lists the effects in the sidecar
+func recordTriggerEffects(meta *metadata.Metadata, effects []triggers.Effect) {
+	for _, effect := range effects {
+		meta.TriggerEffects = append(meta.TriggerEffects, metadata.TriggerEffect{
+			Table:   effect.Table,
+			Target:  effect.Target,
+			Chain:   slices.Clone(effect.Chain),
+			Skipped: effect.Skipped,
+		})
+	}
+}
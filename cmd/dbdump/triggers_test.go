@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/triggers"
+)
+
+func TestValidateTriggerMode(t *testing.T) {
+	saved := restoreTriggerMode
+	defer func() { restoreTriggerMode = saved }()
+
+	for _, mode := range []string{triggerModeKeep, triggerModeDefer, triggerModeNoteOnly} {
+		restoreTriggerMode = mode
+		if err := validateTriggerMode(); err != nil {
+			t.Errorf("%s: %v", mode, err)
+		}
+	}
+	restoreTriggerMode = "disable"
+	var invalid *dberrors.ErrConfigInvalid
+	if err := validateTriggerMode(); !errors.As(err, &invalid) {
+		t.Errorf("disable: error %v, want ErrConfigInvalid", err)
+	}
+}
+
+func TestDescribeTriggerEffect(t *testing.T) {
+	tests := []struct {
+		name   string
+		effect triggers.Effect
+		want   string
+	}{
+		{
+			name:   "direct",
+			effect: triggers.Effect{Table: "users", Target: "audit_log", Chain: []string{"users_ai"}},
+			want:   "writes to users reach audit_log through trigger users_ai: the restored database fills audit_log, whose data is excluded, from then on",
+		},
+		{
+			name:   "chained",
+			effect: triggers.Effect{Table: "orders", Target: "audit_log", Chain: []string{"orders_ai", "order_log_ai"}},
+			want:   "writes to orders reach audit_log through triggers orders_ai → order_log_ai: the restored database fills audit_log, whose data is excluded, from then on",
+		},
+		{
+			name:   "skipped",
+			effect: triggers.Effect{Table: "users", Target: "legacy_log", Chain: []string{"users_ai"}, Skipped: true},
+			want:   "writes to users reach legacy_log through trigger users_ai, but legacy_log is not in the dump: they fail once it is restored",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeTriggerEffect(tt.effect); got != tt.want {
+				t.Errorf("describeTriggerEffect =\n %q, want\n %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordTriggerEffects(t *testing.T) {
+	effects := []triggers.Effect{
+		{Table: "orders", Target: "audit_log", Chain: []string{"orders_ai", "order_log_ai"}},
+		{Table: "users", Target: "legacy_log", Chain: []string{"users_ai"}, Skipped: true},
+	}
+	var meta metadata.Metadata
+	recordTriggerEffects(&meta, effects)
+
+	want := []metadata.TriggerEffect{
+		{Table: "orders", Target: "audit_log", Chain: []string{"orders_ai", "order_log_ai"}},
+		{Table: "users", Target: "legacy_log", Chain: []string{"users_ai"}, Skipped: true},
+	}
+	if !reflect.DeepEqual(meta.TriggerEffects, want) {
+		t.Errorf("TriggerEffects = %+v, want %+v", meta.TriggerEffects, want)
+	}
+	effects[0].Chain[0] = "changed"
+	if meta.TriggerEffects[0].Chain[0] != "orders_ai" {
+		t.Error("the sidecar shares its chain with the effect")
+	}
+}
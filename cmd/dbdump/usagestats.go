@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	osuser "os/user"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+// defaultStatsKeyEnv holds the anonymization key unless stats_export.key_env names another variable
+const defaultStatsKeyEnv = "DBDUMP_STATS_KEY"
+
+var (
+	aggregateTop  int
+	aggregateJSON bool
+)
+
+var statsAggregateCmd = &cobra.Command{
+	Use:   "aggregate <dir>",
+	Short: "Summarize the usage records in a stats_export directory",
+	Long: `Merge the per-user usage files that dumps append to the stats_export
+directory into one report: run and failure counts, median duration and size,
+and the most excluded tables.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runStatsAggregate,
+}
+
+func init() {
+	statsAggregateCmd.Flags().IntVar(&aggregateTop, "top", 10, "Number of most excluded tables to show")
+	statsAggregateCmd.Flags().BoolVar(&aggregateJSON, "json", false, "Output the summary as JSON")
+	statsCmd.AddCommand(statsAggregateCmd)
+}
+
+// usageRun collects what the usage record of a dump needs as the dump goes on
+type usageRun struct {
+	started time.Time
+	tables  int
+	result  *database.DumpResult
+}
+
+// statsExportConfig returns stats_export from the project or global config
+func statsExportConfig() config.StatsExportConfig {
+	if len(configFiles) > 0 {
+		if projectConfig, err := loadProjectConfig(); err == nil && projectConfig.StatsExport.Dir != "" {
+			return projectConfig.StatsExport
+		}
+	}
+	if globalConfig, err := config.LoadGlobalConfig(); err == nil && globalConfig != nil {
+		return globalConfig.StatsExport
+	}
+	return config.StatsExportConfig{}
+}
+
+// finish appends the run's anonymized record when stats_export is configured
+func (r *usageRun) finish(err error) {
+	export := statsExportConfig()
+	if export.Dir == "" || dryRun {
+		return
+	}
+
+	keyEnv := export.KeyEnv
+	if keyEnv == "" {
+		keyEnv = defaultStatsKeyEnv
+	}
+	anonymizer, keyErr := usage.NewAnonymizer(os.Getenv(keyEnv))
+	if keyErr != nil {
+		diag.Warnf("stats_export is set but %s is empty; no usage record was written", keyEnv)
+		return
+	}
+
+	record := usage.Record{
+		Time:           time.Now().UTC(),
+		Version:        Version,
+		Database:       anonymizer.Database(database.NormalizeHost(host), port, dbName),
+		Outcome:        usage.OutcomeOK,
+		DurationMillis: time.Since(r.started).Milliseconds(),
+		Tables:         r.tables,
+	}
+	switch {
+	case interrupted(err):
+		record.Outcome = usage.OutcomeInterrupted
+	case err != nil:
+		record.Outcome = usage.OutcomeFailed
+	}
+	if r.result != nil {
+		record.DurationMillis = r.result.Duration.Milliseconds()
+		record.FileSize = r.result.FileSize
+		record.ExcludedTables = r.result.ExcludedTables
+	}
+
+	if err := usage.Append(anonymizer.UserFile(export.Dir, currentUser(), machineName()), record); err != nil {
+		diag.Warnf("%v", err)
+	}
+}
+
+// currentUser returns the login name, or "" when it can't be determined
+func currentUser() string {
+	if u, err := osuser.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// machineName returns the host name, or "" when it can't be determined
+func machineName() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+func runStatsAggregate(cmd *cobra.Command, args []string) error {
+	summary, err := usage.Aggregate(args[0], aggregateTop)
+	if err != nil {
+		return err
+	}
+	if summary.Skipped > 0 {
+		diag.Warnf("skipped %d unreadable lines", summary.Skipped)
+	}
+
+	if aggregateJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+	}
+
+	fmt.Printf("\n%d runs by %d people on %d databases", summary.Runs, summary.Files, summary.Databases)
+	if summary.Runs > 0 {
+		fmt.Printf(" (%s to %s)", summary.First.Local().Format("2006-01-02"), summary.Last.Local().Format("2006-01-02"))
+	}
+	fmt.Println()
+	fmt.Println()
+
+	versions := make([]string, 0, len(summary.Versions))
+	for version, runs := range summary.Versions {
+		versions = append(versions, fmt.Sprintf("%s (%d)", version, runs))
+	}
+	sort.Strings(versions)
+
+	fmt.Printf("  %-17s %s\n", "Median duration:", summary.MedianDuration.Round(time.Second))
+	fmt.Printf("  %-17s %s\n", "Median size:", database.FormatBytes(summary.MedianFileSize))
+	fmt.Printf("  %-17s %.1f%% (%d failed, %d interrupted)\n", "Failure rate:", summary.FailureRate*100, summary.Failed, summary.Interrupted)
+	fmt.Printf("  %-17s %s\n", "Versions:", strings.Join(versions, ", "))
+
+	if len(summary.TopExcluded) > 0 {
+		fmt.Println("\nMost excluded tables:")
+		for _, table := range summary.TopExcluded {
+			fmt.Printf("  %-40s %d runs\n", table.Table, table.Runs)
+		}
+	}
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/usage"
+)
+
+// TestUsageRunFinish checks that a usage record is written only when
+// stats_export is configured and a key is available
+func TestUsageRunFinish(t *testing.T) {
+	savedConfigs, savedDryRun, savedOutput := configFiles, dryRun, diag.Output
+	savedHost, savedPort, savedDB := host, port, dbName
+	defer func() {
+		configFiles, dryRun, diag.Output = savedConfigs, savedDryRun, savedOutput
+		host, port, dbName = savedHost, savedPort, savedDB
+		diag.Default.Reset()
+	}()
+	var output bytes.Buffer
+	diag.Output = &output
+	configFiles = nil
+	host, port, dbName = "db.internal", 3306, "shop"
+
+	tests := []struct {
+		name    string
+		config  string // ~/.dbdump.yaml, with $STATS for the stats directory
+		key     string
+		keyEnv  string // variable holding the key, DBDUMP_STATS_KEY if empty
+		dryRun  bool
+		err     error
+		want    string // outcome of the record, none written if empty
+		warning string
+	}{
+		{name: "no config", key: "team-key"},
+		{name: "no stats_export", config: "default_rules_version: 3\n", key: "team-key"},
+		{name: "written", config: "stats_export:\n  dir: $STATS\n", key: "team-key", want: usage.OutcomeOK},
+		{name: "failed run", config: "stats_export:\n  dir: $STATS\n", key: "team-key", err: errors.New("boom"), want: usage.OutcomeFailed},
+		{name: "dry run", config: "stats_export:\n  dir: $STATS\n", key: "team-key", dryRun: true},
+		{
+			name:    "no key",
+			config:  "stats_export:\n  dir: $STATS\n",
+			warning: "stats_export is set but DBDUMP_STATS_KEY is empty; no usage record was written",
+		},
+		{
+			name:   "key in another variable",
+			config: "stats_export:\n  dir: $STATS\n  key_env: TEAM_STATS_KEY\n",
+			key:    "team-key", keyEnv: "TEAM_STATS_KEY",
+			want: usage.OutcomeOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output.Reset()
+			diag.Default.Reset()
+			dryRun = tt.dryRun
+			home := t.TempDir()
+			stats := filepath.Join(t.TempDir(), "stats")
+			if err := os.Mkdir(stats, 0755); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("HOME", home)
+			t.Setenv("DBDUMP_STATS_KEY", "")
+			keyEnv := tt.keyEnv
+			if keyEnv == "" {
+				keyEnv = "DBDUMP_STATS_KEY"
+			}
+			t.Setenv(keyEnv, tt.key)
+			if tt.config != "" {
+				config := strings.ReplaceAll(tt.config, "$STATS", stats)
+				if err := os.WriteFile(filepath.Join(home, ".dbdump.yaml"), []byte(config), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			run := &usageRun{
+				started: time.Now(),
+				tables:  3,
+				result:  &database.DumpResult{Duration: 2 * time.Second, FileSize: 4096, ExcludedTables: []string{"sessions"}},
+			}
+			if tt.err != nil {
+				run.result = nil
+			}
+			run.finish(tt.err)
+
+			entries, err := os.ReadDir(stats)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if len(entries) > 0 {
+					t.Errorf("wrote %s, want nothing", entries[0].Name())
+				}
+			} else {
+				summary, err := usage.Aggregate(stats, 10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if summary.Runs != 1 || (tt.want == usage.OutcomeFailed) != (summary.Failed == 1) {
+					t.Errorf("summary = %+v, want one %s run", summary, tt.want)
+				}
+				data, _ := os.ReadFile(filepath.Join(stats, entries[0].Name()))
+				for _, secret := range []string{"db.internal", "shop\"", "3306"} {
+					if strings.Contains(string(data), secret) {
+						t.Errorf("record %s reveals %s", data, secret)
+					}
+				}
+			}
+
+			warnings := diag.Warnings()
+			switch {
+			case tt.warning == "" && len(warnings) > 0:
+				t.Errorf("warned %q", warnings[0].Message)
+			case tt.warning != "" && (len(warnings) != 1 || warnings[0].Message != tt.warning):
+				t.Errorf("warnings = %v, want %q", warnings, tt.warning)
+			}
+
+			// Nothing is written next to the config either
+			homeEntries, _ := os.ReadDir(home)
+			for _, entry := range homeEntries {
+				if entry.Name() != ".dbdump.yaml" {
+					t.Errorf("wrote %s in the home directory", entry.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/variables"
+)
+
+// captureVariables reads the source's values of the variables restore
+// compares, or nil when they can't be read
+func captureVariables(inspector *database.Inspector) map[string]string {
+	values, err := inspector.GetVariables(variables.Names())
+	if err != nil {
+		diag.Warnf("%v", err)
+		return nil
+	}
+	return variables.Fill(values)
+}
+
+// variablesHeader returns the dump header lines recording the variables
+func variablesHeader(values map[string]string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	var header strings.Builder
+	header.WriteString("-- Server variables:\n")
+	for _, name := range variables.Names() {
+		if value, ok := values[name]; ok {
+			fmt.Fprintf(&header, "--   %s = %s\n", name, displayValue(value))
+		}
+	}
+	return header.String()
+}
+
+// displayValue quotes an empty value (an empty sql_mode) so it shows
+func displayValue(value string) string {
+	if value == "" {
+		return "''"
+	}
+	return value
+}
+
+// checkTargetVariables warns about each recorded variable whose value on
+// the target server differs from the source's in a way that shows after
+// the restore
+func checkTargetVariables(ctx context.Context, inputFile string, target *database.Connection) {
+	meta, err := metadata.LoadForDump(inputFile)
+	if err != nil || meta == nil || len(meta.ServerVariables) == 0 {
+		return
+	}
+
+	// The target database may not exist yet
+	server := *target
+	server.Database = ""
+	db, err := server.ConnectContext(ctx)
+	if err != nil {
+		diag.Warnf("could not read the target's server variables: %v", err)
+		return
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+
+	values, err := database.NewInspector(db).WithContext(ctx).GetVariables(variables.Names())
+	if err != nil {
+		diag.Warnf("%v", err)
+		return
+	}
+	for _, difference := range variables.Compare(meta.ServerVariables, variables.Fill(values)) {
+		diag.Warnf("%s is %s on the target but was %s on the source: %s",
+			difference.Name, displayValue(difference.Target), displayValue(difference.Source), difference.Symptom)
+	}
+}
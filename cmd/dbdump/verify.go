@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/verify"
+)
+
+// verifySampleSize is the number of tables compared after a restore verification
+const verifySampleSize = 5
+
+// prepareRestoreVerification checks that Docker is usable and records checksums
+// for a sample of data-included tables. When Docker is unusable it prints the
+// reason and falls back to the order check.
+func prepareRestoreVerification(inspector *database.Inspector, tablesInfo []database.TableInfo, excludes []string) ([]metadata.TableChecksum, string) {
+	if err := verify.CheckDocker(); err != nil {
+		ui.PrintInfo(fmt.Sprintf("Skipping restore verification: %v", err))
+		return nil, "order"
+	}
+
+	excluded := make(map[string]bool, len(excludes))
+	for _, table := range excludes {
+		excluded[table] = true
+	}
+
+	// Prefer the smallest tables that have data, keeping the checksum pass cheap
+	var candidates []database.TableInfo
+	for _, info := range tablesInfo {
+		if !excluded[info.Name] && (info.RowCount > 0 || info.SizeUnknown) {
+			candidates = append(candidates, info)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].TotalSize < candidates[j].TotalSize
+	})
+	if len(candidates) > verifySampleSize {
+		candidates = candidates[:verifySampleSize]
+	}
+
+	checksums := make([]metadata.TableChecksum, 0, len(candidates))
+	for _, info := range candidates {
+		rows, sum, err := inspector.ChecksumTable(info.Name)
+		if err != nil {
+			diag.Warnf("%v", err)
+			continue
+		}
+		checksums = append(checksums, metadata.TableChecksum{
+			Name:     info.Name,
+			RowCount: rows,
+			Checksum: sum,
+		})
+	}
+
+	return checksums, "restore"
+}
+
+// recheckChecksums takes the sampled checksums again once the dump is done.
+// They are taken before the dump, outside its snapshot, so a table written
+// to in between is marked as changed: its restored copy matches neither.
+func recheckChecksums(inspector *database.Inspector, checksums []metadata.TableChecksum) []metadata.TableChecksum {
+	for i, before := range checksums {
+		rows, sum, err := inspector.ChecksumTable(before.Name)
+		if err != nil {
+			diag.Warnf("%v", err)
+			continue
+		}
+		checksums[i].Changed = rows != before.RowCount || sum != before.Checksum
+	}
+	return checksums
+}
+
+// runOrderVerification reads a finished dump back and checks that it follows
+// the output order: structure, then each table's data in one run, then
+// triggers and events
+func runOrderVerification(dumpFile string) error {
+	violations, err := dumpfile.CheckOrder(dumpFile)
+	if err != nil {
+		ui.PrintError(err)
+		return &dberrors.ErrVerificationFailed{Checks: []string{"order"}, Err: err}
+	}
+
+	for _, violation := range violations {
+		ui.PrintFailure(fmt.Sprintf("line %d: %s", violation.Line, violation.Problem))
+	}
+	if len(violations) > 0 {
+		return &dberrors.ErrVerificationFailed{
+			Checks: []string{"order"},
+			Err:    fmt.Errorf("%d statement(s) out of order", len(violations)),
+		}
+	}
+
+	ui.PrintSuccess("Statement order verified")
+	return nil
+}
+
+// runRestoreVerification replays a finished dump into a throwaway container and
+// compares the sampled tables against the sidecar
+func runRestoreVerification(ctx context.Context, dumpFile string, meta *metadata.Metadata) error {
+	result, err := verify.VerifyRestore(ctx, verify.RestoreOptions{
+		DumpFile:     dumpFile,
+		Metadata:     meta,
+		Image:        verifyImage,
+		ShowProgress: progressEnabled(),
+	})
+	if err != nil {
+		ui.PrintError(err)
+		return &dberrors.ErrVerificationFailed{Checks: []string{"restore"}, Err: err}
+	}
+
+	failed := result.Failed()
+	checks := make([]string, 0, len(failed))
+	for _, table := range failed {
+		checks = append(checks, "checksum "+table.Name)
+	}
+
+	compared := 0
+	for _, table := range result.Tables {
+		if table.Changed {
+			ui.PrintWarning(fmt.Sprintf("%s: written to during the dump, not compared (restored %d rows)", table.Name, table.ActualRows))
+			continue
+		}
+		compared++
+		if table.OK() {
+			ui.PrintSuccess(fmt.Sprintf("%s: %d rows, checksum %d", table.Name, table.ActualRows, table.ActualChecksum))
+			continue
+		}
+		ui.PrintFailure(fmt.Sprintf("%s: expected %d rows (checksum %d), restored %d rows (checksum %d)",
+			table.Name, table.ExpectedRows, table.ExpectedChecksum, table.ActualRows, table.ActualChecksum))
+	}
+
+	if len(failed) > 0 {
+		return &dberrors.ErrVerificationFailed{
+			Checks: checks,
+			Err:    fmt.Errorf("%d of %d sampled table(s) differ after restore", len(failed), compared),
+		}
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Restore verified in %s (%d table(s) compared)", result.Image, compared))
+	return nil
+}
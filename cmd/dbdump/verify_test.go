@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// TestRecheckChecksums checks that tables written to during the dump are
+// marked as changed, and that a failed recheck leaves the table as it was
+func TestRecheckChecksums(t *testing.T) {
+	diag.Default.Reset()
+	defer diag.Default.Reset()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	checksum := func(table string, rows, sum int64) {
+		mock.ExpectQuery("CHECKSUM TABLE `" + table + "`").
+			WillReturnRows(sqlmock.NewRows([]string{"Table", "Checksum"}).AddRow("shop."+table, sum))
+		mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(rows))
+	}
+	checksum("countries", 250, 111)
+	checksum("orders", 1001, 222)
+	checksum("users", 40, 999)
+	mock.ExpectQuery("CHECKSUM TABLE `sessions`").WillReturnError(sqlmock.ErrCancelled)
+
+	got := recheckChecksums(database.NewInspector(db), []metadata.TableChecksum{
+		{Name: "countries", RowCount: 250, Checksum: 111},
+		{Name: "orders", RowCount: 1000, Checksum: 222},
+		{Name: "users", RowCount: 40, Checksum: 333},
+		{Name: "sessions", RowCount: 5, Checksum: 444},
+	})
+	want := []metadata.TableChecksum{
+		{Name: "countries", RowCount: 250, Checksum: 111},
+		{Name: "orders", RowCount: 1000, Checksum: 222, Changed: true},
+		{Name: "users", RowCount: 40, Checksum: 333, Changed: true},
+		{Name: "sessions", RowCount: 5, Checksum: 444},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("recheckChecksums() = %+v, want %+v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if warnings := diag.Default.Warnings(); len(warnings) != 1 {
+		t.Errorf("warnings = %+v, want one for sessions", warnings)
+	}
+}
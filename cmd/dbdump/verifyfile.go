@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/helgesverre/dbdump/internal/ui/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyAgainst bool
+	verifyFormat  string
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <dump file>",
+	Short: "Check a dump file for truncation before restoring it",
+	Long: `Stream through a dump file (plain, gzip or zstd, or a split dump) and report
+whether it ends with the "Dump completed on" marker, which tables it has the
+structure of, and how many INSERT statements each table has.
+
+With --against, the tables are compared with the database given by the
+connection flags (by default the one named in the dump's header): tables
+missing from the dump, and tables with rows in the database but none in the
+dump, fail the check unless the exclusion rules (-c, --exclude,
+--exclude-pattern) or the dump's sidecar say their data was left out.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVerify,
+}
+
+func init() {
+	verifyCmd.Flags().BoolVar(&verifyAgainst, "against", false, "Compare the dump's tables with the database given by the connection flags")
+	verifyCmd.Flags().StringVar(&verifyFormat, "format", "table", "Output format: table or json")
+	verifyCmd.Flags().StringArrayVarP(&configFiles, "config", "c", nil, "Config file path, repeatable (exclusion rules for --against)")
+	verifyCmd.Flags().StringArrayVar(&excludeTables, "exclude", []string{}, "Table whose data is excluded on purpose (repeatable)")
+	verifyCmd.Flags().StringArrayVar(&excludePattern, "exclude-pattern", []string{}, "Pattern of tables whose data is excluded on purpose (repeatable)")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// verifyReport is the result of dbdump verify, and its JSON output
+type verifyReport struct {
+	File      string                   `json:"file"`
+	Completed bool                     `json:"completed"`
+	Tables    []dumpfile.TableContents `json:"tables"`
+
+	// Set with --against
+	Database     string        `json:"database,omitempty"`
+	Missing      []string      `json:"missing,omitempty"`
+	WithoutData  []missingData `json:"without_data,omitempty"`
+	DataExcluded []string      `json:"data_excluded,omitempty"`
+}
+
+// missingData is a table with rows in the database but none in the dump
+type missingData struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	if verifyFormat != "table" && verifyFormat != "json" {
+		return fmt.Errorf("unsupported --format %q (supported: table, json)", verifyFormat)
+	}
+
+	contents, err := dumpfile.Inspect(args[0])
+	if err != nil {
+		return err
+	}
+	report := verifyReport{File: contents.Path, Completed: contents.Completed, Tables: contents.Tables}
+	if report.Tables == nil {
+		report.Tables = []dumpfile.TableContents{}
+	}
+
+	if verifyAgainst {
+		if err := applyProfile(cmd); err != nil {
+			return err
+		}
+		if dbName == "" {
+			dbName = contents.Database
+		}
+		if err := compareWithDatabase(cmd, &report); err != nil {
+			return err
+		}
+	}
+
+	if verifyFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else if err := printVerifyReport(contents, report); err != nil {
+		return err
+	}
+
+	var failed []string
+	if !report.Completed {
+		failed = append(failed, "completion marker")
+	}
+	if len(report.Missing) > 0 || len(report.WithoutData) > 0 {
+		failed = append(failed, "tables")
+	}
+	if len(failed) > 0 {
+		return &dberrors.ErrVerificationFailed{Checks: failed}
+	}
+	return nil
+}
+
+// compareWithDatabase fills in the --against part of the report
+func compareWithDatabase(cmd *cobra.Command, report *verifyReport) error {
+	resolvePassword()
+	if user == "" {
+		return fmt.Errorf("database user is required for --against (use -u or --user)")
+	}
+	if dbName == "" {
+		return fmt.Errorf("the dump names no database; use -d to give the one to compare with")
+	}
+
+	matcher, err := buildDataMatcher()
+	if err != nil {
+		return err
+	}
+
+	conn := &database.Connection{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		Database: dbName,
+	}
+	if err := applyAWSIAMAuth(cmd, conn); err != nil {
+		return err
+	}
+	db, err := conn.ConnectContext(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			diag.Warnf("failed to close database connection: %v", err)
+		}
+	}()
+	inspector, err := newInspector(cmd.Context(), db)
+	if err != nil {
+		return err
+	}
+	tablesInfo, err := inspector.GetAllTablesInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get table information: %w", err)
+	}
+
+	// The sidecar, when there is one, records what the dump left out on purpose
+	intended := make(map[string]metadata.Table)
+	meta, err := metadata.LoadForDump(report.File)
+	if err != nil {
+		diag.Warnf("ignoring the sidecar: %v", err)
+	} else if meta != nil {
+		for _, t := range meta.Tables {
+			intended[t.Name] = t
+		}
+	}
+
+	report.Database = dbName
+	compareTables(report, tablesInfo, intended, matcher)
+	return nil
+}
+
+// compareTables sorts the database's tables into those missing from the
+// dump, those without the data they have in the database, and those whose
+// data the sidecar or the exclusion rules say was left out on purpose
+func compareTables(report *verifyReport, tablesInfo []database.TableInfo, intended map[string]metadata.Table, matcher *patterns.Matcher) {
+	inDump := make(map[string]dumpfile.TableContents, len(report.Tables))
+	for _, t := range report.Tables {
+		inDump[t.Name] = t
+	}
+	for _, info := range tablesInfo {
+		sidecar, recorded := intended[info.Name]
+		dumped, ok := inDump[info.Name]
+		switch {
+		case recorded && sidecar.Skipped:
+			report.DataExcluded = append(report.DataExcluded, info.Name)
+		case !ok || !dumped.Structure && !dumped.View:
+			report.Missing = append(report.Missing, info.Name)
+		case dumped.View || dumped.HasData():
+		case recorded && !sidecar.DataIncluded, matcher.Matches(info.Name):
+			report.DataExcluded = append(report.DataExcluded, info.Name)
+		case info.RowCount > 0:
+			report.WithoutData = append(report.WithoutData, missingData{Table: info.Name, Rows: info.RowCount})
+		}
+	}
+}
+
+// printVerifyReport prints the verification result as text
+func printVerifyReport(contents *dumpfile.Contents, report verifyReport) error {
+	fmt.Printf("\n%s (%s)\n\n", report.File, database.FormatBytes(contents.DecompressedSize))
+
+	out := table.New(
+		table.Column{Title: "Table", MaxWidth: 60, Flex: true},
+		table.Column{Title: "Structure"},
+		table.Column{Title: "Inserts", Align: table.Right},
+		table.Column{Title: "Rows", Align: table.Right},
+	)
+	structures, withData := 0, 0
+	var inserts int64
+	for _, t := range report.Tables {
+		kind := "-"
+		switch {
+		case t.View:
+			kind = "view"
+		case t.Structure:
+			kind = "yes"
+			structures++
+		}
+		count, rows := "-", "-"
+		if t.HasData() {
+			count, rows = fmt.Sprintf("%d", t.Inserts), fmt.Sprintf("%d", t.Rows)
+			withData++
+			inserts += t.Inserts
+		}
+		out.Row(t.Name, kind, count, rows)
+	}
+	if len(report.Tables) > 0 {
+		if err := out.Render(os.Stdout, table.Text); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%d tables with structure, %d with data (%d INSERT statements)\n\n", structures, withData, inserts)
+
+	if report.Completed {
+		ui.PrintSuccess("Completion marker found")
+	} else {
+		ui.PrintFailure("No \"Dump completed on\" marker at the end: the dump is probably truncated")
+	}
+	if report.Database == "" {
+		return nil
+	}
+
+	if len(report.Missing) == 0 {
+		ui.PrintSuccess(fmt.Sprintf("Every table of %s is in the dump", report.Database))
+	} else {
+		ui.PrintFailure(fmt.Sprintf("%d table(s) of %s missing from the dump: %s", len(report.Missing), report.Database, strings.Join(report.Missing, ", ")))
+	}
+	for _, t := range report.WithoutData {
+		ui.PrintFailure(fmt.Sprintf("%s has about %d rows in the database but no data in the dump", t.Table, t.Rows))
+	}
+	if len(report.DataExcluded) > 0 {
+		ui.PrintInfo(fmt.Sprintf("%d table(s) intentionally data-excluded: %s", len(report.DataExcluded), strings.Join(report.DataExcluded, ", ")))
+	}
+	return nil
+}
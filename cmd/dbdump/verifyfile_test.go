@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/patterns"
+)
+
+func TestCompareTables(t *testing.T) {
+	dumped := []dumpfile.TableContents{
+		{Name: "users", Structure: true, Inserts: 2, Rows: 40},
+		{Name: "orders", Structure: true},
+		{Name: "sessions", Structure: true},
+		{Name: "cache", Structure: true},
+		{Name: "audit", Structure: true},
+		{Name: "user_totals", View: true},
+		{Name: "empty", Structure: true},
+	}
+	tablesInfo := []database.TableInfo{
+		{Name: "users", RowCount: 40},
+		{Name: "orders", RowCount: 1200},   // data missing from the dump
+		{Name: "sessions", RowCount: 9000}, // excluded by the rules
+		{Name: "cache", RowCount: 50},      // excluded according to the sidecar
+		{Name: "audit", RowCount: 700},     // included in the sidecar but empty in the dump
+		{Name: "user_totals"},
+		{Name: "empty"},
+		{Name: "invoices", RowCount: 10}, // created after the dump
+		{Name: "hits", RowCount: 5},      // skipped according to the sidecar
+	}
+	matcher := patterns.NewMatcher(config.ExcludeConfig{Patterns: []string{"sess*"}})
+
+	tests := []struct {
+		name     string
+		intended map[string]metadata.Table
+		want     verifyReport
+	}{
+		{
+			name: "without a sidecar",
+			want: verifyReport{
+				Missing:      []string{"invoices", "hits"},
+				WithoutData:  []missingData{{Table: "orders", Rows: 1200}, {Table: "cache", Rows: 50}, {Table: "audit", Rows: 700}},
+				DataExcluded: []string{"sessions"},
+			},
+		},
+		{
+			name: "with a sidecar",
+			intended: map[string]metadata.Table{
+				"cache": {Name: "cache"},
+				"audit": {Name: "audit", DataIncluded: true},
+				"hits":  {Name: "hits", Skipped: true},
+			},
+			want: verifyReport{
+				Missing:      []string{"invoices"},
+				WithoutData:  []missingData{{Table: "orders", Rows: 1200}, {Table: "audit", Rows: 700}},
+				DataExcluded: []string{"sessions", "cache", "hits"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := verifyReport{Tables: dumped}
+			compareTables(&report, tablesInfo, tt.intended, matcher)
+			got := verifyReport{Missing: report.Missing, WithoutData: report.WithoutData, DataExcluded: report.DataExcluded}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareTables() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/redact"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+var (
+	warningsAsErrors bool
+	warningsReported bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&warningsAsErrors, "warnings-as-errors", false, "Exit with code 6 when any warning was raised")
+
+	// Warnings may quote errors that echo the DSN
+	diag.Redact = func(message string) string {
+		return redact.String(message, password)
+	}
+}
+
+// reportWarnings prints the consolidated list of warnings, at most once per run
+func reportWarnings() {
+	if warningsReported {
+		return
+	}
+	warningsReported = true
+	ui.PrintWarningSummary(diag.Warnings())
+}
+
+// finishWarnings lists the warnings of a successful run if the command hasn't
+// already, and turns them into an error under --warnings-as-errors
+func finishWarnings() error {
+	reportWarnings()
+	if count := diag.Count(); warningsAsErrors && count > 0 {
+		return &dberrors.ErrWarnings{Count: count}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package awsauth
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	dbconfig "github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// CABundleURL is where AWS publishes the CA bundle for all RDS regions
+const CABundleURL = "https://truststore.pki.rds.amazonaws.com/global/global-bundle.pem"
+
+// caBundleMaxAge is how long a downloaded bundle is used before refreshing it
+const caBundleMaxAge = 30 * 24 * time.Hour
+
+// CABundlePath returns the path of the cached RDS CA bundle, downloading it
+// first if it is missing or stale. A stale bundle is still used when the
+// download fails.
+func CABundlePath(ctx context.Context) (string, error) {
+	configDir, err := dbconfig.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(configDir, "rds-global-bundle.pem")
+
+	info, statErr := os.Stat(path)
+	if statErr == nil && time.Since(info.ModTime()) < caBundleMaxAge {
+		return path, nil
+	}
+
+	if err := downloadCABundle(ctx, path); err != nil {
+		if statErr == nil {
+			diag.Warnf("failed to refresh the RDS CA bundle, using the cached copy: %v", err)
+			return path, nil
+		}
+		return "", fmt.Errorf("failed to download the RDS CA bundle from %s (download it yourself and pass --aws-ca-bundle): %w", CABundleURL, err)
+	}
+	return path, nil
+}
+
+// downloadCABundle fetches the bundle and stores it at path if it contains certificates
+func downloadCABundle(ctx context.Context, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, CABundleURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	if err := checkBundle(data); err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(path, data, 0644)
+}
+
+// checkBundle verifies that data holds at least one PEM certificate
+func checkBundle(data []byte) error {
+	found := 0
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("invalid certificate in CA bundle: %w", err)
+		}
+		found++
+	}
+	if found == 0 {
+		return fmt.Errorf("no certificates in CA bundle")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Package bench defines the dump strategies compared by `dbdump bench` and
+// ranks their measurements.
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Strategy is a named preset of dump options
+type Strategy struct {
+	Name        string
+	Description string
+
+	// MySQLDumpArgs are passed to mysqldump in addition to dbdump's own
+	MySQLDumpArgs []string
+}
+
+// builtins are the strategies available without configuration
+var builtins = []Strategy{
+	{Name: "default", Description: "dbdump's standard mysqldump options"},
+	{Name: "compress", Description: "compressed client/server protocol (helps over slow networks)", MySQLDumpArgs: []string{"--compress"}},
+	{Name: "small-buffer", Description: "16K network buffer (smaller INSERT statements)", MySQLDumpArgs: []string{"--net-buffer-length=16K"}},
+	{Name: "row-inserts", Description: "one INSERT per row (--skip-extended-insert)", MySQLDumpArgs: []string{"--skip-extended-insert"}},
+}
+
+// Builtins returns the built-in strategies
+func Builtins() []Strategy {
+	return append([]Strategy(nil), builtins...)
+}
+
+// Resolve looks up strategies by name; custom strategies take precedence
+// over built-in ones with the same name
+func Resolve(names []string, custom []Strategy) ([]Strategy, error) {
+	available := make(map[string]Strategy)
+	var known []string
+	for _, strategy := range append(Builtins(), custom...) {
+		if _, ok := available[strategy.Name]; !ok {
+			known = append(known, strategy.Name)
+		}
+		available[strategy.Name] = strategy
+	}
+
+	var resolved []Strategy
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		strategy, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown strategy %q (available: %s)", name, strings.Join(known, ", "))
+		}
+		resolved = append(resolved, strategy)
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("no strategies given")
+	}
+	return resolved, nil
+}
+
+// Result is the measurement of one strategy
+type Result struct {
+	Strategy string
+	Wall     time.Duration
+	CPU      time.Duration // dbdump and mysqldump together, 0 if unknown
+	Size     int64
+
+	// Error is set when the strategy failed; TimedOut when --bench-timeout
+	// ran out before or while it ran
+	Error    string
+	TimedOut bool
+}
+
+// OK reports whether the strategy completed
+func (r Result) OK() bool {
+	return r.Error == "" && !r.TimedOut
+}
+
+// Recommend picks the fastest completed strategy and explains the choice
+// relative to the first one (the baseline). It returns "" when no strategy
+// completed.
+func Recommend(results []Result) (string, string) {
+	var completed []Result
+	for _, result := range results {
+		if result.OK() {
+			completed = append(completed, result)
+		}
+	}
+	if len(completed) == 0 {
+		return "", ""
+	}
+
+	fastest := append([]Result(nil), completed...)
+	sort.SliceStable(fastest, func(i, j int) bool { return fastest[i].Wall < fastest[j].Wall })
+	best := fastest[0]
+
+	baseline := results[0]
+	if !baseline.OK() || baseline.Strategy == best.Strategy {
+		if len(completed) == 1 {
+			return best.Strategy, "the only strategy that completed"
+		}
+		return best.Strategy, "the fastest"
+	}
+
+	saved := 100 * float64(baseline.Wall-best.Wall) / float64(baseline.Wall)
+	reason := fmt.Sprintf("%.0f%% faster than %s", saved, baseline.Strategy)
+	if saved < 5 {
+		// Within measurement noise, stay with the baseline
+		return baseline.Strategy, fmt.Sprintf("%s is only %.0f%% faster, within noise", best.Strategy, saved)
+	}
+	if baseline.Size > 0 && best.Size > baseline.Size {
+		reason += fmt.Sprintf(", output %.0f%% larger", 100*float64(best.Size-baseline.Size)/float64(baseline.Size))
+	}
+	return best.Strategy, reason
+}
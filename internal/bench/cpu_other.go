@@ -0,0 +1,10 @@
+//go:build !unix
+
+package bench
+
+import "time"
+
+// CPUTime is not available on this platform
+func CPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,99 @@
+// Package budget schedules the data of a dump under a time limit
+// (--time-budget): tables are dumped one at a time, the most important
+// first, and no table is started that is expected to run past the limit
+package budget
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// AssumedRate is the throughput, in bytes per second, expected of the
+// first table, before any table has been timed
+const AssumedRate = 16 << 20
+
+// Order returns the tables of estimates in the order to dump them: those in
+// first in that order, then the others smallest first
+func Order(estimates map[string]int64, first []string) []string {
+	order := make([]string, 0, len(estimates))
+	for _, table := range first {
+		if _, ok := estimates[table]; ok && !slices.Contains(order, table) {
+			order = append(order, table)
+		}
+	}
+	var rest []string
+	for table := range estimates {
+		if !slices.Contains(order, table) {
+			rest = append(rest, table)
+		}
+	}
+	slices.SortFunc(rest, func(a, b string) int {
+		return cmp.Or(cmp.Compare(estimates[a], estimates[b]), cmp.Compare(a, b))
+	})
+	return append(order, rest...)
+}
+
+// Scheduler decides whether each table fits in what is left of the budget,
+// estimating its duration from its size and the throughput of the tables
+// dumped so far. Once a table doesn't fit, no other table is started. It is
+// not safe for concurrent use.
+type Scheduler struct {
+	budget  time.Duration
+	started time.Time
+	now     func() time.Time
+
+	bytes   int64
+	spent   time.Duration
+	stopped bool
+}
+
+// NewScheduler creates a scheduler for a dump started at started that must
+// not start tables it expects to finish after budget has passed
+func NewScheduler(budget time.Duration, started time.Time) *Scheduler {
+	return &Scheduler{budget: budget, started: started, now: time.Now}
+}
+
+// WithClock replaces the clock the scheduler reads the elapsed time from
+func (s *Scheduler) WithClock(now func() time.Time) *Scheduler {
+	s.now = now
+	return s
+}
+
+// Estimate returns how long a table of size bytes is expected to take
+func (s *Scheduler) Estimate(size int64) time.Duration {
+	rate := float64(AssumedRate)
+	if s.bytes > 0 && s.spent > 0 {
+		rate = float64(s.bytes) / s.spent.Seconds()
+	}
+	return time.Duration(float64(size) / rate * float64(time.Second))
+}
+
+// Remaining returns the time left in the budget
+func (s *Scheduler) Remaining() time.Duration {
+	return s.budget - s.now().Sub(s.started)
+}
+
+// Start reports whether a table of size bytes may be started: it must be
+// expected to finish within the budget, and no table before it may have
+// been refused
+func (s *Scheduler) Start(size int64) bool {
+	if s.stopped {
+		return false
+	}
+	if s.Estimate(size) > s.Remaining() {
+		s.stopped = true
+	}
+	return !s.stopped
+}
+
+// Finish records a table dumped in full, for the estimates of the next ones
+func (s *Scheduler) Finish(size int64, took time.Duration) {
+	s.bytes += size
+	s.spent += took
+}
+
+// Stopped reports whether a table was refused
+func (s *Scheduler) Stopped() bool {
+	return s.stopped
+}
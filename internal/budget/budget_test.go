@@ -0,0 +1,197 @@
+package budget
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const mib = 1 << 20
+
+func TestOrder(t *testing.T) {
+	estimates := map[string]int64{
+		"users":    40 * mib,
+		"orders":   900 * mib,
+		"settings": 1024,
+		"logs":     5 * mib,
+		"tags":     5 * mib,
+		"empty":    0,
+	}
+
+	tests := []struct {
+		name  string
+		first []string
+		want  []string
+	}{
+		{name: "smallest first", want: []string{"empty", "settings", "logs", "tags", "users", "orders"}},
+		{name: "priority first", first: []string{"orders", "users"}, want: []string{"orders", "users", "empty", "settings", "logs", "tags"}},
+		{name: "priority order kept", first: []string{"users", "orders"}, want: []string{"users", "orders", "empty", "settings", "logs", "tags"}},
+		{name: "repeated priority", first: []string{"tags", "orders", "tags"}, want: []string{"tags", "orders", "empty", "settings", "logs", "users"}},
+		{name: "unknown priority", first: []string{"missing", "logs"}, want: []string{"logs", "empty", "settings", "tags", "users", "orders"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Order(estimates, tt.first); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Order() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := Order(nil, []string{"users"}); len(got) != 0 {
+		t.Errorf("Order of no tables = %v", got)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	s := NewScheduler(time.Minute, time.Now())
+	if got := s.Estimate(AssumedRate); got != time.Second {
+		t.Errorf("first estimate = %v, want 1s at the assumed rate", got)
+	}
+	// A table dumped in no measurable time leaves the assumed rate
+	s.Finish(mib, 0)
+	if got := s.Estimate(AssumedRate); got != time.Second {
+		t.Errorf("estimate after an untimed table = %v, want 1s", got)
+	}
+	s.Finish(31*mib, time.Second)
+	if got := s.Estimate(16 * mib); got != 500*time.Millisecond {
+		t.Errorf("estimate at 32MiB/s = %v, want 500ms", got)
+	}
+}
+
+// run schedules tables (in order, with their sizes) under budget on a fake
+// clock, each table taking its size at rate bytes per second, and returns
+// the tables dumped and those left out
+func run(t *testing.T, tables []string, sizes map[string]int64, budget time.Duration, late time.Duration, rate float64) (dumped, omitted []string, elapsed time.Duration) {
+	t.Helper()
+	started := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := started.Add(late)
+	s := NewScheduler(budget, started).WithClock(func() time.Time { return now })
+	for _, table := range tables {
+		if !s.Start(sizes[table]) {
+			omitted = append(omitted, table)
+			continue
+		}
+		// The table runs to the end, however long it takes
+		took := time.Duration(float64(sizes[table]) / rate * float64(time.Second))
+		now = now.Add(took)
+		s.Finish(sizes[table], took)
+		dumped = append(dumped, table)
+	}
+	if len(omitted) > 0 != s.Stopped() {
+		t.Errorf("Stopped() = %v with %d tables left out", s.Stopped(), len(omitted))
+	}
+	return dumped, omitted, now.Sub(started)
+}
+
+func TestScheduler(t *testing.T) {
+	sizes := map[string]int64{
+		"a":    64 * mib,
+		"b":    64 * mib,
+		"c":    64 * mib,
+		"tiny": 1024,
+		"huge": 2048 * mib,
+	}
+
+	tests := []struct {
+		name        string
+		tables      []string
+		budget      time.Duration
+		late        time.Duration // time spent before the data, e.g. on the schema
+		rate        float64
+		wantDumped  []string
+		wantOmitted []string
+		wantElapsed time.Duration
+	}{
+		{
+			name:        "all fit",
+			tables:      []string{"tiny", "a", "b"},
+			budget:      time.Minute,
+			rate:        AssumedRate,
+			wantDumped:  []string{"tiny", "a", "b"},
+			wantElapsed: 8*time.Second + time.Second/16384,
+		},
+		{
+			// 4s each: after a and b 2s are left, too little for c
+			name:        "stops at the budget",
+			tables:      []string{"a", "b", "c"},
+			budget:      10 * time.Second,
+			rate:        AssumedRate,
+			wantDumped:  []string{"a", "b"},
+			wantOmitted: []string{"c"},
+			wantElapsed: 8 * time.Second,
+		},
+		{
+			// tiny would fit in the 2s left, but is after a refused table
+			name:        "nothing after a refused table",
+			tables:      []string{"a", "b", "c", "tiny"},
+			budget:      10 * time.Second,
+			rate:        AssumedRate,
+			wantDumped:  []string{"a", "b"},
+			wantOmitted: []string{"c", "tiny"},
+			wantElapsed: 8 * time.Second,
+		},
+		{
+			// a is expected to take 4s at the assumed rate but takes 16s:
+			// it finishes past the budget, and nothing starts after it
+			name:        "in-flight table finishes",
+			tables:      []string{"a", "tiny", "b"},
+			budget:      10 * time.Second,
+			rate:        4 * mib,
+			wantDumped:  []string{"a"},
+			wantOmitted: []string{"tiny", "b"},
+			wantElapsed: 16 * time.Second,
+		},
+		{
+			// At the assumed rate huge takes 128s; measured on a, 32s
+			name:        "estimates follow the measured rate",
+			tables:      []string{"a", "huge"},
+			budget:      40 * time.Second,
+			rate:        64 * mib,
+			wantDumped:  []string{"a", "huge"},
+			wantElapsed: 33 * time.Second,
+		},
+		{
+			name:        "budget spent on the schema",
+			tables:      []string{"tiny", "a"},
+			budget:      10 * time.Second,
+			late:        10 * time.Second,
+			rate:        AssumedRate,
+			wantOmitted: []string{"tiny", "a"},
+			wantElapsed: 10 * time.Second,
+		},
+		{
+			name:        "priority table takes the budget",
+			tables:      Order(sizes, []string{"huge"}),
+			budget:      2 * time.Minute,
+			rate:        AssumedRate,
+			wantDumped:  nil,
+			wantOmitted: []string{"huge", "tiny", "a", "b", "c"},
+		},
+		{
+			name:        "priority table first",
+			tables:      Order(sizes, []string{"c"}),
+			budget:      10 * time.Second,
+			rate:        AssumedRate,
+			wantDumped:  []string{"c", "tiny", "a"},
+			wantOmitted: []string{"b", "huge"},
+			wantElapsed: 8*time.Second + time.Second/16384,
+		},
+		{name: "no tables", budget: time.Second, rate: AssumedRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dumped, omitted, elapsed := run(t, tt.tables, sizes, tt.budget, tt.late, tt.rate)
+			if !reflect.DeepEqual(dumped, tt.wantDumped) {
+				t.Errorf("dumped %v, want %v", dumped, tt.wantDumped)
+			}
+			if !reflect.DeepEqual(omitted, tt.wantOmitted) {
+				t.Errorf("left out %v, want %v", omitted, tt.wantOmitted)
+			}
+			if elapsed != tt.wantElapsed {
+				t.Errorf("took %v, want %v", elapsed, tt.wantElapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package charset
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+// Byte limits enforced by MySQL/InnoDB
+const (
+	maxRowBytes          = 65535
+	maxIndexBytes        = 3072
+	maxCompactIndexBytes = 767 // per column for COMPACT and REDUNDANT row formats
+)
+
+// CheckOverflow reports columns and indexes that would exceed MySQL's byte
+// limits once converted to a character set with targetMaxLen bytes per character
+// Only tables in the tables set are checked
+func CheckOverflow(target string, targetMaxLen int64, columns []database.ColumnCharset, indexes []database.IndexColumn, rowFormats map[string]string, tables map[string]bool) []string {
+	var problems []string
+
+	type key struct{ table, column string }
+	byName := make(map[key]database.ColumnCharset, len(columns))
+	rowBytes := make(map[string]int64)
+	var rowTables []string
+
+	for _, col := range columns {
+		if !tables[col.Table] {
+			continue
+		}
+		byName[key{col.Table, col.Column}] = col
+
+		maxLen := convertedMaxLen(col, target, targetMaxLen)
+		if col.DataType != "varchar" {
+			continue
+		}
+
+		bytes := col.MaxChars * maxLen
+		if bytes > maxRowBytes {
+			problems = append(problems, fmt.Sprintf("%s.%s: VARCHAR(%d) would need %d bytes in %s, over the %d-byte limit (use TEXT or a shorter length)",
+				col.Table, col.Column, col.MaxChars, bytes, target, maxRowBytes))
+		}
+		if _, ok := rowBytes[col.Table]; !ok {
+			rowTables = append(rowTables, col.Table)
+		}
+		rowBytes[col.Table] += bytes + 2
+	}
+
+	for _, table := range rowTables {
+		if rowBytes[table] > maxRowBytes {
+			problems = append(problems, fmt.Sprintf("%s: VARCHAR columns would total %d bytes in %s, over the %d-byte row size limit",
+				table, rowBytes[table], target, maxRowBytes))
+		}
+	}
+
+	type indexKey struct{ table, index string }
+	indexBytes := make(map[indexKey]int64)
+	var indexOrder []indexKey
+
+	for _, part := range indexes {
+		if !tables[part.Table] {
+			continue
+		}
+		col, ok := byName[key{part.Table, part.Column}]
+		if !ok {
+			continue // not a character column, or a functional key part
+		}
+
+		chars := col.MaxChars
+		if part.SubPart > 0 {
+			chars = part.SubPart
+		}
+		bytes := chars * convertedMaxLen(col, target, targetMaxLen)
+
+		limit := int64(maxIndexBytes)
+		if format := strings.ToLower(rowFormats[part.Table]); format == "compact" || format == "redundant" {
+			limit = maxCompactIndexBytes
+		}
+		if bytes > limit {
+			problems = append(problems, fmt.Sprintf("%s: index %s on %s would need %d bytes in %s, over the %d-byte key part limit (use a prefix index or shorter column)",
+				part.Table, part.Index, part.Column, bytes, target, limit))
+		}
+
+		k := indexKey{part.Table, part.Index}
+		if _, ok := indexBytes[k]; !ok {
+			indexOrder = append(indexOrder, k)
+		}
+		indexBytes[k] += bytes
+	}
+
+	for _, k := range indexOrder {
+		if indexBytes[k] > maxIndexBytes {
+			problems = append(problems, fmt.Sprintf("%s: index %s would need %d bytes in %s, over the %d-byte index limit",
+				k.table, k.index, indexBytes[k], target, maxIndexBytes))
+		}
+	}
+
+	return problems
+}
+
+// convertedMaxLen returns the bytes per character of a column after conversion
+func convertedMaxLen(col database.ColumnCharset, target string, targetMaxLen int64) int64 {
+	if strings.EqualFold(col.CharSet, "binary") || strings.EqualFold(col.CharSet, target) {
+		return col.MaxLen
+	}
+	return targetMaxLen
+}
@@ -0,0 +1,121 @@
+package charset
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+func TestCheckOverflow(t *testing.T) {
+	utf8 := func(table, column string, chars int64) database.ColumnCharset {
+		return database.ColumnCharset{Table: table, Column: column, DataType: "varchar", CharSet: "utf8", MaxChars: chars, MaxLen: 3}
+	}
+	index := func(table, name string, columns ...string) []database.IndexColumn {
+		var parts []database.IndexColumn
+		for _, column := range columns {
+			parts = append(parts, database.IndexColumn{Table: table, Index: name, Column: column})
+		}
+		return parts
+	}
+
+	tests := []struct {
+		name       string
+		columns    []database.ColumnCharset
+		indexes    []database.IndexColumn
+		rowFormats map[string]string
+		want       []string
+	}{
+		{
+			name:    "indexed varchar(191) fits",
+			columns: []database.ColumnCharset{utf8("users", "email", 191)},
+			indexes: index("users", "users_email_unique", "email"),
+		},
+		{
+			name:    "indexed varchar(255) fits a dynamic row",
+			columns: []database.ColumnCharset{utf8("users", "email", 255)},
+			indexes: index("users", "users_email_unique", "email"),
+		},
+		{
+			name:       "indexed varchar(255) in a compact row",
+			columns:    []database.ColumnCharset{utf8("users", "email", 255)},
+			indexes:    index("users", "users_email_unique", "email"),
+			rowFormats: map[string]string{"users": "Compact"},
+			want:       []string{"users: index users_email_unique on email would need 1020 bytes in utf8mb4, over the 767-byte key part limit (use a prefix index or shorter column)"},
+		},
+		{
+			name:       "prefix index in a compact row",
+			columns:    []database.ColumnCharset{utf8("users", "email", 255)},
+			indexes:    []database.IndexColumn{{Table: "users", Index: "email", Column: "email", SubPart: 191}},
+			rowFormats: map[string]string{"users": "REDUNDANT"},
+		},
+		{
+			name:    "index over the limit in total",
+			columns: []database.ColumnCharset{utf8("posts", "slug", 500), utf8("posts", "locale", 300)},
+			indexes: index("posts", "posts_slug_locale", "slug", "locale"),
+			want:    []string{"posts: index posts_slug_locale would need 3200 bytes in utf8mb4, over the 3072-byte index limit"},
+		},
+		{
+			name:    "key part over the limit",
+			columns: []database.ColumnCharset{utf8("posts", "url", 1000)},
+			indexes: index("posts", "posts_url", "url"),
+			want: []string{
+				"posts: index posts_url on url would need 4000 bytes in utf8mb4, over the 3072-byte key part limit (use a prefix index or shorter column)",
+				"posts: index posts_url would need 4000 bytes in utf8mb4, over the 3072-byte index limit",
+			},
+		},
+		{
+			name:    "column over the row limit",
+			columns: []database.ColumnCharset{utf8("pages", "body", 20000)},
+			want: []string{
+				"pages.body: VARCHAR(20000) would need 80000 bytes in utf8mb4, over the 65535-byte limit (use TEXT or a shorter length)",
+				"pages: VARCHAR columns would total 80002 bytes in utf8mb4, over the 65535-byte row size limit",
+			},
+		},
+		{
+			name:    "columns over the row limit together",
+			columns: []database.ColumnCharset{utf8("pages", "a", 8000), utf8("pages", "b", 8000), utf8("pages", "c", 8000)},
+			want:    []string{"pages: VARCHAR columns would total 96006 bytes in utf8mb4, over the 65535-byte row size limit"},
+		},
+		{
+			name:    "two columns still fit",
+			columns: []database.ColumnCharset{utf8("pages", "a", 8000), utf8("pages", "b", 8000)},
+		},
+		{
+			name: "binary and target columns keep their width",
+			columns: []database.ColumnCharset{
+				{Table: "files", Column: "hash", DataType: "varchar", CharSet: "binary", MaxChars: 3000, MaxLen: 1},
+				{Table: "files", Column: "name", DataType: "varchar", CharSet: "utf8mb4", MaxChars: 768, MaxLen: 4},
+			},
+			indexes: index("files", "files_hash", "hash"),
+		},
+		{
+			name:    "text columns don't count towards the row",
+			columns: []database.ColumnCharset{{Table: "pages", Column: "body", DataType: "text", CharSet: "latin1", MaxLen: 1}, utf8("pages", "title", 255)},
+			indexes: []database.IndexColumn{{Table: "pages", Index: "body", Column: "body", SubPart: 768}},
+		},
+		{
+			name:    "functional and numeric key parts",
+			columns: []database.ColumnCharset{utf8("users", "email", 255)},
+			indexes: []database.IndexColumn{{Table: "users", Index: "f", Expression: "lower(`email`)"}, {Table: "users", Index: "id", Column: "id"}},
+		},
+		{
+			name:    "tables not dumped",
+			columns: []database.ColumnCharset{utf8("archive", "body", 30000)},
+			indexes: index("archive", "body", "body"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables := map[string]bool{"users": true, "posts": true, "pages": true, "files": true}
+			got := CheckOverflow("utf8mb4", 4, tt.columns, tt.indexes, tt.rowFormats, tables)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckOverflow() = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
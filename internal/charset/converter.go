@@ -0,0 +1,97 @@
+package charset
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	createTableLine = regexp.MustCompile("^CREATE TABLE `")
+	charsetClause   = regexp.MustCompile(`(?i)\b(CHARSET=|CHARACTER SET )(\w+)`)
+	collateClause   = regexp.MustCompile(`(?i)\b(COLLATE=|COLLATE )(\w+)`)
+)
+
+// Converter rewrites the character set and collation clauses of CREATE TABLE
+// statements in a mysqldump structure stream to a target character set
+type Converter struct {
+	target  string
+	mapping *Mapping
+}
+
+// NewConverter creates a Converter
+func NewConverter(target string, mapping *Mapping) *Converter {
+	return &Converter{target: target, mapping: mapping}
+}
+
+// Transform converts a CREATE TABLE statement and returns any other
+// statement unchanged; it is a transform.Func
+func (c *Converter) Transform(statement []byte) []byte {
+	if !createTableLine.Match(statement) {
+		return statement
+	}
+	return []byte(c.Rewrite(string(statement)))
+}
+
+// Rewrite converts the character set and collation clauses in DDL, leaving
+// quoted identifiers and strings untouched
+func (c *Converter) Rewrite(line string) string {
+	var sb strings.Builder
+	for _, seg := range splitQuoted(line) {
+		if seg.quoted {
+			sb.WriteString(seg.text)
+			continue
+		}
+		text := charsetClause.ReplaceAllStringFunc(seg.text, func(m string) string {
+			parts := charsetClause.FindStringSubmatch(m)
+			if strings.EqualFold(parts[2], "binary") {
+				return m
+			}
+			return parts[1] + c.target
+		})
+		text = collateClause.ReplaceAllStringFunc(text, func(m string) string {
+			parts := collateClause.FindStringSubmatch(m)
+			return parts[1] + c.mapping.Collation(parts[2])
+		})
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+// segment is a part of a line, either inside or outside quotes
+type segment struct {
+	text   string
+	quoted bool
+}
+
+// splitQuoted splits text into quoted ('…', "…", `…`) and unquoted segments
+func splitQuoted(line string) []segment {
+	var segments []segment
+	start := 0
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quote == 0 && (ch == '\'' || ch == '"' || ch == '`'):
+			if i > start {
+				segments = append(segments, segment{text: line[start:i]})
+			}
+			quote = ch
+			start = i
+		case quote != 0 && ch == '\\' && quote != '`':
+			i++
+		case quote != 0 && ch == quote:
+			// A doubled quote is an escaped quote inside the string
+			if i+1 < len(line) && line[i+1] == quote {
+				i++
+				continue
+			}
+			segments = append(segments, segment{text: line[start : i+1], quoted: true})
+			quote = 0
+			start = i + 1
+		}
+	}
+	if start < len(line) {
+		segments = append(segments, segment{text: line[start:], quoted: quote != 0})
+	}
+	return segments
+}
@@ -0,0 +1,107 @@
+package charset
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/transform"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files of testdata")
+
+// utf8mb4 converts to utf8mb4 with a per-collation override, as the
+// collation mapping of a project config sets it
+func utf8mb4() *Converter {
+	known := map[string]bool{"utf8mb4_bin": true, "utf8mb4_0900_ai_ci": true, "utf8mb4_swedish_ci": true, "utf8mb4_general_ci": true}
+	explicit := map[string]string{"utf8_unicode_ci": "utf8mb4_0900_ai_ci"}
+	return NewConverter("utf8mb4", NewMapping("utf8mb4", "utf8mb4_0900_ai_ci", explicit, known))
+}
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{name: "table options", line: ") ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_general_ci;", want: ") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;"},
+		{name: "column", line: "  `a` varchar(10) CHARACTER SET latin1 COLLATE latin1_swedish_ci,", want: "  `a` varchar(10) CHARACTER SET utf8mb4 COLLATE utf8mb4_swedish_ci,"},
+		{name: "column override", line: "  `a` text COLLATE utf8_unicode_ci,", want: "  `a` text COLLATE utf8mb4_0900_ai_ci,"},
+		{name: "unknown collation", line: "  `a` text COLLATE utf8_spanish2_ci,", want: "  `a` text COLLATE utf8mb4_0900_ai_ci,"},
+		{name: "lower case", line: ") default charset=utf8 collate=utf8_bin;", want: ") default charset=utf8mb4 collate=utf8mb4_bin;"},
+		{name: "binary", line: "  `h` char(40) CHARACTER SET binary COLLATE binary,", want: "  `h` char(40) CHARACTER SET binary COLLATE binary,"},
+		{name: "quoted", line: "  `CHARSET=x` int COMMENT 'COLLATE latin1_bin, it''s \\' CHARSET=latin1',", want: "  `CHARSET=x` int COMMENT 'COLLATE latin1_bin, it''s \\' CHARSET=latin1',"},
+		{name: "after a string", line: "  `s` enum('a\"b') CHARACTER SET utf8,", want: "  `s` enum('a\"b') CHARACTER SET utf8mb4,"},
+		{name: "unterminated string", line: "  `s` int COMMENT 'CHARSET=latin1", want: "  `s` int COMMENT 'CHARSET=latin1"},
+		{name: "nothing to convert", line: "  `id` int NOT NULL,", want: "  `id` int NOT NULL,"},
+	}
+
+	c := utf8mb4()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Rewrite(tt.line); got != tt.want {
+				t.Errorf("Rewrite() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformOtherStatements(t *testing.T) {
+	c := utf8mb4()
+	for _, statement := range []string{
+		"/*!40101 SET NAMES utf8 */;",
+		"INSERT INTO `t` VALUES ('CREATE TABLE `x` (a text) DEFAULT CHARSET=utf8');",
+		"ALTER TABLE `t` CONVERT TO CHARACTER SET latin1;",
+	} {
+		if got := string(c.Transform([]byte(statement))); got != statement {
+			t.Errorf("Transform(%q) = %q, want it unchanged", statement, got)
+		}
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+	got := splitQuoted("a 'b''c' `d``e` \"f\\\"g\" h")
+	want := []segment{
+		{text: "a "}, {text: "'b''c'", quoted: true}, {text: " "}, {text: "`d``e`", quoted: true},
+		{text: " "}, {text: "\"f\\\"g\"", quoted: true}, {text: " h"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitQuoted() = %+v, want %+v", got, want)
+	}
+}
+
+// TestConvertGolden converts a captured utf8 (utf8mb3) structure dump and
+// compares it with the .golden file; run with -update to rewrite it after
+// checking the diff
+func TestConvertGolden(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("testdata", "legacy.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	w := transform.NewWriter(&out, utf8mb4().Transform)
+	if _, err := w.Write(input); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "legacy.golden")
+	if *update {
+		if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("output differs from %s:\n%s", golden, out.Bytes())
+	}
+}
@@ -0,0 +1,46 @@
+package charset
+
+import "strings"
+
+// Mapping maps source collations to collations of the target character set
+type Mapping struct {
+	target           string
+	defaultCollation string
+	explicit         map[string]string
+	known            map[string]bool
+}
+
+// NewMapping creates a collation mapping for a target character set
+// explicit overrides the derived mapping (e.g. utf8_unicode_ci → utf8mb4_0900_ai_ci);
+// known lists the target's collations, and unknown results fall back to
+// defaultCollation. known may be nil to skip that check.
+func NewMapping(target, defaultCollation string, explicit map[string]string, known map[string]bool) *Mapping {
+	return &Mapping{
+		target:           target,
+		defaultCollation: defaultCollation,
+		explicit:         explicit,
+		known:            known,
+	}
+}
+
+// Collation returns the target collation for a source collation
+// The charset prefix is swapped for the target's, so utf8mb3_unicode_ci
+// becomes utf8mb4_unicode_ci when converting to utf8mb4
+func (m *Mapping) Collation(source string) string {
+	if mapped, ok := m.explicit[source]; ok {
+		return mapped
+	}
+	if strings.EqualFold(source, "binary") {
+		return source
+	}
+
+	mapped := source
+	if i := strings.IndexByte(source, '_'); i > 0 {
+		mapped = m.target + source[i:]
+	}
+
+	if m.known != nil && !m.known[mapped] && m.defaultCollation != "" {
+		return m.defaultCollation
+	}
+	return mapped
+}
@@ -0,0 +1,37 @@
+package charset
+
+import "testing"
+
+func TestMappingCollation(t *testing.T) {
+	known := map[string]bool{
+		"utf8mb4_general_ci": true, "utf8mb4_unicode_ci": true, "utf8mb4_bin": true,
+		"utf8mb4_0900_ai_ci": true, "utf8mb4_swedish_ci": true,
+	}
+	explicit := map[string]string{"utf8_unicode_ci": "utf8mb4_0900_ai_ci"}
+
+	tests := []struct {
+		name    string
+		mapping *Mapping
+		source  string
+		want    string
+	}{
+		{name: "prefix swapped", mapping: NewMapping("utf8mb4", "utf8mb4_0900_ai_ci", nil, known), source: "utf8_general_ci", want: "utf8mb4_general_ci"},
+		{name: "utf8mb3 prefix", mapping: NewMapping("utf8mb4", "utf8mb4_0900_ai_ci", nil, known), source: "utf8mb3_bin", want: "utf8mb4_bin"},
+		{name: "other charset", mapping: NewMapping("utf8mb4", "utf8mb4_0900_ai_ci", nil, known), source: "latin1_swedish_ci", want: "utf8mb4_swedish_ci"},
+		{name: "explicit", mapping: NewMapping("utf8mb4", "utf8mb4_0900_ai_ci", explicit, known), source: "utf8_unicode_ci", want: "utf8mb4_0900_ai_ci"},
+		{name: "explicit wins over known", mapping: NewMapping("utf8mb4", "", map[string]string{"utf8_bin": "utf8mb4_0900_bin"}, known), source: "utf8_bin", want: "utf8mb4_0900_bin"},
+		{name: "unknown falls back", mapping: NewMapping("utf8mb4", "utf8mb4_0900_ai_ci", nil, known), source: "utf8_spanish2_ci", want: "utf8mb4_0900_ai_ci"},
+		{name: "unknown without default", mapping: NewMapping("utf8mb4", "", nil, known), source: "utf8_spanish2_ci", want: "utf8mb4_spanish2_ci"},
+		{name: "no known list", mapping: NewMapping("utf8mb4", "utf8mb4_0900_ai_ci", nil, nil), source: "utf8_spanish2_ci", want: "utf8mb4_spanish2_ci"},
+		{name: "binary", mapping: NewMapping("utf8mb4", "utf8mb4_0900_ai_ci", nil, known), source: "binary", want: "binary"},
+		{name: "no prefix", mapping: NewMapping("utf8mb4", "", nil, nil), source: "odd", want: "odd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mapping.Collation(tt.source); got != tt.want {
+				t.Errorf("Collation(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
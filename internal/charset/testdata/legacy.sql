@@ -0,0 +1,47 @@
+-- MySQL dump 10.13  Distrib 5.7.44, for Linux (x86_64)
+--
+-- Host: 127.0.0.1    Database: legacy
+-- ------------------------------------------------------
+
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40101 SET NAMES utf8 */;
+
+--
+-- Table structure for table `customers`
+--
+
+DROP TABLE IF EXISTS `customers`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `customers` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(191) COLLATE utf8_unicode_ci NOT NULL,
+  `email` varchar(191) CHARACTER SET utf8 COLLATE utf8_bin NOT NULL,
+  `legacy_code` char(8) CHARACTER SET latin1 COLLATE latin1_swedish_ci DEFAULT NULL,
+  `token` varbinary(64) DEFAULT NULL,
+  `hash` char(40) CHARACTER SET binary DEFAULT NULL,
+  `notes` text COLLATE utf8_unicode_ci COMMENT 'was CHARSET=latin1, COLLATE latin1_bin before 2019',
+  `status` enum('active','it''s CHARSET=utf8') COLLATE utf8_unicode_ci NOT NULL DEFAULT 'active',
+  `region` varchar(32) COLLATE utf8_spanish2_ci DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `customers_email_unique` (`email`),
+  KEY `customers_name_index` (`name`)
+) ENGINE=InnoDB AUTO_INCREMENT=1201 DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `CHARSET=latin1`
+--
+
+DROP TABLE IF EXISTS `CHARSET=latin1`;
+CREATE TABLE `CHARSET=latin1` (
+  `COLLATE utf8_bin` varchar(10) DEFAULT NULL
+) ENGINE=MyISAM DEFAULT CHARSET=latin1;
+
+--
+-- Dumping data for table `customers`
+--
+
+LOCK TABLES `customers` WRITE;
+INSERT INTO `customers` VALUES (1,'CREATE TABLE `x` (a text) DEFAULT CHARSET=utf8','a@example.com',NULL,NULL,NULL,NULL,'active',NULL);
+UNLOCK TABLES;
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Confidence is how sure an exclusion rule is that the data of the tables
+// it matches can be left out
+type Confidence string
+
+const (
+	// ConfidenceHigh rules exclude data in auto mode and are pre-ticked in
+	// the selector: built-in rules, rules given on the command line and
+	// config rules without a confidence
+	ConfidenceHigh Confidence = "high"
+
+	// ConfidenceMedium rules are highlighted in the selector but not
+	// ticked (the default of rules dbdump analyze proposes)
+	ConfidenceMedium Confidence = "medium"
+
+	// ConfidenceLow rules only annotate the tables they match
+	ConfidenceLow Confidence = "low"
+)
+
+// Confidences are the levels, strongest first
+var Confidences = []Confidence{ConfidenceHigh, ConfidenceMedium, ConfidenceLow}
+
+// ParseConfidence reads high, medium or low
+func ParseConfidence(text string) (Confidence, error) {
+	level := Confidence(strings.ToLower(strings.TrimSpace(text)))
+	switch level {
+	case ConfidenceHigh, ConfidenceMedium, ConfidenceLow:
+		return level, nil
+	}
+	return "", fmt.Errorf("unknown confidence %q (use high, medium or low)", text)
+}
+
+// rank orders the levels; "" ranks below low
+func (c Confidence) rank() int {
+	switch c {
+	case ConfidenceHigh:
+		return 3
+	case ConfidenceMedium:
+		return 2
+	case ConfidenceLow:
+		return 1
+	}
+	return 0
+}
+
+// AtLeast reports whether c is threshold or stronger
+func (c Confidence) AtLeast(threshold Confidence) bool {
+	return c.rank() >= threshold.rank()
+}
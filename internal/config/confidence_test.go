@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+func TestParseConfidence(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Confidence
+		wantErr bool
+	}{
+		{text: "high", want: ConfidenceHigh},
+		{text: "medium", want: ConfidenceMedium},
+		{text: "low", want: ConfidenceLow},
+		{text: " High ", want: ConfidenceHigh},
+		{text: "LOW", want: ConfidenceLow},
+		{text: "", wantErr: true},
+		{text: "certain", wantErr: true},
+		{text: "med", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseConfidence(tt.text)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseConfidence(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestConfidenceAtLeast(t *testing.T) {
+	levels := append(append([]Confidence(nil), Confidences...), "")
+	for i, level := range levels {
+		for j, threshold := range levels {
+			// Strongest first, with "" (unrated) below low
+			if got, want := level.AtLeast(threshold), i <= j; got != want {
+				t.Errorf("%q.AtLeast(%q) = %v, want %v", level, threshold, got, want)
+			}
+		}
+	}
+}
@@ -2,71 +2,205 @@ package config
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-
-	"gopkg.in/yaml.v3"
+	"sync"
 )
 
-// Default configuration as embedded constant
-const defaultConfigYAML = `default_excludes:
-  exact:
-    - audits
-    - sessions
-    - cache
-    - cache_locks
-    - failed_jobs
-    - telescope_entries
-    - telescope_entries_tags
-    - telescope_monitoring
-    - pulse_entries
-    - pulse_aggregates
-  patterns:
-    - "telescope_*"
-    - "pulse_*"
-    - "_cache"
-`
-
 // ExcludeConfig represents the exclude configuration
 type ExcludeConfig struct {
-	Exact    []string `yaml:"exact"`
-	Patterns []string `yaml:"patterns"`
+	Exact    []string `yaml:"exact" json:"exact"`
+	Patterns []string `yaml:"patterns" json:"patterns"`
 }
 
 // Config represents the full configuration
 type Config struct {
 	Name    string        `yaml:"name"`
 	Exclude ExcludeConfig `yaml:"exclude"`
+
+	// Only switches to positive selection: tables not matching are skipped entirely
+	Only ExcludeConfig `yaml:"only"`
+
+	// Include inverts the exclusion rules: tables not matching have their
+	// data excluded (exclude rules still win over include rules)
+	Include ExcludeConfig `yaml:"include"`
+
+	Charset CharsetConfig `yaml:"charset"`
+
+	// Confidence maps exclude rules (exact names or patterns, as written
+	// in exclude) to high, medium or low; rules not listed are high
+	Confidence map[string]string `yaml:"confidence"`
+
+	// GitignoreCheck can be set to false to stop warning about dumps that are
+	// not git-ignored (for people who commit dumps on purpose)
+	GitignoreCheck *bool `yaml:"gitignore_check"`
+
+	// Labels adds tags describing where a dump was taken
+	Labels LabelsConfig `yaml:"labels"`
+
+	// SizeWarningFactor is how many times larger or smaller than estimated a
+	// dump may be before a notice is printed (default 3)
+	SizeWarningFactor float64 `yaml:"size_warning_factor"`
+
+	// SelectionConflict picks which side --auto follows when the config rules
+	// and the saved table selection disagree: "config" (default) or "saved"
+	SelectionConflict string `yaml:"selection_conflict"`
+
+	// StatsExport appends an anonymized record of each dump to a shared
+	// directory; nothing is written unless Dir is set
+	StatsExport StatsExportConfig `yaml:"stats_export"`
+
+	// StructureRules set how much of the CREATE TABLE of data-excluded
+	// tables is kept; the first matching rule applies
+	StructureRules []StructureRule `yaml:"structure_rules"`
+
+	// BenchStrategies defines custom strategies for `dbdump bench`
+	BenchStrategies []BenchStrategy `yaml:"bench_strategies"`
+
+	// PreAnalyze refreshes the statistics of the largest tables before the
+	// table selector opens; off unless Top is set
+	PreAnalyze PreAnalyzeConfig `yaml:"pre_analyze"`
+
+	// Sample maps table names or patterns to a row count: the data of
+	// those tables is excluded except for their last rows
+	Sample map[string]int `yaml:"sample"`
+
+	// Mask maps table.column to a masking strategy (null, fake_email,
+	// fake_name or hash) or a literal value replacing the column's data
+	Mask map[string]string `yaml:"mask"`
+
+	// NullColumns maps tables to columns dumped as NULL, their default or
+	// a literal placeholder, keeping the INSERTs' shape
+	NullColumns map[string]NullColumns `yaml:"null_columns"`
+
+	// AppendTables maps append-only tables to their watermark column, an
+	// indexed column that only grows; --append-since-last dumps the rows
+	// past the previous dump's watermark
+	AppendTables map[string]string `yaml:"append_tables"`
+
+	// PriorityTables are table names or patterns whose data --time-budget
+	// dumps first, in this order, before the other tables smallest first
+	PriorityTables []string `yaml:"priority_tables"`
+
+	// Jobs are named dumps run with `dbdump run`; the job named defaults
+	// supplies the settings the others leave unset
+	Jobs map[string]Job `yaml:"jobs"`
+
+	// DefaultRulesVersion pins the built-in exclusion rules to an older
+	// version; 0 follows the rules of the running release
+	DefaultRulesVersion int `yaml:"default_rules_version"`
+
+	// FilenameTimestamp sets the time zone and format of the timestamp in
+	// generated dump file names
+	FilenameTimestamp FilenameTimestampConfig `yaml:"filename_timestamp"`
+
+	// Performance sets buffer sizes, compression and parallel jobs
+	Performance PerformanceConfig `yaml:"performance"`
+
+	// Extends names configs merged before this one: paths relative to
+	// this file, or https URLs with an optional sha256 pin
+	Extends ExtendsList `yaml:"extends"`
+
+	// Origins records the config each rule came from; set when loading
+	Origins Origins `yaml:"-"`
 }
 
-// DefaultConfig represents the default excludes
-type DefaultConfig struct {
-	DefaultExcludes ExcludeConfig `yaml:"default_excludes"`
+// PreAnalyzeConfig configures ANALYZE TABLE before interactive selection
+type PreAnalyzeConfig struct {
+	// Top is how many of the largest tables (by data size) are analyzed
+	Top int `yaml:"top"`
+
+	// Budget is the total time allowed, e.g. 30s (default 30s)
+	Budget string `yaml:"budget"`
 }
 
-// LoadDefaults loads the default exclude patterns
-func LoadDefaults() (*DefaultConfig, error) {
-	var config DefaultConfig
-	if err := yaml.Unmarshal([]byte(defaultConfigYAML), &config); err != nil {
-		return nil, fmt.Errorf("failed to parse defaults: %w", err)
-	}
+// BenchStrategy is a named set of extra mysqldump options compared by `dbdump bench`
+type BenchStrategy struct {
+	Name          string   `yaml:"name"`
+	Description   string   `yaml:"description"`
+	MySQLDumpArgs []string `yaml:"mysqldump_args"`
+}
 
-	return &config, nil
+// StructureRule sets the structure level (full, no-indexes or minimal) of
+// excluded tables matching a table name or glob pattern
+type StructureRule struct {
+	Match     string `yaml:"match"`
+	Structure string `yaml:"structure"`
 }
 
-// LoadConfig loads a project-specific configuration file
-func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
+// FilenameTimestampConfig configures the timestamp of generated dump file
+// names; empty fields keep the local-time 20060102_150405 default
+type FilenameTimestampConfig struct {
+	// Zone is local (the default) or utc
+	Zone string `yaml:"zone"`
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
-	}
+	// Format is legacy (the default), iso8601-basic or a Go time layout
+	Format string `yaml:"format"`
+}
+
+// StatsExportConfig configures the opt-in usage records (stats_export)
+type StatsExportConfig struct {
+	Dir string `yaml:"dir"`
+
+	// KeyEnv names the environment variable holding the team's
+	// anonymization key (default DBDUMP_STATS_KEY)
+	KeyEnv string `yaml:"key_env"`
+}
+
+// LabelsConfig configures the tags dbdump adds to each dump by itself
+type LabelsConfig struct {
+	// Git tags the dump with the branch, commit, dirty flag and nearest tag
+	// of the git work tree dbdump runs in (--label-from-git)
+	Git *bool `yaml:"git"`
+}
+
+// GitignoreCheckEnabled reports whether the gitignore check is enabled (the default)
+func (c *Config) GitignoreCheckEnabled() bool {
+	return c.GitignoreCheck == nil || *c.GitignoreCheck
+}
 
-	return &config, nil
+// CharsetConfig configures character set conversion (--convert-charset)
+type CharsetConfig struct {
+	// Collations maps source collations to target collations, overriding the
+	// default of swapping the charset prefix
+	Collations map[string]string `yaml:"collations"`
+}
+
+// IsEmpty reports whether the rule set contains no rules
+func (e ExcludeConfig) IsEmpty() bool {
+	return len(e.Exact) == 0 && len(e.Patterns) == 0
+}
+
+// StdinPath is the config path that reads the project config from stdin
+const StdinPath = "-"
+
+var (
+	stdinOnce sync.Once
+	stdinData []byte
+	stdinErr  error
+)
+
+// readStdin reads the config from stdin the first time it is loaded; stdin
+// can only be consumed once, so later loads reuse the content
+func readStdin() ([]byte, error) {
+	stdinOnce.Do(func() {
+		stdinData, stdinErr = io.ReadAll(os.Stdin)
+	})
+	return stdinData, stdinErr
+}
+
+// StdinConfig returns the config read from stdin, or nil if none was read
+func StdinConfig() []byte {
+	return stdinData
+}
+
+// SourceName returns how a config path is named in messages
+func SourceName(path string) string {
+	if path == StdinPath {
+		return "stdin"
+	}
+	return path
 }
 
 // MergeExcludes merges default excludes with project-specific excludes
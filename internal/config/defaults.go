@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRulesVersion is the version of the built-in exclusion rules this
+// release applies unless default_rules_version pins another
+const DefaultRulesVersion = 3
+
+// Built-in exclusion rules of this and the previous releases, oldest first.
+// Never change a released version: add a new one and bump DefaultRulesVersion.
+const defaultRuleSetsYAML = `- version: 1
+  default_excludes:
+    exact:
+      - sessions
+      - cache
+      - failed_jobs
+      - telescope_entries
+      - telescope_entries_tags
+      - telescope_monitoring
+    patterns:
+      - "telescope_*"
+      - "*_cache"
+- version: 2
+  default_excludes:
+    exact:
+      - audits
+      - sessions
+      - cache
+      - cache_locks
+      - failed_jobs
+      - telescope_entries
+      - telescope_entries_tags
+      - telescope_monitoring
+    patterns:
+      - "telescope_*"
+      - "*_cache"
+- version: 3
+  default_excludes:
+    exact:
+      - audits
+      - sessions
+      - cache
+      - cache_locks
+      - failed_jobs
+      - telescope_entries
+      - telescope_entries_tags
+      - telescope_monitoring
+      - pulse_entries
+      - pulse_aggregates
+    patterns:
+      - "telescope_*"
+      - "pulse_*"
+      - "*_cache"
+`
+
+// DefaultConfig represents the default excludes of one rules version
+type DefaultConfig struct {
+	Version         int           `yaml:"version"`
+	DefaultExcludes ExcludeConfig `yaml:"default_excludes"`
+}
+
+// loadRuleSets parses the embedded rule sets
+func loadRuleSets() ([]DefaultConfig, error) {
+	var sets []DefaultConfig
+	if err := yaml.Unmarshal([]byte(defaultRuleSetsYAML), &sets); err != nil {
+		return nil, fmt.Errorf("failed to parse defaults: %w", err)
+	}
+	return sets, nil
+}
+
+// LoadDefaults loads the default exclude patterns of this release
+func LoadDefaults() (*DefaultConfig, error) {
+	return LoadDefaultsVersion(DefaultRulesVersion)
+}
+
+// LoadDefaultsVersion loads the default exclude patterns of a rules
+// version; 0 is the version of this release
+func LoadDefaultsVersion(version int) (*DefaultConfig, error) {
+	if version == 0 {
+		version = DefaultRulesVersion
+	}
+	sets, err := loadRuleSets()
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range sets {
+		if set.Version == version {
+			return &set, nil
+		}
+	}
+	return nil, fmt.Errorf("built-in rules version %d is not available (this release has %s)", version, formatVersions(DefaultRulesVersions()))
+}
+
+// DefaultRulesVersions returns the rules versions this release ships, oldest first
+func DefaultRulesVersions() []int {
+	sets, err := loadRuleSets()
+	if err != nil {
+		return nil
+	}
+	versions := make([]int, len(sets))
+	for i, set := range sets {
+		versions[i] = set.Version
+	}
+	return versions
+}
+
+// formatVersions writes versions as "v1, v2, v3"
+func formatVersions(versions []int) string {
+	names := make([]string, len(versions))
+	for i, version := range versions {
+		names[i] = fmt.Sprintf("v%d", version)
+	}
+	return strings.Join(names, ", ")
+}
+
+// DiffRules returns the exact names and patterns in to but not in from,
+// and those in from but not in to
+func DiffRules(from, to ExcludeConfig) (added, removed []string) {
+	before := slices.Concat(from.Exact, from.Patterns)
+	after := slices.Concat(to.Exact, to.Patterns)
+	for _, rule := range after {
+		if !slices.Contains(before, rule) && !slices.Contains(added, rule) {
+			added = append(added, rule)
+		}
+	}
+	for _, rule := range before {
+		if !slices.Contains(after, rule) && !slices.Contains(removed, rule) {
+			removed = append(removed, rule)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestRuleSets checks the embedded rule sets are ordered oldest first with
+// the current version last, so pinning and diffing can look them up by number
+func TestRuleSets(t *testing.T) {
+	versions := DefaultRulesVersions()
+	if len(versions) == 0 {
+		t.Fatal("no built-in rule sets")
+	}
+	for i := 1; i < len(versions); i++ {
+		if versions[i] <= versions[i-1] {
+			t.Errorf("versions %v are not ascending and unique", versions)
+		}
+	}
+	if last := versions[len(versions)-1]; last != DefaultRulesVersion {
+		t.Errorf("latest rule set is v%d, want DefaultRulesVersion v%d", last, DefaultRulesVersion)
+	}
+
+	current, err := LoadDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != DefaultRulesVersion {
+		t.Errorf("LoadDefaults() = v%d, want v%d", current.Version, DefaultRulesVersion)
+	}
+}
+
+func TestLoadDefaultsVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		want    int
+		exact   string // a rule the version has
+		without string // a rule the version lacks
+		wantErr bool
+	}{
+		{name: "zero is current", version: 0, want: DefaultRulesVersion, exact: "pulse_entries"},
+		{name: "v1", version: 1, want: 1, exact: "sessions", without: "audits"},
+		{name: "v2", version: 2, want: 2, exact: "cache_locks", without: "pulse_entries"},
+		{name: "v3", version: 3, want: 3, exact: "pulse_aggregates"},
+		{name: "newer than this release", version: 4, wantErr: true},
+		{name: "negative", version: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults, err := LoadDefaultsVersion(tt.version)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LoadDefaultsVersion(%d) = v%d, want an error", tt.version, defaults.Version)
+				}
+				for _, want := range []string{"not available", "v1, v2, v3"} {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q doesn't mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if defaults.Version != tt.want {
+				t.Errorf("version = %d, want %d", defaults.Version, tt.want)
+			}
+			rules := slices.Concat(defaults.DefaultExcludes.Exact, defaults.DefaultExcludes.Patterns)
+			if !slices.Contains(rules, tt.exact) {
+				t.Errorf("v%d rules %v lack %q", tt.want, rules, tt.exact)
+			}
+			if tt.without != "" && slices.Contains(rules, tt.without) {
+				t.Errorf("v%d rules %v have %q", tt.want, rules, tt.without)
+			}
+		})
+	}
+}
+
+// TestDiffRules runs the diff over synthetic version histories as well as
+// the shipped ones
+func TestDiffRules(t *testing.T) {
+	v1 := ExcludeConfig{Exact: []string{"sessions", "cache"}, Patterns: []string{"telescope_*"}}
+	v2 := ExcludeConfig{Exact: []string{"sessions", "cache", "audits"}, Patterns: []string{"telescope_*"}}
+	v3 := ExcludeConfig{Exact: []string{"sessions", "audits"}, Patterns: []string{"telescope_*", "pulse_*"}}
+	// v4 turns an exact name into a pattern of the same spelling
+	v4 := ExcludeConfig{Exact: []string{"sessions"}, Patterns: []string{"telescope_*", "pulse_*", "audits"}}
+
+	tests := []struct {
+		name        string
+		from, to    ExcludeConfig
+		wantAdded   []string
+		wantRemoved []string
+	}{
+		{name: "same", from: v2, to: v2},
+		{name: "added exact", from: v1, to: v2, wantAdded: []string{"audits"}},
+		{name: "added and removed", from: v2, to: v3, wantAdded: []string{"pulse_*"}, wantRemoved: []string{"cache"}},
+		{name: "skipping a version", from: v1, to: v3, wantAdded: []string{"audits", "pulse_*"}, wantRemoved: []string{"cache"}},
+		{name: "downgrade", from: v3, to: v1, wantAdded: []string{"cache"}, wantRemoved: []string{"audits", "pulse_*"}},
+		{name: "moved between lists", from: v3, to: v4},
+		{name: "from nothing", to: v1, wantAdded: []string{"sessions", "cache", "telescope_*"}},
+		{name: "to nothing", from: v1, wantRemoved: []string{"sessions", "cache", "telescope_*"}},
+		{
+			name:      "duplicates",
+			from:      ExcludeConfig{Exact: []string{"sessions"}},
+			to:        ExcludeConfig{Exact: []string{"jobs", "jobs"}, Patterns: []string{"jobs"}},
+			wantAdded: []string{"jobs"}, wantRemoved: []string{"sessions"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := DiffRules(tt.from, tt.to)
+			if !reflect.DeepEqual(added, tt.wantAdded) {
+				t.Errorf("added = %v, want %v", added, tt.wantAdded)
+			}
+			if !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+// TestDiffShippedRules checks each release's rules only grew
+func TestDiffShippedRules(t *testing.T) {
+	versions := DefaultRulesVersions()
+	for i := 1; i < len(versions); i++ {
+		from, err := LoadDefaultsVersion(versions[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		to, err := LoadDefaultsVersion(versions[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		added, removed := DiffRules(from.DefaultExcludes, to.DefaultExcludes)
+		if len(added) == 0 {
+			t.Errorf("v%d → v%d adds no rules", versions[i-1], versions[i])
+		}
+		if len(removed) != 0 {
+			t.Errorf("v%d → v%d removes %v", versions[i-1], versions[i], removed)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// extendsCacheTTL is how long a remote config is used before it is
+	// fetched again
+	extendsCacheTTL = time.Hour
+
+	// extendsTimeout bounds fetching a remote config
+	extendsTimeout = 30 * time.Second
+
+	// maxRemoteConfig is the largest remote config read
+	maxRemoteConfig = 1 << 20
+)
+
+// ExtendsRef names a config merged before the one naming it: a path
+// (relative to the including file) or an https URL, optionally pinned to
+// the SHA-256 of its content
+type ExtendsRef struct {
+	Path   string `yaml:"path"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// UnmarshalYAML accepts a bare path as well as a mapping with a pin
+func (r *ExtendsRef) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		r.Path = node.Value
+		return nil
+	}
+	type plain ExtendsRef
+	return node.Decode((*plain)(r))
+}
+
+// ExtendsList is the extends key: one reference or a list of them
+type ExtendsList []ExtendsRef
+
+// UnmarshalYAML accepts a single reference as well as a list
+func (l *ExtendsList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode {
+		var ref ExtendsRef
+		if err := node.Decode(&ref); err != nil {
+			return err
+		}
+		*l = ExtendsList{ref}
+		return nil
+	}
+	var refs []ExtendsRef
+	if err := node.Decode(&refs); err != nil {
+		return err
+	}
+	*l = refs
+	return nil
+}
+
+// Origins records the config each exclude and include rule came from,
+// keyed by section and rule (an exact table name or a pattern)
+type Origins map[string]string
+
+// Add records source as the origin of rules unless they already have one:
+// rules merged later repeat an earlier rule
+func (o Origins) Add(section string, rules ExcludeConfig, source string) {
+	for _, rule := range slices.Concat(rules.Exact, rules.Patterns) {
+		if _, ok := o[section+":"+rule]; !ok {
+			o[section+":"+rule] = source
+		}
+	}
+}
+
+// Of returns the origin of a rule, or "" if it isn't known
+func (o Origins) Of(section, rule string) string {
+	return o[section+":"+rule]
+}
+
+// Merge adds the origins of other that o doesn't have
+func (o Origins) Merge(other Origins) {
+	for key, source := range other {
+		if _, ok := o[key]; !ok {
+			o[key] = source
+		}
+	}
+}
+
+// LoadConfigs loads the project configs given with --config and merges
+// them in order, each after the configs it extends
+func LoadConfigs(paths []string) (*Config, error) {
+	merged := &Config{Origins: make(Origins)}
+	for _, path := range paths {
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		merged.Merge(cfg)
+	}
+	return merged, nil
+}
+
+// LoadConfig loads a project-specific configuration file, merged after
+// the configs it extends
+func LoadConfig(path string) (*Config, error) {
+	return loadChain(path, "", nil)
+}
+
+// loadChain loads the config at location (a path or an https URL), checked
+// against pin when set, and the configs it extends; chain holds the
+// configs including it, to detect cycles
+func loadChain(location, pin string, chain []string) (*Config, error) {
+	source := SourceName(location)
+	if slices.Contains(chain, configKey(location)) {
+		return nil, &dberrors.ErrConfigInvalid{
+			Source:   SourceName(chain[0]),
+			Problems: []string{"extends cycle: " + strings.Join(append(slices.Clone(chain), configKey(location)), " → ")},
+		}
+	}
+	chain = append(chain, configKey(location))
+
+	data, err := readConfig(location, pin)
+	if err != nil {
+		return nil, &dberrors.ErrConfigInvalid{Source: source, Err: err}
+	}
+	var own Config
+	if err := yaml.Unmarshal(data, &own); err != nil {
+		return nil, &dberrors.ErrConfigInvalid{
+			Source: source,
+			Err:    fmt.Errorf("failed to parse config file: %w", err),
+		}
+	}
+	own.Origins = make(Origins)
+	own.Origins.Add("exclude", own.Exclude, source)
+	own.Origins.Add("include", own.Include, source)
+	own.Origins.Add("only", own.Only, source)
+
+	merged := &Config{Origins: make(Origins)}
+	for _, ref := range own.Extends {
+		if ref.Path == "" {
+			return nil, &dberrors.ErrConfigInvalid{Source: source, Problems: []string{"extends entry without a path"}}
+		}
+		base, err := resolveExtends(location, ref.Path)
+		if err != nil {
+			return nil, &dberrors.ErrConfigInvalid{Source: source, Err: err}
+		}
+		cfg, err := loadChain(base, ref.SHA256, chain)
+		if err != nil {
+			return nil, err
+		}
+		merged.Merge(cfg)
+	}
+	merged.Merge(&own)
+	merged.Extends = nil
+	return merged, nil
+}
+
+// configKey identifies a config in an extends chain, however its path is spelled
+func configKey(location string) string {
+	if location == StdinPath || isRemote(location) {
+		return location
+	}
+	if abs, err := filepath.Abs(location); err == nil {
+		return abs
+	}
+	return location
+}
+
+// isRemote reports whether a config location is a URL
+func isRemote(location string) bool {
+	return strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://")
+}
+
+// resolveExtends resolves an extends reference against the config naming it
+func resolveExtends(including, ref string) (string, error) {
+	if strings.HasPrefix(ref, "http://") {
+		return "", fmt.Errorf("extends %s: only https URLs are allowed", ref)
+	}
+	if isRemote(including) {
+		base, err := url.Parse(including)
+		if err != nil {
+			return "", fmt.Errorf("extends %s: %w", ref, err)
+		}
+		resolved, err := base.Parse(ref)
+		if err != nil {
+			return "", fmt.Errorf("extends %s: %w", ref, err)
+		}
+		if resolved.Scheme != "https" {
+			return "", fmt.Errorf("extends %s: a remote config can only extend https URLs", ref)
+		}
+		return resolved.String(), nil
+	}
+	if isRemote(ref) || filepath.IsAbs(ref) {
+		return ref, nil
+	}
+	if strings.HasPrefix(ref, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		return filepath.Join(home, ref[2:]), nil
+	}
+	// Relative to the including file; stdin has no directory of its own
+	if including == StdinPath {
+		return ref, nil
+	}
+	return filepath.Join(filepath.Dir(including), ref), nil
+}
+
+// readConfig reads a config from a file, stdin or an https URL, checking
+// its SHA-256 when pinned
+func readConfig(location, pin string) ([]byte, error) {
+	var data []byte
+	var err error
+	switch {
+	case location == StdinPath:
+		data, err = readStdin()
+	case isRemote(location):
+		data, err = fetchRemote(location)
+	default:
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if pin != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, strings.TrimPrefix(pin, "sha256:")) {
+			return nil, fmt.Errorf("%s does not match its sha256 pin (got %s)", location, got)
+		}
+	}
+	return data, nil
+}
+
+// fetchRemote returns a remote config, from the cache when it was fetched
+// within extendsCacheTTL
+func fetchRemote(location string) ([]byte, error) {
+	cachePath := ""
+	if dir, err := GetConfigDir(); err == nil {
+		key := sha256.Sum256([]byte(location))
+		cachePath = filepath.Join(dir, "extends-cache", hex.EncodeToString(key[:16])+".yaml")
+		if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < extendsCacheTTL {
+			if data, err := os.ReadFile(cachePath); err == nil {
+				return data, nil
+			}
+		}
+	}
+
+	client := &http.Client{Timeout: extendsTimeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", location, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfig+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", location, err)
+	}
+	if len(data) > maxRemoteConfig {
+		return nil, fmt.Errorf("fetching %s: larger than %d bytes", location, maxRemoteConfig)
+	}
+
+	// A failed cache write only means fetching again next time
+	if cachePath != "" && os.MkdirAll(filepath.Dir(cachePath), 0700) == nil {
+		_ = fileutil.WriteFileAtomic(cachePath, data, 0600)
+	}
+	return data, nil
+}
+
+// Merge applies overlay on top of c, as a project config applies on top of
+// the global one: rules are added (each kept once), settings overlay sets
+// replace those of c, map entries replace those with the same key, and
+// overlay's structure rules and bench strategies come first
+func (c *Config) Merge(overlay *Config) {
+	if c.Origins == nil {
+		c.Origins = make(Origins)
+	}
+	c.Origins.Merge(overlay.Origins)
+
+	if overlay.Name != "" {
+		c.Name = overlay.Name
+	}
+	c.Exclude = mergeRules(c.Exclude, overlay.Exclude)
+	c.Only = mergeRules(c.Only, overlay.Only)
+	c.Include = mergeRules(c.Include, overlay.Include)
+	c.Charset.Collations = mergeMap(c.Charset.Collations, overlay.Charset.Collations)
+	c.Confidence = mergeMap(c.Confidence, overlay.Confidence)
+	if overlay.GitignoreCheck != nil {
+		c.GitignoreCheck = overlay.GitignoreCheck
+	}
+	if overlay.Labels.Git != nil {
+		c.Labels.Git = overlay.Labels.Git
+	}
+	if overlay.SizeWarningFactor != 0 {
+		c.SizeWarningFactor = overlay.SizeWarningFactor
+	}
+	if overlay.SelectionConflict != "" {
+		c.SelectionConflict = overlay.SelectionConflict
+	}
+	if overlay.StatsExport.Dir != "" {
+		c.StatsExport.Dir = overlay.StatsExport.Dir
+	}
+	if overlay.StatsExport.KeyEnv != "" {
+		c.StatsExport.KeyEnv = overlay.StatsExport.KeyEnv
+	}
+	c.StructureRules = slices.Concat(overlay.StructureRules, c.StructureRules)
+
+	strategies := slices.Clone(overlay.BenchStrategies)
+	for _, strategy := range c.BenchStrategies {
+		if !slices.ContainsFunc(overlay.BenchStrategies, func(s BenchStrategy) bool { return s.Name == strategy.Name }) {
+			strategies = append(strategies, strategy)
+		}
+	}
+	c.BenchStrategies = strategies
+
+	if overlay.PreAnalyze.Top != 0 {
+		c.PreAnalyze = overlay.PreAnalyze
+	}
+	c.Sample = mergeMap(c.Sample, overlay.Sample)
+	c.Mask = mergeMap(c.Mask, overlay.Mask)
+	c.NullColumns = mergeNullColumns(c.NullColumns, overlay.NullColumns)
+	c.AppendTables = mergeMap(c.AppendTables, overlay.AppendTables)
+	c.PriorityTables = uniqueStrings(slices.Concat(overlay.PriorityTables, c.PriorityTables))
+	c.Jobs = mergeMap(c.Jobs, overlay.Jobs)
+	if overlay.FilenameTimestamp.Zone != "" {
+		c.FilenameTimestamp.Zone = overlay.FilenameTimestamp.Zone
+	}
+	if overlay.FilenameTimestamp.Format != "" {
+		c.FilenameTimestamp.Format = overlay.FilenameTimestamp.Format
+	}
+	if overlay.DefaultRulesVersion != 0 {
+		c.DefaultRulesVersion = overlay.DefaultRulesVersion
+	}
+	c.Performance = c.Performance.merge(overlay.Performance)
+}
+
+// mergeRules adds the rules of overlay not already in base
+func mergeRules(base, overlay ExcludeConfig) ExcludeConfig {
+	if overlay.IsEmpty() {
+		return base
+	}
+	return ExcludeConfig{
+		Exact:    uniqueStrings(slices.Concat(base.Exact, overlay.Exact)),
+		Patterns: uniqueStrings(slices.Concat(base.Patterns, overlay.Patterns)),
+	}
+}
+
+// mergeMap returns base with the entries of overlay, which win
+func mergeMap[V any](base, overlay map[string]V) map[string]V {
+	if len(overlay) == 0 {
+		return base
+	}
+	merged := make(map[string]V, len(base)+len(overlay))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overlay {
+		merged[key] = value
+	}
+	return merged
+}
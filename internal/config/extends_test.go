@@ -0,0 +1,374 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+// writeFiles writes files (relative path to content) under dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestExtendsChain merges a three-level chain whose levels include tables
+// another level excludes: both rules are kept, in chain order, each with
+// the file that first named it
+func TestExtendsChain(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"shared/base.yaml": `name: base
+size_warning_factor: 2
+exclude:
+  exact: [sessions, cache]
+  patterns: ["*_log"]
+include:
+  exact: [users, orders]
+`,
+		// Excludes a table base includes and includes one base excludes
+		"shared/team/team.yaml": `name: team
+extends: ../base.yaml
+size_warning_factor: 4
+exclude:
+  exact: [users]
+include:
+  exact: [sessions]
+  patterns: ["audit_*"]
+`,
+		// Excludes a table base includes, includes one team excludes, and
+		// repeats a rule of base
+		"app/.dbdump.yaml": `extends:
+  - path: ../shared/team/team.yaml
+exclude:
+  exact: [orders, cache]
+include:
+  exact: [users]
+`,
+		"other/extra.yaml": `exclude:
+  exact: [jobs, sessions]
+`,
+	})
+	base := filepath.Join(dir, "shared", "base.yaml")
+	team := filepath.Join(dir, "shared", "team", "team.yaml")
+	project := filepath.Join(dir, "app", ".dbdump.yaml")
+	extra := filepath.Join(dir, "other", "extra.yaml")
+
+	tests := []struct {
+		name        string
+		paths       []string
+		wantExclude ExcludeConfig
+		wantInclude ExcludeConfig
+		wantName    string
+		wantFactor  float64
+		origins     map[string]string
+	}{
+		{
+			name:        "three levels",
+			paths:       []string{project},
+			wantExclude: ExcludeConfig{Exact: []string{"sessions", "cache", "users", "orders"}, Patterns: []string{"*_log"}},
+			wantInclude: ExcludeConfig{Exact: []string{"users", "orders", "sessions"}, Patterns: []string{"audit_*"}},
+			wantName:    "team",
+			wantFactor:  4,
+			origins: map[string]string{
+				"exclude:sessions": base,
+				"exclude:cache":    base,
+				"exclude:*_log":    base,
+				"exclude:users":    team,
+				"exclude:orders":   project,
+				"include:users":    base,
+				"include:orders":   base,
+				"include:sessions": team,
+				"include:audit_*":  team,
+			},
+		},
+		{
+			name:        "middle level",
+			paths:       []string{team},
+			wantExclude: ExcludeConfig{Exact: []string{"sessions", "cache", "users"}, Patterns: []string{"*_log"}},
+			wantInclude: ExcludeConfig{Exact: []string{"users", "orders", "sessions"}, Patterns: []string{"audit_*"}},
+			wantName:    "team",
+			wantFactor:  4,
+			origins:     map[string]string{"exclude:users": team, "include:users": base},
+		},
+		{
+			name:        "repeated --config",
+			paths:       []string{extra, project},
+			wantExclude: ExcludeConfig{Exact: []string{"jobs", "sessions", "cache", "users", "orders"}, Patterns: []string{"*_log"}},
+			wantInclude: ExcludeConfig{Exact: []string{"users", "orders", "sessions"}, Patterns: []string{"audit_*"}},
+			wantName:    "team",
+			wantFactor:  4,
+			origins:     map[string]string{"exclude:sessions": extra, "exclude:jobs": extra, "exclude:cache": base},
+		},
+		{
+			name:        "repeated --config reversed",
+			paths:       []string{project, extra},
+			wantExclude: ExcludeConfig{Exact: []string{"sessions", "cache", "users", "orders", "jobs"}, Patterns: []string{"*_log"}},
+			wantInclude: ExcludeConfig{Exact: []string{"users", "orders", "sessions"}, Patterns: []string{"audit_*"}},
+			wantName:    "team",
+			wantFactor:  4,
+			origins:     map[string]string{"exclude:sessions": base, "exclude:jobs": extra},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfigs(tt.paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.Exclude, tt.wantExclude) {
+				t.Errorf("exclude = %+v, want %+v", cfg.Exclude, tt.wantExclude)
+			}
+			if !reflect.DeepEqual(cfg.Include, tt.wantInclude) {
+				t.Errorf("include = %+v, want %+v", cfg.Include, tt.wantInclude)
+			}
+			if cfg.Name != tt.wantName {
+				t.Errorf("name = %q, want %q", cfg.Name, tt.wantName)
+			}
+			if cfg.SizeWarningFactor != tt.wantFactor {
+				t.Errorf("size_warning_factor = %v, want %v", cfg.SizeWarningFactor, tt.wantFactor)
+			}
+			if len(cfg.Extends) != 0 {
+				t.Errorf("extends = %v, want none after merging", cfg.Extends)
+			}
+			for key, want := range tt.origins {
+				section, rule, _ := strings.Cut(key, ":")
+				if got := cfg.Origins.Of(section, rule); got != want {
+					t.Errorf("origin of %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExtendsCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"self.yaml":  "extends: ./self.yaml\n",
+		"a.yaml":     "extends: sub/b.yaml\n",
+		"sub/b.yaml": "extends: [c.yaml]\n",
+		// The same file spelled another way is still a cycle
+		"sub/c.yaml": "extends: ../sub/../a.yaml\n",
+		// A diamond reaches d.yaml twice without a cycle
+		"top.yaml":   "extends: [left.yaml, right.yaml]\n",
+		"left.yaml":  "extends: d.yaml\nexclude:\n  exact: [left]\n",
+		"right.yaml": "extends: d.yaml\nexclude:\n  exact: [right]\n",
+		"d.yaml":     "exclude:\n  exact: [shared]\n",
+	})
+
+	tests := []struct {
+		name      string
+		path      string
+		wantChain []string // file names in the reported cycle, none if no cycle
+	}{
+		{name: "self", path: "self.yaml", wantChain: []string{"self.yaml", "self.yaml"}},
+		{name: "three files", path: "a.yaml", wantChain: []string{"a.yaml", "b.yaml", "c.yaml", "a.yaml"}},
+		{name: "entering mid-cycle", path: "sub/c.yaml", wantChain: []string{"c.yaml", "a.yaml", "b.yaml", "c.yaml"}},
+		{name: "diamond", path: "top.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(filepath.Join(dir, tt.path))
+			if len(tt.wantChain) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := []string{"shared", "left", "right"}
+				if !reflect.DeepEqual(cfg.Exclude.Exact, want) {
+					t.Errorf("exclude = %v, want %v", cfg.Exclude.Exact, want)
+				}
+				return
+			}
+
+			var invalid *dberrors.ErrConfigInvalid
+			if !errors.As(err, &invalid) {
+				t.Fatalf("err = %v, want ErrConfigInvalid", err)
+			}
+			if len(invalid.Problems) != 1 || !strings.HasPrefix(invalid.Problems[0], "extends cycle: ") {
+				t.Fatalf("problems = %q, want an extends cycle", invalid.Problems)
+			}
+			steps := strings.Split(strings.TrimPrefix(invalid.Problems[0], "extends cycle: "), " → ")
+			var names []string
+			for _, step := range steps {
+				if !filepath.IsAbs(step) {
+					t.Errorf("cycle step %q is not an absolute path", step)
+				}
+				names = append(names, filepath.Base(step))
+			}
+			if !reflect.DeepEqual(names, tt.wantChain) {
+				t.Errorf("cycle = %v, want %v", names, tt.wantChain)
+			}
+		})
+	}
+}
+
+func TestResolveExtends(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		name      string
+		including string
+		ref       string
+		want      string
+		wantErr   string
+	}{
+		{name: "sibling", including: "/srv/app/.dbdump.yaml", ref: "base.yaml", want: "/srv/app/base.yaml"},
+		{name: "parent", including: "/srv/app/.dbdump.yaml", ref: "../shared/base.yaml", want: "/srv/shared/base.yaml"},
+		{name: "relative including", including: "app/.dbdump.yaml", ref: "base.yaml", want: "app/base.yaml"},
+		{name: "absolute", including: "/srv/app/.dbdump.yaml", ref: "/etc/dbdump/base.yaml", want: "/etc/dbdump/base.yaml"},
+		{name: "home", including: "/srv/app/.dbdump.yaml", ref: "~/dbdump/base.yaml", want: filepath.Join(home, "dbdump", "base.yaml")},
+		{name: "from stdin", including: StdinPath, ref: "base.yaml", want: "base.yaml"},
+		{name: "https", including: "/srv/app/.dbdump.yaml", ref: "https://example.com/base.yaml", want: "https://example.com/base.yaml"},
+		{name: "http", including: "/srv/app/.dbdump.yaml", ref: "http://example.com/base.yaml", wantErr: "only https"},
+		{name: "remote sibling", including: "https://example.com/configs/team.yaml", ref: "base.yaml", want: "https://example.com/configs/base.yaml"},
+		{name: "remote parent", including: "https://example.com/configs/team.yaml", ref: "../base.yaml", want: "https://example.com/base.yaml"},
+		{name: "remote rooted", including: "https://example.com/configs/team.yaml", ref: "/etc/passwd", want: "https://example.com/etc/passwd"},
+		{name: "remote to file", including: "https://example.com/configs/team.yaml", ref: "file:///etc/passwd", wantErr: "only extend https"},
+		{name: "remote to http", including: "https://example.com/configs/team.yaml", ref: "http://example.com/base.yaml", wantErr: "only https"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveExtends(tt.including, tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveExtends(%q, %q) = %q, %v; want an error with %q", tt.including, tt.ref, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveExtends(%q, %q) = %q, want %q", tt.including, tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtendsPin(t *testing.T) {
+	dir := t.TempDir()
+	content := "exclude:\n  exact: [sessions]\n"
+	writeFiles(t, dir, map[string]string{"base.yaml": content})
+	sum := sha256.Sum256([]byte(content))
+	pin := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name    string
+		pin     string
+		wantErr bool
+	}{
+		{name: "matching", pin: pin},
+		{name: "prefixed", pin: "sha256:" + pin},
+		{name: "upper case", pin: strings.ToUpper(pin)},
+		{name: "other content", pin: strings.Repeat("0", 64), wantErr: true},
+		{name: "truncated", pin: pin[:32], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := filepath.Join(t.TempDir(), ".dbdump.yaml")
+			writeFiles(t, filepath.Dir(project), map[string]string{
+				".dbdump.yaml": "extends:\n  path: " + filepath.Join(dir, "base.yaml") + "\n  sha256: \"" + tt.pin + "\"\n",
+			})
+			cfg, err := LoadConfig(project)
+			if tt.wantErr {
+				var invalid *dberrors.ErrConfigInvalid
+				if !errors.As(err, &invalid) {
+					t.Fatalf("err = %v, want ErrConfigInvalid", err)
+				}
+				if !strings.Contains(err.Error(), "does not match its sha256 pin (got "+pin+")") {
+					t.Errorf("error %q doesn't give the actual sha256", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.Exclude.Exact, []string{"sessions"}) {
+				t.Errorf("exclude = %v, want [sessions]", cfg.Exclude.Exact)
+			}
+		})
+	}
+}
+
+// TestRemoteExtends fetches a chain of https configs, the second time from
+// the cache, and checks the pin of the cached content too
+func TestRemoteExtends(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	base := "exclude:\n  exact: [sessions]\n"
+	var fetches atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		switch r.URL.Path {
+		case "/configs/team.yaml":
+			_, _ = w.Write([]byte("extends: base.yaml\ninclude:\n  exact: [users]\n"))
+		case "/configs/base.yaml":
+			_, _ = w.Write([]byte(base))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	savedTransport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() {
+		http.DefaultTransport = savedTransport
+	}()
+
+	sum := sha256.Sum256([]byte(base))
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"chain.yaml":   "extends: " + server.URL + "/configs/team.yaml\n",
+		"pinned.yaml":  "extends:\n  path: " + server.URL + "/configs/base.yaml\n  sha256: " + hex.EncodeToString(sum[:]) + "\n",
+		"wrong.yaml":   "extends:\n  path: " + server.URL + "/configs/base.yaml\n  sha256: " + strings.Repeat("0", 64) + "\n",
+		"missing.yaml": "extends: " + server.URL + "/configs/gone.yaml\n",
+	})
+
+	for round := 1; round <= 2; round++ {
+		cfg, err := LoadConfig(filepath.Join(dir, "chain.yaml"))
+		if err != nil {
+			t.Fatalf("round %d: %v", round, err)
+		}
+		if !reflect.DeepEqual(cfg.Exclude.Exact, []string{"sessions"}) || !reflect.DeepEqual(cfg.Include.Exact, []string{"users"}) {
+			t.Errorf("round %d: exclude %v, include %v; want [sessions], [users]", round, cfg.Exclude.Exact, cfg.Include.Exact)
+		}
+		if got := cfg.Origins.Of("exclude", "sessions"); got != server.URL+"/configs/base.yaml" {
+			t.Errorf("round %d: origin of sessions = %q", round, got)
+		}
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("fetched %d times, want 2 (the second load is cached)", got)
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "pinned.yaml")); err != nil {
+		t.Errorf("pinned: %v", err)
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "wrong.yaml")); err == nil || !strings.Contains(err.Error(), "sha256 pin") {
+		t.Errorf("wrong pin: err = %v, want a pin mismatch", err)
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "missing.yaml")); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("missing: err = %v, want the 404", err)
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/units"
+)
+
+// DefaultsJob is the job every other job inherits from
+const DefaultsJob = "defaults"
+
+// Job is a named dump in the project config, run with `dbdump run <job>`.
+// Unset fields are inherited from the defaults job; rules are added to it.
+type Job struct {
+	// Profile is a saved connection profile; the fields below override it
+	Profile  string `yaml:"profile"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Database string `yaml:"database"`
+
+	Exclude ExcludeConfig  `yaml:"exclude"`
+	Include ExcludeConfig  `yaml:"include"`
+	Only    ExcludeConfig  `yaml:"only"`
+	Sample  map[string]int `yaml:"sample"`
+
+	// Output is the dump file; OutputDir keeps the generated
+	// {database}_{timestamp}.sql name but writes it to another directory
+	Output    string `yaml:"output"`
+	OutputDir string `yaml:"output_dir"`
+
+	Compress    *bool             `yaml:"compress"`
+	MaxFileSize string            `yaml:"max_file_size"`
+	SkipEngines []string          `yaml:"skip_engines"`
+	Verify      string            `yaml:"verify"`
+	Tags        map[string]string `yaml:"tags"`
+}
+
+// JobNames returns the runnable jobs in name order, without the defaults job
+func (c *Config) JobNames() []string {
+	var names []string
+	for name := range c.Jobs {
+		if name != DefaultsJob {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Job returns a job with the defaults job applied
+func (c *Config) Job(name string) (Job, error) {
+	job, ok := c.Jobs[name]
+	if !ok || name == DefaultsJob {
+		if names := c.JobNames(); len(names) > 0 {
+			return Job{}, fmt.Errorf("no job named %q (available: %s)", name, strings.Join(names, ", "))
+		}
+		return Job{}, fmt.Errorf("no job named %q; the config defines no jobs", name)
+	}
+	return c.Jobs[DefaultsJob].extend(job), nil
+}
+
+// extend returns job with the fields it leaves unset taken from base; rules,
+// samples and tags are combined, the job's own winning on conflicts
+func (base Job) extend(job Job) Job {
+	merged := job
+	for _, field := range []struct{ value, fallback *string }{
+		{&merged.Profile, &base.Profile},
+		{&merged.Host, &base.Host},
+		{&merged.User, &base.User},
+		{&merged.Database, &base.Database},
+		{&merged.MaxFileSize, &base.MaxFileSize},
+		{&merged.Verify, &base.Verify},
+	} {
+		if *field.value == "" {
+			*field.value = *field.fallback
+		}
+	}
+	if job.Output == "" && job.OutputDir == "" {
+		merged.Output, merged.OutputDir = base.Output, base.OutputDir
+	}
+	if merged.Port == 0 {
+		merged.Port = base.Port
+	}
+	if merged.Compress == nil {
+		merged.Compress = base.Compress
+	}
+	if merged.SkipEngines == nil {
+		merged.SkipEngines = base.SkipEngines
+	}
+
+	merged.Exclude = ExcludeConfig{
+		Exact:    uniqueStrings(append(slices.Clone(base.Exclude.Exact), job.Exclude.Exact...)),
+		Patterns: uniqueStrings(append(slices.Clone(base.Exclude.Patterns), job.Exclude.Patterns...)),
+	}
+	merged.Include = ExcludeConfig{
+		Exact:    uniqueStrings(append(slices.Clone(base.Include.Exact), job.Include.Exact...)),
+		Patterns: uniqueStrings(append(slices.Clone(base.Include.Patterns), job.Include.Patterns...)),
+	}
+	merged.Only = ExcludeConfig{
+		Exact:    uniqueStrings(append(slices.Clone(base.Only.Exact), job.Only.Exact...)),
+		Patterns: uniqueStrings(append(slices.Clone(base.Only.Patterns), job.Only.Patterns...)),
+	}
+	merged.Sample = maps.Clone(base.Sample)
+	if merged.Sample == nil {
+		merged.Sample = make(map[string]int)
+	}
+	maps.Copy(merged.Sample, job.Sample)
+	merged.Tags = maps.Clone(base.Tags)
+	if merged.Tags == nil {
+		merged.Tags = make(map[string]string)
+	}
+	maps.Copy(merged.Tags, job.Tags)
+	return merged
+}
+
+// JobProblems checks every job (with its defaults applied) and returns
+// what is wrong with them; profiles and patterns are checked by the caller
+func (c *Config) JobProblems() []string {
+	var problems []string
+	for _, name := range c.JobNames() {
+		if name == "" || strings.ContainsAny(name, " \t\n") {
+			problems = append(problems, fmt.Sprintf("jobs: %q is not a valid job name (no spaces)", name))
+			continue
+		}
+		job, _ := c.Job(name)
+		prefix := "jobs." + name + ": "
+		if job.Database == "" && job.Profile == "" {
+			problems = append(problems, prefix+"needs a database or a profile")
+		}
+		if job.Output != "" && job.OutputDir != "" {
+			problems = append(problems, prefix+"output and output_dir cannot both be set")
+		}
+		if job.MaxFileSize != "" {
+			if size, err := units.ParseBytes(job.MaxFileSize); err != nil {
+				problems = append(problems, fmt.Sprintf("%smax_file_size: %v", prefix, err))
+			} else if size < 1024*1024 {
+				problems = append(problems, prefix+"max_file_size must be at least 1MiB")
+			}
+		}
+		if job.Verify != "" && job.Verify != "restore" && job.Verify != "order" {
+			problems = append(problems, fmt.Sprintf("%sunsupported verify mode %q (supported: order, restore)", prefix, job.Verify))
+		}
+		for _, table := range slices.Sorted(maps.Keys(job.Sample)) {
+			if job.Sample[table] <= 0 {
+				problems = append(problems, fmt.Sprintf("%ssample: %s must keep at least 1 row", prefix, table))
+			}
+		}
+	}
+	return problems
+}
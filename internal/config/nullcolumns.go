@@ -0,0 +1,47 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// NullColumns are the columns of one table dumped as placeholders. Each
+// maps to a literal written instead of its values, or to nil for NULL (or
+// the column's default when it is NOT NULL).
+type NullColumns map[string]*string
+
+// UnmarshalYAML accepts a list of column names as well as a mapping of
+// columns to literals
+func (n *NullColumns) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var columns []string
+		if err := node.Decode(&columns); err != nil {
+			return err
+		}
+		*n = make(NullColumns, len(columns))
+		for _, column := range columns {
+			(*n)[column] = nil
+		}
+		return nil
+	}
+	var columns map[string]*string
+	if err := node.Decode(&columns); err != nil {
+		return err
+	}
+	*n = columns
+	return nil
+}
+
+// mergeNullColumns merges overlay into base column by column
+func mergeNullColumns(base, overlay map[string]NullColumns) map[string]NullColumns {
+	if len(overlay) == 0 {
+		return base
+	}
+	merged := make(map[string]NullColumns, len(base)+len(overlay))
+	for table, columns := range base {
+		merged[table] = columns
+	}
+	for table, columns := range overlay {
+		merged[table] = mergeMap(merged[table], columns)
+	}
+	return merged
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNullColumnsYAML(t *testing.T) {
+	literal := func(s string) *string { return &s }
+	tests := []struct {
+		name string
+		yaml string
+		want map[string]NullColumns
+	}{
+		{
+			name: "list of columns",
+			yaml: "null_columns:\n  users: [phone, notes]\n",
+			want: map[string]NullColumns{"users": {"phone": nil, "notes": nil}},
+		},
+		{
+			name: "columns with literals",
+			yaml: "null_columns:\n  users:\n    email: redacted@example.invalid\n    notes: ~\n",
+			want: map[string]NullColumns{"users": {"email": literal("redacted@example.invalid"), "notes": nil}},
+		},
+		{
+			name: "empty literal",
+			yaml: "null_columns:\n  users:\n    token: ''\n",
+			want: map[string]NullColumns{"users": {"token": literal("")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if err := yaml.Unmarshal([]byte(tt.yaml), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.NullColumns, tt.want) {
+				t.Errorf("NullColumns = %v, want %v", cfg.NullColumns, tt.want)
+			}
+		})
+	}
+}
+
+// TestMergeNullColumns checks that an overlay replaces columns of the same
+// table one by one and keeps the others
+func TestMergeNullColumns(t *testing.T) {
+	literal := "n/a"
+	base := map[string]NullColumns{"users": {"phone": nil, "email": nil}, "orders": {"notes": nil}}
+	overlay := map[string]NullColumns{"users": {"email": &literal}, "sessions": {"ip": nil}}
+	want := map[string]NullColumns{
+		"users":    {"phone": nil, "email": &literal},
+		"orders":   {"notes": nil},
+		"sessions": {"ip": nil},
+	}
+	if got := mergeNullColumns(base, overlay); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeNullColumns() = %v, want %v", got, want)
+	}
+}
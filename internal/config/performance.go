@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/helgesverre/dbdump/internal/tuning"
+	"github.com/helgesverre/dbdump/internal/units"
+)
+
+// PerformanceConfig sets the buffers and worker counts of dumps; unset
+// fields keep the defaults, or what --auto-tune picks
+type PerformanceConfig struct {
+	// WriterBuffer is the buffer in front of the output file, e.g. 1MiB
+	WriterBuffer string `yaml:"writer_buffer"`
+
+	// CompressionLevel is the gzip level, 1 (fastest) to 9 (smallest)
+	CompressionLevel int `yaml:"compression_level"`
+
+	// CompressionWorkers compress the output on this many goroutines
+	CompressionWorkers int `yaml:"compression_workers"`
+
+	// DumpParallelism is how many jobs dbdump run dumps at once unless
+	// --parallel-jobs is given
+	DumpParallelism int `yaml:"dump_parallelism"`
+
+	// NetBuffer is mysqldump's --net-buffer-length, e.g. 4MiB
+	NetBuffer string `yaml:"net_buffer"`
+}
+
+// Settings converts the section, returning what is wrong with it
+func (p PerformanceConfig) Settings() (tuning.Settings, []string) {
+	settings := tuning.Settings{
+		CompressionLevel:   p.CompressionLevel,
+		CompressionWorkers: p.CompressionWorkers,
+		DumpParallelism:    p.DumpParallelism,
+	}
+	var problems []string
+	for _, size := range []struct {
+		name  string
+		text  string
+		bytes *int64
+	}{
+		{"writer_buffer", p.WriterBuffer, &settings.WriterBuffer},
+		{"net_buffer", p.NetBuffer, &settings.NetBuffer},
+	} {
+		if size.text == "" {
+			continue
+		}
+		bytes, err := units.ParseBytes(size.text)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("performance.%s: %v", size.name, err))
+			continue
+		}
+		*size.bytes = bytes
+	}
+	return settings, append(problems, settings.Validate()...)
+}
+
+// merge returns p with the fields set in overlay
+func (p PerformanceConfig) merge(overlay PerformanceConfig) PerformanceConfig {
+	if overlay.WriterBuffer != "" {
+		p.WriterBuffer = overlay.WriterBuffer
+	}
+	if overlay.CompressionLevel != 0 {
+		p.CompressionLevel = overlay.CompressionLevel
+	}
+	if overlay.CompressionWorkers != 0 {
+		p.CompressionWorkers = overlay.CompressionWorkers
+	}
+	if overlay.DumpParallelism != 0 {
+		p.DumpParallelism = overlay.DumpParallelism
+	}
+	if overlay.NetBuffer != "" {
+		p.NetBuffer = overlay.NetBuffer
+	}
+	return p
+}
@@ -4,18 +4,55 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+	"github.com/helgesverre/dbdump/internal/redact"
 	"gopkg.in/yaml.v3"
 )
 
 // ConnectionProfile represents a saved database connection
 type ConnectionProfile struct {
-	Name     string `yaml:"name"`
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password,omitempty"`
-	Database string `yaml:"database,omitempty"`
+	Name     string   `yaml:"name"`
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	User     string   `yaml:"user"`
+	Password string   `yaml:"password,omitempty"`
+	Database string   `yaml:"database,omitempty"`
+	Tags     []string `yaml:"tags,omitempty"`
+
+	// Auth selects the authentication method: empty for a password, or
+	// "aws-iam" for RDS IAM auth tokens (with Region, optional)
+	Auth   string `yaml:"auth,omitempty"`
+	Region string `yaml:"region,omitempty"`
+
+	// Proxy marks a connection through a local proxy on a loopback address:
+	// "cloudsql" for the Cloud SQL Auth Proxy or "generic", with Instance
+	// naming the server behind it (e.g. project:region:instance)
+	Proxy    string `yaml:"proxy,omitempty"`
+	Instance string `yaml:"instance,omitempty"`
+}
+
+// String describes the profile without its password
+func (p ConnectionProfile) String() string {
+	return fmt.Sprintf("%s (%s@%s:%d/%s)", p.Name, p.User, p.Host, p.Port, p.Database)
+}
+
+// GoString masks the password when the profile is printed with %#v
+func (p ConnectionProfile) GoString() string {
+	return fmt.Sprintf("config.ConnectionProfile{Name:%q, Host:%q, Port:%d, User:%q, Password:%q, Database:%q, Tags:%q, Auth:%q, Region:%q, Proxy:%q, Instance:%q}",
+		p.Name, p.Host, p.Port, p.User, redact.Value(p.Password), p.Database, p.Tags, p.Auth, p.Region, p.Proxy, p.Instance)
+}
+
+// HasTag reports whether the profile has a tag (case-insensitive)
+func (p *ConnectionProfile) HasTag(tag string) bool {
+	for _, t := range p.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
 }
 
 // ProfilesConfig represents the profiles configuration file
@@ -23,8 +60,8 @@ type ProfilesConfig struct {
 	Profiles []ConnectionProfile `yaml:"profiles"`
 }
 
-// GetProfilesPath returns the path to the profiles config file
-func GetProfilesPath() (string, error) {
+// GetConfigDir returns the dbdump config directory, creating it if needed
+func GetConfigDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
@@ -35,6 +72,16 @@ func GetProfilesPath() (string, error) {
 		return "", fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	return configDir, nil
+}
+
+// GetProfilesPath returns the path to the profiles config file
+func GetProfilesPath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+
 	return filepath.Join(configDir, "profiles.yaml"), nil
 }
 
@@ -57,13 +104,17 @@ func LoadProfiles() (*ProfilesConfig, error) {
 
 	var config ProfilesConfig
 	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse profiles: %w", err)
+		return nil, &dberrors.ErrConfigInvalid{
+			Source: path,
+			Err:    fmt.Errorf("failed to parse profiles: %w", err),
+		}
 	}
 
 	return &config, nil
 }
 
-// SaveProfiles saves connection profiles
+// SaveProfiles saves connection profiles, replacing the file atomically.
+// Use UpdateProfiles to change profiles that may be saved concurrently.
 func SaveProfiles(config *ProfilesConfig) error {
 	path, err := GetProfilesPath()
 	if err != nil {
@@ -75,13 +126,33 @@ func SaveProfiles(config *ProfilesConfig) error {
 		return fmt.Errorf("failed to marshal profiles: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0600); err != nil {
+	if err := fileutil.WriteFileAtomic(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write profiles: %w", err)
 	}
 
 	return nil
 }
 
+// UpdateProfiles loads the profiles, applies update and saves the result
+// while holding the profiles lock, so concurrent updates aren't lost
+func UpdateProfiles(update func(*ProfilesConfig) error) error {
+	path, err := GetProfilesPath()
+	if err != nil {
+		return err
+	}
+
+	return fileutil.WithLock(path, func() error {
+		config, err := LoadProfiles()
+		if err != nil {
+			return err
+		}
+		if err := update(config); err != nil {
+			return err
+		}
+		return SaveProfiles(config)
+	})
+}
+
 // GetProfile retrieves a profile by name
 func (pc *ProfilesConfig) GetProfile(name string) (*ConnectionProfile, error) {
 	for _, profile := range pc.Profiles {
@@ -92,6 +163,18 @@ func (pc *ProfilesConfig) GetProfile(name string) (*ConnectionProfile, error) {
 	return nil, fmt.Errorf("profile '%s' not found", name)
 }
 
+// FindByTarget returns the first profile for the given database on a server,
+// using match to compare servers, or nil if none matches
+func (pc *ProfilesConfig) FindByTarget(database string, match func(profile *ConnectionProfile) bool) *ConnectionProfile {
+	for i := range pc.Profiles {
+		profile := &pc.Profiles[i]
+		if profile.Database == database && match(profile) {
+			return profile
+		}
+	}
+	return nil
+}
+
 // AddProfile adds or updates a profile
 func (pc *ProfilesConfig) AddProfile(profile ConnectionProfile) {
 	// Check if profile already exists and update it
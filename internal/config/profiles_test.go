@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentStateWrites adds profiles and saves selections from many
+// goroutines against one config dir: every update must survive and the
+// files must parse afterwards
+func TestConcurrentStateWrites(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const writers, rounds = 8, 10
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				name := fmt.Sprintf("w%d-%d", w, r)
+				err := UpdateProfiles(func(profiles *ProfilesConfig) error {
+					profiles.AddProfile(ConnectionProfile{Name: name, Host: "db", Port: 3306, User: "app"})
+					return nil
+				})
+				if err != nil {
+					t.Errorf("UpdateProfiles: %v", err)
+					return
+				}
+				err = SaveSelection(SavedSelection{Host: "db", Port: 3306, Database: name, Excluded: []string{"logs"}, SavedAt: time.Now()})
+				if err != nil {
+					t.Errorf("SaveSelection: %v", err)
+					return
+				}
+				// Readers see a whole file at any time
+				if _, err := LoadProfiles(); err != nil {
+					t.Errorf("LoadProfiles during writes: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	profiles, err := LoadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles.Profiles) != writers*rounds {
+		t.Errorf("%d profiles saved, want %d", len(profiles.Profiles), writers*rounds)
+	}
+	for w := 0; w < writers; w++ {
+		for r := 0; r < rounds; r++ {
+			name := fmt.Sprintf("w%d-%d", w, r)
+			if _, err := profiles.GetProfile(name); err != nil {
+				t.Errorf("profile %s lost", name)
+			}
+			if saved, err := LoadSelection("db", 3306, name); err != nil || saved == nil {
+				t.Errorf("selection of %s lost: %v", name, err)
+			}
+		}
+	}
+}
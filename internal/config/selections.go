@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/fileutil"
+)
+
+// SavedSelection is the set of tables whose data was excluded the last time
+// the table selector was confirmed for a database
+type SavedSelection struct {
+	Host     string    `json:"host"`
+	Port     int       `json:"port"`
+	Database string    `json:"database"`
+	Excluded []string  `json:"excluded"`
+	SavedAt  time.Time `json:"saved_at"`
+}
+
+// selectionsFile is the content of the selections cache
+type selectionsFile struct {
+	Selections []SavedSelection `json:"selections"`
+}
+
+// GetSelectionsPath returns the path to the saved selections file
+func GetSelectionsPath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, "selections.json"), nil
+}
+
+// loadSelections reads the selections cache, returning an empty one if it doesn't exist
+func loadSelections(path string) (*selectionsFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &selectionsFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved selections: %w", err)
+	}
+
+	var file selectionsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse saved selections %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// LoadSelection returns the saved selection for a database, or nil if none was saved
+func LoadSelection(host string, port int, database string) (*SavedSelection, error) {
+	path, err := GetSelectionsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := loadSelections(path)
+	if err != nil {
+		return nil, err
+	}
+	for i := range file.Selections {
+		saved := &file.Selections[i]
+		if saved.Host == host && saved.Port == port && saved.Database == database {
+			return saved, nil
+		}
+	}
+	return nil, nil
+}
+
+// SaveSelection stores a selection, replacing any earlier one for the same database
+func SaveSelection(selection SavedSelection) error {
+	path, err := GetSelectionsPath()
+	if err != nil {
+		return err
+	}
+
+	return fileutil.WithLock(path, func() error {
+		file, err := loadSelections(path)
+		if err != nil {
+			return err
+		}
+
+		replaced := false
+		for i, saved := range file.Selections {
+			if saved.Host == selection.Host && saved.Port == selection.Port && saved.Database == selection.Database {
+				file.Selections[i] = selection
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			file.Selections = append(file.Selections, selection)
+		}
+
+		data, err := json.MarshalIndent(file, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal saved selections: %w", err)
+		}
+		if err := fileutil.WriteFileAtomic(path, append(data, '\n'), 0600); err != nil {
+			return fmt.Errorf("failed to write saved selections: %w", err)
+		}
+		return nil
+	})
+}
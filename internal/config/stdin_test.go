@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+// pipeStdin makes content the process's stdin and forgets any config read
+// from it, until the test ends
+func pipeStdin(t *testing.T, content string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	saved := os.Stdin
+	os.Stdin = r
+	stdinOnce, stdinData, stdinErr = sync.Once{}, nil, nil
+	t.Cleanup(func() {
+		os.Stdin = saved
+		_ = r.Close()
+		stdinOnce, stdinData, stdinErr = sync.Once{}, nil, nil
+	})
+}
+
+func TestLoadConfigStdin(t *testing.T) {
+	const yaml = "exclude:\n  exact: [sessions]\n  patterns: [\"*_log\"]\n"
+	pipeStdin(t, yaml)
+
+	// Stdin is read once; later loads see the same config
+	for range 2 {
+		cfg, err := LoadConfig(StdinPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := ExcludeConfig{Exact: []string{"sessions"}, Patterns: []string{"*_log"}}
+		if !reflect.DeepEqual(cfg.Exclude, want) {
+			t.Errorf("Exclude = %+v, want %+v", cfg.Exclude, want)
+		}
+	}
+	if got := string(StdinConfig()); got != yaml {
+		t.Errorf("StdinConfig() = %q, want %q", got, yaml)
+	}
+}
+
+func TestLoadConfigStdinInvalid(t *testing.T) {
+	pipeStdin(t, "exclude: [unterminated\n")
+
+	_, err := LoadConfig(StdinPath)
+	var invalid *dberrors.ErrConfigInvalid
+	if !errors.As(err, &invalid) || invalid.Source != "stdin" {
+		t.Fatalf("LoadConfig(%q) error = %v, want one naming stdin", StdinPath, err)
+	}
+}
+
+func TestSourceName(t *testing.T) {
+	for path, want := range map[string]string{StdinPath: "stdin", ".dbdump.yaml": ".dbdump.yaml", "./-": "./-"} {
+		if got := SourceName(path); got != want {
+			t.Errorf("SourceName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
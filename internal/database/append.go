@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// AppendRange limits an append-only table's rows to those whose watermark
+// column is past From (unless From is empty, for the base dump) and at most
+// Through
+type AppendRange struct {
+	Column  string
+	From    string
+	Through string
+}
+
+// where returns the condition selecting the range
+func (r AppendRange) where() string {
+	if r.Through == "" {
+		// The table was empty when the watermark was read
+		return "FALSE"
+	}
+	column := sqlident.Quote(r.Column)
+	condition := fmt.Sprintf("%s <= %s", column, watermarkLiteral(r.Through))
+	if r.From != "" {
+		condition = fmt.Sprintf("%s > %s AND %s", column, watermarkLiteral(r.From), condition)
+	}
+	return condition
+}
+
+// watermarkLiteral returns a watermark as an SQL literal: integers bare, as
+// comparing an integer column with a string goes through floating point
+// and loses precision past 2^53, and dates quoted
+func watermarkLiteral(value string) string {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return value
+	}
+	if _, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return value
+	}
+	return quoteString(value)
+}
+
+// String describes the range for the comment above the rows
+func (r AppendRange) String() string {
+	if r.From == "" {
+		return fmt.Sprintf("%s up to %s", r.Column, r.Through)
+	}
+	return fmt.Sprintf("%s in (%s, %s]", r.Column, r.From, r.Through)
+}
+
+// GetWatermark returns the highest value of a table's watermark column, as
+// text, and the number of rows up to it; max is empty for an empty table
+func (i *Inspector) GetWatermark(ctx context.Context, table, column string) (max string, rows int64, err error) {
+	var value sql.NullString
+	query := fmt.Sprintf("SELECT CAST(MAX(%s) AS CHAR), COUNT(*) FROM %s", sqlident.Quote(column), sqlident.Quote(table))
+	err = i.inUTC(ctx, func(conn *sql.Conn) error {
+		return conn.QueryRowContext(ctx, query).Scan(&value, &rows)
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read the watermark of %s: %w", table, err)
+	}
+	return value.String, rows, nil
+}
+
+// CountThrough counts a table's rows whose watermark column is at most
+// through; none when through is empty (an empty table's watermark)
+func (i *Inspector) CountThrough(ctx context.Context, table, column, through string) (int64, error) {
+	if through == "" {
+		through = "NULL"
+	} else {
+		through = watermarkLiteral(through)
+	}
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s <= %s", sqlident.Quote(table), sqlident.Quote(column), through)
+	var rows int64
+	err := i.inUTC(ctx, func(conn *sql.Conn) error {
+		return conn.QueryRowContext(ctx, query).Scan(&rows)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count the rows of %s: %w", table, err)
+	}
+	return rows, nil
+}
+
+// inUTC runs fn on a connection whose session time zone is UTC, the zone
+// the masking path reads TIMESTAMP values in, so watermarks read here
+// compare the same way in the dump's queries
+func (i *Inspector) inUTC(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := i.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if _, err := conn.ExecContext(ctx, "SET time_zone = '+00:00'"); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// IsLeadingIndexColumn reports whether column is the first column of an
+// index of table, so ranges on it don't scan the table
+func (i *Inspector) IsLeadingIndexColumn(ctx context.Context, table, column string) (bool, error) {
+	var count int
+	err := i.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM information_schema.statistics
+		WHERE table_schema = DATABASE()
+		AND table_name = ?
+		AND column_name = ?
+		AND seq_in_index = 1
+	`, table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the indexes of %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// WatermarkProblem returns why a column can't be the watermark of an
+// append-only table, or "": it must exist, be NOT NULL (rows without a
+// value would never be dumped) and hold integers or dates, which compare
+// as numbers rather than text
+func WatermarkProblem(columns []ColumnInfo, column string) string {
+	for _, col := range columns {
+		if col.Name != column {
+			continue
+		}
+		switch {
+		case col.Nullable:
+			return fmt.Sprintf("watermark column %s is nullable", column)
+		case !integerType(col.DataType) && !temporalType(col.DataType):
+			return fmt.Sprintf("watermark column %s is %s; it must be an integer or date column", column, col.Type)
+		}
+		return ""
+	}
+	return fmt.Sprintf("no such column %s", column)
+}
+
+// integerType reports whether a column type holds integers
+func integerType(dataType string) bool {
+	switch dataType {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint":
+		return true
+	}
+	return false
+}
+
+// TableFingerprint hashes a CREATE TABLE statement as SHOW CREATE TABLE
+// returns it, giving the same fingerprint as the statement in a dump
+func TableFingerprint(createTable string) string {
+	fingerprinter := NewSchemaFingerprinter()
+	_, _ = fingerprinter.Write([]byte(createTable + ";\n"))
+	for _, fingerprint := range fingerprinter.Fingerprints() {
+		return fingerprint
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/budget"
+)
+
+// budgetedData dumps the data of the tables in BudgetOrder one at a time,
+// leaving out those the time budget has no room for. A table that was
+// started always finishes, so the dump stays complete for the tables it
+// has; with mysqldump each table is read in its own snapshot.
+func (d *Dumper) budgetedData(writer io.Writer) error {
+	counter := &countingWriter{writer: writer}
+	dumpTable, done, err := d.tableDumper(counter)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	scheduler := budget.NewScheduler(d.options.TimeBudget, d.started)
+	d.overBudget = nil
+	for _, table := range d.options.BudgetOrder {
+		if !scheduler.Start(d.options.TableEstimates[table]) {
+			d.overBudget = append(d.overBudget, table)
+			continue
+		}
+		started, written := time.Now(), counter.written
+		if err := dumpTable(table); err != nil {
+			return err
+		}
+		scheduler.Finish(counter.written-written, time.Since(started))
+	}
+	return nil
+}
+
+// tableDumper returns a function writing one table's data to writer, and
+// one releasing what it holds. Natively all tables are read in the same
+// snapshot, each in its own session block.
+func (d *Dumper) tableDumper(writer io.Writer) (dumpTable func(table string) error, done func(), err error) {
+	if !d.options.Native {
+		dumpTable = func(table string) error {
+			return d.mysqldump("data", d.tableDataArgs(table))(writer)
+		}
+		return dumpTable, func() {}, nil
+	}
+
+	ctx := d.context()
+	session, err := d.openNative(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	dumpTable = func(table string) error {
+		out := bufio.NewWriterSize(writer, 256*1024)
+		if _, err := io.WriteString(out, sessionHeader); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		if err := d.nativeTable(ctx, session, out, table, nil); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(out, sessionFooter); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		if err := out.Flush(); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		return nil
+	}
+	return dumpTable, session.close, nil
+}
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// CharacterSet describes a character set supported by the server
+type CharacterSet struct {
+	Name             string
+	DefaultCollation string
+	MaxLen           int64 // maximum bytes per character
+	Collations       map[string]bool
+}
+
+// ColumnCharset describes a character column of a base table
+type ColumnCharset struct {
+	Table    string
+	Column   string
+	DataType string
+	CharSet  string
+	MaxChars int64 // declared length in characters (0 for TEXT types)
+	MaxLen   int64 // bytes per character in the current character set
+}
+
+// IndexColumn is one column part of an index
+type IndexColumn struct {
+	Table   string
+	Index   string
+	Column  string // empty for a functional key part
+	SubPart int64  // prefix length in characters, 0 for the full column
+
+	// Expression is the expression of a functional key part (MySQL 8.0.13+)
+	Expression string
+}
+
+// GetCharacterSet returns a character set and its collations
+func (i *Inspector) GetCharacterSet(name string) (*CharacterSet, error) {
+	cs := &CharacterSet{Name: name, Collations: make(map[string]bool)}
+
+	err := i.db.QueryRowContext(i.context(), `
+		SELECT default_collate_name, maxlen
+		FROM information_schema.character_sets
+		WHERE character_set_name = ?
+	`, name).Scan(&cs.DefaultCollation, &cs.MaxLen)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("character set %q is not supported by the server", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get character set %s: %w", name, err)
+	}
+
+	rows, err := i.db.QueryContext(i.context(), `
+		SELECT collation_name
+		FROM information_schema.collations
+		WHERE character_set_name = ?
+	`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collations for %s: %w", name, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var collation string
+		if err := rows.Scan(&collation); err != nil {
+			return nil, fmt.Errorf("failed to scan collation: %w", err)
+		}
+		cs.Collations[collation] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating collations: %w", err)
+	}
+
+	return cs, nil
+}
+
+// GetCharsetColumns returns all character columns of base tables
+func (i *Inspector) GetCharsetColumns() ([]ColumnCharset, error) {
+	query := `
+		SELECT
+			c.table_name,
+			c.column_name,
+			c.data_type,
+			c.character_set_name,
+			IFNULL(c.character_maximum_length, 0),
+			cs.maxlen
+		FROM information_schema.columns c
+		JOIN information_schema.tables t
+			ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		JOIN information_schema.character_sets cs
+			ON cs.character_set_name = c.character_set_name
+		WHERE c.table_schema = DATABASE()
+		AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position
+	`
+
+	rows, err := i.db.QueryContext(i.context(), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column character sets: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var columns []ColumnCharset
+	for rows.Next() {
+		var col ColumnCharset
+		if err := rows.Scan(&col.Table, &col.Column, &col.DataType, &col.CharSet, &col.MaxChars, &col.MaxLen); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		columns = append(columns, col)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating columns: %w", err)
+	}
+
+	return columns, nil
+}
+
+// GetIndexColumns returns the key parts of all non-fulltext indexes, with
+// the expression of functional key parts on servers that support them
+func (i *Inspector) GetIndexColumns() ([]IndexColumn, error) {
+	rows, err := i.db.QueryContext(i.context(), `
+		SELECT table_name, index_name, IFNULL(column_name, ''), IFNULL(sub_part, 0), IFNULL(expression, '')
+		FROM information_schema.statistics
+		WHERE table_schema = DATABASE()
+		AND index_type NOT IN ('FULLTEXT', 'SPATIAL')
+		ORDER BY table_name, index_name, seq_in_index
+	`)
+	if unknownColumn(err) {
+		// Before MySQL 8.0.13 every key part is a column
+		rows, err = i.db.QueryContext(i.context(), `
+			SELECT table_name, index_name, column_name, IFNULL(sub_part, 0), ''
+			FROM information_schema.statistics
+			WHERE table_schema = DATABASE()
+			AND index_type NOT IN ('FULLTEXT', 'SPATIAL')
+			AND column_name IS NOT NULL
+			ORDER BY table_name, index_name, seq_in_index
+		`)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index columns: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var parts []IndexColumn
+	for rows.Next() {
+		var part IndexColumn
+		if err := rows.Scan(&part.Table, &part.Index, &part.Column, &part.SubPart, &part.Expression); err != nil {
+			return nil, fmt.Errorf("failed to scan index column: %w", err)
+		}
+		parts = append(parts, part)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating index columns: %w", err)
+	}
+
+	return parts, nil
+}
+
+// GetRowFormats returns the row format of each base table (e.g. "Dynamic", "Compact")
+func (i *Inspector) GetRowFormats() (map[string]string, error) {
+	rows, err := i.db.QueryContext(i.context(), `
+		SELECT table_name, IFNULL(row_format, '')
+		FROM information_schema.tables
+		WHERE table_schema = DATABASE()
+		AND table_type = 'BASE TABLE'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get row formats: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	formats := make(map[string]string)
+	for rows.Next() {
+		var table, format string
+		if err := rows.Scan(&table, &format); err != nil {
+			return nil, fmt.Errorf("failed to scan row format: %w", err)
+		}
+		formats[table] = format
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating row formats: %w", err)
+	}
+
+	return formats, nil
+}
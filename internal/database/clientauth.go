@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// ClientEnv returns the environment for a MySQL client tool (mysqldump,
+// mysql) with the password passed as MYSQL_PWD. With a TokenSource a token
+// is minted now, so call it right before starting the tool. Returns nil
+// (inherit the environment) when there is no password.
+func (c *Connection) ClientEnv(ctx context.Context) ([]string, error) {
+	secret := c.Password
+	if c.TokenSource != nil {
+		token, err := c.TokenSource(ctx)
+		if err != nil {
+			return nil, c.connectionError(fmt.Errorf("failed to generate auth token: %w", err))
+		}
+		secret = token
+	}
+
+	if secret == "" {
+		return nil, nil
+	}
+	return append(os.Environ(), "MYSQL_PWD="+secret), nil
+}
+
+// ClientArgs returns the TLS and authentication flags MySQL client tools
+// need for this connection
+func (c *Connection) ClientArgs() []string {
+	var args []string
+	if c.CAFile != "" {
+		args = append(args, "--ssl-mode=VERIFY_IDENTITY", "--ssl-ca="+c.CAFile)
+	}
+	if c.TokenSource != nil {
+		// Tokens are sent with mysql_clear_password, which is safe over TLS
+		args = append(args, "--enable-cleartext-plugin")
+	}
+	return args
+}
+
+// tlsConfig returns a TLS configuration that verifies the server against CAFile
+func (c *Connection) tlsConfig() (*tls.Config, error) {
+	pem, err := os.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", c.CAFile)
+	}
+
+	return &tls.Config{
+		RootCAs:    roots,
+		ServerName: c.Host,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
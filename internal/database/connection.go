@@ -1,10 +1,19 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"strings"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/redact"
+	"github.com/helgesverre/dbdump/internal/sqlident"
 )
 
 // Connection represents a database connection configuration
@@ -14,6 +23,52 @@ type Connection struct {
 	User     string
 	Password string
 	Database string
+
+	// ReadOnly puts every session in read-only transaction mode, so the
+	// server rejects any write made through this connection
+	ReadOnly bool
+
+	// TokenSource mints a short-lived password (such as an RDS IAM auth
+	// token) for every new session and client tool invocation; when set,
+	// Password is ignored and cleartext authentication is allowed
+	TokenSource func(ctx context.Context) (string, error)
+
+	// CAFile requires TLS, verifying the server against this PEM bundle
+	CAFile string
+
+	// Proxy is the kind of local proxy (ProxyCloudSQL, ProxyGeneric) the
+	// connection goes through, and Instance the server behind it, if known
+	Proxy    string
+	Instance string
+}
+
+// String describes the connection as user@host:port/database; the password
+// never appears, so a Connection is safe to print with %v and %+v
+func (c Connection) String() string {
+	return fmt.Sprintf("%s@%s:%d/%s", c.User, c.Host, c.Port, c.Database)
+}
+
+// GoString masks the password when the connection is printed with %#v
+func (c Connection) GoString() string {
+	return fmt.Sprintf("database.Connection{Host:%q, Port:%d, User:%q, Password:%q, Database:%q, ReadOnly:%t, CAFile:%q, Proxy:%q, Instance:%q}",
+		c.Host, c.Port, c.User, redact.Value(c.Password), c.Database, c.ReadOnly, c.CAFile, c.Proxy, c.Instance)
+}
+
+// LogValue masks the password when the connection is logged with slog
+func (c Connection) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("host", c.Host),
+		slog.Int("port", c.Port),
+		slog.String("user", c.User),
+		slog.String("password", redact.Value(c.Password)),
+		slog.String("database", c.Database),
+	)
+}
+
+// connectionError wraps err as a connection failure with the password
+// scrubbed from its message, as the driver sometimes echoes the DSN
+func (c *Connection) connectionError(err error) error {
+	return connectionError(redact.Error(err, c.Password))
 }
 
 // DSN returns the data source name for MySQL connection
@@ -39,20 +94,124 @@ func (c *Connection) DSN() string {
 
 // Connect establishes a connection to the database
 func (c *Connection) Connect() (*sql.DB, error) {
-	db, err := sql.Open("mysql", c.DSN())
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext establishes a connection, giving up when ctx is cancelled
+func (c *Connection) ConnectContext(ctx context.Context) (*sql.DB, error) {
+	cfg, err := mysql.ParseDSN(c.DSN())
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, c.connectionError(fmt.Errorf("failed to open database: %w", err))
+	}
+
+	if c.CAFile != "" {
+		cfg.TLS, err = c.tlsConfig()
+		if err != nil {
+			return nil, c.connectionError(err)
+		}
+	}
+	if c.TokenSource != nil {
+		cfg.AllowCleartextPasswords = true
+		err := cfg.Apply(mysql.BeforeConnect(func(ctx context.Context, cfg *mysql.Config) error {
+			token, err := c.TokenSource(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to generate auth token: %w", err)
+			}
+			cfg.Passwd = token
+			return nil
+		}))
+		if err != nil {
+			return nil, c.connectionError(fmt.Errorf("failed to open database: %w", err))
+		}
+	}
+
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, c.connectionError(fmt.Errorf("failed to open database: %w", err))
+	}
+
+	var db *sql.DB
+	if c.ReadOnly {
+		db = sql.OpenDB(&readOnlyConnector{Connector: connector})
+	} else {
+		db = sql.OpenDB(connector)
 	}
 
 	// Verify the connection
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		_ = db.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, c.connectionError(fmt.Errorf("failed to ping database: %w", err))
 	}
 
 	return db, nil
 }
 
+// readOnlyConnector sets every new session to read-only transaction mode
+// before it is handed out by the pool
+type readOnlyConnector struct {
+	driver.Connector
+}
+
+// Connect implements driver.Connector
+func (c *readOnlyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		_ = conn.Close()
+		return nil, fmt.Errorf("driver connection does not support session setup")
+	}
+	if _, err := execer.ExecContext(ctx, "SET SESSION TRANSACTION READ ONLY", nil); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to make session read-only: %w", err)
+	}
+
+	return conn, nil
+}
+
+// CheckReadOnly verifies that a read-only session really rejects writes by
+// attempting a no-op DELETE on table inside a transaction that is always
+// rolled back. Returns nil if the write was rejected.
+func CheckReadOnly(db *sql.DB, table string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start test transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	quoted := sqlident.Quote(table)
+	_, err = tx.Exec("DELETE FROM " + quoted + " WHERE 1 = 0")
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errReadOnlyTransaction {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("test write failed for another reason: %w", err)
+	}
+	return fmt.Errorf("a write to %s was accepted; the session is not read-only", table)
+}
+
+// errReadOnlyTransaction is ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION
+const errReadOnlyTransaction = 1792
+
+// connectionError wraps a connection error, extracting the MySQL error number when available
+func connectionError(err error) error {
+	connErr := &dberrors.ErrConnectionFailed{Err: err}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		connErr.Code = mysqlErr.Number
+	}
+
+	return connErr
+}
+
 // TestConnection tests if the connection is valid
 func (c *Connection) TestConnection() error {
 	db, err := c.Connect()
@@ -64,3 +223,30 @@ func (c *Connection) TestConnection() error {
 	}()
 	return nil
 }
+
+// NormalizeHost returns a canonical form of a host name for comparisons.
+// Loopback aliases (localhost, 127.0.0.0/8, ::1, empty) all normalize to
+// "localhost"; other names are lowercased without brackets or a trailing dot.
+func NormalizeHost(host string) string {
+	h := strings.ToLower(strings.TrimSpace(host))
+	h = strings.TrimSuffix(strings.TrimPrefix(h, "["), "]")
+	h = strings.TrimSuffix(h, ".")
+
+	if h == "" || h == "localhost" || h == "localhost.localdomain" {
+		return "localhost"
+	}
+	if ip := net.ParseIP(h); ip != nil {
+		if ip.IsLoopback() {
+			return "localhost"
+		}
+		return ip.String()
+	}
+
+	return h
+}
+
+// SameServer reports whether two host/port pairs refer to the same server,
+// tolerating loopback aliases such as localhost vs 127.0.0.1
+func SameServer(hostA string, portA int, hostB string, portB int) bool {
+	return portA == portB && NormalizeHost(hostA) == NormalizeHost(hostB)
+}
@@ -0,0 +1,67 @@
+package database
+
+import "testing"
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"localhost", "localhost"},
+		{"LocalHost", "localhost"},
+		{"localhost.", "localhost"},
+		{"localhost.localdomain", "localhost"},
+		{"", "localhost"},
+		{"  ", "localhost"},
+		{"127.0.0.1", "localhost"},
+		{"127.0.1.1", "localhost"},
+		{"::1", "localhost"},
+		{"[::1]", "localhost"},
+		{"0:0:0:0:0:0:0:1", "localhost"},
+		{"::ffff:127.0.0.1", "localhost"},
+		{"db.example.com.", "db.example.com"},
+		{"DB.Example.COM", "db.example.com"},
+		{" db.example.com ", "db.example.com"},
+		{"10.0.0.5", "10.0.0.5"},
+		{"[2001:DB8::1]", "2001:db8::1"},
+		{"2001:db8:0:0:0:0:0:1", "2001:db8::1"},
+		{"::ffff:10.0.0.5", "10.0.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := NormalizeHost(tt.host); got != tt.want {
+				t.Errorf("NormalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameServer(t *testing.T) {
+	tests := []struct {
+		name         string
+		hostA, hostB string
+		portA, portB int
+		want         bool
+	}{
+		{name: "identical", hostA: "db", portA: 3306, hostB: "db", portB: 3306, want: true},
+		{name: "localhost and 127.0.0.1", hostA: "localhost", portA: 3306, hostB: "127.0.0.1", portB: 3306, want: true},
+		{name: "localhost and ::1", hostA: "localhost", portA: 3306, hostB: "::1", portB: 3306, want: true},
+		{name: "127.0.0.1 and [::1]", hostA: "127.0.0.1", portA: 3306, hostB: "[::1]", portB: 3306, want: true},
+		{name: "trailing dot", hostA: "db.example.com.", portA: 3306, hostB: "db.example.com", portB: 3306, want: true},
+		{name: "mixed case", hostA: "DB.example.com", portA: 3306, hostB: "db.EXAMPLE.com", portB: 3306, want: true},
+		{name: "port mismatch", hostA: "localhost", portA: 3306, hostB: "127.0.0.1", portB: 3307},
+		{name: "port mismatch, same name", hostA: "db", portA: 3306, hostB: "db", portB: 33060},
+		{name: "different hosts", hostA: "db1", portA: 3306, hostB: "db2", portB: 3306},
+		{name: "loopback and a remote host", hostA: "localhost", portA: 3306, hostB: "10.0.0.5", portB: 3306},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SameServer(tt.hostA, tt.portA, tt.hostB, tt.portB); got != tt.want {
+				t.Errorf("SameServer(%q, %d, %q, %d) = %v, want %v", tt.hostA, tt.portA, tt.hostB, tt.portB, got, tt.want)
+			}
+			if got := SameServer(tt.hostB, tt.portB, tt.hostA, tt.portA); got != tt.want {
+				t.Errorf("SameServer isn't symmetric for %s", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// TableCopy is a table whose rows are identical to those of the same table
+// in another database dumped earlier in the run. Instead of its rows, the
+// dump copies them from that database, which must be restored first.
+type TableCopy struct {
+	Table string
+	From  string // database
+
+	// Columns are the columns copied: all but generated ones, which the
+	// server computes
+	Columns []string
+}
+
+// NewTableCopy creates the copy of a table from another database
+func NewTableCopy(table, from string, columns []ColumnInfo) TableCopy {
+	copied := TableCopy{Table: table, From: from}
+	for _, col := range columns {
+		if col.Generated == "" {
+			copied.Columns = append(copied.Columns, col.Name)
+		}
+	}
+	return copied
+}
+
+// Statement returns the INSERT … SELECT copying the rows
+func (c TableCopy) Statement() string {
+	columns := make([]string, len(c.Columns))
+	for i, name := range c.Columns {
+		columns[i] = sqlident.Quote(name)
+	}
+	list := strings.Join(columns, ", ")
+	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s.%s;\n",
+		sqlident.Quote(c.Table), list, list, sqlident.Quote(c.From), sqlident.Quote(c.Table))
+}
+
+// dumpCopies writes the statements of the copied tables
+func (d *Dumper) dumpCopies(writer io.Writer) error {
+	var out strings.Builder
+	out.WriteString(sessionHeader)
+	for _, table := range d.options.Copies {
+		fmt.Fprintf(&out, "\n-- Data of %s: identical to %s.%s, which must be restored first\n", table.Table, table.From, table.Table)
+		out.WriteString(table.Statement())
+	}
+	out.WriteString(sessionFooter)
+	if _, err := io.WriteString(writer, out.String()); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// copied reports whether a table's data is copied from another database
+func (d *Dumper) copied(table string) bool {
+	for _, c := range d.options.Copies {
+		if c.Table == table {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+// DataSample is the start of a table's data as the dump writes it
+type DataSample struct {
+	Data []byte
+
+	// Duration is the time from the first byte to the last, leaving out
+	// mysqldump's startup
+	Duration time.Duration
+}
+
+// Rate returns the bytes per second the sample was written at, or 0 when
+// it came too quickly to time
+func (s DataSample) Rate() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(len(s.Data)) / s.Duration.Seconds()
+}
+
+// errSampleFull stops a data sample that has enough
+var errSampleFull = errors.New("data sample full")
+
+// sampleWriter keeps the first limit bytes written to it, and stops the
+// dump writing them once it has them
+type sampleWriter struct {
+	limit       int64
+	data        []byte
+	first, last time.Time
+	stop        context.CancelFunc
+}
+
+func (w *sampleWriter) Write(p []byte) (int, error) {
+	if w.first.IsZero() {
+		w.first = time.Now()
+	}
+	room := w.limit - int64(len(w.data))
+	if int64(len(p)) < room {
+		w.data = append(w.data, p...)
+		w.last = time.Now()
+		return len(p), nil
+	}
+	w.data = append(w.data, p[:room]...)
+	w.last = time.Now()
+	w.stop()
+	return int(room), errSampleFull
+}
+
+// SampleData dumps the data of a table until limit bytes are written or
+// within runs out, whichever comes first. Stopping early is the point, so
+// it only fails when nothing was written.
+func (d *Dumper) SampleData(table string, limit int64, within time.Duration) (DataSample, error) {
+	ctx, cancel := context.WithTimeout(d.context(), within)
+	defer cancel()
+
+	w := &sampleWriter{limit: limit, stop: cancel}
+	var err error
+	if d.options.Native {
+		err = d.nativeDataSample(ctx, table, w)
+	} else {
+		err = d.mysqldumpDataSample(ctx, table, w)
+	}
+	if parent := d.context().Err(); parent != nil {
+		return DataSample{}, fmt.Errorf("%w: data sample of %s: %w", dberrors.ErrDumpInterrupted, table, parent)
+	}
+	if err != nil && len(w.data) == 0 {
+		return DataSample{}, err
+	}
+	return DataSample{Data: w.data, Duration: w.last.Sub(w.first)}, nil
+}
+
+// mysqldumpDataSample writes the data of a table to w with mysqldump
+func (d *Dumper) mysqldumpDataSample(ctx context.Context, table string, w io.Writer) error {
+	args := d.buildMySQLDumpArgs()
+	args = append(args,
+		"--no-create-info",
+		"--skip-triggers",
+		"--skip-routines",
+		"--skip-events",
+		"--set-gtid-purged=OFF",
+		"--column-statistics=0",
+		d.options.Connection.Database,
+		table,
+	)
+
+	cmd := exec.CommandContext(ctx, "mysqldump", args...)
+	cmd.Stdout = w
+	stderr := &stderrTail{}
+	cmd.Stderr = stderr
+
+	env, err := d.options.Connection.ClientEnv(ctx)
+	if err != nil {
+		return err
+	}
+	cmd.Env = env
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("mysqldump data sample of %s stopped: %w", table, ctx.Err())
+		}
+		return classifyDumpError("data sample", err, stderr.buf)
+	}
+	return nil
+}
+
+// nativeDataSample writes the data of a table to w as native dumps do
+func (d *Dumper) nativeDataSample(ctx context.Context, table string, w io.Writer) error {
+	session, err := d.openNative(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+	return d.nativeTable(ctx, session, w, table, nil)
+}
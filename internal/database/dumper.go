@@ -3,32 +3,186 @@ package database
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
-	"os/signal"
-	"syscall"
+	"path/filepath"
 	"time"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+	"github.com/helgesverre/dbdump/internal/transform"
+	"github.com/helgesverre/dbdump/internal/tuning"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
 )
 
 // DumpOptions contains options for dumping the database
 type DumpOptions struct {
 	Connection    *Connection
 	ExcludeTables []string
+	SkipTables    []string // skipped entirely (structure and data)
 	OutputFile    string
-	ShowProgress  bool
 	DryRun        bool
+
+	// ShowProgress reports the data phase to OnProgress as mysqldump moves
+	// from table to table. TableEstimates holds the expected data size of
+	// each table whose data is dumped, for the table count and total.
+	ShowProgress   bool
+	TableEstimates map[string]int64
+	OnProgress     func(DumpProgress)
+
+	// OnPhase is called as each phase starts, with its name; OnTableDone
+	// when the data phase has moved past a table, with its timing
+	OnPhase     func(phase string)
+	OnTableDone func(TableTiming)
+
+	// MaxFileSize splits the output into numbered parts of at most this many
+	// bytes, switching only between statements (0 writes a single file)
+	MaxFileSize int64
+
+	// Compress gzips the output; split dumps compress each part on its own
+	// and MaxFileSize then limits the SQL per part before compression
+	Compress bool
+
+	// ServerMaxAllowedPacket is the server's max_allowed_packet; the client's
+	// is clamped to it (0 keeps 1G)
+	ServerMaxAllowedPacket int64
+
+	// Samples dumps the last rows of these data-excluded tables after the
+	// data phase; the tables must also be in ExcludeTables
+	Samples []TableSample
+
+	// Masked tables have their data read by dbdump itself with column masks
+	// applied, after the other phases, instead of by mysqldump
+	Masked []MaskedTable
+
+	// Copies are tables whose data is copied from another database's
+	// identical table instead of dumped (--dedupe-identical)
+	Copies []TableCopy
+
+	// MaxTableSize cuts each table's data off at a statement boundary once
+	// it would exceed this many bytes (0 for no limit)
+	MaxTableSize int64
+
+	// SampleStatements copies the first and last this many statements of
+	// each table's data, and any that are not valid UTF-8, into SampleFile
+	// for debugging, with string values cut after SampleValueLength
+	// characters; the dump itself is unaffected (0 disables sampling)
+	SampleStatements  int
+	SampleValueLength int
+	SampleFile        string
+
+	// DefaultCharacterSet is the connection character set for mysqldump; the
+	// server transcodes data to it
+	DefaultCharacterSet string
+
+	// StructureFilter, if set, wraps the output of the structure phase (e.g. to
+	// rewrite DDL); it is closed when the phase finishes
+	StructureFilter func(io.Writer) io.WriteCloser
+
+	// Header is written at the start of the output, before the structure;
+	// it must consist of SQL comment lines
+	Header string
+
+	// TableDefRetries restarts a phase that failed because a table was
+	// altered mid-dump (ER_TABLE_DEF_CHANGED) up to this many times; only
+	// single-file dumps can be restarted
+	TableDefRetries int
+
+	// ExtraArgs are appended to the mysqldump arguments of every phase
+	ExtraArgs []string
+
+	// Performance sets the buffer sizes and compression of the output, and
+	// mysqldump's net buffer; zero fields keep the defaults
+	Performance tuning.Settings
+
+	// SyncPolicy says when the output is synced to stable storage; the zero
+	// value syncs each file once it is complete
+	SyncPolicy dumpfile.SyncPolicy
+
+	// Native reads the database over the connection instead of running
+	// mysqldump; triggers, events and routines are left out (see native.go)
+	Native bool
+
+	// Increment writes only the rows of the Masked tables, each limited to
+	// its Range, to be restored on top of an earlier dump
+	Increment bool
+
+	// TimeBudget dumps the data one table at a time in BudgetOrder, which
+	// holds every table whose data mysqldump would dump, and starts no
+	// table expected to take the dump past this long since it started;
+	// those tables are left without data (0 for no budget)
+	TimeBudget  time.Duration
+	BudgetOrder []string
+
+	// Context, if set, stops the dump when it is done; the command passes
+	// one that is cancelled on Ctrl+C or SIGTERM
+	Context context.Context
+
+	// KeepPartial keeps the output of a dump that failed mid-stream, renamed
+	// to *.partial, instead of removing it
+	KeepPartial bool
+
+	// OnCheckpoint is called when single-file output reaches the end of a
+	// phase. Output that failed after a checkpoint is kept (at ResumePath,
+	// or the .partial file) so it can be continued from there.
+	OnCheckpoint func(Checkpoint)
+
+	// Resume continues the output kept at ResumeFile from its checkpoint,
+	// skipping the header and the phases before it
+	Resume     *Checkpoint
+	ResumeFile string
+
+	// BeforeRetry is called before such a restart with the affected table
+	// (empty if unknown); it returns the exclude and skip lists to use from
+	// then on, e.g. after refreshing the table list
+	BeforeRetry func(table string) (excludes, skips []string, err error)
 }
 
 // Dumper handles database dumping operations
 type Dumper struct {
-	options *DumpOptions
+	options     *DumpOptions
+	fingerprint *SchemaFingerprinter
+	timer       *TableTimer
+	limiter     *tableLimiter
+
+	structureDuration time.Duration
+	dataDuration      time.Duration
+
+	started    time.Time
+	overBudget []string
+
+	// completed is the last phase run to the end, last its checkpoint,
+	// and kept where the output of a failed dump was kept for resuming
+	completed string
+	last      *Checkpoint
+	kept      string
+
+	// resumeAfter is the phase a resumed dump continues after, until reached
+	resumeAfter string
 }
 
 // NewDumper creates a new Dumper
 func NewDumper(options *DumpOptions) *Dumper {
-	return &Dumper{options: options}
+	d := &Dumper{
+		options:     options,
+		fingerprint: NewSchemaFingerprinter(),
+		timer:       NewTableTimer(),
+	}
+	if options.Resume != nil {
+		d.fingerprint.tables = maps.Clone(options.Resume.Fingerprints)
+		if d.fingerprint.tables == nil {
+			d.fingerprint.tables = make(map[string]string)
+		}
+		d.resumeAfter = options.Resume.Phase
+	}
+	return d
 }
 
 // DumpResult contains the result of a dump operation
@@ -38,43 +192,105 @@ type DumpResult struct {
 	ExcludedTables  []string
 	FileSize        int64
 	FileSizeDisplay string
+
+	// UncompressedSize is the SQL written, before any compression
+	UncompressedSize int64
+
+	// SchemaFingerprints maps each table to a hash of its CREATE TABLE statement
+	SchemaFingerprints map[string]string
+
+	// Parts lists the output files of a split dump in order (nil if not split)
+	Parts []dumpfile.Part
+
+	// Phase durations, and per-table data timings in dump order (empty if the
+	// output could not be attributed to tables)
+	StructureDuration time.Duration
+	DataDuration      time.Duration
+	TableTimings      []TableTiming
+
+	// TableSizes lists the data written per table, largest first, and which
+	// tables were truncated (only with MaxTableSize)
+	TableSizes []TableSize
+
+	// LargestStatement is the size of the longest data statement written
+	LargestStatement int64
+
+	// OverBudget lists the tables left without data by TimeBudget, in the
+	// order they would have been dumped
+	OverBudget []string
 }
 
 // Dump performs the database dump
-func (d *Dumper) Dump() (*DumpResult, error) {
+func (d *Dumper) Dump() (result *DumpResult, err error) {
 	startTime := time.Now()
+	d.started = startTime
 
 	if d.options.DryRun {
 		return d.dryRun()
 	}
 
-	// Create output file with restrictive permissions (owner read/write only)
-	outFile, err := os.OpenFile(d.options.OutputFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
-	if err != nil {
+	// Catch options the installed mysqldump rejects before creating any output
+	if !d.options.Native && !d.options.Increment {
+		if err := d.Probe(); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.options.MaxFileSize > 0 {
+		return d.dumpParts(startTime)
+	}
+
+	// The dump is written to a temporary file, renamed to the output file
+	// once complete. Create it with restrictive permissions (owner
+	// read/write only), or continue the kept output.
+	temp := TempPath(d.options.OutputFile)
+	var outFile *os.File
+	var prefix hash.Hash
+	if d.options.Resume != nil {
+		if outFile, prefix, err = d.openResumed(temp); err != nil {
+			return nil, err
+		}
+	} else if outFile, err = os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600); err != nil {
 		return nil, fmt.Errorf("failed to create output file: %w", err)
 	}
+	// Runs last, after the file is flushed and closed
 	defer func() {
-		if err := outFile.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to close output file: %v\n", err)
+		if err != nil {
+			d.discardOutput([]string{temp}, err)
 		}
 	}()
-
-	// Use 256KB buffer for optimal write performance
-	writer := bufio.NewWriterSize(outFile, 256*1024)
+	closed := false
 	defer func() {
-		if err := writer.Flush(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to flush writer: %v\n", err)
+		if closed {
+			return
+		}
+		if err := outFile.Close(); err != nil {
+			diag.Warnf("failed to close output file: %v", err)
 		}
 	}()
 
-	// Phase 1: Dump structure for all tables
-	if err := d.dumpStructure(writer); err != nil {
-		return nil, fmt.Errorf("failed to dump structure: %w", err)
+	out := newOutput(outFile, d.options.Compress, d.options.Performance, d.options.SyncPolicy, prefix)
+	if d.options.Resume != nil {
+		out.written, out.member = d.options.Resume.Written, d.options.Resume.Written
 	}
-
-	// Phase 2: Dump data for non-excluded tables
-	if err := d.dumpData(writer); err != nil {
-		return nil, fmt.Errorf("failed to dump data: %w", err)
+	recorder := &writeRecorder{writer: out}
+	if err := d.dumpPhases(recorder, &rewinder{out: out}); err != nil {
+		// End the gzip stream so output kept with KeepPartial still decompresses
+		if finishErr := out.finish(); finishErr != nil {
+			diag.Warnf("%v", finishErr)
+		}
+		if recorder.err != nil {
+			return nil, outputWriteError(d.options.OutputFile, recorder.err)
+		}
+		return nil, err
+	}
+	if err := out.finish(); err != nil {
+		return nil, outputWriteError(d.options.OutputFile, err)
+	}
+	// Some filesystems (network, delayed allocation) only report a full
+	// disk once the data is synced
+	if err := out.sync.Finish(); err != nil {
+		return nil, outputWriteError(d.options.OutputFile, err)
 	}
 
 	// Get file size
@@ -83,58 +299,452 @@ func (d *Dumper) Dump() (*DumpResult, error) {
 		return nil, fmt.Errorf("failed to get file info: %w", err)
 	}
 
-	result := &DumpResult{
+	// The synced file replaces the output in one step, made durable by
+	// syncing the directory
+	closed = true
+	if err := outFile.Close(); err != nil {
+		return nil, outputWriteError(d.options.OutputFile, err)
+	}
+	if err := os.Rename(temp, d.options.OutputFile); err != nil {
+		return nil, fmt.Errorf("failed to move the output into place: %w", err)
+	}
+	if err := d.syncDir(); err != nil {
+		return nil, err
+	}
+
+	result = d.result(startTime, fileInfo.Size())
+	result.UncompressedSize = out.written
+	return result, nil
+}
+
+// dumpParts performs the dump into numbered part files
+func (d *Dumper) dumpParts(startTime time.Time) (*DumpResult, error) {
+	performance := d.options.Performance.Filled()
+	parts := dumpfile.NewPartWriter(d.options.OutputFile, d.options.MaxFileSize, d.options.Compress).
+		Compression(performance.GzipLevel(), performance.CompressionWorkers).
+		Sync(d.options.SyncPolicy)
+	recorder := &writeRecorder{writer: parts}
+	counter := &countingWriter{writer: recorder}
+	writer := bufio.NewWriterSize(counter, int(performance.WriterBuffer))
+
+	err := d.dumpPhases(writer, nil)
+	if flushErr := writer.Flush(); flushErr != nil && err == nil {
+		err = fmt.Errorf("failed to write output: %w", flushErr)
+	}
+	// Close syncs the last part, which may fail on a full disk as well
+	current := parts.Current()
+	if closeErr := parts.Close(); closeErr != nil && recorder.err == nil {
+		recorder.err = closeErr
+	}
+	if recorder.err != nil {
+		if current == "" {
+			current = d.options.OutputFile
+		}
+		err = outputWriteError(current, recorder.err)
+	}
+	if err != nil {
+		var paths []string
+		for _, part := range parts.Parts() {
+			paths = append(paths, part.Path)
+		}
+		d.discardOutput(paths, err)
+		return nil, err
+	}
+	if err := d.syncDir(); err != nil {
+		return nil, err
+	}
+
+	var size int64
+	for _, part := range parts.Parts() {
+		size += part.Size
+	}
+
+	result := d.result(startTime, size)
+	result.Parts = parts.Parts()
+	result.UncompressedSize = counter.written
+	return result, nil
+}
+
+// dumpPhases runs the phases into writer; rw (nil for split output) allows
+// restarting a phase after a mid-dump table change. The output always follows
+// the same order: the header, the structure of every table and view, the
+// data of each table in one run (mysqldump's, then samples, then masked
+// tables), triggers and events, and finally the completion marker. Each phase runs to completion
+// before the next starts, so no phase's output needs holding back;
+// dumpfile.CheckOrder checks a finished dump against this order.
+func (d *Dumper) dumpPhases(writer io.Writer, rw *rewinder) error {
+	if d.options.Header != "" && d.options.Resume == nil {
+		if _, err := io.WriteString(writer, d.options.Header); err != nil {
+			return fmt.Errorf("failed to write header: %w", err)
+		}
+	}
+
+	// An increment has nothing but the new rows of its tables
+	increment := d.options.Increment
+
+	// Phase 1: Dump structure for all tables
+	phaseStart := time.Now()
+	if !increment {
+		if err := d.runPhase("structure", writer, rw, d.dumpStructure); err != nil {
+			return fmt.Errorf("failed to dump structure: %w", err)
+		}
+		d.structureDuration = time.Since(phaseStart)
+	}
+
+	// Phase 2: Dump data for non-excluded tables
+	phaseStart = time.Now()
+	if !increment {
+		if err := d.runPhase("data", writer, rw, d.dumpData); err != nil {
+			return fmt.Errorf("failed to dump data: %w", err)
+		}
+		d.dataDuration = time.Since(phaseStart)
+	}
+
+	// Phase 3: Dump samples of excluded tables
+	if len(d.options.Samples) > 0 && !increment {
+		phaseStart = time.Now()
+		if err := d.runPhase("samples", writer, rw, d.dumpSamples); err != nil {
+			return fmt.Errorf("failed to dump table samples: %w", err)
+		}
+		d.dataDuration += time.Since(phaseStart)
+	}
+
+	// Phase 4: Dump the data of masked tables
+	if len(d.options.Masked) > 0 {
+		phaseStart = time.Now()
+		if err := d.runPhase("masked", writer, rw, d.dumpMasked); err != nil {
+			return fmt.Errorf("failed to dump masked tables: %w", err)
+		}
+		d.dataDuration += time.Since(phaseStart)
+	}
+
+	// Phase 5: Copy the data of tables identical to ones dumped before
+	if len(d.options.Copies) > 0 {
+		if err := d.runPhase("copies", writer, rw, d.dumpCopies); err != nil {
+			return fmt.Errorf("failed to write table copies: %w", err)
+		}
+	}
+
+	// Phase 6: Dump triggers and events once all data is in place, so
+	// triggers don't fire on the restored rows
+	if !d.options.Native && !increment {
+		phaseStart = time.Now()
+		if err := d.runPhase("objects", writer, rw, d.dumpObjects); err != nil {
+			return fmt.Errorf("failed to dump triggers and events: %w", err)
+		}
+		d.structureDuration += time.Since(phaseStart)
+	}
+
+	if d.resumeAfter != "" {
+		return fmt.Errorf("the dump to resume was interrupted after its %s phase, which this dump doesn't have", d.resumeAfter)
+	}
+
+	// mysqldump's own marker is left out with --skip-comments; dbdump verify
+	// takes a dump without it for a truncated one
+	if _, err := fmt.Fprintf(writer, "\n-- Dump completed on %s\n", time.Now().Format(time.DateTime)); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// TempPath returns where the output of a single-file dump is written until
+// it is complete
+func TempPath(output string) string {
+	return output + ".tmp"
+}
+
+// syncDir makes the entry of a new output file durable along with its
+// contents, unless the sync policy is none
+func (d *Dumper) syncDir() error {
+	if d.options.SyncPolicy.Never {
+		return nil
+	}
+	if err := fileutil.SyncDir(filepath.Dir(d.options.OutputFile)); err != nil {
+		return fmt.Errorf("failed to sync the output directory: %w", err)
+	}
+	return nil
+}
+
+// discardOutput removes the output of a failed dump, or with KeepPartial
+// renames it to *.partial so it can't be mistaken for a complete dump. The
+// output of a usage error is empty and always removed without comment.
+// Kept output is named after the output file, not its TempPath.
+func (d *Dumper) discardOutput(paths []string, cause error) {
+	var dumpErr *dberrors.ErrMySQLDumpFailed
+	usage := errors.As(cause, &dumpErr) && dumpErr.Usage
+
+	// Single-file output past a checkpoint is kept to be resumed
+	if d.last != nil && !usage && !d.options.KeepPartial && len(paths) == 1 {
+		kept := ResumePath(d.options.OutputFile)
+		if err := os.Rename(paths[0], kept); err != nil {
+			diag.Warnf("failed to keep the output for resuming: %v", err)
+		} else {
+			d.kept = kept
+			return
+		}
+	}
+
+	for _, path := range paths {
+		if d.options.KeepPartial && !usage {
+			kept := path + ".partial"
+			if path == TempPath(d.options.OutputFile) {
+				kept = d.options.OutputFile + ".partial"
+			}
+			if err := os.Rename(path, kept); err != nil {
+				diag.Warnf("failed to keep partial output: %v", err)
+			} else {
+				diag.Warnf("kept the incomplete output of the failed dump as %s", kept)
+				if d.last != nil && len(paths) == 1 {
+					d.kept = kept
+				}
+			}
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			diag.Warnf("failed to remove partial output: %v", err)
+		}
+	}
+}
+
+// result builds the DumpResult for a finished dump
+func (d *Dumper) result(startTime time.Time, size int64) *DumpResult {
+	return &DumpResult{
 		OutputFile:      d.options.OutputFile,
 		Duration:        time.Since(startTime),
 		ExcludedTables:  d.options.ExcludeTables,
-		FileSize:        fileInfo.Size(),
-		FileSizeDisplay: formatBytes(fileInfo.Size()),
+		FileSize:        size,
+		FileSizeDisplay: FormatBytes(size),
+
+		SchemaFingerprints: d.fingerprint.Fingerprints(),
+
+		StructureDuration: d.structureDuration,
+		DataDuration:      d.dataDuration,
+		TableTimings:      d.timer.Finish(),
+		LargestStatement:  d.timer.Longest(),
+		TableSizes:        d.tableSizes(),
+		OverBudget:        d.overBudget,
 	}
+}
 
-	return result, nil
+// tableSizes returns the per-table sizes recorded by the size limit
+func (d *Dumper) tableSizes() []TableSize {
+	if d.limiter == nil {
+		return nil
+	}
+	return d.limiter.tableSizes()
+}
+
+// DumpStructure runs only the structure phase (all non-skipped tables and
+// views, without triggers or events) into writer
+func (d *Dumper) DumpStructure(writer io.Writer) error {
+	return d.dumpStructure(writer)
 }
 
 // dumpStructure dumps the structure of all tables
 func (d *Dumper) dumpStructure(writer io.Writer) error {
-	// Create context that cancels on Ctrl+C
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
+	// Fingerprint CREATE TABLE statements as they stream past
+	d.fingerprint = NewSchemaFingerprinter()
+	if d.options.Native {
+		return d.dumpDefinitions(writer, d.fingerprint, d.nativeStructure)
+	}
+	return d.dumpDefinitions(writer, d.fingerprint, d.mysqldump("structure", d.structureArgs()))
+}
+
+// dumpObjects dumps the triggers of all non-skipped tables and the events
+func (d *Dumper) dumpObjects(writer io.Writer) error {
+	return d.dumpDefinitions(writer, io.Discard, d.mysqldump("objects", d.objectsArgs()))
+}
+
+// dumpDefinitions runs a phase that writes definitions only, through the
+// structure filter; observer sees the unfiltered output
+func (d *Dumper) dumpDefinitions(writer, observer io.Writer, run func(io.Writer) error) error {
+	var filter io.WriteCloser
+	if d.options.StructureFilter != nil {
+		filter = d.options.StructureFilter(writer)
+		writer = filter
+	}
+
+	if err := run(io.MultiWriter(writer, observer)); err != nil {
+		return err
+	}
+
+	if filter != nil {
+		if err := filter.Close(); err != nil {
+			return fmt.Errorf("failed to write definitions: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// mysqldump returns a function running mysqldump with args, writing its
+// output to the given writer
+func (d *Dumper) mysqldump(phase string, args []string) func(io.Writer) error {
+	return func(stdout io.Writer) error {
+		ctx := d.context()
+		cmd := exec.CommandContext(ctx, "mysqldump", args...)
+		cmd.Stdout = stdout
+		stderr := &stderrTail{}
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+		// Pass the password via MYSQL_PWD; tokens are minted right before each phase
+		env, err := d.options.Connection.ClientEnv(ctx)
+		if err != nil {
+			return err
+		}
+		cmd.Env = env
+
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w: mysqldump %s: %w", dberrors.ErrDumpInterrupted, phase, err)
+			}
+			return classifyDumpError(phase, err, stderr.buf)
+		}
+		return nil
+	}
+}
+
+// dumpData dumps data for non-excluded tables
+func (d *Dumper) dumpData(writer io.Writer) error {
+	// Cut tables off at the size limit, a whole statement at a time, and
+	// sample what is written
+	var funcs []transform.Func
+	var spilled []transform.SpillFunc
+	if d.options.MaxTableSize > 0 {
+		d.limiter = newTableLimiter(d.options.MaxTableSize)
+		funcs = append(funcs, d.limiter.transform)
+		spilled = append(spilled, d.limiter.spilled)
+	}
+	if d.options.SampleStatements > 0 {
+		sampler := newStatementSampler(d.options.SampleStatements, d.options.SampleValueLength)
+		funcs = append(funcs, sampler.transform)
+		spilled = append(spilled, sampler.spilled)
+		// Written even when the phase fails, which is when it is most useful
+		defer func() {
+			if err := sampler.write(d.options.SampleFile); err != nil {
+				diag.Warnf("%v", err)
+			}
+		}()
+	}
+	var transformed *transform.Writer
+	if len(funcs) > 0 {
+		transformed = transform.NewWriter(writer, funcs...).OnSpill(spilled...)
+		writer = transformed
+	}
+
+	// Attribute time and bytes to tables as their data streams past
+	d.timer = NewTableTimer()
+	if d.options.ShowProgress && d.options.OnProgress != nil {
+		d.timer.onLine = d.reportProgress
+	}
+	d.timer.onDone = d.options.OnTableDone
+	d.timer.Start()
+
+	run := d.mysqldump("data", d.dataArgs())
+	switch {
+	case d.options.TimeBudget > 0:
+		run = d.budgetedData
+	case d.options.Native:
+		run = d.nativeData
+	}
+	if err := run(io.MultiWriter(writer, d.timer)); err != nil {
+		if transformed != nil {
+			_ = transformed.Discard()
+		}
+		// Name the table whose data was streaming when mysqldump gave up
+		var dumpErr *dberrors.ErrMySQLDumpFailed
+		if errors.As(err, &dumpErr) && !dumpErr.Usage {
+			dumpErr.Table = d.timer.Current()
+		}
+		return err
+	}
+
+	if transformed != nil {
+		if err := transformed.Close(); err != nil {
+			return fmt.Errorf("failed to write data: %w", err)
+		}
+	}
 
+	// The last table is done with the phase, not when the next one starts
+	d.timer.Finish()
+	return nil
+}
+
+// structureArgs builds the mysqldump arguments of the structure phase
+func (d *Dumper) structureArgs() []string {
 	args := d.buildMySQLDumpArgs()
 	args = append(args,
 		"--no-data",
-		"--triggers",            // Explicitly include triggers
-		"--events",              // Include scheduled events
+		"--skip-triggers",       // Triggers follow the data (objects phase)
+		"--skip-events",         // Events follow the data (objects phase)
 		"--set-gtid-purged=OFF", // Cross-version compatibility
 		"--column-statistics=0", // Avoid MySQL 8.0 warnings/errors
 		// Note: --routines disabled due to MySQL 5.7 compatibility issues with INFORMATION_SCHEMA.LIBRARIES
 	)
+
+	// Add ignore-table flags for skipped tables
+	for _, table := range d.options.SkipTables {
+		args = append(args, sqlident.IgnoreTableArg(d.options.Connection.Database, table))
+	}
+
 	args = append(args, d.options.Connection.Database)
+	return args
+}
 
-	cmd := exec.CommandContext(ctx, "mysqldump", args...)
-	cmd.Stdout = writer
-	cmd.Stderr = os.Stderr
+// objectsArgs builds the mysqldump arguments of the objects phase, which
+// writes triggers and events without any table definitions or data
+func (d *Dumper) objectsArgs() []string {
+	args := d.buildMySQLDumpArgs()
+	args = append(args,
+		"--no-create-info",
+		"--no-data",
+		"--triggers",            // Explicitly include triggers
+		"--events",              // Include scheduled events
+		"--skip-routines",       // See structureArgs
+		"--set-gtid-purged=OFF", // Cross-version compatibility
+		"--column-statistics=0", // Avoid MySQL 8.0 warnings/errors
+	)
 
-	// Set MYSQL_PWD environment variable for secure password passing
-	if d.options.Connection.Password != "" {
-		cmd.Env = append(os.Environ(), "MYSQL_PWD="+d.options.Connection.Password)
+	// Skipped tables take their triggers with them
+	for _, table := range d.options.SkipTables {
+		args = append(args, sqlident.IgnoreTableArg(d.options.Connection.Database, table))
 	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("mysqldump structure failed: %w", err)
+	args = append(args, d.options.Connection.Database)
+	return args
+}
+
+// dataArgs builds the mysqldump arguments of the data phase
+func (d *Dumper) dataArgs() []string {
+	args := d.dataOnlyArgs()
+
+	// Add ignore-table flags for excluded and skipped tables, and for
+	// masked and copied tables, whose data is dumped separately
+	for _, tables := range [][]string{d.options.ExcludeTables, d.options.SkipTables} {
+		for _, table := range tables {
+			args = append(args, sqlident.IgnoreTableArg(d.options.Connection.Database, table))
+		}
+	}
+	for _, table := range d.options.Masked {
+		args = append(args, sqlident.IgnoreTableArg(d.options.Connection.Database, table.Table))
+	}
+	for _, table := range d.options.Copies {
+		args = append(args, sqlident.IgnoreTableArg(d.options.Connection.Database, table.Table))
 	}
 
-	return nil
+	args = append(args, d.options.Connection.Database)
+	return args
 }
 
-// dumpData dumps data for non-excluded tables
-func (d *Dumper) dumpData(writer io.Writer) error {
-	// Create context that cancels on Ctrl+C
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
+// tableDataArgs builds the mysqldump arguments dumping one table's data
+func (d *Dumper) tableDataArgs(table string) []string {
+	return append(d.dataOnlyArgs(), d.options.Connection.Database, table)
+}
 
-	args := d.buildMySQLDumpArgs()
-	args = append(args,
+// dataOnlyArgs builds the arguments shared by the data invocations, which
+// write rows and nothing else
+func (d *Dumper) dataOnlyArgs() []string {
+	return append(d.buildMySQLDumpArgs(),
 		"--no-create-info",
 		"--skip-triggers",       // Prevent duplicate triggers
 		"--skip-routines",       // Prevent duplicate routines
@@ -142,29 +752,34 @@ func (d *Dumper) dumpData(writer io.Writer) error {
 		"--set-gtid-purged=OFF", // Cross-version compatibility
 		"--column-statistics=0", // Avoid MySQL 8.0 warnings/errors
 	)
+}
 
-	// Add ignore-table flags for excluded tables
-	for _, table := range d.options.ExcludeTables {
-		args = append(args, fmt.Sprintf("--ignore-table=%s.%s",
-			d.options.Connection.Database, table))
-	}
-
-	args = append(args, d.options.Connection.Database)
-
-	cmd := exec.CommandContext(ctx, "mysqldump", args...)
-	cmd.Stdout = writer
-	cmd.Stderr = os.Stderr
+// Command is the mysqldump invocation of a dump phase
+type Command struct {
+	Phase string
+	Args  []string
+}
 
-	// Set MYSQL_PWD environment variable for secure password passing
-	if d.options.Connection.Password != "" {
-		cmd.Env = append(os.Environ(), "MYSQL_PWD="+d.options.Connection.Password)
+// Commands returns the mysqldump invocations of the phases in the order
+// they run, or nil for a native dump. Samples and masked tables are read
+// by dbdump itself and have none.
+func (d *Dumper) Commands() []Command {
+	if d.options.Native {
+		return nil
 	}
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("mysqldump data failed: %w", err)
+	return []Command{
+		{Phase: "structure", Args: d.structureArgs()},
+		{Phase: "data", Args: d.dataArgs()},
+		{Phase: "objects", Args: d.objectsArgs()},
 	}
+}
 
-	return nil
+// context returns the parent context of the dump phases
+func (d *Dumper) context() context.Context {
+	if d.options.Context != nil {
+		return d.options.Context
+	}
+	return context.Background()
 }
 
 // buildMySQLDumpArgs builds common mysqldump arguments
@@ -185,13 +800,41 @@ func (d *Dumper) buildMySQLDumpArgs() []string {
 
 	// Add performance optimization flags
 	args = append(args,
-		"--max-allowed-packet=1G",
-		"--net-buffer-length=1M",
+		d.maxAllowedPacketArg(),
+		d.netBufferArg(),
 		"--skip-comments",
 		"--hex-blob", // Handle binary columns safely
 	)
 
-	return args
+	if d.options.DefaultCharacterSet != "" {
+		args = append(args, "--default-character-set="+d.options.DefaultCharacterSet)
+	}
+	args = append(args, d.options.ExtraArgs...)
+
+	return append(args, d.options.Connection.ClientArgs()...)
+}
+
+// maxAllowedPacketArg returns the --max-allowed-packet flag, clamped to
+// the server's value when it is known
+func (d *Dumper) maxAllowedPacketArg() string {
+	limit := ClientPacketLimit(d.options.ServerMaxAllowedPacket)
+	if limit == ClientMaxAllowedPacket {
+		return "--max-allowed-packet=1G"
+	}
+	return fmt.Sprintf("--max-allowed-packet=%d", limit)
+}
+
+// netBufferArg returns the --net-buffer-length flag, at most the server's
+// max_allowed_packet so that the INSERT statements it sizes can be restored
+func (d *Dumper) netBufferArg() string {
+	size := d.options.Performance.Filled().NetBuffer
+	if packet := d.options.ServerMaxAllowedPacket; packet > 0 {
+		size = min(size, max(packet, tuning.MinNetBuffer))
+	}
+	if size%(1<<20) == 0 {
+		return fmt.Sprintf("--net-buffer-length=%dM", size>>20)
+	}
+	return fmt.Sprintf("--net-buffer-length=%d", size)
 }
 
 // dryRun performs a dry run showing what would be dumped
@@ -206,11 +849,48 @@ func (d *Dumper) dryRun() (*DumpResult, error) {
 	return result, nil
 }
 
+// probeTimeout bounds each mysqldump compatibility probe
+const probeTimeout = 10 * time.Second
+
+// Probe runs mysqldump with each phase's planned arguments followed by
+// --help. mysqldump parses every option before acting on --help, so an
+// option the installed client doesn't support fails here, before any output
+// is written, while a compatible invocation prints its usage and exits
+// without connecting.
+func (d *Dumper) Probe() error {
+	phases := []struct {
+		name string
+		args []string
+	}{
+		{"structure", d.structureArgs()},
+		{"data", d.dataArgs()},
+		{"objects", d.objectsArgs()},
+	}
+
+	for _, phase := range phases {
+		ctx, cancel := context.WithTimeout(d.context(), probeTimeout)
+		cmd := exec.CommandContext(ctx, "mysqldump", append(phase.args, "--help")...)
+		stderr := &stderrTail{}
+		cmd.Stderr = stderr
+		err := cmd.Run()
+		cancel()
+
+		if err != nil {
+			if d.context().Err() != nil {
+				return fmt.Errorf("%w: mysqldump compatibility check: %w", dberrors.ErrDumpInterrupted, err)
+			}
+			return fmt.Errorf("mysqldump compatibility check failed: %w",
+				classifyDumpError(phase.name, err, stderr.buf))
+		}
+	}
+	return nil
+}
+
 // CheckMySQLDump verifies that mysqldump is available
 func CheckMySQLDump() error {
 	cmd := exec.Command("mysqldump", "--version")
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("mysqldump not found in PATH: %w", err)
+		return fmt.Errorf("%w: %w", dberrors.ErrMySQLDumpNotFound, err)
 	}
 	return nil
 }
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+)
+
+// TestDumpRenamesIntoPlace checks that a dump is written to its TempPath
+// and only replaces the output file once complete
+func TestDumpRenamesIntoPlace(t *testing.T) {
+	dir := installFakeMySQLDump(t)
+	out := t.TempDir()
+	output := filepath.Join(out, "shop.sql")
+	if err := os.WriteFile(output, []byte("-- an earlier dump\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// While mysqldump runs, the earlier dump is untouched
+	t.Setenv("FAKE_MYSQLDUMP_HANG", "data")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ctx.Err() == nil {
+			if _, err := os.Stat(filepath.Join(dir, "data.pid")); err == nil {
+				checkFiles(t, out, "shop.sql", "shop.sql.tmp")
+				checkContent(t, output, "-- an earlier dump\n")
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	if _, err := interruptibleDump(ctx, output).Dump(); err == nil {
+		t.Fatal("the interrupted dump succeeded")
+	}
+	<-done
+	checkFiles(t, out, "shop.sql", "shop.sql.resume")
+	checkContent(t, output, "-- an earlier dump\n")
+
+	// A failed dump leaves it too
+	if err := os.Remove(ResumePath(output)); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_MYSQLDUMP_HANG", "")
+	t.Setenv("FAKE_MYSQLDUMP_FAIL", "structure")
+	if _, _, err := fakeDump(output, false, nil, ""); err == nil {
+		t.Fatal("the failing dump succeeded")
+	}
+	checkFiles(t, out, "shop.sql")
+	checkContent(t, output, "-- an earlier dump\n")
+
+	// A complete one replaces it
+	t.Setenv("FAKE_MYSQLDUMP_FAIL", "")
+	_, result, err := fakeDump(output, false, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, out, "shop.sql")
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OutputFile != output || info.Size() != result.FileSize {
+		t.Errorf("result names %s of %d bytes, the file is %d", result.OutputFile, result.FileSize, info.Size())
+	}
+}
+
+// checkContent fails unless the file at path holds want
+func checkContent(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("%s holds %q, want %q", path, data, want)
+	}
+}
+
+// TestDumpOrder checks that dumps keep the documented order whatever the
+// order of mysqldump's tables, with compressed and split output and with a
+// data phase restarted after a table change
+func TestDumpOrder(t *testing.T) {
+	structure := strings.SplitAfter(fakeOutput["structure"], ");\n")[:2]
+	data := strings.SplitAfter(fakeOutput["data"], "\n")[:2]
+
+	tests := []struct {
+		name      string
+		structure []string
+		data      []string
+		compress  bool
+		split     int64
+		retry     bool
+	}{
+		{name: "as listed", structure: structure, data: data},
+		{name: "data reversed", structure: structure, data: []string{data[1], data[0]}},
+		{name: "structure reversed", structure: []string{structure[1], structure[0]}, data: data},
+		{name: "compressed", structure: []string{structure[1], structure[0]}, data: []string{data[1], data[0]}, compress: true},
+		{name: "split", structure: structure, data: []string{data[1], data[0]}, split: 120},
+		{name: "split and compressed", structure: []string{structure[1], structure[0]}, data: data, compress: true, split: 120},
+		{name: "retried", structure: structure, data: []string{data[1], data[0]}, retry: true},
+		{name: "retried and compressed", structure: []string{structure[1], structure[0]}, data: data, compress: true, retry: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := installFakeMySQLDump(t)
+			for phase, sql := range map[string]string{"structure": strings.Join(tt.structure, ""), "data": strings.Join(tt.data, "")} {
+				if err := os.WriteFile(filepath.Join(dir, phase+".sql"), []byte(sql), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.retry {
+				t.Setenv("FAKE_MYSQLDUMP_FAIL", "data")
+				t.Setenv("FAKE_MYSQLDUMP_ONCE", "1")
+				t.Setenv("FAKE_MYSQLDUMP_ERROR", "Couldn't execute 'SELECT /*!40001 SQL_NO_CACHE */ * FROM `orders`': Table definition has changed, please retry transaction (1412)")
+			}
+
+			output := filepath.Join(t.TempDir(), "shop.sql")
+			result, err := NewDumper(&DumpOptions{
+				Connection:      &Connection{Host: "db", Port: 3306, User: "app", Database: "shop"},
+				OutputFile:      output,
+				Compress:        tt.compress,
+				MaxFileSize:     tt.split,
+				Header:          "-- dbdump test\n",
+				TableDefRetries: 1,
+			}).Dump()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.split > 0 && len(result.Parts) < 2 {
+				t.Fatalf("dump written in %d parts, want several", len(result.Parts))
+			}
+
+			violations, err := dumpfile.CheckOrder(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(violations) > 0 {
+				t.Errorf("CheckOrder() = %v", violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package database
+
+// structureOverhead approximates the DDL and dump boilerplate written per table
+const structureOverhead = 2 * 1024
+
+// EstimateDumpSize estimates the size of a dump from table statistics: the
+// data length of tables whose data is dumped, plus DDL for every table that
+// isn't skipped. Statistics are approximate (InnoDB samples them), so the
+// estimate is only meant to be within a small factor of the real size.
+func EstimateDumpSize(tables []TableInfo, excluded, skipped []string) int64 {
+	dataExcluded := make(map[string]bool, len(excluded)+len(skipped))
+	for _, table := range excluded {
+		dataExcluded[table] = true
+	}
+	isSkipped := make(map[string]bool, len(skipped))
+	for _, table := range skipped {
+		isSkipped[table] = true
+	}
+
+	var size int64
+	for _, table := range tables {
+		if isSkipped[table.Name] {
+			continue
+		}
+		size += structureOverhead
+		if !dataExcluded[table.Name] {
+			size += table.DataSize
+		}
+	}
+	return size
+}
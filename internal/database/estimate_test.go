@@ -0,0 +1,125 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+const mb = 1024 * 1024
+
+func TestEstimateDumpSize(t *testing.T) {
+	tables := []TableInfo{
+		{Name: "users", DataSize: 10 * mb},
+		{Name: "orders", DataSize: 40 * mb},
+		{Name: "sessions", DataSize: 100 * mb},
+		{Name: "user_totals"}, // a view
+	}
+	tests := []struct {
+		name     string
+		excluded []string
+		skipped  []string
+		want     int64
+	}{
+		{name: "everything", want: 150*mb + 4*structureOverhead},
+		{name: "data excluded", excluded: []string{"sessions"}, want: 50*mb + 4*structureOverhead},
+		{name: "skipped", skipped: []string{"sessions", "user_totals"}, want: 50*mb + 2*structureOverhead},
+		{name: "excluded and skipped", excluded: []string{"sessions", "orders"}, skipped: []string{"sessions"}, want: 10*mb + 3*structureOverhead},
+		{name: "unknown names", excluded: []string{"gone"}, skipped: []string{"also_gone"}, want: 150*mb + 4*structureOverhead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateDumpSize(tables, tt.excluded, tt.skipped); got != tt.want {
+				t.Errorf("EstimateDumpSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEstimateCalibration feeds pairs of table statistics and dump sizes
+// through the estimator and checks it stays within the factor at which
+// dbdump warns about a diverging dump (size_warning_factor, default 3).
+// The cases model common database shapes; add pairs recorded in the
+// estimated_size and file_size fields of real dumps' sidecars when the
+// estimator changes.
+func TestEstimateCalibration(t *testing.T) {
+	const factor = 3.0
+	tests := []struct {
+		name     string
+		tables   []TableInfo
+		excluded []string
+		actual   int64
+	}{
+		{
+			// Extended INSERTs are a little larger than InnoDB's pages for short rows
+			name: "small rows",
+			tables: []TableInfo{
+				{Name: "users", DataSize: 48 * mb},
+				{Name: "orders", DataSize: 210 * mb},
+				{Name: "order_items", DataSize: 390 * mb},
+			},
+			actual: 702 * mb,
+		},
+		{
+			// Text is written much as it is stored
+			name: "text heavy",
+			tables: []TableInfo{
+				{Name: "posts", DataSize: 1500 * mb},
+				{Name: "comments", DataSize: 620 * mb},
+			},
+			actual: 1890 * mb,
+		},
+		{
+			// Binary columns are written as hex, doubling their size
+			name: "blob heavy",
+			tables: []TableInfo{
+				{Name: "attachments", DataSize: 800 * mb},
+				{Name: "users", DataSize: 12 * mb},
+			},
+			actual: 1710 * mb,
+		},
+		{
+			// Pages freed by large deletes still count towards the data length
+			name: "fragmented after deletes",
+			tables: []TableInfo{
+				{Name: "events", DataSize: 2400 * mb},
+				{Name: "users", DataSize: 30 * mb},
+			},
+			actual: 1050 * mb,
+		},
+		{
+			name: "large tables excluded",
+			tables: []TableInfo{
+				{Name: "logs", DataSize: 5000 * mb},
+				{Name: "sessions", DataSize: 900 * mb},
+				{Name: "customers", DataSize: 64 * mb},
+			},
+			excluded: []string{"logs", "sessions"},
+			actual:   71 * mb,
+		},
+		{
+			// Many empty tables: the per-table DDL allowance is the estimate
+			name:   "schema only",
+			tables: emptyTables(400),
+			actual: 700 * 1024,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := EstimateDumpSize(tt.tables, tt.excluded, nil)
+			ratio := float64(tt.actual) / float64(estimate)
+			if ratio > factor || ratio < 1/factor {
+				t.Errorf("estimate %s is %.2fx the dump size %s, outside a factor of %.0f",
+					FormatBytes(estimate), 1/ratio, FormatBytes(tt.actual), factor)
+			}
+		})
+	}
+}
+
+// emptyTables returns n tables without data
+func emptyTables(n int) []TableInfo {
+	tables := make([]TableInfo, n)
+	for i := range tables {
+		tables[i].Name = fmt.Sprintf("t%d", i)
+	}
+	return tables
+}
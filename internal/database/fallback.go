@@ -0,0 +1,272 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// SizeUnavailable is shown instead of a size when it could not be read
+const SizeUnavailable = "unavailable"
+
+// permissionErrors are MySQL error numbers for missing privileges
+var permissionErrors = map[uint16]bool{
+	1044: true, // ER_DBACCESS_DENIED_ERROR
+	1142: true, // ER_TABLEACCESS_DENIED_ERROR
+	1143: true, // ER_COLUMNACCESS_DENIED_ERROR
+	1227: true, // ER_SPECIFIC_ACCESS_DENIED_ERROR
+}
+
+// WithMetadataTimeout limits how long each attempt at reading table
+// information may take before falling back to a cheaper source
+func (i *Inspector) WithMetadataTimeout(timeout time.Duration) *Inspector {
+	i.metadataTimeout = timeout
+	return i
+}
+
+// Degraded returns why table information came from a fallback source, or ""
+// when the configured source answered in time
+func (i *Inspector) Degraded() string {
+	return i.degraded
+}
+
+// GetAllTablesInfo retrieves information for all tables, largest first,
+// from the configured metadata source. When information_schema is too slow
+// or not accessible it falls back to SHOW TABLE STATUS, and then to SHOW
+// FULL TABLES with sizes marked unknown; in auto mode its table list is
+// also checked against SHOW TABLES. Degraded explains any fallback.
+func (i *Inspector) GetAllTablesInfo() ([]TableInfo, error) {
+	i.degraded = ""
+	i.source = ""
+
+	if i.metadataSource == MetadataShow {
+		return i.tablesFromShowStatements()
+	}
+
+	tables, err := i.withTimeout(i.tablesFromInformationSchema)
+	if err == nil {
+		if i.metadataSource != MetadataAuto {
+			i.source = sourceInformationSchema
+			return tables, nil
+		}
+		mismatch, err := i.checkAgainstShowTables(tables)
+		if err != nil {
+			return nil, err
+		}
+		if mismatch == "" {
+			i.source = sourceInformationSchema
+			return tables, nil
+		}
+		tables, err = i.tablesFromShowStatements()
+		if err != nil {
+			return nil, err
+		}
+		if i.degraded == "" {
+			i.degraded = mismatch + "; used SHOW statements instead"
+		} else {
+			i.degraded = mismatch + ", " + i.degraded
+		}
+		return tables, nil
+	}
+	if !shouldFallBack(err) {
+		return nil, err
+	}
+	reason := "information_schema " + fallbackReason(err, i.metadataTimeout)
+
+	tables, err = i.withTimeout(i.tablesFromTableStatus)
+	if err == nil {
+		i.source = sourceTableStatus
+		i.degraded = reason + "; used SHOW TABLE STATUS instead"
+		return tables, nil
+	}
+	if !shouldFallBack(err) {
+		return nil, err
+	}
+	reason += ", SHOW TABLE STATUS " + fallbackReason(err, i.metadataTimeout)
+
+	// The table list itself is cheap; don't limit it
+	tables, err = i.tablesFromShowTables(i.context())
+	if err != nil {
+		return nil, err
+	}
+	i.source = sourceShowTables
+	i.degraded = reason + "; sizes and row counts are unavailable"
+	return tables, nil
+}
+
+// SizesKnown reports whether the tables' sizes and row counts were read
+func SizesKnown(tables []TableInfo) bool {
+	for _, info := range tables {
+		if info.SizeUnknown {
+			return false
+		}
+	}
+	return true
+}
+
+// withTimeout runs a table query under the metadata timeout
+func (i *Inspector) withTimeout(query func(context.Context) ([]TableInfo, error)) ([]TableInfo, error) {
+	ctx := i.context()
+	if i.metadataTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, i.metadataTimeout)
+		defer cancel()
+	}
+
+	tables, err := query(ctx)
+	if err != nil && ctx.Err() != nil {
+		// The driver may report a cancelled query as a broken connection
+		return nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+	return tables, err
+}
+
+// shouldFallBack reports whether an error calls for a cheaper source:
+// a timeout or missing privileges
+func shouldFallBack(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return IsPermissionError(err)
+}
+
+// IsPermissionError reports whether err is MySQL refusing a statement for
+// missing privileges
+func IsPermissionError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && permissionErrors[mysqlErr.Number]
+}
+
+// errUnknownColumn is MySQL's error for a column the server doesn't have
+const errUnknownColumn = 1054
+
+// unknownColumn reports whether a query failed because the server predates
+// one of its information_schema columns
+func unknownColumn(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errUnknownColumn
+}
+
+// fallbackReason describes why a source was abandoned
+func fallbackReason(err error, timeout time.Duration) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Sprintf("did not answer within %s", timeout)
+	}
+	return "is not accessible"
+}
+
+// tablesFromTableStatus reads table information with SHOW TABLE STATUS
+func (i *Inspector) tablesFromTableStatus(ctx context.Context) ([]TableInfo, error) {
+	rows, err := i.db.QueryContext(ctx, "SHOW TABLE STATUS")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table status: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table status: %w", err)
+	}
+
+	var tables []TableInfo
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for n := range values {
+			dest[n] = &values[n]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan table status: %w", err)
+		}
+
+		field := func(name string) string {
+			for n, column := range columns {
+				if strings.EqualFold(column, name) {
+					return values[n].String
+				}
+			}
+			return ""
+		}
+		number := func(name string) int64 {
+			n, _ := strconv.ParseInt(field(name), 10, 64)
+			return n
+		}
+
+		info := TableInfo{
+			Name:      field("Name"),
+			RowCount:  number("Rows"),
+			DataSize:  number("Data_length"),
+			IndexSize: number("Index_length"),
+			Engine:    field("Engine"),
+			Comment:   field("Comment"),
+		}
+		if info.Engine == "" && info.Comment == "VIEW" {
+			info.Comment = ""
+		}
+		info.TotalSize = info.DataSize + info.IndexSize
+		info.SizeDisplay = FormatBytes(info.TotalSize)
+		info.CreateTime = parseStatusTime(field("Create_time"))
+		info.UpdateTime = parseStatusTime(field("Update_time"))
+		tables = append(tables, info)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating table status: %w", err)
+	}
+
+	sort.SliceStable(tables, func(a, b int) bool {
+		return tables[a].TotalSize > tables[b].TotalSize
+	})
+	return tables, nil
+}
+
+// parseStatusTime parses a SHOW TABLE STATUS time, which arrives formatted as
+// RFC 3339 when the driver parses times and as DATETIME text otherwise
+func parseStatusTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// tablesFromShowTables lists tables and views with SHOW FULL TABLES; sizes
+// and row counts are marked unknown and engines are left empty
+func (i *Inspector) tablesFromShowTables(ctx context.Context) ([]TableInfo, error) {
+	rows, err := i.db.QueryContext(ctx, "SHOW FULL TABLES")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var tables []TableInfo
+	for rows.Next() {
+		var name, tableType string
+		if err := rows.Scan(&name, &tableType); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		tables = append(tables, TableInfo{
+			Name:        name,
+			SizeDisplay: SizeUnavailable,
+			SizeUnknown: true,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tables: %w", err)
+	}
+
+	return tables, nil
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Patterns of the table information queries
+const (
+	informationSchemaQuery = `FROM information_schema\.tables`
+	tableStatusQuery       = `SHOW TABLE STATUS`
+	showTablesQuery        = `SHOW FULL TABLES`
+)
+
+// newMockInspector returns an inspector on a mocked connection; the
+// expectations are checked when the test ends
+func newMockInspector(t *testing.T) (*Inspector, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		_ = db.Close()
+	})
+	return NewInspector(db), mock
+}
+
+// informationSchemaRows answers the information_schema query
+func informationSchemaRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"table_name", "row_count", "data_size", "index_size", "total_size", "engine", "comment", "create_time", "update_time"}).
+		AddRow("orders", 5000, 4<<20, 1<<20, 5<<20, "InnoDB", "", nil, nil).
+		AddRow("users", 120, 64<<10, 16<<10, 80<<10, "InnoDB", "Accounts", nil, nil)
+}
+
+// tableStatusRows answers SHOW TABLE STATUS, including a view
+func tableStatusRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"Name", "Engine", "Version", "Rows", "Data_length", "Index_length", "Create_time", "Update_time", "Comment"}).
+		AddRow("users", "InnoDB", "10", "120", "65536", "16384", "2024-03-01 10:00:00", nil, "Accounts").
+		AddRow("orders", "InnoDB", "10", "5000", "4194304", "1048576", "2024-03-01T10:00:00Z", nil, "").
+		AddRow("user_totals", nil, nil, nil, nil, nil, nil, nil, "VIEW")
+}
+
+// showTablesRows answers SHOW FULL TABLES
+func showTablesRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"Tables_in_shop", "Table_type"}).
+		AddRow("orders", "BASE TABLE").
+		AddRow("users", "BASE TABLE").
+		AddRow("user_totals", "VIEW")
+}
+
+func TestGetAllTablesInfoFallback(t *testing.T) {
+	denied := &mysql.MySQLError{Number: 1142, Message: "SELECT command denied to user 'dump'@'%' for table 'tables'"}
+	tests := []struct {
+		name     string
+		expect   func(mock sqlmock.Sqlmock)
+		source   string
+		degraded string
+		tables   []string
+		unknown  bool
+		err      string
+	}{
+		{
+			name: "information_schema",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillReturnRows(informationSchemaRows())
+			},
+			source: sourceInformationSchema,
+			tables: []string{"orders", "users"},
+		},
+		{
+			name: "information_schema too slow",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillDelayFor(time.Second).WillReturnRows(informationSchemaRows())
+				mock.ExpectQuery(tableStatusQuery).WillReturnRows(tableStatusRows())
+			},
+			source:   sourceTableStatus,
+			degraded: "information_schema did not answer within 50ms; used SHOW TABLE STATUS instead",
+			tables:   []string{"orders", "users", "user_totals"},
+		},
+		{
+			name: "information_schema denied",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillReturnError(denied)
+				mock.ExpectQuery(tableStatusQuery).WillReturnRows(tableStatusRows())
+			},
+			source:   sourceTableStatus,
+			degraded: "information_schema is not accessible; used SHOW TABLE STATUS instead",
+			tables:   []string{"orders", "users", "user_totals"},
+		},
+		{
+			name: "both denied",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillReturnError(denied)
+				mock.ExpectQuery(tableStatusQuery).WillReturnError(&mysql.MySQLError{Number: 1227, Message: "Access denied"})
+				mock.ExpectQuery(showTablesQuery).WillReturnRows(showTablesRows())
+			},
+			source:   sourceShowTables,
+			degraded: "information_schema is not accessible, SHOW TABLE STATUS is not accessible; sizes and row counts are unavailable",
+			tables:   []string{"orders", "users", "user_totals"},
+			unknown:  true,
+		},
+		{
+			name: "both too slow",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillDelayFor(time.Second).WillReturnRows(informationSchemaRows())
+				mock.ExpectQuery(tableStatusQuery).WillDelayFor(time.Second).WillReturnRows(tableStatusRows())
+				mock.ExpectQuery(showTablesQuery).WillReturnRows(showTablesRows())
+			},
+			source:   sourceShowTables,
+			degraded: "information_schema did not answer within 50ms, SHOW TABLE STATUS did not answer within 50ms; sizes and row counts are unavailable",
+			tables:   []string{"orders", "users", "user_totals"},
+			unknown:  true,
+		},
+		{
+			// Other errors are reported instead of hidden by a fallback
+			name: "other error",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillReturnError(&mysql.MySQLError{Number: 2013, Message: "Lost connection to MySQL server during query"})
+			},
+			err: "Lost connection",
+		},
+		{
+			name: "fallback fails",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillReturnError(denied)
+				mock.ExpectQuery(tableStatusQuery).WillReturnError(sql.ErrConnDone)
+			},
+			err: "failed to get table status",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspector, mock := newMockInspector(t)
+			inspector.WithMetadataTimeout(50 * time.Millisecond).WithMetadataSource(MetadataInformationSchema)
+			tt.expect(mock)
+
+			tables, err := inspector.GetAllTablesInfo()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("GetAllTablesInfo() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, info := range tables {
+				names = append(names, info.Name)
+				if info.SizeUnknown != tt.unknown {
+					t.Errorf("%s: SizeUnknown = %v, want %v", info.Name, info.SizeUnknown, tt.unknown)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.tables) {
+				t.Errorf("tables = %v, want %v", names, tt.tables)
+			}
+			if got := inspector.Source(); got != tt.source {
+				t.Errorf("Source() = %q, want %q", got, tt.source)
+			}
+			if got := inspector.Degraded(); got != tt.degraded {
+				t.Errorf("Degraded() = %q, want %q", got, tt.degraded)
+			}
+			if SizesKnown(tables) == tt.unknown {
+				t.Errorf("SizesKnown() = %v", !tt.unknown)
+			}
+		})
+	}
+}
+
+func TestTablesFromTableStatus(t *testing.T) {
+	inspector, mock := newMockInspector(t)
+	mock.ExpectQuery(tableStatusQuery).WillReturnRows(tableStatusRows())
+
+	tables, err := inspector.tablesFromTableStatus(inspector.context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	want := []TableInfo{
+		{Name: "orders", RowCount: 5000, DataSize: 4 << 20, IndexSize: 1 << 20, TotalSize: 5 << 20, SizeDisplay: "5.0 MB", Engine: "InnoDB", CreateTime: created},
+		{Name: "users", RowCount: 120, DataSize: 64 << 10, IndexSize: 16 << 10, TotalSize: 80 << 10, SizeDisplay: "80.0 KB", Engine: "InnoDB", Comment: "Accounts", CreateTime: created},
+		{Name: "user_totals", SizeDisplay: "0 B"},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("tablesFromTableStatus() =\n%+v\nwant\n%+v", tables, want)
+	}
+}
+
+func TestIsPermissionError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: &mysql.MySQLError{Number: 1044}, want: true},
+		{err: &mysql.MySQLError{Number: 1142}, want: true},
+		{err: &mysql.MySQLError{Number: 1143}, want: true},
+		{err: &mysql.MySQLError{Number: 1227}, want: true},
+		{err: &mysql.MySQLError{Number: 1045}}, // wrong password, not a privilege
+		{err: sql.ErrNoRows},
+		{err: nil},
+	}
+	for _, tt := range tests {
+		if got := IsPermissionError(tt.err); got != tt.want {
+			t.Errorf("IsPermissionError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
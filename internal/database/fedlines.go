@@ -0,0 +1,109 @@
+package database
+
+import "github.com/helgesverre/dbdump/internal/spill"
+
+// maxFedLines is how many of the last lines sent to the client are kept.
+// The client stops at the first failing statement, so the line it names is
+// among those unless the pipe and the client's own buffer hold more.
+const maxFedLines = 1 << 16
+
+// minFedLines is the smallest ring worth keeping; below it errors are
+// reported where the scanner is
+const minFedLines = 64
+
+// fedLineSize is what a fedLine takes from the memory ceiling; table names
+// are shared with the scanner
+const fedLineSize = 32
+
+// fedLine maps a line sent to the client back to its position in the dump
+type fedLine struct {
+	offset int64
+	line   int
+	table  string
+}
+
+// fedLines remembers where the last lines sent to the client came from, in
+// a ring of fixed size, so that the line number in a client error can be
+// mapped back to a resumable offset without keeping a record of every line
+type fedLines struct {
+	pool     *spill.Pool
+	reserved int64
+	ring     []fedLine
+	preamble int    // lines of the replayed session preamble, sent first
+	sent     int    // dump lines sent after the preamble
+	evicted  string // table of the last line with one that left the ring
+}
+
+// newFedLines creates a ring keeping the last size lines, or fewer if the
+// pool can't hold them; release returns its memory
+func newFedLines(pool *spill.Pool, size int) *fedLines {
+	f := &fedLines{pool: pool}
+	for ; size >= minFedLines; size /= 2 {
+		if pool.Reserve(int64(size) * fedLineSize) {
+			f.reserved = int64(size) * fedLineSize
+			f.ring = make([]fedLine, 0, size)
+			break
+		}
+	}
+	return f
+}
+
+// release returns the ring's memory to the pool
+func (f *fedLines) release() {
+	f.pool.Release(f.reserved)
+	f.reserved = 0
+}
+
+// add records a line of the dump sent to the client
+func (f *fedLines) add(offset int64, line int) {
+	f.sent++
+	if cap(f.ring) == 0 {
+		return
+	}
+	entry := fedLine{offset: offset, line: line}
+	if len(f.ring) < cap(f.ring) {
+		f.ring = append(f.ring, entry)
+		return
+	}
+	i := (f.sent - 1) % cap(f.ring)
+	if f.ring[i].table != "" {
+		f.evicted = f.ring[i].table
+	}
+	f.ring[i] = entry
+}
+
+// setTable records that the last line sent starts the statements of table
+func (f *fedLines) setTable(table string) {
+	if len(f.ring) == 0 {
+		f.evicted = table
+		return
+	}
+	f.ring[(f.sent-1)%cap(f.ring)].table = table
+}
+
+// find returns where line n (counted from 1, as the client does) came from,
+// with the table it belongs to. Lines of the preamble have offset -1. It
+// reports false for lines never sent or no longer in the ring.
+func (f *fedLines) find(n int) (fedLine, bool) {
+	if n < 1 || n > f.preamble+f.sent {
+		return fedLine{}, false
+	}
+	if n <= f.preamble {
+		return fedLine{offset: -1}, true
+	}
+	k := n - f.preamble
+	oldest := f.sent - len(f.ring) // lines up to this one left the ring
+	if k <= oldest {
+		return fedLine{}, false
+	}
+
+	fed := f.ring[(k-1)%cap(f.ring)]
+	fed.table = f.evicted
+	for j := k; j > oldest; j-- {
+		if table := f.ring[(j-1)%cap(f.ring)].table; table != "" {
+			fed.table = table
+			break
+		}
+	}
+	return fed, true
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/spill"
+)
+
+// ring returns fedLines keeping the last size lines, whatever the ceiling
+func ring(size int) *fedLines {
+	return &fedLines{ring: make([]fedLine, 0, size)}
+}
+
+// feed sends lines 1..count of a dump whose line n starts at byte n*10, with
+// the tables starting at the given lines
+func feed(f *fedLines, count int, tables map[int]string) {
+	for line := 1; line <= count; line++ {
+		f.add(int64(line*10), line)
+		if table, ok := tables[line]; ok {
+			f.setTable(table)
+		}
+	}
+}
+
+func TestFedLines(t *testing.T) {
+	tables := map[int]string{3: "users", 8: "orders"}
+	tests := []struct {
+		name     string
+		size     int
+		preamble int
+		n        int
+		want     fedLine
+		ok       bool
+	}{
+		{name: "first line", size: 16, n: 1, want: fedLine{offset: 10, line: 1}, ok: true},
+		{name: "in a table", size: 16, n: 5, want: fedLine{offset: 50, line: 5, table: "users"}, ok: true},
+		{name: "the line starting a table", size: 16, n: 8, want: fedLine{offset: 80, line: 8, table: "orders"}, ok: true},
+		{name: "last line", size: 16, n: 10, want: fedLine{offset: 100, line: 10, table: "orders"}, ok: true},
+		{name: "never sent", size: 16, n: 11},
+		{name: "line zero", size: 16, n: 0},
+		{name: "preamble", size: 16, preamble: 2, n: 2, want: fedLine{offset: -1}, ok: true},
+		{name: "after the preamble", size: 16, preamble: 2, n: 7, want: fedLine{offset: 50, line: 5, table: "users"}, ok: true},
+		{name: "wrapped ring", size: 4, n: 9, want: fedLine{offset: 90, line: 9, table: "orders"}, ok: true},
+		{name: "table from a line that left the ring", size: 4, n: 7, want: fedLine{offset: 70, line: 7, table: "users"}, ok: true},
+		{name: "left the ring", size: 4, n: 6},
+		{name: "preamble with a wrapped ring", size: 4, preamble: 3, n: 3, want: fedLine{offset: -1}, ok: true},
+		{name: "no ring", size: 0, n: 10},
+		{name: "no ring, preamble", size: 0, preamble: 1, n: 1, want: fedLine{offset: -1}, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := ring(tt.size)
+			f.preamble = tt.preamble
+			feed(f, 10, tables)
+			got, ok := f.find(tt.n)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("find(%d) = %+v, %v; want %+v, %v", tt.n, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFedLinesBounded(t *testing.T) {
+	f := ring(8)
+	feed(f, 100000, map[int]string{1: "users", 99998: "orders"})
+	if len(f.ring) != 8 || cap(f.ring) != 8 {
+		t.Errorf("ring holds %d lines in %d, want 8", len(f.ring), cap(f.ring))
+	}
+	if got, ok := f.find(99999); !ok || got != (fedLine{offset: 999990, line: 99999, table: "orders"}) {
+		t.Errorf("find(99999) = %+v, %v", got, ok)
+	}
+	if got, ok := f.find(99995); !ok || got.table != "users" {
+		t.Errorf("find(99995) = %+v, %v; want the table of line 1", got, ok)
+	}
+}
+
+// TestFedLinesCeiling checks that the ring takes its memory from the pool,
+// shrinking under small ceilings down to none
+func TestFedLinesCeiling(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int64
+		want  int
+	}{
+		{name: "no limit", limit: 0, want: maxFedLines},
+		{name: "room for all", limit: maxFedLines * fedLineSize, want: maxFedLines},
+		{name: "room for some", limit: 100 * fedLineSize, want: 64},
+		{name: "no room", limit: minFedLines*fedLineSize - 1, want: 0},
+		{name: "tiny ceiling", limit: 1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := spill.NewPool(tt.limit, t.TempDir())
+			f := newFedLines(pool, maxFedLines)
+			if cap(f.ring) != tt.want {
+				t.Errorf("ring of %d lines, want %d", cap(f.ring), tt.want)
+			}
+
+			f.preamble = 1
+			feed(f, 200, map[int]string{1: "users"})
+			if fed, ok := f.find(1); !ok || fed.offset != -1 {
+				t.Errorf("find(1) = %+v, %v; want the preamble", fed, ok)
+			}
+			fed, ok := f.find(201)
+			if ok != (tt.want > 0) || (ok && fed != (fedLine{offset: 2000, line: 200, table: "users"})) {
+				t.Errorf("find(201) = %+v, %v", fed, ok)
+			}
+
+			f.release()
+			if tt.limit > 0 && !pool.Reserve(tt.limit) {
+				t.Error("the ring's memory wasn't returned to the pool")
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"regexp"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// maxFingerprintLine caps how much of a single line is buffered while fingerprinting
+const maxFingerprintLine = 64 * 1024
+
+var createTableStart = regexp.MustCompile("^CREATE TABLE `((?:[^`]|``)+)`")
+
+// autoIncrementClause is removed before hashing since it changes with every insert
+var autoIncrementClause = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+
+// SchemaFingerprinter is an io.Writer that observes structure-phase output and
+// computes a hash of each CREATE TABLE statement, so schema changes can be
+// detected without extra queries
+type SchemaFingerprinter struct {
+	tables  map[string]string
+	line    []byte
+	current string
+	hasher  hash.Hash
+
+	// statements holds each CREATE TABLE statement when capturing is enabled
+	statements map[string]string
+	statement  []byte
+	order      []string
+}
+
+// NewSchemaFingerprinter creates a new SchemaFingerprinter
+func NewSchemaFingerprinter() *SchemaFingerprinter {
+	return &SchemaFingerprinter{tables: make(map[string]string)}
+}
+
+// CaptureStatements makes the fingerprinter also keep each CREATE TABLE
+// statement, for callers that need the DDL itself (e.g. schema deltas)
+func (f *SchemaFingerprinter) CaptureStatements() *SchemaFingerprinter {
+	f.statements = make(map[string]string)
+	return f
+}
+
+// Write implements io.Writer
+func (f *SchemaFingerprinter) Write(p []byte) (int, error) {
+	data := p
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			f.buffer(data)
+			break
+		}
+		f.buffer(data[:i])
+		f.endLine()
+		data = data[i+1:]
+	}
+	return len(p), nil
+}
+
+// buffer appends to the current line, up to maxFingerprintLine bytes
+func (f *SchemaFingerprinter) buffer(data []byte) {
+	room := maxFingerprintLine - len(f.line)
+	if room <= 0 {
+		return
+	}
+	if len(data) > room {
+		data = data[:room]
+	}
+	f.line = append(f.line, data...)
+}
+
+// endLine processes a complete line
+func (f *SchemaFingerprinter) endLine() {
+	line := f.line
+	f.line = f.line[:0]
+
+	if f.current == "" {
+		match := createTableStart.FindSubmatch(line)
+		if match == nil {
+			return
+		}
+		f.current = sqlident.Unescape(string(match[1]))
+		f.hasher = sha256.New()
+	}
+
+	f.hasher.Write(autoIncrementClause.ReplaceAll(line, nil))
+	f.hasher.Write([]byte{'\n'})
+	if f.statements != nil {
+		f.statement = append(append(f.statement, line...), '\n')
+	}
+
+	if bytes.HasSuffix(bytes.TrimSpace(line), []byte(";")) {
+		f.tables[f.current] = hex.EncodeToString(f.hasher.Sum(nil))[:16]
+		if f.statements != nil {
+			if _, seen := f.statements[f.current]; !seen {
+				f.order = append(f.order, f.current)
+			}
+			f.statements[f.current] = string(f.statement)
+			f.statement = f.statement[:0]
+		}
+		f.current = ""
+	}
+}
+
+// Fingerprints returns the per-table schema hashes collected so far
+func (f *SchemaFingerprinter) Fingerprints() map[string]string {
+	return f.tables
+}
+
+// Statements returns the captured CREATE TABLE statements and the table names
+// in the order they appeared (nil unless CaptureStatements was called)
+func (f *SchemaFingerprinter) Statements() (map[string]string, []string) {
+	return f.statements, f.order
+}
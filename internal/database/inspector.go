@@ -1,8 +1,13 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
 )
 
 // TableInfo represents information about a table
@@ -13,22 +18,85 @@ type TableInfo struct {
 	IndexSize   int64
 	TotalSize   int64
 	SizeDisplay string
+	SizeUnknown bool   // row count and sizes could not be read (all zero)
+	Engine      string // storage engine, empty for views
+	Comment     string
+	CreateTime  time.Time // zero if unknown
+	UpdateTime  time.Time // zero if unknown (not tracked by all engines)
+
+	// Analyzed is set when ANALYZE TABLE refreshed the statistics the row
+	// count and sizes are estimated from just before they were read
+	Analyzed bool
+}
+
+// ColumnInfo describes a table column
+type ColumnInfo struct {
+	Name string
+	Type string
+
+	// DataType is the type without its length or attributes (e.g. varchar);
+	// MaxLength is the length of text columns in characters, 0 for others
+	DataType  string
+	Nullable  bool
+	MaxLength int64
+
+	// Invisible columns (MySQL 8.0.23+) are left out of SELECT *
+	Invisible bool
+
+	// Generated is "virtual" or "stored" for generated columns, with the
+	// expression computing them
+	Generated  string
+	Expression string
+
+	// HasDefault is set when the column has a DEFAULT other than NULL;
+	// DefaultExpression when that default is an expression (MySQL 8.0.13+)
+	HasDefault        bool
+	DefaultExpression bool
 }
 
 // Inspector handles database inspection operations
 type Inspector struct {
 	db *sql.DB
+
+	// ctx cancels every query the inspector runs
+	ctx context.Context
+
+	// metadataTimeout bounds the table information query (0 for no limit)
+	metadataTimeout time.Duration
+
+	// degraded explains why table information came from a fallback source
+	degraded string
+
+	// metadataSource selects where table information is read from, and
+	// source names the query it last came from
+	metadataSource MetadataSource
+	source         string
 }
 
 // NewInspector creates a new Inspector
 func NewInspector(db *sql.DB) *Inspector {
-	return &Inspector{db: db}
+	return &Inspector{db: db, metadataSource: MetadataAuto}
+}
+
+// WithContext sets the context every query runs under, so an interrupt
+// cancels inspection instead of waiting for the driver to time out
+func (i *Inspector) WithContext(ctx context.Context) *Inspector {
+	i.ctx = ctx
+	return i
+}
+
+// context returns the context queries run under
+func (i *Inspector) context() context.Context {
+	if i.ctx != nil {
+		return i.ctx
+	}
+	return context.Background()
 }
 
 // ListTables returns a list of all tables in the database
 func (i *Inspector) ListTables() ([]string, error) {
 	query := "SHOW TABLES"
-	rows, err := i.db.Query(query)
+	rows, err := i.db.QueryContext(i.context(), query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list tables: %w", err)
 	}
@@ -60,45 +128,60 @@ func (i *Inspector) GetTableInfo(tableName string) (*TableInfo, error) {
 			IFNULL(table_rows, 0) as row_count,
 			IFNULL(data_length, 0) as data_size,
 			IFNULL(index_length, 0) as index_size,
-			IFNULL(data_length + index_length, 0) as total_size
+			IFNULL(data_length + index_length, 0) as total_size,
+			IFNULL(engine, '') as engine,
+			IFNULL(table_comment, '') as comment,
+			create_time,
+			update_time
 		FROM information_schema.tables
 		WHERE table_schema = DATABASE()
 		AND table_name = ?
 	`
 
 	var info TableInfo
-	err := i.db.QueryRow(query, tableName).Scan(
+	var createTime, updateTime sql.NullTime
+	err := i.db.QueryRowContext(i.context(), query, tableName).Scan(
 		&info.Name,
 		&info.RowCount,
 		&info.DataSize,
 		&info.IndexSize,
 		&info.TotalSize,
+		&info.Engine,
+		&info.Comment,
+		&createTime,
+		&updateTime,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get table info: %w", err)
 	}
 
-	info.SizeDisplay = formatBytes(info.TotalSize)
+	info.SizeDisplay = FormatBytes(info.TotalSize)
+	info.CreateTime = createTime.Time
+	info.UpdateTime = updateTime.Time
 
 	return &info, nil
 }
 
-// GetAllTablesInfo retrieves information for all tables
-func (i *Inspector) GetAllTablesInfo() ([]TableInfo, error) {
+// tablesFromInformationSchema reads table information from information_schema.tables
+func (i *Inspector) tablesFromInformationSchema(ctx context.Context) ([]TableInfo, error) {
 	query := `
 		SELECT
 			table_name,
 			IFNULL(table_rows, 0) as row_count,
 			IFNULL(data_length, 0) as data_size,
 			IFNULL(index_length, 0) as index_size,
-			IFNULL(data_length + index_length, 0) as total_size
+			IFNULL(data_length + index_length, 0) as total_size,
+			IFNULL(engine, '') as engine,
+			IFNULL(table_comment, '') as comment,
+			create_time,
+			update_time
 		FROM information_schema.tables
 		WHERE table_schema = DATABASE()
 		ORDER BY total_size DESC
 	`
 
-	rows, err := i.db.Query(query)
+	rows, err := i.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tables info: %w", err)
 	}
@@ -109,17 +192,24 @@ func (i *Inspector) GetAllTablesInfo() ([]TableInfo, error) {
 	var tables []TableInfo
 	for rows.Next() {
 		var info TableInfo
+		var createTime, updateTime sql.NullTime
 		if err := rows.Scan(
 			&info.Name,
 			&info.RowCount,
 			&info.DataSize,
 			&info.IndexSize,
 			&info.TotalSize,
+			&info.Engine,
+			&info.Comment,
+			&createTime,
+			&updateTime,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan table info: %w", err)
 		}
 
-		info.SizeDisplay = formatBytes(info.TotalSize)
+		info.SizeDisplay = FormatBytes(info.TotalSize)
+		info.CreateTime = createTime.Time
+		info.UpdateTime = updateTime.Time
 		tables = append(tables, info)
 	}
 
@@ -130,8 +220,8 @@ func (i *Inspector) GetAllTablesInfo() ([]TableInfo, error) {
 	return tables, nil
 }
 
-// formatBytes formats byte size into human-readable format
-func formatBytes(bytes int64) string {
+// FormatBytes formats byte size into human-readable format
+func FormatBytes(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
 		return fmt.Sprintf("%d B", bytes)
@@ -150,3 +240,172 @@ func formatBytes(bytes int64) string {
 
 	return fmt.Sprintf("%.1f %s", float64(bytes)/float64(div), sizes[exp])
 }
+
+// GetColumns returns the columns of a table in definition order, including
+// invisible ones. Servers without generation_expression (before MySQL 5.7)
+// report no generated columns.
+func (i *Inspector) GetColumns(ctx context.Context, tableName string) ([]ColumnInfo, error) {
+	rows, err := i.db.QueryContext(ctx, `
+		SELECT column_name, column_type, data_type, is_nullable = 'YES',
+			IFNULL(character_maximum_length, 0), extra, IFNULL(generation_expression, ''),
+			column_default IS NOT NULL
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE()
+		AND table_name = ?
+		ORDER BY ordinal_position
+	`, tableName)
+	if unknownColumn(err) {
+		rows, err = i.db.QueryContext(ctx, `
+			SELECT column_name, column_type, data_type, is_nullable = 'YES',
+				IFNULL(character_maximum_length, 0), extra, '', column_default IS NOT NULL
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE()
+			AND table_name = ?
+			ORDER BY ordinal_position
+		`, tableName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns of %s: %w", tableName, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var columns []ColumnInfo
+	for rows.Next() {
+		var col ColumnInfo
+		var extra string
+		if err := rows.Scan(&col.Name, &col.Type, &col.DataType, &col.Nullable, &col.MaxLength, &extra, &col.Expression, &col.HasDefault); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		extra = strings.ToUpper(extra)
+		col.DataType = strings.ToLower(col.DataType)
+		col.Invisible = strings.Contains(extra, "INVISIBLE")
+		col.DefaultExpression = strings.Contains(extra, "DEFAULT_GENERATED")
+		switch {
+		case strings.Contains(extra, "VIRTUAL GENERATED"):
+			col.Generated = "virtual"
+		case strings.Contains(extra, "STORED GENERATED"):
+			col.Generated = "stored"
+		default:
+			// DEFAULT_GENERATED marks expression defaults, not generated columns
+			col.Expression = ""
+		}
+		columns = append(columns, col)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating columns: %w", err)
+	}
+
+	return columns, nil
+}
+
+// GetCreateTable returns the current CREATE statement of a table or view
+func (i *Inspector) GetCreateTable(tableName string) (string, error) {
+	quoted := sqlident.Quote(tableName)
+	rows, err := i.db.QueryContext(i.context(), "SHOW CREATE TABLE "+quoted)
+	if err != nil {
+		return "", fmt.Errorf("failed to get definition of %s: %w", tableName, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// Tables return (name, statement); views add charset columns
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", fmt.Errorf("failed to get definition of %s: %w", tableName, err)
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", fmt.Errorf("failed to get definition of %s: %w", tableName, err)
+		}
+		return "", fmt.Errorf("failed to get definition of %s: no rows returned", tableName)
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]any, len(columns))
+	for n := range values {
+		dest[n] = &values[n]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return "", fmt.Errorf("failed to scan definition of %s: %w", tableName, err)
+	}
+	if len(values) < 2 {
+		return "", fmt.Errorf("failed to get definition of %s: unexpected result", tableName)
+	}
+	return string(values[1]), nil
+}
+
+// GetServerVersion returns the server version string (e.g. "8.0.35" or "10.11.6-MariaDB")
+func (i *Inspector) GetServerVersion() (string, error) {
+	var version string
+	if err := i.db.QueryRowContext(i.context(), "SELECT VERSION()").Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to get server version: %w", err)
+	}
+	return version, nil
+}
+
+// AnalyzeTable refreshes a table's statistics with ANALYZE TABLE. Failures
+// reported in the result (e.g. an unsupported engine) are returned as errors.
+func (i *Inspector) AnalyzeTable(ctx context.Context, tableName string) error {
+	quoted := sqlident.Quote(tableName)
+	rows, err := i.db.QueryContext(ctx, "ANALYZE NO_WRITE_TO_BINLOG TABLE "+quoted)
+	if err != nil {
+		return fmt.Errorf("failed to analyze %s: %w", tableName, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// One row per message: Table, Op, Msg_type, Msg_text
+	for rows.Next() {
+		var table, op, msgType, msgText string
+		if err := rows.Scan(&table, &op, &msgType, &msgText); err != nil {
+			return fmt.Errorf("failed to analyze %s: %w", tableName, err)
+		}
+		if strings.EqualFold(msgType, "error") {
+			return fmt.Errorf("failed to analyze %s: %s", tableName, msgText)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to analyze %s: %w", tableName, err)
+	}
+	return nil
+}
+
+// ChecksumTable returns the exact row count and CHECKSUM TABLE value for a table
+func (i *Inspector) ChecksumTable(tableName string) (rowCount int64, checksum int64, err error) {
+	quoted := sqlident.Quote(tableName)
+
+	var name string
+	var sum sql.NullInt64
+	if err := i.db.QueryRowContext(i.context(), "CHECKSUM TABLE "+quoted).Scan(&name, &sum); err != nil {
+		return 0, 0, fmt.Errorf("failed to checksum table %s: %w", tableName, err)
+	}
+
+	if err := i.db.QueryRowContext(i.context(), "SELECT COUNT(*) FROM "+quoted).Scan(&rowCount); err != nil {
+		return 0, 0, fmt.Errorf("failed to count rows in %s: %w", tableName, err)
+	}
+
+	return rowCount, sum.Int64, nil
+}
+
+// CountRows returns the exact row count of a table
+func (i *Inspector) CountRows(tableName string) (int64, error) {
+	var rowCount int64
+	if err := i.db.QueryRowContext(i.context(), "SELECT COUNT(*) FROM "+sqlident.Quote(tableName)).Scan(&rowCount); err != nil {
+		return 0, fmt.Errorf("failed to count rows in %s: %w", tableName, err)
+	}
+	return rowCount, nil
+}
+
+// GetNameCase reads lower_case_table_names, which decides whether the
+// server tells table names differing only by case apart
+func (i *Inspector) GetNameCase() (sqlident.Case, error) {
+	var value int
+	if err := i.db.QueryRowContext(i.context(), "SELECT @@lower_case_table_names").Scan(&value); err != nil {
+		return sqlident.CaseSensitive, fmt.Errorf("failed to read lower_case_table_names: %w", err)
+	}
+	return sqlident.Case(value), nil
+}
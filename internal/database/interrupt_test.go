@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+// TestDumpInterrupted cancels a dump while mysqldump hangs in each phase
+// and checks that the dump stops promptly, the mysqldump process is gone
+// and the incomplete output is removed, or kept for --resume once a phase
+// has completed
+func TestDumpInterrupted(t *testing.T) {
+	tests := []struct {
+		phase string
+		kept  []string
+	}{
+		{phase: "structure"},
+		{phase: "data", kept: []string{"shop.sql.resume"}},
+		{phase: "objects", kept: []string{"shop.sql.resume"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phase, func(t *testing.T) {
+			dir := installFakeMySQLDump(t)
+			t.Setenv("FAKE_MYSQLDUMP_HANG", tt.phase)
+			out := t.TempDir()
+			pidFile := filepath.Join(dir, tt.phase+".pid")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				for ctx.Err() == nil {
+					if _, err := os.Stat(pidFile); err == nil {
+						cancel()
+						return
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+			}()
+
+			start := time.Now()
+			dumper := interruptibleDump(ctx, filepath.Join(out, "shop.sql"))
+			_, err := dumper.Dump()
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("the dump took %v to stop", elapsed)
+			}
+			if !errors.Is(err, dberrors.ErrDumpInterrupted) {
+				t.Fatalf("Dump = %v, want an interruption", err)
+			}
+
+			data, err := os.ReadFile(pidFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if processRunning(pid) {
+				t.Errorf("mysqldump (pid %d) is still running", pid)
+			}
+			checkFiles(t, out, tt.kept...)
+			if _, kept := dumper.Resumable(); (kept != "") != (len(tt.kept) > 0) {
+				t.Errorf("Resumable() = %q", kept)
+			}
+		})
+	}
+}
+
+// TestDumpInterruptedBeforeStart checks that a dump whose context is
+// already cancelled starts no mysqldump and writes nothing
+func TestDumpInterruptedBeforeStart(t *testing.T) {
+	dir := installFakeMySQLDump(t)
+	t.Setenv("FAKE_MYSQLDUMP_HANG", "structure")
+	out := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := interruptibleDump(ctx, filepath.Join(out, "shop.sql")).Dump()
+	if !errors.Is(err, dberrors.ErrDumpInterrupted) {
+		t.Fatalf("Dump = %v, want an interruption", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "structure.pid")); !os.IsNotExist(err) {
+		t.Errorf("mysqldump was started: %v", err)
+	}
+	checkFiles(t, out)
+}
+
+// interruptibleDump returns a dumper of the fake database that stops when
+// ctx is done
+func interruptibleDump(ctx context.Context, output string) *Dumper {
+	return NewDumper(&DumpOptions{
+		Connection: &Connection{Host: "db", Port: 3306, User: "app", Database: "shop"},
+		OutputFile: output,
+		Context:    ctx,
+	})
+}
+
+// checkFiles fails unless dir holds exactly the named files: no leftover
+// dump or temporary file
+func checkFiles(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("%s holds %q, want %q", dir, names, want)
+	}
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"fmt"
+)
+
+// TableIO counts the row operations on a table recorded by performance_schema
+type TableIO struct {
+	Reads  int64 // rows fetched
+	Writes int64 // rows inserted, updated or deleted
+}
+
+// GetTableIOStats reads per-table row operation counts from
+// performance_schema.table_io_waits_summary_by_table. It fails without
+// SELECT on performance_schema, and the map is empty when performance_schema
+// is disabled or has recorded nothing for the database.
+func (i *Inspector) GetTableIOStats() (map[string]TableIO, error) {
+	rows, err := i.db.QueryContext(i.context(), `
+		SELECT object_name, count_fetch, count_insert + count_update + count_delete
+		FROM performance_schema.table_io_waits_summary_by_table
+		WHERE object_schema = DATABASE() AND object_type = 'TABLE'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read performance_schema IO statistics: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	stats := make(map[string]TableIO)
+	for rows.Next() {
+		var name string
+		var io TableIO
+		if err := rows.Scan(&name, &io.Reads, &io.Writes); err != nil {
+			return nil, fmt.Errorf("failed to scan IO statistics: %w", err)
+		}
+		stats[name] = io
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating IO statistics: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/spill"
+)
+
+// TableSize is the data a table contributed to a dump
+type TableSize struct {
+	Table     string
+	Bytes     int64 // bytes of INSERT statements written
+	Rows      int64 // rows written
+	Truncated bool  // rows beyond the size limit were left out
+}
+
+// tableLimiter drops a table's INSERT statements once its data would grow
+// beyond the limit, so tables are cut off at a statement boundary
+type tableLimiter struct {
+	limit int64
+	sizes map[string]*TableSize
+	order []string
+}
+
+// newTableLimiter creates a limiter allowing limit bytes of data per table
+func newTableLimiter(limit int64) *tableLimiter {
+	return &tableLimiter{limit: limit, sizes: make(map[string]*TableSize)}
+}
+
+// transform is the limiter's transform.Func
+func (l *tableLimiter) transform(statement []byte) []byte {
+	if !bytes.HasPrefix(statement, []byte("INSERT INTO ")) {
+		return statement
+	}
+	table, ok := dumpfile.StatementTable(statement)
+	if !ok {
+		return statement
+	}
+
+	size := l.admit(table, int64(len(statement)))
+	if size == nil {
+		return nil
+	}
+	size.Rows += dumpfile.CountRows(statement)
+	return statement
+}
+
+// spilled is the limiter's transform.SpillFunc, counting statements too
+// large to hold in memory from disk
+func (l *tableLimiter) spilled(statement *spill.Buffer) (bool, error) {
+	head := statement.Head()
+	if !bytes.HasPrefix(head, []byte("INSERT INTO ")) {
+		return true, nil
+	}
+	table, ok := dumpfile.StatementTable(head)
+	if !ok {
+		return true, nil
+	}
+
+	size := l.admit(table, statement.Len())
+	if size == nil {
+		return false, nil
+	}
+	rows, err := dumpfile.CountRowsFrom(statement.Reader())
+	if err != nil {
+		return false, fmt.Errorf("failed to read spilled statement: %w", err)
+	}
+	size.Rows += rows
+	return true, nil
+}
+
+// admit adds a statement of n bytes to its table's size and returns it, or
+// returns nil if the table is over the limit
+func (l *tableLimiter) admit(table string, n int64) *TableSize {
+	size := l.sizes[table]
+	if size == nil {
+		size = &TableSize{Table: table}
+		l.sizes[table] = size
+		l.order = append(l.order, table)
+	}
+	if size.Truncated || size.Bytes+n > l.limit {
+		size.Truncated = true
+		return nil
+	}
+	size.Bytes += n
+	return size
+}
+
+// tableSizes returns the size of every table with data, largest first
+func (l *tableLimiter) tableSizes() []TableSize {
+	sizes := make([]TableSize, 0, len(l.order))
+	for _, table := range l.order {
+		sizes = append(sizes, *l.sizes[table])
+	}
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return sizes[i].Bytes > sizes[j].Bytes
+	})
+	return sizes
+}
@@ -0,0 +1,559 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// Masking strategies; any other mask value is written as a literal
+const (
+	MaskNull      = "null"       // NULL (the column must be nullable)
+	MaskFakeEmail = "fake_email" // user_<hash>@example.invalid
+	MaskFakeName  = "fake_name"  // a first name picked by the value's hash
+	MaskHash      = "hash"       // the value's SHA-256, in hex
+)
+
+// fakeNames are the names fake_name picks from
+var fakeNames = []string{
+	"Alex", "Bobbie", "Casey", "Dana", "Eli", "Frankie", "Gale", "Harper",
+	"Indy", "Jesse", "Kai", "Lee", "Morgan", "Noa", "Oakley", "Parker",
+	"Quinn", "Riley", "Sam", "Taylor", "Uma", "Val", "Wren", "Yael",
+}
+
+// fakeEmailLength is the length of the addresses fake_email writes:
+// "user_", 12 hex digits of the hash and "@example.invalid"
+const fakeEmailLength = 33
+
+// maskedRowsPerStatement caps the rows of one INSERT; statements are also
+// cut at maskedStatementSize bytes, like mysqldump's --net-buffer-length
+const (
+	maskedRowsPerStatement = 1000
+	maskedStatementSize    = 1 << 20
+)
+
+// MaskedColumn is a column dumped by the masking path; an empty Mask keeps
+// the value as it is, unless the column is in null_columns and has a
+// Placeholder
+type MaskedColumn struct {
+	ColumnInfo
+	Mask        string
+	Placeholder *Placeholder
+}
+
+// Placeholder is what a column of null_columns is dumped as instead of its
+// values: a configured literal, the column's DEFAULT, or NULL
+type Placeholder struct {
+	Literal *string
+	Default bool
+}
+
+// String returns the placeholder as it is written in the dump
+func (p Placeholder) String() string {
+	switch {
+	case p.Literal != nil:
+		return quoteString(*p.Literal)
+	case p.Default:
+		return "DEFAULT"
+	}
+	return "NULL"
+}
+
+// MaskedTable is a table whose data dbdump reads itself, replacing the
+// values of masked columns, instead of leaving it to mysqldump
+type MaskedTable struct {
+	Table   string
+	Columns []MaskedColumn
+
+	// Sample, if set, limits the dump to the table's last rows
+	Sample *TableSample
+
+	// Range, if set, limits the dump to the rows of an append-only table
+	// past one watermark and up to the next
+	Range *AppendRange
+}
+
+// NewMaskedTable checks masks (column name to strategy or literal) and
+// nulls (column name to a literal placeholder, or nil) against a table's
+// columns, returning the problems found with them
+func NewMaskedTable(table string, columns []ColumnInfo, masks map[string]string, nulls map[string]*string) (MaskedTable, []string) {
+	masked := MaskedTable{Table: table}
+	var problems []string
+
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col.Name] = true
+		mask, ok := masks[col.Name]
+		if literal, nulled := nulls[col.Name]; nulled {
+			if ok {
+				// Each column is replaced once: a mask on top of a placeholder
+				// would mask the placeholder
+				problems = append(problems, fmt.Sprintf("%s.%s is both masked and in null_columns; keep one", table, col.Name))
+				continue
+			}
+			placeholder, problem := newPlaceholder(table, col, literal)
+			if problem != "" {
+				problems = append(problems, problem)
+				continue
+			}
+			masked.Columns = append(masked.Columns, MaskedColumn{ColumnInfo: col, Placeholder: placeholder})
+			continue
+		}
+		if !ok {
+			if col.Generated == "" {
+				masked.Columns = append(masked.Columns, MaskedColumn{ColumnInfo: col})
+			}
+			continue
+		}
+
+		name := table + "." + col.Name
+		switch {
+		case col.Generated != "":
+			problems = append(problems, fmt.Sprintf("%s is a generated column and can't be masked (mask the columns it is computed from)", name))
+		case mask == MaskNull && !col.Nullable:
+			problems = append(problems, fmt.Sprintf("%s is NOT NULL and can't be masked with null", name))
+		case (mask == MaskHash || mask == MaskFakeEmail || mask == MaskFakeName) && !textType(col.DataType):
+			problems = append(problems, fmt.Sprintf("%s is %s; %s needs a text column", name, col.DataType, mask))
+		case mask == MaskFakeEmail && col.MaxLength > 0 && col.MaxLength < fakeEmailLength:
+			problems = append(problems, fmt.Sprintf("%s holds at most %d characters; fake_email needs %d", name, col.MaxLength, fakeEmailLength))
+		case mask != MaskNull && mask != MaskHash && mask != MaskFakeEmail && mask != MaskFakeName &&
+			col.MaxLength > 0 && int64(len([]rune(mask))) > col.MaxLength:
+			problems = append(problems, fmt.Sprintf("%s holds at most %d characters; the literal %q is longer", name, col.MaxLength, mask))
+		}
+		masked.Columns = append(masked.Columns, MaskedColumn{ColumnInfo: col, Mask: mask})
+	}
+
+	for _, column := range slices.Sorted(maps.Keys(masks)) {
+		if !known[column] {
+			problems = append(problems, fmt.Sprintf("%s.%s: no such column", table, column))
+		}
+	}
+	for _, column := range slices.Sorted(maps.Keys(nulls)) {
+		if !known[column] {
+			problems = append(problems, fmt.Sprintf("%s.%s: no such column in null_columns", table, column))
+		}
+	}
+	return masked, problems
+}
+
+// newPlaceholder picks what a column of null_columns is dumped as: the
+// literal if one is configured, else NULL, else the column's default.
+// NOT NULL columns without a literal default need a literal.
+func newPlaceholder(table string, col ColumnInfo, literal *string) (*Placeholder, string) {
+	name := table + "." + col.Name
+	switch {
+	case col.Generated != "":
+		return nil, fmt.Sprintf("%s is a generated column and is computed on restore; leave it out of null_columns", name)
+	case literal != nil:
+		if col.MaxLength > 0 && int64(len([]rune(*literal))) > col.MaxLength {
+			return nil, fmt.Sprintf("%s holds at most %d characters; the placeholder %q is longer", name, col.MaxLength, *literal)
+		}
+		return &Placeholder{Literal: literal}, ""
+	case col.Nullable:
+		return &Placeholder{}, ""
+	case col.HasDefault && !col.DefaultExpression:
+		return &Placeholder{Default: true}, ""
+	case col.HasDefault:
+		return nil, fmt.Sprintf("%s is NOT NULL and its default is an expression; give it a literal placeholder in null_columns", name)
+	}
+	return nil, fmt.Sprintf("%s is NOT NULL without a default; give it a literal placeholder in null_columns", name)
+}
+
+// textType reports whether a column type holds text the masks can replace
+func textType(dataType string) bool {
+	switch dataType {
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext":
+		return true
+	}
+	return false
+}
+
+// binaryType reports whether values of a column type are written in hex,
+// as mysqldump does with --hex-blob
+func binaryType(dataType string) bool {
+	switch dataType {
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "bit",
+		"geometry", "point", "linestring", "polygon", "multipoint", "multilinestring",
+		"multipolygon", "geometrycollection", "geomcollection":
+		return true
+	}
+	return false
+}
+
+// temporalType reports whether the driver parses values of a column type
+// into time.Time
+func temporalType(dataType string) bool {
+	switch dataType {
+	case "date", "datetime", "timestamp":
+		return true
+	}
+	return false
+}
+
+// numericType reports whether values of a column type are written unquoted
+func numericType(dataType string) bool {
+	switch dataType {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint",
+		"decimal", "numeric", "float", "double", "real":
+		return true
+	}
+	return false
+}
+
+// MaskedColumnNames returns the names of the masked columns
+func (t MaskedTable) MaskedColumnNames() []string {
+	var names []string
+	for _, col := range t.Columns {
+		if col.Mask != "" {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
+
+// NulledColumns maps the table's columns of null_columns to their
+// placeholders
+func (t MaskedTable) NulledColumns() map[string]string {
+	var nulled map[string]string
+	for _, col := range t.Columns {
+		if col.Placeholder != nil {
+			if nulled == nil {
+				nulled = make(map[string]string)
+			}
+			nulled[col.Name] = col.Placeholder.String()
+		}
+	}
+	return nulled
+}
+
+// describe lists the replaced columns for the comment above the data
+func (t MaskedTable) describe() string {
+	names := t.MaskedColumnNames()
+	for _, col := range t.Columns {
+		if col.Placeholder != nil {
+			names = append(names, col.Name+" as "+col.Placeholder.String())
+		}
+	}
+	if t.Range != nil {
+		names = append(names, "rows with "+t.Range.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// expression returns the SELECT expression producing a column's dumped value
+func (c MaskedColumn) expression() string {
+	name := sqlident.Quote(c.Name)
+	hash := "SHA2(" + name + ", 256)"
+	limit := func(expr string) string {
+		if c.MaxLength > 0 {
+			return fmt.Sprintf("LEFT(%s, %d)", expr, c.MaxLength)
+		}
+		return expr
+	}
+
+	if p := c.Placeholder; p != nil {
+		switch {
+		case p.Literal != nil:
+			return quoteString(*p.Literal)
+		case p.Default:
+			return c.raw("DEFAULT(" + name + ")")
+		}
+		return "NULL"
+	}
+
+	switch c.Mask {
+	case "":
+		return c.raw(name)
+	case MaskNull:
+		return "NULL"
+	case MaskHash:
+		return limit(hash)
+	case MaskFakeEmail:
+		return "CONCAT('user_', LEFT(" + hash + ", 12), '@example.invalid')"
+	case MaskFakeName:
+		quoted := make([]string, len(fakeNames))
+		for i, fake := range fakeNames {
+			quoted[i] = quoteString(fake)
+		}
+		return fmt.Sprintf("ELT(1 + CONV(LEFT(%s, 8), 16, 10) %% %d, %s)", hash, len(fakeNames), strings.Join(quoted, ", "))
+	}
+	// A literal keeps NULLs, so optional values stay optional
+	return fmt.Sprintf("IF(%s IS NULL, NULL, %s)", name, quoteString(c.Mask))
+}
+
+// raw returns the SELECT expression reading a value of the column's type
+// unchanged
+func (c MaskedColumn) raw(expr string) string {
+	switch {
+	case binaryType(c.DataType):
+		return "HEX(" + expr + ")"
+	case temporalType(c.DataType):
+		// As text, so the driver doesn't parse it (zero dates included)
+		return "CAST(" + expr + " AS CHAR)"
+	}
+	return expr
+}
+
+// literal writes a value returned by expression as SQL
+func (c MaskedColumn) literal(value sql.RawBytes) string {
+	switch {
+	case value == nil:
+		return "NULL"
+	case c.Placeholder != nil && c.Placeholder.Literal != nil:
+		return quoteString(string(value))
+	case c.Mask == "" && binaryType(c.DataType):
+		if len(value) == 0 {
+			return "''"
+		}
+		return "0x" + string(value)
+	case c.Mask == "" && numericType(c.DataType):
+		return string(value)
+	}
+	return quoteString(string(value))
+}
+
+// query returns the SELECT reading the table's dumped values
+func (t MaskedTable) query() string {
+	exprs := make([]string, len(t.Columns))
+	for i, col := range t.Columns {
+		exprs[i] = col.expression()
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(exprs, ", "), sqlident.Quote(t.Table))
+	switch {
+	case t.Range != nil && t.Sample != nil:
+		query += " WHERE " + t.Range.where() + " AND " + t.Sample.where()
+	case t.Range != nil:
+		query += " WHERE " + t.Range.where()
+	case t.Sample != nil:
+		query += " WHERE " + t.Sample.where()
+	}
+	return query
+}
+
+// insertPrefix returns the start of the table's INSERT statements
+func (t MaskedTable) insertPrefix() string {
+	names := make([]string, len(t.Columns))
+	for i, col := range t.Columns {
+		names[i] = sqlident.Quote(col.Name)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES ", sqlident.Quote(t.Table), strings.Join(names, ","))
+}
+
+// quoteString quotes a string literal, escaping it as mysqldump does
+func quoteString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '"':
+			b.WriteString(`\"`)
+		case 0x1a:
+			b.WriteString(`\Z`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// sessionHeader and sessionFooter surround the masked data and native
+// phases like mysqldump surrounds its own: foreign keys and unique checks
+// are off while the rows load, and TIMESTAMP values are in UTC
+const (
+	sessionHeader = `
+/*!40101 SET NAMES utf8mb4 */;
+/*!40103 SET @OLD_TIME_ZONE=@@TIME_ZONE */;
+/*!40103 SET TIME_ZONE='+00:00' */;
+/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */;
+/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;
+/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;
+`
+	sessionFooter = `
+/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
+/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;
+/*!40014 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS */;
+/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
+`
+)
+
+// dumpMasked writes the data of the masked tables, read over its own
+// connection rather than by mysqldump. The connection is opened like a
+// native phase's, in UTC and one consistent snapshot, so the masked tables
+// agree with each other as mysqldump's tables do.
+func (d *Dumper) dumpMasked(writer io.Writer) error {
+	ctx := d.context()
+
+	session, err := d.openNative(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	out := bufio.NewWriterSize(writer, 256*1024)
+	if _, err := io.WriteString(out, sessionHeader); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	for _, table := range d.options.Masked {
+		if err := writeMaskedTable(ctx, session.conn, out, table); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(out, sessionFooter); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// writeMaskedTable writes one table's masked rows as extended INSERTs
+func writeMaskedTable(ctx context.Context, conn *sql.Conn, out *bufio.Writer, table MaskedTable) error {
+	rows, err := conn.QueryContext(ctx, table.query())
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: masked data of %s: %w", dberrors.ErrDumpInterrupted, table.Table, err)
+		}
+		return fmt.Errorf("failed to read %s for masking: %w", table.Table, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	name := sqlident.Quote(table.Table)
+	description := "Masked data"
+	switch {
+	case table.Sample != nil:
+		description = "Masked sample (last " + strconv.Itoa(table.Sample.Rows) + " rows)"
+	case table.Range != nil && len(table.MaskedColumnNames()) == 0 && table.NulledColumns() == nil:
+		description = "Data"
+	}
+	fmt.Fprintf(out, "\n-- %s of %s: %s\n", description, table.Table, table.describe())
+	fmt.Fprintf(out, "LOCK TABLES %s WRITE;\n/*!40000 ALTER TABLE %s DISABLE KEYS */;\n", name, name)
+
+	inserts := newInsertWriter(out, table, nil)
+	_, err = inserts.copy(rows, func(err error) error {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: masked data of %s: %w", dberrors.ErrDumpInterrupted, table.Table, err)
+		}
+		return fmt.Errorf("failed to read %s for masking: %w", table.Table, err)
+	})
+	if err != nil {
+		return err
+	}
+	if err := inserts.flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "/*!40000 ALTER TABLE %s ENABLE KEYS */;\nUNLOCK TABLES;\n", name)
+	return nil
+}
+
+// insertWriter writes rows read with a table's query as extended INSERTs,
+// starting a new statement every maskedRowsPerStatement rows or
+// maskedStatementSize bytes
+type insertWriter struct {
+	out       io.Writer
+	table     MaskedTable
+	prefix    string
+	statement strings.Builder
+	rows      int
+
+	// key lists the columns (in key order) whose literals of the last row
+	// are kept in last
+	key      []int
+	last     []string
+	literals []string
+}
+
+// newInsertWriter creates an insertWriter keeping the values of the key columns
+func newInsertWriter(out io.Writer, table MaskedTable, key []int) *insertWriter {
+	return &insertWriter{out: out, table: table, prefix: table.insertPrefix(), key: key, last: make([]string, len(key)), literals: make([]string, len(table.Columns))}
+}
+
+// copy writes the rows of a query result, returning how many there were;
+// failures to read them are wrapped by readErr
+func (w *insertWriter) copy(rows *sql.Rows, readErr func(error) error) (int, error) {
+	values := make([]sql.RawBytes, len(w.table.Columns))
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return count, readErr(err)
+		}
+		if err := w.row(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, readErr(err)
+	}
+	return count, nil
+}
+
+// row adds a row to the current statement
+func (w *insertWriter) row(values []sql.RawBytes) error {
+	if w.rows == 0 {
+		w.statement.WriteString(w.prefix)
+	} else {
+		w.statement.WriteByte(',')
+	}
+	w.statement.WriteByte('(')
+	for i, col := range w.table.Columns {
+		if i > 0 {
+			w.statement.WriteByte(',')
+		}
+		w.literals[i] = col.literal(values[i])
+		w.statement.WriteString(w.literals[i])
+	}
+	w.statement.WriteByte(')')
+	for k, i := range w.key {
+		w.last[k] = w.literals[i]
+	}
+	w.rows++
+	if w.rows >= maskedRowsPerStatement || w.statement.Len() >= maskedStatementSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush writes the current statement, if it has any rows
+func (w *insertWriter) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	w.statement.WriteString(";\n")
+	_, err := io.WriteString(w.out, w.statement.String())
+	w.statement.Reset()
+	w.rows = 0
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MetadataSource selects where table information is read from
+type MetadataSource string
+
+// Metadata sources
+const (
+	// MetadataAuto reads information_schema and checks it against SHOW
+	// TABLES, switching to SHOW statements when the two disagree
+	MetadataAuto MetadataSource = "auto"
+
+	// MetadataInformationSchema reads information_schema, falling back to
+	// SHOW statements only when it is too slow or not accessible
+	MetadataInformationSchema MetadataSource = "information_schema"
+
+	// MetadataShow reads SHOW FULL TABLES and SHOW TABLE STATUS, for proxies
+	// (ProxySQL, Vitess) whose information_schema is scoped wrongly
+	MetadataShow MetadataSource = "show"
+)
+
+// Names of the queries table information can come from, as reported by Source
+const (
+	sourceInformationSchema = "information_schema"
+	sourceTableStatus       = "SHOW TABLE STATUS"
+	sourceShowTables        = "SHOW FULL TABLES"
+)
+
+// ParseMetadataSource validates a --metadata-source value
+func ParseMetadataSource(value string) (MetadataSource, error) {
+	switch source := MetadataSource(value); source {
+	case MetadataAuto, MetadataInformationSchema, MetadataShow:
+		return source, nil
+	}
+	return "", fmt.Errorf("unknown metadata source %q (use auto, information_schema or show)", value)
+}
+
+// WithMetadataSource selects where table information is read from
+func (i *Inspector) WithMetadataSource(source MetadataSource) *Inspector {
+	i.metadataSource = source
+	return i
+}
+
+// Source names the query the last GetAllTablesInfo call took table
+// information from
+func (i *Inspector) Source() string {
+	return i.source
+}
+
+// checkAgainstShowTables compares information_schema's table list with SHOW
+// FULL TABLES and returns why it can't be trusted, or "" when they agree
+func (i *Inspector) checkAgainstShowTables(tables []TableInfo) (string, error) {
+	listed, err := i.tablesFromShowTables(i.context())
+	if err != nil {
+		return "", err
+	}
+
+	if len(tables) == 0 && len(listed) > 0 {
+		return fmt.Sprintf("information_schema listed no tables but SHOW TABLES lists %d", len(listed)), nil
+	}
+	known := make(map[string]bool, len(tables))
+	for _, info := range tables {
+		known[info.Name] = true
+	}
+	matched := 0
+	for _, info := range listed {
+		if known[info.Name] {
+			matched++
+		}
+	}
+	if matched != len(listed) || len(tables) != len(listed) {
+		return fmt.Sprintf("information_schema listed %d tables but SHOW TABLES lists %d (%d in common)",
+			len(tables), len(listed), matched), nil
+	}
+	return "", nil
+}
+
+// tablesFromShowStatements reads the table list with SHOW FULL TABLES and
+// sizes with SHOW TABLE STATUS. Tables missing from the status output, or
+// all tables when SHOW TABLE STATUS fails, are kept with sizes unknown.
+func (i *Inspector) tablesFromShowStatements() ([]TableInfo, error) {
+	listed, err := i.tablesFromShowTables(i.context())
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := i.withTimeout(i.tablesFromTableStatus)
+	if err != nil {
+		if !shouldFallBack(err) {
+			return nil, err
+		}
+		i.source = sourceShowTables
+		i.degraded = "SHOW TABLE STATUS " + fallbackReason(err, i.metadataTimeout) + "; sizes and row counts are unavailable"
+		return listed, nil
+	}
+
+	byName := make(map[string]TableInfo, len(status))
+	for _, info := range status {
+		byName[info.Name] = info
+	}
+	tables := make([]TableInfo, 0, len(listed))
+	for _, info := range listed {
+		if withSize, ok := byName[info.Name]; ok {
+			info = withSize
+		}
+		tables = append(tables, info)
+	}
+	sort.SliceStable(tables, func(a, b int) bool {
+		if tables[a].SizeUnknown != tables[b].SizeUnknown {
+			return !tables[a].SizeUnknown
+		}
+		return tables[a].TotalSize > tables[b].TotalSize
+	})
+
+	i.source = sourceShowTables + " + " + sourceTableStatus
+	return tables, nil
+}
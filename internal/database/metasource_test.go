@@ -0,0 +1,160 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestParseMetadataSource(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    MetadataSource
+		wantErr bool
+	}{
+		{value: "auto", want: MetadataAuto},
+		{value: "information_schema", want: MetadataInformationSchema},
+		{value: "show", want: MetadataShow},
+		{value: "SHOW", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMetadataSource(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMetadataSource(%q) = %q, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestMetadataSource(t *testing.T) {
+	// What a proxy scoping information_schema to another schema answers
+	wrongSchema := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"table_name", "row_count", "data_size", "index_size", "total_size", "engine", "comment", "create_time", "update_time"}).
+			AddRow("proxy_config", 3, 16<<10, 0, 16<<10, "InnoDB", "", nil, nil)
+	}
+	noTables := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"table_name", "row_count", "data_size", "index_size", "total_size", "engine", "comment", "create_time", "update_time"})
+	}
+	// SHOW TABLE STATUS without the view, as some proxies answer it
+	partialStatus := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"Name", "Engine", "Rows", "Data_length", "Index_length", "Comment"}).
+			AddRow("users", "InnoDB", "120", "65536", "16384", "").
+			AddRow("orders", "InnoDB", "5000", "4194304", "1048576", "")
+	}
+
+	tests := []struct {
+		name     string
+		source   MetadataSource
+		expect   func(mock sqlmock.Sqlmock)
+		tables   []string
+		unknown  []string
+		used     string
+		degraded string
+	}{
+		{
+			name:   "auto, sources agree",
+			source: MetadataAuto,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillReturnRows(informationSchemaRows())
+				mock.ExpectQuery(showTablesQuery).WillReturnRows(sqlmock.NewRows([]string{"Tables_in_shop", "Table_type"}).
+					AddRow("orders", "BASE TABLE").AddRow("users", "BASE TABLE"))
+			},
+			tables: []string{"orders", "users"},
+			used:   sourceInformationSchema,
+		},
+		{
+			name:   "auto, wrong schema",
+			source: MetadataAuto,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillReturnRows(wrongSchema())
+				mock.ExpectQuery(showTablesQuery).WillReturnRows(showTablesRows())
+				mock.ExpectQuery(showTablesQuery).WillReturnRows(showTablesRows())
+				mock.ExpectQuery(tableStatusQuery).WillReturnRows(tableStatusRows())
+			},
+			tables:   []string{"orders", "users", "user_totals"},
+			used:     sourceShowTables + " + " + sourceTableStatus,
+			degraded: "information_schema listed 1 tables but SHOW TABLES lists 3 (0 in common); used SHOW statements instead",
+		},
+		{
+			name:   "auto, no tables",
+			source: MetadataAuto,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillReturnRows(noTables())
+				mock.ExpectQuery(showTablesQuery).WillReturnRows(showTablesRows())
+				mock.ExpectQuery(showTablesQuery).WillReturnRows(showTablesRows())
+				mock.ExpectQuery(tableStatusQuery).WillReturnError(&mysql.MySQLError{Number: 1142, Message: "denied"})
+			},
+			tables:   []string{"orders", "users", "user_totals"},
+			unknown:  []string{"orders", "users", "user_totals"},
+			used:     sourceShowTables,
+			degraded: "information_schema listed no tables but SHOW TABLES lists 3, SHOW TABLE STATUS is not accessible; sizes and row counts are unavailable",
+		},
+		{
+			name:   "information_schema is trusted",
+			source: MetadataInformationSchema,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(informationSchemaQuery).WillReturnRows(wrongSchema())
+			},
+			tables: []string{"proxy_config"},
+			used:   sourceInformationSchema,
+		},
+		{
+			name:   "show",
+			source: MetadataShow,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(showTablesQuery).WillReturnRows(showTablesRows())
+				mock.ExpectQuery(tableStatusQuery).WillReturnRows(partialStatus())
+			},
+			// Tables without status keep unknown sizes and go last
+			tables:  []string{"orders", "users", "user_totals"},
+			unknown: []string{"user_totals"},
+			used:    sourceShowTables + " + " + sourceTableStatus,
+		},
+		{
+			name:   "show, status too slow",
+			source: MetadataShow,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(showTablesQuery).WillReturnRows(showTablesRows())
+				mock.ExpectQuery(tableStatusQuery).WillDelayFor(time.Second).WillReturnRows(partialStatus())
+			},
+			tables:   []string{"orders", "users", "user_totals"},
+			unknown:  []string{"orders", "users", "user_totals"},
+			used:     sourceShowTables,
+			degraded: "SHOW TABLE STATUS did not answer within 50ms; sizes and row counts are unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspector, mock := newMockInspector(t)
+			inspector.WithMetadataTimeout(50 * time.Millisecond).WithMetadataSource(tt.source)
+			tt.expect(mock)
+
+			tables, err := inspector.GetAllTablesInfo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names, unknown []string
+			for _, info := range tables {
+				names = append(names, info.Name)
+				if info.SizeUnknown {
+					unknown = append(unknown, info.Name)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.tables) {
+				t.Errorf("tables = %v, want %v", names, tt.tables)
+			}
+			if !reflect.DeepEqual(unknown, tt.unknown) {
+				t.Errorf("tables with unknown sizes = %v, want %v", unknown, tt.unknown)
+			}
+			if got := inspector.Source(); got != tt.used {
+				t.Errorf("Source() = %q, want %q", got, tt.used)
+			}
+			if got := inspector.Degraded(); got != tt.degraded {
+				t.Errorf("Degraded() = %q, want %q", got, tt.degraded)
+			}
+		})
+	}
+}
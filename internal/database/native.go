@@ -0,0 +1,351 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// NativeLimitation describes what native dumps leave out, for the dump
+// header and the dry run
+const NativeLimitation = "triggers, events and routines are not dumped in native mode"
+
+// nativePageRows is how many rows each keyset-paginated SELECT reads
+const nativePageRows = 10000
+
+// errTableDefChanged is ER_TABLE_DEF_CHANGED
+const errTableDefChanged = 1412
+
+// nativeSession is the connection a native phase reads over, in a
+// consistent snapshot like mysqldump's --single-transaction
+type nativeSession struct {
+	db        *sql.DB
+	conn      *sql.Conn
+	inspector *Inspector
+}
+
+// openNative opens the connection of a native phase
+func (d *Dumper) openNative(ctx context.Context) (*nativeSession, error) {
+	db, err := d.options.Connection.ConnectContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session := &nativeSession{db: db, inspector: NewInspector(db).WithContext(ctx)}
+	if session.conn, err = db.Conn(ctx); err != nil {
+		session.close()
+		return nil, fmt.Errorf("failed to open dump connection: %w", err)
+	}
+
+	// Read TIMESTAMP values in UTC, matching sessionHeader
+	for _, statement := range []string{
+		"SET time_zone = '+00:00'",
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"START TRANSACTION /*!40100 WITH CONSISTENT SNAPSHOT */",
+	} {
+		if _, err := session.conn.ExecContext(ctx, statement); err != nil {
+			session.close()
+			return nil, fmt.Errorf("failed to prepare dump connection (%s): %w", statement, err)
+		}
+	}
+	return session, nil
+}
+
+// close ends the snapshot and closes the connection
+func (s *nativeSession) close() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+	if err := s.db.Close(); err != nil {
+		diag.Warnf("failed to close dump connection: %v", err)
+	}
+}
+
+// listTables returns the tables and views of the database, in name order,
+// without the skipped ones
+func (s *nativeSession) listTables(ctx context.Context, skip []string) (tables, views []string, err error) {
+	rows, err := s.conn.QueryContext(ctx, "SHOW FULL TABLES")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var name, kind string
+		if err := rows.Scan(&name, &kind); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		switch {
+		case slices.Contains(skip, name):
+		case kind == "VIEW":
+			views = append(views, name)
+		default:
+			tables = append(tables, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating tables: %w", err)
+	}
+	slices.Sort(tables)
+	slices.Sort(views)
+	return tables, views, nil
+}
+
+// nativeStructure writes the definitions of the tables, then of the views
+// (views using other views after them), as mysqldump --no-data does
+func (d *Dumper) nativeStructure(writer io.Writer) error {
+	ctx := d.context()
+	session, err := d.openNative(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	tables, views, err := session.listTables(ctx, d.options.SkipTables)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriterSize(writer, 256*1024)
+	if _, err := io.WriteString(out, sessionHeader); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	for _, table := range tables {
+		create, err := session.inspector.GetCreateTable(table)
+		if err != nil {
+			return nativeError(ctx, table, err)
+		}
+		name := sqlident.Quote(table)
+		fmt.Fprintf(out, "\nDROP TABLE IF EXISTS %s;\n", name)
+		fmt.Fprintf(out, "/*!40101 SET @saved_cs_client     = @@character_set_client */;\n/*!50503 SET character_set_client = utf8mb4 */;\n")
+		fmt.Fprintf(out, "%s;\n/*!40101 SET character_set_client = @saved_cs_client */;\n", create)
+	}
+
+	definitions := make(map[string]string, len(views))
+	for _, view := range views {
+		create, err := session.inspector.GetCreateTable(view)
+		if err != nil {
+			return nativeError(ctx, view, err)
+		}
+		definitions[view] = create
+	}
+	for _, view := range viewOrder(views, definitions) {
+		fmt.Fprintf(out, "\n/*!50001 DROP VIEW IF EXISTS %s*/;\n%s;\n", sqlident.Quote(view), definitions[view])
+	}
+
+	if _, err := io.WriteString(out, sessionFooter); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// viewOrder sorts views so that each comes after the views its definition
+// names; views in a cycle keep their order
+func viewOrder(views []string, definitions map[string]string) []string {
+	var ordered []string
+	done := make(map[string]bool, len(views))
+	visiting := make(map[string]bool)
+	var visit func(view string)
+	visit = func(view string) {
+		if done[view] || visiting[view] {
+			return
+		}
+		visiting[view] = true
+		for _, other := range views {
+			if other != view && strings.Contains(definitions[view], sqlident.Quote(other)) {
+				visit(other)
+			}
+		}
+		visiting[view] = false
+		done[view] = true
+		ordered = append(ordered, view)
+	}
+	for _, view := range views {
+		visit(view)
+	}
+	return ordered
+}
+
+// nativeData writes the data of every table that is neither excluded,
+// skipped, masked nor copied, as mysqldump's data phase does
+func (d *Dumper) nativeData(writer io.Writer) error {
+	ctx := d.context()
+	session, err := d.openNative(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	tables, _, err := session.listTables(ctx, d.options.SkipTables)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriterSize(writer, 256*1024)
+	if _, err := io.WriteString(out, sessionHeader); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	for _, table := range tables {
+		if slices.Contains(d.options.ExcludeTables, table) || d.copied(table) || slices.ContainsFunc(d.options.Masked, func(masked MaskedTable) bool {
+			return masked.Table == table
+		}) {
+			continue
+		}
+		if err := d.nativeTable(ctx, session, out, table, nil); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(out, sessionFooter); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// nativeSamples writes the sampled rows of each table in Samples
+func (d *Dumper) nativeSamples(writer io.Writer) error {
+	ctx := d.context()
+	session, err := d.openNative(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	out := bufio.NewWriterSize(writer, 256*1024)
+	if _, err := io.WriteString(out, sessionHeader); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	for _, sample := range d.options.Samples {
+		fmt.Fprintf(out, "\n-- Sample of %s: last %d rows\n", sample.Table, sample.Rows)
+		if err := d.nativeTable(ctx, session, out, sample.Table, &sample); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(out, sessionFooter); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// nativeTable writes a table's rows (or a sample of them) as extended
+// INSERTs. Tables with a primary key are read in pages ordered by it, each
+// starting after the last key of the previous one, so no single query
+// holds the whole table; others are read in one streamed SELECT.
+func (d *Dumper) nativeTable(ctx context.Context, session *nativeSession, out io.Writer, name string, sample *TableSample) error {
+	columns, err := session.inspector.GetColumns(ctx, name)
+	if err != nil {
+		return nativeError(ctx, name, err)
+	}
+	table, _ := NewMaskedTable(name, columns, nil, nil)
+	table.Sample = sample
+
+	var key []int
+	if sample == nil {
+		primary, err := session.inspector.GetPrimaryKey(name)
+		if err != nil {
+			return nativeError(ctx, name, err)
+		}
+		key = table.keyColumns(primary)
+	}
+
+	quoted := sqlident.Quote(name)
+	fmt.Fprintf(out, "LOCK TABLES %s WRITE;\n/*!40000 ALTER TABLE %s DISABLE KEYS */;\n", quoted, quoted)
+
+	inserts := newInsertWriter(out, table, key)
+	readErr := func(err error) error {
+		return nativeError(ctx, name, err)
+	}
+	var after []string
+	for {
+		query := table.query()
+		if key != nil {
+			query = table.pageQuery(key, after)
+		}
+		rows, err := session.conn.QueryContext(ctx, query)
+		if err != nil {
+			return readErr(err)
+		}
+		count, err := inserts.copy(rows, readErr)
+		_ = rows.Close()
+		if err != nil {
+			return err
+		}
+		if key == nil || count < nativePageRows {
+			break
+		}
+		after = slices.Clone(inserts.last)
+	}
+	if err := inserts.flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "/*!40000 ALTER TABLE %s ENABLE KEYS */;\nUNLOCK TABLES;\n", quoted)
+	return nil
+}
+
+// keyColumns returns the positions of the primary key columns in key
+// order, or nil if the table has none or part of it isn't read (a
+// generated column)
+func (t MaskedTable) keyColumns(primary []string) []int {
+	if len(primary) == 0 {
+		return nil
+	}
+	key := make([]int, 0, len(primary))
+	for _, column := range primary {
+		i := slices.IndexFunc(t.Columns, func(col MaskedColumn) bool {
+			return col.Name == column
+		})
+		if i < 0 {
+			return nil
+		}
+		key = append(key, i)
+	}
+	return key
+}
+
+// pageQuery returns the SELECT reading the page of rows that follows the
+// key values after (from the first row if nil), in key order
+func (t MaskedTable) pageQuery(key []int, after []string) string {
+	names := make([]string, len(key))
+	for k, i := range key {
+		names[k] = sqlident.Quote(t.Columns[i].Name)
+	}
+	query := t.query()
+	if after != nil {
+		query += fmt.Sprintf(" WHERE (%s) > (%s)", strings.Join(names, ", "), strings.Join(after, ", "))
+	}
+	return query + fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(names, ", "), nativePageRows)
+}
+
+// nativeError names the table a native read failed on, as an interrupt or
+// a table altered mid-dump where that is the cause
+func nativeError(ctx context.Context, table string, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: native dump of %s: %w", dberrors.ErrDumpInterrupted, table, err)
+	}
+	wrapped := fmt.Errorf("failed to dump %s: %w", table, err)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errTableDefChanged {
+		return &dberrors.ErrTableDefChanged{Table: table, Attempts: 1, Err: wrapped}
+	}
+	return wrapped
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewMaskedTableNullColumns(t *testing.T) {
+	literal := func(s string) *string { return &s }
+	columns := []ColumnInfo{
+		{Name: "id", DataType: "int"},
+		{Name: "phone", DataType: "varchar", MaxLength: 20, Nullable: true},
+		{Name: "status", DataType: "varchar", MaxLength: 10, HasDefault: true},
+		{Name: "created", DataType: "datetime", HasDefault: true, DefaultExpression: true},
+		{Name: "email", DataType: "varchar", MaxLength: 50},
+		{Name: "avatar", DataType: "blob", HasDefault: true},
+		{Name: "full_name", DataType: "varchar", Generated: "stored", Expression: "concat(`first`, ' ', `last`)"},
+	}
+	tests := []struct {
+		name     string
+		masks    map[string]string
+		nulls    map[string]*string
+		want     map[string]string
+		query    string
+		problems []string
+	}{
+		{
+			name:  "nullable column",
+			nulls: map[string]*string{"phone": nil},
+			want:  map[string]string{"phone": "NULL"},
+			query: "SELECT `id`, NULL, `status`, CAST(`created` AS CHAR), `email`, HEX(`avatar`) FROM `users`",
+		},
+		{
+			name:  "NOT NULL column with a default",
+			nulls: map[string]*string{"status": nil, "avatar": nil},
+			want:  map[string]string{"status": "DEFAULT", "avatar": "DEFAULT"},
+			query: "SELECT `id`, `phone`, DEFAULT(`status`), CAST(`created` AS CHAR), `email`, HEX(DEFAULT(`avatar`)) FROM `users`",
+		},
+		{
+			name:  "literal placeholder",
+			nulls: map[string]*string{"email": literal("o'neil@example.invalid"), "created": literal("2000-01-01 00:00:00")},
+			want:  map[string]string{"email": `'o\'neil@example.invalid'`, "created": "'2000-01-01 00:00:00'"},
+			query: "SELECT `id`, `phone`, `status`, '2000-01-01 00:00:00', 'o\\'neil@example.invalid', HEX(`avatar`) FROM `users`",
+		},
+		{
+			name:     "NOT NULL without a default",
+			nulls:    map[string]*string{"email": nil},
+			problems: []string{"users.email is NOT NULL without a default; give it a literal placeholder in null_columns"},
+		},
+		{
+			name:     "default is an expression",
+			nulls:    map[string]*string{"created": nil},
+			problems: []string{"users.created is NOT NULL and its default is an expression; give it a literal placeholder in null_columns"},
+		},
+		{
+			name:     "literal longer than the column",
+			nulls:    map[string]*string{"phone": literal("a placeholder far too long")},
+			problems: []string{`users.phone holds at most 20 characters; the placeholder "a placeholder far too long" is longer`},
+		},
+		{
+			name:     "generated column",
+			nulls:    map[string]*string{"full_name": nil},
+			problems: []string{"users.full_name is a generated column and is computed on restore; leave it out of null_columns"},
+		},
+		{
+			name:     "unknown column",
+			nulls:    map[string]*string{"fax": nil},
+			problems: []string{"users.fax: no such column in null_columns"},
+		},
+		{
+			name:     "masked and nulled",
+			masks:    map[string]string{"phone": MaskNull},
+			nulls:    map[string]*string{"phone": nil},
+			problems: []string{"users.phone is both masked and in null_columns; keep one"},
+		},
+		{
+			name:  "masked and nulled columns side by side",
+			masks: map[string]string{"email": MaskFakeEmail},
+			nulls: map[string]*string{"phone": nil},
+			want:  map[string]string{"phone": "NULL"},
+			query: "SELECT `id`, NULL, `status`, CAST(`created` AS CHAR), CONCAT('user_', LEFT(SHA2(`email`, 256), 12), '@example.invalid'), HEX(`avatar`) FROM `users`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, problems := NewMaskedTable("users", columns, tt.masks, tt.nulls)
+			if !reflect.DeepEqual(problems, tt.problems) {
+				t.Fatalf("problems = %q, want %q", problems, tt.problems)
+			}
+			if tt.problems != nil {
+				return
+			}
+			if got := table.NulledColumns(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NulledColumns() = %v, want %v", got, tt.want)
+			}
+			if got := table.query(); got != tt.query {
+				t.Errorf("query() =\n%s\nwant\n%s", got, tt.query)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/triggers"
+)
+
+// Object types returned by GetObjects
+const (
+	ObjectProcedure = "PROCEDURE"
+	ObjectFunction  = "FUNCTION"
+	ObjectTrigger   = "TRIGGER"
+	ObjectEvent     = "EVENT"
+)
+
+// ObjectInfo describes a stored procedure, function, trigger or event
+type ObjectInfo struct {
+	Type    string    `json:"type"`
+	Name    string    `json:"name"`
+	Table   string    `json:"table,omitempty"`  // triggers: the table the trigger is on
+	Timing  string    `json:"timing,omitempty"` // triggers: e.g. "BEFORE INSERT"
+	Definer string    `json:"definer"`
+	Created time.Time `json:"created"` // zero if unknown
+
+	// BodySize is the length of the definition in bytes; BodyHidden is set
+	// when the server doesn't show the definition to the current user
+	BodySize   int64 `json:"body_size"`
+	BodyHidden bool  `json:"body_hidden,omitempty"`
+
+	// SecurityDefiner is set for routines declared SQL SECURITY DEFINER,
+	// which run with the definer's privileges instead of the caller's
+	SecurityDefiner bool `json:"security_definer"`
+
+	// DefinerMissing is set when the definer account doesn't exist on the
+	// server; such objects fail to run, and restoring them needs SUPER or
+	// SET_USER_ID. It is nil when accounts could not be checked.
+	DefinerMissing *bool `json:"definer_missing,omitempty"`
+}
+
+// GetObjects returns the routines, triggers and events of the database,
+// ordered by type and name
+func (i *Inspector) GetObjects() ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	routines, err := i.queryObjects("routines", `
+		SELECT routine_type, routine_name, '', '', definer, created,
+			LENGTH(routine_definition), security_type = 'DEFINER'
+		FROM information_schema.routines
+		WHERE routine_schema = DATABASE()
+		ORDER BY routine_type DESC, routine_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	objects = append(objects, routines...)
+
+	triggers, err := i.queryObjects("triggers", `
+		SELECT 'TRIGGER', trigger_name, event_object_table,
+			CONCAT(action_timing, ' ', event_manipulation), definer, created,
+			LENGTH(action_statement), FALSE
+		FROM information_schema.triggers
+		WHERE trigger_schema = DATABASE()
+		ORDER BY trigger_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	objects = append(objects, triggers...)
+
+	events, err := i.queryObjects("events", `
+		SELECT 'EVENT', event_name, '', '', definer, created,
+			LENGTH(event_definition), FALSE
+		FROM information_schema.events
+		WHERE event_schema = DATABASE()
+		ORDER BY event_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	objects = append(objects, events...)
+
+	return objects, nil
+}
+
+// GetTriggers returns the triggers of the database with their bodies
+func (i *Inspector) GetTriggers() ([]triggers.Trigger, error) {
+	rows, err := i.db.QueryContext(i.context(), `
+		SELECT trigger_name, event_object_table,
+			CONCAT(action_timing, ' ', event_manipulation), action_statement
+		FROM information_schema.triggers
+		WHERE trigger_schema = DATABASE()
+		ORDER BY event_object_table, action_order, trigger_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list triggers: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var found []triggers.Trigger
+	for rows.Next() {
+		var trigger triggers.Trigger
+		var body sql.NullString
+		if err := rows.Scan(&trigger.Name, &trigger.Table, &trigger.Timing, &body); err != nil {
+			return nil, fmt.Errorf("failed to scan trigger: %w", err)
+		}
+		trigger.Body = body.String
+		found = append(found, trigger)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating triggers: %w", err)
+	}
+	return found, nil
+}
+
+// queryObjects runs one of the GetObjects queries
+func (i *Inspector) queryObjects(kind, query string) ([]ObjectInfo, error) {
+	rows, err := i.db.QueryContext(i.context(), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", kind, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var objects []ObjectInfo
+	for rows.Next() {
+		var object ObjectInfo
+		var created sql.NullTime
+		var size sql.NullInt64
+		if err := rows.Scan(&object.Type, &object.Name, &object.Table, &object.Timing,
+			&object.Definer, &created, &size, &object.SecurityDefiner); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", kind, err)
+		}
+		object.Created = created.Time
+		object.BodySize = size.Int64
+		object.BodyHidden = !size.Valid
+		objects = append(objects, object)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s: %w", kind, err)
+	}
+
+	return objects, nil
+}
+
+// CheckDefiners marks objects whose definer account doesn't exist on the
+// server. Reading the account list needs SELECT on mysql.user; without it
+// the objects are left unchecked and the error is returned.
+func (i *Inspector) CheckDefiners(objects []ObjectInfo) error {
+	rows, err := i.db.QueryContext(i.context(), "SELECT user, host FROM mysql.user")
+	if err != nil {
+		return fmt.Errorf("cannot read accounts from mysql.user: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	accounts := make(map[string]bool)
+	for rows.Next() {
+		var user, host string
+		if err := rows.Scan(&user, &host); err != nil {
+			return fmt.Errorf("failed to scan account: %w", err)
+		}
+		accounts[user+"@"+strings.ToLower(host)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating accounts: %w", err)
+	}
+
+	for idx := range objects {
+		missing := !accounts[definerAccount(objects[idx].Definer)]
+		objects[idx].DefinerMissing = &missing
+	}
+	return nil
+}
+
+// definerAccount normalizes a definer ("app@%", "`app`@`%`") to user@host.
+// The user part may itself contain @, so the host is split off at the last one.
+func definerAccount(definer string) string {
+	at := strings.LastIndex(definer, "@")
+	if at < 0 {
+		return definer
+	}
+	user := strings.Trim(definer[:at], "`'")
+	host := strings.Trim(definer[at+1:], "`'")
+	return user + "@" + strings.ToLower(host)
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+	"github.com/helgesverre/dbdump/internal/tuning"
+)
+
+// output is the writer of a single-file dump: the file behind a buffer
+// (256KB by default), optionally gzip-compressed, counting the SQL bytes
+// written and syncing the file as the sync policy says
+type output struct {
+	file       *os.File
+	sync       *dumpfile.SyncWriter
+	boundaries *dumpfile.Boundaries // nil unless syncing at intervals
+	hash       hash.Hash            // SHA-256 of the file, for resume checkpoints
+	sink       io.Writer            // what the buffer flushes to: the file and the hash
+	buffer     *bufio.Writer
+	gz         dumpfile.GzipWriter // nil without compression
+	written    int64
+	member     int64 // SQL bytes written when the current gzip member started
+}
+
+// newOutput creates the writer for file with the buffer size and gzip
+// settings of performance; prefix hashes what the file already holds (nil
+// for a new file)
+func newOutput(file *os.File, compress bool, performance tuning.Settings, policy dumpfile.SyncPolicy, prefix hash.Hash) *output {
+	performance = performance.Filled()
+	if prefix == nil {
+		prefix = sha256.New()
+	}
+	o := &output{file: file, sync: dumpfile.NewSyncWriter(file, policy), hash: prefix}
+	if policy.Interval > 0 {
+		o.boundaries = dumpfile.NewBoundaries()
+	}
+	o.sink = io.MultiWriter(o.sync, o.hash)
+	o.buffer = bufio.NewWriterSize(o.sink, int(performance.WriterBuffer))
+	if compress {
+		o.gz = dumpfile.NewGzipWriter(o.buffer, performance.GzipLevel(), performance.CompressionWorkers)
+	}
+	return o
+}
+
+// Write implements io.Writer. An interval sync that is due happens at the
+// last statement boundary in p.
+func (o *output) Write(p []byte) (int, error) {
+	if o.boundaries == nil {
+		return o.write(p)
+	}
+	end := o.boundaries.Last(p)
+	if end < 0 || !o.sync.Due() {
+		return o.write(p)
+	}
+
+	n, err := o.write(p[:end])
+	if err != nil {
+		return n, err
+	}
+	if err := o.buffer.Flush(); err != nil {
+		return n, err
+	}
+	if err := o.sync.Boundary(); err != nil {
+		return n, err
+	}
+	m, err := o.write(p[end:])
+	return n + m, err
+}
+
+// write writes SQL to the buffer, through gzip if compressing
+func (o *output) write(p []byte) (int, error) {
+	var w io.Writer = o.buffer
+	if o.gz != nil {
+		w = o.gz
+	}
+	n, err := w.Write(p)
+	o.written += int64(n)
+	return n, err
+}
+
+// finish ends the gzip stream and flushes everything to the file; the
+// output must not be written to afterwards, except after a rewind
+func (o *output) finish() error {
+	if o.gz != nil {
+		if err := o.gz.Close(); err != nil {
+			return fmt.Errorf("failed to finish compressed output: %w", err)
+		}
+	}
+	if err := o.buffer.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+// Write implements io.Writer
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.written += int64(n)
+	return n, err
+}
+
+// writeRecorder keeps the first error of the writer it wraps. A failed write
+// makes mysqldump die of a broken pipe, and the process error would
+// otherwise hide the cause.
+type writeRecorder struct {
+	writer io.Writer
+	err    error
+}
+
+// Write implements io.Writer
+func (r *writeRecorder) Write(p []byte) (int, error) {
+	n, err := r.writer.Write(p)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// outputWriteError describes a failed write to path. A full filesystem, or
+// a file that reached the filesystem's size limit, becomes ErrOutputFull
+// naming the filesystem and the size the file reached.
+func outputWriteError(path string, err error) error {
+	noSpace := fileutil.IsNoSpace(err)
+	if !noSpace && !fileutil.IsFileTooLarge(err) {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	var offset int64
+	if info, statErr := os.Stat(path); statErr == nil {
+		offset = info.Size()
+	}
+	return &dberrors.ErrOutputFull{
+		Path:       path,
+		Offset:     offset,
+		Filesystem: fileutil.DetectFilesystem(filepath.Dir(path)).Type,
+		NoSpace:    noSpace,
+		Err:        err,
+	}
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/tuning"
+)
+
+// statements returns n INSERT statements of rows rows each, one per line
+// like mysqldump's extended inserts
+func statements(n, rows int) []byte {
+	var sql bytes.Buffer
+	sql.WriteString("-- MySQL dump\n\nDROP TABLE IF EXISTS `t`;\n")
+	for i := range n {
+		sql.WriteString("INSERT INTO `t` VALUES ")
+		for row := range rows {
+			if row > 0 {
+				sql.WriteByte(',')
+			}
+			fmt.Fprintf(&sql, "(%d,'some text; with a semicolon',NULL)", i*rows+row)
+		}
+		sql.WriteString(";\n")
+	}
+	return sql.Bytes()
+}
+
+// TestOutputSyncsAtStatementEnds writes a dump in pieces that split its
+// statements and checks that interval syncs leave the file at the end of a
+// statement
+func TestOutputSyncsAtStatementEnds(t *testing.T) {
+	sql := statements(2000, 1)
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "shop.sql")
+			file, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			out := newOutput(file, compress, tuning.Settings{WriterBuffer: 4 << 10}, dumpfile.SyncPolicy{Interval: 10 << 10}, nil)
+
+			syncs := 0
+			for data := sql; len(data) > 0; {
+				n := min(7, len(data))
+				if _, err := out.Write(data[:n]); err != nil {
+					t.Fatal(err)
+				}
+				data = data[n:]
+				if out.sync.Syncs() == syncs {
+					continue
+				}
+				syncs = out.sync.Syncs()
+				if compress {
+					continue
+				}
+				synced, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.HasSuffix(synced, []byte(");\n")) || !bytes.HasPrefix(sql, synced) {
+					t.Fatalf("sync %d left the file at %d bytes, mid-statement: …%q", syncs, len(synced), synced[max(0, len(synced)-20):])
+				}
+			}
+			if err := out.finish(); err != nil {
+				t.Fatal(err)
+			}
+			if err := out.sync.Finish(); err != nil {
+				t.Fatal(err)
+			}
+			// The interval counts the bytes of the file, compressed or not
+			info, err := file.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lo, hi := int(info.Size()/(20<<10)), int(info.Size()/(10<<10))+2; syncs < lo || out.sync.Syncs() > hi {
+				t.Errorf("%d syncs of a %d byte file, want %d to %d", out.sync.Syncs(), info.Size(), lo, hi)
+			}
+			if got := readSQL(t, path, compress); !bytes.Equal(got, sql) {
+				t.Error("the output differs from the SQL written")
+			}
+		})
+	}
+}
+
+// BenchmarkSyncPolicy measures the cost of each sync policy writing a dump
+// of about 45MB in extended inserts to the temporary directory's filesystem
+func BenchmarkSyncPolicy(b *testing.B) {
+	sql := statements(1000, 1000)
+	for _, policy := range []string{"none", "end", "interval:64MB", "interval:8MB", "interval:1MB"} {
+		b.Run(policy, func(b *testing.B) {
+			parsed, err := dumpfile.ParseSyncPolicy(policy)
+			if err != nil {
+				b.Fatal(err)
+			}
+			dir := b.TempDir()
+			b.SetBytes(int64(len(sql)))
+			for b.Loop() {
+				file, err := os.Create(filepath.Join(dir, "shop.sql"))
+				if err != nil {
+					b.Fatal(err)
+				}
+				out := newOutput(file, false, tuning.Settings{}, parsed, nil)
+				for data := sql; len(data) > 0; {
+					n := min(32<<10, len(data))
+					if _, err := out.Write(data[:n]); err != nil {
+						b.Fatal(err)
+					}
+					data = data[n:]
+				}
+				if err := out.finish(); err != nil {
+					b.Fatal(err)
+				}
+				if err := out.sync.Finish(); err != nil {
+					b.Fatal(err)
+				}
+				if err := file.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+//go:build !windows
+
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+)
+
+func TestOutputWriteError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shop.sql.tmp")
+	if err := os.WriteFile(path, make([]byte, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fsName := fileutil.DetectFilesystem(dir).Name()
+
+	tests := []struct {
+		name    string
+		path    string
+		err     error
+		full    bool
+		noSpace bool
+		offset  int64
+		want    string
+	}{
+		{
+			name: "no space", path: path, err: &os.PathError{Op: "write", Path: path, Err: syscall.ENOSPC},
+			full: true, noSpace: true, offset: 4096,
+			want: "no space left on the " + fsName + " filesystem: " + path + " stopped at byte 4096",
+		},
+		{
+			name: "file too large", path: path, err: &os.PathError{Op: "write", Path: path, Err: syscall.EFBIG},
+			full: true, offset: 4096,
+			want: path + " reached the largest file the " + fsName + " filesystem allows, at byte 4096",
+		},
+		{
+			// The offset is unknown when the file is gone
+			name: "file removed", path: filepath.Join(dir, "gone.sql"), err: syscall.ENOSPC,
+			full: true, noSpace: true,
+			want: "stopped at byte 0",
+		},
+		{
+			name: "other", path: path, err: errors.New("broken pipe"),
+			want: "failed to write output: broken pipe",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := outputWriteError(tt.path, tt.err)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("outputWriteError() = %v, doesn't wrap %v", err, tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("outputWriteError() = %q, want it to contain %q", err, tt.want)
+			}
+			var full *dberrors.ErrOutputFull
+			if errors.As(err, &full) != tt.full {
+				t.Fatalf("outputWriteError() = %T, ErrOutputFull wanted: %v", err, tt.full)
+			}
+			if tt.full && (full.NoSpace != tt.noSpace || full.Offset != tt.offset || full.Path != tt.path) {
+				t.Errorf("ErrOutputFull = %+v", full)
+			}
+		})
+	}
+}
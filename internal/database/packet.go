@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ClientMaxAllowedPacket is the max_allowed_packet given to mysqldump and
+// mysql unless the server's is smaller
+const ClientMaxAllowedPacket int64 = 1 << 30
+
+// packetRiskFactor is how far below max_allowed_packet a table's average
+// hex-encoded row must stay: the largest rows are often many times the average
+const packetRiskFactor = 8
+
+// GetMaxAllowedPacket returns the server's max_allowed_packet in bytes
+func (i *Inspector) GetMaxAllowedPacket() (int64, error) {
+	var size int64
+	if err := i.db.QueryRowContext(i.context(), "SELECT @@max_allowed_packet").Scan(&size); err != nil {
+		return 0, fmt.Errorf("failed to get max_allowed_packet: %w", err)
+	}
+	return size, nil
+}
+
+// ClientPacketLimit clamps the client's max_allowed_packet to the server's:
+// a larger client value can't help, since the server refuses to send or
+// accept bigger packets. 0 (unknown) keeps the default.
+func ClientPacketLimit(server int64) int64 {
+	if server <= 0 {
+		return ClientMaxAllowedPacket
+	}
+	return min(server, ClientMaxAllowedPacket)
+}
+
+// PacketRisk is a table whose rows could exceed max_allowed_packet
+type PacketRisk struct {
+	Table        string
+	AvgRowLength int64
+
+	// LargeColumns are the table's MEDIUM/LONG BLOB and TEXT and JSON
+	// columns, which is where oversized rows come from
+	LargeColumns []string
+}
+
+// PacketRisks returns the tables whose average row, written hex-encoded,
+// comes within packetRiskFactor of limit. Tables without size statistics
+// are not considered.
+func (i *Inspector) PacketRisks(ctx context.Context, tables []TableInfo, limit int64) ([]PacketRisk, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	var risks []PacketRisk
+	for _, info := range tables {
+		if info.SizeUnknown || info.RowCount <= 0 {
+			continue
+		}
+		avg := info.DataSize / info.RowCount
+		// --hex-blob writes binary data at twice its size
+		if 2*avg*packetRiskFactor <= limit {
+			continue
+		}
+
+		risk := PacketRisk{Table: info.Name, AvgRowLength: avg}
+		columns, err := i.GetColumns(ctx, info.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range columns {
+			if largeColumnType(col.Type) {
+				risk.LargeColumns = append(risk.LargeColumns, col.Name)
+			}
+		}
+		risks = append(risks, risk)
+	}
+	return risks, nil
+}
+
+// largeColumnType reports whether values of a column type can reach
+// megabytes
+func largeColumnType(columnType string) bool {
+	base, _, _ := strings.Cut(strings.TrimSpace(columnType), " ")
+	switch strings.ToLower(base) {
+	case "mediumblob", "longblob", "mediumtext", "longtext", "json":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const maxAllowedPacketQuery = `SELECT @@max_allowed_packet`
+
+// columnRows answers the columns query with name and type pairs
+func columnRows(columns ...[2]string) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"column_name", "column_type", "data_type", "nullable", "max_length", "extra", "expression", "has_default"})
+	for _, col := range columns {
+		rows.AddRow(col[0], col[1], col[1], true, 0, "", "", false)
+	}
+	return rows
+}
+
+func TestClientPacketLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		server int64
+		want   int64
+	}{
+		{name: "unknown", server: 0, want: ClientMaxAllowedPacket},
+		{name: "smaller server", server: 64 << 20, want: 64 << 20},
+		{name: "same", server: 1 << 30, want: 1 << 30},
+		{name: "larger server", server: 2 << 30, want: ClientMaxAllowedPacket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClientPacketLimit(tt.server); got != tt.want {
+				t.Errorf("ClientPacketLimit(%d) = %d, want %d", tt.server, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMaxAllowedPacket(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  func(*sqlmock.ExpectedQuery)
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "small server",
+			answer: func(q *sqlmock.ExpectedQuery) {
+				q.WillReturnRows(sqlmock.NewRows([]string{"@@max_allowed_packet"}).AddRow(4 << 20))
+			},
+			want: 4 << 20,
+		},
+		{
+			name: "large server",
+			answer: func(q *sqlmock.ExpectedQuery) {
+				q.WillReturnRows(sqlmock.NewRows([]string{"@@max_allowed_packet"}).AddRow(1 << 30))
+			},
+			want: 1 << 30,
+		},
+		{
+			name:    "error",
+			answer:  func(q *sqlmock.ExpectedQuery) { q.WillReturnError(errors.New("denied")) },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspector, mock := newMockInspector(t)
+			tt.answer(mock.ExpectQuery(maxAllowedPacketQuery))
+
+			got, err := inspector.GetMaxAllowedPacket()
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("GetMaxAllowedPacket() = %d, %v", got, err)
+			}
+			if !tt.wantErr && ClientPacketLimit(got) != min(tt.want, ClientMaxAllowedPacket) {
+				t.Errorf("ClientPacketLimit(%d) = %d", got, ClientPacketLimit(got))
+			}
+		})
+	}
+}
+
+func TestPacketRisks(t *testing.T) {
+	const limit = 16 << 20
+	tables := []TableInfo{
+		{Name: "users", RowCount: 1000, DataSize: 1 << 20},                    // 1 KB rows
+		{Name: "attachments", RowCount: 10, DataSize: 20 << 20},               // 2 MB rows
+		{Name: "documents", RowCount: 100, DataSize: 100 << 20},               // 1 MB rows, exactly at the factor
+		{Name: "empty", RowCount: 0, DataSize: 16 << 10},                      // no rows
+		{Name: "unknown", SizeUnknown: true, SizeDisplay: SizeUnavailable},    // no statistics
+		{Name: "payloads", RowCount: 4, DataSize: 64 << 20, Engine: "InnoDB"}, // 16 MB rows
+	}
+
+	inspector, mock := newMockInspector(t)
+	mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("attachments").WillReturnRows(
+		columnRows([2]string{"id", "int"}, [2]string{"body", "longblob"}, [2]string{"meta", "json"}, [2]string{"name", "varchar(255)"}))
+	mock.ExpectQuery(`FROM information_schema\.columns`).WithArgs("payloads").WillReturnRows(
+		columnRows([2]string{"id", "int"}, [2]string{"data", "varbinary(65000)"}))
+
+	risks, err := inspector.PacketRisks(context.Background(), tables, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PacketRisk{
+		{Table: "attachments", AvgRowLength: 2 << 20, LargeColumns: []string{"body", "meta"}},
+		{Table: "payloads", AvgRowLength: 16 << 20},
+	}
+	if !reflect.DeepEqual(risks, want) {
+		t.Errorf("PacketRisks() = %+v, want %+v", risks, want)
+	}
+
+	// No limit, no risks and no queries
+	if risks, err := inspector.PacketRisks(context.Background(), tables, 0); err != nil || risks != nil {
+		t.Errorf("PacketRisks() without a limit = %+v, %v", risks, err)
+	}
+}
+
+func TestLargeColumnType(t *testing.T) {
+	for columnType, want := range map[string]bool{
+		"longblob":              true,
+		"MEDIUMBLOB":            true,
+		"mediumtext":            true,
+		"longtext":              true,
+		"json":                  true,
+		"blob":                  false,
+		"text":                  false,
+		"varchar(255)":          false,
+		"longtext COLLATE utf8": true,
+		"":                      false,
+	} {
+		if got := largeColumnType(columnType); got != want {
+			t.Errorf("largeColumnType(%q) = %v, want %v", columnType, got, want)
+		}
+	}
+}
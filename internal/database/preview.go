@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// previewRows is how many rows a table preview reads
+const previewRows = 5
+
+// previewTimeout bounds a table preview, so a slow table doesn't hold up
+// the selector
+const previewTimeout = 5 * time.Second
+
+// RowPreview is the first few rows of a table, for a look at its content.
+// Values are nil for NULL, []byte or time.Time.
+type RowPreview struct {
+	Columns []string
+	Binary  []bool // columns of binary types, whose values are shown by size
+	Rows    [][]any
+}
+
+// PreviewRows reads the first rows of a table. It runs on the inspector's
+// connection, so a read-only session applies to it too.
+func (i *Inspector) PreviewRows(ctx context.Context, table string) (*RowPreview, error) {
+	ctx, cancel := context.WithTimeout(ctx, previewTimeout)
+	defer cancel()
+
+	rows, err := i.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s LIMIT %d", sqlident.Quote(table), previewRows))
+	if err != nil {
+		return nil, previewError(ctx, table, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, previewError(ctx, table, err)
+	}
+	preview := &RowPreview{Columns: make([]string, len(types)), Binary: make([]bool, len(types))}
+	for c, columnType := range types {
+		preview.Columns[c] = columnType.Name()
+		name := columnType.DatabaseTypeName()
+		preview.Binary[c] = strings.Contains(name, "BLOB") || strings.Contains(name, "BINARY") ||
+			name == "GEOMETRY" || name == "BIT"
+	}
+
+	for rows.Next() {
+		values := make([]any, len(types))
+		targets := make([]any, len(types))
+		for c := range values {
+			targets[c] = &values[c]
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, previewError(ctx, table, err)
+		}
+		preview.Rows = append(preview.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, previewError(ctx, table, err)
+	}
+	return preview, nil
+}
+
+// previewError describes a failed preview, naming a timeout as such
+func previewError(ctx context.Context, table string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("reading rows of %s took longer than %s", table, previewTimeout)
+	}
+	return fmt.Errorf("failed to read rows of %s: %w", table, err)
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPreviewRows(t *testing.T) {
+	inspector, mock := newMockInspector(t)
+	rows := sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("id").OfType("INT", int64(0)),
+		sqlmock.NewColumn("name").OfType("VARCHAR", ""),
+		sqlmock.NewColumn("avatar").OfType("MEDIUMBLOB", nil),
+		sqlmock.NewColumn("token").OfType("VARBINARY", nil),
+		sqlmock.NewColumn("flags").OfType("BIT", nil),
+	).AddRow(int64(1), []byte("ada"), []byte{1, 2}, nil, []byte{1})
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order items` LIMIT 5")).WillReturnRows(rows)
+
+	preview, err := inspector.PreviewRows(context.Background(), "order items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &RowPreview{
+		Columns: []string{"id", "name", "avatar", "token", "flags"},
+		Binary:  []bool{false, false, true, true, true},
+		Rows:    [][]any{{int64(1), []byte("ada"), []byte{1, 2}, nil, []byte{1}}},
+	}
+	if !reflect.DeepEqual(preview, want) {
+		t.Errorf("PreviewRows() = %+v, want %+v", preview, want)
+	}
+}
+
+func TestPreviewRowsError(t *testing.T) {
+	inspector, mock := newMockInspector(t)
+	denied := errors.New("SELECT command denied")
+	mock.ExpectQuery("SELECT").WillReturnError(denied)
+
+	_, err := inspector.PreviewRows(context.Background(), "secrets")
+	if !errors.Is(err, denied) || err.Error() != "failed to read rows of secrets: SELECT command denied" {
+		t.Errorf("PreviewRows() error = %v", err)
+	}
+}
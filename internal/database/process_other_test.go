@@ -0,0 +1,13 @@
+//go:build !windows
+
+package database
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processRunning reports whether a process with the given pid exists
+func processRunning(pid int) bool {
+	return !errors.Is(syscall.Kill(pid, 0), syscall.ESRCH)
+}
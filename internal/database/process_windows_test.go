@@ -0,0 +1,7 @@
+package database
+
+// processRunning reports whether a process with the given pid exists; the
+// fake mysqldump is a shell script, so no test starts one on Windows
+func processRunning(pid int) bool {
+	return false
+}
@@ -0,0 +1,38 @@
+package database
+
+// DumpProgress describes how far the data phase has come
+type DumpProgress struct {
+	Table string // table whose data is being written
+	Index int    // position of Table among the tables whose data is dumped, from 1
+	Count int    // number of tables whose data is dumped
+
+	// Bytes is the SQL the data phase has written so far, and EstimatedBytes
+	// what it is expected to write according to table statistics
+	Bytes          int64
+	EstimatedBytes int64
+}
+
+// reportProgress passes the data phase's position to OnProgress
+func (d *Dumper) reportProgress() {
+	table := d.timer.Current()
+	if table == "" {
+		return
+	}
+
+	progress := DumpProgress{
+		Table: table,
+		Count: len(d.options.TableEstimates),
+		Bytes: d.timer.written,
+	}
+	for i, timing := range d.timer.timings {
+		if timing.Table == table {
+			progress.Index = i + 1
+		}
+	}
+	progress.Count = max(progress.Count, len(d.timer.timings))
+	for _, size := range d.options.TableEstimates {
+		progress.EstimatedBytes += size
+	}
+
+	d.options.OnProgress(progress)
+}
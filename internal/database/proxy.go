@@ -0,0 +1,78 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Kinds of local proxies a connection can go through. The host of such a
+// connection is a loopback address, while the server is somewhere else.
+const (
+	ProxyCloudSQL = "cloudsql" // Cloud SQL Auth Proxy
+	ProxyGeneric  = "generic"  // any other forwarding proxy or tunnel
+)
+
+// ProxyKinds are the values accepted for a proxy hint
+var ProxyKinds = []string{ProxyCloudSQL, ProxyGeneric}
+
+// CheckProxy reports an unknown proxy hint; empty means no proxy
+func CheckProxy(kind string) error {
+	if kind != "" && !slices.Contains(ProxyKinds, kind) {
+		return fmt.Errorf("unknown proxy %q (use %s or %s)", kind, ProxyCloudSQL, ProxyGeneric)
+	}
+	return nil
+}
+
+// ProxyName returns how a kind of proxy is called in messages
+func ProxyName(kind string) string {
+	if kind == ProxyCloudSQL {
+		return "the Cloud SQL Auth Proxy"
+	}
+	return "a local proxy"
+}
+
+// Local reports whether the server runs on this machine: the host is a
+// loopback address and the connection doesn't go through a proxy
+func (c Connection) Local() bool {
+	return c.Proxy == "" && NormalizeHost(c.Host) == "localhost"
+}
+
+// ControlsReplication reports whether the replica status can be read and
+// replication stopped over the connection. Cloud SQL manages replication
+// itself and denies both to users, so replica detection isn't tried
+// through its proxy.
+func (c Connection) ControlsReplication() bool {
+	return c.Proxy != ProxyCloudSQL
+}
+
+// ServerName names the server in headers, summaries and history: the
+// instance behind a proxy when it is known, or the host
+func (c Connection) ServerName() string {
+	if c.Instance != "" {
+		return c.Instance
+	}
+	return c.Host
+}
+
+// DetectProxy guesses the proxy of a connection to a loopback address from
+// the server it reaches, returning "" when there is no sign of one. Only
+// Cloud SQL can be told apart, by its cloudsql_* server variables; other
+// proxies forward the server's handshake unchanged.
+func (i *Inspector) DetectProxy() (string, error) {
+	rows, err := i.db.QueryContext(i.context(), `SHOW GLOBAL VARIABLES LIKE 'cloudsql\_%'`)
+	if err != nil {
+		return "", fmt.Errorf("failed to read server variables: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	found := rows.Next()
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error iterating server variables: %w", err)
+	}
+	if found {
+		return ProxyCloudSQL, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import "testing"
+
+func TestConnectionProxy(t *testing.T) {
+	tests := []struct {
+		name            string
+		conn            Connection
+		wantLocal       bool
+		wantServer      string
+		wantReplication bool
+	}{
+		{name: "local", conn: Connection{Host: "127.0.0.1"}, wantLocal: true, wantServer: "127.0.0.1", wantReplication: true},
+		{name: "localhost", conn: Connection{Host: "localhost"}, wantLocal: true, wantServer: "localhost", wantReplication: true},
+		{name: "remote", conn: Connection{Host: "db.example.com"}, wantServer: "db.example.com", wantReplication: true},
+		{
+			name:       "cloud sql",
+			conn:       Connection{Host: "127.0.0.1", Proxy: ProxyCloudSQL, Instance: "acme:europe-west1:main"},
+			wantServer: "acme:europe-west1:main",
+		},
+		{name: "cloud sql without instance", conn: Connection{Host: "127.0.0.1", Proxy: ProxyCloudSQL}, wantServer: "127.0.0.1"},
+		{
+			name:            "tunnel",
+			conn:            Connection{Host: "::1", Proxy: ProxyGeneric, Instance: "db-primary"},
+			wantServer:      "db-primary",
+			wantReplication: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conn.Local(); got != tt.wantLocal {
+				t.Errorf("Local() = %v, want %v", got, tt.wantLocal)
+			}
+			if got := tt.conn.ServerName(); got != tt.wantServer {
+				t.Errorf("ServerName() = %q, want %q", got, tt.wantServer)
+			}
+			if got := tt.conn.ControlsReplication(); got != tt.wantReplication {
+				t.Errorf("ControlsReplication() = %v, want %v", got, tt.wantReplication)
+			}
+		})
+	}
+}
+
+func TestCheckProxy(t *testing.T) {
+	for _, kind := range []string{"", ProxyCloudSQL, ProxyGeneric} {
+		if err := CheckProxy(kind); err != nil {
+			t.Errorf("CheckProxy(%q) = %v", kind, err)
+		}
+	}
+	for _, kind := range []string{"CloudSQL", "ssh", " "} {
+		if err := CheckProxy(kind); err == nil {
+			t.Errorf("CheckProxy(%q) accepted", kind)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ReplicaStatus is the part of SHOW REPLICA STATUS that pinning looks at
+type ReplicaStatus struct {
+	Source     string // the server the replica replicates from
+	SQLRunning bool
+	LastError  string // the SQL thread's last error, if any
+}
+
+// ReplicaServer runs the statements replica pinning needs. ReplicaControl
+// implements it over a connection; PinReplica only talks to the interface,
+// so its failure paths can be driven by a scripted status sequence.
+type ReplicaServer interface {
+	// Status returns the replication status, or nil if the server is not a replica
+	Status(ctx context.Context) (*ReplicaStatus, error)
+
+	// Missing returns the part of gtids the replica has not applied yet
+	Missing(ctx context.Context, gtids string) (string, error)
+
+	// StopSQLThread stops the replica's SQL thread (a no-op if it is stopped)
+	StopSQLThread(ctx context.Context) error
+
+	// StartSQLThread starts the SQL thread; with until set, it stops again
+	// by itself right after applying that GTID set
+	StartSQLThread(ctx context.Context, until string) error
+}
+
+// gtidSetPattern matches a GTID set such as
+// "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,…", tagged GTIDs included
+var gtidSetPattern = regexp.MustCompile(`^[0-9A-Za-z_:,\-\s]+$`)
+
+// ValidateGTIDSet checks that gtids looks like a GTID set, which also keeps
+// it safe to use as a literal in START REPLICA UNTIL
+func ValidateGTIDSet(gtids string) error {
+	if !gtidSetPattern.MatchString(gtids) || !strings.Contains(gtids, ":") {
+		return fmt.Errorf("%q is not a GTID set (expected uuid:interval[,uuid:interval…])", gtids)
+	}
+	return nil
+}
+
+// errAccessDenied is ER_SPECIFIC_ACCESS_DENIED_ERROR
+const errAccessDenied = 1227
+
+// errParse is ER_PARSE_ERROR, returned for SHOW REPLICA STATUS before MySQL 8.0.22
+const errParse = 1064
+
+// ReplicaControl implements ReplicaServer on a database connection. Servers
+// before MySQL 8.0.22 only know the SLAVE spelling of the statements, which
+// is used once SHOW REPLICA STATUS is rejected.
+type ReplicaControl struct {
+	db      *sql.DB
+	keyword string
+}
+
+// NewReplicaControl returns a ReplicaControl using db
+func NewReplicaControl(db *sql.DB) *ReplicaControl {
+	return &ReplicaControl{db: db, keyword: "REPLICA"}
+}
+
+// Status implements ReplicaServer
+func (r *ReplicaControl) Status(ctx context.Context) (*ReplicaStatus, error) {
+	rows, err := r.db.QueryContext(ctx, "SHOW "+r.keyword+" STATUS")
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errParse && r.keyword == "REPLICA" {
+		r.keyword = "SLAVE"
+		return r.Status(ctx)
+	}
+	if err != nil {
+		return nil, r.statementError("SHOW "+r.keyword+" STATUS", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replica status: %w", err)
+	}
+	var statuses []ReplicaStatus
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for n := range values {
+			dest[n] = &values[n]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to read replica status: %w", err)
+		}
+		field := make(map[string]string, len(columns))
+		for n, column := range columns {
+			field[column] = values[n].String
+		}
+		statuses = append(statuses, ReplicaStatus{
+			Source:     field["Source_Host"] + field["Master_Host"],
+			SQLRunning: strings.EqualFold(field["Replica_SQL_Running"]+field["Slave_SQL_Running"], "Yes"),
+			LastError:  field["Last_SQL_Error"],
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read replica status: %w", err)
+	}
+
+	switch len(statuses) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &statuses[0], nil
+	}
+	return nil, fmt.Errorf("the server replicates over %d channels; pinning supports a single channel", len(statuses))
+}
+
+// Missing implements ReplicaServer
+func (r *ReplicaControl) Missing(ctx context.Context, gtids string) (string, error) {
+	var missing sql.NullString
+	err := r.db.QueryRowContext(ctx, "SELECT GTID_SUBTRACT(?, @@GLOBAL.gtid_executed)", gtids).Scan(&missing)
+	if err != nil {
+		return "", fmt.Errorf("failed to compare %s with the replica's executed GTIDs: %w", gtids, err)
+	}
+	return strings.TrimSpace(missing.String), nil
+}
+
+// StopSQLThread implements ReplicaServer
+func (r *ReplicaControl) StopSQLThread(ctx context.Context) error {
+	return r.exec(ctx, "STOP "+r.keyword+" SQL_THREAD")
+}
+
+// StartSQLThread implements ReplicaServer
+func (r *ReplicaControl) StartSQLThread(ctx context.Context, until string) error {
+	statement := "START " + r.keyword + " SQL_THREAD"
+	if until != "" {
+		if err := ValidateGTIDSet(until); err != nil {
+			return err
+		}
+		statement += " UNTIL SQL_AFTER_GTIDS = " + quoteString(until)
+	}
+	return r.exec(ctx, statement)
+}
+
+// exec runs a replication statement
+func (r *ReplicaControl) exec(ctx context.Context, statement string) error {
+	if _, err := r.db.ExecContext(ctx, statement); err != nil {
+		return r.statementError(statement, err)
+	}
+	return nil
+}
+
+// statementError names the statement that failed, and the privileges it
+// needs when it was denied
+func (r *ReplicaControl) statementError(statement string, err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errAccessDenied {
+		return fmt.Errorf("%s needs the REPLICATION_SLAVE_ADMIN (or SUPER) privilege: %w", statement, err)
+	}
+	return fmt.Errorf("%s failed: %w", statement, err)
+}
+
+// PinOptions controls how long PinReplica waits for the replica
+type PinOptions struct {
+	// Timeout bounds the wait for the replica to reach the GTID set (0 for no limit)
+	Timeout time.Duration
+
+	// PollInterval is the time between status checks (default 1s)
+	PollInterval time.Duration
+
+	// OnProgress, if set, is called after each status check with the part
+	// of the GTID set still to be applied
+	OnProgress func(missing string)
+}
+
+// resumeTimeout bounds Resume, which must not depend on a context that may
+// already be cancelled
+const resumeTimeout = 30 * time.Second
+
+// ReplicaPin is a replica stopped right after a GTID set. Resume puts the
+// SQL thread back in the state it was found in.
+type ReplicaPin struct {
+	GTIDs  string
+	Source string
+
+	server     ReplicaServer
+	wasRunning bool
+	resumed    bool
+}
+
+// PinReplica stops the replica's SQL thread right after it has applied
+// gtids and waits for it to get there. Servers that are not replicas, and
+// replicas that have already applied all of gtids (and possibly more), are
+// refused. Whenever PinReplica fails after touching the SQL thread, it puts
+// the thread back as it was before returning.
+func PinReplica(ctx context.Context, server ReplicaServer, gtids string, options PinOptions) (*ReplicaPin, error) {
+	if err := ValidateGTIDSet(gtids); err != nil {
+		return nil, err
+	}
+	status, err := CheckReplica(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	missing, err := server.Missing(ctx, gtids)
+	if err != nil {
+		return nil, err
+	}
+	if missing == "" {
+		return nil, fmt.Errorf("the replica has already applied %s and may have applied later transactions; it can't be stopped at that point", gtids)
+	}
+
+	pin := &ReplicaPin{GTIDs: gtids, Source: status.Source, server: server, wasRunning: status.SQLRunning}
+	if err := pin.wait(ctx, options); err != nil {
+		if resumeErr := pin.Resume(); resumeErr != nil {
+			return nil, fmt.Errorf("%w (and resuming replication failed: %w)", err, resumeErr)
+		}
+		return nil, err
+	}
+	return pin, nil
+}
+
+// CheckReplica returns the status of the server's replication, and an error
+// if the server is not a replica
+func CheckReplica(ctx context.Context, server ReplicaServer) (*ReplicaStatus, error) {
+	status, err := server.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		return nil, fmt.Errorf("the server is not a replica (replica status is empty); refusing to stop replication")
+	}
+	return status, nil
+}
+
+// wait restarts the SQL thread with the UNTIL condition and polls until
+// it has stopped at the GTID set
+func (p *ReplicaPin) wait(ctx context.Context, options PinOptions) error {
+	if err := p.server.StopSQLThread(ctx); err != nil {
+		return err
+	}
+	if err := p.server.StartSQLThread(ctx, p.GTIDs); err != nil {
+		return err
+	}
+
+	interval := options.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	var deadline <-chan time.Time
+	if options.Timeout > 0 {
+		timer := time.NewTimer(options.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status, err := p.server.Status(ctx)
+		if err != nil {
+			return err
+		}
+		if status == nil {
+			return fmt.Errorf("the server stopped being a replica while waiting for %s", p.GTIDs)
+		}
+		missing, err := p.server.Missing(ctx, p.GTIDs)
+		if err != nil {
+			return err
+		}
+
+		// The thread stops by itself once the set is applied
+		switch {
+		case missing == "" && !status.SQLRunning:
+			return nil
+		case !status.SQLRunning:
+			reason := status.LastError
+			if reason == "" {
+				reason = "stopped by another session"
+			}
+			return fmt.Errorf("the replica SQL thread stopped before applying %s: %s", missing, reason)
+		}
+		if options.OnProgress != nil {
+			options.OnProgress(missing)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("the replica did not reach %s within %s (still missing %s)", p.GTIDs, options.Timeout, missing)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Resume stops the SQL thread, clearing the UNTIL condition, and starts it
+// again if it was running before the pin. It runs on its own context so
+// that replication resumes even after the dump was interrupted; calling it
+// again does nothing.
+func (p *ReplicaPin) Resume() error {
+	if p.resumed {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resumeTimeout)
+	defer cancel()
+
+	if err := p.server.StopSQLThread(ctx); err != nil {
+		return err
+	}
+	if p.wasRunning {
+		if err := p.server.StartSQLThread(ctx, ""); err != nil {
+			return err
+		}
+	}
+	p.resumed = true
+	return nil
+}
+
+// WasRunning reports whether the SQL thread was running before the pin,
+// and so runs again after Resume
+func (p *ReplicaPin) WasRunning() bool {
+	return p.wasRunning
+}
@@ -0,0 +1,346 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedReplica is a ReplicaServer answering from scripted sequences and
+// recording the calls made to it
+type scriptedReplica struct {
+	statuses []*ReplicaStatus // returned in turn, the last one repeating
+	missing  []string         // likewise
+
+	// fail maps "<call> <n>" (the nth call of its kind) or "<call>" (every
+	// call) to the error it returns; calls are status, missing, stop and start
+	fail map[string]error
+
+	calls  []string
+	counts map[string]int
+}
+
+func (r *scriptedReplica) call(ctx context.Context, kind, record string) error {
+	if r.counts == nil {
+		r.counts = make(map[string]int)
+	}
+	r.counts[kind]++
+	r.calls = append(r.calls, record)
+	// Like a connection, the server refuses statements on a cancelled context
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := r.fail[fmt.Sprintf("%s %d", kind, r.counts[kind])]; err != nil {
+		return err
+	}
+	return r.fail[kind]
+}
+
+// next returns the element of a sequence for the nth call
+func next[T any](sequence []T, n int) T {
+	return sequence[min(n, len(sequence))-1]
+}
+
+func (r *scriptedReplica) Status(ctx context.Context) (*ReplicaStatus, error) {
+	if err := r.call(ctx, "status", "status"); err != nil {
+		return nil, err
+	}
+	return next(r.statuses, r.counts["status"]), nil
+}
+
+func (r *scriptedReplica) Missing(ctx context.Context, gtids string) (string, error) {
+	if err := r.call(ctx, "missing", "missing"); err != nil {
+		return "", err
+	}
+	return next(r.missing, r.counts["missing"]), nil
+}
+
+func (r *scriptedReplica) StopSQLThread(ctx context.Context) error {
+	return r.call(ctx, "stop", "stop")
+}
+
+func (r *scriptedReplica) StartSQLThread(ctx context.Context, until string) error {
+	if until != "" {
+		return r.call(ctx, "start", "start until "+until)
+	}
+	return r.call(ctx, "start", "start")
+}
+
+const (
+	pinGTIDs = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100"
+	toApply  = "3e11fa47-71ca-11e1-9e33-c80aa9429562:90-100"
+)
+
+var (
+	running = &ReplicaStatus{Source: "primary.internal", SQLRunning: true}
+	stopped = &ReplicaStatus{Source: "primary.internal"}
+)
+
+func TestValidateGTIDSet(t *testing.T) {
+	tests := []struct {
+		gtids   string
+		wantErr bool
+	}{
+		{gtids: pinGTIDs},
+		{gtids: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:11-18,\n2174b383-5441-11e8-b90a-c80aa9429562:1-3"},
+		{gtids: "3e11fa47-71ca-11e1-9e33-c80aa9429562:incident:1-5"},
+		{gtids: "", wantErr: true},
+		{gtids: "3e11fa47-71ca-11e1-9e33-c80aa9429562", wantErr: true},
+		{gtids: "x:1' OR '1'='1", wantErr: true},
+		{gtids: "uuid:1-5;STOP REPLICA", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.gtids, func(t *testing.T) {
+			if err := ValidateGTIDSet(tt.gtids); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateGTIDSet() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPinReplica(t *testing.T) {
+	errLost := errors.New("connection lost")
+
+	tests := []struct {
+		name     string
+		gtids    string
+		replica  scriptedReplica
+		timeout  time.Duration
+		cancel   bool // cancel the context once the wait has started
+		progress []string
+		calls    []string
+		wantErr  string // substring of the error, success if empty
+	}{
+		{
+			name:     "reaches the set",
+			replica:  scriptedReplica{statuses: []*ReplicaStatus{running, running, running, stopped}, missing: []string{toApply, toApply, "3e11fa47-71ca-11e1-9e33-c80aa9429562:99-100", ""}},
+			progress: []string{toApply, "3e11fa47-71ca-11e1-9e33-c80aa9429562:99-100"},
+			calls: []string{
+				"status", "missing", "stop", "start until " + pinGTIDs,
+				"status", "missing", "status", "missing", "status", "missing",
+			},
+		},
+		{
+			name:    "stopped replica",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{stopped}, missing: []string{toApply, ""}},
+			calls:   []string{"status", "missing", "stop", "start until " + pinGTIDs, "status", "missing"},
+		},
+		{
+			name:    "not a GTID set",
+			gtids:   "mysql-bin.000042:1337",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running}},
+			wantErr: "is not a GTID set",
+		},
+		{
+			name:    "not a replica",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{nil}},
+			calls:   []string{"status"},
+			wantErr: "the server is not a replica (replica status is empty); refusing to stop replication",
+		},
+		{
+			name:    "status unreadable",
+			replica: scriptedReplica{fail: map[string]error{"status": errLost}},
+			calls:   []string{"status"},
+			wantErr: "connection lost",
+		},
+		{
+			name:    "missing unreadable",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running}, fail: map[string]error{"missing": errLost}},
+			calls:   []string{"status", "missing"},
+			wantErr: "connection lost",
+		},
+		{
+			name:    "already applied",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running}, missing: []string{""}},
+			calls:   []string{"status", "missing"},
+			wantErr: "the replica has already applied " + pinGTIDs,
+		},
+		{
+			// Nothing was changed, but the thread is stopped to be sure
+			name:    "stop denied",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running}, missing: []string{toApply}, fail: map[string]error{"stop 1": errors.New("STOP REPLICA SQL_THREAD needs the REPLICATION_SLAVE_ADMIN (or SUPER) privilege")}},
+			calls:   []string{"status", "missing", "stop", "stop", "start"},
+			wantErr: "needs the REPLICATION_SLAVE_ADMIN",
+		},
+		{
+			name:    "start fails",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running}, missing: []string{toApply}, fail: map[string]error{"start 1": errLost}},
+			calls:   []string{"status", "missing", "stop", "start until " + pinGTIDs, "stop", "start"},
+			wantErr: "connection lost",
+		},
+		{
+			name:    "start fails on a stopped replica",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{stopped}, missing: []string{toApply}, fail: map[string]error{"start 1": errLost}},
+			calls:   []string{"status", "missing", "stop", "start until " + pinGTIDs, "stop"},
+			wantErr: "connection lost",
+		},
+		{
+			name:     "SQL thread error",
+			replica:  scriptedReplica{statuses: []*ReplicaStatus{running, running, {Source: "primary.internal", LastError: "Error 'Duplicate entry' on query"}}, missing: []string{toApply}},
+			progress: []string{toApply},
+			calls: []string{
+				"status", "missing", "stop", "start until " + pinGTIDs,
+				"status", "missing", "status", "missing", "stop", "start",
+			},
+			wantErr: "the replica SQL thread stopped before applying " + toApply + ": Error 'Duplicate entry' on query",
+		},
+		{
+			name:    "stopped by another session",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running, stopped}, missing: []string{toApply}},
+			calls:   []string{"status", "missing", "stop", "start until " + pinGTIDs, "status", "missing", "stop", "start"},
+			wantErr: "stopped by another session",
+		},
+		{
+			name:    "no longer a replica",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running, nil}, missing: []string{toApply}},
+			calls:   []string{"status", "missing", "stop", "start until " + pinGTIDs, "status", "stop", "start"},
+			wantErr: "the server stopped being a replica while waiting for " + pinGTIDs,
+		},
+		{
+			name:     "status lost while waiting",
+			replica:  scriptedReplica{statuses: []*ReplicaStatus{running}, missing: []string{toApply}, fail: map[string]error{"status 3": errLost}},
+			progress: []string{toApply},
+			calls:    []string{"status", "missing", "stop", "start until " + pinGTIDs, "status", "missing", "status", "stop", "start"},
+			wantErr:  "connection lost",
+		},
+		{
+			name:    "missing lost while waiting",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running}, missing: []string{toApply}, fail: map[string]error{"missing 2": errLost}},
+			calls:   []string{"status", "missing", "stop", "start until " + pinGTIDs, "status", "missing", "stop", "start"},
+			wantErr: "connection lost",
+		},
+		{
+			name:    "timeout",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running}, missing: []string{toApply}},
+			timeout: 25 * time.Millisecond,
+			wantErr: "the replica did not reach " + pinGTIDs + " within 25ms (still missing " + toApply + ")",
+		},
+		{
+			name:    "interrupted",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running}, missing: []string{toApply}},
+			cancel:  true,
+			wantErr: context.Canceled.Error(),
+		},
+		{
+			name:    "resume fails too",
+			replica: scriptedReplica{statuses: []*ReplicaStatus{running, stopped}, missing: []string{toApply}, fail: map[string]error{"stop 2": errLost}},
+			calls:   []string{"status", "missing", "stop", "start until " + pinGTIDs, "status", "missing", "stop"},
+			wantErr: "stopped by another session (and resuming replication failed: connection lost)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gtids := tt.gtids
+			if gtids == "" {
+				gtids = pinGTIDs
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var progress []string
+			replica := tt.replica
+			pin, err := PinReplica(ctx, &replica, gtids, PinOptions{
+				Timeout:      tt.timeout,
+				PollInterval: time.Millisecond,
+				OnProgress: func(missing string) {
+					progress = append(progress, missing)
+					if tt.cancel {
+						cancel()
+					}
+				},
+			})
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("PinReplica() = %v", err)
+				}
+				if pin.Source != "primary.internal" || pin.GTIDs != gtids {
+					t.Errorf("pin = %+v", pin)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("PinReplica() = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if tt.calls != nil && !reflect.DeepEqual(replica.calls, tt.calls) {
+				t.Errorf("calls = %q\nwant %q", replica.calls, tt.calls)
+			}
+			if tt.progress != nil && !reflect.DeepEqual(progress, tt.progress) {
+				t.Errorf("progress = %q, want %q", progress, tt.progress)
+			}
+
+			// A failed pin leaves the SQL thread as it found it
+			if err != nil && replica.counts["stop"] > 0 && !strings.Contains(tt.wantErr, "resuming replication failed") {
+				calls := replica.calls
+				wasRunning := next(replica.statuses, 1).SQLRunning
+				if last := calls[len(calls)-1]; wasRunning && last != "start" || !wasRunning && last != "stop" {
+					t.Errorf("last call after a failure = %s, replica running before: %v", last, wasRunning)
+				}
+			}
+		})
+	}
+}
+
+func TestReplicaPinResume(t *testing.T) {
+	for _, wasRunning := range []bool{true, false} {
+		t.Run(fmt.Sprintf("running %v", wasRunning), func(t *testing.T) {
+			status := stopped
+			if wasRunning {
+				status = running
+			}
+			replica := &scriptedReplica{
+				statuses: []*ReplicaStatus{status, stopped},
+				missing:  []string{toApply, ""},
+				fail:     map[string]error{"stop 2": errors.New("connection lost")},
+			}
+			pin, err := PinReplica(context.Background(), replica, pinGTIDs, PinOptions{PollInterval: time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pin.WasRunning() != wasRunning {
+				t.Errorf("WasRunning() = %v", pin.WasRunning())
+			}
+			replica.calls = nil
+
+			// A failed resume can be retried; a done one isn't repeated
+			if err := pin.Resume(); err == nil {
+				t.Fatal("Resume() succeeded, want the scripted failure")
+			}
+			if err := pin.Resume(); err != nil {
+				t.Fatalf("Resume() again = %v", err)
+			}
+			if err := pin.Resume(); err != nil {
+				t.Fatalf("Resume() a third time = %v", err)
+			}
+			want := []string{"stop", "stop"}
+			if wasRunning {
+				want = append(want, "start")
+			}
+			if !reflect.DeepEqual(replica.calls, want) {
+				t.Errorf("calls = %q, want %q", replica.calls, want)
+			}
+		})
+	}
+}
+
+// TestResumeAfterCancel checks that replication resumes after the dump's
+// context was cancelled
+func TestResumeAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	replica := &scriptedReplica{statuses: []*ReplicaStatus{running, stopped}, missing: []string{toApply, ""}}
+	pin, err := PinReplica(ctx, replica, pinGTIDs, PinOptions{PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := pin.Resume(); err != nil {
+		t.Fatalf("Resume() = %v", err)
+	}
+	if calls := replica.calls[len(replica.calls)-2:]; !reflect.DeepEqual(calls, []string{"stop", "start"}) {
+		t.Errorf("last calls = %q, want stop and start", calls)
+	}
+}
@@ -0,0 +1,358 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/redact"
+	"github.com/helgesverre/dbdump/internal/spill"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// RestoreOptions contains options for restoring a dump
+type RestoreOptions struct {
+	Connection  *Connection
+	InputFile   string
+	StartOffset int64
+
+	// OnProgress is called after each chunk is sent to the server with the
+	// number of file bytes consumed and SQL bytes sent so far
+	OnProgress func(fileBytes, sqlBytes int64)
+
+	// OnTable is called when the restore moves on to a new table
+	OnTable func(table string)
+
+	// Renamer, when set, rewrites database names and table prefixes on the way to the server
+	Renamer *dumpfile.Renamer
+
+	// Filter, when set, restores only the statements of some tables
+	Filter *dumpfile.TableFilter
+
+	// Context, if set, stops the restore when it is done
+	Context context.Context
+
+	// CreatesDatabase starts the mysql client without a default database,
+	// for dumps that create and USE their own
+	CreatesDatabase bool
+}
+
+// RestoreResult contains the result of a restore operation
+type RestoreResult struct {
+	InputFile   string
+	Duration    time.Duration
+	BytesSent   int64
+	StartOffset int64
+}
+
+// RestoreError describes where a restore failed so it can be resumed
+type RestoreError struct {
+	Offset  int64
+	Line    int
+	Table   string
+	Message string
+	Err     error
+}
+
+func (e *RestoreError) Error() string {
+	msg := fmt.Sprintf("restore failed near line %d (byte offset %d)", e.Line, e.Offset)
+	if e.Table != "" {
+		msg += fmt.Sprintf(" in table %s", e.Table)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *RestoreError) Unwrap() error {
+	return e.Err
+}
+
+// Restorer applies a dump file to a database through the mysql client
+type Restorer struct {
+	options *RestoreOptions
+}
+
+// NewRestorer creates a new Restorer
+func NewRestorer(options *RestoreOptions) *Restorer {
+	return &Restorer{options: options}
+}
+
+// Restore streams the dump file into the target database
+func (r *Restorer) Restore() (*RestoreResult, error) {
+	startTime := time.Now()
+
+	file, err := dumpfile.Open(r.options.InputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			diag.Warnf("failed to close dump file: %v", err)
+		}
+	}()
+
+	scanner := dumpfile.NewScanner(file)
+
+	// The lines sent are remembered under the same ceiling as the statements
+	lines := newFedLines(spill.Default, maxFedLines)
+	defer lines.release()
+
+	// The preamble is held in a spill buffer like any other statement text
+	preamble := spill.Default.NewBuffer()
+	defer func() {
+		if err := preamble.Close(); err != nil {
+			diag.Warnf("%v", err)
+		}
+	}()
+	if r.options.StartOffset > 0 {
+		err = scanner.SkipTo(r.options.StartOffset, preamble)
+		if err == io.EOF {
+			return nil, fmt.Errorf("start offset %d is beyond the end of the dump", r.options.StartOffset)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to seek to start offset: %w", err)
+		}
+		newlines, err := countLines(preamble.Reader())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the session preamble: %w", err)
+		}
+		lines.preamble = newlines
+	}
+	resumedAt := scanner.Offset()
+
+	ctx := r.context()
+
+	cmd := exec.CommandContext(ctx, "mysql", r.buildMySQLArgs()...)
+	var stderr bytes.Buffer
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	cmd.Stdout = os.Stdout
+
+	// Set MYSQL_PWD environment variable for secure password passing
+	cmd.Env, err = r.options.Connection.ClientEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mysql stdin: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mysql client: %w", err)
+	}
+
+	renamer := r.options.Renamer
+	var rewritten []byte
+	var filtered int64 // bytes of statements the filter left out
+
+	writeErr := func() error {
+		if err := r.writePreamble(stdin, preamble); err != nil {
+			return err
+		}
+
+		applying := true
+		for {
+			atLineStart := scanner.AtLineStart()
+			statementStart := atLineStart && scanner.AtBoundary()
+			lineNumber := scanner.Line()
+			chunk, err := scanner.Next()
+			if err == io.EOF {
+				if renamer != nil {
+					if rest := renamer.Flush(nil); len(rest) > 0 {
+						_, err := stdin.Write(rest)
+						return err
+					}
+				}
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			// Statements of tables left out are read but not sent
+			if r.options.Filter != nil && statementStart {
+				applying = r.options.Filter.Statement(chunk)
+			}
+			if !applying {
+				filtered += int64(len(chunk))
+				if r.options.OnProgress != nil {
+					r.options.OnProgress(file.FileBytesRead(), scanner.Offset()-resumedAt)
+				}
+				continue
+			}
+
+			if atLineStart {
+				lines.add(scanner.LineStart(), lineNumber)
+			}
+
+			if renamer != nil {
+				rewritten = renamer.Rewrite(rewritten[:0], chunk, lineNumber)
+				chunk = rewritten
+			}
+			if _, err := stdin.Write(chunk); err != nil {
+				return err
+			}
+
+			if scanner.TableChanged() {
+				lines.setTable(scanner.Table())
+				if r.options.OnTable != nil {
+					r.options.OnTable(scanner.Table())
+				}
+			}
+			if r.options.OnProgress != nil {
+				r.options.OnProgress(file.FileBytesRead(), scanner.Offset()-resumedAt)
+			}
+		}
+	}()
+
+	closeErr := stdin.Close()
+	waitErr := cmd.Wait()
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%w: restore stopped near byte offset %d", dberrors.ErrDumpInterrupted, scanner.Offset())
+	}
+	if waitErr != nil {
+		return nil, r.describeFailure(waitErr, stderr.String(), lines, scanner)
+	}
+	if writeErr != nil {
+		return nil, fmt.Errorf("failed to read dump file: %w", writeErr)
+	}
+	if closeErr != nil && !errors.Is(closeErr, os.ErrClosed) {
+		return nil, fmt.Errorf("failed to close mysql stdin: %w", closeErr)
+	}
+
+	return &RestoreResult{
+		InputFile:   r.options.InputFile,
+		Duration:    time.Since(startTime),
+		BytesSent:   scanner.Offset() - resumedAt - filtered,
+		StartOffset: resumedAt,
+	}, nil
+}
+
+// writePreamble replays the session preamble, through the renamer if set
+func (r *Restorer) writePreamble(stdin io.Writer, preamble *spill.Buffer) error {
+	renamer := r.options.Renamer
+	if renamer == nil {
+		_, err := preamble.WriteTo(stdin)
+		return err
+	}
+
+	reader := preamble.Reader()
+	buf := make([]byte, 64*1024)
+	var rewritten []byte
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			rewritten = renamer.Rewrite(rewritten[:0], buf[:n], 0)
+			if _, err := stdin.Write(rewritten); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// countLines returns the number of newlines read from r
+func countLines(r io.Reader) (int, error) {
+	buf := make([]byte, 64*1024)
+	count := 0
+	for {
+		n, err := r.Read(buf)
+		count += bytes.Count(buf[:n], []byte("\n"))
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+var clientErrorLine = regexp.MustCompile(`ERROR \d+ \([0-9A-Z]+\) at line (\d+)`)
+
+// describeFailure maps a mysql client failure to a RestoreError with a resumable offset
+func (r *Restorer) describeFailure(err error, stderr string, lines *fedLines, scanner *dumpfile.Scanner) error {
+	restoreErr := &RestoreError{
+		Offset:  scanner.LineStart(),
+		Line:    scanner.Line(),
+		Table:   scanner.Table(),
+		Message: redact.String(strings.TrimSpace(stderr), r.options.Connection.Password),
+		Err:     err,
+	}
+
+	match := clientErrorLine.FindStringSubmatch(stderr)
+	if match == nil {
+		return restoreErr
+	}
+
+	n, convErr := strconv.Atoi(match[1])
+	if convErr != nil {
+		return restoreErr
+	}
+	fed, ok := lines.find(n)
+	if !ok {
+		return restoreErr
+	}
+	if fed.offset < 0 {
+		// The failure happened in the replayed session preamble
+		restoreErr.Offset = r.options.StartOffset
+		restoreErr.Line = 0
+		return restoreErr
+	}
+
+	restoreErr.Offset = fed.offset
+	restoreErr.Line = fed.line
+	restoreErr.Table = fed.table
+	return restoreErr
+}
+
+// context returns the context the restore runs under
+func (r *Restorer) context() context.Context {
+	if r.options.Context != nil {
+		return r.options.Context
+	}
+	return context.Background()
+}
+
+// buildMySQLArgs builds the mysql client arguments
+// Note: Password is NOT included here - it's passed via MYSQL_PWD environment variable
+func (r *Restorer) buildMySQLArgs() []string {
+	args := []string{
+		"-h", r.options.Connection.Host,
+		"-P", fmt.Sprintf("%d", r.options.Connection.Port),
+		"-u", r.options.Connection.User,
+		"--max-allowed-packet=1G",
+	}
+	args = append(args, r.options.Connection.ClientArgs()...)
+	if r.options.CreatesDatabase {
+		return args
+	}
+	return append(args, r.options.Connection.Database)
+}
+
+// CheckMySQLClient verifies that the mysql client is available
+func CheckMySQLClient() error {
+	cmd := exec.Command("mysql", "--version")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mysql client not found in PATH: %w", err)
+	}
+	return nil
+}
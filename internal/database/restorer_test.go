@@ -0,0 +1,69 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+)
+
+func TestDescribeFailure(t *testing.T) {
+	lines := ring(4)
+	lines.preamble = 2
+	feed(lines, 10, map[int]string{3: "users", 8: "orders"})
+
+	// Where the scanner got to when the client gave up
+	scanner := dumpfile.NewScanner(strings.NewReader(""))
+
+	tests := []struct {
+		name   string
+		stderr string
+		want   RestoreError
+	}{
+		{
+			name:   "mapped line",
+			stderr: "ERROR 1062 (23000) at line 11: Duplicate entry '1' for key 'PRIMARY'",
+			want:   RestoreError{Offset: 90, Line: 9, Table: "orders"},
+		},
+		{
+			name:   "table from a line that left the ring",
+			stderr: "ERROR 1062 (23000) at line 9: Duplicate entry",
+			want:   RestoreError{Offset: 70, Line: 7, Table: "users"},
+		},
+		{
+			name:   "preamble",
+			stderr: "ERROR 1193 (HY000) at line 2: Unknown system variable",
+			want:   RestoreError{Offset: 500},
+		},
+		{
+			name:   "no longer known: where the scanner is",
+			stderr: "ERROR 1062 (23000) at line 5: Duplicate entry",
+			want:   RestoreError{Line: 1},
+		},
+		{
+			name:   "no line number",
+			stderr: "ERROR 2013 (HY000): Lost connection to MySQL server",
+			want:   RestoreError{Line: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRestorer(&RestoreOptions{Connection: &Connection{}, StartOffset: 500})
+			cause := errors.New("exit status 1")
+			err := r.describeFailure(cause, tt.stderr, lines, scanner)
+
+			var restoreErr *RestoreError
+			if !errors.As(err, &restoreErr) || !errors.Is(err, cause) {
+				t.Fatalf("describeFailure = %v, want a RestoreError wrapping the exit", err)
+			}
+			if restoreErr.Offset != tt.want.Offset || restoreErr.Line != tt.want.Line || restoreErr.Table != tt.want.Table {
+				t.Errorf("failure at offset %d, line %d, table %q; want %d, %d, %q",
+					restoreErr.Offset, restoreErr.Line, restoreErr.Table, tt.want.Offset, tt.want.Line, tt.want.Table)
+			}
+			if restoreErr.Message != tt.stderr {
+				t.Errorf("Message = %q", restoreErr.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// Checkpoint marks the end of a completed phase in single-file output, from
+// which a failed dump can be continued: the phase, the file offset and SQL
+// bytes written up to it, the SHA-256 of the file up to it, and the schema
+// fingerprints of the structure phase before it
+type Checkpoint struct {
+	Phase        string            `json:"phase"`
+	Offset       int64             `json:"offset"`
+	Written      int64             `json:"written"`
+	SHA256       string            `json:"sha256"`
+	Fingerprints map[string]string `json:"fingerprints,omitempty"`
+}
+
+// ResumePath returns where the output of a dump that failed after a
+// checkpoint is kept
+func ResumePath(output string) string {
+	return output + ".resume"
+}
+
+// skipPhase reports whether a phase ends before the checkpoint the dump
+// resumes from, and so is already in the output
+func (d *Dumper) skipPhase(name string) bool {
+	if d.resumeAfter == "" {
+		return false
+	}
+	if name == d.resumeAfter {
+		d.resumeAfter = ""
+	}
+	d.completed = name
+	return true
+}
+
+// checkpoint records the end of the last completed phase, now at offset in
+// the flushed output, and hands it to OnCheckpoint
+func (d *Dumper) checkpoint(rw *rewinder, offset int64) {
+	if d.completed == "" {
+		return
+	}
+	d.last = &Checkpoint{
+		Phase:        d.completed,
+		Offset:       offset,
+		Written:      rw.written,
+		SHA256:       hex.EncodeToString(rw.out.hash.Sum(nil)),
+		Fingerprints: d.fingerprint.Fingerprints(),
+	}
+	if d.options.OnCheckpoint != nil {
+		d.options.OnCheckpoint(*d.last)
+	}
+}
+
+// Resumable returns the last checkpoint of a failed dump and the file its
+// output was kept in, or nil and "" if it can't be continued
+func (d *Dumper) Resumable() (*Checkpoint, string) {
+	if d.kept == "" {
+		return nil, ""
+	}
+	return d.last, d.kept
+}
+
+// openResumed checks the output kept at ResumeFile against the checkpoint
+// of Resume, moves it to path and opens it at the checkpoint, with a hash
+// of what precedes it
+func (d *Dumper) openResumed(path string) (*os.File, hash.Hash, error) {
+	checkpoint, kept := d.options.Resume, d.options.ResumeFile
+	hasher, err := hashPrefix(kept, checkpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	if kept != path {
+		if err := os.Rename(kept, path); err != nil {
+			return nil, nil, fmt.Errorf("failed to move the kept output back: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open output file: %w", err)
+	}
+	if err := file.Truncate(checkpoint.Offset); err != nil {
+		_ = file.Close()
+		return nil, nil, fmt.Errorf("failed to truncate output: %w", err)
+	}
+	if _, err := file.Seek(checkpoint.Offset, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, nil, fmt.Errorf("failed to seek output: %w", err)
+	}
+	return file, hasher, nil
+}
+
+// hashPrefix hashes the kept output up to the checkpoint, which must match
+// the hash recorded there
+func hashPrefix(path string, checkpoint *Checkpoint) (hash.Hash, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the kept output: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	hasher := sha256.New()
+	if _, err := io.CopyN(hasher, file, checkpoint.Offset); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s is shorter than the %s phase it is to be continued after", path, checkpoint.Phase)
+		}
+		return nil, fmt.Errorf("failed to read the kept output: %w", err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != checkpoint.SHA256 {
+		return nil, fmt.Errorf("%s changed since the dump was interrupted (SHA-256 mismatch)", path)
+	}
+	return hasher, nil
+}
+
+// SchemaDigest hashes the columns and keys of every table and view in one
+// query, to tell whether the schema changed between two points in time
+// without reading each CREATE statement
+func (i *Inspector) SchemaDigest() (string, error) {
+	rows, err := i.db.QueryContext(i.context(), `
+		SELECT table_name, column_name, column_type, is_nullable, column_default, extra, column_key
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE()
+		ORDER BY table_name, ordinal_position`)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the schema: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	hasher := sha256.New()
+	for rows.Next() {
+		var fields [7]sql.NullString
+		if err := rows.Scan(&fields[0], &fields[1], &fields[2], &fields[3], &fields[4], &fields[5], &fields[6]); err != nil {
+			return "", fmt.Errorf("failed to scan column: %w", err)
+		}
+		for _, field := range fields {
+			fmt.Fprintf(hasher, "%t%q\x00", field.Valid, field.String)
+		}
+		hasher.Write([]byte{'\n'})
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error iterating columns: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
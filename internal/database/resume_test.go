@@ -0,0 +1,309 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+// fakeMySQLDump stands in for mysqldump: it prints the phase's SQL from
+// $FAKE_MYSQLDUMP_DIR, or the start of it and fails when the phase is
+// $FAKE_MYSQLDUMP_FAIL (only the first time with $FAKE_MYSQLDUMP_ONCE), or
+// the start of it and hangs, its pid in <phase>.pid, when the phase is
+// $FAKE_MYSQLDUMP_HANG
+const fakeMySQLDump = `#!/bin/sh
+for arg; do
+	case $arg in
+	--help) exit 0 ;;
+	--version) echo "mysqldump  Ver 8.0.36 for Linux"; exit 0 ;;
+	esac
+done
+case " $* " in
+*" --triggers "*) phase=objects ;;
+*" --no-create-info "*) phase=data ;;
+*) phase=structure ;;
+esac
+if [ "$FAKE_MYSQLDUMP_FAIL" = "$phase" ] && [ ! -e "$FAKE_MYSQLDUMP_DIR/failed" ]; then
+	[ -n "$FAKE_MYSQLDUMP_ONCE" ] && touch "$FAKE_MYSQLDUMP_DIR/failed"
+	head -c 50 "$FAKE_MYSQLDUMP_DIR/$phase.sql"
+	echo "mysqldump: $FAKE_MYSQLDUMP_ERROR" >&2
+	exit 2
+fi
+if [ "$FAKE_MYSQLDUMP_HANG" = "$phase" ]; then
+	head -c 50 "$FAKE_MYSQLDUMP_DIR/$phase.sql"
+	echo $$ > "$FAKE_MYSQLDUMP_DIR/$phase.pid"
+	exec sleep 60
+fi
+cat "$FAKE_MYSQLDUMP_DIR/$phase.sql"
+`
+
+// fakeOutput is what the fake mysqldump prints in each phase
+var fakeOutput = map[string]string{
+	"structure": "CREATE TABLE `users` (\n  `id` int NOT NULL,\n  `email` varchar(255),\n  PRIMARY KEY (`id`)\n);\n" +
+		"CREATE TABLE `orders` (\n  `id` int NOT NULL,\n  `user_id` int,\n  PRIMARY KEY (`id`)\n);\n",
+	"data": "INSERT INTO `users` VALUES (1,'a@example.com'),(2,'b@example.com');\n" +
+		"INSERT INTO `orders` VALUES (1,1),(2,1),(3,2);\n",
+	"objects": "DELIMITER ;;\nCREATE TRIGGER `orders_ai` AFTER INSERT ON `orders` FOR EACH ROW BEGIN END ;;\nDELIMITER ;\n",
+}
+
+// completedLine is the completion marker, the one line that differs
+// between two runs
+var completedLine = regexp.MustCompile(`\n-- Dump completed on [0-9: -]+\n$`)
+
+// installFakeMySQLDump puts the fake mysqldump first on PATH
+func installFakeMySQLDump(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake mysqldump is a shell script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mysqldump"), []byte(fakeMySQLDump), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for phase, sql := range fakeOutput {
+		if err := os.WriteFile(filepath.Join(dir, phase+".sql"), []byte(sql), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_MYSQLDUMP_DIR", dir)
+	t.Setenv("FAKE_MYSQLDUMP_FAIL", "")
+	t.Setenv("FAKE_MYSQLDUMP_ONCE", "")
+	t.Setenv("FAKE_MYSQLDUMP_HANG", "")
+	t.Setenv("FAKE_MYSQLDUMP_ERROR", "Lost connection to MySQL server during query")
+	return dir
+}
+
+// fakeDump runs a dump of the fake database into output
+func fakeDump(output string, compress bool, resume *Checkpoint, kept string) (*Dumper, *DumpResult, error) {
+	dumper := NewDumper(&DumpOptions{
+		Connection: &Connection{Host: "db", Port: 3306, User: "app", Database: "shop"},
+		OutputFile: output,
+		Compress:   compress,
+		Header:     "-- dbdump test\n",
+		Resume:     resume,
+		ResumeFile: kept,
+	})
+	result, err := dumper.Dump()
+	return dumper, result, err
+}
+
+// readSQL returns the SQL of a dump, decompressed
+func readSQL(t *testing.T, path string, compress bool) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compress {
+		return data
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("%s doesn't decompress: %v", path, err)
+	}
+	return sql
+}
+
+// sameDump compares two dump files, which may differ only in the time of
+// the completion marker
+func sameDump(t *testing.T, got, want []byte) {
+	t.Helper()
+	if !completedLine.Match(want) || !completedLine.Match(got) {
+		t.Fatalf("a dump lacks its completion marker:\n got: %q\nwant: %q", got, want)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("resumed dump is %d bytes, want %d", len(got), len(want))
+	}
+	stem := len(want) - len(completedLine.Find(want))
+	if !bytes.Equal(got[:stem], want[:stem]) {
+		t.Errorf("resumed dump differs:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestResumeIsByteIdentical(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		for _, failIn := range []string{"data", "objects"} {
+			name := failIn
+			if compress {
+				name += "/gzip"
+			}
+			t.Run(name, func(t *testing.T) {
+				dir := installFakeMySQLDump(t)
+				out := t.TempDir()
+
+				whole := filepath.Join(out, "whole.sql")
+				uninterrupted, _, err := fakeDump(whole, compress, nil, "")
+				if err != nil {
+					t.Fatalf("uninterrupted dump: %v", err)
+				}
+				// Compressed, only the last phase's gzip member holds the
+				// completion time
+				lastPhase := uninterrupted.last.Offset
+				want, err := os.ReadFile(whole)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// Fail in the phase, keeping the output past the checkpoint
+				t.Setenv("FAKE_MYSQLDUMP_FAIL", failIn)
+				output := filepath.Join(out, "resumed.sql")
+				dumper, _, err := fakeDump(output, compress, nil, "")
+				var dumpErr *dberrors.ErrMySQLDumpFailed
+				if !errors.As(err, &dumpErr) || dumpErr.Phase != failIn {
+					t.Fatalf("interrupted dump error = %v, want mysqldump failing in %s", err, failIn)
+				}
+				checkpoint, kept := dumper.Resumable()
+				if checkpoint == nil || kept != ResumePath(output) {
+					t.Fatalf("Resumable = %+v, %q; want a checkpoint kept at %s", checkpoint, kept, ResumePath(output))
+				}
+				if _, err := os.Stat(output); !os.IsNotExist(err) {
+					t.Errorf("the failed output is still at %s", output)
+				}
+				if checkpoint.Phase != map[string]string{"data": "structure", "objects": "data"}[failIn] {
+					t.Errorf("checkpoint after %s, want the phase before %s", checkpoint.Phase, failIn)
+				}
+				if len(checkpoint.Fingerprints) != 2 {
+					t.Errorf("checkpoint fingerprints = %v, want users and orders", checkpoint.Fingerprints)
+				}
+
+				// The kept bytes up to the checkpoint are those of the
+				// uninterrupted dump
+				partial, err := os.ReadFile(kept)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if int64(len(partial)) <= checkpoint.Offset || !bytes.Equal(partial[:checkpoint.Offset], want[:checkpoint.Offset]) {
+					t.Errorf("kept output doesn't start with the uninterrupted dump's %d bytes", checkpoint.Offset)
+				}
+
+				// Resume with mysqldump working again
+				t.Setenv("FAKE_MYSQLDUMP_FAIL", "")
+				if err := os.Remove(filepath.Join(dir, "failed")); err != nil && !os.IsNotExist(err) {
+					t.Fatal(err)
+				}
+				_, result, err := fakeDump(output, compress, checkpoint, kept)
+				if err != nil {
+					t.Fatalf("resumed dump: %v", err)
+				}
+				got, err := os.ReadFile(output)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got[:checkpoint.Offset], want[:checkpoint.Offset]) {
+					t.Error("resumed dump changed the bytes before the checkpoint")
+				}
+				if len(got) < int(lastPhase) || !bytes.Equal(got[:lastPhase], want[:lastPhase]) {
+					t.Errorf("resumed dump differs from the uninterrupted one before its last phase (%d bytes)", lastPhase)
+				}
+				sameDump(t, readSQL(t, output, compress), readSQL(t, whole, compress))
+				if !compress {
+					sameDump(t, got, want)
+				}
+				if _, err := os.Stat(kept); !os.IsNotExist(err) {
+					t.Errorf("the kept output %s was left behind", kept)
+				}
+				if len(result.SchemaFingerprints) != 2 {
+					t.Errorf("resumed result fingerprints = %v, want those of the checkpoint", result.SchemaFingerprints)
+				}
+			})
+		}
+	}
+}
+
+func TestResumeNothingToKeep(t *testing.T) {
+	installFakeMySQLDump(t)
+	output := filepath.Join(t.TempDir(), "shop.sql")
+
+	// A dump failing in its first phase has no checkpoint to resume from
+	t.Setenv("FAKE_MYSQLDUMP_FAIL", "structure")
+	dumper, _, err := fakeDump(output, false, nil, "")
+	if err == nil {
+		t.Fatal("dump succeeded")
+	}
+	if checkpoint, kept := dumper.Resumable(); checkpoint != nil || kept != "" {
+		t.Errorf("Resumable = %+v, %q; want nothing", checkpoint, kept)
+	}
+	for _, path := range []string{output, ResumePath(output)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s left behind", path)
+		}
+	}
+}
+
+func TestResumeChangedOutput(t *testing.T) {
+	installFakeMySQLDump(t)
+	output := filepath.Join(t.TempDir(), "shop.sql")
+	t.Setenv("FAKE_MYSQLDUMP_FAIL", "objects")
+	dumper, _, err := fakeDump(output, false, nil, "")
+	if err == nil {
+		t.Fatal("dump succeeded")
+	}
+	checkpoint, kept := dumper.Resumable()
+	if checkpoint == nil {
+		t.Fatal("no checkpoint")
+	}
+
+	// Bytes before the checkpoint changed: the kept output can't be spliced
+	data, err := os.ReadFile(kept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len("-- dbdump test\n")] ^= 1
+	if err := os.WriteFile(kept, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_MYSQLDUMP_FAIL", "")
+	if _, _, err := fakeDump(output, false, checkpoint, kept); err == nil || !regexp.MustCompile(`changed since the dump was interrupted`).MatchString(err.Error()) {
+		t.Errorf("resuming a changed file: %v", err)
+	}
+
+	// A kept output cut short before the checkpoint
+	if err := os.WriteFile(kept, data[:checkpoint.Offset/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := fakeDump(output, false, checkpoint, kept); err == nil || !regexp.MustCompile(`shorter than the data phase`).MatchString(err.Error()) {
+		t.Errorf("resuming a truncated file: %v", err)
+	}
+}
+
+// TestRewindIsByteIdentical restarts the data phase after a table
+// definition change: the rewound output must equal a run without one
+func TestRewindIsByteIdentical(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		installFakeMySQLDump(t)
+		out := t.TempDir()
+		whole := filepath.Join(out, "whole.sql")
+		if _, _, err := fakeDump(whole, compress, nil, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Setenv("FAKE_MYSQLDUMP_FAIL", "data")
+		t.Setenv("FAKE_MYSQLDUMP_ONCE", "1")
+		t.Setenv("FAKE_MYSQLDUMP_ERROR", "Couldn't execute 'SELECT /*!40001 SQL_NO_CACHE */ * FROM `orders`': Table definition has changed, please retry transaction (1412)")
+		retried := filepath.Join(out, "retried.sql")
+		dumper := NewDumper(&DumpOptions{
+			Connection:      &Connection{Host: "db", Port: 3306, User: "app", Database: "shop"},
+			OutputFile:      retried,
+			Compress:        compress,
+			Header:          "-- dbdump test\n",
+			TableDefRetries: 1,
+		})
+		if _, err := dumper.Dump(); err != nil {
+			t.Fatalf("compress %v: dump with a retried phase: %v", compress, err)
+		}
+		sameDump(t, readSQL(t, retried, compress), readSQL(t, whole, compress))
+	}
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// tableDefChangedPattern matches mysqldump's report of ER_TABLE_DEF_CHANGED (1412)
+var tableDefChangedPattern = regexp.MustCompile(`(?i)table definition has changed`)
+
+// tableDefChangedTable extracts the table name from mysqldump's error message,
+// e.g. "... when dumping table `orders` at row: 0" or "... FROM `orders`"
+var tableDefChangedTable = regexp.MustCompile("(?i)(?:when dumping table|from|table) `((?:[^`]|``)+)`")
+
+// stderrTailSize is how much of mysqldump's stderr is kept for error detection
+const stderrTailSize = 16 * 1024
+
+// stderrTail keeps the last bytes written to it
+type stderrTail struct {
+	buf []byte
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > stderrTailSize {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-stderrTailSize:]...)
+	}
+	return len(p), nil
+}
+
+// usageErrorPattern matches mysqldump's complaints about its own arguments.
+// Exit codes alone are ambiguous: MySQL exits 1 on bad options and 2 on
+// server errors, while MariaDB exits with my_getopt's codes (2 for an
+// unknown option, 7 for an unknown variable).
+var usageErrorPattern = regexp.MustCompile(`(?i)unknown (option|variable)|ambiguous option|requires an argument|doesn't allow an argument|incorrect (boolean|integer) value`)
+
+// classifyDumpError wraps a mysqldump failure as ErrTableDefChanged when its
+// stderr reports a table definition change, and as ErrMySQLDumpFailed
+// otherwise, telling usage errors apart from mid-stream failures
+func classifyDumpError(phase string, err error, stderr []byte) error {
+	wrapped := fmt.Errorf("mysqldump %s failed: %w", phase, err)
+	if tableDefChangedPattern.Match(stderr) {
+		table := ""
+		if match := tableDefChangedTable.FindSubmatch(stderr); match != nil {
+			table = sqlident.Unescape(string(match[1]))
+		}
+		return &dberrors.ErrTableDefChanged{Table: table, Attempts: 1, Err: wrapped}
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return wrapped
+	}
+	return &dberrors.ErrMySQLDumpFailed{
+		Phase:    phase,
+		ExitCode: exitErr.ExitCode(),
+		Usage:    usageErrorPattern.Match(stderr),
+		Message:  lastLine(stderr),
+		Err:      err,
+	}
+}
+
+// lastLine returns the last non-empty line of mysqldump's stderr without
+// its "mysqldump:" prefix
+func lastLine(stderr []byte) string {
+	lines := bytes.Split(bytes.TrimSpace(stderr), []byte("\n"))
+	line := bytes.TrimSpace(lines[len(lines)-1])
+	return string(bytes.TrimSpace(bytes.TrimPrefix(line, []byte("mysqldump:"))))
+}
+
+// rewinder truncates single-file output back to a phase boundary so a failed
+// phase can be run again. Compressed output starts a new gzip member at each
+// boundary, which readers see as one stream.
+type rewinder struct {
+	out     *output
+	written int64  // SQL bytes written at the mark
+	hashed  []byte // state of the output's hash at the mark
+}
+
+// mark flushes buffered output and returns the current file offset. A gzip
+// member with nothing in it yet is left open, so a resumed dump, which
+// starts with one, writes the same bytes as one that ran through.
+func (r *rewinder) mark() (int64, error) {
+	if r.out.gz != nil && r.out.written == r.out.member {
+		if err := r.out.buffer.Flush(); err != nil {
+			return 0, fmt.Errorf("failed to write output: %w", err)
+		}
+	} else {
+		if err := r.out.finish(); err != nil {
+			return 0, err
+		}
+		if r.out.gz != nil {
+			r.out.gz.Reset(r.out.buffer)
+		}
+		r.out.member = r.out.written
+	}
+	r.written = r.out.written
+	hashed, err := r.out.hash.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return 0, fmt.Errorf("failed to save the output hash: %w", err)
+	}
+	r.hashed = hashed
+	return r.out.file.Seek(0, io.SeekCurrent)
+}
+
+// rewind discards everything written after offset
+func (r *rewinder) rewind(offset int64) error {
+	r.out.buffer.Reset(r.out.sink)
+	if err := r.out.file.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate output: %w", err)
+	}
+	if _, err := r.out.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek output: %w", err)
+	}
+	if r.out.gz != nil {
+		r.out.gz.Reset(r.out.buffer)
+	}
+	r.out.written, r.out.member = r.written, r.written
+	if r.out.boundaries != nil {
+		// The mark is at a boundary
+		r.out.boundaries = dumpfile.NewBoundaries()
+	}
+	if err := r.out.hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(r.hashed); err != nil {
+		return fmt.Errorf("failed to restore the output hash: %w", err)
+	}
+	return nil
+}
+
+// runPhase runs a dump phase, restarting it when a table is altered mid-dump.
+// Restarts need a rewinder; split output is not rewound and fails instead.
+func (d *Dumper) runPhase(name string, writer io.Writer, rw *rewinder, phase func(io.Writer) error) error {
+	if d.skipPhase(name) {
+		return nil
+	}
+	if d.options.OnPhase != nil {
+		d.options.OnPhase(name)
+	}
+
+	var start int64
+	if rw != nil {
+		offset, err := rw.mark()
+		if err != nil {
+			return err
+		}
+		start = offset
+		d.checkpoint(rw, offset)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := phase(writer)
+
+		var defErr *dberrors.ErrTableDefChanged
+		if !errors.As(err, &defErr) {
+			if err == nil {
+				d.completed = name
+			}
+			return err
+		}
+		if rw == nil || attempt > d.options.TableDefRetries {
+			defErr.Attempts = attempt
+			return err
+		}
+
+		diag.Warnf("%s; restarting the %s phase (attempt %d of %d)",
+			defErr.Error(), name, attempt+1, d.options.TableDefRetries+1)
+
+		if d.options.BeforeRetry != nil {
+			excludes, skips, err := d.options.BeforeRetry(defErr.Table)
+			if err != nil {
+				return fmt.Errorf("failed to refresh table metadata: %w", err)
+			}
+			d.options.ExcludeTables = excludes
+			d.options.SkipTables = skips
+		}
+
+		if err := rw.rewind(start); err != nil {
+			return err
+		}
+	}
+}
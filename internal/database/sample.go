@@ -0,0 +1,199 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/spill"
+)
+
+// maxSampleBytes caps a sampled statement after its values are shortened
+const maxSampleBytes = 64 << 10
+
+// sampledStatement is a statement copied into the sample file
+type sampledStatement struct {
+	index int // 1-based position among the table's statements
+	sql   []byte
+}
+
+// tableSamples holds the statements sampled for one table
+type tableSamples struct {
+	count   int
+	first   []sampledStatement
+	last    []sampledStatement // ring buffer of the most recent statements
+	next    int                // ring position of the oldest entry in last
+	invalid []sampledStatement // statements that are not valid UTF-8
+}
+
+// statementSampler copies the first and last statements of each table, and
+// those with encoding problems, into a debug file without touching the dump
+type statementSampler struct {
+	perTable    int
+	valueLength int
+	tables      map[string]*tableSamples
+	order       []string
+}
+
+// newStatementSampler keeps perTable statements from each end of a table's
+// data, shortening string values to valueLength characters
+func newStatementSampler(perTable, valueLength int) *statementSampler {
+	return &statementSampler{perTable: perTable, valueLength: valueLength, tables: make(map[string]*tableSamples)}
+}
+
+// transform is the sampler's transform.Func; it returns the statement unchanged
+func (s *statementSampler) transform(statement []byte) []byte {
+	if !bytes.HasPrefix(statement, []byte("INSERT INTO ")) {
+		return statement
+	}
+	table, ok := dumpfile.StatementTable(statement)
+	if !ok {
+		return statement
+	}
+	s.add(table, statement, utf8.Valid(statement), false)
+	return statement
+}
+
+// spilled is the sampler's transform.SpillFunc. Only the head of a spilled
+// statement is read: it is sampled and checked for encoding problems.
+func (s *statementSampler) spilled(statement *spill.Buffer) (bool, error) {
+	head := statement.Head()
+	if !bytes.HasPrefix(head, []byte("INSERT INTO ")) {
+		return true, nil
+	}
+	table, ok := dumpfile.StatementTable(head)
+	if !ok {
+		return true, nil
+	}
+	s.add(table, head, validHead(head), true)
+	return true, nil
+}
+
+// add records one of a table's statements; cut says statement is only the
+// start of it
+func (s *statementSampler) add(table string, statement []byte, valid, cut bool) {
+	samples := s.tables[table]
+	if samples == nil {
+		samples = &tableSamples{}
+		s.tables[table] = samples
+		s.order = append(s.order, table)
+	}
+	samples.count++
+
+	switch {
+	case !valid && len(samples.invalid) < s.perTable:
+		samples.invalid = append(samples.invalid, s.sample(samples.count, statement, cut))
+	case len(samples.first) < s.perTable:
+		samples.first = append(samples.first, s.sample(samples.count, statement, cut))
+	case len(samples.last) < s.perTable:
+		samples.last = append(samples.last, s.sample(samples.count, statement, cut))
+	default:
+		samples.last[samples.next] = s.sample(samples.count, statement, cut)
+		samples.next = (samples.next + 1) % s.perTable
+	}
+}
+
+// validHead reports whether the start of a statement is valid UTF-8,
+// ignoring a character cut off at its end
+func validHead(head []byte) bool {
+	for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(head[i]) {
+			if !utf8.FullRune(head[i:]) {
+				head = head[:i]
+			}
+			break
+		}
+	}
+	return utf8.Valid(head)
+}
+
+// sample copies a statement with its string values shortened; cut marks
+// it as cut off even when it is short
+func (s *statementSampler) sample(index int, statement []byte, cut bool) sampledStatement {
+	sql := shortenLiterals(bytes.TrimRight(statement, "\n"), s.valueLength)
+	if cut || len(sql) > maxSampleBytes {
+		sql = append(sql[:min(len(sql), maxSampleBytes):min(len(sql), maxSampleBytes)], "\n-- (statement cut off)"...)
+	}
+	sql = append(sql, '\n')
+	return sampledStatement{index: index, sql: sql}
+}
+
+// write saves the samples to path
+func (s *statementSampler) write(path string) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create statement samples: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to write statement samples: %w", closeErr)
+		}
+	}()
+
+	w := bufio.NewWriter(file)
+	fmt.Fprintf(w, "-- dbdump statement samples: the first and last %d statements of each table\n", s.perTable)
+	fmt.Fprintf(w, "-- and any that are not valid UTF-8; string values are cut after %d characters.\n", s.valueLength)
+	fmt.Fprintf(w, "-- This file is for debugging and is not restorable.\n")
+
+	for _, table := range s.order {
+		samples := s.tables[table]
+		fmt.Fprintf(w, "\n-- Table `%s`: %d statements\n", table, samples.count)
+		for _, sample := range samples.invalid {
+			fmt.Fprintf(w, "\n-- statement %d is not valid UTF-8\n", sample.index)
+			_, _ = w.Write(sample.sql)
+		}
+		last := append(samples.last[samples.next:len(samples.last):len(samples.last)], samples.last[:samples.next]...)
+		for _, sample := range append(samples.first, last...) {
+			fmt.Fprintf(w, "\n-- statement %d\n", sample.index)
+			_, _ = w.Write(sample.sql)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write statement samples: %w", err)
+	}
+	return nil
+}
+
+// shortenLiterals copies a statement with every quoted string value longer
+// than n characters cut to n characters followed by "..."
+func shortenLiterals(statement []byte, n int) []byte {
+	out := make([]byte, 0, min(len(statement), maxSampleBytes))
+	var quote byte
+	escaped := false
+	chars := 0
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		if quote == 0 {
+			if c == '\'' || c == '"' {
+				quote, chars = c, 0
+			}
+			out = append(out, c)
+			continue
+		}
+
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == quote:
+			if chars > n {
+				out = append(out, "..."...)
+			}
+			quote = 0
+			out = append(out, c)
+			continue
+		}
+		if c&0xC0 != 0x80 && !escaped {
+			chars++
+		}
+		if chars <= n {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/spill"
+	"github.com/helgesverre/dbdump/internal/transform"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files of testdata")
+
+func TestShortenLiterals(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		n         int
+		want      string
+	}{
+		{name: "short values", statement: "INSERT INTO `t` VALUES (1,'ab','cd');", n: 4, want: "INSERT INTO `t` VALUES (1,'ab','cd');"},
+		{name: "exactly n", statement: "INSERT INTO `t` VALUES ('abcd');", n: 4, want: "INSERT INTO `t` VALUES ('abcd');"},
+		{name: "cut", statement: "INSERT INTO `t` VALUES ('secret-token',2);", n: 4, want: "INSERT INTO `t` VALUES ('secr...',2);"},
+		{name: "double quotes", statement: `INSERT INTO t VALUES ("secret-token");`, n: 6, want: `INSERT INTO t VALUES ("secret...");`},
+		{name: "escaped quote", statement: `INSERT INTO t VALUES ('it\'s a long value','x');`, n: 5, want: `INSERT INTO t VALUES ('it\'s ...','x');`},
+		{name: "escaped backslash", statement: `INSERT INTO t VALUES ('a\\','long value');`, n: 3, want: `INSERT INTO t VALUES ('a\\','lon...');`},
+		{name: "multibyte", statement: "INSERT INTO t VALUES ('ÆØÅæøå');", n: 3, want: "INSERT INTO t VALUES ('ÆØÅ...');"},
+		{name: "identifiers untouched", statement: "INSERT INTO `a_very_long_table_name` VALUES (1);", n: 2, want: "INSERT INTO `a_very_long_table_name` VALUES (1);"},
+		{name: "zero", statement: "INSERT INTO t VALUES ('x','');", n: 0, want: "INSERT INTO t VALUES ('...','');"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(shortenLiterals([]byte(tt.statement), tt.n)); got != tt.want {
+				t.Errorf("shortenLiterals(%q, %d) = %q, want %q", tt.statement, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidHead(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want bool
+	}{
+		{name: "ascii", head: "INSERT INTO t VALUES ('abc", want: true},
+		{name: "cut in a character", head: "INSERT INTO t VALUES ('æ\xc3", want: true},
+		{name: "cut in a four byte character", head: "INSERT INTO t VALUES ('\xf0\x9f\x9a", want: true},
+		{name: "invalid byte", head: "INSERT INTO t VALUES ('\xff abc", want: false},
+		{name: "invalid before the end", head: "INSERT INTO t VALUES ('\xe9t\xc3", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validHead([]byte(tt.head)); got != tt.want {
+				t.Errorf("validHead(%q) = %v, want %v", tt.head, got, tt.want)
+			}
+		})
+	}
+}
+
+// sampledDump is a data phase with enough statements per table to be
+// sampled from both ends, one that is not valid UTF-8 and one large enough
+// to spill to disk
+func sampledDump() string {
+	var b strings.Builder
+	b.WriteString("-- MySQL dump\n/*!40101 SET NAMES utf8mb4 */;\n\nLOCK TABLES `users` WRITE;\n")
+	for i := 1; i <= 7; i++ {
+		fmt.Fprintf(&b, "INSERT INTO `users` VALUES (%d,'user%d@example.com','$2y$10$abcdefghijklmnopqrstuv');\n", i, i)
+		if i == 4 {
+			b.WriteString("INSERT INTO `users` VALUES (99,'caf\xe9@example.com','latin1 leaked into utf8mb4');\n")
+		}
+	}
+	b.WriteString("UNLOCK TABLES;\nLOCK TABLES `files` WRITE;\n")
+	b.WriteString("INSERT INTO `files` VALUES (1,'" + strings.Repeat("ab", 150<<10) + "');\n")
+	b.WriteString("INSERT INTO `files` VALUES (2,'small');\nUNLOCK TABLES;\n")
+	return b.String()
+}
+
+// TestStatementSamplerOutputUnchanged writes a data phase through the
+// sampler in odd-sized chunks: the dump must come out byte for byte as it
+// went in, and the samples must match the golden file
+func TestStatementSamplerOutputUnchanged(t *testing.T) {
+	savedLimit := spill.Default.Limit()
+	defer spill.Default.SetLimit(savedLimit)
+	spill.Default.SetLimit(128 << 10)
+
+	dump := sampledDump()
+	sampler := newStatementSampler(2, 8)
+	var out bytes.Buffer
+	writer := transform.NewWriter(&out, sampler.transform).OnSpill(sampler.spilled)
+	for rest := dump; rest != ""; {
+		n := min(4093, len(rest))
+		if _, err := writer.Write([]byte(rest[:n])); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != dump {
+		t.Fatalf("sampling changed the dump: %d bytes in, %d out", len(dump), out.Len())
+	}
+
+	path := filepath.Join(t.TempDir(), "dump.sql.samples.txt")
+	if err := sampler.write(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "samples.golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("samples differ from %s\n got:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
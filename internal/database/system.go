@@ -0,0 +1,131 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// systemDatabases are the schemas the server keeps for itself
+var systemDatabases = map[string]bool{
+	"mysql":              true,
+	"sys":                true,
+	"information_schema": true,
+	"performance_schema": true,
+}
+
+// IsSystemDatabase reports whether name is one of the server's own schemas
+// (mysql, sys, information_schema, performance_schema)
+func IsSystemDatabase(name string) bool {
+	return systemDatabases[strings.ToLower(name)]
+}
+
+// DatabaseInfo describes a database on the server
+type DatabaseInfo struct {
+	Name   string `json:"name"`
+	Tables int    `json:"tables"`
+	Size   int64  `json:"size"`
+	System bool   `json:"system"`
+}
+
+// ListDatabases returns the databases visible to the user with their table
+// count and size, ordered by name
+func (i *Inspector) ListDatabases() ([]DatabaseInfo, error) {
+	rows, err := i.db.QueryContext(i.context(), `
+		SELECT s.schema_name, COUNT(t.table_name),
+			COALESCE(SUM(t.data_length + t.index_length), 0)
+		FROM information_schema.schemata s
+		LEFT JOIN information_schema.tables t ON t.table_schema = s.schema_name
+		GROUP BY s.schema_name
+		ORDER BY s.schema_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var databases []DatabaseInfo
+	for rows.Next() {
+		var info DatabaseInfo
+		if err := rows.Scan(&info.Name, &info.Tables, &info.Size); err != nil {
+			return nil, fmt.Errorf("failed to scan database: %w", err)
+		}
+		info.System = IsSystemDatabase(info.Name)
+		databases = append(databases, info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating databases: %w", err)
+	}
+
+	return databases, nil
+}
+
+// ListBaseTables returns the base tables of every database visible to the
+// user, by database
+func (i *Inspector) ListBaseTables() (map[string][]string, error) {
+	rows, err := i.db.QueryContext(i.context(), `
+		SELECT table_schema, table_name
+		FROM information_schema.tables
+		WHERE table_type = 'BASE TABLE'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	tables := make(map[string][]string)
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		tables[schema] = append(tables[schema], name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tables: %w", err)
+	}
+	return tables, nil
+}
+
+// GetDatabaseDefaults returns the default character set and collation of
+// the connected database
+func (i *Inspector) GetDatabaseDefaults() (string, string, error) {
+	var charset, collation string
+	err := i.db.QueryRowContext(i.context(), `
+		SELECT default_character_set_name, default_collation_name
+		FROM information_schema.schemata
+		WHERE schema_name = DATABASE()
+	`).Scan(&charset, &collation)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get database defaults: %w", err)
+	}
+	return charset, collation, nil
+}
+
+// CreateDatabaseStatements returns the statements mysqldump --databases
+// writes before a database's tables: CREATE DATABASE IF NOT EXISTS with its
+// defaults and USE, preceded by DROP DATABASE when drop is set
+func CreateDatabaseStatements(name, charset, collation string, drop bool) string {
+	quoted := sqlident.Quote(name)
+
+	var b strings.Builder
+	b.WriteString("\n")
+	if drop {
+		fmt.Fprintf(&b, "/*!40000 DROP DATABASE IF EXISTS %s*/;\n\n", quoted)
+	}
+	fmt.Fprintf(&b, "CREATE DATABASE /*!32312 IF NOT EXISTS*/ %s", quoted)
+	if charset != "" {
+		fmt.Fprintf(&b, " /*!40100 DEFAULT CHARACTER SET %s", charset)
+		if collation != "" {
+			fmt.Fprintf(&b, " COLLATE %s", collation)
+		}
+		b.WriteString(" */")
+	}
+	fmt.Fprintf(&b, ";\n\nUSE %s;\n", quoted)
+	return b.String()
+}
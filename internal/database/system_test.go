@@ -0,0 +1,54 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestIsSystemDatabase(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "mysql", want: true},
+		{name: "sys", want: true},
+		{name: "information_schema", want: true},
+		{name: "performance_schema", want: true},
+		{name: "MySQL", want: true},
+		{name: "PERFORMANCE_SCHEMA", want: true},
+		{name: "mysql_app"},
+		{name: "shop"},
+		{name: ""},
+	}
+	for _, tt := range tests {
+		if got := IsSystemDatabase(tt.name); got != tt.want {
+			t.Errorf("IsSystemDatabase(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestListDatabases(t *testing.T) {
+	inspector, mock := newMockInspector(t)
+	mock.ExpectQuery(`FROM information_schema\.schemata`).WillReturnRows(
+		sqlmock.NewRows([]string{"schema_name", "tables", "size"}).
+			AddRow("information_schema", 79, 0).
+			AddRow("mysql", 38, 2<<20).
+			AddRow("shop", 12, 40<<20).
+			AddRow("sys", 101, 16<<10))
+
+	databases, err := inspector.ListDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DatabaseInfo{
+		{Name: "information_schema", Tables: 79, System: true},
+		{Name: "mysql", Tables: 38, Size: 2 << 20, System: true},
+		{Name: "shop", Tables: 12, Size: 40 << 20},
+		{Name: "sys", Tables: 101, Size: 16 << 10, System: true},
+	}
+	if !reflect.DeepEqual(databases, want) {
+		t.Errorf("ListDatabases() =\n%+v\nwant\n%+v", databases, want)
+	}
+}
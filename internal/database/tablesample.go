@@ -0,0 +1,117 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// TableSample asks for the last rows of a data-excluded table to be dumped
+type TableSample struct {
+	Table string
+	Rows  int
+
+	// OrderBy are the primary key columns; the rows with the highest keys
+	// are dumped. Without them an arbitrary LIMIT is used.
+	OrderBy []string
+}
+
+// where returns the mysqldump --where condition selecting the sample
+func (s TableSample) where() string {
+	if len(s.OrderBy) == 0 {
+		return fmt.Sprintf("1 LIMIT %d", s.Rows)
+	}
+	keys := make([]string, len(s.OrderBy))
+	for i, column := range s.OrderBy {
+		keys[i] = sqlident.Quote(column) + " DESC"
+	}
+	return fmt.Sprintf("1 ORDER BY %s LIMIT %d", strings.Join(keys, ", "), s.Rows)
+}
+
+// GetPrimaryKey returns the primary key columns of a table in key order,
+// or nil if it has none
+func (i *Inspector) GetPrimaryKey(tableName string) ([]string, error) {
+	rows, err := i.db.QueryContext(i.context(), `
+		SELECT column_name
+		FROM information_schema.statistics
+		WHERE table_schema = DATABASE()
+		AND table_name = ?
+		AND index_name = 'PRIMARY'
+		ORDER BY seq_in_index
+	`, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get primary key of %s: %w", tableName, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("failed to scan primary key column: %w", err)
+		}
+		columns = append(columns, column)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating primary key columns: %w", err)
+	}
+
+	return columns, nil
+}
+
+// dumpSamples dumps the sampled rows of each table in Samples, one
+// mysqldump run per table since --where applies to all tables of a run
+func (d *Dumper) dumpSamples(writer io.Writer) error {
+	if d.options.Native {
+		return d.nativeSamples(writer)
+	}
+	ctx := d.context()
+
+	for _, sample := range d.options.Samples {
+		args := d.buildMySQLDumpArgs()
+		args = append(args,
+			"--no-create-info",
+			"--skip-triggers",
+			"--skip-routines",
+			"--skip-events",
+			"--set-gtid-purged=OFF",
+			"--column-statistics=0",
+			"--where="+sample.where(),
+			d.options.Connection.Database,
+			sample.Table,
+		)
+
+		if _, err := fmt.Fprintf(writer, "\n-- Sample of %s: last %d rows\n", sample.Table, sample.Rows); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+
+		cmd := exec.CommandContext(ctx, "mysqldump", args...)
+		cmd.Stdout = writer
+		stderr := &stderrTail{}
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+		// Pass the password via MYSQL_PWD; tokens are minted right before each run
+		env, err := d.options.Connection.ClientEnv(ctx)
+		if err != nil {
+			return err
+		}
+		cmd.Env = env
+
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w: mysqldump sample of %s: %w", dberrors.ErrDumpInterrupted, sample.Table, err)
+			}
+			return classifyDumpError("samples", err, stderr.buf)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/timezone"
+)
+
+// GetTimeZone returns the server's global time zone, which new sessions
+// (mysqldump's, and a restore's) start in, with its current UTC offset
+func (i *Inspector) GetTimeZone() (timezone.Zone, error) {
+	var timeZone, systemTimeZone string
+	var seconds int64
+	// NOW() follows the session's zone, which starts as the global one
+	err := i.db.QueryRowContext(i.context(),
+		"SELECT @@global.time_zone, @@system_time_zone, TIMESTAMPDIFF(SECOND, UTC_TIMESTAMP(), NOW())").
+		Scan(&timeZone, &systemTimeZone, &seconds)
+	if err != nil {
+		return timezone.Zone{}, fmt.Errorf("failed to get the server time zone: %w", err)
+	}
+	// TIMESTAMPDIFF truncates; offsets are whole minutes
+	offset := (time.Duration(seconds) * time.Second).Round(time.Minute)
+	return timezone.Server(timeZone, systemTimeZone, offset), nil
+}
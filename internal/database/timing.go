@@ -0,0 +1,139 @@
+package database
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+)
+
+// timingHeadSize is how much of each line is kept to detect the table it belongs to
+const timingHeadSize = 256
+
+// TableTiming is the wall time and output size of one table's data
+type TableTiming struct {
+	Table    string
+	Duration time.Duration
+	Bytes    int64
+}
+
+// TableTimer is an io.Writer that observes data-phase output and attributes
+// wall time and bytes to tables as mysqldump moves from one table to the
+// next. Times are measured when output arrives, so the time the server spends
+// before sending a table's first row is counted towards the previous table.
+type TableTimer struct {
+	timings   []TableTiming
+	current   int // index into timings, -1 before the first table
+	started   time.Time
+	head      []byte
+	lineBytes int64
+	longest   int64
+	written   int64
+
+	// onLine, if set, is called after each complete line, and onDone with
+	// the timing of each table the timer moves past
+	onLine func()
+	onDone func(TableTiming)
+}
+
+// NewTableTimer creates a TableTimer; call Start when the phase begins
+func NewTableTimer() *TableTimer {
+	return &TableTimer{current: -1}
+}
+
+// Start marks the beginning of the data phase
+func (t *TableTimer) Start() {
+	t.started = time.Now()
+}
+
+// Write implements io.Writer
+func (t *TableTimer) Write(p []byte) (int, error) {
+	data := p
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		chunk := data
+		if i >= 0 {
+			chunk = data[:i+1]
+		}
+
+		if room := timingHeadSize - len(t.head); room > 0 {
+			t.head = append(t.head, chunk[:min(room, len(chunk))]...)
+		}
+		t.lineBytes += int64(len(chunk))
+
+		if i < 0 {
+			break
+		}
+		t.endLine()
+		data = data[i+1:]
+	}
+	return len(p), nil
+}
+
+// endLine attributes a completed line to its table, switching tables if needed
+func (t *TableTimer) endLine() {
+	if table, ok := dumpfile.StatementTable(t.head); ok && (t.current < 0 || t.timings[t.current].Table != table) {
+		t.switchTo(table)
+	}
+	if t.current >= 0 {
+		t.timings[t.current].Bytes += t.lineBytes
+	}
+	t.longest = max(t.longest, t.lineBytes)
+	t.written += t.lineBytes
+	t.head = t.head[:0]
+	t.lineBytes = 0
+	if t.onLine != nil {
+		t.onLine()
+	}
+}
+
+// Current returns the table whose data is being written, or "" before the
+// first table
+func (t *TableTimer) Current() string {
+	if t.current < 0 {
+		return ""
+	}
+	return t.timings[t.current].Table
+}
+
+// switchTo closes the current table's timing and starts a new one
+func (t *TableTimer) switchTo(table string) {
+	now := time.Now()
+	if t.current >= 0 {
+		t.timings[t.current].Duration += now.Sub(t.started)
+		t.done()
+	}
+	t.started = now
+
+	for i := range t.timings {
+		if t.timings[i].Table == table {
+			t.current = i
+			return
+		}
+	}
+	t.timings = append(t.timings, TableTiming{Table: table})
+	t.current = len(t.timings) - 1
+}
+
+// Finish ends the current table's timing and returns all timings in dump order
+func (t *TableTimer) Finish() []TableTiming {
+	if t.current >= 0 {
+		t.timings[t.current].Duration += time.Since(t.started)
+		t.done()
+		t.current = -1
+	}
+	return t.timings
+}
+
+// done passes the current table's timing to onDone
+func (t *TableTimer) done() {
+	if t.onDone != nil {
+		t.onDone(t.timings[t.current])
+	}
+}
+
+// Longest returns the size of the longest line seen; mysqldump writes each
+// statement on one line, so this is the largest statement
+func (t *TableTimer) Longest() int64 {
+	return t.longest
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTableTimer(t *testing.T) {
+	lines := []string{
+		"-- MySQL dump\n",
+		"LOCK TABLES `users` WRITE;\n",
+		"INSERT INTO `users` VALUES (1),(2);\n",
+		"UNLOCK TABLES;\n",
+		"LOCK TABLES `order``items` WRITE;\n",
+		"INSERT INTO `order``items` VALUES (1);\n",
+		"UNLOCK TABLES;\n",
+		"INSERT INTO `users` VALUES (3);\n",
+	}
+	dump := strings.Join(lines, "")
+	size := func(lines ...string) int64 { return int64(len(strings.Join(lines, ""))) }
+
+	tests := []struct {
+		name  string
+		chunk int
+	}{
+		{name: "whole", chunk: len(dump)},
+		{name: "lines split", chunk: 7},
+		{name: "byte by byte", chunk: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timer := NewTableTimer()
+			var done []string
+			timer.onDone = func(timing TableTiming) { done = append(done, timing.Table) }
+			timer.Start()
+			for rest := dump; rest != ""; {
+				n := min(tt.chunk, len(rest))
+				if _, err := timer.Write([]byte(rest[:n])); err != nil {
+					t.Fatal(err)
+				}
+				rest = rest[n:]
+			}
+			timings := timer.Finish()
+
+			var tables []string
+			var bytes []int64
+			for _, timing := range timings {
+				tables = append(tables, timing.Table)
+				bytes = append(bytes, timing.Bytes)
+			}
+			if want := []string{"users", "order`items"}; !reflect.DeepEqual(tables, want) {
+				t.Errorf("tables = %q, want %q", tables, want)
+			}
+			// The header is before any table; UNLOCK TABLES stays with the table it ends
+			if want := []int64{size(lines[1], lines[2], lines[3], lines[7]), size(lines[4:7]...)}; !reflect.DeepEqual(bytes, want) {
+				t.Errorf("bytes = %v, want %v", bytes, want)
+			}
+			if want := []string{"users", "order`items", "users"}; !reflect.DeepEqual(done, want) {
+				t.Errorf("tables done = %q, want %q", done, want)
+			}
+			if got, want := timer.Longest(), size(lines[5]); got != want {
+				t.Errorf("Longest() = %d, want %d", got, want)
+			}
+			if got := timer.Current(); got != "" {
+				t.Errorf("Current() = %q after Finish", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// GetVariables returns the global values of the named server variables;
+// names the server doesn't have are left out
+func (i *Inspector) GetVariables(names []string) (map[string]string, error) {
+	rows, err := i.db.QueryContext(i.context(), "SHOW GLOBAL VARIABLES")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server variables: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	values := make(map[string]string, len(names))
+	for rows.Next() {
+		var name string
+		var value sql.NullString
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan server variable: %w", err)
+		}
+		if slices.Contains(names, name) {
+			values[name] = value.String
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating server variables: %w", err)
+	}
+	return values, nil
+}
@@ -0,0 +1,245 @@
+// Package dberrors defines the error classes callers can match with errors.Is
+// and errors.As. Every error keeps its underlying cause available through
+// Unwrap so the original driver or exec error is never lost.
+package dberrors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMySQLDumpNotFound is returned when the mysqldump binary cannot be executed
+var ErrMySQLDumpNotFound = errors.New("mysqldump not found in PATH")
+
+// ErrDumpInterrupted is returned when a dump is cancelled (Ctrl+C or SIGTERM)
+var ErrDumpInterrupted = errors.New("dump interrupted")
+
+// ErrConnectionFailed is returned when the database cannot be reached or
+// rejects the connection. Code is the MySQL error number when known, or 0.
+type ErrConnectionFailed struct {
+	Code uint16
+	Err  error
+}
+
+func (e *ErrConnectionFailed) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("connection failed (MySQL error %d): %v", e.Code, e.Err)
+	}
+	return fmt.Sprintf("connection failed: %v", e.Err)
+}
+
+func (e *ErrConnectionFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrVerificationFailed is returned when a dump fails one or more verification checks
+type ErrVerificationFailed struct {
+	Checks []string
+	Err    error
+}
+
+func (e *ErrVerificationFailed) Error() string {
+	msg := fmt.Sprintf("verification failed: %s", strings.Join(e.Checks, ", "))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ErrVerificationFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrConfigInvalid is returned when configuration cannot be loaded or contains
+// invalid values. Source names the file (or flag) the problems came from.
+type ErrConfigInvalid struct {
+	Source   string
+	Problems []string
+	Err      error
+}
+
+func (e *ErrConfigInvalid) Error() string {
+	msg := "invalid configuration"
+	if e.Source != "" {
+		msg += " in " + e.Source
+	}
+	if len(e.Problems) > 0 {
+		msg += ": " + strings.Join(e.Problems, "; ")
+	} else if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ErrConfigInvalid) Unwrap() error {
+	return e.Err
+}
+
+// ErrOutputPath is returned when an output file cannot be written. Op names
+// the operation that failed (e.g. "create probe file") and Path the file or
+// directory it was attempted on.
+type ErrOutputPath struct {
+	Path string
+	Op   string
+	Err  error
+}
+
+func (e *ErrOutputPath) Error() string {
+	return fmt.Sprintf("output path %s: %s: %v", e.Path, e.Op, e.Err)
+}
+
+func (e *ErrOutputPath) Unwrap() error {
+	return e.Err
+}
+
+// ErrOutputFull is returned when the filesystem refuses more dump output,
+// either because it is full (NoSpace) or because the file reached the
+// largest size the filesystem allows. Offset is the size the file reached
+// and Filesystem the filesystem type, when known.
+type ErrOutputFull struct {
+	Path       string
+	Offset     int64
+	Filesystem string
+	NoSpace    bool
+	Err        error
+}
+
+func (e *ErrOutputFull) Error() string {
+	filesystem := e.Filesystem
+	if filesystem == "" {
+		filesystem = "output"
+	}
+	if e.NoSpace {
+		return fmt.Sprintf("no space left on the %s filesystem: %s stopped at byte %d", filesystem, e.Path, e.Offset)
+	}
+	return fmt.Sprintf("%s reached the largest file the %s filesystem allows, at byte %d", e.Path, filesystem, e.Offset)
+}
+
+func (e *ErrOutputFull) Unwrap() error {
+	return e.Err
+}
+
+// ErrTableDefChanged is returned when mysqldump fails with ER_TABLE_DEF_CHANGED
+// (1412) because a table was altered while it was being dumped, and the phase
+// could not be completed within the allowed restarts. Table is empty when
+// mysqldump's message didn't name it.
+type ErrTableDefChanged struct {
+	Table    string
+	Attempts int
+	Err      error
+}
+
+func (e *ErrTableDefChanged) Error() string {
+	table := "a table"
+	if e.Table != "" {
+		table = "table " + e.Table
+	}
+	msg := fmt.Sprintf("%s was altered during the dump (table definition has changed)", table)
+	if e.Attempts > 1 {
+		msg += fmt.Sprintf(", after %d attempts", e.Attempts)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ErrTableDefChanged) Unwrap() error {
+	return e.Err
+}
+
+// ErrTablesChanged is returned with --strict-plan when tables appeared in or
+// disappeared from the database between selecting them and dumping them
+type ErrTablesChanged struct {
+	Added   []string
+	Removed []string
+}
+
+func (e *ErrTablesChanged) Error() string {
+	var changes []string
+	if len(e.Added) > 0 {
+		changes = append(changes, fmt.Sprintf("%d added (%s)", len(e.Added), strings.Join(e.Added, ", ")))
+	}
+	if len(e.Removed) > 0 {
+		changes = append(changes, fmt.Sprintf("%d removed (%s)", len(e.Removed), strings.Join(e.Removed, ", ")))
+	}
+	return "tables changed since the dump was planned: " + strings.Join(changes, ", ")
+}
+
+// ErrMySQLDumpFailed is returned when mysqldump exits with an error. Usage is
+// set when it rejected its arguments (e.g. an option the installed client
+// doesn't support) and so wrote nothing; otherwise it failed mid-stream and
+// its output is incomplete. Message is the last line of its stderr, and
+// Table the table being dumped when it failed, if known.
+type ErrMySQLDumpFailed struct {
+	Phase    string
+	Table    string
+	ExitCode int
+	Usage    bool
+	Message  string
+	Err      error
+}
+
+func (e *ErrMySQLDumpFailed) Error() string {
+	msg := fmt.Sprintf("mysqldump %s failed (exit code %d)", e.Phase, e.ExitCode)
+	if e.Table != "" {
+		msg = fmt.Sprintf("mysqldump %s failed at table %s (exit code %d)", e.Phase, e.Table, e.ExitCode)
+	}
+	if e.Usage {
+		msg = fmt.Sprintf("mysqldump rejected the %s options (exit code %d)", e.Phase, e.ExitCode)
+	}
+	if e.Message != "" {
+		return msg + ": " + e.Message
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ErrMySQLDumpFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrWarnings is returned when a run finished with warnings and
+// --warnings-as-errors is set. Count is the number of distinct warnings.
+type ErrWarnings struct {
+	Count int
+}
+
+func (e *ErrWarnings) Error() string {
+	if e.Count == 1 {
+		return "completed with 1 warning"
+	}
+	return fmt.Sprintf("completed with %d warnings", e.Count)
+}
+
+// ErrOverBudget is returned when a dump finished within --time-budget by
+// leaving out the data of some tables; the dump itself is valid
+type ErrOverBudget struct {
+	Tables []string
+}
+
+func (e *ErrOverBudget) Error() string {
+	if len(e.Tables) == 1 {
+		return "completed without the data of 1 table to stay within the time budget"
+	}
+	return fmt.Sprintf("completed without the data of %d tables to stay within the time budget", len(e.Tables))
+}
+
+// ErrPartialFailure is returned when a run over several databases finished
+// but some of them failed or were skipped
+type ErrPartialFailure struct {
+	Failed  int
+	Skipped int
+	Total   int
+}
+
+func (e *ErrPartialFailure) Error() string {
+	msg := fmt.Sprintf("%d of %d databases failed", e.Failed, e.Total)
+	if e.Skipped > 0 {
+		msg += fmt.Sprintf(", %d skipped", e.Skipped)
+	}
+	return msg
+}
@@ -0,0 +1,233 @@
+package dberrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// wrapped wraps err the way the call sites do, so the tests match through
+// at least one layer of context
+func wrapped(err error) error {
+	return fmt.Errorf("dump shop: %w", err)
+}
+
+func TestSentinels(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		sentinel error
+		cause    error
+	}{
+		{
+			name:     "mysqldump not found",
+			err:      fmt.Errorf("%w: %w", ErrMySQLDumpNotFound, exec.ErrNotFound),
+			sentinel: ErrMySQLDumpNotFound,
+			cause:    exec.ErrNotFound,
+		},
+		{
+			name:     "dump interrupted",
+			err:      fmt.Errorf("%w: mysqldump data: %w", ErrDumpInterrupted, context.Canceled),
+			sentinel: ErrDumpInterrupted,
+			cause:    context.Canceled,
+		},
+		{
+			name:     "interrupted by a deadline",
+			err:      fmt.Errorf("%w: native dump of users: %w", ErrDumpInterrupted, context.DeadlineExceeded),
+			sentinel: ErrDumpInterrupted,
+			cause:    context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := wrapped(tt.err)
+			if !errors.Is(err, tt.sentinel) {
+				t.Errorf("errors.Is(%q, %q) = false", err, tt.sentinel)
+			}
+			if !errors.Is(err, tt.cause) {
+				t.Errorf("errors.Is(%q, %q) = false: the cause was lost", err, tt.cause)
+			}
+		})
+	}
+
+	if errors.Is(ErrMySQLDumpNotFound, ErrDumpInterrupted) || errors.Is(ErrDumpInterrupted, ErrMySQLDumpNotFound) {
+		t.Error("the sentinels match each other")
+	}
+}
+
+// TestAs checks that every typed error is found by errors.As through a
+// wrapping layer, exposes its fields, and still reaches its cause
+func TestAs(t *testing.T) {
+	cause := &fs.PathError{Op: "write", Path: "/backups/shop.sql", Err: syscall.ENOSPC}
+
+	t.Run("connection failed", func(t *testing.T) {
+		var target *ErrConnectionFailed
+		err := wrapped(&ErrConnectionFailed{Code: 1045, Err: cause})
+		if !errors.As(err, &target) || target.Code != 1045 {
+			t.Fatalf("errors.As = %v, %+v", errors.As(err, &target), target)
+		}
+		checkCause(t, err, cause)
+	})
+	t.Run("verification failed", func(t *testing.T) {
+		var target *ErrVerificationFailed
+		err := wrapped(&ErrVerificationFailed{Checks: []string{"footer", "tables"}, Err: cause})
+		if !errors.As(err, &target) || len(target.Checks) != 2 {
+			t.Fatalf("errors.As = %v, %+v", errors.As(err, &target), target)
+		}
+		checkCause(t, err, cause)
+	})
+	t.Run("config invalid", func(t *testing.T) {
+		var target *ErrConfigInvalid
+		err := wrapped(&ErrConfigInvalid{Source: ".dbdump.yaml", Err: cause})
+		if !errors.As(err, &target) || target.Source != ".dbdump.yaml" {
+			t.Fatalf("errors.As = %v, %+v", errors.As(err, &target), target)
+		}
+		checkCause(t, err, cause)
+	})
+	t.Run("output path", func(t *testing.T) {
+		var target *ErrOutputPath
+		err := wrapped(&ErrOutputPath{Path: "/backups", Op: "create probe file", Err: cause})
+		if !errors.As(err, &target) || target.Op != "create probe file" {
+			t.Fatalf("errors.As = %v, %+v", errors.As(err, &target), target)
+		}
+		checkCause(t, err, cause)
+	})
+	t.Run("output full", func(t *testing.T) {
+		var target *ErrOutputFull
+		err := wrapped(&ErrOutputFull{Path: "/backups/shop.sql", Offset: 4096, NoSpace: true, Err: cause})
+		if !errors.As(err, &target) || target.Offset != 4096 {
+			t.Fatalf("errors.As = %v, %+v", errors.As(err, &target), target)
+		}
+		checkCause(t, err, cause)
+		if !errors.Is(err, syscall.ENOSPC) {
+			t.Error("errors.Is(err, ENOSPC) = false")
+		}
+	})
+	t.Run("table definition changed", func(t *testing.T) {
+		var target *ErrTableDefChanged
+		err := wrapped(&ErrTableDefChanged{Table: "users", Attempts: 3, Err: cause})
+		if !errors.As(err, &target) || target.Table != "users" {
+			t.Fatalf("errors.As = %v, %+v", errors.As(err, &target), target)
+		}
+		checkCause(t, err, cause)
+	})
+	t.Run("mysqldump failed", func(t *testing.T) {
+		var target *ErrMySQLDumpFailed
+		exitErr := &exec.ExitError{}
+		err := wrapped(&ErrMySQLDumpFailed{Phase: "data", ExitCode: 2, Err: exitErr})
+		if !errors.As(err, &target) || target.Phase != "data" {
+			t.Fatalf("errors.As = %v, %+v", errors.As(err, &target), target)
+		}
+		var gotExit *exec.ExitError
+		if !errors.As(err, &gotExit) || gotExit != exitErr {
+			t.Error("errors.As doesn't reach the *exec.ExitError")
+		}
+	})
+	t.Run("typed errors wrapping a sentinel", func(t *testing.T) {
+		err := wrapped(&ErrMySQLDumpFailed{Phase: "data", ExitCode: -1, Err: fmt.Errorf("%w: %w", ErrDumpInterrupted, context.Canceled)})
+		if !errors.Is(err, ErrDumpInterrupted) || !errors.Is(err, context.Canceled) {
+			t.Errorf("errors.Is doesn't see the interruption through %q", err)
+		}
+	})
+}
+
+// TestAsMismatch checks that errors.As doesn't confuse the typed errors
+func TestAsMismatch(t *testing.T) {
+	errs := []error{
+		&ErrConnectionFailed{Err: errors.New("refused")},
+		&ErrVerificationFailed{Checks: []string{"footer"}},
+		&ErrConfigInvalid{Problems: []string{"bad"}},
+		&ErrOutputPath{Err: errors.New("denied")},
+		&ErrOutputFull{Err: errors.New("full")},
+		&ErrTableDefChanged{Err: errors.New("changed")},
+		&ErrTablesChanged{Added: []string{"t"}},
+		&ErrMySQLDumpFailed{Err: errors.New("exit 2")},
+		&ErrWarnings{Count: 1},
+		&ErrOverBudget{Tables: []string{"t"}},
+		&ErrPartialFailure{Failed: 1, Total: 2},
+	}
+	for i, err := range errs {
+		for j, other := range errs {
+			// A pointer to a nil variable of other's type, for errors.As to fill in
+			target := reflect.New(reflect.TypeOf(other)).Interface()
+			if got := errors.As(wrapped(err), target); got != (i == j) {
+				t.Errorf("errors.As(%T, %T) = %v", err, other, got)
+			}
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	cause := errors.New("boom")
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"connection with code", &ErrConnectionFailed{Code: 1045, Err: cause}, "connection failed (MySQL error 1045): boom"},
+		{"connection without code", &ErrConnectionFailed{Err: cause}, "connection failed: boom"},
+		{"verification", &ErrVerificationFailed{Checks: []string{"footer", "tables"}}, "verification failed: footer, tables"},
+		{"verification with cause", &ErrVerificationFailed{Checks: []string{"footer"}, Err: cause}, "verification failed: footer: boom"},
+		{"config problems", &ErrConfigInvalid{Source: ".dbdump.yaml", Problems: []string{"a", "b"}, Err: cause}, "invalid configuration in .dbdump.yaml: a; b"},
+		{"config cause", &ErrConfigInvalid{Err: cause}, "invalid configuration: boom"},
+		{"config bare", &ErrConfigInvalid{}, "invalid configuration"},
+		{"output path", &ErrOutputPath{Path: "/out", Op: "create", Err: cause}, "output path /out: create: boom"},
+		{"output full", &ErrOutputFull{Path: "a.sql", Offset: 10, Filesystem: "ext4", NoSpace: true}, "no space left on the ext4 filesystem: a.sql stopped at byte 10"},
+		{"output too large", &ErrOutputFull{Path: "a.sql", Offset: 10}, "a.sql reached the largest file the output filesystem allows, at byte 10"},
+		{"table def named", &ErrTableDefChanged{Table: "users", Attempts: 2, Err: cause}, "table users was altered during the dump (table definition has changed), after 2 attempts: boom"},
+		{"table def unnamed", &ErrTableDefChanged{Attempts: 1, Err: cause}, "a table was altered during the dump (table definition has changed): boom"},
+		{"tables changed", &ErrTablesChanged{Added: []string{"a"}, Removed: []string{"b", "c"}}, "tables changed since the dump was planned: 1 added (a), 2 removed (b, c)"},
+		{"mysqldump message", &ErrMySQLDumpFailed{Phase: "data", ExitCode: 2, Message: "Got error", Err: cause}, "mysqldump data failed (exit code 2): Got error"},
+		{"mysqldump table", &ErrMySQLDumpFailed{Phase: "data", Table: "users", ExitCode: 2, Err: cause}, "mysqldump data failed at table users (exit code 2): boom"},
+		{"mysqldump usage", &ErrMySQLDumpFailed{Phase: "structure", ExitCode: 7, Usage: true, Err: cause}, "mysqldump rejected the structure options (exit code 7): boom"},
+		{"one warning", &ErrWarnings{Count: 1}, "completed with 1 warning"},
+		{"warnings", &ErrWarnings{Count: 3}, "completed with 3 warnings"},
+		{"over budget", &ErrOverBudget{Tables: []string{"a", "b"}}, "completed without the data of 2 tables to stay within the time budget"},
+		{"partial", &ErrPartialFailure{Failed: 1, Skipped: 2, Total: 5}, "1 of 5 databases failed, 2 skipped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() =\n %q, want\n %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNilCause checks that the errors whose cause is optional unwrap to nil
+// rather than panicking
+func TestNilCause(t *testing.T) {
+	for _, err := range []interface{ Unwrap() error }{
+		&ErrConnectionFailed{},
+		&ErrVerificationFailed{Checks: []string{"footer"}},
+		&ErrConfigInvalid{Problems: []string{"bad"}},
+		&ErrOutputFull{Path: "a.sql"},
+		&ErrTableDefChanged{Table: "orders", Attempts: 3},
+		&ErrMySQLDumpFailed{Phase: "data", ExitCode: 2},
+	} {
+		if err.Unwrap() != nil {
+			t.Errorf("%T.Unwrap() = %v, want nil", err, err.Unwrap())
+		}
+		if strings.Contains(err.(error).Error(), "%!") {
+			t.Errorf("%T.Error() = %q", err, err.(error).Error())
+		}
+	}
+}
+
+// checkCause fails unless err reaches cause through errors.Is and errors.As
+func checkCause(t *testing.T, err error, cause *fs.PathError) {
+	t.Helper()
+	if !errors.Is(err, cause) {
+		t.Errorf("errors.Is(%q, cause) = false", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr != cause {
+		t.Errorf("errors.As(%q, *fs.PathError) doesn't reach the cause", err)
+	}
+}
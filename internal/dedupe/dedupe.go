@@ -0,0 +1,94 @@
+// Package dedupe finds tables with the same contents in several databases
+// of one run (lookup tables shared by tenant databases), so the dumps after
+// the first can copy them from the database dumped first instead of
+// repeating their rows
+package dedupe
+
+import "slices"
+
+// Fingerprint identifies a table's contents: its schema fingerprint, exact
+// row count and CHECKSUM TABLE value. Tables are only treated as identical
+// when all three match.
+type Fingerprint struct {
+	Schema   string
+	Rows     int64
+	Checksum int64
+}
+
+// Source is the first dumped instance of a table's contents
+type Source struct {
+	Database string
+	Bytes    int64 // size of its data in that dump
+}
+
+// Copy is a table dumped as a copy of its source
+type Copy struct {
+	Table string
+	From  string
+	Saved int64 // bytes of data not repeated
+}
+
+// key identifies a table's contents across databases
+type key struct {
+	table string
+	Fingerprint
+}
+
+// Registry tracks the tables dumped so far in a run. It is not safe for
+// concurrent use.
+type Registry struct {
+	candidates map[string]bool
+	sources    map[key]Source
+	copies     map[string][]Copy
+}
+
+// NewRegistry creates a registry for a run over the databases given with
+// their tables; only tables whose name appears in more than one of them
+// are candidates for deduplication
+func NewRegistry(tables map[string][]string) *Registry {
+	seen := make(map[string]int)
+	for _, names := range tables {
+		for _, name := range slices.Compact(slices.Sorted(slices.Values(names))) {
+			seen[name]++
+		}
+	}
+	candidates := make(map[string]bool)
+	for name, count := range seen {
+		if count > 1 {
+			candidates[name] = true
+		}
+	}
+	return &Registry{candidates: candidates, sources: make(map[key]Source), copies: make(map[string][]Copy)}
+}
+
+// Candidate reports whether a table is worth fingerprinting: another
+// database of the run has a table of the same name
+func (r *Registry) Candidate(table string) bool {
+	return r.candidates[table]
+}
+
+// Source returns the database a table with these contents was dumped from
+// first, if any
+func (r *Registry) Source(table string, fingerprint Fingerprint) (Source, bool) {
+	source, ok := r.sources[key{table, fingerprint}]
+	return source, ok
+}
+
+// AddSource records a table dumped in full, unless identical contents were
+// already recorded
+func (r *Registry) AddSource(database, table string, fingerprint Fingerprint, bytes int64) {
+	k := key{table, fingerprint}
+	if _, ok := r.sources[k]; !ok {
+		r.sources[k] = Source{Database: database, Bytes: bytes}
+	}
+}
+
+// AddCopy records a table dumped as a copy in a database
+func (r *Registry) AddCopy(database string, c Copy) {
+	r.copies[database] = append(r.copies[database], c)
+}
+
+// Copies returns the tables of a database dumped as copies
+func (r *Registry) Copies(database string) []Copy {
+	return r.copies[database]
+}
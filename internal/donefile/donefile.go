@@ -0,0 +1,132 @@
+// Package donefile writes the completion signal of --done-file: a small
+// JSON file that appears only once the dump and its sidecar are on disk,
+// for watchers that must not pick up a dump still being written
+package donefile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/fileutil"
+)
+
+// Signal is the content of the done-file
+type Signal struct {
+	OutputFile  string    `json:"output_file"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256,omitempty"` // of the whole file; split dumps have it per part
+	Parts       []Part    `json:"parts,omitempty"`
+	Sidecar     string    `json:"sidecar,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// Part is one file of a split dump
+type Part struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// FS is the filesystem the done-file is written through, in the order
+// Clear and Write call it
+type FS interface {
+	// Remove deletes a file; a missing one is not an error
+	Remove(path string) error
+
+	// WriteFile writes a file through a synced temporary file and a rename
+	WriteFile(path string, data []byte, perm os.FileMode) error
+
+	// SyncDir flushes a directory's entries (creations, renames) to storage
+	SyncDir(dir string) error
+}
+
+// OS is the real filesystem
+var OS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Remove(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (osFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return fileutil.WriteFileAtomic(path, data, perm)
+}
+
+func (osFS) SyncDir(dir string) error {
+	return fileutil.SyncDir(dir)
+}
+
+// Clear removes a done-file left by an earlier run, so a watcher never
+// takes it for this run's
+func Clear(fs FS, path string) error {
+	if err := fs.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale done-file %s: %w", path, err)
+	}
+	if err := fs.SyncDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", filepath.Dir(path), err)
+	}
+	return nil
+}
+
+// Write writes the done-file for a finished dump. The directories of the
+// dump, its parts and its sidecar are synced first, so every entry the
+// signal names is durable before the signal itself appears; the done-file's
+// own directory is synced last.
+func Write(fs FS, path string, signal Signal) error {
+	dirs := []string{filepath.Dir(signal.OutputFile)}
+	for _, part := range signal.Parts {
+		dirs = append(dirs, filepath.Dir(part.Path))
+	}
+	if signal.Sidecar != "" {
+		dirs = append(dirs, filepath.Dir(signal.Sidecar))
+	}
+	synced := make(map[string]bool)
+	for _, dir := range dirs {
+		if synced[dir] {
+			continue
+		}
+		synced[dir] = true
+		if err := fs.SyncDir(dir); err != nil {
+			return fmt.Errorf("failed to sync %s: %w", dir, err)
+		}
+	}
+
+	data, err := json.MarshalIndent(signal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal done-file: %w", err)
+	}
+	if err := fs.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write done-file %s: %w", path, err)
+	}
+	if err := fs.SyncDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", filepath.Dir(path), err)
+	}
+	return nil
+}
+
+// Checksum returns the SHA-256 and size of a file
+func Checksum(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
@@ -0,0 +1,215 @@
+package donefile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordingFS records the operations made through it and fails the one
+// named in failOn
+type recordingFS struct {
+	ops    []string
+	files  map[string][]byte
+	failOn string
+}
+
+var errInjected = errors.New("injected failure")
+
+func (fs *recordingFS) do(op string) error {
+	fs.ops = append(fs.ops, op)
+	if op == fs.failOn {
+		return errInjected
+	}
+	return nil
+}
+
+func (fs *recordingFS) Remove(path string) error {
+	return fs.do("remove " + path)
+}
+
+func (fs *recordingFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	if err := fs.do("write " + path); err != nil {
+		return err
+	}
+	if fs.files == nil {
+		fs.files = make(map[string][]byte)
+	}
+	fs.files[path] = data
+	return nil
+}
+
+func (fs *recordingFS) SyncDir(dir string) error {
+	return fs.do("sync " + dir)
+}
+
+func TestClear(t *testing.T) {
+	tests := []struct {
+		name   string
+		failOn string
+		want   []string
+	}{
+		{name: "removes then syncs", want: []string{"remove /signals/shop.done", "sync /signals"}},
+		{name: "remove fails", failOn: "remove /signals/shop.done", want: []string{"remove /signals/shop.done"}},
+		{name: "sync fails", failOn: "sync /signals", want: []string{"remove /signals/shop.done", "sync /signals"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := &recordingFS{failOn: tt.failOn}
+			err := Clear(fs, "/signals/shop.done")
+			if !reflect.DeepEqual(fs.ops, tt.want) {
+				t.Errorf("operations = %q, want %q", fs.ops, tt.want)
+			}
+			if (tt.failOn != "") != errors.Is(err, errInjected) {
+				t.Errorf("Clear = %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteOrder(t *testing.T) {
+	completed := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		signal Signal
+		want   []string
+	}{
+		{
+			name:   "dump and sidecar in one directory",
+			signal: Signal{OutputFile: "/backups/shop.sql", Sidecar: "/backups/shop.sql.meta.json"},
+			want:   []string{"sync /backups", "write /signals/shop.done", "sync /signals"},
+		},
+		{
+			name:   "sidecar elsewhere",
+			signal: Signal{OutputFile: "/backups/shop.sql", Sidecar: "/meta/shop.sql.meta.json"},
+			want:   []string{"sync /backups", "sync /meta", "write /signals/shop.done", "sync /signals"},
+		},
+		{
+			name: "split dump",
+			signal: Signal{OutputFile: "/backups/shop.sql", Parts: []Part{
+				{Path: "/backups/shop.part001.sql"},
+				{Path: "/overflow/shop.part002.sql"},
+				{Path: "/overflow/shop.part003.sql"},
+			}},
+			want: []string{"sync /backups", "sync /overflow", "write /signals/shop.done", "sync /signals"},
+		},
+		{
+			// The done-file's directory is synced again after the write,
+			// even when the dump's was synced before it
+			name:   "done-file next to the dump",
+			signal: Signal{OutputFile: "/signals/shop.sql"},
+			want:   []string{"sync /signals", "write /signals/shop.done", "sync /signals"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := &recordingFS{}
+			tt.signal.CompletedAt = completed
+			if err := Write(fs, "/signals/shop.done", tt.signal); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(fs.ops, tt.want) {
+				t.Errorf("operations =\n %q, want\n %q", fs.ops, tt.want)
+			}
+
+			var written Signal
+			if err := json.Unmarshal(fs.files["/signals/shop.done"], &written); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(written, tt.signal) {
+				t.Errorf("done-file = %+v, want %+v", written, tt.signal)
+			}
+		})
+	}
+}
+
+// TestWriteFailures checks that when syncing the dump fails the done-file
+// is never written, and that every failure is returned
+func TestWriteFailures(t *testing.T) {
+	signal := Signal{OutputFile: "/backups/shop.sql", Sidecar: "/meta/shop.sql.meta.json"}
+	tests := []struct {
+		failOn  string
+		written bool
+	}{
+		{failOn: "sync /backups"},
+		{failOn: "sync /meta"},
+		{failOn: "write /signals/shop.done"},
+		{failOn: "sync /signals", written: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.failOn, func(t *testing.T) {
+			fs := &recordingFS{failOn: tt.failOn}
+			err := Write(fs, "/signals/shop.done", signal)
+			if !errors.Is(err, errInjected) {
+				t.Fatalf("Write = %v, want the injected failure", err)
+			}
+			if last := fs.ops[len(fs.ops)-1]; last != tt.failOn {
+				t.Errorf("operations %q continued after the failure", fs.ops)
+			}
+			if _, ok := fs.files["/signals/shop.done"]; ok != tt.written {
+				t.Errorf("done-file written = %v, want %v", ok, tt.written)
+			}
+		})
+	}
+}
+
+// TestOS runs a stale done-file, a clear and a write through the real
+// filesystem
+func TestOS(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "shop.sql")
+	done := filepath.Join(dir, "signals", "shop.done")
+	if err := os.WriteFile(dump, []byte("-- dump\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Dir(done), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Clearing when there is nothing to clear is fine
+	if err := Clear(OS, done); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(done, []byte(`{"output_file":"stale"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Clear(OS, done); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(done); !os.IsNotExist(err) {
+		t.Fatalf("stale done-file still there: %v", err)
+	}
+
+	sum, size, err := Checksum(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sha256.Sum256([]byte("-- dump\n")); size != 8 || sum != hex.EncodeToString(want[:]) {
+		t.Errorf("Checksum = %s, %d", sum, size)
+	}
+	if err := Write(OS, done, Signal{OutputFile: dump, Size: size, SHA256: sum, CompletedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written Signal
+	if err := json.Unmarshal(data, &written); err != nil || written.SHA256 != sum || written.OutputFile != dump {
+		t.Errorf("done-file = %s, %v", data, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(done))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("signals directory holds %v, %v; want only the done-file", entries, err)
+	}
+
+	if _, _, err := Checksum(filepath.Join(dir, "missing.sql")); !os.IsNotExist(err) {
+		t.Errorf("Checksum of a missing file = %v", err)
+	}
+}
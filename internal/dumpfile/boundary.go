@@ -0,0 +1,68 @@
+package dumpfile
+
+import "bytes"
+
+// Boundaries finds statement boundaries in a dump stream written in pieces
+// of any size, by the rules of Scanner, keeping only the start and end of
+// the current line
+type Boundaries struct {
+	head      []byte
+	tail      []byte
+	delimiter string
+	boundary  bool
+}
+
+// NewBoundaries creates a tracker for a stream that starts at a boundary
+func NewBoundaries() *Boundaries {
+	return &Boundaries{delimiter: ";", boundary: true}
+}
+
+// Last takes the next piece of the stream and returns the length of its
+// longest prefix that ends at a statement boundary, or -1 if none does
+func (b *Boundaries) Last(p []byte) int {
+	last := -1
+	for start := 0; start < len(p); {
+		end := len(p)
+		newline := bytes.IndexByte(p[start:], '\n')
+		if newline >= 0 {
+			end = start + newline + 1
+		}
+		b.add(p[start:end])
+		if newline >= 0 && b.endLine() {
+			last = end
+		}
+		start = end
+	}
+	return last
+}
+
+// add keeps the start and end of the current line
+func (b *Boundaries) add(piece []byte) {
+	if len(b.head) < headSize {
+		b.head = append(b.head, piece[:min(len(piece), headSize-len(b.head))]...)
+	}
+	b.tail = append(b.tail, piece...)
+	if len(b.tail) > tailSize {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-tailSize:]...)
+	}
+}
+
+// endLine updates the boundary state once a line is complete and reports
+// whether the stream is at a boundary after it
+func (b *Boundaries) endLine() bool {
+	head := bytes.TrimSpace(b.head)
+	tail := bytes.TrimRight(b.tail, " \t\r\n")
+
+	switch {
+	case len(head) == 0 || bytes.HasPrefix(head, []byte("--")):
+		// Blank and comment lines don't change the boundary state
+	case (head[0]|0x20) == 'd' && delimiterPattern.Match(head):
+		b.delimiter = string(delimiterPattern.FindSubmatch(head)[1])
+		b.boundary = true
+	default:
+		b.boundary = bytes.HasSuffix(tail, []byte(b.delimiter))
+	}
+
+	b.head, b.tail = b.head[:0], b.tail[:0]
+	return b.boundary
+}
@@ -0,0 +1,150 @@
+// Package dumpfile reads SQL dump files as a stream, transparently handling
+// compression and tracking byte offsets, line numbers and statement boundaries
+// without loading whole lines (which can be many megabytes) into memory.
+package dumpfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// readBufferSize is the size of the read buffer and the maximum chunk returned by Next
+const readBufferSize = 256 * 1024
+
+// File is an open dump file. A dump split into parts is read as one stream.
+type File struct {
+	Path        string
+	Size        int64
+	Compressed  bool
+	Compression string   // "gzip", "zstd", or "" for plain SQL
+	Parts       []string // part files in order, nil for an unsplit dump
+
+	files   []*os.File
+	counter *countingReader
+	gz      *gzip.Reader
+	zstd    *zstdReader
+	stream  io.Reader
+}
+
+// Open opens a dump file, detecting gzip or zstd compression from the file's
+// magic bytes. If path is a split dump (or one of its parts), all parts are opened
+// and read in sequence.
+func Open(path string) (*File, error) {
+	parts, err := FindParts(path)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := parts
+	if paths == nil {
+		paths = []string{path}
+	}
+
+	f := &File{Path: BasePath(path), Parts: parts}
+	if parts == nil {
+		f.Path = path
+	}
+
+	readers := make([]io.Reader, 0, len(paths))
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if err != nil {
+			_ = f.closeFiles()
+			return nil, fmt.Errorf("failed to open dump file: %w", err)
+		}
+		f.files = append(f.files, file)
+
+		info, err := file.Stat()
+		if err != nil {
+			_ = f.closeFiles()
+			return nil, fmt.Errorf("failed to stat dump file: %w", err)
+		}
+		f.Size += info.Size()
+		readers = append(readers, file)
+	}
+
+	counter := &countingReader{reader: io.MultiReader(readers...)}
+	buffered := bufio.NewReaderSize(counter, readBufferSize)
+	f.counter = counter
+	f.stream = buffered
+
+	// Compressed parts are separate gzip members, which gzip.Reader reads as one stream
+	magic, _ := buffered.Peek(4)
+	if bytes.HasPrefix(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			_ = f.closeFiles()
+			return nil, fmt.Errorf("failed to read gzip header: %w", err)
+		}
+		f.Compressed = true
+		f.Compression = "gzip"
+		f.gz = gz
+		f.stream = gz
+	} else if bytes.Equal(magic, zstdMagic) {
+		zr, err := newZstdReader(buffered)
+		if err != nil {
+			_ = f.closeFiles()
+			return nil, err
+		}
+		f.Compressed = true
+		f.Compression = "zstd"
+		f.zstd = zr
+		f.stream = zr
+	}
+
+	return f, nil
+}
+
+// Read reads from the decompressed SQL stream
+func (f *File) Read(p []byte) (int, error) {
+	return f.stream.Read(p)
+}
+
+// FileBytesRead returns how many bytes of the (possibly compressed) file have been consumed
+func (f *File) FileBytesRead() int64 {
+	return f.counter.n
+}
+
+// Close closes the dump file
+func (f *File) Close() error {
+	if f.gz != nil {
+		if err := f.gz.Close(); err != nil {
+			_ = f.closeFiles()
+			return err
+		}
+	}
+	if f.zstd != nil {
+		if err := f.zstd.Close(); err != nil {
+			_ = f.closeFiles()
+			return err
+		}
+	}
+	return f.closeFiles()
+}
+
+// closeFiles closes all underlying files, returning the first error
+func (f *File) closeFiles() error {
+	var firstErr error
+	for _, file := range f.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// countingReader counts bytes read from the underlying reader
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
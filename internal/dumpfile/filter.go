@@ -0,0 +1,82 @@
+package dumpfile
+
+import (
+	"bytes"
+	"regexp"
+	"slices"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// viewStatementPattern matches the statements mysqldump writes for a view,
+// which are all wrapped in /*!50001 comments
+var viewStatementPattern = regexp.MustCompile("^/\\*!50001 (?i:DROP VIEW IF EXISTS|CREATE VIEW|VIEW) `((?:[^`]|``)+)`")
+
+// triggerTablePattern finds the table a CREATE TRIGGER statement is on
+var triggerTablePattern = regexp.MustCompile("(?i)\\bTRIGGER `(?:[^`]|``)+` (?:BEFORE|AFTER) (?:INSERT|UPDATE|DELETE) ON `((?:[^`]|``)+)`")
+
+// filterHeadSize bounds the part of a statement's first line searched for
+// the table it belongs to; trigger bodies can be long
+const filterHeadSize = 1024
+
+// TableFilter decides which statements of a dump a selective restore
+// applies: the structure, data and triggers of the chosen tables and views,
+// and everything not tied to a table (session settings, routines, events)
+type TableFilter struct {
+	tables map[string]bool
+	seen   map[string]bool
+
+	// view is the view whose definition continues in /*!50001 statements
+	// that don't name it
+	view string
+}
+
+// NewTableFilter creates a filter applying the statements of tables
+func NewTableFilter(tables []string) *TableFilter {
+	f := &TableFilter{tables: make(map[string]bool, len(tables)), seen: make(map[string]bool)}
+	for _, table := range tables {
+		f.tables[table] = true
+	}
+	return f
+}
+
+// Statement reports whether the statement starting with line is applied
+func (f *TableFilter) Statement(line []byte) bool {
+	head := bytes.TrimSpace(line[:min(len(line), filterHeadSize)])
+	switch {
+	case len(head) == 0 || bytes.HasPrefix(head, []byte("--")) || isSessionSetup(head):
+		return true
+	case viewStatementPattern.Match(head):
+		f.view = sqlident.Unescape(string(viewStatementPattern.FindSubmatch(head)[1]))
+		return f.keep(f.view)
+	case bytes.HasPrefix(head, []byte("/*!50001 ")) && f.view != "":
+		// The final CREATE of a view names it on a later line
+		return f.keep(f.view)
+	}
+	if table, ok := StatementTable(head); ok {
+		return f.keep(table)
+	}
+	if match := triggerTablePattern.FindSubmatch(head); match != nil {
+		return f.keep(sqlident.Unescape(string(match[1])))
+	}
+	return true
+}
+
+// keep notes that the dump has a table and reports whether it is applied
+func (f *TableFilter) keep(table string) bool {
+	f.seen[table] = true
+	return f.tables[table]
+}
+
+// Missing returns the chosen tables the dump had no statements for, once
+// it was read to the end
+func (f *TableFilter) Missing() []string {
+	var missing []string
+	for table := range f.tables {
+		if !f.seen[table] {
+			missing = append(missing, table)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
@@ -0,0 +1,216 @@
+package dumpfile
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+// gzipBlockSize is how much input each compression worker takes at a time
+const gzipBlockSize = 1 << 20
+
+// gzipDictSize is the deflate window: each block is compressed with the
+// end of the previous one as its dictionary, so splitting costs little
+const gzipDictSize = 32 << 10
+
+// GzipWriter writes a gzip stream; Reset starts a new one on w, which
+// readers of concatenated gzip members see as a continuation
+type GzipWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// NewGzipWriter returns a gzip writer at level (gzip.DefaultCompression or
+// 1-9). With more than one worker, blocks of the input are compressed
+// concurrently into a single gzip member, as pigz does; its output is a
+// standard gzip stream.
+func NewGzipWriter(w io.Writer, level, workers int) GzipWriter {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression || level == gzip.NoCompression {
+		level = gzip.DefaultCompression
+	}
+	if workers <= 1 {
+		gz, _ := gzip.NewWriterLevel(w, level)
+		return gz
+	}
+	p := &parallelGzip{level: level, workers: workers}
+	p.Reset(w)
+	return p
+}
+
+// parallelGzip compresses blocks on up to workers goroutines and writes
+// them in order from another
+type parallelGzip struct {
+	level   int
+	workers int
+
+	w      io.Writer
+	block  []byte
+	dict   []byte
+	crc    uint32
+	size   uint32 // ISIZE: the input length modulo 2^32
+	queue  chan chan []byte
+	done   chan struct{}
+	header bool
+
+	mu  sync.Mutex
+	err error
+}
+
+// errAbandoned stops the writing of blocks of a stream dropped by Reset
+var errAbandoned = errors.New("gzip stream abandoned")
+
+// Reset discards the state and starts a new stream on w. Blocks of an
+// unfinished stream are dropped, not written.
+func (p *parallelGzip) Reset(w io.Writer) {
+	if p.queue != nil {
+		p.fail(errAbandoned)
+		close(p.queue)
+		<-p.done
+	}
+	p.w = w
+	p.block = make([]byte, 0, gzipBlockSize)
+	p.dict = nil
+	p.crc, p.size = 0, 0
+	p.header = false
+	p.err = nil
+	p.queue = make(chan chan []byte, p.workers)
+	p.done = make(chan struct{})
+	go p.writeBlocks(p.queue, p.done)
+}
+
+// Write implements io.Writer
+func (p *parallelGzip) Write(data []byte) (int, error) {
+	if err := p.failed(); err != nil {
+		return 0, err
+	}
+	p.crc = crc32.Update(p.crc, crc32.IEEETable, data)
+	p.size += uint32(len(data))
+
+	written := 0
+	for len(data) > 0 {
+		n := min(len(data), gzipBlockSize-len(p.block))
+		p.block = append(p.block, data[:n]...)
+		data = data[n:]
+		written += n
+		if len(p.block) == gzipBlockSize {
+			p.dispatch(false)
+		}
+	}
+	return written, p.failed()
+}
+
+// Close compresses what is left, waits for the workers and writes the
+// gzip trailer; it does not close the underlying writer
+func (p *parallelGzip) Close() error {
+	if p.queue == nil {
+		return p.failed()
+	}
+	p.dispatch(true)
+	close(p.queue)
+	<-p.done
+	p.queue = nil
+	if err := p.failed(); err != nil {
+		return err
+	}
+
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[:4], p.crc)
+	binary.LittleEndian.PutUint32(trailer[4:], p.size)
+	_, err := p.w.Write(trailer[:])
+	return err
+}
+
+// dispatch hands the current block to a new worker, in writing order. The
+// queue holds at most workers blocks, so this waits when all are busy.
+func (p *parallelGzip) dispatch(final bool) {
+	block, dict := p.block, p.dict
+	if len(block) >= gzipDictSize {
+		p.dict = block[len(block)-gzipDictSize:]
+	} else {
+		p.dict = append(p.dict, block...)
+		p.dict = p.dict[max(len(p.dict)-gzipDictSize, 0):]
+	}
+	p.block = make([]byte, 0, gzipBlockSize)
+
+	result := make(chan []byte, 1)
+	p.queue <- result
+	go func() {
+		result <- p.compress(block, dict, final)
+	}()
+}
+
+// compress deflates a block primed with dict. Blocks other than the last
+// end with a sync flush so that their streams concatenate into one.
+func (p *parallelGzip) compress(block, dict []byte, final bool) []byte {
+	var out bytes.Buffer
+	fw, err := flate.NewWriterDict(&out, p.level, dict)
+	if err == nil {
+		_, err = fw.Write(block)
+	}
+	if err == nil {
+		if final {
+			err = fw.Close()
+		} else {
+			err = fw.Flush()
+		}
+	}
+	if err != nil {
+		p.fail(err)
+		return nil
+	}
+	return out.Bytes()
+}
+
+// writeBlocks writes the gzip header, then each block as it is ready
+func (p *parallelGzip) writeBlocks(queue chan chan []byte, done chan struct{}) {
+	defer close(done)
+	for result := range queue {
+		compressed := <-result
+		if p.failed() != nil {
+			continue
+		}
+		if !p.header {
+			p.header = true
+			if _, err := p.w.Write(gzipHeader(p.level)); err != nil {
+				p.fail(err)
+				continue
+			}
+		}
+		if _, err := p.w.Write(compressed); err != nil {
+			p.fail(err)
+		}
+	}
+}
+
+// gzipHeader is the header compress/gzip writes without a name or time
+func gzipHeader(level int) []byte {
+	header := []byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 255}
+	switch level {
+	case gzip.BestCompression:
+		header[8] = 2
+	case gzip.BestSpeed:
+		header[8] = 4
+	}
+	return header
+}
+
+// fail records the first error
+func (p *parallelGzip) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// failed returns the first error
+func (p *parallelGzip) failed() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
@@ -0,0 +1,69 @@
+package dumpfile
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// headerLines is how many lines from the start of a dump are searched for header information
+const headerLines = 64
+
+// Header holds source information found at the start of a dump file
+type Header struct {
+	Host     string
+	Database string
+
+	// CreatesDatabase is set when the dump starts with CREATE DATABASE, as
+	// written by --add-create-database or mysqldump --databases
+	CreatesDatabase bool
+}
+
+var hostDatabasePattern = regexp.MustCompile(`^-- Host: (\S+)\s+Database: (\S+)`)
+
+var usePattern = regexp.MustCompile("^USE `((?:[^`]|``)+)`")
+
+var createDatabasePattern = regexp.MustCompile(`(?i)^CREATE (?:DATABASE|SCHEMA)\b`)
+
+// ReadHeader looks for the source host and database in the first lines of a
+// dump, using mysqldump's "-- Host: ... Database: ..." comment or a USE
+// statement, and for a CREATE DATABASE statement
+func ReadHeader(path string) (*Header, error) {
+	file, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	header := &Header{}
+	scanner := NewScanner(file)
+	for scanner.Line() <= headerLines {
+		if _, err := scanner.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if !scanner.LineEnded() {
+			continue
+		}
+
+		head := bytes.TrimSpace(scanner.Head())
+		if match := hostDatabasePattern.FindSubmatch(head); match != nil && header.Host == "" {
+			header.Host = string(match[1])
+			header.Database = string(match[2])
+		}
+		if match := usePattern.FindSubmatch(head); match != nil && header.Database == "" {
+			header.Database = sqlident.Unescape(string(match[1]))
+		}
+		if createDatabasePattern.Match(head) {
+			header.CreatesDatabase = true
+		}
+	}
+
+	return header, nil
+}
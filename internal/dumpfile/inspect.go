@@ -0,0 +1,293 @@
+package dumpfile
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// Contents describes what a dump file holds, as found by Inspect
+type Contents struct {
+	Path             string `json:"path"`
+	Compression      string `json:"compression,omitempty"`
+	Parts            int    `json:"parts,omitempty"`
+	FileSize         int64  `json:"file_size"`
+	DecompressedSize int64  `json:"decompressed_size"`
+
+	// From the header comments written by dbdump and mysqldump
+	Host          string `json:"host,omitempty"`
+	Database      string `json:"database,omitempty"`
+	ServerVersion string `json:"server_version,omitempty"`
+	DbdumpVersion string `json:"dbdump_version,omitempty"`
+	DumpTool      string `json:"dump_tool,omitempty"`
+	Tags          string `json:"tags,omitempty"`
+
+	// Completed is set when the dump ends with the "Dump completed on"
+	// comment that mysqldump (and dbdump) write last; without it the dump
+	// was probably cut off
+	Completed bool `json:"completed"`
+
+	// Charset is the connection charset set with SET NAMES, and
+	// TableCharsets the distinct default charsets of the tables
+	Charset       string   `json:"charset,omitempty"`
+	TableCharsets []string `json:"table_charsets,omitempty"`
+
+	Tables   []TableContents `json:"tables"`
+	Views    int             `json:"views"`
+	Triggers int             `json:"triggers"`
+	Routines int             `json:"routines"`
+	Events   int             `json:"events"`
+}
+
+// TableContents describes one table in a dump. Rows counts the value tuples
+// of the table's INSERT statements, which matches the row count unless the
+// dump was edited by hand.
+type TableContents struct {
+	Name      string `json:"name"`
+	View      bool   `json:"view,omitempty"`
+	Structure bool   `json:"structure"`
+	Inserts   int64  `json:"inserts"`
+	Rows      int64  `json:"rows"`
+
+	// DataSize is the size of the table's INSERT statements in the SQL
+	DataSize int64 `json:"data_size"`
+}
+
+// HasData reports whether the dump contains rows for the table
+func (t *TableContents) HasData() bool {
+	return t.Inserts > 0
+}
+
+var (
+	insertPattern        = regexp.MustCompile(`^(?i:INSERT|REPLACE)\s`)
+	dumpToolPattern      = regexp.MustCompile(`^-- (?:MySQL|MariaDB) dump \S+\s+Distrib (\S+?),?\s`)
+	dbdumpPattern        = regexp.MustCompile(`^-- dbdump (\S+)(?: \((.+)\))?$`)
+	serverVersionPattern = regexp.MustCompile(`^-- Server version:?\s+(\S+)`)
+	tagsPattern          = regexp.MustCompile(`^-- dbdump tags: (.+)$`)
+	completedPattern     = regexp.MustCompile(`^-- Dump completed(?: on|$)`)
+	setNamesPattern      = regexp.MustCompile(`(?i)\bSET NAMES\s+(\w+)`)
+	tableCharsetPattern  = regexp.MustCompile(`(?i)^\).*\bDEFAULT CHARSET=(\w+)`)
+	createTablePattern   = regexp.MustCompile(`^(?i:CREATE TABLE)`)
+	viewPattern          = regexp.MustCompile("(?i)\\bCREATE\\b.*\\bVIEW `((?:[^`]|``)+)`")
+	triggerPattern       = regexp.MustCompile("(?i)\\bCREATE\\b.*\\bTRIGGER `")
+	routinePattern       = regexp.MustCompile("(?i)\\bCREATE\\b.*\\b(?:PROCEDURE|FUNCTION) `")
+	eventPattern         = regexp.MustCompile("(?i)\\bCREATE\\b.*\\bEVENT `")
+)
+
+// Inspect streams through a dump and describes its contents without
+// restoring it: header information, the tables with their structure and
+// row counts, and the views, triggers, routines and events it defines
+func Inspect(path string) (*Contents, error) {
+	file, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	contents := &Contents{
+		Path:        file.Path,
+		Compression: file.Compression,
+		Parts:       len(file.Parts),
+		FileSize:    file.Size,
+	}
+	// Tables are listed in the order they first appear
+	tables := make(map[string]*TableContents)
+	var order []string
+	table := func(name string) *TableContents {
+		t, ok := tables[name]
+		if !ok {
+			t = &TableContents{Name: name}
+			tables[name] = t
+			order = append(order, name)
+		}
+		return t
+	}
+	charsets := make(map[string]bool)
+
+	scanner := NewScanner(file)
+	var rows *rowCounter
+	var rowsTable *TableContents
+	for {
+		atLineStart := scanner.AtLineStart()
+		chunk, err := scanner.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if atLineStart {
+			rows = nil
+			head := bytes.TrimSpace(chunk[:min(len(chunk), headSize)])
+			// Anything after the marker means it wasn't the end (e.g.
+			// dumps concatenated by hand)
+			if len(head) > 0 {
+				contents.Completed = false
+			}
+			if insertPattern.Match(head) {
+				if name, ok := StatementTable(head); ok {
+					rowsTable = table(name)
+					rowsTable.Inserts++
+					rows = &rowCounter{}
+				}
+			}
+		}
+		if rows != nil {
+			rows.feed(chunk)
+			rowsTable.DataSize += int64(len(chunk))
+		}
+		if !scanner.LineEnded() {
+			continue
+		}
+		if rows != nil {
+			rowsTable.Rows += rows.rows
+			rows = nil
+			continue
+		}
+
+		head := bytes.TrimSpace(scanner.Head())
+		switch {
+		case len(head) == 0:
+		case bytes.HasPrefix(head, []byte("--")):
+			contents.readComment(head)
+		case createTablePattern.Match(head):
+			if name, ok := StatementTable(head); ok {
+				table(name).Structure = true
+			}
+		case tableCharsetPattern.Match(head):
+			charsets[string(tableCharsetPattern.FindSubmatch(head)[1])] = true
+		case viewPattern.Match(head):
+			// mysqldump creates each view twice: a placeholder, then the real one
+			name := sqlident.Unescape(string(viewPattern.FindSubmatch(head)[1]))
+			table(name).View = true
+		case triggerPattern.Match(head):
+			contents.Triggers++
+		case routinePattern.Match(head):
+			contents.Routines++
+		case eventPattern.Match(head):
+			contents.Events++
+		case contents.Charset == "" && setNamesPattern.Match(head):
+			contents.Charset = string(setNamesPattern.FindSubmatch(head)[1])
+		}
+	}
+
+	contents.DecompressedSize = scanner.Offset()
+	contents.Tables = make([]TableContents, 0, len(order))
+	for _, name := range order {
+		contents.Tables = append(contents.Tables, *tables[name])
+		if tables[name].View {
+			contents.Views++
+		}
+	}
+	for charset := range charsets {
+		contents.TableCharsets = append(contents.TableCharsets, charset)
+	}
+	sort.Strings(contents.TableCharsets)
+
+	return contents, nil
+}
+
+// readComment picks up header information from a comment line
+func (c *Contents) readComment(head []byte) {
+	if match := hostDatabasePattern.FindSubmatch(head); match != nil {
+		c.Host, c.Database = string(match[1]), string(match[2])
+	} else if match := dumpToolPattern.FindSubmatch(head); match != nil {
+		c.DumpTool = "mysqldump " + string(match[1])
+	} else if match := tagsPattern.FindSubmatch(head); match != nil {
+		c.Tags = string(match[1])
+	} else if match := dbdumpPattern.FindSubmatch(head); match != nil {
+		c.DbdumpVersion = string(match[1])
+		if c.DumpTool == "" {
+			c.DumpTool = string(match[2])
+		}
+	} else if match := serverVersionPattern.FindSubmatch(head); match != nil {
+		c.ServerVersion = string(match[1])
+	} else if completedPattern.Match(head) {
+		c.Completed = true
+	}
+}
+
+// rowCounter counts the value tuples of an INSERT statement fed to it in
+// chunks, skipping the column list and anything inside quotes
+type rowCounter struct {
+	values  bool    // past the VALUES keyword
+	quote   byte    // open quote character, 0 outside quotes
+	escaped bool    // previous byte was a backslash inside a string
+	depth   int     // parenthesis depth in the values list
+	recent  [6]byte // last bytes outside quotes, to find VALUES
+	rows    int64
+}
+
+var valuesKeyword = [6]byte{'V', 'A', 'L', 'U', 'E', 'S'}
+
+// feed processes the next chunk of the statement
+func (c *rowCounter) feed(chunk []byte) {
+	for _, b := range chunk {
+		if c.quote != 0 {
+			switch {
+			case c.escaped:
+				c.escaped = false
+			case b == '\\' && c.quote != '`':
+				c.escaped = true
+			case b == c.quote:
+				c.quote = 0
+			}
+			continue
+		}
+
+		switch b {
+		case '\'', '"', '`':
+			c.quote = b
+			continue
+		}
+
+		if !c.values {
+			copy(c.recent[:], c.recent[1:])
+			if b >= 'a' && b <= 'z' {
+				b -= 'a' - 'A'
+			}
+			c.recent[len(c.recent)-1] = b
+			c.values = c.recent == valuesKeyword
+			continue
+		}
+
+		switch b {
+		case '(':
+			if c.depth == 0 {
+				c.rows++
+			}
+			c.depth++
+		case ')':
+			c.depth--
+		}
+	}
+}
+
+// CountRows returns the number of rows in an INSERT statement
+func CountRows(statement []byte) int64 {
+	var c rowCounter
+	c.feed(statement)
+	return c.rows
+}
+
+// CountRowsFrom returns the number of rows in an INSERT statement read from r
+func CountRowsFrom(r io.Reader) (int64, error) {
+	var c rowCounter
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buf)
+		c.feed(buf[:n])
+		if err == io.EOF {
+			return c.rows, nil
+		}
+		if err != nil {
+			return c.rows, err
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package dumpfile
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// inspectDump is a small mysqldump dump with two tables, a view and a
+// trigger, ending with the completion marker
+const inspectDump = "-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)\n" +
+	"--\n-- Host: db.internal    Database: shop\n" +
+	"-- ------------------------------------------------------\n" +
+	"-- Server version\t8.0.36\n\n" +
+	"/*!40101 SET NAMES utf8mb4 */;\n" +
+	"CREATE TABLE `users` (\n  `id` int NOT NULL,\n  `name` varchar(20)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+	"CREATE TABLE `orders` (\n  `id` int NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+	"/*!50001 CREATE VIEW `user_totals` AS SELECT 1 AS `id` */;\n" +
+	"LOCK TABLES `users` WRITE;\n" +
+	"INSERT INTO `users` VALUES (1,'a'),(2,'(b), c'),(3,'it\\'s');\n" +
+	"INSERT INTO `users` VALUES (4,'d');\n" +
+	"UNLOCK TABLES;\n" +
+	"DELIMITER ;;\n/*!50003 CREATE*/ /*!50003 TRIGGER `users_ai` AFTER INSERT ON `users` FOR EACH ROW BEGIN END */;;\nDELIMITER ;\n"
+
+const completedMarker = "-- Dump completed on 2024-03-01 10:00:00\n"
+
+// inspectedTable is what the tests compare of a table's contents
+type inspectedTable struct {
+	Name      string
+	Structure bool
+	View      bool
+	Inserts   int64
+	Rows      int64
+}
+
+func TestInspect(t *testing.T) {
+	users := inspectedTable{Name: "users", Structure: true, Inserts: 2, Rows: 4}
+	orders := inspectedTable{Name: "orders", Structure: true}
+	view := inspectedTable{Name: "user_totals", View: true}
+
+	// A single INSERT line of several megabytes, longer than any line
+	// buffer, with one row per value tuple
+	var huge strings.Builder
+	huge.WriteString("INSERT INTO `orders` VALUES ")
+	for i := range 40000 {
+		if i > 0 {
+			huge.WriteString(",")
+		}
+		huge.WriteString("(1,'" + strings.Repeat("x", 100) + "')")
+	}
+	huge.WriteString(";\n")
+
+	tests := []struct {
+		name      string
+		sql       string
+		gzip      bool
+		completed bool
+		tables    []inspectedTable
+	}{
+		{name: "complete", sql: inspectDump + completedMarker, completed: true, tables: []inspectedTable{users, orders, view}},
+		{name: "gzipped", sql: inspectDump + completedMarker, gzip: true, completed: true, tables: []inspectedTable{users, orders, view}},
+		{name: "truncated", sql: inspectDump, tables: []inspectedTable{users, orders, view}},
+		{
+			name:   "cut inside a statement",
+			sql:    inspectDump[:strings.Index(inspectDump, "INSERT INTO `users` VALUES (4")+20],
+			tables: []inspectedTable{{Name: "users", Structure: true, Inserts: 2, Rows: 3}, orders, view},
+		},
+		{
+			// Dumps concatenated by hand: the first marker isn't the end
+			name:   "marker before more statements",
+			sql:    inspectDump + completedMarker + "INSERT INTO `orders` VALUES (1);\n",
+			tables: []inspectedTable{users, {Name: "orders", Structure: true, Inserts: 1, Rows: 1}, view},
+		},
+		{name: "trailing blank lines", sql: inspectDump + completedMarker + "\n\n", completed: true, tables: []inspectedTable{users, orders, view}},
+		{
+			name:      "multi-megabyte line",
+			sql:       inspectDump + huge.String() + completedMarker,
+			completed: true,
+			tables:    []inspectedTable{users, {Name: "orders", Structure: true, Inserts: 1, Rows: 40000}, view},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "shop.sql")
+			data := []byte(tt.sql)
+			if tt.gzip {
+				path += ".gz"
+				var b strings.Builder
+				zw := gzip.NewWriter(&b)
+				if _, err := zw.Write(data); err != nil {
+					t.Fatal(err)
+				}
+				if err := zw.Close(); err != nil {
+					t.Fatal(err)
+				}
+				data = []byte(b.String())
+			}
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			contents, err := Inspect(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contents.Completed != tt.completed {
+				t.Errorf("Completed = %v, want %v", contents.Completed, tt.completed)
+			}
+			var tables []inspectedTable
+			for _, table := range contents.Tables {
+				tables = append(tables, inspectedTable{Name: table.Name, Structure: table.Structure, View: table.View, Inserts: table.Inserts, Rows: table.Rows})
+			}
+			if !reflect.DeepEqual(tables, tt.tables) {
+				t.Errorf("tables = %+v\nwant %+v", tables, tt.tables)
+			}
+			if contents.Database != "shop" || contents.Host != "db.internal" {
+				t.Errorf("header names %q on %q, want shop on db.internal", contents.Database, contents.Host)
+			}
+			if contents.Views != 1 {
+				t.Errorf("Views = %d, want 1", contents.Views)
+			}
+			if contents.DecompressedSize != int64(len(tt.sql)) {
+				t.Errorf("DecompressedSize = %d, want %d", contents.DecompressedSize, len(tt.sql))
+			}
+		})
+	}
+}
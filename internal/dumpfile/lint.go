@@ -0,0 +1,423 @@
+package dumpfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// LintSeverity ranks how likely a finding is to break a restore
+type LintSeverity string
+
+const (
+	// LintError fails the restore on common targets
+	LintError LintSeverity = "error"
+	// LintWarning fails under some server settings or on some targets
+	LintWarning LintSeverity = "warning"
+	// LintInfo is worth knowing but restores with the dump's own settings
+	LintInfo LintSeverity = "info"
+)
+
+// LintScope says which lines of a dump a rule looks at
+type LintScope int
+
+const (
+	// LintStructure rules see each line of a CREATE TABLE statement
+	LintStructure LintScope = 1 << iota
+	// LintData rules see the contents of INSERT statements, chunk by chunk
+	LintData
+	// LintStatement rules see each finished INSERT statement's size
+	LintStatement
+)
+
+// LintRule is one check of the lint pass
+type LintRule struct {
+	ID          string
+	Severity    LintSeverity
+	Scope       LintScope
+	Description string
+	Suggestion  string
+
+	// check returns a message when the rule matches the linter's current
+	// line, and a severity when it differs from the rule's
+	check func(l *linter) (string, LintSeverity)
+}
+
+// LintFinding is a rule that matched in a dump, reported once per table at
+// the first line it matched
+type LintFinding struct {
+	Rule       string       `json:"rule"`
+	Severity   LintSeverity `json:"severity"`
+	Line       int          `json:"line"`
+	Table      string       `json:"table,omitempty"`
+	Lines      int          `json:"lines"`
+	Message    string       `json:"message"`
+	Suggestion string       `json:"suggestion,omitempty"`
+}
+
+// LintOptions tunes the lint pass for a known target
+type LintOptions struct {
+	// MaxAllowedPacket is the target's max_allowed_packet; 0 checks
+	// statements against the defaults of common servers
+	MaxAllowedPacket int64
+}
+
+// lintTextLimit is how much of a structure or session line the rules see
+const lintTextLimit = 64 << 10
+
+// lintOverlap is how many bytes of a data chunk are seen again with the
+// next one, so patterns split between chunks still match
+const lintOverlap = 32
+
+var (
+	zeroDatePattern    = regexp.MustCompile(`'0000-00-00`)
+	sqlModePattern     = regexp.MustCompile(`(?i)\bSQL_MODE\s*=\s*'([^']*)'`)
+	charsetPattern     = regexp.MustCompile(`(?i)\b(?:DEFAULT CHARSET=|CHARACTER SET )(\w+)`)
+	mysql80Collation   = regexp.MustCompile(`(?i)\b(utf8mb4_(?:\w+_)?0900_\w+)`)
+	mariadbCollation   = regexp.MustCompile(`(?i)\b(\w+_uca1400_\w+)`)
+	rowFormatPattern   = regexp.MustCompile(`(?i)\bROW_FORMAT=(COMPRESSED|FIXED)\b`)
+	tablespacePattern  = regexp.MustCompile("(?i)\\bTABLESPACE\\s+(`[^`]+`|\\w+)")
+	directoryPattern   = regexp.MustCompile(`(?i)\b((?:DATA|INDEX) DIRECTORY)\s*=`)
+	systemTablespaces  = []string{"innodb_system", "innodb_file_per_table"}
+	packetDefaultSizes = []struct {
+		size     int64
+		severity LintSeverity
+		servers  string
+	}{
+		{64 << 20, LintError, "64 MiB, the largest default max_allowed_packet (MySQL 8.0)"},
+		{16 << 20, LintWarning, "16 MiB, the default max_allowed_packet of MariaDB and of the mysql client"},
+		{4 << 20, LintInfo, "4 MiB, the default max_allowed_packet of MySQL 5.7"},
+	}
+)
+
+// LintRules are the checks of the lint pass, in the order their findings
+// are listed for the same line
+var LintRules = []LintRule{
+	{
+		ID:          "max-allowed-packet",
+		Severity:    LintError,
+		Scope:       LintStatement,
+		Description: "INSERT statements larger than the target's max_allowed_packet",
+		Suggestion:  "Raise max_allowed_packet on the target and for the mysql client, or lower performance.net_buffer so multi-row INSERTs are split smaller (a single row this large still needs the larger packet)",
+		check:       checkPacket,
+	},
+	{
+		ID:          "zero-date",
+		Severity:    LintWarning,
+		Scope:       LintStructure | LintData,
+		Description: "Zero dates, rejected under strict sql_mode with NO_ZERO_DATE",
+		Suggestion:  "Restore with the sql_mode the dump sets, or remove NO_ZERO_DATE and NO_ZERO_IN_DATE from the target's sql_mode; dbdump restore warns about sql_mode differences",
+		check:       checkZeroDate,
+	},
+	{
+		ID:          "charset-mix",
+		Severity:    LintWarning,
+		Scope:       LintStructure,
+		Description: "latin1 and utf8 character sets in the same dump",
+		Suggestion:  "Dump with --convert-charset utf8mb4 so every table uses one character set",
+		check:       checkCharsetMix,
+	},
+	{
+		ID:          "mysql80-collation",
+		Severity:    LintWarning,
+		Scope:       LintStructure,
+		Description: "Collations only MySQL 8.0 and later have",
+		Suggestion:  "Map the collation in charset.collations (e.g. utf8mb4_0900_ai_ci: utf8mb4_unicode_ci) and dump with --convert-charset utf8mb4",
+		check:       matchCollation(mysql80Collation, "MySQL 8.0 and later; MySQL 5.7 and MariaDB reject it"),
+	},
+	{
+		ID:          "mariadb-collation",
+		Severity:    LintWarning,
+		Scope:       LintStructure,
+		Description: "UCA 14.0 collations only MariaDB 10.10 and later have",
+		Suggestion:  "Map the collation in charset.collations (e.g. utf8mb4_uca1400_ai_ci: utf8mb4_unicode_ci) and dump with --convert-charset utf8mb4",
+		check:       matchCollation(mariadbCollation, "MariaDB 10.10 and later; MySQL and older MariaDB reject it"),
+	},
+	{
+		ID:          "row-format",
+		Severity:    LintWarning,
+		Scope:       LintStructure,
+		Description: "ROW_FORMAT clauses that InnoDB or managed servers refuse",
+		Suggestion:  "Remove the ROW_FORMAT clause from the CREATE TABLE before restoring, or convert the table on the source",
+		check:       checkRowFormat,
+	},
+	{
+		ID:          "tablespace",
+		Severity:    LintWarning,
+		Scope:       LintStructure,
+		Description: "General tablespaces and DATA/INDEX DIRECTORY clauses",
+		Suggestion:  "Create the tablespace or directory on the target first, or remove the clause from the CREATE TABLE; managed servers don't allow either",
+		check:       checkTablespace,
+	},
+}
+
+// linter holds what the rules see of the current line, and the state some
+// of them carry through the dump
+type linter struct {
+	options LintOptions
+	table   string
+
+	// text is the current structure line, or the current data chunk after
+	// the end of the one before it
+	text []byte
+	// length is the size of the INSERT statement that just ended
+	length int64
+
+	sqlMode    string
+	sqlModeSet bool
+	charsets   map[string][2]string // charset family → first table and charset
+}
+
+// Lint streams through a dump once and reports constructs that restore on
+// the source's kind of server but not on others: statements over
+// max_allowed_packet, zero dates, mixed character sets, collations and
+// table options older or other servers don't have. Findings are in line
+// order.
+func Lint(path string, options LintOptions) ([]LintFinding, error) {
+	file, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	l := &linter{options: options, charsets: make(map[string][2]string)}
+	var findings []LintFinding
+	index := make(map[string]int)    // rule and table → finding
+	lastLine := make(map[string]int) // rule and table → last line counted
+	run := func(scope LintScope, line int) {
+		for _, rule := range LintRules {
+			if rule.Scope&scope == 0 {
+				continue
+			}
+			message, severity := rule.check(l)
+			if message == "" {
+				continue
+			}
+			if severity == "" {
+				severity = rule.Severity
+			}
+			key := rule.ID + "\x00" + l.table
+			i, ok := index[key]
+			if !ok {
+				i = len(findings)
+				index[key] = i
+				findings = append(findings, LintFinding{
+					Rule: rule.ID, Severity: severity, Line: line, Table: l.table,
+					Message: message, Suggestion: rule.Suggestion,
+				})
+			} else if severityRank(severity) > severityRank(findings[i].Severity) {
+				findings[i].Severity, findings[i].Message = severity, message
+			}
+			if lastLine[key] != line {
+				lastLine[key] = line
+				findings[i].Lines++
+			}
+		}
+	}
+
+	const (
+		kindOther = iota
+		kindStructure
+		kindData
+	)
+	kind := kindOther
+	inBody := false
+	scanner := NewScanner(file)
+	for {
+		atStart, continued := scanner.AtLineStart(), !scanner.AtBoundary()
+		chunk, err := scanner.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line := scanner.Line()
+		if scanner.LineEnded() {
+			line--
+		}
+
+		if atStart {
+			head := bytes.TrimSpace(scanner.Head())
+			if !continued || inBody {
+				switch {
+				case inBody:
+					kind = kindOther
+				case createTablePattern.Match(head):
+					kind = kindStructure
+				case insertPattern.Match(head):
+					kind = kindData
+				default:
+					kind = kindOther
+				}
+			}
+			if table, ok := StatementTable(head); ok {
+				l.table = table
+			}
+			l.text = l.text[:0]
+		}
+
+		if kind == kindData {
+			if len(l.text) > lintOverlap {
+				l.text = append(l.text[:0], l.text[len(l.text)-lintOverlap:]...)
+			}
+			l.text = append(l.text, chunk...)
+			run(LintData, line)
+			if scanner.LineEnded() {
+				l.length = scanner.Offset() - scanner.LineStart()
+				run(LintStatement, line)
+			}
+			continue
+		}
+
+		if len(l.text) < lintTextLimit {
+			l.text = append(l.text, chunk[:min(len(chunk), lintTextLimit-len(l.text))]...)
+		}
+		if !scanner.LineEnded() {
+			continue
+		}
+		if match := delimiterPattern.FindSubmatch(bytes.TrimSpace(scanner.Head())); match != nil {
+			inBody = string(match[1]) != ";"
+			continue
+		}
+		if kind == kindStructure {
+			run(LintStructure, line)
+		} else if match := sqlModePattern.FindSubmatch(l.text); match != nil && !l.sqlModeSet {
+			// The header's sql_mode holds for the data; later ones are set
+			// around triggers and routines and restored after them
+			l.sqlMode, l.sqlModeSet = string(match[1]), true
+		}
+	}
+
+	slices.SortStableFunc(findings, func(a, b LintFinding) int {
+		return a.Line - b.Line
+	})
+	return findings, nil
+}
+
+// severityRank orders severities from info to error
+func severityRank(severity LintSeverity) int {
+	switch severity {
+	case LintError:
+		return 2
+	case LintWarning:
+		return 1
+	}
+	return 0
+}
+
+// formatMiB formats a size in MiB with one decimal
+func formatMiB(size int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+}
+
+// checkPacket flags statements over the target's max_allowed_packet, or
+// over the defaults of common servers when it isn't known
+func checkPacket(l *linter) (string, LintSeverity) {
+	if limit := l.options.MaxAllowedPacket; limit > 0 {
+		if l.length > limit {
+			return fmt.Sprintf("%s statement is over the target's max_allowed_packet of %s", formatMiB(l.length), formatMiB(limit)), ""
+		}
+		return "", ""
+	}
+	for _, limit := range packetDefaultSizes {
+		if l.length > limit.size {
+			return fmt.Sprintf("%s statement is over %s", formatMiB(l.length), limit.servers), limit.severity
+		}
+	}
+	return "", ""
+}
+
+// checkZeroDate flags zero dates. mysqldump's header sets a lenient
+// sql_mode, so with it they restore; without it, or when a tool replays
+// the statements under the server's own sql_mode, strict servers refuse them.
+func checkZeroDate(l *linter) (string, LintSeverity) {
+	if !zeroDatePattern.Match(l.text) {
+		return "", ""
+	}
+	if !l.sqlModeSet {
+		return "zero date '0000-00-00', and the dump doesn't set sql_mode; fails under the default strict sql_mode of MySQL 5.7 and later", ""
+	}
+	modes := strings.Split(strings.ToUpper(l.sqlMode), ",")
+	if slices.Contains(modes, "NO_ZERO_DATE") || slices.Contains(modes, "TRADITIONAL") {
+		return fmt.Sprintf("zero date '0000-00-00' under the dump's sql_mode %q, which rejects it", l.sqlMode), LintError
+	}
+	return "zero date '0000-00-00'; restores under the sql_mode the dump sets, but fails where a strict sql_mode with NO_ZERO_DATE applies", LintInfo
+}
+
+// charsetFamily groups character sets whose mixing the charset-mix rule
+// reports, or returns "" for others
+func charsetFamily(charset string) string {
+	switch strings.ToLower(charset) {
+	case "latin1":
+		return "latin1"
+	case "utf8", "utf8mb3", "utf8mb4":
+		return "utf8"
+	}
+	return ""
+}
+
+// checkCharsetMix flags the first latin1 use after utf8 ones, or the
+// other way round
+func checkCharsetMix(l *linter) (string, LintSeverity) {
+	for _, match := range charsetPattern.FindAllSubmatch(l.text, -1) {
+		charset := string(match[1])
+		family := charsetFamily(charset)
+		if family == "" {
+			continue
+		}
+		if _, ok := l.charsets[family]; !ok {
+			l.charsets[family] = [2]string{l.table, charset}
+		}
+		for other, first := range l.charsets {
+			if other != family {
+				return fmt.Sprintf("%s in `%s` mixed with %s in `%s`", charset, l.table, first[1], first[0]), ""
+			}
+		}
+	}
+	return "", ""
+}
+
+// matchCollation returns a check for collations only some servers have
+func matchCollation(pattern *regexp.Regexp, servers string) func(l *linter) (string, LintSeverity) {
+	return func(l *linter) (string, LintSeverity) {
+		match := pattern.FindSubmatch(l.text)
+		if match == nil {
+			return "", ""
+		}
+		return fmt.Sprintf("collation %s only exists on %s", match[1], servers), ""
+	}
+}
+
+// checkRowFormat flags row formats InnoDB refuses in strict mode (FIXED) or
+// that managed servers don't support (COMPRESSED)
+func checkRowFormat(l *linter) (string, LintSeverity) {
+	match := rowFormatPattern.FindSubmatch(l.text)
+	if match == nil {
+		return "", ""
+	}
+	if strings.EqualFold(string(match[1]), "FIXED") {
+		return "ROW_FORMAT=FIXED is refused by InnoDB under innodb_strict_mode", ""
+	}
+	return "ROW_FORMAT=COMPRESSED needs innodb_file_per_table and isn't supported on some managed servers (e.g. Aurora)", ""
+}
+
+// checkTablespace flags general tablespaces and DATA/INDEX DIRECTORY
+// clauses, which refer to storage that must already exist on the target
+func checkTablespace(l *linter) (string, LintSeverity) {
+	if match := tablespacePattern.FindSubmatch(l.text); match != nil {
+		name := strings.Trim(string(match[1]), "`")
+		if !slices.Contains(systemTablespaces, strings.ToLower(name)) {
+			return fmt.Sprintf("TABLESPACE %s must exist on the target", name), ""
+		}
+	}
+	if match := directoryPattern.FindSubmatch(l.text); match != nil {
+		return fmt.Sprintf("%s names a path on the source's file system", strings.ToUpper(string(match[1]))), ""
+	}
+	return "", ""
+}
@@ -0,0 +1,293 @@
+package dumpfile
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// lintHeader is the start of a mysqldump dump, setting its lenient sql_mode
+const lintHeader = "-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)\n" +
+	"/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;\n"
+
+// createTable returns a CREATE TABLE statement for name with one column
+// definition and the table options, four lines long
+func createTable(name, column, options string) string {
+	return "CREATE TABLE `" + name + "` (\n" +
+		"  `id` int NOT NULL,\n" +
+		"  " + column + "\n" +
+		") ENGINE=InnoDB " + options + ";\n"
+}
+
+// lintString writes sql to a dump file and lints it
+func lintString(t *testing.T, sql string, options LintOptions) []LintFinding {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "shop.sql")
+	if err := os.WriteFile(path, []byte(sql), 0644); err != nil {
+		t.Fatal(err)
+	}
+	findings, err := Lint(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return findings
+}
+
+// TestLintRules runs every rule over fixture snippets it must and must not
+// flag. Findings are compared on rule, severity, line, table and line count.
+func TestLintRules(t *testing.T) {
+	users := createTable("users", "`email` varchar(255)", "DEFAULT CHARSET=utf8mb4")
+	tests := []struct {
+		name    string
+		rule    string
+		sql     string
+		options LintOptions
+		want    []LintFinding // of rule; none for a negative fixture
+	}{
+		// max-allowed-packet
+		{
+			name: "statement over the target's packet", rule: "max-allowed-packet",
+			sql:     lintHeader + users + "INSERT INTO `users` VALUES (1,'" + strings.Repeat("a", 100) + "');\n",
+			options: LintOptions{MaxAllowedPacket: 64},
+			want:    []LintFinding{{Severity: LintError, Line: 7, Table: "users", Lines: 1}},
+		},
+		{
+			name: "statement within the target's packet", rule: "max-allowed-packet",
+			sql:     lintHeader + users + "INSERT INTO `users` VALUES (1,'a');\n",
+			options: LintOptions{MaxAllowedPacket: 64},
+		},
+		{
+			name: "over MySQL 5.7's default", rule: "max-allowed-packet",
+			sql:  lintHeader + users + "INSERT INTO `users` VALUES (1,'" + strings.Repeat("a", 5<<20) + "');\n",
+			want: []LintFinding{{Severity: LintInfo, Line: 7, Table: "users", Lines: 1}},
+		},
+		{
+			name: "over MariaDB's default", rule: "max-allowed-packet",
+			sql:  lintHeader + users + "INSERT INTO `users` VALUES (1,'" + strings.Repeat("a", 17<<20) + "');\n",
+			want: []LintFinding{{Severity: LintWarning, Line: 7, Table: "users", Lines: 1}},
+		},
+		{
+			name: "under every default", rule: "max-allowed-packet",
+			sql: lintHeader + users + "INSERT INTO `users` VALUES (1,'" + strings.Repeat("a", 1<<20) + "');\n",
+		},
+
+		// zero-date
+		{
+			name: "zero date under the dump's lenient sql_mode", rule: "zero-date",
+			sql: lintHeader + users +
+				"INSERT INTO `users` VALUES (1,'0000-00-00');\n" +
+				"INSERT INTO `users` VALUES (2,'2024-01-01'),(3,'0000-00-00 00:00:00');\n",
+			want: []LintFinding{{Severity: LintInfo, Line: 7, Table: "users", Lines: 2}},
+		},
+		{
+			name: "zero date under a strict sql_mode", rule: "zero-date",
+			sql: "/*!40101 SET SQL_MODE='STRICT_TRANS_TABLES,NO_ZERO_DATE' */;\n" + users +
+				"INSERT INTO `users` VALUES (1,'0000-00-00');\n",
+			want: []LintFinding{{Severity: LintError, Line: 6, Table: "users", Lines: 1}},
+		},
+		{
+			name: "zero date under TRADITIONAL", rule: "zero-date",
+			sql: "SET SQL_MODE='traditional';\n" + users +
+				"INSERT INTO `users` VALUES (1,'0000-00-00');\n",
+			want: []LintFinding{{Severity: LintError, Line: 6, Table: "users", Lines: 1}},
+		},
+		{
+			name: "zero date without a sql_mode", rule: "zero-date",
+			sql:  users + "INSERT INTO `users` VALUES (1,'0000-00-00');\n",
+			want: []LintFinding{{Severity: LintWarning, Line: 5, Table: "users", Lines: 1}},
+		},
+		{
+			name: "zero date default in the structure", rule: "zero-date",
+			sql:  lintHeader + createTable("events", "`at` datetime NOT NULL DEFAULT '0000-00-00 00:00:00'", ""),
+			want: []LintFinding{{Severity: LintInfo, Line: 5, Table: "events", Lines: 1}},
+		},
+		{
+			name: "later sql_mode of a trigger doesn't count", rule: "zero-date",
+			sql: lintHeader + users +
+				"/*!50003 SET sql_mode = 'STRICT_TRANS_TABLES,NO_ZERO_DATE' */ ;\n" +
+				"INSERT INTO `users` VALUES (1,'0000-00-00');\n",
+			want: []LintFinding{{Severity: LintInfo, Line: 8, Table: "users", Lines: 1}},
+		},
+		{
+			name: "real dates", rule: "zero-date",
+			sql: lintHeader + users + "INSERT INTO `users` VALUES (1,'2000-00-00'),(2,'1970-01-01');\n",
+		},
+
+		// charset-mix
+		{
+			name: "latin1 after utf8mb4", rule: "charset-mix",
+			sql:  lintHeader + users + createTable("legacy", "`name` varchar(10)", "DEFAULT CHARSET=latin1"),
+			want: []LintFinding{{Severity: LintWarning, Line: 10, Table: "legacy", Lines: 1}},
+		},
+		{
+			name: "latin1 column in a utf8 table", rule: "charset-mix",
+			sql:  lintHeader + createTable("users", "`name` varchar(10) CHARACTER SET latin1", "DEFAULT CHARSET=utf8mb3"),
+			want: []LintFinding{{Severity: LintWarning, Line: 6, Table: "users", Lines: 1}},
+		},
+		{
+			name: "utf8 family only", rule: "charset-mix",
+			sql: lintHeader + users + createTable("legacy", "`name` varchar(10) CHARACTER SET utf8", "DEFAULT CHARSET=utf8mb3"),
+		},
+		{
+			name: "latin1 and other charsets", rule: "charset-mix",
+			sql: lintHeader + createTable("a", "`x` char(1) CHARACTER SET ascii", "DEFAULT CHARSET=latin1"),
+		},
+		{
+			name: "charset names in data", rule: "charset-mix",
+			sql: lintHeader + users + "INSERT INTO `users` VALUES (1,'DEFAULT CHARSET=latin1');\n",
+		},
+
+		// mysql80-collation
+		{
+			name: "0900 collation", rule: "mysql80-collation",
+			sql:  lintHeader + createTable("users", "`email` varchar(255)", "DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci"),
+			want: []LintFinding{{Severity: LintWarning, Line: 6, Table: "users", Lines: 1}},
+		},
+		{
+			name: "0900 collation of a column and the table", rule: "mysql80-collation",
+			sql:  lintHeader + createTable("users", "`email` varchar(255) COLLATE utf8mb4_0900_as_cs", "COLLATE=utf8mb4_0900_ai_ci"),
+			want: []LintFinding{{Severity: LintWarning, Line: 5, Table: "users", Lines: 2}},
+		},
+		{
+			name: "portable collation", rule: "mysql80-collation",
+			sql: lintHeader + createTable("users", "`email` varchar(255)", "COLLATE=utf8mb4_unicode_ci"),
+		},
+
+		// mariadb-collation
+		{
+			name: "uca1400 collation", rule: "mariadb-collation",
+			sql:  lintHeader + createTable("users", "`email` varchar(255)", "COLLATE=utf8mb4_uca1400_ai_ci"),
+			want: []LintFinding{{Severity: LintWarning, Line: 6, Table: "users", Lines: 1}},
+		},
+		{
+			name: "general collation", rule: "mariadb-collation",
+			sql: lintHeader + createTable("users", "`email` varchar(255)", "COLLATE=utf8mb4_general_ci"),
+		},
+
+		// row-format
+		{
+			name: "compressed", rule: "row-format",
+			sql:  lintHeader + createTable("blobs", "`b` blob", "ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8"),
+			want: []LintFinding{{Severity: LintWarning, Line: 6, Table: "blobs", Lines: 1}},
+		},
+		{
+			name: "fixed", rule: "row-format",
+			sql:  lintHeader + createTable("blobs", "`b` int", "row_format=fixed"),
+			want: []LintFinding{{Severity: LintWarning, Line: 6, Table: "blobs", Lines: 1}},
+		},
+		{
+			name: "dynamic", rule: "row-format",
+			sql: lintHeader + createTable("blobs", "`b` blob", "ROW_FORMAT=DYNAMIC"),
+		},
+		{
+			name: "row format in data", rule: "row-format",
+			sql: lintHeader + users + "INSERT INTO `users` VALUES (1,'ROW_FORMAT=COMPRESSED');\n",
+		},
+
+		// tablespace
+		{
+			name: "general tablespace", rule: "tablespace",
+			sql:  lintHeader + createTable("archive", "`b` blob", "/*!50100 TABLESPACE `ts_archive` */"),
+			want: []LintFinding{{Severity: LintWarning, Line: 6, Table: "archive", Lines: 1}},
+		},
+		{
+			name: "data directory", rule: "tablespace",
+			sql:  lintHeader + createTable("archive", "`b` blob", "DATA DIRECTORY='/mnt/slow/'"),
+			want: []LintFinding{{Severity: LintWarning, Line: 6, Table: "archive", Lines: 1}},
+		},
+		{
+			name: "system tablespaces", rule: "tablespace",
+			sql: lintHeader + createTable("a", "`b` blob", "TABLESPACE `innodb_system`") + createTable("b", "`b` blob", "TABLESPACE innodb_file_per_table"),
+		},
+	}
+
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.name, func(t *testing.T) {
+			var got []LintFinding
+			for _, finding := range lintString(t, tt.sql, tt.options) {
+				if finding.Rule != tt.rule {
+					continue
+				}
+				if finding.Message == "" || finding.Suggestion == "" {
+					t.Errorf("finding without a message or suggestion: %+v", finding)
+				}
+				finding.Rule, finding.Message, finding.Suggestion = "", "", ""
+				got = append(got, finding)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("findings = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("finding %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+		if len(tt.want) > 0 {
+			covered[tt.rule] = true
+		}
+	}
+
+	for _, rule := range LintRules {
+		if !covered[rule.ID] {
+			t.Errorf("rule %s has no positive fixture", rule.ID)
+		}
+	}
+}
+
+// TestLintDump lints a whole dump with several findings, plain and gzipped,
+// and checks they come in line order, once per rule and table
+func TestLintDump(t *testing.T) {
+	sql := lintHeader +
+		createTable("users", "`email` varchar(255)", "DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci") +
+		createTable("legacy", "`name` varchar(10)", "DEFAULT CHARSET=latin1 ROW_FORMAT=COMPRESSED") +
+		"INSERT INTO `users` VALUES (1,'0000-00-00');\n" +
+		"INSERT INTO `legacy` VALUES (1,'0000-00-00');\n" +
+		"INSERT INTO `users` VALUES (2,'0000-00-00');\n"
+	want := []string{
+		"mysql80-collation users 6",
+		"charset-mix legacy 10",
+		"row-format legacy 10",
+		"zero-date users 11",
+		"zero-date legacy 12",
+	}
+
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "shop.sql")
+	if err := os.WriteFile(plain, []byte(sql), 0644); err != nil {
+		t.Fatal(err)
+	}
+	compressed := filepath.Join(dir, "shop.sql.gz")
+	file, err := os.Create(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write([]byte(sql)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{plain, compressed} {
+		findings, err := Lint(path, LintOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, finding := range findings {
+			got = append(got, strings.Join([]string{finding.Rule, finding.Table, strconv.Itoa(finding.Line)}, " "))
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s findings =\n%s\nwant\n%s", filepath.Base(path), strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
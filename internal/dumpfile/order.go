@@ -0,0 +1,114 @@
+package dumpfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// OrderViolation is a statement that breaks the output order of a dump
+type OrderViolation struct {
+	Line    int    `json:"line"`
+	Problem string `json:"problem"`
+}
+
+// Sections of a dump in the order dbdump writes them. Header comments and
+// session SET statements may appear in any of them.
+const (
+	sectionStructure = iota
+	sectionData
+	sectionObjects
+)
+
+// CheckOrder streams through a dump and reports where it breaks the order
+// dbdump writes: the structure of every table and view first, then the data
+// of each table in one unbroken run, then triggers, routines and events.
+// Each distinct problem is reported once, at the first line it occurs.
+func CheckOrder(path string) ([]OrderViolation, error) {
+	file, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var violations []OrderViolation
+	reported := make(map[string]bool)
+	report := func(line int, format string, args ...any) {
+		problem := fmt.Sprintf(format, args...)
+		if !reported[problem] {
+			reported[problem] = true
+			violations = append(violations, OrderViolation{Line: line, Problem: problem})
+		}
+	}
+
+	section := sectionStructure
+	created := make(map[string]bool)
+	finished := make(map[string]bool)
+	current := ""
+	// Trigger and routine bodies are written between DELIMITER lines and
+	// may hold INSERT statements of their own
+	inBody := false
+
+	scanner := NewScanner(file)
+	for {
+		_, err := scanner.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !scanner.LineEnded() {
+			continue
+		}
+
+		line := scanner.Line() - 1
+		head := bytes.TrimSpace(scanner.Head())
+		switch {
+		case len(head) == 0 || bytes.HasPrefix(head, []byte("--")):
+		case delimiterPattern.Match(head):
+			inBody = string(delimiterPattern.FindSubmatch(head)[1]) != ";"
+		case inBody && section == sectionObjects:
+		case createTablePattern.Match(head), viewPattern.Match(head):
+			name, ok := StatementTable(head)
+			if match := viewPattern.FindSubmatch(head); !ok && match != nil {
+				name = sqlident.Unescape(string(match[1]))
+			}
+			created[name] = true
+			switch section {
+			case sectionData:
+				report(line, "structure of %s after the data", name)
+			case sectionObjects:
+				report(line, "structure of %s after triggers, routines or events", name)
+			}
+		case insertPattern.Match(head):
+			name, ok := StatementTable(head)
+			if !ok {
+				continue
+			}
+			if section == sectionObjects {
+				report(line, "data of %s after triggers, routines or events", name)
+				continue
+			}
+			section = sectionData
+			if !created[name] {
+				report(line, "data of %s before its structure", name)
+			}
+			if name != current {
+				if finished[name] {
+					report(line, "data of %s resumes after other tables' data", name)
+				}
+				finished[current] = true
+				current = name
+			}
+		case triggerPattern.Match(head), routinePattern.Match(head), eventPattern.Match(head):
+			section = sectionObjects
+		}
+	}
+
+	return violations, nil
+}
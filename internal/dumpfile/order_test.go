@@ -0,0 +1,245 @@
+package dumpfile
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Statements of a dump in the form mysqldump writes them; a view is its
+// stand-in of the structure section
+func tableStructure(table string) string {
+	return fmt.Sprintf("DROP TABLE IF EXISTS `%s`;\nCREATE TABLE `%s` (\n  `id` int NOT NULL\n) ENGINE=InnoDB;\n", table, table)
+}
+
+func createView(view string) string {
+	return fmt.Sprintf("/*!50001 CREATE VIEW `%s` AS SELECT \n 1 AS `id`*/;\n", view)
+}
+
+func insert(table string, id int) string {
+	return fmt.Sprintf("INSERT INTO `%s` VALUES (%d);\n", table, id)
+}
+
+func tableData(table string) string {
+	return fmt.Sprintf("LOCK TABLES `%s` WRITE;\n", table) + insert(table, 1) + insert(table, 2) + "UNLOCK TABLES;\n"
+}
+
+func trigger(table string) string {
+	return "DELIMITER ;;\n" +
+		fmt.Sprintf("/*!50003 CREATE*/ /*!50017 DEFINER=`app`@`%%`*/ /*!50003 TRIGGER `%s_ai` AFTER INSERT ON `%s` FOR EACH ROW BEGIN\n", table, table) +
+		"  INSERT INTO `audit` VALUES (NEW.id);\nEND */;;\nDELIMITER ;\n"
+}
+
+func procedure(name, table string) string {
+	return "DELIMITER ;;\n" +
+		fmt.Sprintf("CREATE DEFINER=`app`@`%%` PROCEDURE `%s`()\nBEGIN\n  INSERT INTO `%s` VALUES (9);\nEND ;;\nDELIMITER ;\n", name, table)
+}
+
+const (
+	orderHeader = "-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)\n/*!40101 SET NAMES utf8mb4 */;\n"
+	orderFooter = "/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;\n-- Dump completed on 2026-01-01 12:00:00\n"
+)
+
+// checkDump writes a dump and returns the problems CheckOrder finds, with
+// the text of each problem's line in place of its number
+func checkDump(t *testing.T, dump string) []string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(path, []byte(dump), 0644); err != nil {
+		t.Fatal(err)
+	}
+	violations, err := CheckOrder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return describeViolations(dump, violations)
+}
+
+// describeViolations renders violations as "problem @ line text"
+func describeViolations(dump string, violations []OrderViolation) []string {
+	lines := strings.Split(dump, "\n")
+	var problems []string
+	for _, violation := range violations {
+		text := "?"
+		if violation.Line >= 1 && violation.Line <= len(lines) {
+			text = lines[violation.Line-1]
+		}
+		problems = append(problems, violation.Problem+" @ "+text)
+	}
+	return problems
+}
+
+func TestCheckOrder(t *testing.T) {
+	structure := tableStructure("users") + tableStructure("orders") + createView("recent_orders")
+	data := tableData("users") + tableData("orders")
+	objects := trigger("orders") + procedure("cleanup", "orders")
+
+	tests := []struct {
+		name string
+		dump string
+		want []string
+	}{
+		{name: "in order", dump: orderHeader + structure + data + objects + orderFooter},
+		{name: "structure only", dump: orderHeader + structure + objects + orderFooter},
+		{name: "empty tables", dump: orderHeader + structure + objects},
+		{
+			// mysqldump splits a large table into several INSERTs
+			name: "one table in several statements",
+			dump: orderHeader + structure + tableData("users") + insert("users", 3) + tableData("orders") + objects,
+		},
+		{
+			name: "structure after the data",
+			dump: orderHeader + tableStructure("users") + tableData("users") + tableStructure("orders") + tableData("orders"),
+			want: []string{"structure of orders after the data @ CREATE TABLE `orders` ("},
+		},
+		{
+			name: "data before its structure",
+			dump: orderHeader + tableStructure("users") + tableData("orders") + tableStructure("orders"),
+			want: []string{
+				"data of orders before its structure @ INSERT INTO `orders` VALUES (1);",
+				"structure of orders after the data @ CREATE TABLE `orders` (",
+			},
+		},
+		{
+			name: "data resumes",
+			dump: orderHeader + structure + tableData("users") + tableData("orders") + insert("users", 3) + insert("users", 4) + objects,
+			want: []string{"data of users resumes after other tables' data @ INSERT INTO `users` VALUES (3);"},
+		},
+		{
+			name: "data after triggers",
+			dump: orderHeader + structure + tableData("users") + trigger("orders") + tableData("orders"),
+			want: []string{"data of orders after triggers, routines or events @ INSERT INTO `orders` VALUES (1);"},
+		},
+		{
+			name: "structure after routines",
+			dump: orderHeader + tableStructure("users") + procedure("cleanup", "users") + createView("recent_orders"),
+			want: []string{"structure of recent_orders after triggers, routines or events @ /*!50001 CREATE VIEW `recent_orders` AS SELECT "},
+		},
+		{
+			// The INSERTs of trigger and procedure bodies aren't data
+			name: "inserts in bodies",
+			dump: orderHeader + structure + data + trigger("users") + procedure("archive", "users") + procedure("cleanup", "orders"),
+		},
+		{
+			// Each problem is reported once, at its first line
+			name: "repeated problem",
+			dump: orderHeader + structure + tableData("users") + tableData("orders") + tableData("users") + tableData("orders") + tableData("users"),
+			want: []string{
+				"data of users resumes after other tables' data @ INSERT INTO `users` VALUES (1);",
+				"data of orders resumes after other tables' data @ INSERT INTO `orders` VALUES (1);",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkDump(t, tt.dump); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckOrder() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCheckOrderStable builds dumps with the tables in many orders: any
+// order within a section is fine, and a statement out of place is found
+// the same way whatever the order of the others
+func TestCheckOrderStable(t *testing.T) {
+	tables := []string{"users", "orders", "order_items", "products", "audits", "sessions", "jobs", "settings"}
+	rng := rand.New(rand.NewSource(1506))
+
+	for round := range 50 {
+		structureOrder := slices.Clone(tables)
+		rng.Shuffle(len(structureOrder), func(i, j int) {
+			structureOrder[i], structureOrder[j] = structureOrder[j], structureOrder[i]
+		})
+		dataOrder := slices.Clone(tables)
+		rng.Shuffle(len(dataOrder), func(i, j int) {
+			dataOrder[i], dataOrder[j] = dataOrder[j], dataOrder[i]
+		})
+		// The table moved out of place below must not have the last data
+		if dataOrder[len(dataOrder)-1] == "orders" {
+			dataOrder[0], dataOrder[len(dataOrder)-1] = dataOrder[len(dataOrder)-1], dataOrder[0]
+		}
+
+		build := func(mutate func(structure, data []string) ([]string, []string)) string {
+			var structure, data []string
+			for i, table := range structureOrder {
+				structure = append(structure, tableStructure(table))
+				if i == round%len(structureOrder) {
+					structure = append(structure, createView("recent_"+table))
+				}
+			}
+			for _, table := range dataOrder {
+				data = append(data, tableData(table))
+			}
+			if mutate != nil {
+				structure, data = mutate(structure, data)
+			}
+			objects := trigger(dataOrder[0]) + procedure("cleanup", dataOrder[1])
+			return orderHeader + strings.Join(structure, "") + strings.Join(data, "") + objects + orderFooter
+		}
+
+		if got := checkDump(t, build(nil)); len(got) != 0 {
+			t.Fatalf("round %d: tables in order %v then %v: %q", round, structureOrder, dataOrder, got)
+		}
+
+		// A stray INSERT of orders at the end of the data
+		got := checkDump(t, build(func(structure, data []string) ([]string, []string) {
+			return structure, append(data, insert("orders", 99))
+		}))
+		want := []string{"data of orders resumes after other tables' data @ INSERT INTO `orders` VALUES (99);"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("round %d: stray INSERT in %v: %q, want %q", round, dataOrder, got, want)
+		}
+
+		// The structure of orders after all the data
+		got = checkDump(t, build(func(structure, data []string) ([]string, []string) {
+			structure = slices.DeleteFunc(structure, func(s string) bool { return s == tableStructure("orders") })
+			return structure, append(data, tableStructure("orders"))
+		}))
+		want = []string{
+			"data of orders before its structure @ INSERT INTO `orders` VALUES (1);",
+			"structure of orders after the data @ CREATE TABLE `orders` (",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("round %d: late structure in %v: %q, want %q", round, structureOrder, got, want)
+		}
+	}
+}
+
+// TestCheckOrderParts checks split and compressed dumps read as the whole
+func TestCheckOrderParts(t *testing.T) {
+	good := orderHeader + tableStructure("users") + tableStructure("orders") + tableData("users") + tableData("orders") + trigger("orders") + orderFooter
+	bad := orderHeader + tableStructure("users") + tableData("users") + tableData("orders") + tableStructure("orders") + insert("users", 3) + orderFooter
+
+	for _, dump := range []string{good, bad} {
+		want := checkDump(t, dump)
+		for _, compress := range []bool{false, true} {
+			for _, maxSize := range []int64{64, 256, 1 << 20} {
+				base := filepath.Join(t.TempDir(), "dump.sql")
+				w := NewPartWriter(base, maxSize, compress)
+				if _, err := w.Write([]byte(dump)); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if parts, err := FindParts(base); err != nil || maxSize < 1<<20 && len(parts) < 2 {
+					t.Fatalf("compress %v, parts of %d: %d parts, %v", compress, maxSize, len(parts), err)
+				}
+				violations, err := CheckOrder(base)
+				if err != nil {
+					t.Fatalf("compress %v, parts of %d: %v", compress, maxSize, err)
+				}
+				if got := describeViolations(dump, violations); !reflect.DeepEqual(got, want) {
+					t.Errorf("compress %v, parts of %d: %q, want %q", compress, maxSize, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package dumpfile
+
+import (
+	"strings"
+)
+
+// PartialSuffix marks a dump whose data is incomplete: some tables were
+// truncated at --max-table-size
+const PartialSuffix = ".partial.sql"
+
+// PartialPath returns the name a dump gets when its data is incomplete,
+// e.g. shop_20240101_020000.sql → shop_20240101_020000.partial.sql, keeping
+// a compression extension last (.partial.sql.gz)
+func PartialPath(path string) string {
+	ext := ""
+	for _, compressed := range []string{".gz", ".zst"} {
+		if strings.HasSuffix(path, compressed) {
+			path, ext = strings.TrimSuffix(path, compressed), compressed
+		}
+	}
+	return strings.TrimSuffix(path, ".sql") + PartialSuffix + ext
+}
+
+// IsPartial reports whether a dump file (or part of one) is named as
+// incomplete: truncated tables (.partial.sql) or the kept output of a
+// failed dump (.partial)
+func IsPartial(path string) bool {
+	base := BasePath(path)
+	for _, ext := range []string{".gz", ".zst"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return strings.HasSuffix(base, PartialSuffix) || strings.HasSuffix(path, ".partial")
+}
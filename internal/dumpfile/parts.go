@@ -0,0 +1,320 @@
+package dumpfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var partSuffixPattern = regexp.MustCompile(`\.part(\d{3,})$`)
+
+// Part describes one file of a dump split with a maximum file size
+type Part struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// PartPath returns the path of part n (1-based) of a split dump
+func PartPath(base string, n int) string {
+	return fmt.Sprintf("%s.part%03d", base, n)
+}
+
+// BasePath strips a .partNNN suffix, so any part of a split dump can be used
+// to refer to the whole dump
+func BasePath(path string) string {
+	return partSuffixPattern.ReplaceAllString(path, "")
+}
+
+// FindParts returns the parts of a split dump in order, or nil if path is a
+// regular (unsplit) dump file. Gaps in the part numbering are reported as errors.
+func FindParts(path string) ([]string, error) {
+	base := BasePath(path)
+	if info, err := os.Stat(base); err == nil && !info.IsDir() {
+		return nil, nil
+	}
+
+	var parts []string
+	for n := 1; ; n++ {
+		part := PartPath(base, n)
+		if _, err := os.Stat(part); err != nil {
+			break
+		}
+		parts = append(parts, part)
+	}
+
+	// Any part beyond the first gap means the sequence is incomplete
+	matches, _ := filepath.Glob(base + ".part*")
+	for _, match := range matches {
+		m := partSuffixPattern.FindStringSubmatch(match)
+		if m == nil || BasePath(match) != base {
+			continue
+		}
+		if !containsString(parts, match) {
+			return nil, fmt.Errorf("split dump %s is incomplete: part %03d is missing but %s exists",
+				filepath.Base(base), len(parts)+1, filepath.Base(match))
+		}
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("dump file %s not found: %w", base, os.ErrNotExist)
+	}
+
+	return parts, nil
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// PartWriter is an io.WriteCloser that writes a dump into numbered part files
+// (name.sql.part001, part002, …), starting a new part only between statements
+// so every part can be restored in sequence. A statement larger than the
+// maximum size is written to a part of its own.
+//
+// With compression each part is a gzip stream of its own; the maximum size
+// then applies to the SQL before compression, while the recorded size and
+// hash are those of the file.
+type PartWriter struct {
+	base     string
+	maxSize  int64
+	compress bool
+
+	// gzip level and workers of compressed parts
+	level   int
+	workers int
+
+	file   *os.File
+	sync   *SyncWriter
+	policy SyncPolicy
+	gz     GzipWriter
+	hasher hash.Hash
+	size   int64 // SQL bytes in the current part
+	stored int64 // bytes of the current part file
+	parts  []Part
+
+	// pending holds the statement being written until its terminator is seen
+	pending   []byte
+	lineStart int
+	quote     byte
+	escaped   bool
+	delimiter string
+}
+
+// NewPartWriter creates a PartWriter for base with parts of at most maxSize
+// bytes, gzip-compressing each part if compress is set
+func NewPartWriter(base string, maxSize int64, compress bool) *PartWriter {
+	return &PartWriter{base: base, maxSize: maxSize, compress: compress, level: gzip.DefaultCompression, workers: 1, delimiter: ";"}
+}
+
+// Compression sets the gzip level and number of compression workers of
+// compressed parts
+func (w *PartWriter) Compression(level, workers int) *PartWriter {
+	w.level, w.workers = level, workers
+	return w
+}
+
+// Sync sets when parts are synced to stable storage; by default each is
+// synced once when it is complete
+func (w *PartWriter) Sync(policy SyncPolicy) *PartWriter {
+	w.policy = policy
+	return w
+}
+
+// Write implements io.Writer
+func (w *PartWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		w.pending = append(w.pending, c)
+
+		switch {
+		case w.quote != 0:
+			switch {
+			case w.escaped:
+				w.escaped = false
+			case c == '\\' && w.quote != '`':
+				w.escaped = true
+			case c == w.quote:
+				w.quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			if !w.inCommentLine() {
+				w.quote = c
+			}
+		case c == '\n':
+			if err := w.endLine(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// inCommentLine reports whether the current line is a -- or # comment
+func (w *PartWriter) inCommentLine() bool {
+	line := bytes.TrimSpace(w.pending[w.lineStart:])
+	return bytes.HasPrefix(line, []byte("--")) || bytes.HasPrefix(line, []byte("#"))
+}
+
+// endLine checks whether the line just completed ends a statement
+func (w *PartWriter) endLine() error {
+	line := bytes.TrimSpace(w.pending[w.lineStart:])
+	statementStarted := w.lineStart > 0
+	w.lineStart = len(w.pending)
+
+	switch {
+	case len(line) == 0 || bytes.HasPrefix(line, []byte("--")) || bytes.HasPrefix(line, []byte("#")):
+		// Blank and comment lines between statements can go anywhere
+		if statementStarted {
+			return nil
+		}
+	case delimiterPattern.Match(line):
+		w.delimiter = string(delimiterPattern.FindSubmatch(line)[1])
+	case !bytes.HasSuffix(line, []byte(w.delimiter)):
+		return nil
+	}
+
+	return w.flush()
+}
+
+// flush writes the pending statement, starting a new part first if it would
+// not fit in the current one
+func (w *PartWriter) flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	if w.file == nil || (w.size > 0 && w.size+int64(len(w.pending)) > w.maxSize) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.writer().Write(w.pending); err != nil {
+		return fmt.Errorf("failed to write %s: %w", w.file.Name(), err)
+	}
+	w.size += int64(len(w.pending))
+	if err := w.sync.Boundary(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", w.file.Name(), err)
+	}
+
+	w.pending = w.pending[:0]
+	w.lineStart = 0
+	return nil
+}
+
+// rotate closes the current part and opens the next one
+func (w *PartWriter) rotate() error {
+	if err := w.closePart(); err != nil {
+		return err
+	}
+
+	path := PartPath(w.base, len(w.parts)+1)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create output part: %w", err)
+	}
+
+	w.file = file
+	w.sync = NewSyncWriter(file, w.policy)
+	w.hasher = sha256.New()
+	w.size, w.stored = 0, 0
+	if w.compress {
+		w.gz = NewGzipWriter(w.fileWriter(), w.level, w.workers)
+	}
+	return nil
+}
+
+// writer returns where SQL for the current part is written
+func (w *PartWriter) writer() io.Writer {
+	if w.gz != nil {
+		return w.gz
+	}
+	return w.fileWriter()
+}
+
+// fileWriter writes to the current part file, hashing and counting the bytes
+func (w *PartWriter) fileWriter() io.Writer {
+	return io.MultiWriter(w.sync, w.hasher, &byteCounter{n: &w.stored})
+}
+
+// byteCounter adds the length of everything written to n
+type byteCounter struct {
+	n *int64
+}
+
+// Write implements io.Writer
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c.n += int64(len(p))
+	return len(p), nil
+}
+
+// closePart closes the current part and records it
+func (w *PartWriter) closePart() error {
+	if w.file == nil {
+		return nil
+	}
+
+	path := w.file.Name()
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			_ = w.file.Close()
+			return fmt.Errorf("failed to finish %s: %w", path, err)
+		}
+		w.gz = nil
+	}
+	// A full disk may only show when the data reaches it
+	if err := w.sync.Finish(); err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+	w.parts = append(w.parts, Part{
+		Path:   path,
+		Size:   w.stored,
+		SHA256: hex.EncodeToString(w.hasher.Sum(nil)),
+	})
+	w.file = nil
+	return nil
+}
+
+// Close writes any remaining data and closes the last part
+func (w *PartWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if w.file == nil && len(w.parts) == 0 {
+		// Nothing was written; still produce a (empty) first part
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	return w.closePart()
+}
+
+// Current returns the path of the part being written, or "" between parts
+func (w *PartWriter) Current() string {
+	if w.file == nil {
+		return ""
+	}
+	return w.file.Name()
+}
+
+// Parts returns the parts written so far, in order
+func (w *PartWriter) Parts() []Part {
+	return w.parts
+}
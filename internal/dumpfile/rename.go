@@ -0,0 +1,475 @@
+package dumpfile
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// maxRenameIssues caps how many uncertain spots a Renamer records
+const maxRenameIssues = 100
+
+// maxLiteralScan is how much of a string literal is checked for old names
+const maxLiteralScan = 4096
+
+// RenameIssue is a place in the dump where an old name appears but could
+// not be translated with confidence, so it was left unchanged
+type RenameIssue struct {
+	Line    int
+	Message string
+}
+
+// PrefixRename replaces a table name prefix
+type PrefixRename struct {
+	Old string
+	New string
+}
+
+// identContext is what kind of object the next identifier names
+type identContext int
+
+const (
+	contextNone identContext = iota
+	contextTable
+	contextDatabase
+	contextColumn
+)
+
+// lexState is what the renamer is currently reading
+type lexState int
+
+const (
+	stateCode        lexState = iota
+	stateBacktick             // inside a `quoted identifier`
+	stateString               // inside a '...' or "..." literal
+	stateLineComment          // inside a -- comment, until the end of the line
+	stateData                 // in the VALUES of an INSERT, passed through to the end of the line
+)
+
+// tableKeywords are followed by a table name
+var tableKeywords = map[string]bool{
+	"TABLE": true, "TABLES": true, "INTO": true, "REFERENCES": true, "FROM": true,
+	"JOIN": true, "UPDATE": true, "VIEW": true, "ON": true,
+}
+
+// databaseKeywords are followed by a database name
+var databaseKeywords = map[string]bool{"USE": true, "DATABASE": true, "SCHEMA": true}
+
+// fillerKeywords may come between a keyword and the name it introduces
+// (DROP TABLE IF EXISTS `t`, INSERT IGNORE INTO `t`)
+var fillerKeywords = map[string]bool{
+	"IF": true, "NOT": true, "EXISTS": true, "TEMPORARY": true, "IGNORE": true,
+	"LOW_PRIORITY": true, "DELAYED": true, "HIGH_PRIORITY": true, "ONLY": true,
+}
+
+// Renamer rewrites database names and table name prefixes in a dump stream.
+// It reads the SQL as tokens rather than replacing text: only identifiers in
+// positions that name a database or table (USE, CREATE TABLE, INSERT INTO,
+// LOCK TABLES, REFERENCES, FROM/JOIN in views, ON in triggers, and db.table
+// qualifiers) are renamed, string literals and INSERT data are never
+// touched, and old names in places it can't classify are reported as issues.
+type Renamer struct {
+	databases map[string]string
+	prefixes  []PrefixRename
+
+	state       lexState
+	quote       byte
+	escaped     bool
+	closing     bool // saw a backtick that may close the identifier or start a doubled one
+	token       []byte
+	literal     []byte
+	line        int
+	startOfLine bool
+
+	// Statement state
+	context  identContext
+	afterDot identContext
+	list     bool // in a LOCK TABLES list, where names follow commas
+	insert   bool // an INSERT or REPLACE statement, whose VALUES are data
+	first    bool // the next word is the first of the statement
+
+	held       *heldIdent
+	heldSpaces []byte
+
+	issues  []RenameIssue
+	dropped int
+}
+
+// heldIdent is an identifier waiting for the next byte, which tells whether
+// it qualifies another name (followed by a dot)
+type heldIdent struct {
+	name    string
+	raw     []byte
+	quoted  bool
+	context identContext
+	line    int
+}
+
+// NewRenamer creates a renamer for old=new database names and table prefixes
+func NewRenamer(databases map[string]string, prefixes []PrefixRename) *Renamer {
+	return &Renamer{databases: databases, prefixes: prefixes, first: true, startOfLine: true}
+}
+
+// Rewrite appends the rewritten chunk to dst. line is the line number the
+// chunk belongs to; chunks must not span lines, as returned by Scanner.Next.
+// Bytes of an identifier that continues in the next chunk are held back.
+func (r *Renamer) Rewrite(dst, chunk []byte, line int) []byte {
+	r.line = line
+	if r.startOfLine && r.state == stateCode && bytes.HasPrefix(bytes.TrimLeft(chunk, " \t"), []byte("--")) {
+		r.state = stateLineComment
+	}
+	r.startOfLine = false
+
+	for _, b := range chunk {
+		dst = r.feed(dst, b)
+	}
+	return dst
+}
+
+// Flush returns any bytes still held back at the end of the stream
+func (r *Renamer) Flush(dst []byte) []byte {
+	switch r.state {
+	case stateBacktick:
+		dst = append(dst, '`')
+		dst = append(dst, r.token...)
+		if r.closing {
+			dst = r.endBacktick(dst)
+		}
+	case stateCode:
+		if len(r.token) > 0 {
+			dst = r.endWord(dst)
+		}
+	}
+	return r.resolveHeld(dst, false)
+}
+
+// Issues returns the places where old names were left unchanged
+func (r *Renamer) Issues() []RenameIssue {
+	return r.issues
+}
+
+// DroppedIssues returns how many issues were not recorded beyond the cap
+func (r *Renamer) DroppedIssues() int {
+	return r.dropped
+}
+
+// feed processes one byte
+func (r *Renamer) feed(dst []byte, b byte) []byte {
+	switch r.state {
+	case stateData, stateLineComment:
+		if b == '\n' {
+			r.endLine()
+		}
+		return append(dst, b)
+
+	case stateString:
+		dst = append(dst, b)
+		switch {
+		case r.escaped:
+			r.escaped = false
+		case b == '\\':
+			r.escaped = true
+		case b == r.quote:
+			r.state = stateCode
+			r.checkLiteral()
+			return dst
+		}
+		if len(r.literal) < maxLiteralScan {
+			r.literal = append(r.literal, b)
+		}
+		if b == '\n' {
+			r.line++
+		}
+		return dst
+
+	case stateBacktick:
+		if r.closing {
+			r.closing = false
+			if b == '`' {
+				r.token = append(r.token, '`')
+				return dst
+			}
+			dst = r.endBacktick(dst)
+			return r.feed(dst, b)
+		}
+		if b == '`' {
+			r.closing = true
+			return dst
+		}
+		r.token = append(r.token, b)
+		return dst
+	}
+
+	// Code
+	if sqlident.IsWordByte(b) {
+		if len(r.token) == 0 {
+			dst = r.resolveHeld(dst, false)
+		}
+		r.token = append(r.token, b)
+		return dst
+	}
+	if len(r.token) > 0 {
+		dst = r.endWord(dst)
+		if r.state != stateCode {
+			return r.feed(dst, b)
+		}
+	}
+
+	if r.held != nil {
+		if b == ' ' || b == '\t' || b == '\r' {
+			r.heldSpaces = append(r.heldSpaces, b)
+			return dst
+		}
+		dst = r.resolveHeld(dst, b == '.')
+	}
+
+	switch b {
+	case '`':
+		r.state = stateBacktick
+		r.token = r.token[:0]
+		return dst
+	case '\'', '"':
+		r.state = stateString
+		r.quote = b
+		r.literal = r.literal[:0]
+	case '.':
+		// The context after a qualifier was set when it was resolved
+		return append(dst, b)
+	case ';':
+		r.endStatement()
+	case ',':
+		r.afterDot = contextNone
+		if r.list {
+			r.context = contextTable
+		} else {
+			r.context = contextNone
+		}
+	case '\n':
+		r.endLine()
+	case ' ', '\t', '\r', '/', '*', '!':
+		// Whitespace and the delimiters of /*!...*/ comments keep the context
+	default:
+		r.context = contextNone
+		r.afterDot = contextNone
+	}
+	return append(dst, b)
+}
+
+// endLine resets line-scoped state
+func (r *Renamer) endLine() {
+	if r.state == stateData {
+		r.endStatement()
+	}
+	r.state = stateCode
+	r.line++
+	r.startOfLine = true
+}
+
+// endStatement resets statement-scoped state
+func (r *Renamer) endStatement() {
+	r.context = contextNone
+	r.afterDot = contextNone
+	r.list = false
+	r.insert = false
+	r.first = true
+}
+
+// endWord handles a complete unquoted word: a keyword changes the context,
+// anything else is an identifier
+func (r *Renamer) endWord(dst []byte) []byte {
+	word := string(r.token)
+	r.token = r.token[:0]
+	upper := strings.ToUpper(word)
+
+	if isNumber(word) {
+		// Version numbers of /*!40101 ... */ comments keep the context
+		return append(dst, word...)
+	}
+	if r.first {
+		r.first = false
+		r.insert = upper == "INSERT" || upper == "REPLACE"
+	}
+
+	switch {
+	case r.insert && upper == "VALUES":
+		r.state = stateData
+		return append(dst, word...)
+	case tableKeywords[upper] && r.context == contextNone:
+		r.context = contextTable
+		r.list = r.list || upper == "TABLES"
+		return append(dst, word...)
+	case databaseKeywords[upper]:
+		r.context = contextDatabase
+		return append(dst, word...)
+	case fillerKeywords[upper] && r.context != contextNone:
+		return append(dst, word...)
+	}
+
+	context := r.context
+	if r.afterDot != contextNone {
+		context = r.afterDot
+	}
+	if context == contextNone || (context == contextTable && isKeyword(upper)) {
+		// A keyword or an unquoted name in an unknown position
+		if context == contextNone && r.matchesOld(word) {
+			r.report(fmt.Sprintf("unquoted name %s is not in a recognized position", word))
+		}
+		r.context = contextNone
+		r.afterDot = contextNone
+		return append(dst, word...)
+	}
+
+	r.held = &heldIdent{name: word, raw: []byte(word), context: context, line: r.line}
+	r.context = contextNone
+	r.afterDot = contextNone
+	return dst
+}
+
+// endBacktick handles a complete quoted identifier
+func (r *Renamer) endBacktick(dst []byte) []byte {
+	r.state = stateCode
+	name := string(r.token)
+	r.token = r.token[:0]
+
+	context := r.context
+	if r.afterDot != contextNone {
+		context = r.afterDot
+	}
+	raw := sqlident.AppendQuoted(nil, name)
+
+	r.held = &heldIdent{name: name, raw: raw, quoted: true, context: context, line: r.line}
+	r.context = contextNone
+	r.afterDot = contextNone
+	return dst
+}
+
+// resolveHeld writes the held identifier, renamed when its position and the
+// following byte (a dot makes it a qualifier) say what it names
+func (r *Renamer) resolveHeld(dst []byte, qualifier bool) []byte {
+	held := r.held
+	if held == nil {
+		return dst
+	}
+	r.held = nil
+
+	name := held.name
+	switch {
+	case qualifier:
+		// `db`.`table` or `table`.`column`
+		if renamed, ok := r.databases[name]; ok {
+			name = renamed
+			r.afterDot = contextTable
+		} else if held.context == contextTable {
+			// A database we don't rename qualifying a table
+			r.afterDot = contextTable
+		} else {
+			// A table qualifying a column, as in view definitions
+			name = r.renameTable(name)
+			r.afterDot = contextColumn
+		}
+	case held.context == contextTable:
+		name = r.renameTable(name)
+	case held.context == contextDatabase:
+		if renamed, ok := r.databases[name]; ok {
+			name = renamed
+		}
+	case held.context == contextNone:
+		if _, ok := r.databases[name]; ok {
+			r.reportAt(held.line, fmt.Sprintf("name %s matches --rename-database but is not in a recognized position", name))
+		}
+	}
+
+	if name == held.name {
+		dst = append(dst, held.raw...)
+	} else {
+		dst = appendIdent(dst, name, held.quoted)
+	}
+	dst = append(dst, r.heldSpaces...)
+	r.heldSpaces = r.heldSpaces[:0]
+	return dst
+}
+
+// renameTable applies the first matching prefix rename
+func (r *Renamer) renameTable(name string) string {
+	for _, prefix := range r.prefixes {
+		if rest, ok := strings.CutPrefix(name, prefix.Old); ok {
+			return prefix.New + rest
+		}
+	}
+	return name
+}
+
+// matchesOld reports whether a name is an old database name or has an old prefix
+func (r *Renamer) matchesOld(name string) bool {
+	if _, ok := r.databases[name]; ok {
+		return true
+	}
+	return r.renameTable(name) != name
+}
+
+// checkLiteral reports old names inside a string literal outside INSERT
+// data, such as dynamic SQL in a routine
+func (r *Renamer) checkLiteral() {
+	for old := range r.databases {
+		if bytes.Contains(r.literal, []byte(old)) {
+			r.report(fmt.Sprintf("string literal contains database name %s", old))
+			return
+		}
+	}
+	for _, prefix := range r.prefixes {
+		if bytes.Contains(r.literal, []byte(prefix.Old)) {
+			r.report(fmt.Sprintf("string literal contains prefix %s", prefix.Old))
+			return
+		}
+	}
+}
+
+// report records an issue on the current line
+func (r *Renamer) report(message string) {
+	r.reportAt(r.line, message)
+}
+
+// reportAt records an issue, once per line and message
+func (r *Renamer) reportAt(line int, message string) {
+	for i := len(r.issues) - 1; i >= 0 && r.issues[i].Line == line; i-- {
+		if r.issues[i].Message == message {
+			return
+		}
+	}
+	if len(r.issues) >= maxRenameIssues {
+		r.dropped++
+		return
+	}
+	r.issues = append(r.issues, RenameIssue{Line: line, Message: message})
+}
+
+// appendIdent writes a name, quoting it when it was quoted or needs quotes
+// (a renamed table may be a reserved word even if the original wasn't)
+func appendIdent(dst []byte, name string, quoted bool) []byte {
+	if !quoted && !sqlident.NeedsQuotes(name) {
+		return append(dst, name...)
+	}
+	return sqlident.AppendQuoted(dst, name)
+}
+
+// isNumber reports whether a word is all digits
+func isNumber(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] < '0' || word[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isKeyword reports whether an upper-case word is an SQL keyword that can
+// follow a table keyword without being a name (ON DELETE, FROM DUAL, ...)
+func isKeyword(upper string) bool {
+	switch upper {
+	case "DELETE", "UPDATE", "SET", "DUAL", "SELECT", "SCHEDULE", "DUPLICATE", "COMPLETION", "CASCADE", "RESTRICT", "NO", "ACTION", "NULL", "DEFAULT", "WRITE", "READ", "LOCAL":
+		return true
+	}
+	return false
+}
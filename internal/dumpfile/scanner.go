@@ -0,0 +1,220 @@
+package dumpfile
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// headSize is how many bytes from the start of each line are kept for statement detection
+const headSize = 256
+
+// tailSize is how many bytes from the end of each line are kept for terminator detection
+const tailSize = 16
+
+var tablePattern = regexp.MustCompile("^(?:/\\*!\\d+ )?(?i:INSERT INTO|REPLACE INTO|CREATE TABLE(?: IF NOT EXISTS)?|DROP TABLE IF EXISTS|LOCK TABLES|ALTER TABLE) `((?:[^`]|``)+)`")
+
+var delimiterPattern = regexp.MustCompile(`^(?i:DELIMITER)\s+(\S+)`)
+
+// Scanner walks a dump stream in chunks while tracking line numbers, byte
+// offsets, the current table and whether the stream position is at a
+// statement boundary. It relies on mysqldump's output conventions: every
+// statement ends with its delimiter at the end of a line, and newlines inside
+// string literals are always escaped.
+type Scanner struct {
+	reader *bufio.Reader
+
+	offset    int64
+	line      int
+	lineStart int64
+	startOfLn bool
+	head      []byte
+	tail      []byte
+
+	delimiter string
+	boundary  bool
+	table     string
+	changed   bool
+	lineEnded bool
+}
+
+// NewScanner creates a scanner over a decompressed SQL stream
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{
+		reader:    bufio.NewReaderSize(r, readBufferSize),
+		line:      1,
+		startOfLn: true,
+		delimiter: ";",
+		boundary:  true,
+	}
+}
+
+// Next returns the next chunk of the stream. A chunk never spans lines; it
+// ends either at a newline or when the read buffer is full. The returned
+// slice is only valid until the next call.
+func (s *Scanner) Next() ([]byte, error) {
+	chunk, err := s.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		err = nil
+	}
+	if len(chunk) == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+
+	s.changed = false
+	s.lineEnded = false
+
+	if s.startOfLn {
+		s.lineStart = s.offset
+		s.head = append(s.head[:0], chunk[:min(len(chunk), headSize)]...)
+		s.tail = s.tail[:0]
+		s.startOfLn = false
+	}
+
+	s.tail = append(s.tail, chunk...)
+	if len(s.tail) > tailSize {
+		s.tail = append(s.tail[:0], s.tail[len(s.tail)-tailSize:]...)
+	}
+	s.offset += int64(len(chunk))
+
+	if chunk[len(chunk)-1] == '\n' {
+		s.endLine()
+	} else if err == io.EOF {
+		// Final line without a trailing newline
+		s.endLine()
+	}
+
+	if err == io.EOF {
+		err = nil
+	}
+	return chunk, err
+}
+
+// endLine updates statement state once a complete line has been read
+func (s *Scanner) endLine() {
+	head := bytes.TrimSpace(s.head)
+	tail := bytes.TrimRight(s.tail, " \t\r\n")
+
+	switch {
+	case len(head) == 0 || bytes.HasPrefix(head, []byte("--")):
+		// Blank and comment lines don't change the boundary state
+	case delimiterPattern.Match(head):
+		s.delimiter = string(delimiterPattern.FindSubmatch(head)[1])
+		s.boundary = true
+	default:
+		if table, ok := StatementTable(head); ok {
+			if table != s.table {
+				s.table = table
+				s.changed = true
+			}
+		}
+		s.boundary = bytes.HasSuffix(tail, []byte(s.delimiter))
+	}
+
+	s.line++
+	s.startOfLn = true
+	s.lineEnded = true
+}
+
+// StatementTable returns the table a statement line refers to (INSERT INTO,
+// CREATE TABLE, LOCK TABLES, …), given at least the start of the line
+func StatementTable(head []byte) (string, bool) {
+	match := tablePattern.FindSubmatch(head)
+	if match == nil {
+		return "", false
+	}
+	return sqlident.Unescape(string(match[1])), true
+}
+
+// Offset returns the number of bytes consumed from the stream
+func (s *Scanner) Offset() int64 {
+	return s.offset
+}
+
+// Line returns the line number of the next unread line (1-based)
+func (s *Scanner) Line() int {
+	return s.line
+}
+
+// LineStart returns the offset at which the most recently read line started
+func (s *Scanner) LineStart() int64 {
+	return s.lineStart
+}
+
+// LineEnded reports whether the last chunk completed a line
+func (s *Scanner) LineEnded() bool {
+	return s.lineEnded
+}
+
+// AtLineStart reports whether the next chunk starts a new line
+func (s *Scanner) AtLineStart() bool {
+	return s.startOfLn
+}
+
+// AtBoundary reports whether the current position is between statements
+func (s *Scanner) AtBoundary() bool {
+	return s.startOfLn && s.boundary
+}
+
+// Table returns the table referenced by the most recent table statement
+func (s *Scanner) Table() string {
+	return s.table
+}
+
+// TableChanged reports whether the last completed line switched to a new table
+func (s *Scanner) TableChanged() bool {
+	return s.changed
+}
+
+// Head returns the first bytes of the current (or last completed) line
+func (s *Scanner) Head() []byte {
+	return s.head
+}
+
+// SkipTo discards the stream up to the first statement boundary at or after
+// offset. Session setup lines from the beginning of the dump (SET statements
+// and /*!...*/ version comments) are written to preamble so they can be
+// replayed before resuming, since the skipped part normally contains them.
+func (s *Scanner) SkipTo(offset int64, preamble io.Writer) error {
+	inPreamble := true
+
+	for s.offset < offset || !s.AtBoundary() {
+		chunk, err := s.Next()
+		if err != nil {
+			return err
+		}
+
+		if inPreamble && s.LineEnded() {
+			head := bytes.TrimSpace(s.Head())
+			switch {
+			case len(head) == 0 || bytes.HasPrefix(head, []byte("--")):
+			case isSessionSetup(head) && len(chunk) == int(s.offset-s.lineStart):
+				if _, err := preamble.Write(chunk); err != nil {
+					return err
+				}
+			default:
+				inPreamble = false
+			}
+		}
+	}
+
+	return nil
+}
+
+// isSessionSetup reports whether a line is a session-level setting from the dump header
+func isSessionSetup(head []byte) bool {
+	upper := bytes.ToUpper(head)
+	if bytes.HasPrefix(upper, []byte("SET ")) {
+		return true
+	}
+	if bytes.HasPrefix(upper, []byte("/*!")) {
+		return bytes.Contains(upper, []byte(" SET ")) && !bytes.Contains(upper, []byte("TABLE"))
+	}
+	return false
+}
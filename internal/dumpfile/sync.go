@@ -0,0 +1,118 @@
+package dumpfile
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/fileutil"
+	"github.com/helgesverre/dbdump/internal/units"
+)
+
+// SyncPolicy says when dump output is flushed to stable storage: never,
+// once when a file is complete (the default), or also every Interval bytes
+// on the way
+type SyncPolicy struct {
+	Never    bool
+	Interval int64
+}
+
+// ParseSyncPolicy parses none, end or interval:SIZE (e.g. interval:64MB)
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "" || s == "end":
+		return SyncPolicy{}, nil
+	case s == "none":
+		return SyncPolicy{Never: true}, nil
+	case strings.HasPrefix(s, "interval:"):
+		interval, err := units.ParseBytes(strings.TrimPrefix(s, "interval:"))
+		if err != nil {
+			return SyncPolicy{}, err
+		}
+		if interval <= 0 {
+			return SyncPolicy{}, fmt.Errorf("the interval must be positive")
+		}
+		return SyncPolicy{Interval: interval}, nil
+	}
+	return SyncPolicy{}, fmt.Errorf("unknown sync policy %q (use none, end or interval:SIZE)", s)
+}
+
+// String returns the policy as ParseSyncPolicy reads it
+func (p SyncPolicy) String() string {
+	switch {
+	case p.Never:
+		return "none"
+	case p.Interval > 0:
+		return "interval:" + strconv.FormatInt(p.Interval, 10)
+	}
+	return "end"
+}
+
+// SyncFile is what a SyncWriter writes to, normally an *os.File
+type SyncFile interface {
+	io.Writer
+	Sync() error
+}
+
+// SyncWriter writes to a file and syncs it as its policy says: every
+// Interval bytes, at the first statement boundary its writer reports after
+// the mark, and once more on Finish
+type SyncWriter struct {
+	file     SyncFile
+	policy   SyncPolicy
+	unsynced int64
+	syncs    int
+}
+
+// NewSyncWriter creates a SyncWriter for file
+func NewSyncWriter(file SyncFile, policy SyncPolicy) *SyncWriter {
+	return &SyncWriter{file: file, policy: policy}
+}
+
+// Write implements io.Writer
+func (w *SyncWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.unsynced += int64(n)
+	return n, err
+}
+
+// Due reports whether an interval sync waits for the next statement
+// boundary
+func (w *SyncWriter) Due() bool {
+	return w.policy.Interval > 0 && w.unsynced >= w.policy.Interval
+}
+
+// Boundary tells the writer that what was written so far ends a statement,
+// and syncs if a sync is due
+func (w *SyncWriter) Boundary() error {
+	if !w.Due() {
+		return nil
+	}
+	return w.sync()
+}
+
+// Finish syncs what was written since the last sync, unless the policy is
+// none
+func (w *SyncWriter) Finish() error {
+	if w.policy.Never {
+		return nil
+	}
+	return w.sync()
+}
+
+// sync flushes the file to stable storage
+func (w *SyncWriter) sync() error {
+	if err := fileutil.Sync(w.file); err != nil {
+		return err
+	}
+	w.unsynced = 0
+	w.syncs++
+	return nil
+}
+
+// Syncs returns how many times the file was synced
+func (w *SyncWriter) Syncs() int {
+	return w.syncs
+}
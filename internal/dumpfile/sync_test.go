@@ -0,0 +1,186 @@
+package dumpfile
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// syncRecorder is a SyncFile recording how much was written at each sync
+type syncRecorder struct {
+	written int
+	syncs   []int
+	err     error // returned by Sync
+}
+
+func (f *syncRecorder) Write(p []byte) (int, error) {
+	f.written += len(p)
+	return len(p), nil
+}
+
+func (f *syncRecorder) Sync() error {
+	f.syncs = append(f.syncs, f.written)
+	return f.err
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SyncPolicy
+		wantErr bool
+	}{
+		{in: "", want: SyncPolicy{}},
+		{in: "end", want: SyncPolicy{}},
+		{in: " none ", want: SyncPolicy{Never: true}},
+		{in: "interval:64MB", want: SyncPolicy{Interval: 64_000_000}},
+		{in: "interval:100", want: SyncPolicy{Interval: 100}},
+		{in: "interval:0", wantErr: true},
+		{in: "interval:", wantErr: true},
+		{in: "always", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSyncPolicy(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("ParseSyncPolicy(%q) = %+v, %v", tt.in, got, err)
+			}
+			if err == nil {
+				if again, _ := ParseSyncPolicy(got.String()); again != got {
+					t.Errorf("%+v doesn't survive String: %q", got, got.String())
+				}
+			}
+		})
+	}
+}
+
+// TestSyncWriter writes statements of 35 bytes, each in two writes with a
+// boundary after the second, and checks when the file is synced
+func TestSyncWriter(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []int // bytes written at each sync
+	}{
+		{policy: "none"},
+		{policy: "end", want: []int{350}},
+		{policy: "interval:100", want: []int{105, 210, 315, 350}},
+		{policy: "interval:140", want: []int{140, 280, 350}},
+		{policy: "interval:1", want: []int{35, 70, 105, 140, 175, 210, 245, 280, 315, 350, 350}},
+		{policy: "interval:1GB", want: []int{350}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			policy, err := ParseSyncPolicy(tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file := &syncRecorder{}
+			w := NewSyncWriter(file, policy)
+			for range 10 {
+				for _, half := range []string{"INSERT INTO `t` VALUES ", "(1,'some');\n"} {
+					if _, err := w.Write([]byte(half)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				// Never mid-statement
+				if len(file.syncs) > 0 && file.syncs[len(file.syncs)-1]%35 != 0 {
+					t.Fatalf("synced mid-statement at %d", file.syncs[len(file.syncs)-1])
+				}
+				if err := w.Boundary(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Finish(); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(file.syncs, tt.want) {
+				t.Errorf("synced at %v, want %v", file.syncs, tt.want)
+			}
+			if w.Syncs() != len(tt.want) {
+				t.Errorf("Syncs = %d, want %d", w.Syncs(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSyncWriterError(t *testing.T) {
+	full := errors.New("no space left on device")
+	file := &syncRecorder{err: full}
+	w := NewSyncWriter(file, SyncPolicy{Interval: 10})
+	if _, err := w.Write(make([]byte, 20)); err != nil {
+		t.Fatalf("Write = %v; syncs wait for a boundary", err)
+	}
+	if err := w.Boundary(); !errors.Is(err, full) {
+		t.Errorf("Boundary = %v, want the sync error", err)
+	}
+	if err := w.Finish(); !errors.Is(err, full) {
+		t.Errorf("Finish = %v, want the sync error", err)
+	}
+}
+
+func TestBoundaries(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []int // offsets of the boundaries, after each line that ends one
+	}{
+		{
+			name: "statements",
+			sql:  "INSERT INTO `t` VALUES (1);\nINSERT INTO `t` VALUES (2);\n",
+			want: []int{28, 56},
+		},
+		{
+			name: "statement over several lines",
+			sql:  "CREATE TABLE `t` (\n  `id` int\n) ENGINE=InnoDB;\n",
+			want: []int{47},
+		},
+		{
+			name: "blank and comment lines keep the state",
+			sql:  "-- header\n\nSELECT 1\n-- inside\n;\n",
+			want: []int{10, 11, 32},
+		},
+		{
+			name: "delimiter changes",
+			sql:  "DELIMITER ;;\nCREATE TRIGGER x BEGIN\nSET @a = 1;\nEND ;;\nDELIMITER ;\nSELECT 1;\n",
+			want: []int{13, 55, 67, 77},
+		},
+		{
+			name: "trailing blanks",
+			sql:  "SELECT 1;  \r\n",
+			want: []int{13},
+		},
+		{
+			name: "long line",
+			sql:  "INSERT INTO `t` VALUES ('" + strings.Repeat("x", 3*headSize) + "');\n",
+			want: []int{3*headSize + 29},
+		},
+		{
+			name: "no newline at the end",
+			sql:  "SELECT 1;",
+		},
+	}
+	for _, tt := range tests {
+		for _, size := range []int{1, 3, 16, len(tt.sql)} {
+			b := NewBoundaries()
+			var got []int
+			for start := 0; start < len(tt.sql); start += size {
+				piece := tt.sql[start:min(start+size, len(tt.sql))]
+				if end := b.Last([]byte(piece)); end >= 0 {
+					got = append(got, start+end)
+				}
+			}
+			if size == 1 && !slices.Equal(got, tt.want) {
+				t.Errorf("%s, bytes one by one: boundaries at %v, want %v", tt.name, got, tt.want)
+			}
+			// Larger pieces report the last boundary in each
+			for _, offset := range got {
+				if !slices.Contains(tt.want, offset) {
+					t.Errorf("%s, pieces of %d: boundary at %d, want one of %v", tt.name, size, offset, tt.want)
+				}
+			}
+			if size == len(tt.sql) && len(tt.want) > 0 && (len(got) != 1 || got[0] != tt.want[len(tt.want)-1]) {
+				t.Errorf("%s, in one piece: boundaries at %v, want the last of %v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
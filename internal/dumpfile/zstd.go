@@ -0,0 +1,81 @@
+package dumpfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// zstdMagic starts every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// zstdReader decompresses a stream with the zstd command, as the standard
+// library has no zstd decoder
+type zstdReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	done   bool
+	err    error
+}
+
+// newZstdReader starts zstd decompressing r
+func newZstdReader(r io.Reader) (*zstdReader, error) {
+	path, err := exec.LookPath("zstd")
+	if err != nil {
+		return nil, fmt.Errorf("the dump is zstd-compressed, which requires the zstd command: %w", err)
+	}
+
+	z := &zstdReader{cmd: exec.Command(path, "-dcq")}
+	z.cmd.Stdin = r
+	z.cmd.Stderr = &z.stderr
+	z.stdout, err = z.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start zstd: %w", err)
+	}
+	if err := z.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start zstd: %w", err)
+	}
+	return z, nil
+}
+
+// Read reads decompressed data; a zstd failure is reported at the end of the stream
+func (z *zstdReader) Read(p []byte) (int, error) {
+	n, err := z.stdout.Read(p)
+	if err == io.EOF {
+		if waitErr := z.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close stops zstd if the stream wasn't read to the end
+func (z *zstdReader) Close() error {
+	if !z.done {
+		_ = z.cmd.Process.Kill()
+		_ = z.wait()
+		return nil
+	}
+	return z.err
+}
+
+// wait waits for zstd to exit, once
+func (z *zstdReader) wait() error {
+	if z.done {
+		return z.err
+	}
+	z.done = true
+
+	if err := z.cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("zstd failed: %s", strings.TrimSpace(z.stderr.String()))
+		}
+		z.err = fmt.Errorf("failed to decompress dump: %w", err)
+	}
+	return z.err
+}
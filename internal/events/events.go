@@ -0,0 +1,116 @@
+// Package events writes what a dump is doing as newline-delimited JSON, one
+// Event per line, for tools that wrap dbdump. The stream never holds up the
+// dump: progress is dropped while the reader lags behind, every other event
+// is queued until it has been written.
+package events
+
+import "time"
+
+// SchemaVersion is the version of the event schema; it changes when a field
+// is removed or changes meaning, not when one is added
+const SchemaVersion = 1
+
+// Type says what an event reports, and which payload its Data holds
+type Type string
+
+// Event types, in the order a dump sends them. Phases and tables repeat for
+// each database of a multi-database run; a phase restarted after a table
+// changed mid-dump reports its tables again.
+const (
+	TypeRunStarted     Type = "run_started"     // RunStarted, always first
+	TypePlanReady      Type = "plan_ready"      // PlanReady, once per database
+	TypePhaseStarted   Type = "phase_started"   // PhaseStarted
+	TypeTableCompleted Type = "table_completed" // TableCompleted, during the data phase
+	TypeBytesProgress  Type = "bytes_progress"  // BytesProgress, at most once a second
+	TypeWarning        Type = "warning"         // Warning, at any point
+	TypeRunCompleted   Type = "run_completed"   // RunCompleted, always last
+)
+
+// Event is one line of the stream
+type Event struct {
+	Version int       `json:"version"`
+	Seq     int64     `json:"seq"` // from 1, without gaps: dropped progress takes no number
+	Type    Type      `json:"type"`
+	Time    time.Time `json:"time"`
+	Data    any       `json:"data"`
+}
+
+// RunStarted opens the stream
+type RunStarted struct {
+	DbdumpVersion string   `json:"dbdump_version"`
+	Host          string   `json:"host"`
+	Databases     []string `json:"databases"` // as given: -d, a pattern, or empty with --all-databases
+	PID           int      `json:"pid"`
+}
+
+// PlanReady is sent once the tables of a database are chosen, before
+// anything is written
+type PlanReady struct {
+	Database      string      `json:"database"`
+	OutputFile    string      `json:"output_file"`
+	DryRun        bool        `json:"dry_run,omitempty"`
+	EstimatedSize *int64      `json:"estimated_size"` // null without table statistics
+	Tables        []TablePlan `json:"tables"`
+}
+
+// TablePlan is what a dump does with one table
+type TablePlan struct {
+	Name        string `json:"name"`
+	Disposition string `json:"disposition"` // full, structure-only or skipped
+	DataSize    int64  `json:"data_size"`
+	Rule        string `json:"rule,omitempty"`
+	Confidence  string `json:"confidence,omitempty"`
+}
+
+// PhaseStarted is sent as each phase of a dump starts: structure, data,
+// samples, masked, copies and objects, in that order, each only if the dump
+// has it
+type PhaseStarted struct {
+	Database string `json:"database"`
+	Phase    string `json:"phase"`
+}
+
+// TableCompleted is sent when the data phase has written the last of a
+// table's data
+type TableCompleted struct {
+	Database   string `json:"database"`
+	Table      string `json:"table"`
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// BytesProgress is the position of the data phase
+type BytesProgress struct {
+	Database       string `json:"database"`
+	Table          string `json:"table"`
+	Index          int    `json:"index"` // of Table among the tables with data, from 1
+	Count          int    `json:"count"`
+	Bytes          int64  `json:"bytes"`
+	EstimatedBytes int64  `json:"estimated_bytes"`
+}
+
+// Warning is a distinct warning, sent the first time it is raised
+type Warning struct {
+	Message string `json:"message"`
+}
+
+// RunCompleted closes the stream
+type RunCompleted struct {
+	Status   string   `json:"status"` // ok, failed or interrupted
+	ExitCode int      `json:"exit_code"`
+	Error    string   `json:"error,omitempty"`
+	Results  []Result `json:"results"` // one per database dumped
+	Warnings int      `json:"warnings"`
+}
+
+// Result is a finished dump
+type Result struct {
+	Database         string   `json:"database"`
+	OutputFile       string   `json:"output_file"`
+	Parts            []string `json:"parts,omitempty"`
+	DurationMs       int64    `json:"duration_ms"`
+	FileSize         int64    `json:"file_size"`
+	UncompressedSize int64    `json:"uncompressed_size"`
+	ExcludedTables   []string `json:"excluded_tables"`
+	OverBudgetTables []string `json:"over_budget_tables,omitempty"`
+}
@@ -0,0 +1,146 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ProgressInterval is the shortest time between two progress events
+const ProgressInterval = time.Second
+
+// Stream writes events to a reader from its own goroutine, so a slow or
+// stuck reader never stops the dump. Methods on a nil Stream do nothing.
+type Stream struct {
+	out io.WriteCloser
+
+	mu           sync.Mutex
+	wake         *sync.Cond
+	queue        []queued
+	progress     bool // a progress event is queued
+	lastProgress time.Time
+	seq          int64
+	closed       bool
+	err          error
+	done         chan struct{}
+}
+
+// queued is an encoded event waiting to be written
+type queued struct {
+	line     []byte
+	progress bool
+}
+
+// NewStream starts writing events to out; Close ends the stream
+func NewStream(out io.WriteCloser) *Stream {
+	s := &Stream{out: out, done: make(chan struct{})}
+	s.wake = sync.NewCond(&s.mu)
+	go s.write()
+	return s
+}
+
+// Send queues an event; it is never dropped
+func (s *Stream) Send(typ Type, data any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enqueue(typ, data, false)
+}
+
+// Progress queues a progress event, unless one was sent less than
+// ProgressInterval ago or the last one hasn't been written yet
+func (s *Stream) Progress(data BytesProgress) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.progress || now.Sub(s.lastProgress) < ProgressInterval {
+		return
+	}
+	if s.enqueue(TypeBytesProgress, data, true) {
+		s.progress = true
+		s.lastProgress = now
+	}
+}
+
+// enqueue encodes an event for the writer; it reports whether the event was
+// queued, which it isn't once the stream is closed or broken
+func (s *Stream) enqueue(typ Type, data any, progress bool) bool {
+	if s.closed || s.err != nil {
+		return false
+	}
+	line, err := json.Marshal(Event{
+		Version: SchemaVersion,
+		Seq:     s.seq + 1,
+		Type:    typ,
+		Time:    time.Now().UTC(),
+		Data:    data,
+	})
+	if err != nil {
+		s.err = fmt.Errorf("failed to encode %s event: %w", typ, err)
+		s.wake.Signal()
+		return false
+	}
+	s.seq++
+	s.queue = append(s.queue, queued{line: append(line, '\n'), progress: progress})
+	s.wake.Signal()
+	return true
+}
+
+// write sends queued events until the stream is closed and drained, or a
+// write fails (the reader went away); the queue is dropped from then on
+func (s *Stream) write() {
+	defer close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		for len(s.queue) == 0 && !s.closed && s.err == nil {
+			s.wake.Wait()
+		}
+		if s.err != nil || len(s.queue) == 0 {
+			s.queue = nil
+			return
+		}
+
+		next := s.queue[0]
+		s.queue = s.queue[1:]
+
+		s.mu.Unlock()
+		_, err := s.out.Write(next.line)
+		s.mu.Lock()
+
+		if next.progress {
+			s.progress = false
+		}
+		if err != nil && s.err == nil {
+			s.err = fmt.Errorf("failed to write events: %w", err)
+		}
+	}
+}
+
+// Close sends the queued events, waiting for the reader to take them, and
+// closes the output. It returns the first write error, after which the
+// events that followed were dropped.
+func (s *Stream) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	s.closed = true
+	s.wake.Signal()
+	s.mu.Unlock()
+	<-s.done
+
+	err := s.err
+	if closeErr := s.out.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("failed to close events: %w", closeErr)
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// read decodes the events written to r until it is closed
+func read(t *testing.T, r io.Reader) []Event {
+	t.Helper()
+	var got []Event
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %q is not an event: %v", scanner.Text(), err)
+		}
+		got = append(got, event)
+	}
+	return got
+}
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		stalled   bool // the reader starts once every event is sent
+		wantTypes []Type
+	}{
+		{
+			name:      "live reader",
+			wantTypes: []Type{TypeRunStarted, TypeBytesProgress, TypeWarning, TypeRunCompleted},
+		},
+		{
+			// The first progress event is still queued when the second
+			// comes, which is dropped; the others never are
+			name:      "stalled reader",
+			stalled:   true,
+			wantTypes: []Type{TypeRunStarted, TypeBytesProgress, TypeWarning, TypeRunCompleted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, writer := io.Pipe()
+			start := make(chan struct{})
+			received := make(chan []Event)
+			go func() {
+				<-start
+				received <- read(t, reader)
+			}()
+			if !tt.stalled {
+				close(start)
+			}
+
+			s := NewStream(writer)
+			s.Send(TypeRunStarted, RunStarted{PID: 1})
+			s.Progress(BytesProgress{Bytes: 1})
+			s.Progress(BytesProgress{Bytes: 2}) // within ProgressInterval, or still queued
+			s.Send(TypeWarning, Warning{Message: "w"})
+			s.Send(TypeRunCompleted, RunCompleted{Status: "ok"})
+			if tt.stalled {
+				close(start)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := <-received
+			var types []Type
+			for i, event := range got {
+				types = append(types, event.Type)
+				if event.Seq != int64(i+1) {
+					t.Errorf("event %d has seq %d", i+1, event.Seq)
+				}
+			}
+			if !slices.Equal(types, tt.wantTypes) {
+				t.Errorf("events %v, want %v", types, tt.wantTypes)
+			}
+		})
+	}
+}
+
+// brokenWriter fails every write, like a pipe whose reader went away
+type brokenWriter struct {
+	writes int
+	closed bool
+}
+
+func (w *brokenWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("broken pipe")
+}
+
+func (w *brokenWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestStreamReaderGone(t *testing.T) {
+	out := &brokenWriter{}
+	s := NewStream(out)
+	s.Send(TypeRunStarted, RunStarted{})
+	// Sending after the failure neither blocks nor fails
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		failed := s.err != nil
+		s.mu.Unlock()
+		if failed || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.Send(TypeRunCompleted, RunCompleted{})
+
+	err := s.Close()
+	if err == nil || !strings.Contains(err.Error(), "broken pipe") {
+		t.Errorf("Close() = %v, want the write error", err)
+	}
+	if out.writes != 1 {
+		t.Errorf("%d writes, want 1: events after a failed write are dropped", out.writes)
+	}
+	if !out.closed {
+		t.Error("output not closed")
+	}
+}
+
+func TestNilStream(t *testing.T) {
+	var s *Stream
+	s.Send(TypeRunStarted, RunStarted{})
+	s.Progress(BytesProgress{})
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+}
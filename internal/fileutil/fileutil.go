@@ -0,0 +1,87 @@
+// Package fileutil writes dbdump's own state files (profiles, history) so
+// that concurrent dbdump processes can't corrupt them: whole-file writes go
+// through a temporary file and a rename, and read-modify-write cycles hold an
+// advisory lock on a sidecar lock file.
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes data to a temporary file in path's directory and
+// renames it over path, so readers see either the old or the new contents
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temporary file unless it was renamed into place
+	renamed := false
+	defer func() {
+		if !renamed {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	renamed = true
+	return nil
+}
+
+// AppendFile appends data to path while holding its lock, so lines written
+// by concurrent processes never interleave
+func AppendFile(path string, data []byte, perm os.FileMode) error {
+	return WithLock(path, func() error {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, perm)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(data); err != nil {
+			_ = file.Close()
+			return err
+		}
+		return file.Close()
+	})
+}
+
+// WithLock runs fn while holding an exclusive advisory lock for path. The
+// lock is taken on path+".lock" rather than path itself, since atomic writes
+// replace path's inode.
+func WithLock(path string, fn func() error) error {
+	lockFile, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+	defer func() {
+		_ = lockFile.Close()
+	}()
+
+	if err := lock(lockFile); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	defer func() {
+		_ = unlock(lockFile)
+	}()
+
+	return fn()
+}
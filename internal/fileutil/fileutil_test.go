@@ -0,0 +1,261 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Environment of the writer processes TestStressProcesses starts
+const (
+	writerEnvDir = "FILEUTIL_STRESS_DIR"
+	writerEnvID  = "FILEUTIL_STRESS_ID"
+)
+
+// stressRounds is how many updates each writer makes
+const stressRounds = 50
+
+// counters is the state file of the stress tests: each writer's count of
+// updates, plus padding so a torn write would be visible
+type counters struct {
+	Writes  map[string]int `json:"writes"`
+	Padding string         `json:"padding"`
+}
+
+// increment adds one write of writer to the state file at path, holding its
+// lock across the read and the write
+func increment(path, writer string) error {
+	return WithLock(path, func() error {
+		state := counters{Writes: map[string]int{}}
+		data, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return err
+		default:
+			if err := json.Unmarshal(data, &state); err != nil {
+				return fmt.Errorf("state file torn: %w", err)
+			}
+		}
+		state.Writes[writer]++
+		state.Padding = strings.Repeat(writer, 1000)
+		data, err = json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		return WriteFileAtomic(path, data, 0600)
+	})
+}
+
+// stress makes stressRounds updates of the state and appends of the log
+// in dir as writer
+func stress(dir, writer string) error {
+	for i := 0; i < stressRounds; i++ {
+		if err := increment(filepath.Join(dir, "state.json"), writer); err != nil {
+			return err
+		}
+		line := fmt.Sprintf("%s %d %s\n", writer, i, strings.Repeat("x", 512))
+		if err := AppendFile(filepath.Join(dir, "history.log"), []byte(line), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readWhileWriting reads the state file until done is closed, failing on
+// any read that doesn't parse: atomic writes never show a partial file
+func readWhileWriting(t *testing.T, path string, done <-chan struct{}) {
+	t.Helper()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Errorf("reading during writes: %v", err)
+			return
+		}
+		var state counters
+		if err := json.Unmarshal(data, &state); err != nil {
+			t.Errorf("read a partial state file (%d bytes): %v", len(data), err)
+			return
+		}
+	}
+}
+
+// checkStress checks the files stress left in dir for writers
+func checkStress(t *testing.T, dir string, writers []string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state counters
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("state file doesn't parse: %v", err)
+	}
+	for _, writer := range writers {
+		if state.Writes[writer] != stressRounds {
+			t.Errorf("writer %s: %d updates recorded, want %d (updates were lost)", writer, state.Writes[writer], stressRounds)
+		}
+	}
+
+	file, err := os.Open(filepath.Join(dir, "history.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	next := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || len(fields[2]) != 512 {
+			t.Fatalf("interleaved log line: %q", scanner.Text())
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n != next[fields[0]] {
+			t.Fatalf("log line %q out of order, want %d", scanner.Text(), next[fields[0]])
+		}
+		next[fields[0]]++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for _, writer := range writers {
+		if next[writer] != stressRounds {
+			t.Errorf("writer %s: %d log lines, want %d", writer, next[writer], stressRounds)
+		}
+	}
+
+	// No temporary files are left behind
+	leftovers, _ := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	if len(leftovers) > 0 {
+		t.Errorf("temporary files left: %v", leftovers)
+	}
+}
+
+func TestStressGoroutines(t *testing.T) {
+	dir := t.TempDir()
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			readWhileWriting(t, filepath.Join(dir, "state.json"), done)
+		}()
+	}
+
+	var writers []string
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		writer := fmt.Sprintf("g%d", i)
+		writers = append(writers, writer)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := stress(dir, writer); err != nil {
+				t.Errorf("writer %s: %v", writer, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	readers.Wait()
+	checkStress(t, dir, writers)
+}
+
+// TestStressProcesses runs the writers as separate processes, as parallel
+// dbdump runs are, so the advisory lock is what keeps them apart
+func TestStressProcesses(t *testing.T) {
+	if dir := os.Getenv(writerEnvDir); dir != "" {
+		// Running as one of the writers
+		if err := stress(dir, os.Getenv(writerEnvID)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if testing.Short() {
+		t.Skip("starts writer processes")
+	}
+
+	dir := t.TempDir()
+	done := make(chan struct{})
+	var reader sync.WaitGroup
+	reader.Add(1)
+	go func() {
+		defer reader.Done()
+		readWhileWriting(t, filepath.Join(dir, "state.json"), done)
+	}()
+
+	var writers []string
+	var commands []*exec.Cmd
+	var outputs []*bytes.Buffer
+	for i := 0; i < 6; i++ {
+		writer := fmt.Sprintf("p%d", i)
+		writers = append(writers, writer)
+		cmd := exec.Command(os.Args[0], "-test.run=^TestStressProcesses$")
+		cmd.Env = append(os.Environ(), writerEnvDir+"="+dir, writerEnvID+"="+writer)
+		output := &bytes.Buffer{}
+		cmd.Stdout, cmd.Stderr = output, output
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		commands = append(commands, cmd)
+		outputs = append(outputs, output)
+	}
+	for i, cmd := range commands {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("writer %s: %v\n%s", writers[i], err, outputs[i])
+		}
+	}
+	close(done)
+	reader.Wait()
+	checkStress(t, dir, writers)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := WriteFileAtomic(path, []byte("one"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(path, []byte("two"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "two" {
+		t.Fatalf("contents = %q, %v; want two", data, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0640 {
+		t.Errorf("mode = %v, want 0640", perm)
+	}
+
+	// A failed write leaves the file and no temporary file
+	if err := WriteFileAtomic(filepath.Join(path, "not-a-dir"), []byte("x"), 0600); err == nil {
+		t.Error("writing below a file succeeded")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries after a failed write, want 1", len(entries))
+	}
+}
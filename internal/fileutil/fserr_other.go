@@ -0,0 +1,24 @@
+//go:build !windows
+
+package fileutil
+
+import (
+	"errors"
+	"syscall"
+)
+
+// IsNoSpace reports whether err means the filesystem is full
+func IsNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// IsFileTooLarge reports whether err means the file reached the largest
+// size the filesystem allows
+func IsFileTooLarge(err error) bool {
+	return errors.Is(err, syscall.EFBIG)
+}
+
+// syncUnsupported reports whether a Sync error only means the file can't be synced
+func syncUnsupported(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, errors.ErrUnsupported)
+}
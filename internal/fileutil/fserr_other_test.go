@@ -0,0 +1,54 @@
+//go:build !windows
+
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestWriteErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		noSpace  bool
+		tooLarge bool
+	}{
+		{name: "ENOSPC", err: syscall.ENOSPC, noSpace: true},
+		{name: "EFBIG", err: syscall.EFBIG, tooLarge: true},
+		{name: "path error", err: &fs.PathError{Op: "write", Path: "/mnt/usb/shop.sql", Err: syscall.ENOSPC}, noSpace: true},
+		{name: "wrapped", err: fmt.Errorf("failed to write output: %w", &os.PathError{Op: "write", Path: "shop.sql", Err: syscall.EFBIG}), tooLarge: true},
+		{name: "EDQUOT", err: syscall.EDQUOT},
+		{name: "EIO", err: syscall.EIO},
+		{name: "other", err: errors.New("no space left on device")},
+		{name: "nil"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsNoSpace(tt.err); got != tt.noSpace {
+				t.Errorf("IsNoSpace() = %v, want %v", got, tt.noSpace)
+			}
+			if got := IsFileTooLarge(tt.err); got != tt.tooLarge {
+				t.Errorf("IsFileTooLarge() = %v, want %v", got, tt.tooLarge)
+			}
+		})
+	}
+}
+
+func TestSyncUnsupported(t *testing.T) {
+	for _, err := range []error{syscall.EINVAL, &os.PathError{Op: "sync", Path: "/dev/stdout", Err: syscall.EINVAL}, errors.ErrUnsupported} {
+		if !syncUnsupported(err) {
+			t.Errorf("syncUnsupported(%v) = false", err)
+		}
+	}
+	for _, err := range []error{syscall.ENOSPC, syscall.EIO} {
+		if syncUnsupported(err) {
+			t.Errorf("syncUnsupported(%v) = true", err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package fileutil
+
+import (
+	"os"
+	"runtime"
+	"strings"
+)
+
+// fat32MaxFileSize is the largest file FAT32 can hold: 4 GiB minus one byte
+const fat32MaxFileSize = 4<<30 - 1
+
+// maxFileSizes maps filesystem types, as the platforms report them, to the
+// largest file they can hold. Types missing here have no limit a dump is
+// likely to reach (exFAT, NTFS, ext4, APFS, …).
+var maxFileSizes = map[string]int64{
+	"vfat":  fat32MaxFileSize, // Linux
+	"msdos": fat32MaxFileSize, // Linux (without long names) and macOS
+	"fat":   fat32MaxFileSize, // Windows, FAT12/16
+	"fat32": fat32MaxFileSize, // Windows
+}
+
+// Filesystem describes the filesystem holding a path, as far as it is known
+type Filesystem struct {
+	// Type is the filesystem type in lower case (e.g. "vfat", "exfat",
+	// "ntfs", "apfs"), or empty when it could not be determined
+	Type string
+
+	// MaxFileSize is the largest file the filesystem can hold, or 0 when
+	// it is unknown or out of reach
+	MaxFileSize int64
+}
+
+// NewFilesystem describes a filesystem of the given type
+func NewFilesystem(fsType string) Filesystem {
+	fsType = strings.ToLower(fsType)
+	return Filesystem{Type: fsType, MaxFileSize: maxFileSizes[fsType]}
+}
+
+// DetectFilesystem returns the filesystem holding path (statfs on Linux and
+// macOS, GetVolumeInformation on Windows); its Type is empty when the
+// platform can't tell
+func DetectFilesystem(path string) Filesystem {
+	fsType, err := filesystemType(path)
+	if err != nil {
+		return Filesystem{}
+	}
+	return NewFilesystem(fsType)
+}
+
+// Fits reports whether a file of size bytes is within the filesystem's
+// file size limit
+func (f Filesystem) Fits(size int64) bool {
+	return f.MaxFileSize == 0 || size <= f.MaxFileSize
+}
+
+// Name returns the filesystem type for messages
+func (f Filesystem) Name() string {
+	if f.Type == "" {
+		return "output"
+	}
+	return f.Type
+}
+
+// Sync flushes file to stable storage. Filesystems that can't sync (some
+// FUSE and special files) are not an error; a full disk that only shows up
+// at this point is.
+func Sync(file interface{ Sync() error }) error {
+	if err := file.Sync(); err != nil && !syncUnsupported(err) {
+		return err
+	}
+	return nil
+}
+
+// SyncDir flushes the entries of a directory (files created or renamed in
+// it) to stable storage. Windows can't sync directories and commits
+// renames itself, so it is a no-op there.
+func SyncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return Sync(file)
+}
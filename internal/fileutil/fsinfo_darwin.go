@@ -0,0 +1,15 @@
+//go:build darwin
+
+package fileutil
+
+import "golang.org/x/sys/unix"
+
+// filesystemType returns the type of the filesystem holding path, e.g.
+// "apfs", "msdos" or "exfat"
+func filesystemType(path string) (string, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return "", err
+	}
+	return unix.ByteSliceToString(stat.Fstypename[:]), nil
+}
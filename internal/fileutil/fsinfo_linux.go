@@ -0,0 +1,32 @@
+//go:build linux
+
+package fileutil
+
+import "golang.org/x/sys/unix"
+
+// filesystemNames maps statfs magic numbers to filesystem types
+var filesystemNames = map[int64]string{
+	0x4d44:     "vfat",
+	0x2011bab0: "exfat",
+	0x5346544e: "ntfs",
+	0x7366746e: "ntfs3",
+	0xef53:     "ext4",
+	0x9123683e: "btrfs",
+	0x58465342: "xfs",
+	0x01021994: "tmpfs",
+	0x794c7630: "overlay",
+	0x6969:     "nfs",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuseblk",
+	0x65735543: "fuse",
+}
+
+// filesystemType returns the type of the filesystem holding path
+func filesystemType(path string) (string, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return "", err
+	}
+	return filesystemNames[int64(stat.Type)], nil
+}
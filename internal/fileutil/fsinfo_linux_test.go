@@ -0,0 +1,26 @@
+//go:build linux
+
+package fileutil
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFilesystemTypeLinux(t *testing.T) {
+	// /proc is procfs, which the table doesn't name
+	if fsType, err := filesystemType("/proc"); err != nil || fsType != "" {
+		t.Errorf("filesystemType(/proc) = %q, %v; want an unnamed type", fsType, err)
+	}
+	if _, err := filesystemType("/nonexistent/dir"); err == nil {
+		t.Error("filesystemType() of a missing path succeeded")
+	}
+
+	// Mounts of a named type are named
+	if _, err := os.Stat("/dev/shm"); err != nil {
+		t.Skip("no /dev/shm")
+	}
+	if fsType, err := filesystemType("/dev/shm"); err != nil || fsType != "tmpfs" {
+		t.Skipf("/dev/shm is %q (%v), not tmpfs", fsType, err)
+	}
+}
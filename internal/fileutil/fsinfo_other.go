@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package fileutil
+
+import "errors"
+
+// filesystemType is not available on this platform
+func filesystemType(path string) (string, error) {
+	return "", errors.ErrUnsupported
+}
@@ -0,0 +1,95 @@
+package fileutil
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewFilesystem(t *testing.T) {
+	tests := []struct {
+		fsType string
+		want   Filesystem
+	}{
+		{fsType: "vfat", want: Filesystem{Type: "vfat", MaxFileSize: fat32MaxFileSize}},
+		{fsType: "msdos", want: Filesystem{Type: "msdos", MaxFileSize: fat32MaxFileSize}},
+		// Windows reports volume types in upper case
+		{fsType: "FAT32", want: Filesystem{Type: "fat32", MaxFileSize: fat32MaxFileSize}},
+		{fsType: "FAT", want: Filesystem{Type: "fat", MaxFileSize: fat32MaxFileSize}},
+		{fsType: "exFAT", want: Filesystem{Type: "exfat"}},
+		{fsType: "NTFS", want: Filesystem{Type: "ntfs"}},
+		{fsType: "apfs", want: Filesystem{Type: "apfs"}},
+		{fsType: "ext4", want: Filesystem{Type: "ext4"}},
+		{fsType: "", want: Filesystem{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fsType, func(t *testing.T) {
+			if got := NewFilesystem(tt.fsType); got != tt.want {
+				t.Errorf("NewFilesystem(%q) = %+v, want %+v", tt.fsType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilesystemFits(t *testing.T) {
+	const gib = 1 << 30
+
+	tests := []struct {
+		name string
+		fs   Filesystem
+		size int64
+		want bool
+	}{
+		{name: "fat32, small", fs: NewFilesystem("vfat"), size: gib, want: true},
+		{name: "fat32, largest file", fs: NewFilesystem("vfat"), size: 4*gib - 1, want: true},
+		{name: "fat32, 4GiB", fs: NewFilesystem("vfat"), size: 4 * gib, want: false},
+		{name: "fat32, 6GB", fs: NewFilesystem("FAT32"), size: 6e9, want: false},
+		{name: "exfat, 6GB", fs: NewFilesystem("exfat"), size: 6e9, want: true},
+		{name: "unknown, 1TB", fs: Filesystem{}, size: 1e12, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fs.Fits(tt.size); got != tt.want {
+				t.Errorf("Fits(%d) = %v, want %v", tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilesystemName(t *testing.T) {
+	if name := NewFilesystem("vfat").Name(); name != "vfat" {
+		t.Errorf("Name() = %q, want vfat", name)
+	}
+	if name := (Filesystem{}).Name(); name != "output" {
+		t.Errorf("Name() of an unknown filesystem = %q, want output", name)
+	}
+}
+
+func TestDetectFilesystem(t *testing.T) {
+	// Whatever the temporary directory is on, its limit follows its type
+	fs := DetectFilesystem(t.TempDir())
+	if want := NewFilesystem(fs.Type); fs != want {
+		t.Errorf("DetectFilesystem() = %+v, want %+v", fs, want)
+	}
+
+	if fs := DetectFilesystem(filepath.Join(t.TempDir(), "missing")); fs != (Filesystem{}) {
+		t.Errorf("DetectFilesystem() of a missing directory = %+v, want nothing known", fs)
+	}
+}
+
+// syncFailure is a file whose Sync fails with err
+type syncFailure struct{ err error }
+
+func (f syncFailure) Sync() error { return f.err }
+
+func TestSync(t *testing.T) {
+	if err := Sync(syncFailure{errors.ErrUnsupported}); err != nil {
+		t.Errorf("Sync() of a file that can't be synced = %v, want nil", err)
+	}
+	failure := errors.New("input/output error")
+	if err := Sync(syncFailure{failure}); !errors.Is(err, failure) {
+		t.Errorf("Sync() = %v, want %v", err, failure)
+	}
+}
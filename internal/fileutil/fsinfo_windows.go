@@ -0,0 +1,46 @@
+//go:build windows
+
+package fileutil
+
+import (
+	"errors"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// filesystemType returns the type of the volume holding path, e.g. "NTFS",
+// "FAT32" or "exFAT"
+func filesystemType(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	root := filepath.VolumeName(abs) + `\`
+	rootPtr, err := windows.UTF16PtrFromString(root)
+	if err != nil {
+		return "", err
+	}
+
+	name := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumeInformation(rootPtr, nil, 0, nil, nil, nil, &name[0], uint32(len(name))); err != nil {
+		return "", err
+	}
+	return windows.UTF16ToString(name), nil
+}
+
+// IsNoSpace reports whether err means the filesystem is full
+func IsNoSpace(err error) bool {
+	return errors.Is(err, windows.ERROR_DISK_FULL) || errors.Is(err, windows.ERROR_HANDLE_DISK_FULL)
+}
+
+// IsFileTooLarge reports whether err means the file reached the largest
+// size the filesystem allows
+func IsFileTooLarge(err error) bool {
+	return errors.Is(err, windows.ERROR_FILE_TOO_LARGE)
+}
+
+// syncUnsupported reports whether a Sync error only means the file can't be synced
+func syncUnsupported(err error) bool {
+	return errors.Is(err, windows.ERROR_INVALID_FUNCTION) || errors.Is(err, windows.ERROR_INVALID_HANDLE)
+}
@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package fileutil
+
+import "os"
+
+// lock is a no-op where no advisory locking is available; writes are still atomic
+func lock(file *os.File) error {
+	return nil
+}
+
+func unlock(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,97 @@
+// Package gitlabel reads the state of the git work tree a dump is taken in
+// (branch, commit, uncommitted changes and nearest tag), so the dump can be
+// matched with the code it belongs to later
+package gitlabel
+
+import (
+	"bytes"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Tag keys the labels are recorded under
+const (
+	KeyBranch = "git.branch"
+	KeyCommit = "git.commit"
+	KeyDirty  = "git.dirty"
+	KeyTag    = "git.tag"
+)
+
+// Labels is the state of a work tree
+type Labels struct {
+	Branch string // empty on a detached HEAD
+	Commit string
+	Dirty  bool   // tracked files have uncommitted changes
+	Tag    string // nearest tag reachable from the commit, if any
+}
+
+// Read returns the labels of the work tree containing dir, or nil when git
+// isn't installed, dir isn't in a work tree or it has no commit yet
+func Read(dir string) *Labels {
+	commit, ok := git(dir, "rev-parse", "HEAD")
+	if !ok {
+		return nil
+	}
+	labels := &Labels{Commit: commit}
+	if branch, ok := git(dir, "rev-parse", "--abbrev-ref", "HEAD"); ok && branch != "HEAD" {
+		labels.Branch = branch
+	}
+	if status, ok := git(dir, "status", "--porcelain", "--untracked-files=no"); ok {
+		labels.Dirty = status != ""
+	}
+	if tag, ok := git(dir, "describe", "--tags", "--abbrev=0"); ok {
+		labels.Tag = tag
+	}
+	return labels
+}
+
+// git runs a git command in dir and returns its trimmed output
+func git(dir string, args ...string) (string, bool) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(stdout.String()), true
+}
+
+// Tags returns the labels as dump tags; empty labels are left out
+func (l *Labels) Tags() map[string]string {
+	tags := map[string]string{
+		KeyCommit: l.Commit,
+		KeyDirty:  strconv.FormatBool(l.Dirty),
+	}
+	if l.Branch != "" {
+		tags[KeyBranch] = l.Branch
+	}
+	if l.Tag != "" {
+		tags[KeyTag] = l.Tag
+	}
+	return tags
+}
+
+// Describe renders recorded tags as "branch@commit (tag, dirty)", with the
+// commit shortened; it returns "" when the tags hold no commit
+func Describe(tags map[string]string) string {
+	commit := tags[KeyCommit]
+	if commit == "" {
+		return ""
+	}
+	description := commit[:min(len(commit), 12)]
+	if branch := tags[KeyBranch]; branch != "" {
+		description = branch + "@" + description
+	}
+	var notes []string
+	if tag := tags[KeyTag]; tag != "" {
+		notes = append(notes, tag)
+	}
+	if tags[KeyDirty] == "true" {
+		notes = append(notes, "uncommitted changes")
+	}
+	if len(notes) > 0 {
+		description += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return description
+}
@@ -0,0 +1,278 @@
+package gitlabel
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// repo is a git work tree in a temporary directory
+type repo struct {
+	t   *testing.T
+	dir string
+}
+
+// newRepo creates an empty repository on branch main, with git kept away
+// from the user's configuration
+func newRepo(t *testing.T) *repo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	r := &repo{t: t, dir: t.TempDir()}
+	r.git("init", "-q")
+	r.git("checkout", "-q", "-b", "main")
+	return r
+}
+
+// git runs a git command in the repository and returns its output
+func (r *repo) git(args ...string) string {
+	r.t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", r.dir}, args...)...).CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// write writes a file in the work tree
+func (r *repo) write(name, content string) {
+	r.t.Helper()
+	path := filepath.Join(r.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// commit commits a change to a tracked file and returns the commit
+func (r *repo) commit(content string) string {
+	r.t.Helper()
+	r.write("app.txt", content)
+	r.git("add", "app.txt")
+	r.git("commit", "-q", "-m", content)
+	return r.git("rev-parse", "HEAD")
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(r *repo) (commit string)
+		dir        string // subdirectory to read from
+		wantBranch string
+		wantDirty  bool
+		wantTag    string
+	}{
+		{
+			name:       "clean",
+			setup:      func(r *repo) string { return r.commit("one") },
+			wantBranch: "main",
+		},
+		{
+			name: "tracked change",
+			setup: func(r *repo) string {
+				commit := r.commit("one")
+				r.write("app.txt", "changed")
+				return commit
+			},
+			wantBranch: "main",
+			wantDirty:  true,
+		},
+		{
+			name: "staged change",
+			setup: func(r *repo) string {
+				commit := r.commit("one")
+				r.write("app.txt", "changed")
+				r.git("add", "app.txt")
+				return commit
+			},
+			wantBranch: "main",
+			wantDirty:  true,
+		},
+		{
+			// Dumps themselves are often untracked files in the work tree
+			name: "untracked file",
+			setup: func(r *repo) string {
+				commit := r.commit("one")
+				r.write("shop.sql", "-- dump")
+				return commit
+			},
+			wantBranch: "main",
+		},
+		{
+			name: "nearest tag",
+			setup: func(r *repo) string {
+				r.commit("one")
+				r.git("tag", "v1.0.0")
+				r.commit("two")
+				r.git("tag", "-a", "v1.1.0", "-m", "release")
+				return r.commit("three")
+			},
+			wantBranch: "main",
+			wantTag:    "v1.1.0",
+		},
+		{
+			name: "branch with a slash",
+			setup: func(r *repo) string {
+				r.commit("one")
+				r.git("checkout", "-q", "-b", "feature/export")
+				return r.commit("two")
+			},
+			wantBranch: "feature/export",
+		},
+		{
+			name: "detached head",
+			setup: func(r *repo) string {
+				first := r.commit("one")
+				r.git("tag", "v1")
+				r.commit("two")
+				r.git("checkout", "-q", first)
+				return first
+			},
+			wantTag: "v1",
+		},
+		{
+			name: "subdirectory",
+			setup: func(r *repo) string {
+				r.write("db/dumps/.keep", "")
+				return r.commit("one")
+			},
+			dir:        "db/dumps",
+			wantBranch: "main",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRepo(t)
+			commit := tt.setup(r)
+			got := Read(filepath.Join(r.dir, tt.dir))
+			want := &Labels{Branch: tt.wantBranch, Commit: commit, Dirty: tt.wantDirty, Tag: tt.wantTag}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Read() = %+v, want %+v", got, want)
+			}
+			if len(commit) != 40 {
+				t.Errorf("commit %q is not a full SHA", commit)
+			}
+		})
+	}
+}
+
+// TestReadSilent checks that Read returns nil without a word when it has
+// no labels to give
+func TestReadSilent(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T) string
+	}{
+		{
+			name: "no git",
+			setup: func(t *testing.T) string {
+				t.Setenv("PATH", t.TempDir())
+				return t.TempDir()
+			},
+		},
+		{
+			name: "not a work tree",
+			setup: func(t *testing.T) string {
+				t.Setenv("GIT_CEILING_DIRECTORIES", os.TempDir())
+				return t.TempDir()
+			},
+		},
+		{
+			name: "no commit yet",
+			setup: func(t *testing.T) string {
+				return newRepo(t).dir
+			},
+		},
+		{
+			name: "missing directory",
+			setup: func(t *testing.T) string {
+				return filepath.Join(t.TempDir(), "gone")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := exec.LookPath("git"); err != nil {
+				t.Skip("git is not installed")
+			}
+			dir := tt.setup(t)
+
+			output := captureOutput(t, func() {
+				if labels := Read(dir); labels != nil {
+					t.Errorf("Read() = %+v, want nil", labels)
+				}
+			})
+			if output != "" {
+				t.Errorf("Read() printed %q", output)
+			}
+		})
+	}
+}
+
+// captureOutput returns what fn writes to stdout and stderr
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedOut, savedErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	defer func() {
+		os.Stdout, os.Stderr = savedOut, savedErr
+	}()
+	fn()
+	_ = w.Close()
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output)
+}
+
+func TestDescribe(t *testing.T) {
+	const sha = "3f2a9c1d8e7b6a5f4e3d2c1b0a9f8e7d6c5b4a39"
+	tests := []struct {
+		name   string
+		labels Labels
+		want   string
+	}{
+		{name: "branch", labels: Labels{Branch: "main", Commit: sha}, want: "main@3f2a9c1d8e7b"},
+		{name: "detached", labels: Labels{Commit: sha}, want: "3f2a9c1d8e7b"},
+		{name: "tag", labels: Labels{Branch: "main", Commit: sha, Tag: "v1.2.0"}, want: "main@3f2a9c1d8e7b (v1.2.0)"},
+		{name: "dirty", labels: Labels{Branch: "main", Commit: sha, Dirty: true}, want: "main@3f2a9c1d8e7b (uncommitted changes)"},
+		{name: "all", labels: Labels{Branch: "feature/x", Commit: sha, Dirty: true, Tag: "v2"}, want: "feature/x@3f2a9c1d8e7b (v2, uncommitted changes)"},
+		{name: "short commit", labels: Labels{Commit: "abc123"}, want: "abc123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Describe(tt.labels.Tags()); got != tt.want {
+				t.Errorf("Describe() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := Describe(map[string]string{KeyBranch: "main"}); got != "" {
+		t.Errorf("Describe without a commit = %q, want empty", got)
+	}
+	tags := (&Labels{Commit: sha}).Tags()
+	if want := map[string]string{KeyCommit: sha, KeyDirty: "false"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Tags() = %v, want %v", tags, want)
+	}
+}
@@ -0,0 +1,74 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaDiff lists table-level differences between two schema fingerprints
+type SchemaDiff struct {
+	Added   []string
+	Removed []string
+	Altered []string
+}
+
+// DiffSchemas compares two table→hash maps
+func DiffSchemas(old, new map[string]string) SchemaDiff {
+	var diff SchemaDiff
+
+	for table, hash := range new {
+		oldHash, ok := old[table]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, table)
+		case oldHash != hash:
+			diff.Altered = append(diff.Altered, table)
+		}
+	}
+	for table := range old {
+		if _, ok := new[table]; !ok {
+			diff.Removed = append(diff.Removed, table)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Altered)
+
+	return diff
+}
+
+// Empty reports whether there are no differences
+func (d SchemaDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Altered) == 0
+}
+
+// Summary returns a short description like "2 tables altered, 1 added"
+func (d SchemaDiff) Summary() string {
+	counts := []struct {
+		n    int
+		verb string
+	}{
+		{len(d.Altered), "altered"},
+		{len(d.Added), "added"},
+		{len(d.Removed), "removed"},
+	}
+
+	var parts []string
+	for _, c := range counts {
+		if c.n == 0 {
+			continue
+		}
+		if len(parts) == 0 {
+			noun := "tables"
+			if c.n == 1 {
+				noun = "table"
+			}
+			parts = append(parts, fmt.Sprintf("%d %s %s", c.n, noun, c.verb))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d %s", c.n, c.verb))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
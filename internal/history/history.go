@@ -0,0 +1,155 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+)
+
+// Entry records a single dump run
+type Entry struct {
+	Time           time.Time `json:"time"`
+	Host           string    `json:"host"`
+	Port           int       `json:"port"`
+	Instance       string    `json:"instance,omitempty"` // the server behind a local proxy
+	Database       string    `json:"database"`
+	OutputFile     string    `json:"output_file"`
+	FileSize       int64     `json:"file_size"`
+	DurationMillis int64     `json:"duration_ms"`
+	ExcludedTables []string  `json:"excluded_tables,omitempty"`
+
+	// Schema maps each table to a hash of its CREATE TABLE statement
+	Schema map[string]string `json:"schema,omitempty"`
+
+	// TableSizes maps each table to its data + index size at dump time
+	TableSizes map[string]int64 `json:"table_sizes,omitempty"`
+
+	// Tags are the key=value labels given with --tag
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// RulesVersion is the version of the built-in exclusion rules the dump
+	// applied; 0 for dumps before rules were versioned
+	RulesVersion int `json:"rules_version,omitempty"`
+}
+
+// GetHistoryPath returns the path to the history file
+func GetHistoryPath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, "history.jsonl"), nil
+}
+
+// Load reads all history entries, oldest first
+// Returns an empty slice if no history has been recorded yet
+func Load() ([]Entry, error) {
+	path, err := GetHistoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			// Skip corrupt lines rather than losing the whole history
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries, nil
+}
+
+// Append adds an entry to the history file
+func Append(entry Entry) error {
+	path, err := GetHistoryPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+
+	// Lines from concurrent dbdump processes must not interleave
+	if err := fileutil.AppendFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	return nil
+}
+
+// LastRulesVersion returns the built-in rules version of the latest of
+// entries that recorded one, or 0
+func LastRulesVersion(entries []Entry) int {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].RulesVersion != 0 {
+			return entries[i].RulesVersion
+		}
+	}
+	return 0
+}
+
+// ForDatabase returns the entries for one database on one server, oldest first
+func ForDatabase(entries []Entry, host string, port int, dbName string) []Entry {
+	var matched []Entry
+	for _, entry := range entries {
+		if entry.Database == dbName && database.SameServer(entry.Host, entry.Port, host, port) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// ForConnection returns the entries for the database of conn, oldest first.
+// Behind a proxy with a known instance they are matched by instance, as one
+// local address can reach several servers.
+func ForConnection(entries []Entry, conn *database.Connection) []Entry {
+	if conn.Instance == "" {
+		return ForDatabase(entries, conn.Host, conn.Port, conn.Database)
+	}
+	var matched []Entry
+	for _, entry := range entries {
+		if entry.Database == conn.Database && entry.Instance == conn.Instance {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,58 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+// TestForConnection matches entries by instance behind a proxy that names
+// one, as the local port of a proxy can lead to several servers over time
+func TestForConnection(t *testing.T) {
+	entries := []Entry{
+		{OutputFile: "local", Host: "127.0.0.1", Port: 3307, Database: "shop"},
+		{OutputFile: "main", Host: "127.0.0.1", Port: 3307, Instance: "acme:eu:main", Database: "shop"},
+		{OutputFile: "staging", Host: "127.0.0.1", Port: 3307, Instance: "acme:eu:staging", Database: "shop"},
+		{OutputFile: "main-other-port", Host: "localhost", Port: 3308, Instance: "acme:eu:main", Database: "shop"},
+		{OutputFile: "main-blog", Host: "127.0.0.1", Port: 3307, Instance: "acme:eu:main", Database: "blog"},
+	}
+
+	tests := []struct {
+		name string
+		conn database.Connection
+		want []string
+	}{
+		{
+			name: "by address",
+			conn: database.Connection{Host: "localhost", Port: 3307, Database: "shop"},
+			want: []string{"local", "main", "staging"},
+		},
+		{
+			name: "by instance",
+			conn: database.Connection{Host: "127.0.0.1", Port: 3307, Proxy: database.ProxyCloudSQL, Instance: "acme:eu:main", Database: "shop"},
+			want: []string{"main", "main-other-port"},
+		},
+		{
+			name: "proxy without instance",
+			conn: database.Connection{Host: "127.0.0.1", Port: 3308, Proxy: database.ProxyCloudSQL, Database: "shop"},
+			want: []string{"main-other-port"},
+		},
+		{
+			name: "unknown instance",
+			conn: database.Connection{Host: "127.0.0.1", Port: 3307, Proxy: database.ProxyGeneric, Instance: "other", Database: "shop"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range ForConnection(entries, &tt.conn) {
+				got = append(got, entry.OutputFile)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ForConnection() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package history
+
+import (
+	"github.com/helgesverre/dbdump/internal/metadata"
+)
+
+// TableSizes returns the per-table sizes (data + index) recorded for a run,
+// falling back to the run's metadata sidecar for entries recorded before
+// sizes were kept in the history. Returns nil if neither has them.
+func TableSizes(entry Entry) map[string]int64 {
+	if entry.TableSizes != nil {
+		return entry.TableSizes
+	}
+
+	meta, err := metadata.LoadForDump(entry.OutputFile)
+	if err != nil || meta == nil || len(meta.Tables) == 0 {
+		return nil
+	}
+	sizes := make(map[string]int64, len(meta.Tables))
+	for _, table := range meta.Tables {
+		sizes[table.Name] = table.DataSize + table.IndexSize
+	}
+	return sizes
+}
+
+// SizeHistory returns the table sizes of up to limit of the most recent runs
+// that have them, oldest first
+func SizeHistory(entries []Entry, limit int) []map[string]int64 {
+	var runs []map[string]int64
+	for i := len(entries) - 1; i >= 0 && len(runs) < limit; i-- {
+		if sizes := TableSizes(entries[i]); sizes != nil {
+			runs = append(runs, sizes)
+		}
+	}
+
+	// Collected newest first
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs
+}
+
+// TableTrend is one table's size across recorded runs and now
+type TableTrend struct {
+	Sizes []int64 // oldest first, ending with the current size; runs without the table are left out
+	New   bool    // the table didn't exist in the oldest recorded run
+}
+
+// Change returns the relative size change since the oldest recorded size
+// (0.25 for +25%), and false when there is no baseline to compare against
+func (t TableTrend) Change() (float64, bool) {
+	if t.New || len(t.Sizes) < 2 || t.Sizes[0] == 0 {
+		return 0, false
+	}
+	first, last := t.Sizes[0], t.Sizes[len(t.Sizes)-1]
+	return float64(last-first) / float64(first), true
+}
+
+// Trend builds a table's trend from the recorded runs and its current size
+func Trend(runs []map[string]int64, table string, current int64) TableTrend {
+	var trend TableTrend
+	for i, sizes := range runs {
+		size, ok := sizes[table]
+		if !ok {
+			if i == 0 {
+				trend.New = true
+			}
+			continue
+		}
+		trend.Sizes = append(trend.Sizes, size)
+	}
+	trend.Sizes = append(trend.Sizes, current)
+	return trend
+}
@@ -0,0 +1,91 @@
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/metadata"
+)
+
+func TestTrend(t *testing.T) {
+	runs := []map[string]int64{
+		{"users": 100, "orders": 0, "logs": 50},
+		{"users": 110, "orders": 10, "logs": 60},
+		{"users": 120, "orders": 20, "sessions": 5},
+	}
+	tests := []struct {
+		name       string
+		runs       []map[string]int64
+		table      string
+		current    int64
+		want       TableTrend
+		change     float64
+		haveChange bool
+	}{
+		{name: "growing", runs: runs, table: "users", current: 150, want: TableTrend{Sizes: []int64{100, 110, 120, 150}}, change: 0.5, haveChange: true},
+		{name: "shrinking", runs: runs, table: "users", current: 25, want: TableTrend{Sizes: []int64{100, 110, 120, 25}}, change: -0.75, haveChange: true},
+		{name: "unchanged", runs: runs, table: "users", current: 100, want: TableTrend{Sizes: []int64{100, 110, 120, 100}}, change: 0, haveChange: true},
+		{name: "from zero", runs: runs, table: "orders", current: 30, want: TableTrend{Sizes: []int64{0, 10, 20, 30}}},
+		{name: "new since the oldest run", runs: runs, table: "sessions", current: 8, want: TableTrend{Sizes: []int64{5, 8}, New: true}},
+		{name: "new now", runs: runs, table: "jobs", current: 1, want: TableTrend{Sizes: []int64{1}, New: true}},
+		{name: "missing from a later run", runs: runs, table: "logs", current: 70, want: TableTrend{Sizes: []int64{50, 60, 70}}, change: 0.4, haveChange: true},
+		{name: "no history", runs: nil, table: "users", current: 100, want: TableTrend{Sizes: []int64{100}}},
+		{name: "single run", runs: runs[:1], table: "users", current: 200, want: TableTrend{Sizes: []int64{100, 200}}, change: 1, haveChange: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Trend(tt.runs, tt.table, tt.current)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Trend = %+v, want %+v", got, tt.want)
+			}
+			change, ok := got.Change()
+			if ok != tt.haveChange || change != tt.change {
+				t.Errorf("Change() = %v, %v; want %v, %v", change, ok, tt.change, tt.haveChange)
+			}
+		})
+	}
+}
+
+func TestSizeHistory(t *testing.T) {
+	dir := t.TempDir()
+
+	// An entry recorded before the history kept sizes, with a sidecar
+	old := filepath.Join(dir, "shop_old.sql")
+	meta := &metadata.Metadata{Tables: []metadata.Table{{Name: "users", DataSize: 60, IndexSize: 40}}}
+	if err := metadata.Write(metadata.SidecarPath(old), meta); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []Entry{
+		{OutputFile: old},
+		{OutputFile: filepath.Join(dir, "no_sidecar.sql")},
+		{TableSizes: map[string]int64{"users": 110}},
+		{TableSizes: map[string]int64{"users": 120}},
+		{TableSizes: map[string]int64{"users": 130}},
+	}
+	tests := []struct {
+		limit int
+		want  []int64
+	}{
+		{limit: 10, want: []int64{100, 110, 120, 130}},
+		{limit: 4, want: []int64{100, 110, 120, 130}},
+		{limit: 2, want: []int64{120, 130}},
+		{limit: 1, want: []int64{130}},
+		{limit: 0, want: nil},
+	}
+	for _, tt := range tests {
+		runs := SizeHistory(entries, tt.limit)
+		var got []int64
+		for _, sizes := range runs {
+			got = append(got, sizes["users"])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SizeHistory(limit %d) = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+
+	if runs := SizeHistory(entries[1:2], 10); runs != nil {
+		t.Errorf("SizeHistory without sizes = %v, want nil", runs)
+	}
+}
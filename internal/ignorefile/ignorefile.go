@@ -0,0 +1,113 @@
+// Package ignorefile keeps dump files out of version control by checking and
+// updating .gitignore (and .dockerignore) in the enclosing git repository.
+package ignorefile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Header is written above patterns added by dbdump
+const Header = "# Database dumps (added by dbdump; they are large and contain data)"
+
+// FindRepoRoot walks up from dir looking for a .git entry and returns the
+// work tree root, or "" if dir is not inside a git work tree
+func FindRepoRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// IsIgnored reports whether git ignores path in the repository at root
+// Returns an error if git itself is unavailable or fails
+func IsIgnored(root, path string) (bool, error) {
+	cmd := exec.Command("git", "-C", root, "check-ignore", "-q", "--no-index", path)
+	err := cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+
+	// Exit code 1 means "not ignored"; anything else is a real failure
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return false, fmt.Errorf("git check-ignore failed: %w", err)
+}
+
+// HasLine reports whether an ignore file already contains pattern as a line
+func HasLine(path, pattern string) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == pattern {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// AppendPattern appends pattern under the dbdump header, creating the file if
+// needed. Nothing is written if the pattern is already present.
+func AppendPattern(path, pattern string) (bool, error) {
+	present, err := HasLine(path, pattern)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if present {
+		return false, nil
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var sb strings.Builder
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		sb.WriteString("\n")
+	}
+	if !strings.Contains(string(existing), Header) {
+		if len(existing) > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(Header + "\n")
+	}
+	sb.WriteString(pattern + "\n")
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if _, err := file.WriteString(sb.String()); err != nil {
+		_ = file.Close()
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return false, fmt.Errorf("failed to close %s: %w", path, err)
+	}
+
+	return true, nil
+}
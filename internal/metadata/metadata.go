@@ -0,0 +1,328 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/fileutil"
+)
+
+// FormatVersion is the version of the sidecar file format
+const FormatVersion = 1
+
+// SidecarSuffix is appended to the dump file name to form the sidecar path
+const SidecarSuffix = ".meta.json"
+
+// Source describes the database a dump was taken from (never includes secrets)
+type Source struct {
+	Host          string `json:"host"`
+	Port          int    `json:"port"`
+	Database      string `json:"database"`
+	ServerVersion string `json:"server_version,omitempty"`
+
+	// Proxy is the kind of local proxy Host and Port belong to, and
+	// Instance the server behind it
+	Proxy    string `json:"proxy,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// Table describes a single table at the time of the dump
+type Table struct {
+	Name         string `json:"name"`
+	RowCount     int64  `json:"row_count"`
+	DataSize     int64  `json:"data_size"`
+	IndexSize    int64  `json:"index_size"`
+	DataIncluded bool   `json:"data_included"`
+	Skipped      bool   `json:"skipped,omitempty"`
+
+	// SampleRows is the number of rows sampled of a data-excluded table
+	SampleRows int `json:"sample_rows,omitempty"`
+
+	// MaskedColumns are the columns whose values were replaced by masks
+	MaskedColumns []string `json:"masked_columns,omitempty"`
+
+	// NullColumns maps the columns of null_columns to the placeholder
+	// dumped instead of their values
+	NullColumns map[string]string `json:"null_columns,omitempty"`
+
+	// CopyOf is the database whose identical table the data is copied
+	// from on restore (--dedupe-identical)
+	CopyOf string `json:"copy_of,omitempty"`
+
+	// Approximate wall time and output size of the table's data
+	DumpMillis int64 `json:"dump_ms,omitempty"`
+	DumpBytes  int64 `json:"dump_bytes,omitempty"`
+}
+
+// TableChecksum holds exact row count and checksum for a table, used to
+// verify a restored copy of the dump. Changed is set when the table was
+// written to while it was dumped, so its restored copy can't be compared.
+type TableChecksum struct {
+	Name     string `json:"name"`
+	RowCount int64  `json:"row_count"`
+	Checksum int64  `json:"checksum"`
+	Changed  bool   `json:"changed,omitempty"`
+}
+
+// PhaseMillis holds the duration of each dump phase
+type PhaseMillis struct {
+	Structure int64 `json:"structure"`
+	Data      int64 `json:"data"`
+}
+
+// Part describes one file of a dump split with --max-file-size
+type Part struct {
+	File   string `json:"file"` // base name, in the same directory as the sidecar
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Metadata is the content of the sidecar file written next to each dump
+type Metadata struct {
+	FormatVersion  int             `json:"format_version"`
+	ToolVersion    string          `json:"tool_version"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Source         Source          `json:"source"`
+	OutputFile     string          `json:"output_file"`
+	FileSize       int64           `json:"file_size"`
+	Compression    string          `json:"compression,omitempty"`
+	SQLSize        int64           `json:"sql_size,omitempty"`
+	EstimatedSize  int64           `json:"estimated_size,omitempty"`
+	DurationMillis int64           `json:"duration_ms"`
+	PhaseMillis    *PhaseMillis    `json:"phase_ms,omitempty"`
+	Tables         []Table         `json:"tables"`
+	ExcludedTables []string        `json:"excluded_tables"`
+	SkippedTables  []string        `json:"skipped_tables,omitempty"`
+	Checksums      []TableChecksum `json:"checksums,omitempty"`
+	Parts          []Part          `json:"parts,omitempty"`
+
+	// Schema maps each table to a hash of its CREATE TABLE statement
+	Schema map[string]string `json:"schema,omitempty"`
+
+	// Tags are the key=value labels given with --tag
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// TruncatedTables lists tables whose data was cut off at --max-table-size
+	TruncatedTables []TruncatedTable `json:"truncated_tables,omitempty"`
+
+	// OverBudgetTables lists tables left without data by --time-budget
+	OverBudgetTables []string `json:"over_budget_tables,omitempty"`
+
+	// LargestStatement is the size of the longest data statement, which the
+	// target's max_allowed_packet must allow; MaxAllowedPacket is the
+	// source server's
+	LargestStatement int64 `json:"largest_statement,omitempty"`
+	MaxAllowedPacket int64 `json:"max_allowed_packet,omitempty"`
+
+	// ReplicaGTID is the GTID set the source replica was stopped right after
+	// for the dump (--stop-replica-at-gtid)
+	ReplicaGTID string `json:"replica_gtid,omitempty"`
+
+	// TableSnapshots are the tables the dump was planned for and those found
+	// right before it ran
+	TableSnapshots *TableSnapshots `json:"table_snapshots,omitempty"`
+
+	// StdinConfig is the project config read with --config -, kept so the
+	// dump can be reproduced
+	StdinConfig string `json:"stdin_config,omitempty"`
+
+	// StatementSampling is set when --sample-statements copied statements
+	// into a debug file
+	StatementSampling *StatementSampling `json:"statement_sampling,omitempty"`
+
+	// TimeZones records the zones TIMESTAMP values were dumped under
+	TimeZones *TimeZones `json:"time_zones,omitempty"`
+
+	// ServerVariables are the source's values of the variables restore
+	// compares with the target (see package variables)
+	ServerVariables map[string]string `json:"server_variables,omitempty"`
+
+	// RulesVersion is the version of the built-in exclusion rules applied
+	RulesVersion int `json:"rules_version,omitempty"`
+
+	// Performance records the buffer and compression settings of the dump
+	Performance *Performance `json:"performance,omitempty"`
+
+	// Chain is set on dumps of append_tables, which later increments
+	// (--append-since-last) continue from
+	Chain *Chain `json:"chain,omitempty"`
+
+	// TriggerEffects are the trigger chains through which writes to tables
+	// with data reach tables without
+	TriggerEffects []TriggerEffect `json:"trigger_effects,omitempty"`
+
+	// Migrations is the migration state of the source, recorded with the
+	// git labels (--label-from-git)
+	Migrations *Migrations `json:"migrations,omitempty"`
+}
+
+// Migrations is the row count of the table a migration tool records the
+// applied migrations in
+type Migrations struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+// Chain links a dump of append-only tables to the one before it. A base
+// dump has no Previous; each increment names the dump it continues and
+// holds the rows after that dump's watermarks.
+type Chain struct {
+	Base           string      `json:"base"`               // file name of the base dump
+	Previous       string      `json:"previous,omitempty"` // file name, in the same directory
+	PreviousSHA256 string      `json:"previous_sha256,omitempty"`
+	Sequence       int         `json:"sequence"` // 0 for the base
+	Watermarks     []Watermark `json:"watermarks"`
+}
+
+// Watermark is the range of an append-only table's watermark column a dump
+// holds: From (exclusive, empty for a base dump) up to Through
+type Watermark struct {
+	Table   string `json:"table"`
+	Column  string `json:"column"`
+	From    string `json:"from,omitempty"`
+	Through string `json:"through"`
+
+	// Rows is the number of rows up to Through, counted when it was read
+	Rows int64 `json:"rows"`
+
+	// Schema is the table's schema fingerprint; a change breaks the chain
+	Schema string `json:"schema"`
+}
+
+// Watermark returns the watermark recorded for a table
+func (c *Chain) Watermark(table string) (*Watermark, bool) {
+	for i := range c.Watermarks {
+		if c.Watermarks[i].Table == table {
+			return &c.Watermarks[i], true
+		}
+	}
+	return nil, false
+}
+
+// Performance is the performance settings a dump ran with; a compression
+// level of -1 is gzip's default
+type Performance struct {
+	WriterBuffer       int64 `json:"writer_buffer"`
+	CompressionLevel   int   `json:"compression_level"`
+	CompressionWorkers int   `json:"compression_workers"`
+	DumpParallelism    int   `json:"dump_parallelism"`
+	NetBuffer          int64 `json:"net_buffer"`
+
+	// AutoTuned holds what --auto-tune based the settings on
+	AutoTuned *TuningConditions `json:"auto_tuned,omitempty"`
+}
+
+// TuningConditions are the conditions --auto-tune detected
+type TuningConditions struct {
+	Local           bool   `json:"local"`
+	CPUs            int    `json:"cpus"`
+	Disk            string `json:"disk,omitempty"` // ssd or rotational, if known
+	AvailableMemory int64  `json:"available_memory,omitempty"`
+	Jobs            int    `json:"jobs"`
+}
+
+// TimeZones describes the source server's and the client's time zones at
+// dump time, and whether TIMESTAMP values were written in UTC (--tz-utc)
+// or in the server's zone
+type TimeZones struct {
+	Server       string `json:"server"`
+	ServerOffset string `json:"server_offset"`
+	Client       string `json:"client"`
+	ClientOffset string `json:"client_offset"`
+	TZUTC        bool   `json:"tz_utc"`
+
+	// OffsetChanged is set when the server's UTC offset changed while the
+	// dump ran, as at a daylight-saving transition
+	OffsetChanged bool `json:"offset_changed,omitempty"`
+}
+
+// StatementSampling describes the statement samples taken during a dump
+type StatementSampling struct {
+	PerTable    int    `json:"per_table"`
+	ValueLength int    `json:"value_length"`
+	File        string `json:"file"`
+}
+
+// TableSnapshots records the table set at planning and at execution; they
+// differ when a migration added or dropped tables in between
+type TableSnapshots struct {
+	Planned    []string  `json:"planned"`
+	PlannedAt  time.Time `json:"planned_at"`
+	Executed   []string  `json:"executed"`
+	ExecutedAt time.Time `json:"executed_at"`
+}
+
+// TruncatedTable records how much of a truncated table's data a dump holds
+type TruncatedTable struct {
+	Table string `json:"table"`
+	Bytes int64  `json:"bytes"`
+	Rows  int64  `json:"rows"`
+}
+
+// TriggerEffect is a table whose writes reach Target, a table without data
+// (or not in the dump, if Skipped), through the triggers in Chain
+type TriggerEffect struct {
+	Table   string   `json:"table"`
+	Target  string   `json:"target"`
+	Chain   []string `json:"chain"`
+	Skipped bool     `json:"skipped,omitempty"`
+}
+
+// SidecarPath returns the sidecar path for a dump file
+func SidecarPath(dumpFile string) string {
+	return dumpFile + SidecarSuffix
+}
+
+// Write writes the metadata to the given path with restrictive permissions
+func Write(path string, meta *Metadata) error {
+	meta.FormatVersion = FormatVersion
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	// Synced and renamed into place, so a sidecar is never seen half-written
+	if err := fileutil.WriteFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads a metadata sidecar file
+func Load(path string) (*Metadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	var meta Metadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata %s: %w", path, err)
+	}
+
+	return &meta, nil
+}
+
+// LoadForDump loads the sidecar belonging to a dump file
+// Returns nil if no sidecar exists (which is not an error)
+func LoadForDump(dumpFile string) (*Metadata, error) {
+	path := SidecarPath(dumpFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return Load(path)
+}
+
+// Table returns the metadata for a table by name
+func (m *Metadata) Table(name string) (*Table, bool) {
+	for i := range m.Tables {
+		if m.Tables[i].Name == name {
+			return &m.Tables[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,103 @@
+package patterns
+
+import (
+	"sort"
+	"strings"
+)
+
+// Group is a set of tables sharing a name prefix. The prefix includes the
+// trailing delimiter (e.g. "wp_2_"); the group of tables without a shared
+// prefix has an empty prefix.
+type Group struct {
+	Prefix string
+	Tables []string
+}
+
+// GroupByPrefix clusters tables by the longest delimiter-separated prefix they
+// share with at least one other table, so "wp_1_posts" and "wp_1_options" form
+// "wp_1_" while "wp_users" and "wp_options" form "wp_". Groups are sorted by
+// prefix and keep the input order of their tables; tables that share no prefix
+// end up in a final group with an empty prefix.
+func GroupByPrefix(tables []string, delimiter string) []Group {
+	if delimiter == "" {
+		return []Group{{Tables: tables}}
+	}
+
+	// Count how many tables start with each candidate prefix
+	counts := make(map[string]int)
+	for _, table := range tables {
+		for _, prefix := range prefixes(table, delimiter) {
+			counts[prefix]++
+		}
+	}
+
+	// Assign each table to its longest shared prefix
+	members := make(map[string][]string)
+	for _, table := range tables {
+		best := ""
+		for _, prefix := range prefixes(table, delimiter) {
+			if counts[prefix] >= 2 {
+				best = prefix
+			}
+		}
+		members[best] = append(members[best], table)
+	}
+
+	// A prefix whose other tables all moved to deeper groups is no group at all
+	for prefix, group := range members {
+		if prefix != "" && len(group) < 2 {
+			members[""] = append(members[""], group...)
+			delete(members, prefix)
+		}
+	}
+
+	keys := make([]string, 0, len(members))
+	for prefix := range members {
+		if prefix != "" {
+			keys = append(keys, prefix)
+		}
+	}
+	sort.Strings(keys)
+
+	groups := make([]Group, 0, len(members))
+	for _, prefix := range keys {
+		groups = append(groups, Group{Prefix: prefix, Tables: members[prefix]})
+	}
+	if ungrouped, ok := members[""]; ok {
+		groups = append(groups, Group{Tables: inOrder(ungrouped, tables)})
+	}
+	return groups
+}
+
+// prefixes returns the delimiter-terminated prefixes of a name, shortest first,
+// excluding the name itself
+func prefixes(name, delimiter string) []string {
+	var result []string
+	for i := 0; ; {
+		j := strings.Index(name[i:], delimiter)
+		if j < 0 {
+			break
+		}
+		end := i + j + len(delimiter)
+		if end >= len(name) {
+			break
+		}
+		if end > len(delimiter) {
+			result = append(result, name[:end])
+		}
+		i = end
+	}
+	return result
+}
+
+// inOrder returns subset sorted by position in order
+func inOrder(subset, order []string) []string {
+	position := make(map[string]int, len(order))
+	for i, name := range order {
+		position[name] = i
+	}
+	sort.SliceStable(subset, func(a, b int) bool {
+		return position[subset[a]] < position[subset[b]]
+	})
+	return subset
+}
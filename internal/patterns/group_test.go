@@ -0,0 +1,154 @@
+package patterns
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// multisiteTables are the tables of a WordPress multisite database with
+// the network tables and three sites
+var multisiteTables = []string{
+	"wp_blogs", "wp_site", "wp_sitemeta", "wp_users", "wp_usermeta",
+	"wp_2_posts", "wp_2_postmeta", "wp_2_options", "wp_2_comments",
+	"wp_3_posts", "wp_3_postmeta", "wp_3_options",
+	"wp_10_posts", "wp_10_options",
+}
+
+func TestGroupByPrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		tables    []string
+		delimiter string
+		want      []Group
+	}{
+		{name: "no tables", delimiter: "_", want: []Group{}},
+		{
+			name:      "multisite",
+			tables:    multisiteTables,
+			delimiter: "_",
+			want: []Group{
+				{Prefix: "wp_", Tables: []string{"wp_blogs", "wp_site", "wp_sitemeta", "wp_users", "wp_usermeta"}},
+				{Prefix: "wp_10_", Tables: []string{"wp_10_posts", "wp_10_options"}},
+				{Prefix: "wp_2_", Tables: []string{"wp_2_posts", "wp_2_postmeta", "wp_2_options", "wp_2_comments"}},
+				{Prefix: "wp_3_", Tables: []string{"wp_3_posts", "wp_3_postmeta", "wp_3_options"}},
+			},
+		},
+		{
+			name:      "no shared prefix",
+			tables:    []string{"users", "orders", "sessions"},
+			delimiter: "_",
+			want:      []Group{{Tables: []string{"users", "orders", "sessions"}}},
+		},
+		{
+			name:      "ungrouped tables keep their order",
+			tables:    []string{"users", "app_jobs", "orders", "app_queues", "audit_log"},
+			delimiter: "_",
+			want: []Group{
+				{Prefix: "app_", Tables: []string{"app_jobs", "app_queues"}},
+				{Tables: []string{"users", "orders", "audit_log"}},
+			},
+		},
+		{
+			// wp_users would be alone in wp_ once the sites have their groups
+			name:      "prefix left with one table",
+			tables:    []string{"wp_2_posts", "wp_users", "wp_2_options"},
+			delimiter: "_",
+			want: []Group{
+				{Prefix: "wp_2_", Tables: []string{"wp_2_posts", "wp_2_options"}},
+				{Tables: []string{"wp_users"}},
+			},
+		},
+		{
+			// A table named like a prefix belongs to the parent group
+			name:      "table named as a prefix",
+			tables:    []string{"shop_orders", "shop_orders_archive", "shop_orders_items"},
+			delimiter: "_",
+			want: []Group{
+				{Prefix: "shop_orders_", Tables: []string{"shop_orders_archive", "shop_orders_items"}},
+				{Tables: []string{"shop_orders"}},
+			},
+		},
+		{
+			name:      "leading and doubled delimiters",
+			tables:    []string{"_tmp_a", "_tmp_b", "x__a", "x__b"},
+			delimiter: "_",
+			want: []Group{
+				{Prefix: "_tmp_", Tables: []string{"_tmp_a", "_tmp_b"}},
+				{Prefix: "x__", Tables: []string{"x__a", "x__b"}},
+			},
+		},
+		{
+			name:      "trailing delimiter",
+			tables:    []string{"cache_", "cache_", "cache_pages"},
+			delimiter: "_",
+			want:      []Group{{Tables: []string{"cache_", "cache_", "cache_pages"}}},
+		},
+		{
+			name:      "other delimiter",
+			tables:    []string{"tenant-1.users", "tenant-1.orders", "tenant-2.users", "tenant_3_users"},
+			delimiter: ".",
+			want: []Group{
+				{Prefix: "tenant-1.", Tables: []string{"tenant-1.users", "tenant-1.orders"}},
+				{Tables: []string{"tenant-2.users", "tenant_3_users"}},
+			},
+		},
+		{
+			name:      "longer delimiter",
+			tables:    []string{"crm__contacts", "crm__deals", "crm_legacy"},
+			delimiter: "__",
+			want: []Group{
+				{Prefix: "crm__", Tables: []string{"crm__contacts", "crm__deals"}},
+				{Tables: []string{"crm_legacy"}},
+			},
+		},
+		{
+			name:      "no delimiter",
+			tables:    []string{"wp_2_posts", "wp_2_options"},
+			delimiter: "",
+			want:      []Group{{Tables: []string{"wp_2_posts", "wp_2_options"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GroupByPrefix(tt.tables, tt.delimiter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupByPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGroupByPrefixSites checks a network of many sites gets one group
+// per site, sorted by prefix, and that every table lands in one group
+func TestGroupByPrefixSites(t *testing.T) {
+	tables := []string{"wp_users", "wp_usermeta"}
+	for site := 1; site <= 80; site++ {
+		for _, table := range []string{"posts", "postmeta", "options"} {
+			tables = append(tables, fmt.Sprintf("wp_%d_%s", site, table))
+		}
+	}
+
+	groups := GroupByPrefix(tables, "_")
+	if len(groups) != 81 {
+		t.Fatalf("GroupByPrefix() made %d groups, want 81", len(groups))
+	}
+	seen := make(map[string]bool)
+	for i, group := range groups {
+		if i > 0 && group.Prefix <= groups[i-1].Prefix {
+			t.Errorf("group %q follows %q", group.Prefix, groups[i-1].Prefix)
+		}
+		for _, table := range group.Tables {
+			if seen[table] {
+				t.Errorf("%s is in two groups", table)
+			}
+			seen[table] = true
+		}
+	}
+	if len(seen) != len(tables) {
+		t.Errorf("the groups hold %d tables, want %d", len(seen), len(tables))
+	}
+	if first := groups[0]; first.Prefix != "wp_" || len(first.Tables) != 2 {
+		t.Errorf("first group = %v, want the network tables under wp_", first)
+	}
+}
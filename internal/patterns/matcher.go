@@ -1,18 +1,47 @@
 package patterns
 
 import (
+	"fmt"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/sqlident"
 )
 
-// Matcher handles table name pattern matching
+// Matcher handles table name pattern matching. A pattern takes one of
+// three forms:
+//
+//   - "re:expr" or "/expr/": a regular expression, found anywhere in the
+//     name unless anchored with ^ and $
+//   - with *, ? or [: a glob matched against the whole name ("*_cache")
+//   - anything else: a substring of the name ("_cache" matches "page_cache")
 type Matcher struct {
 	exactMatches map[string]bool
 	patterns     []string
+	regexps      map[string]*regexp.Regexp
+
+	// includes, if set, inverts the selection: tables it doesn't match are
+	// excluded as well
+	includes *Matcher
+
+	// fold compares names case-insensitively, as servers with
+	// lower_case_table_names 1 or 2 do
+	fold bool
+
+	// exactConfidence and patternConfidence hold the rules' confidence
+	// where it isn't high, keyed like exactMatches and by pattern
+	exactConfidence   map[string]config.Confidence
+	patternConfidence map[string]config.Confidence
 }
 
+// RuleNotIncluded is the rule MatchingRule returns for tables excluded
+// only because no include rule matches them
+const RuleNotIncluded = "not included"
+
 // NewMatcher creates a new Matcher from exclude config
 func NewMatcher(excludes config.ExcludeConfig) *Matcher {
 	exactMap := make(map[string]bool)
@@ -20,40 +49,242 @@ func NewMatcher(excludes config.ExcludeConfig) *Matcher {
 		exactMap[exact] = true
 	}
 
+	// Invalid expressions never match; Validate reports them
+	regexps := make(map[string]*regexp.Regexp)
+	for _, pattern := range excludes.Patterns {
+		if expr, ok := regexPattern(pattern); ok {
+			if re, err := regexp.Compile(expr); err == nil {
+				regexps[pattern] = re
+			}
+		}
+	}
+
 	return &Matcher{
 		exactMatches: exactMap,
 		patterns:     excludes.Patterns,
+		regexps:      regexps,
 	}
 }
 
+// WithIncludes returns a matcher that also excludes every table the
+// include rules don't match; with no include rules it returns m. Exclude
+// rules win: a table matched by both is excluded.
+func (m *Matcher) WithIncludes(includes config.ExcludeConfig) *Matcher {
+	if includes.IsEmpty() {
+		return m
+	}
+	inverted := *m
+	inverted.includes = NewMatcher(includes)
+	return &inverted
+}
+
+// WithConfidence returns a matcher whose exclude rules have the given
+// confidence, keyed by exact name or pattern; other rules are high
+func (m *Matcher) WithConfidence(levels map[string]config.Confidence) *Matcher {
+	if len(levels) == 0 {
+		return m
+	}
+	weighted := *m
+	weighted.exactConfidence = make(map[string]config.Confidence)
+	weighted.patternConfidence = make(map[string]config.Confidence)
+	for rule, level := range levels {
+		if m.exactMatches[m.key(rule)] {
+			weighted.exactConfidence[m.key(rule)] = level
+		}
+		if slices.Contains(m.patterns, rule) {
+			weighted.patternConfidence[rule] = level
+		}
+	}
+	return &weighted
+}
+
+// FoldCase returns a matcher comparing names and rules case-insensitively,
+// for servers that do (see sqlident.Case)
+func (m *Matcher) FoldCase() *Matcher {
+	if m.fold {
+		return m
+	}
+	folded := *m
+	folded.fold = true
+	folded.exactMatches = make(map[string]bool, len(m.exactMatches))
+	for exact := range m.exactMatches {
+		folded.exactMatches[sqlident.Fold(exact)] = true
+	}
+	if m.exactConfidence != nil {
+		folded.exactConfidence = make(map[string]config.Confidence, len(m.exactConfidence))
+		for exact, level := range m.exactConfidence {
+			folded.exactConfidence[sqlident.Fold(exact)] = level
+		}
+	}
+	folded.regexps = make(map[string]*regexp.Regexp, len(m.regexps))
+	for pattern := range m.regexps {
+		expr, _ := regexPattern(pattern)
+		if re, err := regexp.Compile("(?i)" + expr); err == nil {
+			folded.regexps[pattern] = re
+		}
+	}
+	if m.includes != nil {
+		folded.includes = m.includes.FoldCase()
+	}
+	return &folded
+}
+
+// key returns the form of a name or rule that is compared
+func (m *Matcher) key(name string) string {
+	if m.fold {
+		return sqlident.Fold(name)
+	}
+	return name
+}
+
+// Inverted reports whether the matcher has include rules
+func (m *Matcher) Inverted() bool {
+	return m.includes != nil
+}
+
 // Matches checks if a table name should be excluded
 func (m *Matcher) Matches(tableName string) bool {
 	// Check exact matches first (faster)
-	if m.exactMatches[tableName] {
+	if m.exactMatches[m.key(tableName)] {
 		return true
 	}
 
 	// Check pattern matches
 	for _, pattern := range m.patterns {
-		if matchPattern(pattern, tableName) {
+		if m.matchPattern(pattern, tableName) {
 			return true
 		}
 	}
 
-	return false
+	return m.includes != nil && !m.includes.Matches(tableName)
+}
+
+// MatchingRule returns the rule that matches a table name ("exact", the
+// matching pattern or RuleNotIncluded), or "" if none does
+func (m *Matcher) MatchingRule(tableName string) string {
+	if m.exactMatches[m.key(tableName)] {
+		return "exact"
+	}
+	for _, pattern := range m.patterns {
+		if m.matchPattern(pattern, tableName) {
+			return pattern
+		}
+	}
+	if m.includes != nil && !m.includes.Matches(tableName) {
+		return RuleNotIncluded
+	}
+	return ""
+}
+
+// Confidence returns the strongest confidence among the exclude rules
+// matching a table name, with the rule that has it ("exact" or the
+// pattern), or "" and "" when none does. Tables excluded only because no
+// include rule matches them are high: the include rules were written for
+// exactly that.
+func (m *Matcher) Confidence(tableName string) (config.Confidence, string) {
+	var best config.Confidence
+	rule := ""
+	consider := func(level config.Confidence, matched string) {
+		if level == "" {
+			level = config.ConfidenceHigh
+		}
+		if rule == "" || level.AtLeast(best) && level != best {
+			best, rule = level, matched
+		}
+	}
+	if m.exactMatches[m.key(tableName)] {
+		consider(m.exactConfidence[m.key(tableName)], "exact")
+	}
+	for _, pattern := range m.patterns {
+		if m.matchPattern(pattern, tableName) {
+			consider(m.patternConfidence[pattern], pattern)
+		}
+	}
+	if rule == "" && m.includes != nil && !m.includes.Matches(tableName) {
+		return config.ConfidenceHigh, RuleNotIncluded
+	}
+	return best, rule
+}
+
+// IncludingRule returns the include rule that matches a table name
+// ("exact" or the matching pattern), or "" if none does or there are no
+// include rules
+func (m *Matcher) IncludingRule(tableName string) string {
+	if m.includes == nil {
+		return ""
+	}
+	return m.includes.MatchingRule(tableName)
 }
 
-// matchPattern matches a glob-style pattern against a string
-// Supports * wildcard (matches any sequence of characters)
-func matchPattern(pattern, str string) bool {
-	// Use filepath.Match for glob-style matching
-	// This supports * and ? wildcards
-	matched, err := filepath.Match(pattern, str)
-	if err != nil {
-		// If pattern is invalid, fall back to simple contains check
-		return strings.Contains(str, strings.Trim(pattern, "*"))
+// Validate checks that all patterns in a rule set are valid globs or
+// regular expressions, and that none is empty (an empty substring matches
+// every table). source names the rule set in error messages (e.g.
+// "exclude patterns").
+func Validate(rules config.ExcludeConfig, source string) error {
+	var problems []string
+	var cause error
+	for _, pattern := range rules.Patterns {
+		expr, isRegex := regexPattern(pattern)
+		switch {
+		case pattern == "" || isRegex && expr == "":
+			problems = append(problems, fmt.Sprintf("empty pattern %q would match every table (use \"*\" if that is intended)", pattern))
+		case isRegex:
+			if _, err := regexp.Compile(expr); err != nil {
+				problems = append(problems, fmt.Sprintf("invalid regular expression %q: %v", pattern, err))
+				cause = err
+			}
+		case IsPattern(pattern):
+			if err := GlobError(pattern); err != nil {
+				problems = append(problems, fmt.Sprintf("invalid pattern %q: %v", pattern, err))
+				cause = err
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &dberrors.ErrConfigInvalid{
+			Source:   source,
+			Problems: problems,
+			Err:      cause,
+		}
+	}
+
+	return nil
+}
+
+// matchPattern matches a pattern in any of its forms against a name
+func (m *Matcher) matchPattern(pattern, name string) bool {
+	if _, ok := regexPattern(pattern); ok {
+		re := m.regexps[pattern]
+		return re != nil && re.MatchString(name)
+	}
+	if !IsPattern(pattern) {
+		return pattern != "" && strings.Contains(m.key(name), m.key(pattern))
+	}
+	// Invalid globs never match; Validate reports them
+	matched, err := filepath.Match(m.key(pattern), m.key(name))
+	return err == nil && matched
+}
+
+// NameRule returns the rule set for a single table name or pattern, as
+// sample and structure rules take them: a plain name matches that table
+// only, not every table containing it
+func NameRule(match string) config.ExcludeConfig {
+	if _, ok := regexPattern(match); ok || IsPattern(match) {
+		return config.ExcludeConfig{Patterns: []string{match}}
+	}
+	return config.ExcludeConfig{Exact: []string{match}}
+}
+
+// regexPattern returns the expression of a "re:expr" or "/expr/" pattern
+func regexPattern(pattern string) (string, bool) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		return expr, true
+	}
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return pattern[1 : len(pattern)-1], true
 	}
-	return matched
+	return "", false
 }
 
 // FilterTables returns only tables that should be excluded
@@ -0,0 +1,317 @@
+package patterns
+
+import (
+	"errors"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+func TestMatcherForms(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		matches []string
+		misses  []string
+	}{
+		{name: "substring", pattern: "_cache", matches: []string{"_cache", "page_cache", "response_cache", "cache_cache"}, misses: []string{"cache", "pagecache"}},
+		{name: "substring in the middle", pattern: "log", matches: []string{"logs", "audit_log", "blogposts"}, misses: []string{"lgo"}},
+		{name: "suffix glob", pattern: "*_cache", matches: []string{"page_cache", "_cache"}, misses: []string{"page_cache_old", "cache"}},
+		{name: "prefix glob", pattern: "tmp_*", matches: []string{"tmp_", "tmp_import"}, misses: []string{"old_tmp_import", "tmp"}},
+		{name: "glob at both ends", pattern: "*_log_*", matches: []string{"app_log_2024"}, misses: []string{"app_log", "log_2024"}},
+		{name: "middle wildcard", pattern: "audit_*_2024", matches: []string{"audit_users_2024", "audit__2024"}, misses: []string{"audit_users_2025", "old_audit_users_2024"}},
+		{name: "single character", pattern: "shard_?", matches: []string{"shard_1", "shard_a"}, misses: []string{"shard_10", "shard_"}},
+		{name: "character class", pattern: "events_20[0-9][0-9]", matches: []string{"events_2019", "events_2024"}, misses: []string{"events_19", "events_20ab"}},
+		{name: "negated class", pattern: "log_[^0-9]*", matches: []string{"log_app"}, misses: []string{"log_2024"}},
+		{name: "glob is case sensitive", pattern: "*_Cache", matches: []string{"page_Cache"}, misses: []string{"page_cache"}},
+		{name: "re: prefix", pattern: "re:^(sessions|jobs)$", matches: []string{"sessions", "jobs"}, misses: []string{"sessions_old", "failed_jobs"}},
+		{name: "unanchored regex", pattern: "re:_v[0-9]+", matches: []string{"users_v2", "orders_v10_backup"}, misses: []string{"users_v", "users"}},
+		{name: "slashes", pattern: "/^cache_[a-z]+$/", matches: []string{"cache_pages"}, misses: []string{"cache_pages2", "page_cache_pages"}},
+		{name: "slashes with an inner slash", pattern: "/a/b/", matches: []string{"xa/by"}, misses: []string{"ab"}},
+		{name: "invalid regex never matches", pattern: "re:(", misses: []string{"(", "anything"}},
+		{name: "invalid glob never matches", pattern: "[a-", misses: []string{"[a-", "a"}},
+		{name: "lone slash is a substring", pattern: "/", matches: []string{"a/b"}, misses: []string{"ab"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatcher(config.ExcludeConfig{Patterns: []string{tt.pattern}})
+			for _, table := range tt.matches {
+				if !m.Matches(table) {
+					t.Errorf("%q doesn't match %q", tt.pattern, table)
+				}
+				if rule := m.MatchingRule(table); rule != tt.pattern {
+					t.Errorf("MatchingRule(%q) = %q, want %q", table, rule, tt.pattern)
+				}
+			}
+			for _, table := range tt.misses {
+				if m.Matches(table) {
+					t.Errorf("%q matches %q", tt.pattern, table)
+				}
+				if rule := m.MatchingRule(table); rule != "" {
+					t.Errorf("MatchingRule(%q) = %q, want none", table, rule)
+				}
+			}
+		})
+	}
+}
+
+func TestMatcherExactAndOrder(t *testing.T) {
+	m := NewMatcher(config.ExcludeConfig{
+		Exact:    []string{"sessions"},
+		Patterns: []string{"sess", "*ions"},
+	})
+	tests := []struct {
+		table string
+		rule  string
+	}{
+		{"sessions", "exact"},   // exact is checked first
+		{"session_log", "sess"}, // then patterns in order
+		{"migrations", "*ions"},
+		{"users", ""},
+	}
+	for _, tt := range tests {
+		if rule := m.MatchingRule(tt.table); rule != tt.rule {
+			t.Errorf("MatchingRule(%q) = %q, want %q", tt.table, rule, tt.rule)
+		}
+	}
+	if got := m.FilterTables([]string{"sessions", "users", "migrations"}); strings.Join(got, ",") != "sessions,migrations" {
+		t.Errorf("FilterTables = %v", got)
+	}
+	if got := m.FilterIncluded([]string{"sessions", "users", "migrations"}); strings.Join(got, ",") != "users" {
+		t.Errorf("FilterIncluded = %v", got)
+	}
+}
+
+func TestMatcherFoldCase(t *testing.T) {
+	rules := config.ExcludeConfig{
+		Exact:    []string{"Sessions"},
+		Patterns: []string{"_Cache", "Tmp_*", "re:^Audit_", "/LOG$/"},
+	}
+	tests := []struct {
+		table        string
+		exact, fold  bool
+		ruleWhenFold string
+	}{
+		{table: "Sessions", exact: true, fold: true, ruleWhenFold: "exact"},
+		{table: "SESSIONS", fold: true, ruleWhenFold: "exact"},
+		{table: "page_cache", fold: true, ruleWhenFold: "_Cache"},
+		{table: "page_Cache", exact: true, fold: true, ruleWhenFold: "_Cache"},
+		{table: "tmp_import", fold: true, ruleWhenFold: "Tmp_*"},
+		{table: "audit_users", fold: true, ruleWhenFold: "re:^Audit_"},
+		{table: "app_log", fold: true, ruleWhenFold: "/LOG$/"},
+		{table: "users"},
+	}
+	m := NewMatcher(rules)
+	folded := m.FoldCase()
+	if folded.FoldCase() != folded {
+		t.Error("FoldCase of a folded matcher isn't the same matcher")
+	}
+	for _, tt := range tests {
+		if got := m.Matches(tt.table); got != tt.exact {
+			t.Errorf("Matches(%q) = %v, want %v", tt.table, got, tt.exact)
+		}
+		if got := folded.Matches(tt.table); got != tt.fold {
+			t.Errorf("folded Matches(%q) = %v, want %v", tt.table, got, tt.fold)
+		}
+		if got := folded.MatchingRule(tt.table); got != tt.ruleWhenFold {
+			t.Errorf("folded MatchingRule(%q) = %q, want %q", tt.table, got, tt.ruleWhenFold)
+		}
+	}
+	// Folding returns a copy
+	if m.Matches("SESSIONS") {
+		t.Error("FoldCase changed the original matcher")
+	}
+}
+
+func TestMatcherWithIncludes(t *testing.T) {
+	m := NewMatcher(config.ExcludeConfig{Patterns: []string{"_log"}})
+	if m.WithIncludes(config.ExcludeConfig{}) != m {
+		t.Error("WithIncludes without rules didn't return the matcher")
+	}
+
+	inverted := m.WithIncludes(config.ExcludeConfig{Exact: []string{"users"}, Patterns: []string{"order*"}})
+	if !inverted.Inverted() || m.Inverted() {
+		t.Fatal("WithIncludes didn't return an inverted copy")
+	}
+	tests := []struct {
+		table     string
+		excluded  bool
+		rule      string
+		including string
+	}{
+		{table: "users", rule: "", including: "exact"},
+		{table: "orders", rule: "", including: "order*"},
+		{table: "order_log", excluded: true, rule: "_log", including: "order*"}, // exclude rules win
+		{table: "products", excluded: true, rule: RuleNotIncluded},
+	}
+	for _, tt := range tests {
+		if got := inverted.Matches(tt.table); got != tt.excluded {
+			t.Errorf("Matches(%q) = %v, want %v", tt.table, got, tt.excluded)
+		}
+		if got := inverted.MatchingRule(tt.table); got != tt.rule {
+			t.Errorf("MatchingRule(%q) = %q, want %q", tt.table, got, tt.rule)
+		}
+		if got := inverted.IncludingRule(tt.table); got != tt.including {
+			t.Errorf("IncludingRule(%q) = %q, want %q", tt.table, got, tt.including)
+		}
+	}
+	if level, rule := inverted.Confidence("products"); level != config.ConfidenceHigh || rule != RuleNotIncluded {
+		t.Errorf("Confidence(products) = %s, %q; want high, %q", level, rule, RuleNotIncluded)
+	}
+	if folded := inverted.FoldCase(); !folded.Matches("PRODUCTS") || folded.Matches("USERS") {
+		t.Error("FoldCase didn't fold the include rules")
+	}
+}
+
+func TestMatcherConfidence(t *testing.T) {
+	m := NewMatcher(config.ExcludeConfig{
+		Exact:    []string{"sessions", "Audit_Log"},
+		Patterns: []string{"*_log", "audit_*", "cache_*", "re:^tmp_"},
+	})
+	if m.WithConfidence(nil) != m {
+		t.Error("WithConfidence without levels didn't return the matcher")
+	}
+	weighted := m.WithConfidence(map[string]config.Confidence{
+		"sessions":  config.ConfidenceLow,
+		"Audit_Log": config.ConfidenceMedium,
+		"*_log":     config.ConfidenceLow,
+		"audit_*":   config.ConfidenceMedium,
+		"re:^tmp_":  config.ConfidenceLow,
+		"unused_*":  config.ConfidenceLow, // not a rule of the matcher
+	})
+
+	tests := []struct {
+		table string
+		level config.Confidence
+		rule  string
+	}{
+		{table: "sessions", level: config.ConfidenceLow, rule: "exact"},
+		{table: "app_log", level: config.ConfidenceLow, rule: "*_log"},
+		{table: "audit_log", level: config.ConfidenceMedium, rule: "audit_*"}, // the stronger of two patterns
+		{table: "Audit_Log", level: config.ConfidenceMedium, rule: "exact"},   // globs are case sensitive
+		{table: "cache_pages", level: config.ConfidenceHigh, rule: "cache_*"}, // unrated rules are high
+		{table: "cache_log", level: config.ConfidenceHigh, rule: "cache_*"},   // a high rule beats a low one
+		{table: "tmp_import", level: config.ConfidenceLow, rule: "re:^tmp_"},
+		{table: "unused_table", level: "", rule: ""},
+		{table: "users", level: "", rule: ""},
+	}
+	for _, tt := range tests {
+		if level, rule := weighted.Confidence(tt.table); level != tt.level || rule != tt.rule {
+			t.Errorf("Confidence(%q) = %s, %q; want %s, %q", tt.table, level, rule, tt.level, tt.rule)
+		}
+	}
+
+	// Without levels every matching rule is high
+	if level, rule := m.Confidence("sessions"); level != config.ConfidenceHigh || rule != "exact" {
+		t.Errorf("unweighted Confidence(sessions) = %s, %q", level, rule)
+	}
+	// Folding the case keeps the levels of exact rules
+	if level, _ := weighted.FoldCase().Confidence("SESSIONS"); level != config.ConfidenceLow {
+		t.Errorf("folded Confidence(SESSIONS) = %s, want low", level)
+	}
+	// Include rules don't lower the confidence of the exclude rules
+	inverted := weighted.WithIncludes(config.ExcludeConfig{Patterns: []string{"app_*"}})
+	if level, rule := inverted.Confidence("app_log"); level != config.ConfidenceLow || rule != "*_log" {
+		t.Errorf("inverted Confidence(app_log) = %s, %q; want low, *_log", level, rule)
+	}
+	if level, rule := inverted.Confidence("users"); level != config.ConfidenceHigh || rule != RuleNotIncluded {
+		t.Errorf("inverted Confidence(users) = %s, %q; want high, %q", level, rule, RuleNotIncluded)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		problems []string
+		regexErr bool
+	}{
+		{name: "valid forms", patterns: []string{"_cache", "*_log", "tmp_?", "[a-c]*", "re:^x$", "/y/"}},
+		{name: "empty", patterns: []string{""}, problems: []string{`empty pattern ""`}},
+		{name: "empty regex", patterns: []string{"re:"}, problems: []string{`empty pattern "re:"`}},
+		{name: "empty slashes", patterns: []string{"//"}, problems: []string{`empty pattern "//"`}},
+		{name: "invalid regex", patterns: []string{"re:(unclosed"}, problems: []string{`invalid regular expression "re:(unclosed"`}, regexErr: true},
+		{name: "invalid slashes", patterns: []string{"/[z-a]/"}, problems: []string{`invalid regular expression "/[z-a]/"`}, regexErr: true},
+		{name: "invalid glob", patterns: []string{"logs_[0-9"}, problems: []string{`invalid pattern "logs_[0-9"`}},
+		{
+			name:     "every problem is reported",
+			patterns: []string{"", "ok_*", "re:(", "[x"},
+			problems: []string{`empty pattern ""`, `invalid regular expression "re:("`, `invalid pattern "[x"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(config.ExcludeConfig{Patterns: tt.patterns}, "exclude patterns")
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Fatalf("Validate(%q) = %v", tt.patterns, err)
+				}
+				return
+			}
+			var invalid *dberrors.ErrConfigInvalid
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Validate(%q) = %v, want ErrConfigInvalid", tt.patterns, err)
+			}
+			if invalid.Source != "exclude patterns" {
+				t.Errorf("source = %q", invalid.Source)
+			}
+			if len(invalid.Problems) != len(tt.problems) {
+				t.Fatalf("problems = %q, want %d", invalid.Problems, len(tt.problems))
+			}
+			for i, problem := range tt.problems {
+				if !strings.HasPrefix(invalid.Problems[i], problem) {
+					t.Errorf("problem %d = %q, want it to start with %q", i, invalid.Problems[i], problem)
+				}
+			}
+			var regexErr *syntax.Error
+			if got := errors.As(err, &regexErr); got != tt.regexErr {
+				t.Errorf("errors.As(err, *syntax.Error) = %v, want %v", got, tt.regexErr)
+			}
+		})
+	}
+}
+
+func TestNameRule(t *testing.T) {
+	tests := []struct {
+		match   string
+		exact   bool
+		matches string
+		misses  string
+	}{
+		{match: "users", exact: true, matches: "users", misses: "users_old"},
+		{match: "users_*", matches: "users_old", misses: "users"},
+		{match: "re:^a", matches: "abc", misses: "bac"},
+	}
+	for _, tt := range tests {
+		rule := NameRule(tt.match)
+		if got := len(rule.Exact) == 1; got != tt.exact {
+			t.Errorf("NameRule(%q) = %+v, exact %v", tt.match, rule, got)
+		}
+		m := NewMatcher(rule)
+		if !m.Matches(tt.matches) || m.Matches(tt.misses) {
+			t.Errorf("NameRule(%q) matches %q: %v, %q: %v", tt.match, tt.matches, m.Matches(tt.matches), tt.misses, m.Matches(tt.misses))
+		}
+	}
+}
+
+func TestDefaultRules(t *testing.T) {
+	for _, version := range config.DefaultRulesVersions() {
+		defaults, err := config.LoadDefaultsVersion(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Validate(defaults.DefaultExcludes, "default rules"); err != nil {
+			t.Errorf("default rules version %d: %v", version, err)
+		}
+		// The shipped cache rule must catch the tables it is meant for
+		m := NewMatcher(defaults.DefaultExcludes)
+		for _, table := range []string{"page_cache", "response_cache"} {
+			if !m.Matches(table) {
+				t.Errorf("default rules version %d don't exclude %s", version, table)
+			}
+		}
+	}
+}
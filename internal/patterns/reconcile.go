@@ -0,0 +1,60 @@
+package patterns
+
+// SelectionSource is a named set of tables whose data is excluded
+type SelectionSource struct {
+	Name     string
+	Excluded []string
+}
+
+// SelectionConflict is a table that one source excludes and the other dumps
+type SelectionConflict struct {
+	Table      string
+	ExcludedBy string // name of the source that excludes the table's data
+	DumpedBy   string // name of the source that dumps it
+}
+
+// DiffSelections returns the tables (in the order of tables) on which two
+// sources disagree. Exclusions of tables not in tables are ignored.
+func DiffSelections(tables []string, a, b SelectionSource) []SelectionConflict {
+	inA := toSet(a.Excluded)
+	inB := toSet(b.Excluded)
+
+	var conflicts []SelectionConflict
+	for _, table := range tables {
+		switch {
+		case inA[table] && !inB[table]:
+			conflicts = append(conflicts, SelectionConflict{Table: table, ExcludedBy: a.Name, DumpedBy: b.Name})
+		case inB[table] && !inA[table]:
+			conflicts = append(conflicts, SelectionConflict{Table: table, ExcludedBy: b.Name, DumpedBy: a.Name})
+		}
+	}
+	return conflicts
+}
+
+// ResolveSelections returns the exclusions of base restricted to tables,
+// with choices (table -> exclude) overriding base for individual tables.
+// The result is in the order of tables.
+func ResolveSelections(tables []string, base SelectionSource, choices map[string]bool) []string {
+	excluded := toSet(base.Excluded)
+
+	resolved := []string{}
+	for _, table := range tables {
+		exclude, chosen := choices[table]
+		if !chosen {
+			exclude = excluded[table]
+		}
+		if exclude {
+			resolved = append(resolved, table)
+		}
+	}
+	return resolved
+}
+
+// toSet returns the names as a set
+func toSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
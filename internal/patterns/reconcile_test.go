@@ -0,0 +1,172 @@
+package patterns
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// shopTables are the tables the selections are compared over
+var shopTables = []string{"users", "orders", "sessions", "cache", "audit_log", "jobs"}
+
+func TestDiffSelections(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables []string
+		a, b   []string
+		want   []SelectionConflict
+	}{
+		{name: "both empty"},
+		{name: "agree", tables: shopTables, a: []string{"sessions", "cache"}, b: []string{"cache", "sessions"}},
+		{name: "nil and empty agree", tables: shopTables, a: nil, b: []string{}},
+		{
+			name:   "config excludes more",
+			tables: shopTables, a: []string{"sessions", "cache"}, b: []string{"cache"},
+			want: []SelectionConflict{{Table: "sessions", ExcludedBy: "config", DumpedBy: "saved"}},
+		},
+		{
+			name:   "saved excludes more",
+			tables: shopTables, a: []string{"cache"}, b: []string{"cache", "jobs"},
+			want: []SelectionConflict{{Table: "jobs", ExcludedBy: "saved", DumpedBy: "config"}},
+		},
+		{
+			name:   "one side empty",
+			tables: shopTables, a: nil, b: []string{"jobs", "sessions"},
+			want: []SelectionConflict{
+				{Table: "sessions", ExcludedBy: "saved", DumpedBy: "config"},
+				{Table: "jobs", ExcludedBy: "saved", DumpedBy: "config"},
+			},
+		},
+		{
+			name:   "disjoint, in the order of the tables",
+			tables: shopTables, a: []string{"jobs", "users"}, b: []string{"audit_log", "orders"},
+			want: []SelectionConflict{
+				{Table: "users", ExcludedBy: "config", DumpedBy: "saved"},
+				{Table: "orders", ExcludedBy: "saved", DumpedBy: "config"},
+				{Table: "audit_log", ExcludedBy: "saved", DumpedBy: "config"},
+				{Table: "jobs", ExcludedBy: "config", DumpedBy: "saved"},
+			},
+		},
+		{
+			name:   "tables no longer in the database are ignored",
+			tables: shopTables, a: []string{"cache", "old_cache"}, b: []string{"cache", "dropped_table"},
+		},
+		{
+			name:   "duplicates count once",
+			tables: shopTables, a: []string{"cache", "cache"}, b: []string{"cache", "sessions", "sessions"},
+			want: []SelectionConflict{{Table: "sessions", ExcludedBy: "saved", DumpedBy: "config"}},
+		},
+		{
+			name:   "names are exact",
+			tables: []string{"Users", "users"}, a: []string{"Users"}, b: []string{"users"},
+			want: []SelectionConflict{
+				{Table: "Users", ExcludedBy: "config", DumpedBy: "saved"},
+				{Table: "users", ExcludedBy: "saved", DumpedBy: "config"},
+			},
+		},
+		{
+			name:   "no tables, no conflicts",
+			tables: nil, a: []string{"cache"}, b: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := SelectionSource{Name: "config", Excluded: tt.a}
+			b := SelectionSource{Name: "saved", Excluded: tt.b}
+			got := DiffSelections(tt.tables, a, b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffSelections =\n %+v, want\n %+v", got, tt.want)
+			}
+
+			// Swapping the sources finds the same tables, the roles kept
+			swapped := DiffSelections(tt.tables, b, a)
+			if !reflect.DeepEqual(swapped, got) {
+				t.Errorf("swapped DiffSelections =\n %+v, want\n %+v", swapped, got)
+			}
+		})
+	}
+}
+
+func TestResolveSelections(t *testing.T) {
+	tests := []struct {
+		name    string
+		tables  []string
+		base    []string
+		choices map[string]bool
+		want    []string
+	}{
+		{name: "nothing", want: []string{}},
+		{name: "base only", tables: shopTables, base: []string{"jobs", "cache"}, want: []string{"cache", "jobs"}},
+		{name: "base restricted to the tables", tables: shopTables, base: []string{"cache", "dropped_table"}, want: []string{"cache"}},
+		{name: "choice excludes", tables: shopTables, base: []string{"cache"}, choices: map[string]bool{"sessions": true}, want: []string{"sessions", "cache"}},
+		{name: "choice dumps", tables: shopTables, base: []string{"cache", "sessions"}, choices: map[string]bool{"sessions": false}, want: []string{"cache"}},
+		{name: "choice agreeing with base", tables: shopTables, base: []string{"cache"}, choices: map[string]bool{"cache": true, "users": false}, want: []string{"cache"}},
+		{name: "choice for an unknown table", tables: shopTables, base: []string{"cache"}, choices: map[string]bool{"dropped_table": true}, want: []string{"cache"}},
+		{name: "every table chosen", tables: []string{"a", "b"}, base: []string{"a"}, choices: map[string]bool{"a": false, "b": true}, want: []string{"b"}},
+		{name: "duplicates in base", tables: shopTables, base: []string{"cache", "cache"}, want: []string{"cache"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveSelections(tt.tables, SelectionSource{Name: "config", Excluded: tt.base}, tt.choices)
+			if got == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("ResolveSelections = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReconcileEveryPick resolves each conflict between two selections
+// every possible way and checks the outcome table by table: agreed tables
+// keep what both sides say, conflicting ones follow the pick, whichever
+// side is the base
+func TestReconcileEveryPick(t *testing.T) {
+	a := SelectionSource{Name: "config", Excluded: []string{"sessions", "cache", "audit_log"}}
+	b := SelectionSource{Name: "saved", Excluded: []string{"cache", "jobs", "users", "dropped_table"}}
+	conflicts := DiffSelections(shopTables, a, b)
+	if len(conflicts) != 4 {
+		t.Fatalf("conflicts = %+v, want 4", conflicts)
+	}
+
+	for picks := 0; picks < 1<<len(conflicts); picks++ {
+		// Bit i set: conflict i follows the source that excludes the table
+		choices := make(map[string]bool)
+		for i, conflict := range conflicts {
+			choices[conflict.Table] = picks&(1<<i) != 0
+		}
+		want := []string{}
+		for _, table := range shopTables {
+			exclude, conflicting := choices[table]
+			if !conflicting {
+				exclude = slices.Contains(a.Excluded, table) // agreed by both
+			}
+			if exclude {
+				want = append(want, table)
+			}
+		}
+
+		for _, base := range []SelectionSource{a, b} {
+			if got := ResolveSelections(shopTables, base, choices); !slices.Equal(got, want) {
+				t.Errorf("picks %04b from %s = %v, want %v", picks, base.Name, got, want)
+			}
+		}
+	}
+
+	// Accepting one side wholesale is no choices on top of it
+	for _, side := range []SelectionSource{a, b} {
+		choices := make(map[string]bool)
+		for _, conflict := range conflicts {
+			choices[conflict.Table] = conflict.ExcludedBy == side.Name
+		}
+		other := a
+		if side.Name == a.Name {
+			other = b
+		}
+		wholesale := ResolveSelections(shopTables, side, nil)
+		if got := ResolveSelections(shopTables, other, choices); !slices.Equal(got, wholesale) {
+			t.Errorf("picking the %s everywhere = %v, want %v", side.Name, got, wholesale)
+		}
+		if rest := DiffSelections(shopTables, SelectionSource{Name: "resolved", Excluded: wholesale}, side); rest != nil {
+			t.Errorf("the %s disagrees with itself: %+v", side.Name, rest)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package patterns
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// IsPattern reports whether a name contains glob wildcards
+func IsPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// Expand returns the tables matching a glob pattern, in the order given
+func Expand(pattern string, tables []string) []string {
+	var matched []string
+	for _, table := range tables {
+		if ok, err := filepath.Match(pattern, table); err == nil && ok {
+			matched = append(matched, table)
+		}
+	}
+	return matched
+}
+
+// Suggest returns the table name closest to name, or "" if none is close enough
+// to be a likely typo
+func Suggest(name string, tables []string) string {
+	// Allow roughly one edit per three characters, and at least two
+	bestDistance := max(2, len(name)/3) + 1
+	best := ""
+	lower := strings.ToLower(name)
+	for _, table := range tables {
+		if distance := levenshtein(lower, strings.ToLower(table)); distance < bestDistance {
+			best = table
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
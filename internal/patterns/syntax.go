@@ -0,0 +1,104 @@
+package patterns
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// GlobError returns what is wrong with a glob pattern and where, counting
+// characters from 1, or nil if filepath.Match accepts it
+func GlobError(pattern string) error {
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if filepath.Separator == '\\' {
+				continue // a path separator on Windows, not an escape
+			}
+			if i+1 == len(runes) {
+				return fmt.Errorf("\\ at position %d escapes nothing", i+1)
+			}
+			i++
+		case '[':
+			end, err := classEnd(runes, i)
+			if err != nil {
+				return err
+			}
+			i = end
+		}
+	}
+	// Anything the scan above doesn't know about
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return err
+	}
+	return nil
+}
+
+// classEnd returns the position of the ] closing the character class
+// opened at open, following filepath.Match's rules
+func classEnd(runes []rune, open int) (int, error) {
+	i := open + 1
+	if i < len(runes) && runes[i] == '^' {
+		i++
+	}
+	for ranges := 0; ; ranges++ {
+		if i < len(runes) && runes[i] == ']' && ranges > 0 {
+			return i, nil
+		}
+		next, err := classChar(runes, i, open, false)
+		if err != nil {
+			return 0, err
+		}
+		i = next
+		if i < len(runes) && runes[i] == '-' {
+			if i, err = classChar(runes, i+1, open, true); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+// classChar returns the position after the character of a class at i, or
+// why there is none; bound is set for the upper bound of a range
+func classChar(runes []rune, i, open int, bound bool) (int, error) {
+	switch {
+	case i >= len(runes) || runes[i] == '\\' && i+1 >= len(runes):
+		return 0, fmt.Errorf("[ at position %d is never closed (escape it as \\[ to match a bracket)", open+1)
+	case runes[i] == ']' && bound:
+		return 0, fmt.Errorf("- at position %d has no upper bound (escape it as \\- to match a dash)", i)
+	case runes[i] == ']':
+		return 0, fmt.Errorf("[] at position %d is an empty character class", open+1)
+	case runes[i] == '-':
+		return 0, fmt.Errorf("- at position %d has no lower bound (escape it as \\- to match a dash)", i+1)
+	case runes[i] == '\\' && filepath.Separator != '\\':
+		return i + 2, nil
+	}
+	return i + 1, nil
+}
+
+// Normalize fixes common mistakes in a pattern as typed by a user: spaces
+// around it and a second pair of quotes. It returns the pattern to use and
+// a note for each fix, and for a glob that looks like a regular expression
+// (".*", a leading ^ or a trailing $), which is matched as a glob anyway.
+func Normalize(pattern string) (string, []string) {
+	var notes []string
+	normalized := strings.TrimSpace(pattern)
+	if normalized != pattern {
+		notes = append(notes, "removed the spaces around it")
+	}
+	if n := len(normalized); n >= 2 && (normalized[0] == '"' || normalized[0] == '\'') && normalized[n-1] == normalized[0] {
+		normalized = strings.TrimSpace(normalized[1 : n-1])
+		notes = append(notes, "removed the quotes around it (the shell or YAML already removes one pair)")
+	}
+	if _, isRegex := regexPattern(normalized); !isRegex && looksLikeRegex(normalized) {
+		notes = append(notes, fmt.Sprintf("looks like a regular expression but isn't matched as one; write %q for that (globs use * for any characters)", "re:"+normalized))
+	}
+	return normalized, notes
+}
+
+// looksLikeRegex reports whether a glob has regular expression syntax
+func looksLikeRegex(pattern string) bool {
+	return strings.Contains(pattern, ".*") || strings.Contains(pattern, ".+") ||
+		strings.HasPrefix(pattern, "^") || strings.HasSuffix(pattern, "$")
+}
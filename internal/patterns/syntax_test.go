@@ -0,0 +1,116 @@
+package patterns
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGlobError(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr string
+	}{
+		// Good
+		{name: "plain name", pattern: "users"},
+		{name: "star", pattern: "telescope_*"},
+		{name: "question mark", pattern: "shard_?"},
+		{name: "class", pattern: "events_20[0-9][0-9]"},
+		{name: "negated class", pattern: "log_[^0-9]*"},
+		{name: "escaped bracket", pattern: `a\[b`},
+		{name: "escaped ] in a class", pattern: `[\]]`},
+		{name: "stray ]", pattern: "a]b"},
+		{name: "reversed range", pattern: "a[z-a]"},
+
+		// Bad, with the position of the mistake
+		{name: "unclosed class", pattern: "telescope_[", wantErr: "[ at position 11 is never closed"},
+		{name: "unclosed negated class", pattern: "[^a", wantErr: "[ at position 1 is never closed"},
+		{name: "unclosed after an escape", pattern: `[\`, wantErr: "[ at position 1 is never closed"},
+		{name: "unclosed range", pattern: `[a-\`, wantErr: "[ at position 1 is never closed"},
+		{name: "position counts characters", pattern: "é[", wantErr: "[ at position 2 is never closed"},
+		{name: "empty class", pattern: "a[]", wantErr: "[] at position 2 is an empty character class"},
+		{name: "empty negated class", pattern: "[^]", wantErr: "[] at position 1 is an empty character class"},
+		{name: "] first in a class", pattern: "[]a]", wantErr: "[] at position 1 is an empty character class"},
+		{name: "range without upper bound", pattern: "a[b-]", wantErr: "- at position 4 has no upper bound"},
+		{name: "range without lower bound", pattern: "a[-b]", wantErr: "- at position 3 has no lower bound"},
+		{name: "trailing backslash", pattern: `x\`, wantErr: `\ at position 2 escapes nothing`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := GlobError(tt.pattern)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("GlobError(%q) = %v", tt.pattern, err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Fatalf("GlobError(%q) = %v, want %q", tt.pattern, err, tt.wantErr)
+			}
+			// filepath.Match agrees that the pattern is bad
+			if _, matchErr := filepath.Match(tt.pattern, strings.Repeat("a", 20)); matchErr == nil {
+				t.Errorf("filepath.Match accepts %q", tt.pattern)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	const (
+		spaces = "removed the spaces"
+		quotes = "removed the quotes"
+		regex  = "looks like a regular expression"
+	)
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		notes   []string
+	}{
+		// Good: left alone
+		{name: "plain", pattern: "sessions", want: "sessions"},
+		{name: "glob", pattern: "*_cache", want: "*_cache"},
+		{name: "dot in a name", pattern: "a.b", want: "a.b"},
+		{name: "re: prefix", pattern: "re:^foo$", want: "re:^foo$"},
+		{name: "slashes", pattern: "/^a$/", want: "/^a$/"},
+		{name: "mismatched quotes", pattern: `"x'`, want: `"x'`},
+
+		// Fixed
+		{name: "spaces", pattern: " telescope_* ", want: "telescope_*", notes: []string{spaces}},
+		{name: "tab", pattern: "\tlogs", want: "logs", notes: []string{spaces}},
+		{name: "double quotes", pattern: `"*_log"`, want: "*_log", notes: []string{quotes}},
+		{name: "single quotes", pattern: "'*_log'", want: "*_log", notes: []string{quotes}},
+		{name: "spaces and quotes", pattern: " 'x' ", want: "x", notes: []string{spaces, quotes}},
+		{name: "spaces inside quotes", pattern: "' x '", want: "x", notes: []string{quotes}},
+		{name: "quoted regex", pattern: "'re:.*'", want: "re:.*", notes: []string{quotes}},
+
+		// Ambiguous: kept as a glob, with a suggestion
+		{name: ".*", pattern: ".*_log", want: ".*_log", notes: []string{regex + ` but isn't matched as one; write "re:.*_log"`}},
+		{name: ".+", pattern: "foo.+", want: "foo.+", notes: []string{regex}},
+		{name: "leading ^", pattern: "^foo", want: "^foo", notes: []string{regex}},
+		{name: "trailing $", pattern: "foo$", want: "foo$", notes: []string{regex}},
+		{name: "quoted regex without prefix", pattern: `"^cache_"`, want: "^cache_", notes: []string{quotes, `write "re:^cache_"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, notes := Normalize(tt.pattern)
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+			if len(notes) != len(tt.notes) {
+				t.Fatalf("notes = %q, want %d", notes, len(tt.notes))
+			}
+			for i, note := range tt.notes {
+				if !strings.Contains(notes[i], note) {
+					t.Errorf("note %d = %q, want it to contain %q", i, notes[i], note)
+				}
+			}
+			// Normalizing twice fixes nothing more; only the regex note repeats
+			again, againNotes := Normalize(got)
+			if again != got || len(againNotes) != strings.Count(strings.Join(notes, "\n"), regex) {
+				t.Errorf("Normalize(%q) = %q, %q; not idempotent", got, again, againNotes)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Package plan reads and writes dump plans: reviewable documents listing
+// exactly which tables a dump will copy, and how, before it runs.
+package plan
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"gopkg.in/yaml.v3"
+)
+
+// FormatVersion is the version of the plan file format
+const FormatVersion = 1
+
+// Table dispositions
+const (
+	DispositionFull          = "full"           // structure and data
+	DispositionStructureOnly = "structure-only" // data excluded
+	DispositionSkipped       = "skipped"        // neither structure nor data
+)
+
+// Target is the database the plan was made for (never includes secrets)
+type Target struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Database string `yaml:"database"`
+}
+
+// Table is the planned disposition of a table and the rule responsible for it
+type Table struct {
+	Name           string `yaml:"name"`
+	Disposition    string `yaml:"disposition"`
+	Rule           string `yaml:"rule,omitempty"`
+	Structure      string `yaml:"structure,omitempty"` // no-indexes or minimal; empty is the full CREATE TABLE
+	Sample         int    `yaml:"sample,omitempty"`    // rows of a structure-only table dumped after all
+	Rows           int64  `yaml:"rows"`
+	EstimatedBytes int64  `yaml:"estimated_bytes"`
+}
+
+// Transforms lists changes applied to the dumped SQL
+type Transforms struct {
+	ConvertCharset string `yaml:"convert_charset,omitempty"`
+}
+
+// Destination describes where the dump is written
+type Destination struct {
+	// Output is the dump file; empty means the default generated name
+	Output      string `yaml:"output,omitempty"`
+	MaxFileSize string `yaml:"max_file_size,omitempty"`
+	Compress    bool   `yaml:"compress,omitempty"`
+}
+
+// Plan is the effective dump plan
+type Plan struct {
+	FormatVersion  int         `yaml:"format_version"`
+	ToolVersion    string      `yaml:"tool_version"`
+	CreatedAt      time.Time   `yaml:"created_at"`
+	Target         Target      `yaml:"target"`
+	Tables         []Table     `yaml:"tables"`
+	Transforms     Transforms  `yaml:"transforms,omitempty"`
+	Destination    Destination `yaml:"destination"`
+	EstimatedBytes int64       `yaml:"estimated_bytes"`
+}
+
+// Write writes the plan to path with restrictive permissions
+func Write(path string, p *Plan) error {
+	p.FormatVersion = FormatVersion
+
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads and validates a plan file
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &dberrors.ErrConfigInvalid{
+			Source: path,
+			Err:    fmt.Errorf("failed to read plan: %w", err),
+		}
+	}
+
+	var p Plan
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, &dberrors.ErrConfigInvalid{
+			Source: path,
+			Err:    fmt.Errorf("failed to parse plan: %w", err),
+		}
+	}
+
+	if problems := p.validate(); len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: path, Problems: problems}
+	}
+
+	return &p, nil
+}
+
+// validate returns the problems that make a plan unusable
+func (p *Plan) validate() []string {
+	var problems []string
+	if p.FormatVersion != FormatVersion {
+		problems = append(problems, fmt.Sprintf("unsupported format_version %d (expected %d)", p.FormatVersion, FormatVersion))
+	}
+	if p.Target.Database == "" {
+		problems = append(problems, "target.database is empty")
+	}
+
+	seen := make(map[string]bool, len(p.Tables))
+	for _, table := range p.Tables {
+		switch table.Disposition {
+		case DispositionFull, DispositionStructureOnly, DispositionSkipped:
+		default:
+			problems = append(problems, fmt.Sprintf("table %s: unknown disposition %q", table.Name, table.Disposition))
+		}
+		if table.Sample < 0 || (table.Sample > 0 && table.Disposition != DispositionStructureOnly) {
+			problems = append(problems, fmt.Sprintf("table %s: sample needs a structure-only table and a positive row count", table.Name))
+		}
+		if seen[table.Name] {
+			problems = append(problems, fmt.Sprintf("table %s is listed more than once", table.Name))
+		}
+		seen[table.Name] = true
+	}
+	return problems
+}
+
+// Drift compares the plan with the live table list, returning tables that
+// exist now but are not in the plan, and planned tables that no longer exist
+func (p *Plan) Drift(live []string) (added, removed []string) {
+	planned := make(map[string]bool, len(p.Tables))
+	for _, table := range p.Tables {
+		planned[table.Name] = true
+	}
+	exists := make(map[string]bool, len(live))
+	for _, name := range live {
+		exists[name] = true
+		if !planned[name] {
+			added = append(added, name)
+		}
+	}
+	for _, table := range p.Tables {
+		if !exists[table.Name] {
+			removed = append(removed, table.Name)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,233 @@
+package plan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+// shopPlan is a plan with a table of each disposition
+func shopPlan() *Plan {
+	return &Plan{
+		ToolVersion: "1.4.0",
+		CreatedAt:   time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC),
+		Target:      Target{Host: "db.internal", Port: 3306, User: "reader", Database: "shop"},
+		Tables: []Table{
+			{Name: "users", Disposition: DispositionFull, Rows: 1200, EstimatedBytes: 480000},
+			{Name: "orders", Disposition: DispositionFull, Rows: 5400, EstimatedBytes: 1 << 20},
+			{Name: "sessions", Disposition: DispositionStructureOnly, Rule: "exclude sessions", Structure: "minimal", Rows: 90000},
+			{Name: "audit_log", Disposition: DispositionStructureOnly, Rule: "exclude *_log", Sample: 100, Rows: 2000000},
+			{Name: "tmp_import", Disposition: DispositionSkipped, Rule: "skip tmp_*"},
+		},
+		Transforms:     Transforms{ConvertCharset: "utf8mb4"},
+		Destination:    Destination{Output: "shop.sql.gz", MaxFileSize: "500MB", Compress: true},
+		EstimatedBytes: 480000 + 1<<20,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		plan *Plan
+	}{
+		{name: "every disposition", plan: shopPlan()},
+		// An empty database is written as "tables: []"
+		{name: "no tables", plan: &Plan{Target: Target{Database: "empty"}, Tables: []Table{}}},
+		{
+			name: "defaults",
+			plan: &Plan{
+				Target: Target{Host: "localhost", Port: 3306, User: "root", Database: "app"},
+				Tables: []Table{{Name: "users", Disposition: DispositionFull}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plan.yaml")
+			if err := Write(path, tt.plan); err != nil {
+				t.Fatal(err)
+			}
+			if tt.plan.FormatVersion != FormatVersion {
+				t.Errorf("Write() left format_version %d", tt.plan.FormatVersion)
+			}
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+				t.Errorf("plan file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+			}
+
+			loaded, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(loaded, tt.plan) {
+				t.Errorf("Load() = %+v, want %+v", loaded, tt.plan)
+			}
+
+			// Writing the loaded plan again gives the same file
+			again := filepath.Join(t.TempDir(), "plan.yaml")
+			if err := Write(again, loaded); err != nil {
+				t.Fatal(err)
+			}
+			first, _ := os.ReadFile(path)
+			second, _ := os.ReadFile(again)
+			if string(first) != string(second) {
+				t.Errorf("rewritten plan differs:\n%s\nwant:\n%s", second, first)
+			}
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string // substrings of the problems, in order
+	}{
+		{
+			name: "valid",
+			yaml: "format_version: 1\ntarget: {database: shop}\ntables:\n  - {name: users, disposition: full}\n",
+		},
+		{
+			name: "no version",
+			yaml: "target: {database: shop}\n",
+			want: []string{"unsupported format_version 0 (expected 1)"},
+		},
+		{
+			name: "newer version",
+			yaml: "format_version: 2\ntarget: {database: shop}\n",
+			want: []string{"unsupported format_version 2"},
+		},
+		{
+			name: "no database",
+			yaml: "format_version: 1\ntarget: {host: db}\n",
+			want: []string{"target.database is empty"},
+		},
+		{
+			name: "unknown disposition",
+			yaml: "format_version: 1\ntarget: {database: shop}\ntables:\n  - {name: users, disposition: trimmed}\n",
+			want: []string{`table users: unknown disposition "trimmed"`},
+		},
+		{
+			name: "sample of a full table",
+			yaml: "format_version: 1\ntarget: {database: shop}\ntables:\n  - {name: logs, disposition: full, sample: 10}\n",
+			want: []string{"table logs: sample needs a structure-only table"},
+		},
+		{
+			name: "negative sample",
+			yaml: "format_version: 1\ntarget: {database: shop}\ntables:\n  - {name: logs, disposition: structure-only, sample: -1}\n",
+			want: []string{"table logs: sample needs a structure-only table and a positive row count"},
+		},
+		{
+			name: "duplicate table",
+			yaml: "format_version: 1\ntarget: {database: shop}\ntables:\n  - {name: users, disposition: full}\n  - {name: users, disposition: skipped}\n",
+			want: []string{"table users is listed more than once"},
+		},
+		{
+			name: "every problem",
+			yaml: "tables:\n  - {name: a, disposition: x}\n  - {name: a, disposition: full}\n",
+			want: []string{"format_version", "target.database", "table a: unknown disposition", "table a is listed more than once"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plan.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Load() = %v", err)
+				}
+				return
+			}
+			var invalid *dberrors.ErrConfigInvalid
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Load() = %v, want ErrConfigInvalid", err)
+			}
+			if invalid.Source != path {
+				t.Errorf("source = %q, want %q", invalid.Source, path)
+			}
+			if len(invalid.Problems) != len(tt.want) {
+				t.Fatalf("problems = %q, want %d", invalid.Problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(invalid.Problems[i], want) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, invalid.Problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.yaml")
+	if err := os.WriteFile(broken, []byte("tables: [\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{
+		filepath.Join(dir, "missing.yaml"): "failed to read plan",
+		broken:                             "failed to parse plan",
+	} {
+		_, err := Load(path)
+		var invalid *dberrors.ErrConfigInvalid
+		if !errors.As(err, &invalid) || invalid.Err == nil || !strings.Contains(invalid.Err.Error(), want) {
+			t.Errorf("Load(%s) = %v, want ErrConfigInvalid with %q", filepath.Base(path), err, want)
+		}
+	}
+}
+
+func TestDrift(t *testing.T) {
+	tests := []struct {
+		name           string
+		live           []string
+		added, removed []string
+	}{
+		{name: "no drift", live: []string{"users", "orders", "sessions", "audit_log", "tmp_import"}},
+		{name: "other order", live: []string{"tmp_import", "audit_log", "sessions", "orders", "users"}},
+		{
+			name:  "new tables, in live order",
+			live:  []string{"users", "orders", "carts", "sessions", "audit_log", "tmp_import", "coupons"},
+			added: []string{"carts", "coupons"},
+		},
+		{
+			name:    "dropped tables, in plan order",
+			live:    []string{"orders", "sessions"},
+			removed: []string{"users", "audit_log", "tmp_import"},
+		},
+		{
+			name:    "both",
+			live:    []string{"users", "orders", "sessions", "audit_log", "tmp_import_2"},
+			added:   []string{"tmp_import_2"},
+			removed: []string{"tmp_import"},
+		},
+		{
+			name:    "names are exact",
+			live:    []string{"Users", "orders", "sessions", "audit_log", "tmp_import"},
+			added:   []string{"Users"},
+			removed: []string{"users"},
+		},
+		{
+			name:    "empty database",
+			removed: []string{"users", "orders", "sessions", "audit_log", "tmp_import"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := shopPlan().Drift(tt.live)
+			if !reflect.DeepEqual(added, tt.added) || !reflect.DeepEqual(removed, tt.removed) {
+				t.Errorf("Drift() = %q, %q; want %q, %q", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+// Package planner decides what a dump does with each table: which rules
+// select, exclude or skip it, what the dump's structure and samples look
+// like, where it is written and which mysqldump commands write it. It
+// connects to nothing, prompts for nothing and prints nothing, so the dump,
+// its dry run, the plan command and their JSON output share one answer.
+package planner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/plan"
+	"github.com/helgesverre/dbdump/internal/structure"
+)
+
+// Planner turns table information and the dump's rules into a plan
+type Planner struct {
+	Rules Rules
+}
+
+// Select applies the planner's rules to the tables found in the database
+func (p *Planner) Select(tablesInfo []database.TableInfo) (*Selection, error) {
+	return Select(tablesInfo, p.Rules)
+}
+
+// Input is what a plan is made of besides the rules
+type Input struct {
+	Selection *Selection
+
+	// Excludes are the tables whose data is excluded, as finally chosen
+	// (by the rules, the selector or a plan)
+	Excludes []string
+
+	// Masked maps tables whose data is read with masks to the masked columns
+	Masked map[string][]string
+
+	// Nulled maps tables with null_columns to those columns and their
+	// placeholders
+	Nulled map[string]map[string]string
+
+	// Copies maps tables whose data is copied from an identical table in
+	// another database to that database (--dedupe-identical)
+	Copies map[string]string
+
+	// Native are the tables whose data dbdump reads itself rather than
+	// mysqldump: masked tables, case twins and the base of an append chain
+	Native []string
+
+	// SizesKnown is false when the table sizes could not be read, and the
+	// dump size can't be estimated
+	SizesKnown bool
+
+	// OutputFile is the dump file; MaxFileSize > 0 splits it into parts
+	OutputFile  string
+	MaxFileSize int64
+
+	// Dump holds the connection and mysqldump options the commands are
+	// built with; its table lists and output are set from the plan
+	Dump database.DumpOptions
+}
+
+// DumpPlan is what a dump will do
+type DumpPlan struct {
+	Database string
+
+	// OutputFile is the dump file, or its first part when Parts is set
+	// for a dump split at MaxFileSize
+	OutputFile  string
+	Parts       bool
+	MaxFileSize int64
+
+	// IncludeMode is set when include rules decide which tables keep their data
+	IncludeMode bool
+
+	// EstimatedSize is the expected size of the SQL, when SizesKnown
+	SizesKnown    bool
+	EstimatedSize int64
+
+	// Tables lists every table in the order the database lists them
+	Tables []Table
+
+	// Levels are the reduced structure levels of data-excluded tables, and
+	// Samples the rows kept of sampled ones
+	Levels  map[string]structure.Level
+	Samples map[string]int
+
+	// Commands are the mysqldump invocations, in the order they run (none
+	// for a native dump); passwords are never part of them
+	Commands []database.Command
+}
+
+// Table is the planned disposition of one table
+type Table struct {
+	database.TableInfo
+
+	// Disposition is plan.DispositionFull, plan.DispositionStructureOnly
+	// or plan.DispositionSkipped
+	Disposition string
+
+	// Rule names the rule behind the disposition, if any, and Confidence
+	// how sure it is when that is medium or low
+	Rule       string
+	Confidence config.Confidence
+
+	Structure  structure.Level // empty for the full CREATE TABLE
+	SampleRows int
+	Masked     []string
+	Nulled     map[string]string
+	CopyOf     string // database the data is copied from
+	Native     bool   // data read by dbdump, not mysqldump
+}
+
+// Plan decides the disposition of every table and what the dump writes
+func (p *Planner) Plan(in Input) *DumpPlan {
+	sel := in.Selection
+	excluded := make(map[string]bool, len(in.Excludes))
+	for _, table := range in.Excludes {
+		excluded[table] = true
+	}
+	skipped := make(map[string]bool, len(sel.Skipped))
+	for _, table := range sel.Skipped {
+		skipped[table] = true
+	}
+	native := make(map[string]bool, len(in.Native))
+	for _, table := range in.Native {
+		native[table] = true
+	}
+
+	levels := p.Rules.Levels
+	if levels == nil {
+		levels = p.Rules.Structure.Levels(in.Excludes)
+	}
+	samples := sel.Samples.Counts(in.Excludes)
+	rules := sel.Explain()
+
+	dp := &DumpPlan{
+		Database:    in.Dump.Connection.Database,
+		OutputFile:  in.OutputFile,
+		Parts:       in.MaxFileSize > 0,
+		MaxFileSize: in.MaxFileSize,
+		IncludeMode: sel.Data != nil && sel.Data.Inverted(),
+		SizesKnown:  in.SizesKnown,
+		Levels:      levels,
+		Samples:     samples,
+	}
+	if dp.Parts {
+		dp.OutputFile = dumpfile.PartPath(in.OutputFile, 1)
+	}
+	if in.SizesKnown {
+		dp.EstimatedSize = database.EstimateDumpSize(sel.All, in.Excludes, sel.Skipped)
+	}
+
+	for _, info := range sel.All {
+		table := Table{TableInfo: info, Rule: rules[info.Name], Confidence: sel.Confidence[info.Name]}
+		switch {
+		case skipped[info.Name]:
+			table.Disposition = plan.DispositionSkipped
+		case excluded[info.Name]:
+			table.Disposition = plan.DispositionStructureOnly
+			table.Structure = levels[info.Name]
+			table.SampleRows = samples[info.Name]
+			table.Masked = in.Masked[info.Name]
+			table.Nulled = in.Nulled[info.Name]
+		default:
+			table.Disposition = plan.DispositionFull
+			table.Masked = in.Masked[info.Name]
+			table.Nulled = in.Nulled[info.Name]
+			table.CopyOf = in.Copies[info.Name]
+			table.Native = native[info.Name] || len(table.Masked)+len(table.Nulled) > 0
+		}
+		dp.Tables = append(dp.Tables, table)
+	}
+
+	options := in.Dump
+	options.ExcludeTables = dp.Excludes()
+	options.SkipTables = dp.Skipped()
+	options.OutputFile = in.OutputFile
+	options.Masked = nil
+	for _, table := range dp.Tables {
+		if table.Native {
+			options.Masked = append(options.Masked, database.MaskedTable{Table: table.Name})
+		}
+		if table.CopyOf != "" {
+			options.Copies = append(options.Copies, database.TableCopy{Table: table.Name, From: table.CopyOf})
+		}
+	}
+	dp.Commands = database.NewDumper(&options).Commands()
+
+	return dp
+}
+
+// Excludes returns the tables whose data is excluded, in table order
+func (dp *DumpPlan) Excludes() []string {
+	return dp.names(plan.DispositionStructureOnly)
+}
+
+// Skipped returns the tables left out entirely, in table order
+func (dp *DumpPlan) Skipped() []string {
+	return dp.names(plan.DispositionSkipped)
+}
+
+// Unstreamed returns the tables whose data mysqldump's data phase leaves
+// out: data-excluded tables, and those read by dbdump or copied
+func (dp *DumpPlan) Unstreamed() []string {
+	var names []string
+	for _, table := range dp.Tables {
+		if table.Disposition == plan.DispositionStructureOnly || table.Native || table.CopyOf != "" {
+			names = append(names, table.Name)
+		}
+	}
+	return names
+}
+
+// Estimates returns the expected data size of each table mysqldump dumps
+// in full, for its progress
+func (dp *DumpPlan) Estimates() map[string]int64 {
+	estimates := make(map[string]int64, len(dp.Tables))
+	for _, table := range dp.Tables {
+		if table.Disposition == plan.DispositionFull && !table.Native && table.CopyOf == "" {
+			estimates[table.Name] = table.DataSize
+		}
+	}
+	return estimates
+}
+
+// Count returns how many tables have a disposition
+func (dp *DumpPlan) Count(disposition string) int {
+	return len(dp.names(disposition))
+}
+
+// names returns the tables with a disposition
+func (dp *DumpPlan) names(disposition string) []string {
+	var names []string
+	for _, table := range dp.Tables {
+		if table.Disposition == disposition {
+			names = append(names, table.Name)
+		}
+	}
+	return names
+}
+
+// NameFormat is how the timestamp of generated dump file names is written
+type NameFormat struct {
+	Layout string // a Go time layout
+	UTC    bool   // UTC instead of local time
+}
+
+// Timestamp layouts known by name in filename_timestamp.format
+const (
+	LegacyLayout       = "20060102_150405"
+	ISO8601BasicLayout = "20060102T150405Z0700"
+)
+
+// DefaultNameFormat is the local-time legacy format
+var DefaultNameFormat = NameFormat{Layout: LegacyLayout}
+
+// ParseNameFormat reads filename_timestamp: zone is local or utc, format
+// is legacy, iso8601-basic or a Go layout; empty values keep the default
+func ParseNameFormat(zone, format string) (NameFormat, error) {
+	nameFormat := DefaultNameFormat
+	switch strings.ToLower(zone) {
+	case "", "local":
+	case "utc":
+		nameFormat.UTC = true
+	default:
+		return nameFormat, fmt.Errorf("zone must be local or utc, got %q", zone)
+	}
+
+	switch strings.ToLower(format) {
+	case "", "legacy":
+	case "iso8601-basic":
+		nameFormat.Layout = ISO8601BasicLayout
+	default:
+		// A layout without a date is written out literally: every dump
+		// would get the same name
+		sample := time.Date(2024, 10, 28, 12, 4, 5, 0, time.UTC)
+		if !strings.Contains(sample.Format(format), "2024") {
+			return nameFormat, fmt.Errorf("format %q is not a time layout with a year (e.g. 2006-01-02_150405)", format)
+		}
+		if strings.ContainsAny(format, `/\:`) {
+			return nameFormat, fmt.Errorf("format %q would put / \\ or : in file names", format)
+		}
+		nameFormat.Layout = format
+	}
+	return nameFormat, nil
+}
+
+// Timestamp writes now in the format
+func (f NameFormat) Timestamp(now time.Time) string {
+	if f.UTC {
+		now = now.UTC()
+	} else {
+		now = now.Local()
+	}
+	return now.Format(f.Layout)
+}
+
+// Kinds of dump whose generated file names say so
+const (
+	KindDelta     = "delta"
+	KindIncrement = "increment"
+)
+
+// OutputKind returns the kind of dump named in generated file names: a
+// schema delta, an append increment, or "" for a dump
+func OutputKind(schemaDelta, increment bool) string {
+	switch {
+	case schemaDelta:
+		return KindDelta
+	case increment:
+		return KindIncrement
+	}
+	return ""
+}
+
+// OutputName returns the generated dump file name in dir: the database
+// and the time, with the OutputKind in between
+func OutputName(dir, database, kind string, format NameFormat, now time.Time) string {
+	timestamp := format.Timestamp(now)
+	name := fmt.Sprintf("%s_%s.sql", database, timestamp)
+	if kind != "" {
+		name = fmt.Sprintf("%s_%s_%s.sql", database, kind, timestamp)
+	}
+	return filepath.Join(dir, name)
+}
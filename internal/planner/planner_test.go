@@ -0,0 +1,422 @@
+package planner
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/plan"
+	"github.com/helgesverre/dbdump/internal/structure"
+)
+
+// shopConnection is the connection plans are made for; its password must
+// never reach a command
+func shopConnection() *database.Connection {
+	return &database.Connection{Host: "db", Port: 3306, User: "app", Password: "hunter2", Database: "shop"}
+}
+
+// planShop selects from shop with rules and plans the dump with in
+func planShop(t *testing.T, rules Rules, in Input) *DumpPlan {
+	t.Helper()
+	p := &Planner{Rules: rules}
+	sel, err := p.Select(shop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.Selection = sel
+	if in.Excludes == nil {
+		in.Excludes = sel.Excludes()
+	}
+	if in.OutputFile == "" {
+		in.OutputFile = "/dumps/shop.sql"
+	}
+	in.Dump.Connection = shopConnection()
+	return p.Plan(in)
+}
+
+// dispositions returns each table's disposition
+func dispositions(dp *DumpPlan) map[string]string {
+	got := make(map[string]string, len(dp.Tables))
+	for _, table := range dp.Tables {
+		got[table.Name] = table.Disposition
+	}
+	return got
+}
+
+// table returns the planned table with a name
+func table(t *testing.T, dp *DumpPlan, name string) Table {
+	t.Helper()
+	for _, planned := range dp.Tables {
+		if planned.Name == name {
+			return planned
+		}
+	}
+	t.Fatalf("%s is not in the plan", name)
+	return Table{}
+}
+
+func TestPlanDispositions(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    Rules
+		excludes []string // nil for the rules' own
+		want     map[string]string
+	}{
+		{
+			name:  "rules only",
+			rules: Rules{Data: exclude([]string{"sessions"}, "cache_*"), SkipEngines: []string{"BLACKHOLE"}},
+			want: map[string]string{
+				"users": plan.DispositionFull, "orders": plan.DispositionFull, "order_items": plan.DispositionFull,
+				"audit_log": plan.DispositionFull, "cache_pages": plan.DispositionStructureOnly,
+				"sessions": plan.DispositionStructureOnly, "hits": plan.DispositionStructureOnly,
+				"sink": plan.DispositionSkipped, "user_totals": plan.DispositionFull,
+			},
+		},
+		{
+			name:     "the final choice overrides the rules",
+			rules:    Rules{Data: exclude([]string{"sessions"}, "cache_*")},
+			excludes: []string{"audit_log"},
+			want: map[string]string{
+				"users": plan.DispositionFull, "orders": plan.DispositionFull, "order_items": plan.DispositionFull,
+				"audit_log": plan.DispositionStructureOnly, "cache_pages": plan.DispositionFull,
+				"sessions": plan.DispositionFull, "hits": plan.DispositionFull,
+				"sink": plan.DispositionFull, "user_totals": plan.DispositionFull,
+			},
+		},
+		{
+			name:     "skipping wins over excluding",
+			rules:    Rules{Only: config.ExcludeConfig{Patterns: []string{"order*"}}},
+			excludes: []string{"orders", "users"},
+			want: map[string]string{
+				"users": plan.DispositionSkipped, "orders": plan.DispositionStructureOnly, "order_items": plan.DispositionFull,
+				"audit_log": plan.DispositionSkipped, "cache_pages": plan.DispositionSkipped,
+				"sessions": plan.DispositionSkipped, "hits": plan.DispositionSkipped,
+				"sink": plan.DispositionSkipped, "user_totals": plan.DispositionSkipped,
+			},
+		},
+		{
+			name:  "engines keeping the structure",
+			rules: Rules{SkipEngines: []string{"BLACKHOLE", "MEMORY"}, KeepEngineStructure: true},
+			want: map[string]string{
+				"users": plan.DispositionFull, "orders": plan.DispositionFull, "order_items": plan.DispositionFull,
+				"audit_log": plan.DispositionFull, "cache_pages": plan.DispositionFull,
+				"sessions": plan.DispositionFull, "hits": plan.DispositionStructureOnly,
+				"sink": plan.DispositionStructureOnly, "user_totals": plan.DispositionFull,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dp := planShop(t, tt.rules, Input{Excludes: tt.excludes})
+			if got := dispositions(dp); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dispositions =\n %v\nwant\n %v", got, tt.want)
+			}
+			// Tables stay in the database's order
+			if got := names(tableInfos(dp)); !reflect.DeepEqual(got, names(shop())) {
+				t.Errorf("tables in order %v", got)
+			}
+			if got, want := len(dp.Excludes())+len(dp.Skipped())+dp.Count(plan.DispositionFull), len(shop()); got != want {
+				t.Errorf("dispositions cover %d tables, want %d", got, want)
+			}
+		})
+	}
+}
+
+// tableInfos returns the table information of the planned tables
+func tableInfos(dp *DumpPlan) []database.TableInfo {
+	var infos []database.TableInfo
+	for _, table := range dp.Tables {
+		infos = append(infos, table.TableInfo)
+	}
+	return infos
+}
+
+func TestPlanTables(t *testing.T) {
+	rules := Rules{
+		Data: exclude([]string{"audit_log", "sessions"}),
+		Samples: SampleRules{
+			{Matcher: exclude([]string{"audit_log"}), Rows: 100},
+			{Matcher: exclude([]string{"sessions"}, "audit_*"), Rows: 5}, // later rules lose
+		},
+		Structure: StructureRules{
+			{Matcher: exclude([]string{"sessions"}), Level: structure.Minimal},
+			{Matcher: exclude(nil, "*"), Level: structure.NoIndexes},
+		},
+	}
+	dp := planShop(t, rules, Input{
+		Masked: map[string][]string{"users": {"email"}, "audit_log": {"ip"}},
+		Nulled: map[string]map[string]string{"orders": {"notes": "''"}},
+		Copies: map[string]string{"order_items": "shop_eu"},
+		Native: []string{"cache_pages"},
+	})
+
+	users := table(t, dp, "users")
+	if !users.Native || !reflect.DeepEqual(users.Masked, []string{"email"}) {
+		t.Errorf("users = %+v, want masked email and read by dbdump", users)
+	}
+	if orders := table(t, dp, "orders"); !orders.Native || orders.Nulled["notes"] != "''" {
+		t.Errorf("orders = %+v, want notes nulled and read by dbdump", orders)
+	}
+	if items := table(t, dp, "order_items"); items.CopyOf != "shop_eu" || items.Native {
+		t.Errorf("order_items = %+v, want a copy of shop_eu", items)
+	}
+	if cache := table(t, dp, "cache_pages"); !cache.Native || cache.Masked != nil {
+		t.Errorf("cache_pages = %+v, want read by dbdump without masks", cache)
+	}
+
+	// Data-excluded tables get their sample and structure, and keep
+	// their masks for the sample
+	audit := table(t, dp, "audit_log")
+	if audit.SampleRows != 100 || audit.Structure != structure.NoIndexes || audit.Native || !reflect.DeepEqual(audit.Masked, []string{"ip"}) {
+		t.Errorf("audit_log = %+v, want 100 rows sampled, no-indexes, masked ip", audit)
+	}
+	sessions := table(t, dp, "sessions")
+	if sessions.SampleRows != 5 || sessions.Structure != structure.Minimal {
+		t.Errorf("sessions = %+v, want 5 rows sampled, minimal", sessions)
+	}
+	if !reflect.DeepEqual(dp.Samples, map[string]int{"audit_log": 100, "sessions": 5}) {
+		t.Errorf("Samples = %v", dp.Samples)
+	}
+	if !reflect.DeepEqual(dp.Levels, map[string]structure.Level{"audit_log": structure.NoIndexes, "sessions": structure.Minimal, "hits": structure.NoIndexes}) {
+		t.Errorf("Levels = %v", dp.Levels)
+	}
+	if users.SampleRows != 0 || users.Structure != "" {
+		t.Errorf("a fully dumped table is sampled or reduced: %+v", users)
+	}
+
+	// mysqldump's data phase leaves out what it doesn't stream
+	if got, want := dp.Unstreamed(), []string{"users", "orders", "order_items", "audit_log", "cache_pages", "sessions", "hits"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unstreamed = %v, want %v", got, want)
+	}
+	if got, want := dp.Estimates(), map[string]int64{"sink": 0, "user_totals": 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Estimates = %v, want %v", got, want)
+	}
+}
+
+func TestPlanReviewedLevels(t *testing.T) {
+	// A reviewed plan's levels are used as they are
+	levels := map[string]structure.Level{"sessions": structure.Minimal}
+	dp := planShop(t, Rules{
+		Data:      exclude([]string{"sessions", "audit_log"}),
+		Structure: StructureRules{{Matcher: exclude(nil, "*"), Level: structure.NoIndexes}},
+		Levels:    levels,
+	}, Input{})
+	if !reflect.DeepEqual(dp.Levels, levels) || table(t, dp, "audit_log").Structure != "" {
+		t.Errorf("Levels = %v, audit_log %q; want the reviewed levels", dp.Levels, table(t, dp, "audit_log").Structure)
+	}
+}
+
+func TestPlanStructureFull(t *testing.T) {
+	// A full level ends the search without reducing the table
+	dp := planShop(t, Rules{
+		Data: exclude([]string{"sessions", "audit_log"}),
+		Structure: StructureRules{
+			{Matcher: exclude([]string{"sessions"}), Level: structure.Full},
+			{Matcher: exclude(nil, "*"), Level: structure.Minimal},
+		},
+	}, Input{})
+	if !reflect.DeepEqual(dp.Levels, map[string]structure.Level{"audit_log": structure.Minimal, "hits": structure.Minimal}) {
+		t.Errorf("Levels = %v", dp.Levels)
+	}
+}
+
+func TestPlanSize(t *testing.T) {
+	rules := Rules{Data: exclude([]string{"sessions"}), SkipEngines: []string{"BLACKHOLE"}}
+	dp := planShop(t, rules, Input{SizesKnown: true})
+	// 8 tables with structure, at 2KiB each, and the data of 5 tables
+	if want := int64(8*2048 + 5*1000); dp.EstimatedSize != want || !dp.SizesKnown {
+		t.Errorf("EstimatedSize = %d, want %d", dp.EstimatedSize, want)
+	}
+	if dp := planShop(t, rules, Input{}); dp.EstimatedSize != 0 || dp.SizesKnown {
+		t.Errorf("estimated %d without sizes", dp.EstimatedSize)
+	}
+}
+
+func TestPlanOutput(t *testing.T) {
+	dp := planShop(t, Rules{}, Input{OutputFile: "/dumps/shop.sql.gz"})
+	if dp.OutputFile != "/dumps/shop.sql.gz" || dp.Parts || dp.Database != "shop" {
+		t.Errorf("OutputFile = %s, Parts = %v, Database = %s", dp.OutputFile, dp.Parts, dp.Database)
+	}
+
+	dp = planShop(t, Rules{}, Input{OutputFile: "/dumps/shop.sql", MaxFileSize: 1 << 30})
+	if dp.OutputFile != dumpfile.PartPath("/dumps/shop.sql", 1) || !dp.Parts || dp.MaxFileSize != 1<<30 {
+		t.Errorf("split dump: OutputFile = %s, Parts = %v", dp.OutputFile, dp.Parts)
+	}
+
+	include := exclude(nil).WithIncludes(config.ExcludeConfig{Exact: []string{"users"}})
+	if dp := planShop(t, Rules{Data: include}, Input{}); !dp.IncludeMode {
+		t.Error("IncludeMode not set with include rules")
+	}
+}
+
+func TestPlanCommands(t *testing.T) {
+	dp := planShop(t, Rules{
+		Data:        exclude([]string{"sessions"}),
+		SkipEngines: []string{"BLACKHOLE"},
+	}, Input{
+		Masked: map[string][]string{"users": {"email"}},
+		Copies: map[string]string{"order_items": "shop_eu"},
+		Native: []string{"cache_pages"},
+	})
+	if len(dp.Commands) != 3 {
+		t.Fatalf("%d commands, want structure, data and objects", len(dp.Commands))
+	}
+
+	ignored := func(phase string) []string {
+		var tables []string
+		for _, command := range dp.Commands {
+			if command.Phase != phase {
+				continue
+			}
+			for _, arg := range command.Args {
+				if table, ok := strings.CutPrefix(arg, "--ignore-table=shop."); ok {
+					tables = append(tables, table)
+				}
+			}
+		}
+		slices.Sort(tables)
+		return tables
+	}
+	if got := ignored("structure"); !reflect.DeepEqual(got, []string{"sink"}) {
+		t.Errorf("structure phase ignores %v, want the skipped table", got)
+	}
+	if got := ignored("objects"); !reflect.DeepEqual(got, []string{"sink"}) {
+		t.Errorf("objects phase ignores %v, want the skipped table", got)
+	}
+	if got, want := ignored("data"), []string{"cache_pages", "hits", "order_items", "sessions", "sink", "users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("data phase ignores %v, want %v", got, want)
+	}
+
+	for _, command := range dp.Commands {
+		for _, arg := range command.Args {
+			if strings.Contains(arg, "hunter2") {
+				t.Errorf("%s command holds the password: %q", command.Phase, command.Args)
+			}
+		}
+	}
+
+	native := &Planner{}
+	sel, err := native.Select(shop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	planned := native.Plan(Input{Selection: sel, OutputFile: "/dumps/shop.sql", Dump: database.DumpOptions{Connection: shopConnection(), Native: true}})
+	if planned.Commands != nil {
+		t.Errorf("a native dump runs mysqldump: %v", planned.Commands)
+	}
+}
+
+func TestPlanRules(t *testing.T) {
+	// Each table's rule and confidence come from the selection
+	dp := planShop(t, Rules{
+		Data: exclude(nil, "cache_*").WithConfidence(map[string]config.Confidence{"cache_*": config.ConfidenceLow}),
+	}, Input{})
+	cache := table(t, dp, "cache_pages")
+	if cache.Rule != "matches exclusion rule cache_*, low confidence" || cache.Confidence != config.ConfidenceLow {
+		t.Errorf("cache_pages: rule %q, confidence %q", cache.Rule, cache.Confidence)
+	}
+	if users := table(t, dp, "users"); users.Rule != "" || users.Confidence != "" {
+		t.Errorf("users: rule %q, confidence %q; want none", users.Rule, users.Confidence)
+	}
+}
+
+func TestParseNameFormat(t *testing.T) {
+	tests := []struct {
+		zone, format string
+		want         NameFormat
+		wantErr      string
+	}{
+		{want: DefaultNameFormat},
+		{zone: "LOCAL", format: "Legacy", want: DefaultNameFormat},
+		{zone: "utc", want: NameFormat{Layout: LegacyLayout, UTC: true}},
+		{format: "iso8601-basic", want: NameFormat{Layout: ISO8601BasicLayout}},
+		{format: "2006-01-02_150405", want: NameFormat{Layout: "2006-01-02_150405"}},
+		{zone: "Europe/Oslo", wantErr: "zone must be local or utc"},
+		{format: "backup", wantErr: "not a time layout with a year"},
+		{format: "01-02_1504", wantErr: "not a time layout with a year"},
+		{format: "2006/01/02", wantErr: "would put / \\ or : in file names"},
+		{format: "2006-01-02T15:04", wantErr: "would put / \\ or : in file names"},
+	}
+	for _, tt := range tests {
+		got, err := ParseNameFormat(tt.zone, tt.format)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseNameFormat(%q, %q) error = %v, want %q", tt.zone, tt.format, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseNameFormat(%q, %q) = %+v, %v; want %+v", tt.zone, tt.format, got, err, tt.want)
+		}
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	oslo := time.FixedZone("CEST", 2*60*60)
+	now := time.Date(2024, 10, 28, 1, 4, 5, 0, oslo)
+	utc := NameFormat{Layout: LegacyLayout, UTC: true}
+
+	tests := []struct {
+		name   string
+		kind   string
+		format NameFormat
+		want   string
+	}{
+		{"dump", OutputKind(false, false), utc, "/dumps/shop_20241027_230405.sql"},
+		{"delta", OutputKind(true, false), utc, "/dumps/shop_delta_20241027_230405.sql"},
+		{"increment", OutputKind(false, true), utc, "/dumps/shop_increment_20241027_230405.sql"},
+		{"iso8601-basic", "", NameFormat{Layout: ISO8601BasicLayout, UTC: true}, "/dumps/shop_20241027T230405Z.sql"},
+		{"custom layout", "", NameFormat{Layout: "2006-01-02_150405", UTC: true}, "/dumps/shop_2024-10-27_230405.sql"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OutputName("/dumps", "shop", tt.kind, tt.format, now); got != tt.want {
+				t.Errorf("OutputName = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// A schema delta is never an increment
+	if OutputKind(true, true) != KindDelta {
+		t.Errorf("OutputKind(delta, increment) = %q", OutputKind(true, true))
+	}
+	// Local time is the machine's
+	if got, want := DefaultNameFormat.Timestamp(now), now.Local().Format(LegacyLayout); got != want {
+		t.Errorf("local Timestamp = %s, want %s", got, want)
+	}
+}
+
+// TestOutputNameSortsAcrossDST checks that UTC file names sort in the order
+// the dumps were made through the hour repeated when the clocks go back,
+// where local-time names don't
+func TestOutputNameSortsAcrossDST(t *testing.T) {
+	cest, cet := time.FixedZone("CEST", 2*60*60), time.FixedZone("CET", 60*60)
+	times := []time.Time{
+		time.Date(2026, 10, 25, 2, 30, 0, 0, cest),
+		time.Date(2026, 10, 25, 2, 10, 0, 0, cet),
+		time.Date(2026, 10, 25, 2, 40, 0, 0, cet),
+	}
+	for _, format := range []NameFormat{{Layout: LegacyLayout, UTC: true}, {Layout: ISO8601BasicLayout, UTC: true}} {
+		var names []string
+		for _, created := range times {
+			names = append(names, OutputName("/dumps", "shop", "", format, created))
+		}
+		if !slices.IsSorted(names) {
+			t.Errorf("%s names out of order: %v", format.Layout, names)
+		}
+	}
+
+	// The same dumps named by their wall clocks
+	var wallClock []string
+	for _, created := range times {
+		wallClock = append(wallClock, created.Format(LegacyLayout))
+	}
+	if slices.IsSorted(wallClock) {
+		t.Errorf("local names %v sort in order; the test proves nothing", wallClock)
+	}
+}
@@ -0,0 +1,191 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+	"github.com/helgesverre/dbdump/internal/structure"
+)
+
+// Rules are the selection rules of a dump, merged from the defaults, the
+// global and project configs and the command line
+type Rules struct {
+	// Data matches the tables whose data is excluded: the exclusion rules,
+	// inverted by the include rules when there are any
+	Data *patterns.Matcher
+
+	// Only selects the tables that are dumped at all; empty when every
+	// table is. Args are the table arguments (names or globs), which
+	// select tables the same way.
+	Only config.ExcludeConfig
+	Args []string
+
+	// Tables named by --exclude, --only and --include, checked against the
+	// other rules and the table list
+	ExcludeNames []string
+	OnlyNames    []string
+	IncludeNames []string
+
+	// SkipEngines skips tables with these storage engines entirely, or only
+	// their data with KeepEngineStructure
+	SkipEngines         []string
+	KeepEngineStructure bool
+
+	// Samples keep the last rows of data-excluded tables
+	Samples SampleRules
+
+	// Structure reduces the definitions of data-excluded tables; Levels,
+	// when set, are used instead (a reviewed plan's)
+	Structure StructureRules
+	Levels    map[string]structure.Level
+
+	// Origins names the config or flag each exclude and include rule came
+	// from, for the reasons Explain gives
+	Origins config.Origins
+
+	// Case is how the server compares table names; rules and table
+	// arguments match case-insensitively where it does
+	Case sqlident.Case
+}
+
+// SampleRule is a validated sample entry: data-excluded tables matching it
+// keep their last Rows rows
+type SampleRule struct {
+	Matcher *patterns.Matcher
+	Rows    int
+}
+
+// SampleRules are the sample rules in precedence order
+type SampleRules []SampleRule
+
+// Rows returns how many rows of a table are sampled, or 0
+func (rules SampleRules) Rows(table string) int {
+	for _, rule := range rules {
+		if rule.Matcher.Matches(table) {
+			return rule.Rows
+		}
+	}
+	return 0
+}
+
+// Sampled returns the tables with a sample rule
+func (rules SampleRules) Sampled(tables []database.TableInfo) []string {
+	var names []string
+	for _, info := range tables {
+		if rules.Rows(info.Name) > 0 {
+			names = append(names, info.Name)
+		}
+	}
+	return names
+}
+
+// Counts returns the sampled row count of each data-excluded table with a
+// sample rule
+func (rules SampleRules) Counts(excludes []string) map[string]int {
+	counts := make(map[string]int)
+	for _, table := range excludes {
+		if rows := rules.Rows(table); rows > 0 {
+			counts[table] = rows
+		}
+	}
+	return counts
+}
+
+// StructureRule is a validated structure_rules entry
+type StructureRule struct {
+	Matcher *patterns.Matcher
+	Level   structure.Level
+}
+
+// StructureRules are the structure rules in precedence order
+type StructureRules []StructureRule
+
+// Levels returns the structure level of each data-excluded table that a
+// rule reduces below full
+func (rules StructureRules) Levels(excludes []string) map[string]structure.Level {
+	if len(rules) == 0 {
+		return nil
+	}
+	levels := make(map[string]structure.Level)
+	for _, table := range excludes {
+		for _, rule := range rules {
+			if rule.Matcher.Matches(table) {
+				if rule.Level != structure.Full {
+					levels[table] = rule.Level
+				}
+				break
+			}
+		}
+	}
+	return levels
+}
+
+// engineNotices explains why tables with these storage engines need attention
+var engineNotices = map[string]string{
+	"MEMORY":    "MEMORY table, contents are lost on every server restart",
+	"BLACKHOLE": "BLACKHOLE table, always empty",
+	"FEDERATED": "FEDERATED table, reading it queries a remote server",
+}
+
+// EngineRules is the outcome of applying engine rules to the selected tables
+type EngineRules struct {
+	// Skipped tables are left out entirely; DataExcluded tables are dumped
+	// structure-only (--skip-engines-keep-structure)
+	Skipped      []string
+	DataExcluded []string
+
+	// Preselected tables are suggested for data exclusion but can be deselected
+	Preselected []string
+
+	// Reasons explains per table why it was skipped or excluded
+	Reasons map[string]string
+}
+
+// applyEngineRules notes tables with problematic engines, pre-selects
+// MEMORY tables for data exclusion and skips tables whose engine is listed
+// in skipEngines. Tables named in explicit (e.g. --only) are left alone.
+func applyEngineRules(tablesInfo []database.TableInfo, skipEngines []string, keepStructure bool, explicit map[string]bool, sel *Selection) EngineRules {
+	rules := EngineRules{Reasons: make(map[string]string)}
+
+	skip := make(map[string]bool, len(skipEngines))
+	for _, engine := range skipEngines {
+		skip[strings.ToUpper(strings.TrimSpace(engine))] = true
+	}
+
+	for _, info := range tablesInfo {
+		engine := strings.ToUpper(info.Engine)
+		notice, noteworthy := engineNotices[engine]
+		if !noteworthy && !skip[engine] {
+			continue
+		}
+		if notice == "" {
+			notice = engine + " table"
+		}
+
+		if explicit[info.Name] {
+			sel.warn(fmt.Sprintf("%s: %s (included because it was selected explicitly)", info.Name, notice))
+			continue
+		}
+
+		switch {
+		case skip[engine] && !keepStructure:
+			rules.Skipped = append(rules.Skipped, info.Name)
+			rules.Reasons[info.Name] = notice + ", skipped by --skip-engines"
+		case skip[engine]:
+			rules.DataExcluded = append(rules.DataExcluded, info.Name)
+			rules.Reasons[info.Name] = notice + ", data skipped by --skip-engines"
+		case engine == "MEMORY":
+			rules.Preselected = append(rules.Preselected, info.Name)
+			rules.Reasons[info.Name] = notice
+		default:
+			rules.Reasons[info.Name] = notice
+		}
+		sel.warn(fmt.Sprintf("%s: %s", info.Name, rules.Reasons[info.Name]))
+	}
+
+	return rules
+}
@@ -0,0 +1,140 @@
+package planner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+func TestApplyEngineRules(t *testing.T) {
+	tables := []database.TableInfo{
+		{Name: "users", Engine: "InnoDB"},
+		{Name: "hits", Engine: "MEMORY"},
+		{Name: "sink", Engine: "BLACKHOLE"},
+		{Name: "remote_orders", Engine: "FEDERATED"},
+		{Name: "old_logs", Engine: "ARCHIVE"},
+		{Name: "lowercase_hits", Engine: "memory"},
+		{Name: "user_totals"}, // a view
+	}
+	memory := "MEMORY table, contents are lost on every server restart"
+	blackhole := "BLACKHOLE table, always empty"
+	federated := "FEDERATED table, reading it queries a remote server"
+
+	tests := []struct {
+		name          string
+		skip          []string
+		keepStructure bool
+		explicit      map[string]bool
+		want          EngineRules
+		warnings      []string
+	}{
+		{
+			name: "notices only",
+			want: EngineRules{
+				Preselected: []string{"hits", "lowercase_hits"},
+				Reasons: map[string]string{
+					"hits": memory, "sink": blackhole, "remote_orders": federated, "lowercase_hits": memory,
+				},
+			},
+			warnings: []string{
+				"hits: " + memory,
+				"sink: " + blackhole,
+				"remote_orders: " + federated,
+				"lowercase_hits: " + memory,
+			},
+		},
+		{
+			name: "skipped",
+			skip: []string{"federated", " BLACKHOLE ", "archive"},
+			want: EngineRules{
+				Skipped:     []string{"sink", "remote_orders", "old_logs"},
+				Preselected: []string{"hits", "lowercase_hits"},
+				Reasons: map[string]string{
+					"hits":           memory,
+					"sink":           blackhole + ", skipped by --skip-engines",
+					"remote_orders":  federated + ", skipped by --skip-engines",
+					"old_logs":       "ARCHIVE table, skipped by --skip-engines",
+					"lowercase_hits": memory,
+				},
+			},
+			warnings: []string{
+				"hits: " + memory,
+				"sink: " + blackhole + ", skipped by --skip-engines",
+				"remote_orders: " + federated + ", skipped by --skip-engines",
+				"old_logs: ARCHIVE table, skipped by --skip-engines",
+				"lowercase_hits: " + memory,
+			},
+		},
+		{
+			name:          "structure kept",
+			skip:          []string{"MEMORY", "FEDERATED"},
+			keepStructure: true,
+			want: EngineRules{
+				DataExcluded: []string{"hits", "remote_orders", "lowercase_hits"},
+				Reasons: map[string]string{
+					"hits":           memory + ", data skipped by --skip-engines",
+					"sink":           blackhole,
+					"remote_orders":  federated + ", data skipped by --skip-engines",
+					"lowercase_hits": memory + ", data skipped by --skip-engines",
+				},
+			},
+			warnings: []string{
+				"hits: " + memory + ", data skipped by --skip-engines",
+				"sink: " + blackhole,
+				"remote_orders: " + federated + ", data skipped by --skip-engines",
+				"lowercase_hits: " + memory + ", data skipped by --skip-engines",
+			},
+		},
+		{
+			// Tables named explicitly are dumped whatever their engine
+			name:     "explicit tables",
+			skip:     []string{"FEDERATED"},
+			explicit: map[string]bool{"hits": true, "remote_orders": true},
+			want: EngineRules{
+				Preselected: []string{"lowercase_hits"},
+				Reasons:     map[string]string{"sink": blackhole, "lowercase_hits": memory},
+			},
+			warnings: []string{
+				"hits: " + memory + " (included because it was selected explicitly)",
+				"sink: " + blackhole,
+				"remote_orders: " + federated + " (included because it was selected explicitly)",
+				"lowercase_hits: " + memory,
+			},
+		},
+		{
+			name: "any engine can be skipped",
+			skip: []string{"InnoDB"},
+			want: EngineRules{
+				Skipped:     []string{"users"},
+				Preselected: []string{"hits", "lowercase_hits"},
+				Reasons: map[string]string{
+					"users": "INNODB table, skipped by --skip-engines",
+					"hits":  memory, "sink": blackhole, "remote_orders": federated, "lowercase_hits": memory,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel := &Selection{}
+			got := applyEngineRules(tables, tt.skip, tt.keepStructure, tt.explicit, sel)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyEngineRules() = %+v\nwant %+v", got, tt.want)
+			}
+			if tt.warnings == nil {
+				return
+			}
+			var warnings []string
+			for _, notice := range sel.Notices {
+				if notice.Warning {
+					warnings = append(warnings, notice.Text)
+				}
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("warnings = %q\nwant %q", warnings, tt.warnings)
+			}
+		})
+	}
+}
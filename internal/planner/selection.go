@@ -0,0 +1,419 @@
+package planner
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// Notice is a message about the selection for the user, which the caller
+// prints
+type Notice struct {
+	Warning bool
+	Text    string
+}
+
+// Selection is the result of applying only, engine and exclusion rules to
+// the live table list
+type Selection struct {
+	All         []database.TableInfo
+	Tables      []database.TableInfo // tables whose structure is dumped
+	Skipped     []string             // tables skipped entirely
+	PreSelected []string             // tables whose data the rules exclude
+	Data        *patterns.Matcher    // nil when the selection comes from a plan
+	Only        *patterns.Matcher    // nil when every table is eligible
+	Engines     EngineRules
+	Samples     SampleRules // data-excluded tables that keep their last rows
+	Origins     config.Origins
+
+	// Reasons explains why tables are skipped or have data excluded, where
+	// no rule in Data does (engines, a plan)
+	Reasons map[string]string
+
+	// Confidence holds the pre-selected tables whose rules are less than
+	// sure their data can go; the others are high
+	Confidence map[string]config.Confidence
+
+	// Notices are what the rules found worth telling, in order
+	Notices []Notice
+}
+
+// note and warn add notices
+func (s *Selection) note(text string) { s.Notices = append(s.Notices, Notice{Text: text}) }
+func (s *Selection) warn(text string) {
+	s.Notices = append(s.Notices, Notice{Warning: true, Text: text})
+}
+
+// Select applies the rules to the tables found in the database. Tables
+// outside the only rules (and table arguments) are skipped entirely, then
+// tables whose engine --skip-engines lists; the data rules apply to the
+// rest, MEMORY tables and tables with a sample rule are pre-selected too.
+func Select(tablesInfo []database.TableInfo, rules Rules) (*Selection, error) {
+	sel := &Selection{All: tablesInfo, Tables: tablesInfo, Data: rules.Data, Samples: rules.Samples, Origins: rules.Origins}
+	if sel.Data == nil {
+		sel.Data = patterns.NewMatcher(config.ExcludeConfig{})
+	}
+	if rules.Case.Folds() {
+		sel.Data = sel.Data.FoldCase()
+	}
+
+	onlyConfig := rules.Only
+	if len(rules.Args) > 0 {
+		positional, err := resolveTableArgs(rules.Args, sel.All, rules.Case)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkArgExcludes(positional, rules.ExcludeNames); err != nil {
+			return nil, err
+		}
+		onlyConfig.Exact = slices.Concat(onlyConfig.Exact, positional)
+	}
+	if !onlyConfig.IsEmpty() {
+		sel.Only = nameMatcher(onlyConfig, rules.Case)
+		sel.Tables, sel.Skipped = splitByOnly(sel.All, sel.Only)
+		if err := checkOnlyExcludes(sel.Skipped, rules.ExcludeNames); err != nil {
+			return nil, err
+		}
+		for _, table := range missing(sel.All, rules.OnlyNames, rules.Case) {
+			sel.warn(fmt.Sprintf("--only table %q does not exist", table))
+		}
+		if len(sel.Tables) == 0 {
+			return nil, &dberrors.ErrConfigInvalid{
+				Source:   "only patterns",
+				Problems: []string{"no tables match the only rules"},
+			}
+		}
+		sel.note(fmt.Sprintf("Only mode: %d tables selected, %d skipped entirely", len(sel.Tables), len(sel.Skipped)))
+	}
+
+	// Storage engines that don't dump well; tables selected by exact name are left alone
+	explicit := make(map[string]bool, len(onlyConfig.Exact))
+	for _, table := range onlyConfig.Exact {
+		explicit[table] = true
+	}
+	sel.Engines = applyEngineRules(sel.Tables, rules.SkipEngines, rules.KeepEngineStructure, explicit, sel)
+	if len(sel.Engines.Skipped) > 0 {
+		sel.Tables = WithoutTables(sel.Tables, sel.Engines.Skipped)
+		sel.Skipped = append(sel.Skipped, sel.Engines.Skipped...)
+	}
+
+	// Match tables against patterns
+	tableNames := make([]string, len(sel.Tables))
+	for i, info := range sel.Tables {
+		tableNames[i] = info.Name
+	}
+	sel.Reasons = sel.Engines.Reasons
+	excluded := sel.Data.FilterTables(tableNames)
+	if sel.Data.Inverted() {
+		for _, table := range missing(sel.All, rules.IncludeNames, rules.Case) {
+			sel.warn(fmt.Sprintf("--include table %q does not exist", table))
+		}
+		sel.note(fmt.Sprintf("Include mode: data of %d tables dumped, %d structure only (exclude rules win over include rules)",
+			len(tableNames)-len(excluded), len(excluded)))
+	}
+	sel.PreSelected = AppendMissing(excluded, sel.Engines.Preselected...)
+	sel.PreSelected = AppendMissing(sel.PreSelected, sel.Samples.Sampled(sel.Tables)...)
+
+	// Engine and sample rules name what they want done; only the
+	// exclusion rules can be less than sure
+	sel.Confidence = make(map[string]config.Confidence)
+	for _, table := range excluded {
+		if level, _ := sel.Data.Confidence(table); level != config.ConfidenceHigh {
+			sel.Confidence[table] = level
+		}
+	}
+	for _, table := range slices.Concat(sel.Engines.Preselected, sel.Samples.Sampled(sel.Tables)) {
+		delete(sel.Confidence, table)
+	}
+
+	return sel, nil
+}
+
+// ConfidenceOf returns how sure the rules are that a pre-selected table's
+// data can be left out
+func (s *Selection) ConfidenceOf(table string) config.Confidence {
+	if level, ok := s.Confidence[table]; ok {
+		return level
+	}
+	return config.ConfidenceHigh
+}
+
+// AtLeast returns the pre-selected tables whose confidence is threshold or
+// stronger, in order
+func (s *Selection) AtLeast(threshold config.Confidence) []string {
+	var tables []string
+	for _, table := range s.PreSelected {
+		if s.ConfidenceOf(table).AtLeast(threshold) {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// Below returns the pre-selected tables whose confidence is under
+// threshold, in order: their data is kept without the selector
+func (s *Selection) Below(threshold config.Confidence) []string {
+	var tables []string
+	for _, table := range s.PreSelected {
+		if !s.ConfidenceOf(table).AtLeast(threshold) {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// Decide returns the tables whose data the dump excludes: those chosen
+// (in the selector, by the auto threshold or in a plan) and those
+// --skip-engines-keep-structure keeps only the structure of
+func (s *Selection) Decide(chosen []string) []string {
+	return AppendMissing(slices.Clone(chosen), s.Engines.DataExcluded...)
+}
+
+// WithConfidence returns the pre-selected tables whose confidence is
+// exactly level, in order
+func (s *Selection) WithConfidence(level config.Confidence) []string {
+	var tables []string
+	for _, table := range s.PreSelected {
+		if s.ConfidenceOf(table) == level {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// Explain says for each table with data excluded or skipped which rule is
+// responsible
+func (s *Selection) Explain() map[string]string {
+	if s.Data == nil {
+		return s.Reasons
+	}
+	reasons := make(map[string]string, len(s.PreSelected))
+	for _, table := range s.PreSelected {
+		// The rule named is the one the table's confidence comes from
+		switch _, rule := s.Data.Confidence(table); rule {
+		case "":
+		case patterns.RuleNotIncluded:
+			reasons[table] = "matches no include rule"
+		case "exact":
+			reasons[table] = "exact exclusion rule" + s.origin("exclude", table)
+		default:
+			reasons[table] = "matches exclusion rule " + rule + s.origin("exclude", rule)
+		}
+		// Exclude rules win over include rules; say so where both match
+		if rule := s.Data.IncludingRule(table); rule != "" && reasons[table] != "" {
+			if rule == "exact" {
+				rule = table
+			}
+			reasons[table] += " (wins over include rule " + rule + s.origin("include", rule) + ")"
+		}
+		if level, ok := s.Confidence[table]; ok && reasons[table] != "" {
+			reasons[table] += fmt.Sprintf(", %s confidence", level)
+		}
+		if rows := s.Samples.Rows(table); rows > 0 {
+			if existing, ok := reasons[table]; ok {
+				reasons[table] = fmt.Sprintf("%s; sampled: last %d rows", existing, rows)
+			} else {
+				reasons[table] = fmt.Sprintf("sampled: last %d rows", rows)
+			}
+		}
+	}
+	for table, reason := range s.Engines.Reasons {
+		if existing, ok := reasons[table]; ok {
+			reasons[table] = existing + "; " + reason
+		} else {
+			reasons[table] = reason
+		}
+	}
+	return reasons
+}
+
+// origin names where a rule came from, as " (from <source>)", or "" if
+// that isn't known
+func (s *Selection) origin(section, rule string) string {
+	if source := s.Origins.Of(section, rule); source != "" {
+		return " (from " + source + ")"
+	}
+	return ""
+}
+
+// Excludes returns the tables whose data the rules exclude, including
+// those --skip-engines-keep-structure keeps the structure of
+func (s *Selection) Excludes() []string {
+	return AppendMissing(append([]string{}, s.PreSelected...), s.Engines.DataExcluded...)
+}
+
+// nameMatcher returns the matcher of rules, comparing names as the server
+// does
+func nameMatcher(rules config.ExcludeConfig, nameCase sqlident.Case) *patterns.Matcher {
+	matcher := patterns.NewMatcher(rules)
+	if nameCase.Folds() {
+		return matcher.FoldCase()
+	}
+	return matcher
+}
+
+// splitByOnly splits tables into those selected by the only matcher and
+// the names of those skipped entirely
+func splitByOnly(tablesInfo []database.TableInfo, matcher *patterns.Matcher) ([]database.TableInfo, []string) {
+	var selected []database.TableInfo
+	var skipped []string
+	for _, info := range tablesInfo {
+		if matcher.Matches(info.Name) {
+			selected = append(selected, info)
+		} else {
+			skipped = append(skipped, info.Name)
+		}
+	}
+
+	return selected, skipped
+}
+
+// checkOnlyExcludes rejects --exclude names that only mode already skips
+// entirely, since the two flags then contradict each other
+func checkOnlyExcludes(skipped, excludeNames []string) error {
+	isSkipped := make(map[string]bool, len(skipped))
+	for _, table := range skipped {
+		isSkipped[table] = true
+	}
+
+	var conflicts []string
+	for _, table := range excludeNames {
+		if isSkipped[table] {
+			conflicts = append(conflicts, table)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return &dberrors.ErrConfigInvalid{
+			Source: "--exclude",
+			Problems: []string{fmt.Sprintf("%s not selected by the only rules and would be skipped entirely; add it to --only to dump its structure, or drop the --exclude",
+				strings.Join(conflicts, ", "))},
+		}
+	}
+
+	return nil
+}
+
+// resolveTableArgs resolves positional table arguments against the live table
+// list, expanding globs and suggesting close matches for unknown names
+func resolveTableArgs(args []string, tablesInfo []database.TableInfo, nameCase sqlident.Case) ([]string, error) {
+	names := make([]string, len(tablesInfo))
+	exists := make(map[string]string, len(tablesInfo))
+	for i, info := range tablesInfo {
+		names[i] = info.Name
+		exists[nameCase.Key(info.Name)] = info.Name
+	}
+
+	var resolved []string
+	var problems []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		var matched []string
+		if patterns.IsPattern(arg) {
+			if err := patterns.Validate(config.ExcludeConfig{Patterns: []string{arg}}, "table arguments"); err != nil {
+				return nil, err
+			}
+			matched = nameMatcher(config.ExcludeConfig{Patterns: []string{arg}}, nameCase).FilterTables(names)
+			if len(matched) == 0 {
+				problems = append(problems, fmt.Sprintf("pattern %q matches no tables", arg))
+			}
+		} else if table, ok := exists[nameCase.Key(arg)]; ok {
+			matched = []string{table}
+		} else if suggestion := patterns.Suggest(arg, names); suggestion != "" {
+			problems = append(problems, fmt.Sprintf("table %q does not exist (did you mean %q?)", arg, suggestion))
+		} else {
+			problems = append(problems, fmt.Sprintf("table %q does not exist", arg))
+		}
+
+		for _, table := range matched {
+			if !seen[table] {
+				seen[table] = true
+				resolved = append(resolved, table)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "table arguments", Problems: problems}
+	}
+
+	return resolved, nil
+}
+
+// checkArgExcludes rejects --exclude names that were also given as a table
+// argument, since it's unclear which one the user meant
+func checkArgExcludes(tables, excludeNames []string) error {
+	selected := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		selected[table] = true
+	}
+
+	var conflicts []string
+	for _, table := range excludeNames {
+		if selected[table] {
+			conflicts = append(conflicts, table)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return &dberrors.ErrConfigInvalid{
+			Source: "--exclude",
+			Problems: []string{fmt.Sprintf("%s given both as a table argument and to --exclude; use --only with --exclude for a structure-only table",
+				strings.Join(conflicts, ", "))},
+		}
+	}
+
+	return nil
+}
+
+// missing returns the names that are not tables of the database
+func missing(tablesInfo []database.TableInfo, names []string, nameCase sqlident.Case) []string {
+	exists := make(map[string]bool, len(tablesInfo))
+	for _, info := range tablesInfo {
+		exists[nameCase.Key(info.Name)] = true
+	}
+	var absent []string
+	for _, name := range names {
+		if !exists[nameCase.Key(name)] {
+			absent = append(absent, name)
+		}
+	}
+	return absent
+}
+
+// WithoutTables returns tablesInfo without the named tables
+func WithoutTables(tablesInfo []database.TableInfo, names []string) []database.TableInfo {
+	remove := make(map[string]bool, len(names))
+	for _, name := range names {
+		remove[name] = true
+	}
+
+	var kept []database.TableInfo
+	for _, info := range tablesInfo {
+		if !remove[info.Name] {
+			kept = append(kept, info)
+		}
+	}
+	return kept
+}
+
+// AppendMissing appends the names not already in list
+func AppendMissing(list []string, names ...string) []string {
+	present := make(map[string]bool, len(list))
+	for _, name := range list {
+		present[name] = true
+	}
+	for _, name := range names {
+		if !present[name] {
+			present[name] = true
+			list = append(list, name)
+		}
+	}
+	return list
+}
@@ -0,0 +1,352 @@
+package planner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/config"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+)
+
+// shop is a synthetic database: InnoDB tables of 1000 bytes each, plus the
+// tables with other engines that engine rules look at
+func shop() []database.TableInfo {
+	var tables []database.TableInfo
+	for _, name := range []string{"users", "orders", "order_items", "audit_log", "cache_pages", "sessions"} {
+		tables = append(tables, database.TableInfo{Name: name, Engine: "InnoDB", DataSize: 1000})
+	}
+	return append(tables,
+		database.TableInfo{Name: "hits", Engine: "MEMORY", DataSize: 500},
+		database.TableInfo{Name: "sink", Engine: "BLACKHOLE"},
+		database.TableInfo{Name: "user_totals"}, // a view
+	)
+}
+
+// exclude returns a data matcher of exact names and patterns
+func exclude(exact []string, patternList ...string) *patterns.Matcher {
+	return patterns.NewMatcher(config.ExcludeConfig{Exact: exact, Patterns: patternList})
+}
+
+// names returns the table names of tables
+func names(tables []database.TableInfo) []string {
+	var list []string
+	for _, info := range tables {
+		list = append(list, info.Name)
+	}
+	return list
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       Rules
+		wantTables  []string // structure dumped
+		wantSkipped []string
+		wantPre     []string // data excluded by the rules
+		wantErr     string
+	}{
+		{
+			name:       "no rules",
+			wantTables: []string{"users", "orders", "order_items", "audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+			wantPre:    []string{"hits"}, // MEMORY tables are always suggested
+		},
+		{
+			name:       "exact names and patterns",
+			rules:      Rules{Data: exclude([]string{"sessions"}, "cache_*", "*_log")},
+			wantTables: []string{"users", "orders", "order_items", "audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+			wantPre:    []string{"audit_log", "cache_pages", "sessions", "hits"},
+		},
+		{
+			name:        "only rules skip the rest entirely",
+			rules:       Rules{Only: config.ExcludeConfig{Patterns: []string{"order*"}}, Data: exclude(nil, "*_items")},
+			wantTables:  []string{"orders", "order_items"},
+			wantSkipped: []string{"users", "audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+			wantPre:     []string{"order_items"},
+		},
+		{
+			name:        "table arguments select like only",
+			rules:       Rules{Args: []string{"users", "order*"}},
+			wantTables:  []string{"users", "orders", "order_items"},
+			wantSkipped: []string{"audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+		},
+		{
+			name:        "table arguments add to the only rules",
+			rules:       Rules{Args: []string{"sessions"}, Only: config.ExcludeConfig{Exact: []string{"users"}}},
+			wantTables:  []string{"users", "sessions"},
+			wantSkipped: []string{"orders", "order_items", "audit_log", "cache_pages", "hits", "sink", "user_totals"},
+		},
+		{
+			name:    "unknown table argument",
+			rules:   Rules{Args: []string{"oders"}},
+			wantErr: `table "oders" does not exist (did you mean "orders"?)`,
+		},
+		{
+			name:    "table argument pattern matching nothing",
+			rules:   Rules{Args: []string{"tmp_*"}},
+			wantErr: `pattern "tmp_*" matches no tables`,
+		},
+		{
+			name:    "table argument also excluded",
+			rules:   Rules{Args: []string{"users"}, ExcludeNames: []string{"users"}},
+			wantErr: "users given both as a table argument and to --exclude",
+		},
+		{
+			name:    "excluded table outside the only rules",
+			rules:   Rules{Only: config.ExcludeConfig{Exact: []string{"users"}}, ExcludeNames: []string{"orders"}},
+			wantErr: "orders not selected by the only rules",
+		},
+		{
+			name:    "only rules matching nothing",
+			rules:   Rules{Only: config.ExcludeConfig{Patterns: []string{"tmp_*"}}},
+			wantErr: "no tables match the only rules",
+		},
+		{
+			name:       "include rules exclude everything else",
+			rules:      Rules{Data: exclude(nil).WithIncludes(config.ExcludeConfig{Patterns: []string{"order*", "users"}})},
+			wantTables: []string{"users", "orders", "order_items", "audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+			wantPre:    []string{"audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+		},
+		{
+			name:       "exclude rules win over include rules",
+			rules:      Rules{Data: exclude([]string{"order_items"}).WithIncludes(config.ExcludeConfig{Patterns: []string{"order*"}})},
+			wantTables: []string{"users", "orders", "order_items", "audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+			wantPre:    []string{"users", "order_items", "audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+		},
+		{
+			name:        "skipped engines",
+			rules:       Rules{SkipEngines: []string{" blackhole ", "memory"}},
+			wantTables:  []string{"users", "orders", "order_items", "audit_log", "cache_pages", "sessions", "user_totals"},
+			wantSkipped: []string{"hits", "sink"},
+		},
+		{
+			name:       "skipped engines keeping the structure",
+			rules:      Rules{SkipEngines: []string{"BLACKHOLE"}, KeepEngineStructure: true},
+			wantTables: []string{"users", "orders", "order_items", "audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+			wantPre:    []string{"hits"}, // sink is in Engines.DataExcluded instead
+		},
+		{
+			name:        "only rules come before engine rules",
+			rules:       Rules{Only: config.ExcludeConfig{Patterns: []string{"s*"}}, SkipEngines: []string{"BLACKHOLE"}},
+			wantTables:  []string{"sessions"},
+			wantSkipped: []string{"users", "orders", "order_items", "audit_log", "cache_pages", "hits", "user_totals", "sink"},
+		},
+		{
+			name:        "tables named explicitly keep their engine",
+			rules:       Rules{Args: []string{"hits", "sink"}, SkipEngines: []string{"BLACKHOLE"}},
+			wantTables:  []string{"hits", "sink"},
+			wantSkipped: []string{"users", "orders", "order_items", "audit_log", "cache_pages", "sessions", "user_totals"},
+		},
+		{
+			name: "sample rules pre-select",
+			rules: Rules{Samples: SampleRules{
+				{Matcher: exclude([]string{"audit_log"}), Rows: 100},
+			}},
+			wantTables: []string{"users", "orders", "order_items", "audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+			wantPre:    []string{"hits", "audit_log"},
+		},
+		{
+			name:        "case-insensitive server",
+			rules:       Rules{Args: []string{"USERS", "Order*"}, Data: exclude([]string{"Order_Items"}), Case: sqlident.CaseLower},
+			wantTables:  []string{"users", "orders", "order_items"},
+			wantSkipped: []string{"audit_log", "cache_pages", "sessions", "hits", "sink", "user_totals"},
+			wantPre:     []string{"order_items"},
+		},
+		{
+			name:    "case-sensitive server",
+			rules:   Rules{Args: []string{"USERS"}},
+			wantErr: `table "USERS" does not exist (did you mean "users"?)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := Select(shop(), tt.rules)
+			if tt.wantErr != "" {
+				var invalid *dberrors.ErrConfigInvalid
+				if !errors.As(err, &invalid) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Select error = %v, want ErrConfigInvalid with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Select: %v", err)
+			}
+			if got := names(sel.Tables); !reflect.DeepEqual(got, tt.wantTables) {
+				t.Errorf("Tables = %v, want %v", got, tt.wantTables)
+			}
+			if !reflect.DeepEqual(sel.Skipped, tt.wantSkipped) {
+				t.Errorf("Skipped = %v, want %v", sel.Skipped, tt.wantSkipped)
+			}
+			if !reflect.DeepEqual(sel.PreSelected, tt.wantPre) {
+				t.Errorf("PreSelected = %v, want %v", sel.PreSelected, tt.wantPre)
+			}
+			if got := names(sel.All); len(got) != len(shop()) {
+				t.Errorf("All lists %d tables, want every table", len(got))
+			}
+		})
+	}
+}
+
+func TestSelectEngines(t *testing.T) {
+	sel, err := Select(shop(), Rules{SkipEngines: []string{"BLACKHOLE"}, KeepEngineStructure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sel.Engines.DataExcluded, []string{"sink"}) || sel.Engines.Skipped != nil {
+		t.Errorf("Engines = %+v, want sink data-excluded", sel.Engines)
+	}
+	if got := sel.Excludes(); !reflect.DeepEqual(got, []string{"hits", "sink"}) {
+		t.Errorf("Excludes = %v", got)
+	}
+	// What is chosen is kept; engine exclusions are always added
+	if got := sel.Decide([]string{"users"}); !reflect.DeepEqual(got, []string{"users", "sink"}) {
+		t.Errorf("Decide = %v", got)
+	}
+	if got := sel.Decide(nil); !reflect.DeepEqual(got, []string{"sink"}) {
+		t.Errorf("Decide(nil) = %v", got)
+	}
+
+	var warnings []string
+	for _, notice := range sel.Notices {
+		if notice.Warning {
+			warnings = append(warnings, notice.Text)
+		}
+	}
+	want := []string{
+		"hits: MEMORY table, contents are lost on every server restart",
+		"sink: BLACKHOLE table, always empty, data skipped by --skip-engines",
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %q, want %q", warnings, want)
+	}
+}
+
+func TestSelectNotices(t *testing.T) {
+	sel, err := Select(shop(), Rules{
+		Only:         config.ExcludeConfig{Exact: []string{"users", "ghost"}},
+		OnlyNames:    []string{"users", "ghost"},
+		Data:         exclude(nil).WithIncludes(config.ExcludeConfig{Exact: []string{"users", "phantom"}}),
+		IncludeNames: []string{"phantom"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Notice{
+		{Warning: true, Text: `--only table "ghost" does not exist`},
+		{Text: "Only mode: 1 tables selected, 8 skipped entirely"},
+		{Warning: true, Text: `--include table "phantom" does not exist`},
+		{Text: "Include mode: data of 1 tables dumped, 0 structure only (exclude rules win over include rules)"},
+	}
+	if !reflect.DeepEqual(sel.Notices, want) {
+		t.Errorf("Notices =\n %+v\nwant\n %+v", sel.Notices, want)
+	}
+}
+
+func TestSelectConfidence(t *testing.T) {
+	data := exclude([]string{"sessions"}, "cache_*", "*_log", "audit_*").WithConfidence(map[string]config.Confidence{
+		"cache_*": config.ConfidenceMedium,
+		"*_log":   config.ConfidenceLow,
+		"audit_*": config.ConfidenceMedium,
+	})
+	sel, err := Select(shop(), Rules{
+		Data:    data,
+		Samples: SampleRules{{Matcher: exclude([]string{"cache_pages"}), Rows: 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		table string
+		want  config.Confidence
+	}{
+		{"sessions", config.ConfidenceHigh},
+		{"audit_log", config.ConfidenceMedium}, // the strongest matching rule counts
+		{"cache_pages", config.ConfidenceHigh}, // a sample rule is sure
+		{"hits", config.ConfidenceHigh},        // so is the MEMORY engine
+		{"users", config.ConfidenceHigh},       // not pre-selected
+	}
+	for _, tt := range tests {
+		if got := sel.ConfidenceOf(tt.table); got != tt.want {
+			t.Errorf("ConfidenceOf(%s) = %s, want %s", tt.table, got, tt.want)
+		}
+	}
+
+	if got := sel.AtLeast(config.ConfidenceHigh); !reflect.DeepEqual(got, []string{"cache_pages", "sessions", "hits"}) {
+		t.Errorf("AtLeast(high) = %v", got)
+	}
+	if got := sel.AtLeast(config.ConfidenceMedium); !reflect.DeepEqual(got, []string{"audit_log", "cache_pages", "sessions", "hits"}) {
+		t.Errorf("AtLeast(medium) = %v", got)
+	}
+	if got := sel.AtLeast(config.ConfidenceLow); !reflect.DeepEqual(got, sel.PreSelected) {
+		t.Errorf("AtLeast(low) = %v, want every pre-selected table", got)
+	}
+	if got := sel.Below(config.ConfidenceHigh); !reflect.DeepEqual(got, []string{"audit_log"}) {
+		t.Errorf("Below(high) = %v", got)
+	}
+	if got := sel.Below(config.ConfidenceLow); got != nil {
+		t.Errorf("Below(low) = %v, want none", got)
+	}
+	if got := sel.WithConfidence(config.ConfidenceMedium); !reflect.DeepEqual(got, []string{"audit_log"}) {
+		t.Errorf("WithConfidence(medium) = %v", got)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	origins := config.Origins{}
+	origins.Add("exclude", config.ExcludeConfig{Exact: []string{"sessions"}}, ".dbdump.yaml")
+	origins.Add("exclude", config.ExcludeConfig{Patterns: []string{"cache_*"}}, "--exclude-pattern")
+	origins.Add("include", config.ExcludeConfig{Patterns: []string{"audit_*"}}, "~/.dbdump.yaml")
+
+	data := exclude([]string{"sessions"}, "cache_*", "*_log").
+		WithConfidence(map[string]config.Confidence{"*_log": config.ConfidenceLow}).
+		WithIncludes(config.ExcludeConfig{Exact: []string{"sessions"}, Patterns: []string{"order*", "audit_*"}})
+	sel, err := Select(shop(), Rules{
+		Data:        data,
+		Origins:     origins,
+		Samples:     SampleRules{{Matcher: exclude([]string{"users"}), Rows: 50}},
+		SkipEngines: []string{"BLACKHOLE"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"sessions":    "exact exclusion rule (from .dbdump.yaml) (wins over include rule sessions)",
+		"cache_pages": "matches exclusion rule cache_* (from --exclude-pattern)",
+		"audit_log":   "matches exclusion rule *_log (wins over include rule audit_* (from ~/.dbdump.yaml)), low confidence",
+		"users":       "matches no include rule; sampled: last 50 rows",
+		"hits":        "matches no include rule; MEMORY table, contents are lost on every server restart",
+		"user_totals": "matches no include rule",
+		"sink":        "BLACKHOLE table, always empty, skipped by --skip-engines",
+	}
+	if got := sel.Explain(); !reflect.DeepEqual(got, want) {
+		for table := range want {
+			if got[table] != want[table] {
+				t.Errorf("%s:\n got %q\nwant %q", table, got[table], want[table])
+			}
+		}
+		for table := range got {
+			if _, ok := want[table]; !ok {
+				t.Errorf("%s: unexpected reason %q", table, got[table])
+			}
+		}
+	}
+}
+
+func TestAppendMissing(t *testing.T) {
+	if got := AppendMissing([]string{"a", "b"}, "b", "c", "c", "a", "d"); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("AppendMissing = %v", got)
+	}
+	if got := AppendMissing(nil); got != nil {
+		t.Errorf("AppendMissing(nil) = %v", got)
+	}
+	if got := names(WithoutTables(shop(), []string{"users", "hits", "absent"})); !reflect.DeepEqual(got,
+		[]string{"orders", "order_items", "audit_log", "cache_pages", "sessions", "sink", "user_totals"}) {
+		t.Errorf("WithoutTables = %v", got)
+	}
+}
@@ -0,0 +1,67 @@
+// Package redact masks passwords and other secrets before they reach output,
+// logs or error messages
+package redact
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Mask replaces a secret in output
+const Mask = "********"
+
+// minSecretLength is the shortest secret that is replaced wherever it
+// appears; shorter ones would mask unrelated text (a password "1" would
+// garble every number)
+const minSecretLength = 3
+
+// dsnPassword matches the password in a go-sql-driver DSN
+// (user:password@tcp(host:port)/db), which the driver echoes in some errors.
+// The driver splits at the last @, so a password may contain @, : and / and
+// runs up to the last @ before tcp( or unix(.
+var dsnPassword = regexp.MustCompile(`([^\s:/@()]*):\S*@(tcp|unix)\(`)
+
+// String masks every occurrence of the secrets and any DSN password in s
+func String(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			s = strings.ReplaceAll(s, secret, Mask)
+		}
+	}
+	return dsnPassword.ReplaceAllString(s, "${1}:"+Mask+"@${2}(")
+}
+
+// Error returns err with the secrets masked in its message. The original
+// error stays available through Unwrap for errors.Is and errors.As.
+func Error(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	if masked := String(message, secrets...); masked != message {
+		return &redactedError{message: masked, err: err}
+	}
+	return err
+}
+
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Value returns Mask for a non-empty secret and "" otherwise, so output
+// still shows whether a secret is set
+func Value(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return Mask
+}
@@ -0,0 +1,113 @@
+package redact
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		secrets []string
+		want    string
+	}{
+		{
+			name: "dsn",
+			in:   "dial app:hunter2@tcp(db:3306)/shop failed",
+			want: "dial app:" + Mask + "@tcp(db:3306)/shop failed",
+		},
+		{
+			name: "password with @",
+			in:   "app:p@ss@word@tcp(db:3306)/shop",
+			want: "app:" + Mask + "@tcp(db:3306)/shop",
+		},
+		{
+			name: "password with : / ( and )",
+			in:   "app:a:b/c(d)e@tcp(db:3306)/shop",
+			want: "app:" + Mask + "@tcp(db:3306)/shop",
+		},
+		{
+			name: "password ending in @tcp",
+			in:   "app:x@tcp@tcp(db:3306)/shop",
+			want: "app:" + Mask + "@tcp(db:3306)/shop",
+		},
+		{
+			name: "unix socket",
+			in:   "root:s3cret@unix(/var/run/mysqld.sock)/shop",
+			want: "root:" + Mask + "@unix(/var/run/mysqld.sock)/shop",
+		},
+		{
+			name: "empty password",
+			in:   "app:@tcp(db:3306)/shop",
+			want: "app:" + Mask + "@tcp(db:3306)/shop",
+		},
+		{
+			name: "empty user",
+			in:   "open :pw@tcp(db)/",
+			want: "open :" + Mask + "@tcp(db)/",
+		},
+		{
+			name: "two dsns",
+			in:   "a:one@tcp(h1)/x then b:two@tcp(h2)/y",
+			want: "a:" + Mask + "@tcp(h1)/x then b:" + Mask + "@tcp(h2)/y",
+		},
+		{
+			name: "text before the dsn keeps its colon",
+			in:   "Error 1045: app:pw@tcp(db:3306)/shop",
+			want: "Error 1045: app:" + Mask + "@tcp(db:3306)/shop",
+		},
+		{
+			name: "no dsn",
+			in:   "user@host:3306/shop, time 12:30",
+			want: "user@host:3306/shop, time 12:30",
+		},
+		{
+			name:    "secrets anywhere",
+			in:      "Access denied for 'app' using password 'hunter2' (hunter2)",
+			secrets: []string{"hunter2"},
+			want:    "Access denied for 'app' using password '" + Mask + "' (" + Mask + ")",
+		},
+		{
+			name:    "short secrets are left",
+			in:      "port 3306, retry 1 of 12",
+			secrets: []string{"1", "12", ""},
+			want:    "port 3306, retry 1 of 12",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := String(tt.in, tt.secrets...); got != tt.want {
+				t.Errorf("String(%q) =\n %q, want\n %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	if Error(nil, "secret") != nil {
+		t.Error("Error(nil) isn't nil")
+	}
+
+	clean := errors.New("connection refused")
+	if Error(clean, "secret") != clean {
+		t.Error("an error without secrets was wrapped")
+	}
+
+	cause := &fs.PathError{Op: "open", Path: "app:secret@tcp(db)/shop", Err: fs.ErrNotExist}
+	err := Error(cause, "secret")
+	if err.Error() != "open app:"+Mask+"@tcp(db)/shop: file does not exist" {
+		t.Errorf("Error() = %q", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || !errors.Is(err, fs.ErrNotExist) {
+		t.Error("the redacted error doesn't unwrap to the original")
+	}
+}
+
+func TestValue(t *testing.T) {
+	if Value("") != "" || Value("x") != Mask {
+		t.Errorf("Value = %q, %q", Value(""), Value("x"))
+	}
+}
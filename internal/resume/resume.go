@@ -0,0 +1,85 @@
+// Package resume keeps the state of a dump in progress next to its output,
+// so a dump that fails after its structure (or a later phase) is complete
+// can be continued with --resume instead of starting over
+package resume
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/fileutil"
+)
+
+// State is what --resume needs to continue a dump: which dump it was, the
+// output kept so far and where it ends, and what the source looked like
+type State struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Database string `json:"database"`
+
+	// OutputFile is the dump being written and Kept the file its output
+	// is in now (OutputFile itself if the process died)
+	OutputFile string `json:"output_file"`
+	Kept       string `json:"kept"`
+
+	// Flags are the dump flags given, which a resumed dump must repeat, and
+	// Excluded and Skipped the tables left without data and left out
+	Flags    []string `json:"flags"`
+	Excluded []string `json:"excluded,omitempty"`
+	Skipped  []string `json:"skipped,omitempty"`
+
+	// SchemaDigest hashes the source's columns when the structure was dumped
+	SchemaDigest string `json:"schema_digest"`
+
+	Checkpoint database.Checkpoint `json:"checkpoint"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+// Path returns the state file of the dumps of a database into dir
+func Path(dir, dbName string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(dbName)
+	return filepath.Join(dir, ".dbdump-resume-"+name+".json")
+}
+
+// Load reads a state file, returning nil if there is none
+func Load(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resume state: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse resume state %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// Write saves a state file
+func Write(path string, state *State) error {
+	state.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal resume state: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write resume state: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes a state file; a missing one is not an error
+func Remove(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove resume state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package retention decides which dumps a prune keeps, per source database,
+// from count and age limits and per-tag rules. A base dump and its
+// increments are decided together, since an increment is useless without
+// the dumps before it.
+package retention
+
+import (
+	"sort"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/tags"
+)
+
+// Dump is a dump known to the prune command
+type Dump struct {
+	Path      string
+	Source    string // identifies the source database; limits apply per source
+	CreatedAt time.Time
+	Tags      map[string]string
+
+	// Chain identifies the chain of increments the dump belongs to (the
+	// base dump's name), empty for a standalone dump
+	Chain string
+}
+
+// TagRule gives dumps carrying all of Tags their own retention: they are
+// kept forever (Keep 0) or the Keep most recent per source are kept
+type TagRule struct {
+	Tags map[string]string
+	Keep int
+}
+
+// Policy is the retention for dumps of one source. A dump not covered by a
+// tag rule is kept if it is among the KeepLast most recent such dumps or
+// younger than KeepWithin (either limit may be 0 for unset).
+type Policy struct {
+	KeepLast   int
+	KeepWithin time.Duration
+	TagRules   []TagRule
+}
+
+// Decision is the outcome for one dump
+type Decision struct {
+	Dump   Dump
+	Keep   bool
+	Reason string
+}
+
+// Apply decides for every dump whether to keep it, newest first per source.
+// The dumps of a chain count as one: the chain is ranked and aged by its
+// newest dump, follows the tag rule of its newest tagged dump, and is kept
+// or deleted as a whole.
+func Apply(dumps []Dump, policy Policy, now time.Time) []Decision {
+	sorted := append([]Dump(nil), dumps...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Source != sorted[j].Source {
+			return sorted[i].Source < sorted[j].Source
+		}
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	type counter struct {
+		source string
+		rule   int // -1 for dumps not covered by a tag rule
+	}
+	seen := make(map[counter]int)
+
+	decisions := make([]Decision, 0, len(sorted))
+	for _, unit := range units(sorted) {
+		rule := -1
+		for _, dump := range unit {
+			if rule = matchingRule(dump, policy.TagRules); rule >= 0 {
+				break
+			}
+		}
+		newest := unit[0]
+		key := counter{source: newest.Source, rule: rule}
+		seen[key]++
+		rank := seen[key]
+
+		decision := Decision{Dump: newest}
+		switch {
+		case rule >= 0 && policy.TagRules[rule].Keep == 0:
+			decision.Keep, decision.Reason = true, "tagged "+tags.Format(policy.TagRules[rule].Tags)+", kept forever"
+		case rule >= 0 && rank <= policy.TagRules[rule].Keep:
+			decision.Keep, decision.Reason = true, "tagged "+tags.Format(policy.TagRules[rule].Tags)+", among the most recent"
+		case rule >= 0:
+			decision.Reason = "tagged " + tags.Format(policy.TagRules[rule].Tags) + ", beyond the tag's limit"
+		case policy.KeepLast > 0 && rank <= policy.KeepLast:
+			decision.Keep, decision.Reason = true, "among the most recent"
+		case policy.KeepWithin > 0 && now.Sub(newest.CreatedAt) < policy.KeepWithin:
+			decision.Keep, decision.Reason = true, "within the retention period"
+		default:
+			decision.Reason = "outside the retention"
+		}
+		if len(unit) > 1 {
+			decision.Reason += ", with its chain"
+		}
+		for _, dump := range unit {
+			decision.Dump = dump
+			decisions = append(decisions, decision)
+		}
+	}
+	return decisions
+}
+
+// units groups dumps sorted newest first per source into what retention
+// decides about: each chain, newest first, and each standalone dump. The
+// units are in the order of their newest dumps.
+func units(sorted []Dump) [][]Dump {
+	type chainKey struct{ source, chain string }
+	chains := make(map[chainKey]int)
+
+	var units [][]Dump
+	for _, dump := range sorted {
+		if dump.Chain == "" {
+			units = append(units, []Dump{dump})
+			continue
+		}
+		key := chainKey{dump.Source, dump.Chain}
+		if i, ok := chains[key]; ok {
+			units[i] = append(units[i], dump)
+			continue
+		}
+		chains[key] = len(units)
+		units = append(units, []Dump{dump})
+	}
+	return units
+}
+
+// matchingRule returns the index of the first tag rule a dump matches, or -1
+func matchingRule(dump Dump, rules []TagRule) int {
+	for i, rule := range rules {
+		if tags.Matches(dump.Tags, rule.Tags) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,287 @@
+package retention
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+
+// daysAgo returns a dump of source shop created n days before now
+func daysAgo(path string, n int) Dump {
+	return Dump{Path: path, Source: "db:3306/shop", CreatedAt: now.AddDate(0, 0, -n)}
+}
+
+// inChain returns the dump as part of the chain based on base
+func inChain(dump Dump, base string) Dump {
+	dump.Chain = base
+	return dump
+}
+
+// kept returns the paths of the kept dumps, in decision order
+func kept(decisions []Decision) []string {
+	var paths []string
+	for _, decision := range decisions {
+		if decision.Keep {
+			paths = append(paths, decision.Dump.Path)
+		}
+	}
+	return paths
+}
+
+func TestApply(t *testing.T) {
+	release := map[string]string{"purpose": "release"}
+	tagged := func(dump Dump) Dump {
+		dump.Tags = release
+		return dump
+	}
+
+	tests := []struct {
+		name   string
+		dumps  []Dump
+		policy Policy
+		want   []string
+	}{
+		{
+			name:   "keep last",
+			dumps:  []Dump{daysAgo("a", 3), daysAgo("b", 1), daysAgo("c", 2)},
+			policy: Policy{KeepLast: 2},
+			want:   []string{"b", "c"},
+		},
+		{
+			name:   "keep within",
+			dumps:  []Dump{daysAgo("a", 3), daysAgo("b", 1), daysAgo("c", 2)},
+			policy: Policy{KeepWithin: 36 * time.Hour},
+			want:   []string{"b"},
+		},
+		{
+			name: "limits apply per source",
+			dumps: []Dump{daysAgo("a", 2), daysAgo("b", 1),
+				{Path: "other", Source: "db:3306/blog", CreatedAt: now.AddDate(0, 0, -5)}},
+			policy: Policy{KeepLast: 1},
+			want:   []string{"other", "b"},
+		},
+		{
+			name:   "tag kept forever",
+			dumps:  []Dump{tagged(daysAgo("a", 30)), daysAgo("b", 1), daysAgo("c", 2)},
+			policy: Policy{KeepLast: 1, TagRules: []TagRule{{Tags: release}}},
+			want:   []string{"b", "a"},
+		},
+		{
+			name:   "tag with its own limit",
+			dumps:  []Dump{tagged(daysAgo("a", 30)), tagged(daysAgo("b", 20)), daysAgo("c", 2)},
+			policy: Policy{KeepLast: 1, TagRules: []TagRule{{Tags: release, Keep: 1}}},
+			want:   []string{"c", "b"},
+		},
+		{
+			name: "a chain counts as one dump",
+			dumps: []Dump{
+				inChain(daysAgo("base", 10), "base"),
+				inChain(daysAgo("inc1", 9), "base"),
+				inChain(daysAgo("inc2", 8), "base"),
+				daysAgo("full", 12),
+				daysAgo("older", 20),
+			},
+			policy: Policy{KeepLast: 2},
+			want:   []string{"inc2", "inc1", "base", "full"},
+		},
+		{
+			name: "a chain is aged by its newest increment",
+			dumps: []Dump{
+				inChain(daysAgo("base", 10), "base"),
+				inChain(daysAgo("inc1", 2), "base"),
+				daysAgo("full", 5),
+			},
+			policy: Policy{KeepWithin: 3 * 24 * time.Hour},
+			want:   []string{"inc1", "base"},
+		},
+		{
+			name: "a chain outside the retention goes whole",
+			dumps: []Dump{
+				inChain(daysAgo("base", 10), "base"),
+				inChain(daysAgo("inc1", 9), "base"),
+				daysAgo("full", 1),
+			},
+			policy: Policy{KeepLast: 1},
+			want:   []string{"full"},
+		},
+		{
+			name: "chains of the same name in two sources are separate",
+			dumps: []Dump{
+				inChain(daysAgo("shop-base", 10), "base"),
+				inChain(Dump{Path: "blog-base", Source: "db:3306/blog", CreatedAt: now.AddDate(0, 0, -1)}, "base"),
+				daysAgo("full", 1),
+			},
+			policy: Policy{KeepLast: 1},
+			want:   []string{"blog-base", "full"},
+		},
+		{
+			name: "a tagged base keeps its increments",
+			dumps: []Dump{
+				inChain(tagged(daysAgo("base", 40)), "base"),
+				inChain(daysAgo("inc1", 39), "base"),
+				daysAgo("full", 1),
+			},
+			policy: Policy{KeepLast: 1, TagRules: []TagRule{{Tags: release}}},
+			want:   []string{"full", "inc1", "base"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decisions := Apply(tt.dumps, tt.policy, now)
+			if len(decisions) != len(tt.dumps) {
+				t.Fatalf("%d decisions for %d dumps", len(decisions), len(tt.dumps))
+			}
+			if got := kept(decisions); !slices.Equal(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestApplyNeverOrphansIncrements checks, for every keep-last, that no dump
+// an increment continues from is deleted while the increment is kept
+func TestApplyNeverOrphansIncrements(t *testing.T) {
+	dumps := []Dump{
+		inChain(daysAgo("a0", 20), "a0"),
+		inChain(daysAgo("a1", 19), "a0"),
+		daysAgo("x", 18),
+		inChain(daysAgo("a2", 17), "a0"),
+		inChain(daysAgo("b0", 10), "b0"),
+		daysAgo("y", 9),
+		inChain(daysAgo("b1", 8), "b0"),
+		inChain(daysAgo("b2", 1), "b0"),
+	}
+	for keepLast := 1; keepLast <= len(dumps); keepLast++ {
+		keep := make(map[string]bool)
+		for _, decision := range Apply(dumps, Policy{KeepLast: keepLast}, now) {
+			keep[decision.Dump.Path] = decision.Keep
+		}
+		for _, dump := range dumps {
+			if dump.Chain != "" && keep[dump.Path] != keep[dump.Chain] {
+				t.Errorf("keep-last %d: %s kept %v, its base %s kept %v", keepLast, dump.Path, keep[dump.Path], dump.Chain, keep[dump.Chain])
+			}
+		}
+	}
+}
+
+func TestApplyChainReason(t *testing.T) {
+	dumps := []Dump{inChain(daysAgo("base", 10), "base"), inChain(daysAgo("inc1", 9), "base")}
+	for _, decision := range Apply(dumps, Policy{KeepLast: 1}, now) {
+		if !strings.HasSuffix(decision.Reason, ", with its chain") {
+			t.Errorf("reason for %s = %q, want it to mention the chain", decision.Dump.Path, decision.Reason)
+		}
+	}
+}
+
+// TestApplyAcrossDST checks that ages are elapsed time, not wall-clock time,
+// when the clocks change between a dump and the prune, and that dumps whose
+// sidecars recorded the time in different zones are ranked by the instant
+func TestApplyAcrossDST(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	at := func(path string, year int, month time.Month, day, hour, min int) Dump {
+		return Dump{Path: path, Source: "db:3306/shop", CreatedAt: time.Date(year, month, day, hour, min, 0, 0, oslo)}
+	}
+	// parsed reads a time as the metadata sidecar stores it
+	parsed := func(path, createdAt string) Dump {
+		created, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Dump{Path: path, Source: "db:3306/shop", CreatedAt: created}
+	}
+
+	tests := []struct {
+		name   string
+		dumps  []Dump
+		policy Policy
+		now    time.Time
+		want   []string
+	}{
+		{
+			// Clocks went forward an hour on 29 March: 48 wall-clock hours
+			// before the prune is only 47 elapsed
+			name: "keep within after the spring change",
+			dumps: []Dump{
+				at("47h", 2026, time.March, 28, 12, 0),
+				at("48h", 2026, time.March, 28, 11, 0),
+				at("48h30m", 2026, time.March, 28, 10, 30),
+			},
+			policy: Policy{KeepWithin: 48 * time.Hour},
+			now:    time.Date(2026, time.March, 30, 12, 0, 0, 0, oslo),
+			want:   []string{"47h"},
+		},
+		{
+			// Clocks went back an hour on 25 October: 48 wall-clock hours
+			// before the prune are 49 elapsed
+			name: "keep within after the autumn change",
+			dumps: []Dump{
+				at("47h", 2026, time.October, 24, 14, 0),
+				at("48h", 2026, time.October, 24, 13, 0),
+				at("49h", 2026, time.October, 24, 12, 0),
+			},
+			policy: Policy{KeepWithin: 48 * time.Hour},
+			now:    time.Date(2026, time.October, 26, 12, 0, 0, 0, oslo),
+			want:   []string{"47h"},
+		},
+		{
+			// Seven wall-clock days before the prune are 6d23h elapsed
+			name: "seven days spanning the spring change",
+			dumps: []Dump{
+				at("6d23h", 2026, time.March, 23, 12, 0),
+				at("7d", 2026, time.March, 23, 11, 0),
+			},
+			policy: Policy{KeepWithin: 7 * 24 * time.Hour},
+			now:    time.Date(2026, time.March, 30, 12, 0, 0, 0, oslo),
+			want:   []string{"6d23h"},
+		},
+		{
+			// 02:10 after the clocks went back is 40 minutes after 02:30
+			// before, though its wall clock (and a local-time file name)
+			// is earlier
+			name: "keep last in the repeated hour",
+			dumps: []Dump{
+				parsed("02:30 CEST", "2026-10-25T02:30:00+02:00"),
+				parsed("02:10 CET", "2026-10-25T02:10:00+01:00"),
+			},
+			policy: Policy{KeepLast: 1},
+			now:    time.Date(2026, time.October, 25, 12, 0, 0, 0, oslo),
+			want:   []string{"02:10 CET"},
+		},
+		{
+			// Older sidecars hold local time, newer ones UTC
+			name: "keep last across zones",
+			dumps: []Dump{
+				parsed("local newest", "2026-10-25T03:15:00+01:00"),
+				parsed("utc middle", "2026-10-25T02:00:00Z"),
+				parsed("local oldest", "2026-10-25T02:45:00+02:00"),
+				parsed("utc oldest", "2026-10-24T23:00:00Z"),
+			},
+			policy: Policy{KeepLast: 2},
+			now:    time.Date(2026, time.October, 25, 12, 0, 0, 0, oslo),
+			want:   []string{"local newest", "utc middle"},
+		},
+		{
+			name: "keep within across zones",
+			dumps: []Dump{
+				parsed("utc", "2026-10-24T23:30:00Z"),
+				parsed("local", "2026-10-25T02:30:00+02:00"),
+			},
+			policy: Policy{KeepWithin: 90 * time.Minute},
+			now:    time.Date(2026, time.October, 25, 2, 10, 0, 0, time.FixedZone("CET", 60*60)),
+			want:   []string{"local"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kept(Apply(tt.dumps, tt.policy, tt.now)); !slices.Equal(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Package selftest holds the fixture schema of dbdump selftest and runs its
+// stages, each of which can be skipped, in order
+package selftest
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/transform"
+)
+
+//go:embed fixture.sql
+var fixtureSQL string
+
+// Fixture tables by role. Every base table not excluded keeps its rows in
+// the dump.
+var (
+	// Tables are the base tables of the fixture
+	Tables = []string{"customers", "orders", "vedlegg_æøå", "sessions", "raw_events"}
+
+	// Views are the views of the fixture
+	Views = []string{"customer_totals"}
+
+	// Triggers are the triggers of the fixture
+	Triggers = []string{"orders_after_insert"}
+
+	// ForeignKeys is the number of foreign keys in the fixture
+	ForeignKeys = 2
+
+	// ExcludedByDefault is left without data by the built-in exclusions
+	ExcludedByDefault = "sessions"
+
+	// ExcludedByFlag is the table the selftest's dump excludes with --exclude
+	ExcludedByFlag = "raw_events"
+)
+
+// FixtureSQL returns the fixture as a script for the mysql client
+func FixtureSQL() string {
+	return fixtureSQL
+}
+
+// FixtureStatements splits the fixture into statements without their
+// delimiters, following DELIMITER commands as the mysql client does
+func FixtureStatements() ([]string, error) {
+	var statements []string
+	collect := func(statement []byte) []byte {
+		text := strings.TrimSpace(string(statement))
+		text = strings.TrimSpace(strings.TrimRight(text, ";"))
+		if text != "" {
+			statements = append(statements, text)
+		}
+		return statement
+	}
+	w := transform.NewWriter(io.Discard, collect)
+	if _, err := io.WriteString(w, fixtureSQL); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+// Stage is one step of the selftest
+type Stage struct {
+	Name string
+
+	// Needs are the stages whose results this one uses; it is skipped
+	// unless each of them passed
+	Needs []string
+
+	// Reusable stages meet the needs of later ones when skipped on
+	// request, as their result can already exist (a loaded fixture)
+	Reusable bool
+
+	// Always runs the stage whatever happened before it (cleanup)
+	Always bool
+
+	Run func(ctx context.Context) error
+}
+
+// Status is the outcome of a stage
+type Status string
+
+const (
+	Passed  Status = "passed"
+	Failed  Status = "failed"
+	Skipped Status = "skipped"
+)
+
+// Result is the outcome of one stage
+type Result struct {
+	Stage    string
+	Status   Status
+	Reason   string // why a stage was skipped
+	Err      error
+	Duration time.Duration
+}
+
+// Run runs the stages in order, skipping those in skip and those whose
+// needs weren't met, and calls report after each
+func Run(ctx context.Context, stages []Stage, skip map[string]bool, report func(Result)) []Result {
+	met := make(map[string]bool, len(stages))
+	var results []Result
+	for _, stage := range stages {
+		result := Result{Stage: stage.Name}
+		switch {
+		case skip[stage.Name]:
+			result.Status, result.Reason = Skipped, "skipped on request"
+			met[stage.Name] = stage.Reusable
+		case !stage.Always && unmet(stage.Needs, met) != "":
+			result.Status, result.Reason = Skipped, fmt.Sprintf("needs %s", unmet(stage.Needs, met))
+		case !stage.Always && ctx.Err() != nil:
+			result.Status, result.Reason = Skipped, "interrupted"
+		default:
+			started := time.Now()
+			result.Err = stage.Run(ctx)
+			result.Duration = time.Since(started)
+			result.Status = Passed
+			if result.Err != nil {
+				result.Status = Failed
+			}
+			met[stage.Name] = result.Err == nil
+		}
+		results = append(results, result)
+		if report != nil {
+			report(result)
+		}
+	}
+	return results
+}
+
+// unmet returns the first of needs not met, or ""
+func unmet(needs []string, met map[string]bool) string {
+	for _, need := range needs {
+		if !met[need] {
+			return need
+		}
+	}
+	return ""
+}
+
+// StageNames returns the names of the stages, for flag help and validation
+func StageNames(stages []Stage) []string {
+	names := make([]string, len(stages))
+	for i, stage := range stages {
+		names[i] = stage.Name
+	}
+	return names
+}
@@ -0,0 +1,248 @@
+// Package spill holds statements and other content that is normally small
+// but can be arbitrarily large (a single INSERT line can be hundreds of
+// megabytes). Buffers take their memory from a Pool with a ceiling; content
+// that doesn't fit is moved to a temporary file and read back through the
+// same interface.
+package spill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// DefaultLimit is the memory ceiling of the Default pool
+const DefaultLimit = 256 << 20
+
+// HeadSize is how many bytes from the start of a spilled buffer stay in
+// memory, for looking at what a statement is without reading it back
+const HeadSize = 64 << 10
+
+// keepCapacity is the largest backing array a Buffer keeps across Reset;
+// typical extended INSERTs reuse it, while rare huge ones don't pin memory
+// the pool no longer counts
+const keepCapacity = 4 << 20
+
+// Pool hands out buffers and keeps the memory they hold together under its
+// limit. It is safe for concurrent use.
+type Pool struct {
+	mu    sync.Mutex
+	limit int64 // 0 for no limit
+	dir   string
+	used  int64
+
+	spills  int
+	spilled int64
+}
+
+// NewPool creates a pool holding at most limit bytes in memory (0 for no
+// limit) and spilling to files in dir (the system temporary directory if
+// empty)
+func NewPool(limit int64, dir string) *Pool {
+	return &Pool{limit: limit, dir: dir}
+}
+
+// Default is the pool used by components that aren't given one
+var Default = NewPool(DefaultLimit, "")
+
+// SetLimit changes the pool's memory ceiling; buffers already over it spill
+// on their next write
+func (p *Pool) SetLimit(limit int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limit = limit
+}
+
+// Limit returns the pool's memory ceiling, 0 meaning none
+func (p *Pool) Limit() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.limit
+}
+
+// Stats returns how many buffers spilled to disk, and how many bytes they
+// wrote there
+func (p *Pool) Stats() (spills int, bytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.spills, p.spilled
+}
+
+// NewBuffer returns an empty buffer drawing on the pool
+func (p *Pool) NewBuffer() *Buffer {
+	return &Buffer{pool: p}
+}
+
+// Reserve takes n bytes from the pool for memory held outside a Buffer,
+// reporting false if that would pass the limit
+func (p *Pool) Reserve(n int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.limit > 0 && p.used+n > p.limit {
+		return false
+	}
+	p.used += n
+	return true
+}
+
+// Release returns n bytes taken with Reserve to the pool
+func (p *Pool) Release(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.used -= n
+}
+
+// account adds n bytes to the spilled total
+func (p *Pool) account(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.spilled += n
+}
+
+// Buffer collects bytes in memory until the pool refuses more, then moves
+// them to a temporary file. Once spilled, only the first HeadSize bytes
+// stay in memory. A Buffer is not safe for concurrent use; Close removes
+// its file.
+type Buffer struct {
+	pool     *Pool
+	mem      []byte // the contents, or only their head once spilled
+	reserved int64  // bytes of mem counted against the pool
+	file     *os.File
+	size     int64
+}
+
+// Write implements io.Writer
+func (b *Buffer) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if b.file == nil {
+		if b.pool.Reserve(int64(len(p))) {
+			b.reserved += int64(len(p))
+			b.mem = append(b.mem, p...)
+			b.size += int64(len(p))
+			return len(p), nil
+		}
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(b.mem) < HeadSize {
+		b.mem = append(b.mem, p[:min(len(p), HeadSize-len(b.mem))]...)
+	}
+	n, err := b.file.Write(p)
+	b.size += int64(n)
+	b.pool.account(int64(n))
+	if err != nil {
+		return n, fmt.Errorf("failed to write spill file: %w", err)
+	}
+	return n, nil
+}
+
+// spill moves the contents to a new temporary file, keeping the head
+func (b *Buffer) spill() error {
+	file, err := os.CreateTemp(b.pool.dir, ".dbdump-spill-*")
+	if err != nil {
+		return fmt.Errorf("failed to create spill file: %w", err)
+	}
+	b.file = file
+	b.pool.mu.Lock()
+	b.pool.spills++
+	b.pool.mu.Unlock()
+
+	if _, err := file.Write(b.mem); err != nil {
+		return fmt.Errorf("failed to write spill file: %w", err)
+	}
+	b.pool.account(int64(len(b.mem)))
+
+	// The head is small enough to keep whatever the pool says
+	if len(b.mem) > HeadSize {
+		b.mem = append([]byte(nil), b.mem[:HeadSize]...)
+	}
+	b.pool.Release(b.reserved)
+	b.reserved = 0
+	return nil
+}
+
+// Len returns the number of bytes written since the last Reset
+func (b *Buffer) Len() int64 {
+	return b.size
+}
+
+// Spilled reports whether the contents were moved to disk
+func (b *Buffer) Spilled() bool {
+	return b.file != nil
+}
+
+// Bytes returns the contents if they are in memory, or nil once spilled.
+// The slice is only valid until the next write or Reset.
+func (b *Buffer) Bytes() []byte {
+	if b.file != nil {
+		return nil
+	}
+	return b.mem
+}
+
+// Head returns up to HeadSize bytes from the start of the contents, in
+// memory or not
+func (b *Buffer) Head() []byte {
+	return b.mem[:min(len(b.mem), HeadSize)]
+}
+
+// Reader returns a reader over the whole contents. It is only valid until
+// the next write or Reset.
+func (b *Buffer) Reader() io.Reader {
+	if b.file == nil {
+		return bytes.NewReader(b.mem)
+	}
+	return io.NewSectionReader(b.file, 0, b.size)
+}
+
+// WriteTo implements io.WriterTo, copying the whole contents to w
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	if b.file == nil {
+		n, err := w.Write(b.mem)
+		return int64(n), err
+	}
+	return io.Copy(w, b.Reader())
+}
+
+// Reset empties the buffer, returning its memory to the pool and removing
+// its spill file
+func (b *Buffer) Reset() error {
+	b.pool.Release(b.reserved)
+	b.reserved = 0
+	b.mem = b.mem[:0]
+	if cap(b.mem) > keepCapacity {
+		b.mem = nil
+	}
+	b.size = 0
+	return b.removeFile()
+}
+
+// Close empties the buffer for good
+func (b *Buffer) Close() error {
+	err := b.Reset()
+	b.mem = nil
+	return err
+}
+
+// removeFile closes and deletes the spill file, if there is one
+func (b *Buffer) removeFile() error {
+	if b.file == nil {
+		return nil
+	}
+	file := b.file
+	b.file = nil
+	closeErr := file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		return fmt.Errorf("failed to remove spill file: %w", err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close spill file: %w", closeErr)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package spill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// content returns n bytes that differ from position to position, so that
+// misplaced bytes show
+func content(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte('a' + i%23)
+	}
+	return data
+}
+
+// write writes data to b in chunks of size
+func write(t testing.TB, b *Buffer, data []byte, size int) {
+	t.Helper()
+	for len(data) > 0 {
+		n := min(size, len(data))
+		if written, err := b.Write(data[:n]); err != nil || written != n {
+			t.Fatalf("Write = %d, %v; want %d", written, err, n)
+		}
+		data = data[n:]
+	}
+}
+
+// checkContents checks everything a buffer says about its contents
+func checkContents(t *testing.T, b *Buffer, want []byte, spilled bool) {
+	t.Helper()
+	if b.Len() != int64(len(want)) {
+		t.Errorf("Len = %d, want %d", b.Len(), len(want))
+	}
+	if b.Spilled() != spilled {
+		t.Errorf("Spilled = %v, want %v", b.Spilled(), spilled)
+	}
+	if spilled && b.Bytes() != nil {
+		t.Error("Bytes of a spilled buffer isn't nil")
+	}
+	if !spilled && !bytes.Equal(b.Bytes(), want) {
+		t.Error("Bytes differ from what was written")
+	}
+	if !bytes.Equal(b.Head(), want[:min(len(want), HeadSize)]) {
+		t.Errorf("Head is %d bytes and differs from the first %d written", len(b.Head()), min(len(want), HeadSize))
+	}
+	read, err := io.ReadAll(b.Reader())
+	if err != nil || !bytes.Equal(read, want) {
+		t.Errorf("Reader gives %d bytes (%v), want the %d written", len(read), err, len(want))
+	}
+	var copied bytes.Buffer
+	if n, err := b.WriteTo(&copied); err != nil || n != int64(len(want)) || !bytes.Equal(copied.Bytes(), want) {
+		t.Errorf("WriteTo copied %d bytes (%v), want the %d written", n, err, len(want))
+	}
+}
+
+// checkEmpty checks that dir has no spill files left
+func checkEmpty(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("spill file left behind: %s", entry.Name())
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		size    int
+		spilled bool
+	}{
+		{name: "empty", limit: 1, size: 0},
+		{name: "no limit", limit: 0, size: 1 << 20},
+		{name: "under the limit", limit: 1 << 20, size: 1000},
+		{name: "at the limit", limit: 1000, size: 1000},
+		{name: "one byte over", limit: 1000, size: 1001, spilled: true},
+		{name: "tiny ceiling, one byte", limit: 1, size: 1},
+		{name: "tiny ceiling, small", limit: 1, size: 100, spilled: true},
+		{name: "tiny ceiling, head sized", limit: 1, size: HeadSize, spilled: true},
+		{name: "tiny ceiling, past the head", limit: 1, size: HeadSize + 1, spilled: true},
+		{name: "tiny ceiling, large", limit: 1, size: 3 << 20, spilled: true},
+		{name: "spilled past the head in memory", limit: 2 * HeadSize, size: 3 * HeadSize, spilled: true},
+	}
+	for _, tt := range tests {
+		for _, chunk := range []int{1, 7, 4096, 1 << 20} {
+			if chunk == 1 && tt.size > 1<<17 {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s/chunks of %d", tt.name, chunk), func(t *testing.T) {
+				dir := t.TempDir()
+				pool := NewPool(tt.limit, dir)
+				b := pool.NewBuffer()
+				want := content(tt.size)
+				write(t, b, want, chunk)
+				checkContents(t, b, want, tt.spilled)
+
+				if tt.spilled && pool.used != 0 {
+					t.Errorf("a spilled buffer holds %d bytes of the pool", pool.used)
+				}
+				if err := b.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if pool.used != 0 {
+					t.Errorf("%d bytes of the pool not returned on Close", pool.used)
+				}
+				checkEmpty(t, dir)
+			})
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	dir := t.TempDir()
+	pool := NewPool(1, dir)
+	b := pool.NewBuffer()
+
+	// The same buffer, reused for statements that do and don't spill
+	for i, size := range []int{1, 5000, 0, 1, HeadSize * 2, 1} {
+		want := content(size)
+		write(t, b, want, 512)
+		checkContents(t, b, want, size > 1)
+		if err := b.Reset(); err != nil {
+			t.Fatalf("statement %d: %v", i, err)
+		}
+		checkContents(t, b, nil, false)
+		checkEmpty(t, dir)
+	}
+	if spills, bytes := pool.Stats(); spills != 2 || bytes != 5000+2*HeadSize {
+		t.Errorf("Stats = %d, %d; want 2 spills of %d bytes", spills, bytes, 5000+2*HeadSize)
+	}
+}
+
+// TestSharedCeiling checks that buffers drawing on one pool share its
+// ceiling, and get their memory back when others let go of theirs
+func TestSharedCeiling(t *testing.T) {
+	dir := t.TempDir()
+	pool := NewPool(100, dir)
+	first, second := pool.NewBuffer(), pool.NewBuffer()
+
+	write(t, first, content(80), 80)
+	write(t, second, content(30), 30)
+	if first.Spilled() || !second.Spilled() {
+		t.Fatalf("spilled: first %v, second %v; want only the second", first.Spilled(), second.Spilled())
+	}
+	if err := first.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, second, content(30), 30)
+	if second.Spilled() {
+		t.Error("the second buffer spilled after the first returned its memory")
+	}
+
+	// Lowering the limit spills on the next write
+	pool.SetLimit(1)
+	write(t, second, content(1), 1)
+	if !second.Spilled() {
+		t.Error("a buffer over the lowered limit didn't spill")
+	}
+	checkContents(t, second, append(content(30), content(1)...), true)
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkEmpty(t, dir)
+}
+
+// TestConcurrentSpills runs buffers on many goroutines with a ceiling that
+// makes most of them spill
+func TestConcurrentSpills(t *testing.T) {
+	dir := t.TempDir()
+	pool := NewPool(4<<10, dir)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for worker := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := pool.NewBuffer()
+			defer b.Close()
+			for i := range 20 {
+				want := content(100 + (worker*20+i)*97)
+				if _, err := b.Write(want); err != nil {
+					errs <- err
+					return
+				}
+				read, err := io.ReadAll(b.Reader())
+				if err != nil || !bytes.Equal(read, want) {
+					errs <- fmt.Errorf("worker %d, statement %d: read back %d bytes (%v), want %d", worker, i, len(read), err, len(want))
+					return
+				}
+				if err := b.Reset(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if pool.used != 0 {
+		t.Errorf("%d bytes of the pool not returned", pool.used)
+	}
+	if spills, _ := pool.Stats(); spills == 0 {
+		t.Error("nothing spilled")
+	}
+	checkEmpty(t, dir)
+}
+
+func TestSpillFileError(t *testing.T) {
+	pool := NewPool(1, filepath.Join(t.TempDir(), "missing"))
+	b := pool.NewBuffer()
+	_, err := b.Write(content(10))
+	if err == nil || !strings.Contains(err.Error(), "failed to create spill file") {
+		t.Errorf("Write = %v, want the spill file error", err)
+	}
+}
+
+// statement is an extended INSERT of a typical size
+var statement = []byte("INSERT INTO `t` VALUES " + strings.Repeat("(1,'some text',NULL,3.14),", 2000) + "(0,'',NULL,0);\n")
+
+// BenchmarkNoSpill is the cost of holding statements in a Buffer that never
+// spills; compare it with BenchmarkBytesBuffer
+func BenchmarkNoSpill(b *testing.B) {
+	buffer := NewPool(DefaultLimit, b.TempDir()).NewBuffer()
+	b.SetBytes(int64(len(statement)))
+	b.ReportAllocs()
+	for b.Loop() {
+		write(b, buffer, statement, 32<<10)
+		if _, err := buffer.WriteTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+		if err := buffer.Reset(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBytesBuffer is BenchmarkNoSpill with a bytes.Buffer, the
+// baseline without a ceiling
+func BenchmarkBytesBuffer(b *testing.B) {
+	var buffer bytes.Buffer
+	b.SetBytes(int64(len(statement)))
+	b.ReportAllocs()
+	for b.Loop() {
+		for data := statement; len(data) > 0; {
+			n := min(32<<10, len(data))
+			buffer.Write(data[:n])
+			data = data[n:]
+		}
+		if _, err := buffer.WriteTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+		buffer.Reset()
+	}
+}
+
+// BenchmarkSpill is the cost when every statement spills
+func BenchmarkSpill(b *testing.B) {
+	buffer := NewPool(1, b.TempDir()).NewBuffer()
+	b.SetBytes(int64(len(statement)))
+	for b.Loop() {
+		write(b, buffer, statement, 32<<10)
+		if _, err := buffer.WriteTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+		if err := buffer.Reset(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package sqlident
+
+import "testing"
+
+func TestIsReserved(t *testing.T) {
+	tests := []struct {
+		word string
+		want bool
+	}{
+		{"select", true},
+		{"SELECT", true},
+		{"Order", true},
+		{"group", true},
+		{"key", true},
+		{"rank", true}, // reserved since MySQL 8
+		{"lateral", true},
+		{"cume_dist", true},
+		{"users", false},
+		{"user", false}, // a keyword, but not reserved
+		{"status", false},
+		{"timestamp", false},
+		{"selects", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := IsReserved(tt.word); got != tt.want {
+				t.Errorf("IsReserved(%q) = %v, want %v", tt.word, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Package sqlident quotes MySQL identifiers and compares table names the
+// way a server does, following its lower_case_table_names setting
+package sqlident
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Quote quotes a table, column or database name with backticks, doubling
+// backticks inside it
+func Quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteQualified quotes schema.name
+func QuoteQualified(schema, name string) string {
+	return Quote(schema) + "." + Quote(name)
+}
+
+// AppendQuoted appends the quoted name to dst
+func AppendQuoted(dst []byte, name string) []byte {
+	dst = append(dst, '`')
+	for i := 0; i < len(name); i++ {
+		if name[i] == '`' {
+			dst = append(dst, '`')
+		}
+		dst = append(dst, name[i])
+	}
+	return append(dst, '`')
+}
+
+// NeedsQuotes reports whether a name can't be written bare: it is empty,
+// has characters other than letters, digits, _ and $, is all digits, or
+// is a reserved word
+func NeedsQuotes(name string) bool {
+	if name == "" || IsReserved(name) {
+		return true
+	}
+	digits := true
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if !IsWordByte(b) {
+			return true
+		}
+		if b < '0' || b > '9' {
+			digits = false
+		}
+	}
+	return digits
+}
+
+// IsWordByte reports whether b can be part of an unquoted identifier;
+// bytes of multi-byte UTF-8 characters can
+func IsWordByte(b byte) bool {
+	return b == '_' || b == '$' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+// IgnoreTableArg returns mysqldump's --ignore-table flag for a table.
+// mysqldump takes the name unquoted and splits it at the first dot, so
+// table names with dots work but database names with dots don't.
+func IgnoreTableArg(database, table string) string {
+	return fmt.Sprintf("--ignore-table=%s.%s", database, table)
+}
+
+// Case is how a server stores and compares table names, set by its
+// lower_case_table_names
+type Case int
+
+const (
+	// CaseSensitive stores names as given and compares them as stored
+	// (0, the default on Linux); tables differing only by case can exist
+	CaseSensitive Case = 0
+
+	// CaseLower stores names in lowercase and compares them in lowercase
+	// (1, the default on Windows)
+	CaseLower Case = 1
+
+	// CaseInsensitive stores names as given and compares them in
+	// lowercase (2, the default on macOS)
+	CaseInsensitive Case = 2
+)
+
+// Folds reports whether the server compares names case-insensitively
+func (c Case) Folds() bool {
+	return c != CaseSensitive
+}
+
+// Key returns the form of name the server compares
+func (c Case) Key(name string) string {
+	if c.Folds() {
+		return Fold(name)
+	}
+	return name
+}
+
+// Equal reports whether the server takes a and b for the same name
+func (c Case) Equal(a, b string) bool {
+	return c.Key(a) == c.Key(b)
+}
+
+// String describes the setting for messages
+func (c Case) String() string {
+	return fmt.Sprintf("lower_case_table_names=%d", int(c))
+}
+
+// Fold lowercases a name as servers comparing names case-insensitively do
+func Fold(name string) string {
+	return strings.ToLower(name)
+}
+
+// Collisions groups the names that differ only by case, which only a
+// case-sensitive server holds side by side. Each group is sorted, and the
+// groups are in the order of their first names.
+func Collisions(names []string) [][]string {
+	byKey := make(map[string][]string, len(names))
+	for _, name := range names {
+		key := Fold(name)
+		if !slices.Contains(byKey[key], name) {
+			byKey[key] = append(byKey[key], name)
+		}
+	}
+	var groups [][]string
+	for _, group := range byKey {
+		if len(group) > 1 {
+			slices.Sort(group)
+			groups = append(groups, group)
+		}
+	}
+	slices.SortFunc(groups, func(a, b []string) int {
+		return strings.Compare(a[0], b[0])
+	})
+	return groups
+}
+
+// Twins maps each name that collides with others by case to those others
+func Twins(names []string) map[string][]string {
+	twins := make(map[string][]string)
+	for _, group := range Collisions(names) {
+		for _, name := range group {
+			twins[name] = slices.DeleteFunc(slices.Clone(group), func(other string) bool { return other == name })
+		}
+	}
+	return twins
+}
+
+// Unescape returns the name inside backticks as captured from SQL, with
+// doubled backticks undone
+func Unescape(quoted string) string {
+	return strings.ReplaceAll(quoted, "``", "`")
+}
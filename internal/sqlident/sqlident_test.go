@@ -0,0 +1,190 @@
+package sqlident
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"users", "`users`"},
+		{"order", "`order`"},
+		{"", "``"},
+		{"my`table", "`my``table`"},
+		{"``", "``````"},
+		{"`leading", "```leading`"},
+		{"trailing`", "`trailing```"},
+		{"with.dot", "`with.dot`"},
+		{"løkker", "`løkker`"},
+		{"表", "`表`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Quote(tt.name); got != tt.want {
+				t.Errorf("Quote(%q) = %s, want %s", tt.name, got, tt.want)
+			}
+			if got := string(AppendQuoted([]byte("x."), tt.name)); got != "x."+tt.want {
+				t.Errorf("AppendQuoted(%q) = %s, want x.%s", tt.name, got, tt.want)
+			}
+			if got := Unescape(tt.want[1 : len(tt.want)-1]); got != tt.name {
+				t.Errorf("Unescape(%s) = %q, want %q", tt.want, got, tt.name)
+			}
+		})
+	}
+
+	if got := QuoteQualified("my`db", "select"); got != "`my``db`.`select`" {
+		t.Errorf("QuoteQualified = %s", got)
+	}
+}
+
+func TestNeedsQuotes(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"users", false},
+		{"user_roles", false},
+		{"$price", false},
+		{"t1", false},
+		{"1t", false},
+		{"Users", false},
+		{"løkker", false},
+		{"select", true},
+		{"Select", true},
+		{"ORDER", true},
+		{"", true},
+		{"123", true},
+		{"0", true},
+		{"my table", true},
+		{"my-table", true},
+		{"my`table", true},
+		{"with.dot", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsQuotes(tt.name); got != tt.want {
+				t.Errorf("NeedsQuotes(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCase(t *testing.T) {
+	// a and b differ only by case; the servers disagree on whether they
+	// are the same table
+	pairs := []struct {
+		a, b string
+	}{
+		{"Users", "users"},
+		{"ORDERS", "orders"},
+		{"Ærfugl", "ærfugl"},
+		{"my`Table", "my`table"},
+	}
+	tests := []struct {
+		lctn      Case
+		wantEqual bool
+		wantKey   func(string) string
+	}{
+		{CaseSensitive, false, func(s string) string { return s }},
+		{CaseLower, true, Fold},
+		{CaseInsensitive, true, Fold},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lctn.String(), func(t *testing.T) {
+			if tt.lctn.Folds() != tt.wantEqual {
+				t.Errorf("Folds() = %v, want %v", tt.lctn.Folds(), tt.wantEqual)
+			}
+			for _, pair := range pairs {
+				if got := tt.lctn.Equal(pair.a, pair.b); got != tt.wantEqual {
+					t.Errorf("Equal(%q, %q) = %v, want %v", pair.a, pair.b, got, tt.wantEqual)
+				}
+				if got := tt.lctn.Equal(pair.b, pair.a); got != tt.wantEqual {
+					t.Errorf("Equal(%q, %q) = %v, want %v", pair.b, pair.a, got, tt.wantEqual)
+				}
+				if !tt.lctn.Equal(pair.a, pair.a) {
+					t.Errorf("Equal(%q, %q) = false", pair.a, pair.a)
+				}
+				if got := tt.lctn.Key(pair.a); got != tt.wantKey(pair.a) {
+					t.Errorf("Key(%q) = %q, want %q", pair.a, got, tt.wantKey(pair.a))
+				}
+			}
+			if tt.lctn.Equal("users", "users2") {
+				t.Error("different names compare equal")
+			}
+		})
+	}
+
+	if got := CaseInsensitive.String(); got != "lower_case_table_names=2" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		names     []string
+		want      [][]string
+		wantTwins map[string][]string
+	}{
+		{name: "none", names: []string{"users", "orders"}, wantTwins: map[string][]string{}},
+		{
+			name:      "pair",
+			names:     []string{"users", "Users", "orders"},
+			want:      [][]string{{"Users", "users"}},
+			wantTwins: map[string][]string{"Users": {"users"}, "users": {"Users"}},
+		},
+		{
+			name:  "three of a kind",
+			names: []string{"users", "USERS", "Users"},
+			want:  [][]string{{"USERS", "Users", "users"}},
+			wantTwins: map[string][]string{
+				"USERS": {"Users", "users"},
+				"Users": {"USERS", "users"},
+				"users": {"USERS", "Users"},
+			},
+		},
+		{
+			name:  "groups by first name",
+			names: []string{"zeta", "orders", "Zeta", "Orders"},
+			want:  [][]string{{"Orders", "orders"}, {"Zeta", "zeta"}},
+			wantTwins: map[string][]string{
+				"Orders": {"orders"}, "orders": {"Orders"},
+				"Zeta": {"zeta"}, "zeta": {"Zeta"},
+			},
+		},
+		{
+			name:      "repeated name",
+			names:     []string{"users", "users"},
+			wantTwins: map[string][]string{},
+		},
+		{
+			name:      "backticks",
+			names:     []string{"a`B", "a`b"},
+			want:      [][]string{{"a`B", "a`b"}},
+			wantTwins: map[string][]string{"a`B": {"a`b"}, "a`b": {"a`B"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Collisions(tt.names); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Collisions(%q) = %q, want %q", tt.names, got, tt.want)
+			}
+			if got := Twins(tt.names); !reflect.DeepEqual(got, tt.wantTwins) {
+				t.Errorf("Twins(%q) = %q, want %q", tt.names, got, tt.wantTwins)
+			}
+		})
+	}
+}
+
+func TestIgnoreTableArg(t *testing.T) {
+	if got := IgnoreTableArg("shop", "with.dot"); got != "--ignore-table=shop.with.dot" {
+		t.Errorf("IgnoreTableArg = %q", got)
+	}
+}
@@ -0,0 +1,140 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/metadata"
+)
+
+// TableDelta describes how one table changed between two points in time
+type TableDelta struct {
+	Name         string  `json:"name"`
+	OldSize      int64   `json:"old_size"`
+	NewSize      int64   `json:"new_size"`
+	SizeDelta    int64   `json:"size_delta"`
+	SizePercent  float64 `json:"size_percent"`
+	OldRows      int64   `json:"old_rows"`
+	NewRows      int64   `json:"new_rows"`
+	RowsDelta    int64   `json:"rows_delta"`
+	DataIncluded bool    `json:"data_included"`
+}
+
+// Comparison is the result of comparing live table sizes with an old sidecar
+type Comparison struct {
+	Database string    `json:"database"`
+	Since    time.Time `json:"since"`
+
+	Growers   []TableDelta `json:"growers"`
+	Shrinkers []TableDelta `json:"shrinkers"`
+	Added     []TableDelta `json:"added"`
+	Removed   []TableDelta `json:"removed"`
+
+	OldTotalSize int64 `json:"old_total_size"`
+	NewTotalSize int64 `json:"new_total_size"`
+
+	// OldDumpSize is the size of the old dump file, and ProjectedDumpSize an
+	// estimate of a dump taken now with the same exclusions (0 if unknown)
+	OldDumpSize       int64 `json:"old_dump_size"`
+	ProjectedDumpSize int64 `json:"projected_dump_size"`
+}
+
+// Compare compares live table information with the tables recorded in a sidecar
+// Sizes are data plus index size, as reported by information_schema
+func Compare(old *metadata.Metadata, current []database.TableInfo) *Comparison {
+	cmp := &Comparison{
+		Database:    old.Source.Database,
+		Since:       old.CreatedAt,
+		OldDumpSize: old.FileSize,
+	}
+
+	live := make(map[string]database.TableInfo, len(current))
+	for _, info := range current {
+		live[info.Name] = info
+		cmp.NewTotalSize += info.DataSize + info.IndexSize
+	}
+
+	// Dump size scales with the data of tables whose rows were dumped
+	var oldIncludedData, includedDataDelta int64
+
+	recorded := make(map[string]bool, len(old.Tables))
+	for _, table := range old.Tables {
+		recorded[table.Name] = true
+		oldSize := table.DataSize + table.IndexSize
+		cmp.OldTotalSize += oldSize
+		if table.DataIncluded {
+			oldIncludedData += table.DataSize
+		}
+
+		info, ok := live[table.Name]
+		if !ok {
+			cmp.Removed = append(cmp.Removed, TableDelta{
+				Name:         table.Name,
+				OldSize:      oldSize,
+				SizeDelta:    -oldSize,
+				SizePercent:  -100,
+				OldRows:      table.RowCount,
+				RowsDelta:    -table.RowCount,
+				DataIncluded: table.DataIncluded,
+			})
+			if table.DataIncluded {
+				includedDataDelta -= table.DataSize
+			}
+			continue
+		}
+
+		delta := newDelta(table.Name, oldSize, info.DataSize+info.IndexSize, table.RowCount, info.RowCount)
+		delta.DataIncluded = table.DataIncluded
+		if table.DataIncluded {
+			includedDataDelta += info.DataSize - table.DataSize
+		}
+
+		switch {
+		case delta.SizeDelta > 0:
+			cmp.Growers = append(cmp.Growers, delta)
+		case delta.SizeDelta < 0:
+			cmp.Shrinkers = append(cmp.Shrinkers, delta)
+		}
+	}
+
+	for _, info := range current {
+		if recorded[info.Name] {
+			continue
+		}
+		// New tables are assumed to be dumped with data
+		delta := newDelta(info.Name, 0, info.DataSize+info.IndexSize, 0, info.RowCount)
+		delta.DataIncluded = true
+		cmp.Added = append(cmp.Added, delta)
+		includedDataDelta += info.DataSize
+	}
+
+	sort.Slice(cmp.Growers, func(i, j int) bool { return cmp.Growers[i].SizeDelta > cmp.Growers[j].SizeDelta })
+	sort.Slice(cmp.Shrinkers, func(i, j int) bool { return cmp.Shrinkers[i].SizeDelta < cmp.Shrinkers[j].SizeDelta })
+	sort.Slice(cmp.Added, func(i, j int) bool { return cmp.Added[i].NewSize > cmp.Added[j].NewSize })
+	sort.Slice(cmp.Removed, func(i, j int) bool { return cmp.Removed[i].OldSize > cmp.Removed[j].OldSize })
+
+	if old.FileSize > 0 && oldIncludedData > 0 {
+		ratio := float64(old.FileSize) / float64(oldIncludedData)
+		cmp.ProjectedDumpSize = max(0, old.FileSize+int64(float64(includedDataDelta)*ratio))
+	}
+
+	return cmp
+}
+
+// newDelta builds a TableDelta from old and new sizes and row counts
+func newDelta(name string, oldSize, newSize, oldRows, newRows int64) TableDelta {
+	delta := TableDelta{
+		Name:      name,
+		OldSize:   oldSize,
+		NewSize:   newSize,
+		SizeDelta: newSize - oldSize,
+		OldRows:   oldRows,
+		NewRows:   newRows,
+		RowsDelta: newRows - oldRows,
+	}
+	if oldSize > 0 {
+		delta.SizePercent = float64(delta.SizeDelta) / float64(oldSize) * 100
+	}
+	return delta
+}
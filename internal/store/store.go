@@ -0,0 +1,454 @@
+// Package store keeps dumps in a content-addressed directory. A dump is cut
+// into sections (the structure or data of one table, a phase header) and
+// each section is a chunk named by its SHA-256; a snapshot lists the chunks
+// of one dump in order. Tables that didn't change between dumps produce the
+// same chunk, which is stored once.
+//
+// Layout:
+//
+//	chunks/ab/abcdef…     section contents, named by their SHA-256
+//	snapshots/<id>.json   snapshot manifests
+//	tmp/                  chunks being written
+package store
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/fileutil"
+	"github.com/helgesverre/dbdump/internal/metadata"
+)
+
+// ChunkRef is a section of a dump stored as a chunk
+type ChunkRef struct {
+	Section string `json:"section"`
+	SHA256  string `json:"sha256"`
+	Size    int64  `json:"size"`
+}
+
+// Snapshot is one dump in the store: its chunks in order and the metadata
+// sidecar written with it
+type Snapshot struct {
+	ID        string             `json:"id"`
+	CreatedAt time.Time          `json:"created_at"`
+	Size      int64              `json:"size"`
+	Chunks    []ChunkRef         `json:"chunks"`
+	Metadata  *metadata.Metadata `json:"metadata,omitempty"`
+}
+
+// AddStats tells how much of a dump the store already had
+type AddStats struct {
+	Chunks    int
+	NewChunks int
+	NewBytes  int64
+}
+
+// ErrSnapshotNotFound is returned for a snapshot ID the store doesn't have
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// idPattern restricts snapshot IDs to names that are safe as file names
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// sectionPattern matches the comment lines that start a section of a dump:
+// those mysqldump writes before each table, view and object list, and
+// dbdump's own sample and masked data headers
+var sectionPattern = regexp.MustCompile("^-- (?:(?:Table structure|Dumping data) for table|(?:Temporary|Final) view structure for view|Dumping (?:events|routines) for database|Sample of|Masked (?:data|sample)|MySQL dump)")
+
+// statementPattern matches the statements that start a table's structure,
+// data or a view in dumps written with --skip-comments, as dbdump's are
+var statementPattern = regexp.MustCompile("^(DROP TABLE IF EXISTS|LOCK TABLES|/\\*!50001 DROP VIEW IF EXISTS) (`(?:[^`]|``)+`)")
+
+// statementSections names the sections statementPattern starts
+var statementSections = map[string]string{
+	"DROP TABLE IF EXISTS":         "Table structure for table ",
+	"LOCK TABLES":                  "Dumping data for table ",
+	"/*!50001 DROP VIEW IF EXISTS": "View structure for view ",
+}
+
+// trailerLine starts the settings mysqldump restores at the end of each
+// phase, which are followed by the changing "Dump completed on" line
+const trailerLine = "/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;"
+
+// maxSectionName bounds the section names kept in manifests
+const maxSectionName = 120
+
+// Store is a content-addressed dump store in a directory
+type Store struct {
+	dir string
+}
+
+// Open opens the store in dir, creating it if needed. Stores hold dumps, so
+// they are only accessible to their owner.
+func Open(dir string) (*Store, error) {
+	for _, sub := range []string{"chunks", "snapshots", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create store %s: %w", dir, err)
+		}
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns the store's directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Add cuts the uncompressed dump at path into chunks, stores those the store
+// doesn't have yet and writes a snapshot manifest under id
+func (s *Store) Add(path, id string, meta *metadata.Metadata) (*Snapshot, AddStats, error) {
+	var stats AddStats
+	if !idPattern.MatchString(id) {
+		return nil, stats, fmt.Errorf("invalid snapshot id %q", id)
+	}
+
+	var snapshot *Snapshot
+	err := s.locked(func() error {
+		if _, err := os.Stat(s.snapshotPath(id)); err == nil {
+			return fmt.Errorf("snapshot %s already exists in %s", id, s.dir)
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		snapshot = &Snapshot{ID: id, CreatedAt: time.Now().UTC(), Metadata: meta}
+		if meta != nil && !meta.CreatedAt.IsZero() {
+			snapshot.CreatedAt = meta.CreatedAt
+		}
+		if err := s.split(file, snapshot, &stats); err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(snapshot, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal snapshot: %w", err)
+		}
+		return fileutil.WriteFileAtomic(s.snapshotPath(id), append(data, '\n'), 0600)
+	})
+	if err != nil {
+		return nil, stats, err
+	}
+	return snapshot, stats, nil
+}
+
+// split reads a dump and stores each of its sections as a chunk
+func (s *Store) split(r io.Reader, snapshot *Snapshot, stats *AddStats) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var chunk *chunkWriter
+	lineStart := true
+	// commented is set while a section holds only its comment header, which
+	// the statement that follows belongs to
+	commented := false
+
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			if chunk != nil {
+				chunk.discard()
+			}
+			return fmt.Errorf("failed to read dump: %w", err)
+		}
+
+		starts := lineStart && startsSection(line, commented)
+		if lineStart && len(line) > 0 {
+			commented = starts && sectionPattern.Match(line) ||
+				commented && !starts && (line[0] == '-' || line[0] == '\n')
+		}
+		if len(line) > 0 && (chunk == nil || starts) {
+			first := chunk == nil
+			if !first {
+				if err := s.finish(chunk, snapshot, stats); err != nil {
+					return err
+				}
+			}
+			next, chunkErr := s.newChunk(sectionName(line, first))
+			if chunkErr != nil {
+				return chunkErr
+			}
+			chunk = next
+		}
+		if len(line) > 0 {
+			if _, err := chunk.Write(line); err != nil {
+				chunk.discard()
+				return fmt.Errorf("failed to write chunk: %w", err)
+			}
+			lineStart = line[len(line)-1] == '\n'
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if chunk != nil {
+		return s.finish(chunk, snapshot, stats)
+	}
+	return nil
+}
+
+// startsSection reports whether a line starts a new section; a statement
+// doesn't when it follows a section comment
+func startsSection(line []byte, commented bool) bool {
+	return sectionPattern.Match(line) || strings.HasPrefix(string(line), trailerLine) ||
+		!commented && statementPattern.Match(line)
+}
+
+// sectionName describes a section by its first line
+func sectionName(line []byte, first bool) string {
+	if first && !sectionPattern.Match(line) {
+		return "header"
+	}
+	if strings.HasPrefix(string(line), trailerLine) {
+		return "trailer"
+	}
+	if match := statementPattern.FindSubmatch(line); match != nil {
+		return statementSections[string(match[1])] + string(match[2])
+	}
+	name := strings.TrimSpace(strings.TrimPrefix(string(line), "-- "))
+	if len(name) > maxSectionName {
+		name = name[:maxSectionName]
+	}
+	return name
+}
+
+// chunkWriter writes a chunk to a temporary file while hashing it
+type chunkWriter struct {
+	section string
+	file    *os.File
+	digest  hash.Hash
+	size    int64
+}
+
+// Write implements io.Writer
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	c.digest.Write(p)
+	n, err := c.file.Write(p)
+	c.size += int64(n)
+	return n, err
+}
+
+// discard removes the temporary file
+func (c *chunkWriter) discard() {
+	_ = c.file.Close()
+	_ = os.Remove(c.file.Name())
+}
+
+// newChunk starts a chunk for a section
+func (s *Store) newChunk(section string) (*chunkWriter, error) {
+	file, err := os.CreateTemp(filepath.Join(s.dir, "tmp"), "chunk-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk: %w", err)
+	}
+	return &chunkWriter{section: section, file: file, digest: sha256.New()}, nil
+}
+
+// finish moves a written chunk into place, or drops it when the store
+// already has a chunk with the same contents, and records it in snapshot
+func (s *Store) finish(chunk *chunkWriter, snapshot *Snapshot, stats *AddStats) error {
+	sum := hex.EncodeToString(chunk.digest.Sum(nil))
+	snapshot.Chunks = append(snapshot.Chunks, ChunkRef{Section: chunk.section, SHA256: sum, Size: chunk.size})
+	snapshot.Size += chunk.size
+	stats.Chunks++
+
+	target := s.chunkPath(sum)
+	if _, err := os.Stat(target); err == nil {
+		chunk.discard()
+		return nil
+	}
+
+	if err := fileutil.Sync(chunk.file); err != nil {
+		chunk.discard()
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+	if err := chunk.file.Close(); err != nil {
+		_ = os.Remove(chunk.file.Name())
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		_ = os.Remove(chunk.file.Name())
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	if err := os.Rename(chunk.file.Name(), target); err != nil {
+		_ = os.Remove(chunk.file.Name())
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	stats.NewChunks++
+	stats.NewBytes += chunk.size
+	return nil
+}
+
+// Snapshots returns the snapshots in the store, oldest first
+func (s *Store) Snapshots() ([]*Snapshot, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "snapshots", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*Snapshot
+	for _, path := range paths {
+		snapshot, err := loadSnapshot(path)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Snapshot returns the snapshot with the given id
+func (s *Store) Snapshot(id string) (*Snapshot, error) {
+	if !idPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+	}
+	snapshot, err := loadSnapshot(s.snapshotPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+	}
+	return snapshot, err
+}
+
+// loadSnapshot reads a snapshot manifest
+func loadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+// Extract writes the dump of a snapshot to w, checking each chunk against
+// its SHA-256 and size. On an error, what was written so far is incomplete.
+func (s *Store) Extract(snapshot *Snapshot, w io.Writer) error {
+	for n, ref := range snapshot.Chunks {
+		if err := s.copyChunk(ref, w); err != nil {
+			return fmt.Errorf("chunk %d of %d (%s): %w", n+1, len(snapshot.Chunks), ref.Section, err)
+		}
+	}
+	return nil
+}
+
+// copyChunk copies one chunk to w and verifies it
+func (s *Store) copyChunk(ref ChunkRef, w io.Writer) error {
+	if len(ref.SHA256) != 2*sha256.Size {
+		return fmt.Errorf("invalid chunk reference %q", ref.SHA256)
+	}
+	file, err := os.Open(s.chunkPath(ref.SHA256))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("missing from the store (%s)", ref.SHA256)
+		}
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), file)
+	if err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != ref.SHA256 || size != ref.Size {
+		return fmt.Errorf("corrupt: expected %d bytes with SHA-256 %s, found %d bytes with %s", ref.Size, ref.SHA256, size, sum)
+	}
+	return nil
+}
+
+// Remove deletes a snapshot manifest; its chunks stay until Collect
+func (s *Store) Remove(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+	}
+	return s.locked(func() error {
+		return os.Remove(s.snapshotPath(id))
+	})
+}
+
+// Collect deletes the chunks no snapshot refers to any more, and chunks
+// left behind by interrupted writes. It returns how many chunks it deleted
+// and their size.
+func (s *Store) Collect() (int, int64, error) {
+	var removed int
+	var freed int64
+	err := s.locked(func() error {
+		snapshots, err := s.Snapshots()
+		if err != nil {
+			return err
+		}
+		referenced := make(map[string]bool)
+		for _, snapshot := range snapshots {
+			for _, ref := range snapshot.Chunks {
+				referenced[ref.SHA256] = true
+			}
+		}
+
+		err = filepath.WalkDir(filepath.Join(s.dir, "chunks"), func(path string, entry os.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || referenced[entry.Name()] {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removed++
+			freed += info.Size()
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to collect chunks: %w", err)
+		}
+
+		// Nothing else writes to tmp while the lock is held
+		leftovers, err := filepath.Glob(filepath.Join(s.dir, "tmp", "chunk-*"))
+		if err != nil {
+			return err
+		}
+		for _, path := range leftovers {
+			_ = os.Remove(path)
+		}
+		return nil
+	})
+	return removed, freed, err
+}
+
+// locked runs fn holding the store's lock, so chunks being added are never
+// collected before their snapshot refers to them
+func (s *Store) locked(fn func() error) error {
+	return fileutil.WithLock(filepath.Join(s.dir, "store"), fn)
+}
+
+// chunkPath returns where a chunk with the given SHA-256 is kept
+func (s *Store) chunkPath(sum string) string {
+	return filepath.Join(s.dir, "chunks", sum[:2], sum)
+}
+
+// snapshotPath returns where a snapshot's manifest is kept
+func (s *Store) snapshotPath(id string) string {
+	return filepath.Join(s.dir, "snapshots", id+".json")
+}
@@ -0,0 +1,230 @@
+package structure
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/sqlident"
+	"github.com/helgesverre/dbdump/internal/transform"
+)
+
+// Level is how much of a table's CREATE TABLE statement is kept
+type Level string
+
+const (
+	Full      Level = "full"       // the statement as mysqldump writes it
+	NoIndexes Level = "no-indexes" // without secondary indexes
+	Minimal   Level = "minimal"    // only the columns and the primary key
+)
+
+// ParseLevel validates a structure level; empty means full
+func ParseLevel(s string) (Level, error) {
+	switch level := Level(strings.ToLower(strings.TrimSpace(s))); level {
+	case "":
+		return Full, nil
+	case Full, NoIndexes, Minimal:
+		return level, nil
+	}
+	return "", fmt.Errorf("unknown structure level %q (use full, no-indexes or minimal)", s)
+}
+
+var (
+	createTableLine = regexp.MustCompile("^CREATE TABLE `((?:[^`]|``)+)`")
+	referencesTable = regexp.MustCompile("(?i)\\bREFERENCES\\s+`((?:[^`]|``)+)`")
+	autoIncrement   = regexp.MustCompile(`(?i)\bAUTO_INCREMENT\b`)
+	keyColumns      = regexp.MustCompile("(?i)^[A-Z ]*?(?:`(?:[^`]|``)+`\\s*)?\\(`((?:[^`]|``)+)`")
+	columnName      = regexp.MustCompile("^`((?:[^`]|``)+)`")
+)
+
+// Transform returns the transform reducing each CREATE TABLE statement to
+// its table's structure level. levels maps table names to their level;
+// tables not listed are left alone. excluded tables have no data in the
+// dump, so foreign keys to them are dropped at the no-indexes level.
+func Transform(levels map[string]Level, excluded map[string]bool) transform.Func {
+	return func(statement []byte) []byte {
+		match := createTableLine.FindSubmatch(statement)
+		if match == nil {
+			return statement
+		}
+		level := levels[sqlident.Unescape(string(match[1]))]
+		if level == "" || level == Full {
+			return statement
+		}
+		return []byte(Rewrite(string(statement), level, excluded))
+	}
+}
+
+// Rewrite reduces one CREATE TABLE statement to a structure level. The
+// statement is returned unchanged when it can't be parsed.
+func Rewrite(statement string, level Level, excluded map[string]bool) string {
+	name := createTableLine.FindStringIndex(statement)
+	if name == nil || level == Full {
+		return statement
+	}
+	open := strings.IndexByte(statement[name[1]:], '(')
+	if open < 0 {
+		return statement
+	}
+	open += name[1]
+	end := matchingParen(statement, open)
+	if end < 0 {
+		return statement
+	}
+
+	definitions := splitTopLevel(statement[open+1 : end])
+
+	// The AUTO_INCREMENT column must stay the first column of some key
+	autoColumn := ""
+	for _, def := range definitions {
+		if match := columnName.FindStringSubmatch(def); match != nil && autoIncrement.MatchString(def) {
+			autoColumn = match[1]
+		}
+	}
+	autoKeyed := false
+	for _, def := range definitions {
+		if strings.HasPrefix(strings.ToUpper(def), "PRIMARY KEY") && firstKeyColumn(def) == autoColumn {
+			autoKeyed = true
+		}
+	}
+
+	var kept []string
+	for _, def := range definitions {
+		upper := strings.ToUpper(def)
+		keep := true
+		switch {
+		case strings.HasPrefix(def, "`"), strings.HasPrefix(upper, "PRIMARY KEY"):
+			// Columns and the primary key are always kept
+		case isSecondaryIndex(upper):
+			keep = autoColumn != "" && !autoKeyed && firstKeyColumn(def) == autoColumn
+			if keep {
+				autoKeyed = true
+			}
+		case strings.Contains(upper, "FOREIGN KEY"):
+			keep = level == NoIndexes && !referencesExcluded(def, excluded)
+		case level == Minimal:
+			// CHECK constraints and anything else unrecognized
+			keep = false
+		}
+		if keep {
+			kept = append(kept, def)
+		}
+	}
+
+	return statement[:open] + "(\n  " + strings.Join(kept, ",\n  ") + "\n" + statement[end:]
+}
+
+// isSecondaryIndex reports whether an upper-cased definition declares an
+// index other than the primary key
+func isSecondaryIndex(upper string) bool {
+	for _, prefix := range []string{"KEY ", "INDEX ", "UNIQUE ", "FULLTEXT ", "SPATIAL "} {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstKeyColumn returns the first column of an index definition, or ""
+// when its first key part is an expression (a functional index)
+func firstKeyColumn(def string) string {
+	if match := keyColumns.FindStringSubmatch(def); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// referencesExcluded reports whether a foreign key points at a table whose
+// data is excluded as well
+func referencesExcluded(def string, excluded map[string]bool) bool {
+	match := referencesTable.FindStringSubmatch(def)
+	return match != nil && excluded[sqlident.Unescape(match[1])]
+}
+
+// scanner tracks quotes and comments while walking SQL text
+type scanner struct {
+	quote   byte // ', " or ` while inside a quoted string or identifier
+	comment bool // inside /* ... */
+	escaped bool
+}
+
+// step advances over s[i] and reports whether it is outside quotes and
+// comments; it may consume an extra byte for comment delimiters
+func (sc *scanner) step(s string, i *int) bool {
+	c := s[*i]
+	switch {
+	case sc.comment:
+		if c == '*' && *i+1 < len(s) && s[*i+1] == '/' {
+			sc.comment = false
+			*i++
+		}
+		return false
+	case sc.quote != 0:
+		switch {
+		case sc.escaped:
+			sc.escaped = false
+		case c == '\\' && sc.quote != '`':
+			sc.escaped = true
+		case c == sc.quote:
+			sc.quote = 0
+		}
+		return false
+	case c == '\'' || c == '"' || c == '`':
+		sc.quote = c
+		return false
+	case c == '/' && *i+1 < len(s) && s[*i+1] == '*':
+		sc.comment = true
+		*i++
+		return false
+	}
+	return true
+}
+
+// matchingParen returns the index of the parenthesis closing the one at open
+func matchingParen(s string, open int) int {
+	var sc scanner
+	depth := 0
+	for i := open; i < len(s); i++ {
+		if !sc.step(s, &i) {
+			continue
+		}
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits a table body at commas outside parentheses, quotes
+// and comments, returning the trimmed definitions
+func splitTopLevel(body string) []string {
+	var sc scanner
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(body); i++ {
+		if !sc.step(body, &i) {
+			continue
+		}
+		switch body[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if rest := strings.TrimSpace(body[start:]); rest != "" {
+		parts = append(parts, rest)
+	}
+	return parts
+}
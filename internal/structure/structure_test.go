@@ -0,0 +1,240 @@
+package structure
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/transform"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files of testdata")
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Level
+		wantErr bool
+	}{
+		{input: "", want: Full},
+		{input: "full", want: Full},
+		{input: "no-indexes", want: NoIndexes},
+		{input: " Minimal ", want: Minimal},
+		{input: "NO-INDEXES", want: NoIndexes},
+		{input: "no_indexes", wantErr: true},
+		{input: "none", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLevel(tt.input)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseLevel(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// table builds a CREATE TABLE statement of mysqldump's layout
+func table(name string, definitions ...string) string {
+	statement := "CREATE TABLE `" + name + "` (\n"
+	for i, def := range definitions {
+		statement += "  " + def
+		if i < len(definitions)-1 {
+			statement += ","
+		}
+		statement += "\n"
+	}
+	return statement + ") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;"
+}
+
+func TestRewrite(t *testing.T) {
+	excluded := map[string]bool{"sessions": true, "audit_log": true}
+
+	tests := []struct {
+		name      string
+		statement string
+		level     Level
+		want      string
+	}{
+		{
+			name:      "full is unchanged",
+			statement: table("t", "`id` int NOT NULL", "`a` int", "PRIMARY KEY (`id`)", "KEY `a` (`a`)"),
+			level:     Full,
+			want:      table("t", "`id` int NOT NULL", "`a` int", "PRIMARY KEY (`id`)", "KEY `a` (`a`)"),
+		},
+		{
+			name:      "secondary indexes",
+			statement: table("t", "`id` int NOT NULL", "`a` int", "`b` text", "PRIMARY KEY (`id`)", "UNIQUE KEY `a` (`a`)", "KEY `ab` (`a`,`b`(10))", "INDEX `b` (`b`(4))", "FULLTEXT KEY `ft` (`b`)", "SPATIAL KEY `sp` (`g`)"),
+			level:     NoIndexes,
+			want:      table("t", "`id` int NOT NULL", "`a` int", "`b` text", "PRIMARY KEY (`id`)"),
+		},
+		{
+			name:      "no primary key",
+			statement: table("t", "`a` int", "KEY `a` (`a`)"),
+			level:     Minimal,
+			want:      table("t", "`a` int"),
+		},
+		{
+			name:      "foreign key to a dumped table is kept",
+			statement: table("t", "`user_id` int", "KEY `user_id` (`user_id`)", "CONSTRAINT `fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE"),
+			level:     NoIndexes,
+			want:      table("t", "`user_id` int", "CONSTRAINT `fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE"),
+		},
+		{
+			name:      "foreign key to an excluded table is dropped",
+			statement: table("t", "`session_id` int", "CONSTRAINT `fk` FOREIGN KEY (`session_id`) REFERENCES `sessions` (`id`)"),
+			level:     NoIndexes,
+			want:      table("t", "`session_id` int"),
+		},
+		{
+			name:      "foreign keys are dropped at minimal",
+			statement: table("t", "`user_id` int", "CONSTRAINT `fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)"),
+			level:     Minimal,
+			want:      table("t", "`user_id` int"),
+		},
+		{
+			name:      "unnamed foreign key",
+			statement: table("t", "`user_id` int", "FOREIGN KEY (`user_id`) REFERENCES `audit_log` (`id`)", "FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)"),
+			level:     NoIndexes,
+			want:      table("t", "`user_id` int", "FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)"),
+		},
+		{
+			name:      "check constraints",
+			statement: table("t", "`a` int", "CONSTRAINT `positive` CHECK ((`a` > 0))"),
+			level:     NoIndexes,
+			want:      table("t", "`a` int", "CONSTRAINT `positive` CHECK ((`a` > 0))"),
+		},
+		{
+			name:      "check constraints at minimal",
+			statement: table("t", "`a` int", "CONSTRAINT `positive` CHECK ((`a` > 0))"),
+			level:     Minimal,
+			want:      table("t", "`a` int"),
+		},
+		{
+			name:      "auto increment outside the primary key keeps its index",
+			statement: table("t", "`seq` int NOT NULL AUTO_INCREMENT", "`uuid` char(36) NOT NULL", "PRIMARY KEY (`uuid`)", "KEY `other` (`uuid`)", "KEY `seq` (`seq`)", "UNIQUE KEY `seq2` (`seq`)"),
+			level:     Minimal,
+			want:      table("t", "`seq` int NOT NULL AUTO_INCREMENT", "`uuid` char(36) NOT NULL", "PRIMARY KEY (`uuid`)", "KEY `seq` (`seq`)"),
+		},
+		{
+			name:      "auto increment first in the primary key",
+			statement: table("t", "`id` int NOT NULL AUTO_INCREMENT", "PRIMARY KEY (`id`,`part`)", "KEY `id` (`id`)"),
+			level:     NoIndexes,
+			want:      table("t", "`id` int NOT NULL AUTO_INCREMENT", "PRIMARY KEY (`id`,`part`)"),
+		},
+		{
+			name:      "functional index isn't the auto increment key",
+			statement: table("t", "`id` int NOT NULL AUTO_INCREMENT", "KEY `f` ((`id` + 1))", "KEY `id` (`id`)"),
+			level:     NoIndexes,
+			want:      table("t", "`id` int NOT NULL AUTO_INCREMENT", "KEY `id` (`id`)"),
+		},
+		{
+			name:      "commas and parentheses in strings and comments",
+			statement: table("t", "`a` enum('x,y','(') COMMENT 'a, (b'", "`b` int /* KEY `c` (`c`), */ DEFAULT '0'", "`c` varchar(9) DEFAULT 'it\\'s, )'", "KEY `a` (`a`)"),
+			level:     NoIndexes,
+			want:      table("t", "`a` enum('x,y','(') COMMENT 'a, (b'", "`b` int /* KEY `c` (`c`), */ DEFAULT '0'", "`c` varchar(9) DEFAULT 'it\\'s, )'"),
+		},
+		{
+			name:      "quoted names",
+			statement: table("order items", "`key` int", "`a``b` int", "KEY `key` (`key`)", "KEY `a``b` (`a``b`)"),
+			level:     Minimal,
+			want:      table("order items", "`key` int", "`a``b` int"),
+		},
+		{
+			name:      "not a create table",
+			statement: "INSERT INTO `t` VALUES ('CREATE TABLE `x` (KEY `k` (`a`))');",
+			level:     Minimal,
+			want:      "INSERT INTO `t` VALUES ('CREATE TABLE `x` (KEY `k` (`a`))');",
+		},
+		{
+			name:      "unbalanced parentheses are left alone",
+			statement: "CREATE TABLE `t` (\n  `a` int,\n  KEY `a` (`a`\n",
+			level:     Minimal,
+			want:      "CREATE TABLE `t` (\n  `a` int,\n  KEY `a` (`a`\n",
+		},
+		{
+			name:      "no body is left alone",
+			statement: "CREATE TABLE `t` LIKE `u`;",
+			level:     Minimal,
+			want:      "CREATE TABLE `t` LIKE `u`;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Rewrite(tt.statement, tt.level, excluded)
+			if got != tt.want {
+				t.Errorf("Rewrite() =\n%s\nwant\n%s", got, tt.want)
+			}
+			// A rewritten statement is rewritten to itself
+			if again := Rewrite(got, tt.level, excluded); again != got {
+				t.Errorf("Rewrite() of its own output =\n%s\nwant\n%s", again, got)
+			}
+		})
+	}
+}
+
+// TestTransformGolden runs a mysqldump structure dump through the
+// transform at each level and compares the output with the .golden files;
+// run with -update to rewrite them after checking the diff
+func TestTransformGolden(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("testdata", "shop.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	excluded := map[string]bool{"sessions": true, "audit_log": true, "order items": true}
+
+	for _, level := range []Level{Full, NoIndexes, Minimal} {
+		t.Run(string(level), func(t *testing.T) {
+			levels := map[string]Level{"users": level, "sessions": level, "audit_log": level, "order items": level}
+			var want []byte
+			for _, chunk := range []int{1, 7, 64, len(input)} {
+				got := rewriteStream(t, input, chunk, Transform(levels, excluded))
+				if want == nil {
+					want = got
+					golden := filepath.Join("testdata", "shop."+string(level)+".golden")
+					if *update {
+						if err := os.WriteFile(golden, got, 0o644); err != nil {
+							t.Fatal(err)
+						}
+					}
+					expected, err := os.ReadFile(golden)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(got, expected) {
+						t.Errorf("output differs from %s:\n%s", golden, got)
+					}
+				} else if !bytes.Equal(got, want) {
+					t.Errorf("in chunks of %d: output differs from the whole", chunk)
+				}
+			}
+		})
+	}
+
+	// Tables without a level keep their statement
+	if got := rewriteStream(t, input, len(input), Transform(nil, excluded)); !bytes.Equal(got, input) {
+		t.Error("Transform() without levels changed the dump")
+	}
+}
+
+// rewriteStream writes input through a transform writer, chunk bytes at a time
+func rewriteStream(t *testing.T, input []byte, chunk int, funcs ...transform.Func) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w := transform.NewWriter(&out, funcs...)
+	for data := input; len(data) > 0; {
+		n := min(chunk, len(data))
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
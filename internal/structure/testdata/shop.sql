@@ -0,0 +1,87 @@
+-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)
+--
+-- Host: 127.0.0.1    Database: shop
+-- ------------------------------------------------------
+
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40101 SET NAMES utf8mb4 */;
+
+--
+-- Table structure for table `users`
+--
+
+DROP TABLE IF EXISTS `users`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `users` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `email` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL COMMENT 'login, unique (case-insensitive)',
+  `name` varchar(255) NOT NULL DEFAULT '' COMMENT 'it''s shown as "name, first"',
+  `settings` json DEFAULT NULL,
+  `email_domain` varchar(255) GENERATED ALWAYS AS (substring_index(`email`,_utf8mb4'@',-(1))) VIRTUAL,
+  `created_at` timestamp NULL DEFAULT NULL /*!80023 INVISIBLE */,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `users_email_unique` (`email`),
+  KEY `users_name_created_at_index` (`name`(32),`created_at`),
+  KEY `users_lower_email` ((lower(`email`))),
+  FULLTEXT KEY `users_name_fulltext` (`name`),
+  CONSTRAINT `users_settings_valid` CHECK (json_valid(`settings`))
+) ENGINE=InnoDB AUTO_INCREMENT=1201 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='accounts (one per person)';
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `sessions`
+--
+
+DROP TABLE IF EXISTS `sessions`;
+CREATE TABLE `sessions` (
+  `id` varchar(255) NOT NULL,
+  `user_id` bigint unsigned DEFAULT NULL,
+  `payload` longtext NOT NULL,
+  `last_activity` int NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `sessions_user_id_index` (`user_id`),
+  KEY `sessions_last_activity_index` (`last_activity`),
+  CONSTRAINT `sessions_user_id_foreign` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+--
+-- Table structure for table `audit_log`
+--
+
+DROP TABLE IF EXISTS `audit_log`;
+CREATE TABLE `audit_log` (
+  `seq` int NOT NULL AUTO_INCREMENT,
+  `uuid` char(36) NOT NULL,
+  `session_id` varchar(255) DEFAULT NULL,
+  `user_id` bigint unsigned DEFAULT NULL,
+  `event` enum('login','logout','a,b') NOT NULL,
+  PRIMARY KEY (`uuid`),
+  KEY `audit_log_seq` (`seq`),
+  KEY `audit_log_user` (`user_id`),
+  CONSTRAINT `audit_log_session_foreign` FOREIGN KEY (`session_id`) REFERENCES `sessions` (`id`),
+  CONSTRAINT `audit_log_user_foreign` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
+/*!50100 PARTITION BY KEY (`uuid`)
+PARTITIONS 4 */;
+
+--
+-- Table structure for table `order items`
+--
+
+DROP TABLE IF EXISTS `order items`;
+CREATE TABLE `order items` (
+  `order_id` int NOT NULL,
+  `line` int NOT NULL,
+  `sku` varchar(64) NOT NULL,
+  PRIMARY KEY (`order_id`,`line`),
+  KEY `sku` (`sku`) USING BTREE COMMENT 'lookups, by sku'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+--
+-- Dumping data for table `users`
+--
+
+LOCK TABLES `users` WRITE;
+INSERT INTO `users` VALUES (1,'a@example.com','CREATE TABLE `x` (KEY `k` (`a`))','{}',NULL,NULL);
+UNLOCK TABLES;
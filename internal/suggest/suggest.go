@@ -0,0 +1,141 @@
+// Package suggest scores tables as candidates for data exclusion, from
+// their names and, when available, from how the application uses them
+package suggest
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Source tells which evidence a suggestion is based on
+type Source string
+
+const (
+	SourceName Source = "name"    // the name matches an exclusion rule
+	SourceIO   Source = "io"      // IO statistics show the table is written but not read
+	SourceBoth Source = "name+io" // both
+)
+
+// Thresholds used by Score
+const (
+	// MinWrites is the number of written rows below which IO statistics are
+	// too thin to judge a table by
+	MinWrites = 1000
+
+	// WriteOnlyRatio is the reads-per-write ratio below which a table counts
+	// as write-mostly
+	WriteOnlyRatio = 0.01
+
+	// MinScore is the lowest score that is suggested
+	MinScore = 30
+
+	// largeTable earns a small bonus, since excluding it saves the most
+	largeTable = 100 << 20
+)
+
+// IOStats are a table's row operations since the server started (or the
+// statistics were truncated)
+type IOStats struct {
+	Reads  int64 // rows fetched
+	Writes int64 // rows inserted, updated or deleted
+}
+
+// Metrics are the facts about a table a suggestion is scored from
+type Metrics struct {
+	Table    string
+	DataSize int64
+	NameRule string   // exclusion rule the name matches, "" if none
+	IO       *IOStats // nil when IO statistics are unavailable
+}
+
+// Suggestion is a table proposed for data exclusion
+type Suggestion struct {
+	Table    string   `json:"table"`
+	Score    int      `json:"score"` // 0-100
+	Source   Source   `json:"source"`
+	Reasons  []string `json:"reasons"`
+	DataSize int64    `json:"data_size"`
+
+	// Confidence is that of the matching exclusion rule, or medium for
+	// tables suggested from IO statistics alone, as their rule would be
+	Confidence string `json:"confidence"`
+}
+
+// Score scores one table; ok is false when it is not worth suggesting
+func Score(m Metrics) (suggestion Suggestion, ok bool) {
+	score := 0
+	var reasons []string
+	nameHit, ioHit := false, false
+
+	if m.NameRule != "" {
+		score += 50
+		nameHit = true
+		reasons = append(reasons, "name matches exclusion rule "+m.NameRule)
+	}
+
+	if m.IO != nil && m.IO.Writes >= MinWrites {
+		ratio := float64(m.IO.Reads) / float64(m.IO.Writes)
+		switch {
+		case m.IO.Reads == 0:
+			score += 45
+			ioHit = true
+			reasons = append(reasons, fmt.Sprintf("%d rows written, never read", m.IO.Writes))
+		case ratio < WriteOnlyRatio:
+			score += 35
+			ioHit = true
+			reasons = append(reasons, fmt.Sprintf("%d rows written, %d read", m.IO.Writes, m.IO.Reads))
+		case ratio > 1 && nameHit:
+			// The name says disposable, but the application relies on the data
+			score -= 20
+			reasons = append(reasons, fmt.Sprintf("read by the application (%d rows read, %d written)", m.IO.Reads, m.IO.Writes))
+		}
+	}
+
+	if score > 0 && m.DataSize >= largeTable {
+		score += 5
+	}
+	score = min(max(score, 0), 100)
+
+	if score < MinScore || (!nameHit && !ioHit) {
+		return Suggestion{}, false
+	}
+
+	source := SourceName
+	switch {
+	case nameHit && ioHit:
+		source = SourceBoth
+	case ioHit:
+		source = SourceIO
+	}
+
+	return Suggestion{
+		Table:    m.Table,
+		Score:    score,
+		Source:   source,
+		Reasons:  reasons,
+		DataSize: m.DataSize,
+	}, true
+}
+
+// Rank scores every table and returns the suggestions, best first (ties
+// broken by size, then name)
+func Rank(metrics []Metrics) []Suggestion {
+	var suggestions []Suggestion
+	for _, m := range metrics {
+		if suggestion, ok := Score(m); ok {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	sort.Slice(suggestions, func(a, b int) bool {
+		sa, sb := suggestions[a], suggestions[b]
+		if sa.Score != sb.Score {
+			return sa.Score > sb.Score
+		}
+		if sa.DataSize != sb.DataSize {
+			return sa.DataSize > sb.DataSize
+		}
+		return sa.Table < sb.Table
+	})
+	return suggestions
+}
@@ -0,0 +1,153 @@
+package suggest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	const large = 200 << 20
+
+	tests := []struct {
+		name    string
+		metrics Metrics
+		want    Suggestion
+		wantOK  bool
+	}{
+		{name: "nothing known", metrics: Metrics{Table: "users", DataSize: large}},
+		{
+			name:    "name only",
+			metrics: Metrics{Table: "sessions", NameRule: "sessions", DataSize: 1 << 20},
+			want:    Suggestion{Table: "sessions", Score: 50, Source: SourceName, Reasons: []string{"name matches exclusion rule sessions"}, DataSize: 1 << 20},
+			wantOK:  true,
+		},
+		{
+			name:    "name of a large table",
+			metrics: Metrics{Table: "telescope_entries", NameRule: "telescope_*", DataSize: large},
+			want:    Suggestion{Table: "telescope_entries", Score: 55, Source: SourceName, Reasons: []string{"name matches exclusion rule telescope_*"}, DataSize: large},
+			wantOK:  true,
+		},
+		{
+			name:    "never read",
+			metrics: Metrics{Table: "events", IO: &IOStats{Writes: 50000}},
+			want:    Suggestion{Table: "events", Score: 45, Source: SourceIO, Reasons: []string{"50000 rows written, never read"}},
+			wantOK:  true,
+		},
+		{
+			name:    "write-mostly",
+			metrics: Metrics{Table: "events", IO: &IOStats{Reads: 40, Writes: 50000}},
+			want:    Suggestion{Table: "events", Score: 35, Source: SourceIO, Reasons: []string{"50000 rows written, 40 read"}},
+			wantOK:  true,
+		},
+		{
+			// 1 read per 100 writes is no longer write-mostly
+			name:    "at the ratio",
+			metrics: Metrics{Table: "events", IO: &IOStats{Reads: 500, Writes: 50000}},
+		},
+		{
+			name:    "too few writes to judge",
+			metrics: Metrics{Table: "events", IO: &IOStats{Writes: MinWrites - 1}},
+		},
+		{
+			name:    "just enough writes",
+			metrics: Metrics{Table: "events", IO: &IOStats{Writes: MinWrites}},
+			want:    Suggestion{Table: "events", Score: 45, Source: SourceIO, Reasons: []string{"1000 rows written, never read"}},
+			wantOK:  true,
+		},
+		{
+			name:    "read by the application",
+			metrics: Metrics{Table: "orders", IO: &IOStats{Reads: 90000, Writes: 5000}},
+		},
+		{
+			name:    "name and never read",
+			metrics: Metrics{Table: "audit_log", NameRule: "*_log", DataSize: large, IO: &IOStats{Writes: 2000000}},
+			want: Suggestion{
+				Table: "audit_log", Score: 100, Source: SourceBoth, DataSize: large,
+				Reasons: []string{"name matches exclusion rule *_log", "2000000 rows written, never read"},
+			},
+			wantOK: true,
+		},
+		{
+			name:    "name and write-mostly",
+			metrics: Metrics{Table: "jobs", NameRule: "jobs", IO: &IOStats{Reads: 10, Writes: 5000}},
+			want: Suggestion{
+				Table: "jobs", Score: 85, Source: SourceBoth,
+				Reasons: []string{"name matches exclusion rule jobs", "5000 rows written, 10 read"},
+			},
+			wantOK: true,
+		},
+		{
+			// The name says disposable, but the application relies on it
+			name:    "name but read",
+			metrics: Metrics{Table: "cache", NameRule: "cache", IO: &IOStats{Reads: 80000, Writes: 4000}},
+			want: Suggestion{
+				Table: "cache", Score: 30, Source: SourceName,
+				Reasons: []string{"name matches exclusion rule cache", "read by the application (80000 rows read, 4000 written)"},
+			},
+			wantOK: true,
+		},
+		{
+			name:    "name and few writes",
+			metrics: Metrics{Table: "cache", NameRule: "cache", IO: &IOStats{Reads: 80000, Writes: 10}},
+			want:    Suggestion{Table: "cache", Score: 50, Source: SourceName, Reasons: []string{"name matches exclusion rule cache"}},
+			wantOK:  true,
+		},
+		{
+			// Empty statistics (a truncated performance_schema) change nothing
+			name:    "empty statistics",
+			metrics: Metrics{Table: "sessions", NameRule: "sessions", IO: &IOStats{}},
+			want:    Suggestion{Table: "sessions", Score: 50, Source: SourceName, Reasons: []string{"name matches exclusion rule sessions"}},
+			wantOK:  true,
+		},
+		{
+			name:    "large alone isn't a reason",
+			metrics: Metrics{Table: "orders", DataSize: large, IO: &IOStats{Reads: 10, Writes: 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Score(tt.metrics)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Score() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestScoreWithoutIO checks that tables score the same by name whether IO
+// statistics are unavailable or show nothing to go by
+func TestScoreWithoutIO(t *testing.T) {
+	for _, io := range []*IOStats{nil, {}, {Reads: 5, Writes: 5}} {
+		got, ok := Score(Metrics{Table: "sessions", NameRule: "sessions", IO: io})
+		want, _ := Score(Metrics{Table: "sessions", NameRule: "sessions"})
+		if !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("Score() with %+v = %+v, %v; want %+v", io, got, ok, want)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	metrics := []Metrics{
+		{Table: "users", DataSize: 500 << 20, IO: &IOStats{Reads: 1 << 30, Writes: 1 << 20}},
+		{Table: "sessions", NameRule: "sessions", DataSize: 2 << 20},
+		{Table: "jobs", NameRule: "jobs", DataSize: 1 << 20},
+		{Table: "cache", NameRule: "cache", DataSize: 2 << 20},
+		{Table: "events", DataSize: 10 << 20, IO: &IOStats{Writes: 50000}},
+		{Table: "audit_log", NameRule: "*_log", DataSize: 300 << 20, IO: &IOStats{Reads: 1, Writes: 1 << 20}},
+	}
+
+	var got []string
+	for _, suggestion := range Rank(metrics) {
+		got = append(got, suggestion.Table)
+	}
+	// By score, then size, then name
+	want := []string{"audit_log", "cache", "sessions", "jobs", "events"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rank() = %q, want %q", got, want)
+	}
+
+	if suggestions := Rank(nil); suggestions != nil {
+		t.Errorf("Rank(nil) = %v, want none", suggestions)
+	}
+}
@@ -0,0 +1,100 @@
+// Package tags parses, validates and matches the key=value labels attached
+// to dumps with --tag.
+package tags
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+// Limits on tag keys and values
+const (
+	MaxKeyLength   = 32
+	MaxValueLength = 128
+)
+
+// keyPattern allows keys such as purpose, ticket_id or git.branch
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]*$`)
+
+// ParseTag splits and validates a single key=value tag
+func ParseTag(spec string) (key, value string, err error) {
+	key, value, found := strings.Cut(spec, "=")
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+
+	switch {
+	case !found:
+		return "", "", fmt.Errorf("tag %q must have the form key=value", spec)
+	case key == "":
+		return "", "", fmt.Errorf("tag %q has an empty key", spec)
+	case len(key) > MaxKeyLength:
+		return "", "", fmt.Errorf("tag key %q is longer than %d characters", key, MaxKeyLength)
+	case !keyPattern.MatchString(key):
+		return "", "", fmt.Errorf("tag key %q must start with a lowercase letter and contain only a-z, 0-9, '_', '.' and '-'", key)
+	case value == "":
+		return "", "", fmt.Errorf("tag %q has an empty value", spec)
+	case len(value) > MaxValueLength:
+		return "", "", fmt.Errorf("value of tag %q is longer than %d characters", key, MaxValueLength)
+	case strings.IndexFunc(value, unicode.IsControl) >= 0:
+		return "", "", fmt.Errorf("value of tag %q contains control characters", key)
+	}
+	return key, value, nil
+}
+
+// Parse validates --tag values and returns them as a map, reporting every
+// invalid or repeated tag as a configuration error
+func Parse(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	parsed := make(map[string]string, len(specs))
+	var problems []string
+	for _, spec := range specs {
+		key, value, err := ParseTag(spec)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if previous, ok := parsed[key]; ok && previous != value {
+			problems = append(problems, fmt.Sprintf("tag %q is given twice (%q and %q)", key, previous, value))
+			continue
+		}
+		parsed[key] = value
+	}
+
+	if len(problems) > 0 {
+		return nil, &dberrors.ErrConfigInvalid{Source: "--tag", Problems: problems}
+	}
+	return parsed, nil
+}
+
+// Format renders tags as "key=value, key=value" sorted by key
+func Format(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + tags[key]
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Matches reports whether tags contain every key=value in filter
+func Matches(tags, filter map[string]string) bool {
+	for key, value := range filter {
+		if tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
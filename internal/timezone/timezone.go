@@ -0,0 +1,151 @@
+// Package timezone compares the time zones of a MySQL server and the
+// client, which decide how TIMESTAMP values are written into a dump
+package timezone
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Zone is a time zone as known on one side: by IANA name when possible,
+// otherwise by an abbreviation or offset, and its UTC offset when read
+type Zone struct {
+	Name   string
+	Offset time.Duration
+
+	// Location is set when Name is an IANA zone this system knows, which
+	// lets zones be compared across daylight-saving changes
+	Location *time.Location
+}
+
+// String describes the zone with its offset, e.g. "Europe/Oslo (+02:00)"
+func (z Zone) String() string {
+	return fmt.Sprintf("%s (%s)", z.Name, FormatOffset(z.Offset))
+}
+
+// utcNames are the spellings of UTC that Normalize folds together
+var utcNames = map[string]bool{
+	"UTC": true, "GMT": true, "Z": true, "UCT": true, "UNIVERSAL": true, "ZULU": true,
+	"ETC/UTC": true, "ETC/GMT": true, "ETC/UCT": true, "ETC/UNIVERSAL": true, "ETC/ZULU": true,
+	"GMT0": true, "ETC/GMT0": true, "GREENWICH": true, "+00:00": true, "-00:00": true,
+}
+
+// offsetPattern matches the offsets MySQL accepts for time_zone: "+1:00", "-05:30"
+var offsetPattern = regexp.MustCompile(`^([+-])(\d{1,2}):(\d{2})$`)
+
+// Normalize returns a canonical spelling of a zone name: "UTC" for all of
+// its aliases, offsets with two-digit hours, and other names unchanged
+func Normalize(name string) string {
+	name = strings.TrimSpace(name)
+	if utcNames[strings.ToUpper(name)] {
+		return "UTC"
+	}
+	if match := offsetPattern.FindStringSubmatch(name); match != nil {
+		normalized := fmt.Sprintf("%s%02s:%s", match[1], match[2], match[3])
+		if normalized[1:] == "00:00" {
+			return "UTC"
+		}
+		return normalized
+	}
+	return name
+}
+
+// ParseOffset reads an offset written by FormatOffset
+func ParseOffset(s string) (time.Duration, bool) {
+	match := offsetPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return 0, false
+	}
+	hours, _ := strconv.Atoi(match[2])
+	minutes, _ := strconv.Atoi(match[3])
+	offset := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+	if match[1] == "-" {
+		offset = -offset
+	}
+	return offset, true
+}
+
+// FormatOffset writes a UTC offset as MySQL does, e.g. "+02:00"
+func FormatOffset(offset time.Duration) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	minutes := int(offset.Round(time.Minute) / time.Minute)
+	return fmt.Sprintf("%s%02d:%02d", sign, minutes/60, minutes%60)
+}
+
+// Server describes a server's zone from @@time_zone (SYSTEM meaning
+// @@system_time_zone) and its current UTC offset
+func Server(timeZone, systemTimeZone string, offset time.Duration) Zone {
+	name := Normalize(timeZone)
+	if strings.EqualFold(name, "SYSTEM") {
+		name = Normalize(systemTimeZone)
+	}
+	return Zone{Name: name, Offset: offset, Location: load(name)}
+}
+
+// Client describes the local time zone at now: named after $TZ or the
+// /etc/localtime link when set, otherwise by its abbreviation
+func Client(now time.Time) Zone {
+	abbreviation, seconds := now.In(time.Local).Zone()
+	zone := Zone{Name: Normalize(abbreviation), Offset: time.Duration(seconds) * time.Second}
+
+	name := strings.TrimPrefix(os.Getenv("TZ"), ":")
+	if name == "" {
+		if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+			if _, after, ok := strings.Cut(target, "zoneinfo/"); ok {
+				name = after
+			}
+		}
+	}
+	if name != "" {
+		if location := load(Normalize(name)); location != nil {
+			zone.Name, zone.Location = Normalize(name), location
+		}
+	}
+	return zone
+}
+
+// load returns the location of an IANA zone name, or nil for offsets,
+// abbreviations and zones this system doesn't know
+func load(name string) *time.Location {
+	if name == "UTC" {
+		return time.UTC
+	}
+	if !strings.Contains(name, "/") {
+		return nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	return location
+}
+
+// Same reports whether two zones give the same local times. Zones with the
+// same name do; two named zones do when their offsets agree throughout the
+// year around now (Europe/Oslo and Europe/Berlin); otherwise only the
+// offsets read at the time are compared.
+func Same(a, b Zone, now time.Time) bool {
+	if a.Name == b.Name {
+		return true
+	}
+	if a.Location == nil || b.Location == nil {
+		return a.Offset == b.Offset
+	}
+	for month := range 12 {
+		at := now.AddDate(0, month, 0)
+		_, offsetA := at.In(a.Location).Zone()
+		_, offsetB := at.In(b.Location).Zone()
+		if offsetA != offsetB {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package timezone
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"UTC", "UTC"},
+		{"utc", "UTC"},
+		{" GMT ", "UTC"},
+		{"Etc/UTC", "UTC"},
+		{"Z", "UTC"},
+		{"+00:00", "UTC"},
+		{"-00:00", "UTC"},
+		{"+0:00", "UTC"},
+		{"+2:00", "+02:00"},
+		{"-05:30", "-05:30"},
+		{"+13:00", "+13:00"},
+		{"SYSTEM", "SYSTEM"},
+		{"Europe/Oslo", "Europe/Oslo"},
+		{"CEST", "CEST"},
+		{"+2", "+2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOffset(t *testing.T) {
+	tests := []struct {
+		in     string
+		offset time.Duration
+		out    string
+		ok     bool
+	}{
+		{in: "+00:00", offset: 0, out: "+00:00", ok: true},
+		{in: "+02:00", offset: 2 * time.Hour, out: "+02:00", ok: true},
+		{in: "+2:00", offset: 2 * time.Hour, out: "+02:00", ok: true},
+		{in: "-05:30", offset: -5*time.Hour - 30*time.Minute, out: "-05:30", ok: true},
+		{in: "+05:45", offset: 5*time.Hour + 45*time.Minute, out: "+05:45", ok: true},
+		{in: "UTC"},
+		{in: "0200"},
+		{in: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			offset, ok := ParseOffset(tt.in)
+			if offset != tt.offset || ok != tt.ok {
+				t.Fatalf("ParseOffset(%q) = %v, %v; want %v, %v", tt.in, offset, ok, tt.offset, tt.ok)
+			}
+			if ok && FormatOffset(offset) != tt.out {
+				t.Errorf("FormatOffset(%v) = %q, want %q", offset, FormatOffset(offset), tt.out)
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeZone  string
+		system    string
+		want      string
+		wantNamed bool
+	}{
+		{name: "SYSTEM with a named system zone", timeZone: "SYSTEM", system: "Europe/Oslo", want: "Europe/Oslo", wantNamed: true},
+		{name: "SYSTEM with an abbreviation", timeZone: "SYSTEM", system: "CET", want: "CET"},
+		{name: "SYSTEM in UTC", timeZone: "system", system: "UTC", want: "UTC", wantNamed: true},
+		{name: "zero offset", timeZone: "+00:00", system: "CET", want: "UTC", wantNamed: true},
+		{name: "offset", timeZone: "+1:00", system: "UTC", want: "+01:00"},
+		{name: "named zone", timeZone: "America/New_York", system: "UTC", want: "America/New_York", wantNamed: true},
+		{name: "unknown named zone", timeZone: "Mars/Olympus_Mons", system: "UTC", want: "Mars/Olympus_Mons"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := Server(tt.timeZone, tt.system, time.Hour)
+			if zone.Name != tt.want || (zone.Location != nil) != tt.wantNamed || zone.Offset != time.Hour {
+				t.Errorf("Server(%q, %q) = %+v, want %s (location: %v)", tt.timeZone, tt.system, zone, tt.want, tt.wantNamed)
+			}
+		})
+	}
+}
+
+// zone returns a zone as Server reads it, named and at its offset at now
+func zone(t *testing.T, name string, now time.Time) Zone {
+	t.Helper()
+	z := Server(name, "", 0)
+	if z.Location != nil {
+		_, seconds := now.In(z.Location).Zone()
+		z.Offset = time.Duration(seconds) * time.Second
+	} else {
+		z.Offset, _ = ParseOffset(z.Name)
+	}
+	return z
+}
+
+func TestSame(t *testing.T) {
+	for _, name := range []string{"Europe/Oslo", "Europe/Berlin", "America/New_York", "Asia/Kolkata"} {
+		if _, err := time.LoadLocation(name); err != nil {
+			t.Skipf("time zone database lacks %s: %v", name, err)
+		}
+	}
+
+	summer := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	winter := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		a, b string
+		now  time.Time
+		want bool
+	}{
+		{name: "same name", a: "Europe/Oslo", b: "Europe/Oslo", now: summer, want: true},
+		{name: "UTC aliases", a: "UTC", b: "+00:00", now: summer, want: true},
+		{name: "UTC and Etc/UTC", a: "Etc/UTC", b: "UTC", now: winter, want: true},
+		{name: "named zones with the same rules", a: "Europe/Oslo", b: "Europe/Berlin", now: winter, want: true},
+		{name: "named zones that differ", a: "Europe/Oslo", b: "America/New_York", now: summer},
+		{name: "named zone and UTC", a: "Europe/Oslo", b: "UTC", now: winter},
+		// An offset matches a named zone only at the time it is read
+		{name: "named zone and its summer offset in summer", a: "Europe/Oslo", b: "+02:00", now: summer, want: true},
+		{name: "named zone and its summer offset in winter", a: "Europe/Oslo", b: "+02:00", now: winter},
+		{name: "named zone and its winter offset in winter", a: "Europe/Oslo", b: "+01:00", now: winter, want: true},
+		{name: "zone without daylight saving and its offset", a: "Asia/Kolkata", b: "+05:30", now: summer, want: true},
+		{name: "different offsets", a: "+01:00", b: "+02:00", now: summer},
+		{name: "same offsets", a: "+1:00", b: "+01:00", now: summer, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := zone(t, tt.a, tt.now), zone(t, tt.b, tt.now)
+			if got := Same(a, b, tt.now); got != tt.want {
+				t.Errorf("Same(%s, %s) = %v, want %v", a, b, got, tt.want)
+			}
+			if got := Same(b, a, tt.now); got != tt.want {
+				t.Errorf("Same(%s, %s) = %v, want %v", b, a, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSameAcrossDaylightSaving checks that named zones with different
+// daylight-saving rules differ even when their offsets agree at the time
+func TestSameAcrossDaylightSaving(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skip(err)
+	}
+	lagos, err := time.LoadLocation("Africa/Lagos")
+	if err != nil {
+		t.Skip(err)
+	}
+	winter := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	a := Zone{Name: "Europe/Oslo", Offset: time.Hour, Location: oslo}
+	b := Zone{Name: "Africa/Lagos", Offset: time.Hour, Location: lagos}
+	if Same(a, b, winter) {
+		t.Error("Europe/Oslo and Africa/Lagos (no daylight saving) count as the same")
+	}
+}
+
+func TestClient(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Oslo"); err != nil {
+		t.Skip(err)
+	}
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		tz   string
+		want string
+	}{
+		{tz: "Europe/Oslo", want: "Europe/Oslo"},
+		{tz: ":Europe/Oslo", want: "Europe/Oslo"},
+		{tz: "Etc/UTC", want: "UTC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tz, func(t *testing.T) {
+			t.Setenv("TZ", tt.tz)
+			zone := Client(now)
+			if zone.Name != tt.want || zone.Location == nil {
+				t.Errorf("Client with TZ=%s = %+v, want %s", tt.tz, zone, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+SELECT 'unterminated \' escape; still in string', "dq \" ; too";
+SELECT `back``tick;` FROM t;SELECT 2;   -- trailing comment; after two statements
+--not-a-comment-marker is an operator sequence in MySQL;
+SELECT 1 --1;
+/* comment before */ SELECT 3 /* inside; */ ;
+#hash; at column 0
+SELECT 'DELIMITER ;;' AS d;
+SELECT x FROM y WHERE z = '
+multi-line; literal
+';
+SELECT 4;
+SELECT 5 /*!50000 ; version comment */;
+SELECT 6; -- no newline at the end
\ No newline at end of file
@@ -0,0 +1,17 @@
+--
+-- Dumping events for database 'shop'
+--
+/*!50106 SET @save_time_zone= @@TIME_ZONE */ ;
+/*!50106 DROP EVENT IF EXISTS `cleanup` */;
+DELIMITER ;;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;;
+/*!50003 SET character_set_client  = utf8mb4 */ ;;
+/*!50003 SET time_zone             = 'SYSTEM' */ ;;
+/*!50106 CREATE*/ /*!50117 DEFINER=`ops`@`10.0.0.%`*/ /*!50106 EVENT `cleanup` ON SCHEDULE EVERY 1 DAY STARTS '2026-01-01 03:00:00' ON COMPLETION PRESERVE ENABLE DO BEGIN
+  DELETE FROM sessions WHERE last_seen < NOW() - INTERVAL 7 DAY;
+  DELETE FROM `cache` WHERE `key` LIKE 'tmp;%';
+  INSERT INTO ops_log (msg) VALUES ('cleanup ran; "ok"');
+END */ ;;
+/*!50003 SET time_zone             = @save_time_zone */ ;;
+DELIMITER ;
+/*!50106 SET TIME_ZONE= @save_time_zone */ ;
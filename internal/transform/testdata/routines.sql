@@ -0,0 +1,27 @@
+--
+-- Dumping routines for database 'shop'
+--
+/*!50003 DROP FUNCTION IF EXISTS `slug` */;
+/*!50003 SET @saved_sql_mode       = @@sql_mode */ ;
+DELIMITER ;;
+CREATE DEFINER=`root`@`localhost` FUNCTION `slug`(s TEXT) RETURNS text CHARSET utf8mb4
+    DETERMINISTIC
+BEGIN
+  DECLARE out_s TEXT DEFAULT '';
+  /* block comment; spanning
+     two lines; */
+  SET out_s = REPLACE(LOWER(s), ' ', '-');
+  RETURN CONCAT(out_s, ';');
+END ;;
+DELIMITER ;
+/*!50003 DROP PROCEDURE IF EXISTS `archive` */;
+delimiter $$
+CREATE DEFINER=`root`@`localhost` PROCEDURE `archive`(IN days INT)
+BEGIN
+  DELETE FROM `orders` WHERE note LIKE '%$$%';
+  SELECT 'done $$ ;' AS `result;`;
+END$$
+delimiter //
+CREATE PROCEDURE `noop`() SELECT "//" //
+DELIMITER ;
+SELECT 1;
@@ -0,0 +1 @@
+SELECT "left open; 
\ No newline at end of file
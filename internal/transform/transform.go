@@ -0,0 +1,341 @@
+// Package transform runs rewrites over a mysqldump stream one whole
+// statement at a time. Statement boundaries are found with a scanner that
+// follows DELIMITER changes and skips delimiters inside string literals,
+// backtick identifiers and comments, so trigger and routine bodies reach a
+// transform intact. Statements are held in spill buffers, so one too large
+// for the memory ceiling moves to disk instead of being read into memory.
+package transform
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+
+	"github.com/helgesverre/dbdump/internal/spill"
+)
+
+// Func rewrites one statement, given from its first byte through its
+// delimiter. It returns the statement unchanged when it doesn't apply; the
+// slice it is given is reused afterwards and must not be retained. Statements
+// that were spilled to disk are not given to a Func: they are written
+// unchanged, after the SpillFuncs.
+type Func func(statement []byte) []byte
+
+// SpillFunc looks at a statement that was spilled to disk and reports
+// whether it is written; it can't rewrite it
+type SpillFunc func(statement *spill.Buffer) (bool, error)
+
+// longLine is the length from which a line is scanned before its end is
+// seen, so that long lines are never held whole
+const longLine = 64 << 10
+
+// lookahead is how many bytes at the end of a partly read line are left for
+// the next scan, so that no token is split between two scans
+const lookahead = 16
+
+var delimiterCommand = regexp.MustCompile(`^(?i:DELIMITER)[ \t]+(\S+)`)
+
+// Writer is an io.WriteCloser that splits the stream written to it into
+// statements and passes each through its transforms in order. Whitespace,
+// comments and DELIMITER commands between statements are copied unchanged,
+// so without transforms the output is identical to the input.
+type Writer struct {
+	out       io.Writer
+	funcs     []Func
+	spilled   []SpillFunc
+	delimiter string
+
+	line []byte        // the unscanned part of the current line
+	gap  []byte        // text between statements not yet written
+	stmt *spill.Buffer // the statement being scanned
+
+	inStatement bool
+	indented    bool // only spaces and tabs were scanned on the current line
+	quote       byte // ', " or ` while inside a literal or identifier
+	escaped     bool // the previous byte was a backslash inside a literal
+	comment     bool // inside /* ... */
+	lineComment bool // inside a -- or # comment, up to the end of the line
+	version     bool // inside /*! ... */, whose contents are code
+}
+
+// NewWriter creates a Writer applying funcs to each statement written to
+// out, holding statements in buffers from spill.Default
+func NewWriter(out io.Writer, funcs ...Func) *Writer {
+	return &Writer{out: out, funcs: funcs, delimiter: ";", stmt: spill.Default.NewBuffer(), indented: true}
+}
+
+// OnSpill sets the functions run, in order, on statements spilled to disk;
+// the first to drop one stops the others from seeing it
+func (w *Writer) OnSpill(fns ...SpillFunc) *Writer {
+	w.spilled = fns
+	return w
+}
+
+// Write implements io.Writer
+func (w *Writer) Write(p []byte) (int, error) {
+	data := p
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		complete := end > 0
+		if !complete {
+			end = len(data)
+		}
+		if err := w.collect(data[:end]); err != nil {
+			return 0, err
+		}
+		if complete {
+			if err := w.scan(true); err != nil {
+				return 0, err
+			}
+		}
+		data = data[end:]
+	}
+	return len(p), nil
+}
+
+// Close scans any final line without a newline and writes what is left.
+// An unterminated statement is written without being transformed.
+func (w *Writer) Close() error {
+	if len(w.line) > 0 {
+		if err := w.scan(true); err != nil {
+			return err
+		}
+	}
+	if err := w.write(w.gap); err != nil {
+		return err
+	}
+	w.gap = w.gap[:0]
+	if _, err := w.stmt.WriteTo(w.out); err != nil {
+		return err
+	}
+	return w.stmt.Close()
+}
+
+// Discard drops what is buffered without writing it, for a stream that
+// failed; it removes the spill file of a statement in progress
+func (w *Writer) Discard() error {
+	w.line, w.gap = w.line[:0], w.gap[:0]
+	return w.stmt.Close()
+}
+
+// collect adds part of a line, scanning what it can once the line is long
+func (w *Writer) collect(part []byte) error {
+	for len(part) > 0 {
+		n := min(len(part), longLine)
+		w.line = append(w.line, part[:n]...)
+		part = part[n:]
+		if len(w.line) >= longLine {
+			if err := w.scan(false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scan splits the collected line between the gap and statements. Unless
+// final (the line is complete), it stops lookahead bytes short of the end
+// and keeps the rest for the next scan.
+func (w *Writer) scan(final bool) error {
+	line := w.line
+	limit := len(line)
+	if !final {
+		limit -= max(lookahead, len(w.delimiter))
+	}
+
+	i := 0
+	for i < limit {
+		if !w.inStatement {
+			next, started := w.scanGap(line, i, limit, final)
+			if next == i && !started {
+				// A DELIMITER command, which needs the whole line
+				break
+			}
+			w.gap = append(w.gap, line[i:next]...)
+			i = next
+			if !started {
+				continue
+			}
+			w.inStatement = true
+			w.indented = false
+		}
+
+		end, done := w.scanStatement(line, i, limit)
+		if _, err := w.stmt.Write(line[i:end]); err != nil {
+			return err
+		}
+		i = end
+		if done {
+			if err := w.emit(); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.line = w.line[:copy(w.line, line[i:])]
+	if final {
+		w.indented = true
+		w.lineComment = false
+	}
+	return nil
+}
+
+// scanGap advances over whitespace, comments and DELIMITER commands from
+// line[i:], up to limit. It returns where it stopped, and whether a
+// statement starts there; it returns i itself to wait for the rest of the
+// line.
+func (w *Writer) scanGap(line []byte, i, limit int, final bool) (int, bool) {
+	if w.lineComment {
+		return limit, false
+	}
+	if w.comment {
+		end := bytes.Index(line[i:], []byte("*/"))
+		if end < 0 || i+end >= limit {
+			return limit, false
+		}
+		w.comment = false
+		return i + end + 2, false
+	}
+
+	rest := line[i:]
+	switch {
+	case isBlank(rest[0]):
+		if rest[0] != ' ' && rest[0] != '\t' {
+			w.indented = false
+		}
+		return i + 1, false
+	case startsLineComment(rest):
+		w.lineComment = true
+		return limit, false
+	case bytes.HasPrefix(rest, []byte("/*")) && !bytes.HasPrefix(rest, []byte("/*!")):
+		w.comment = true
+		w.indented = false
+		return i + 2, false
+	case w.indented && delimiterCommand.Match(rest):
+		// DELIMITER is a client command: it takes the rest of the line
+		if !final {
+			return i, false
+		}
+		w.delimiter = string(delimiterCommand.FindSubmatch(rest)[1])
+		return len(line), false
+	}
+	return i, true
+}
+
+// scanStatement advances through statement text from line[i:], up to
+// limit. It returns where it stopped, and whether that is the end of the
+// statement (just past its delimiter).
+func (w *Writer) scanStatement(line []byte, i, limit int) (int, bool) {
+	if w.lineComment {
+		return limit, false
+	}
+	for ; i < limit; i++ {
+		c := line[i]
+		switch {
+		case w.quote != 0:
+			switch {
+			case w.escaped:
+				w.escaped = false
+			case c == '\\' && w.quote != '`':
+				w.escaped = true
+			case c == w.quote:
+				w.quote = 0
+			}
+		case w.comment:
+			if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+				w.comment = false
+				i++
+			}
+		case c == '\'' || c == '"' || c == '`':
+			w.quote = c
+		case c == '/' && i+1 < len(line) && line[i+1] == '*':
+			if i+2 < len(line) && line[i+2] == '!' {
+				w.version = true
+			} else {
+				w.comment = true
+			}
+			i++
+		case c == '*' && w.version && i+1 < len(line) && line[i+1] == '/':
+			w.version = false
+			i++
+		case startsLineComment(line[i:]):
+			w.lineComment = true
+			return limit, false
+		case !w.version && bytes.HasPrefix(line[i:], []byte(w.delimiter)):
+			return i + len(w.delimiter), true
+		}
+	}
+	return i, false
+}
+
+// emit writes the gap before the finished statement and the statement
+// after running it through the transforms
+func (w *Writer) emit() error {
+	if w.stmt.Spilled() {
+		return w.emitSpilled()
+	}
+
+	statement := w.stmt.Bytes()
+	for _, fn := range w.funcs {
+		statement = fn(statement)
+	}
+
+	if err := w.write(w.gap); err != nil {
+		return err
+	}
+	if err := w.write(statement); err != nil {
+		return err
+	}
+	w.gap = w.gap[:0]
+	w.inStatement = false
+	return w.stmt.Reset()
+}
+
+// emitSpilled writes the gap and a statement spilled to disk, unless a
+// SpillFunc drops it
+func (w *Writer) emitSpilled() error {
+	keep := true
+	for _, fn := range w.spilled {
+		var err error
+		if keep, err = fn(w.stmt); err != nil {
+			return err
+		}
+		if !keep {
+			break
+		}
+	}
+
+	if err := w.write(w.gap); err != nil {
+		return err
+	}
+	if keep {
+		if _, err := w.stmt.WriteTo(w.out); err != nil {
+			return err
+		}
+	}
+	w.gap = w.gap[:0]
+	w.inStatement = false
+	return w.stmt.Reset()
+}
+
+// write writes p to the output, skipping empty writes
+func (w *Writer) write(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	_, err := w.out.Write(p)
+	return err
+}
+
+// startsLineComment reports whether s starts a "-- " or "#" comment
+func startsLineComment(s []byte) bool {
+	if len(s) > 0 && s[0] == '#' {
+		return true
+	}
+	return len(s) >= 2 && s[0] == '-' && s[1] == '-' && (len(s) == 2 || s[2] <= ' ')
+}
+
+// isBlank reports whether c is whitespace
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
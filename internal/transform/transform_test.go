@@ -0,0 +1,239 @@
+package transform
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/spill"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files of testdata")
+
+// chunkSizes are the write sizes the stream is fed in, so that statements,
+// literals, comments and delimiters are split across writes
+var chunkSizes = []int{1, 2, 3, 7, 16, 17, 64, 4096, 1 << 20}
+
+// fixtures returns the mysqldump outputs of testdata by name
+func fixtures(t testing.TB) map[string][]byte {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.sql"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no fixtures in testdata: %v", err)
+	}
+	corpus := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corpus[strings.TrimSuffix(filepath.Base(path), ".sql")] = data
+	}
+	return corpus
+}
+
+// run writes input through a Writer with funcs, chunk bytes at a time
+func run(t testing.TB, input []byte, chunk int, funcs ...Func) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w := NewWriter(&out, funcs...)
+	for data := input; len(data) > 0; {
+		n := min(chunk, len(data))
+		if written, err := w.Write(data[:n]); err != nil || written != n {
+			t.Fatalf("Write = %d, %v", written, err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close = %v", err)
+	}
+	return out.Bytes()
+}
+
+// mark wraps each statement in ⟦ ⟧, showing where the scanner put the
+// boundaries
+func mark(statement []byte) []byte {
+	return append(append([]byte("⟦"), statement...), "⟧"...)
+}
+
+func TestIdentityFixtures(t *testing.T) {
+	for name, input := range fixtures(t) {
+		for _, chunk := range chunkSizes {
+			if got := run(t, input, chunk); !bytes.Equal(got, input) {
+				t.Errorf("%s in chunks of %d: output differs from input\n got: %q\nwant: %q", name, chunk, got, input)
+			}
+		}
+	}
+}
+
+// TestStatementBoundaries compares where statements start and end with the
+// .golden files; run with -update to rewrite them after checking the diff
+func TestStatementBoundaries(t *testing.T) {
+	for name, input := range fixtures(t) {
+		golden := filepath.Join("testdata", name+".golden")
+		got := run(t, input, len(input)+1, mark)
+		if *update {
+			if err := os.WriteFile(golden, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%v (run with -update to create it)", err)
+		}
+		for _, chunk := range chunkSizes {
+			if got := run(t, input, chunk, mark); !bytes.Equal(got, want) {
+				t.Errorf("%s in chunks of %d: statements differ from %s\n got: %s\nwant: %s", name, chunk, golden, got, want)
+			}
+		}
+	}
+}
+
+func TestTriggerBodiesReachTransformsWhole(t *testing.T) {
+	var statements []string
+	collect := func(statement []byte) []byte {
+		statements = append(statements, string(statement))
+		return statement
+	}
+	run(t, fixtures(t)["triggers"], 5, collect)
+
+	var triggers []string
+	for _, statement := range statements {
+		if strings.Contains(statement, " TRIGGER ") {
+			triggers = append(triggers, statement)
+		}
+	}
+	if len(triggers) != 2 {
+		t.Fatalf("found %d trigger statements, want 2: %q", len(triggers), triggers)
+	}
+	if !strings.HasPrefix(triggers[0], "/*!50003 CREATE*/") || !strings.HasSuffix(triggers[0], "END */;;") {
+		t.Errorf("first trigger was split: %q", triggers[0])
+	}
+	if !strings.Contains(triggers[1], "`weird;name`") || !strings.HasSuffix(triggers[1], "';;') */;;") {
+		t.Errorf("second trigger was split: %q", triggers[1])
+	}
+}
+
+func TestTransformsRewriteStatements(t *testing.T) {
+	input := []byte("DELIMITER ;;\nCREATE DEFINER=`app`@`%` TRIGGER t BEFORE INSERT ON x FOR EACH ROW BEGIN SET @a = 'DEFINER=`app`'; END ;;\nDELIMITER ;\nSELECT 1;\n")
+	strip := func(statement []byte) []byte {
+		return bytes.Replace(statement, []byte("DEFINER=`app`@`%` "), nil, 1)
+	}
+	upper := func(statement []byte) []byte {
+		return bytes.Replace(statement, []byte("SELECT"), []byte("select"), 1)
+	}
+	want := "DELIMITER ;;\nCREATE TRIGGER t BEFORE INSERT ON x FOR EACH ROW BEGIN SET @a = 'DEFINER=`app`'; END ;;\nDELIMITER ;\nselect 1;\n"
+	for _, chunk := range chunkSizes {
+		if got := run(t, input, chunk, strip, upper); string(got) != want {
+			t.Errorf("chunks of %d:\n got: %q\nwant: %q", chunk, got, want)
+		}
+	}
+}
+
+// TestLongLines feeds lines far longer than longLine, which are scanned
+// before their end is seen
+func TestLongLines(t *testing.T) {
+	var input bytes.Buffer
+	input.WriteString("INSERT INTO `t` VALUES ")
+	for i := 0; i < 20000; i++ {
+		if i > 0 {
+			input.WriteByte(',')
+		}
+		input.WriteString(`(1,'a;b\'c',"d;e",'--;/*;*/')`)
+	}
+	input.WriteString(";\nSELECT 'after';\n")
+	input.WriteString("-- " + strings.Repeat("comment; ", longLine/4) + "\nSELECT 2;")
+
+	var statements int
+	count := func(statement []byte) []byte {
+		statements++
+		return statement
+	}
+	for _, chunk := range []int{1000, longLine - 1, longLine + 1, input.Len()} {
+		statements = 0
+		if got := run(t, input.Bytes(), chunk, count); !bytes.Equal(got, input.Bytes()) {
+			t.Errorf("chunks of %d: output differs from input", chunk)
+		}
+		if statements != 3 {
+			t.Errorf("chunks of %d: %d statements, want 3", chunk, statements)
+		}
+	}
+}
+
+// TestSpilledStatements checks that statements larger than the memory
+// ceiling are written unchanged, after the SpillFuncs
+func TestSpilledStatements(t *testing.T) {
+	limit := spill.Default.Limit()
+	spill.Default.SetLimit(1 << 10)
+	defer spill.Default.SetLimit(limit)
+
+	big := "INSERT INTO `t` VALUES ('" + strings.Repeat("x;", 4<<10) + "');\n"
+	input := []byte("SELECT 1;\n" + big + "SELECT 2;\n")
+
+	var spilled int
+	seen := func(statement *spill.Buffer) (bool, error) {
+		spilled++
+		return true, nil
+	}
+	for _, chunk := range []int{7, 4096} {
+		spilled = 0
+		var out bytes.Buffer
+		w := NewWriter(&out, mark).OnSpill(seen)
+		for data := input; len(data) > 0; {
+			n := min(chunk, len(data))
+			if _, err := w.Write(data[:n]); err != nil {
+				t.Fatal(err)
+			}
+			data = data[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		want := "⟦SELECT 1;⟧\n" + big + "⟦SELECT 2;⟧\n"
+		if out.String() != want {
+			t.Errorf("chunks of %d: the spilled statement wasn't written unchanged between the others", chunk)
+		}
+		if spilled != 1 {
+			t.Errorf("chunks of %d: %d statements spilled, want 1", chunk, spilled)
+		}
+	}
+
+	drop := func(*spill.Buffer) (bool, error) { return false, nil }
+	var out bytes.Buffer
+	w := NewWriter(&out).OnSpill(drop)
+	if _, err := w.Write(input); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The text between statements stays
+	if out.String() != "SELECT 1;\n\nSELECT 2;\n" {
+		t.Errorf("dropped statement was written: %q", out.String())
+	}
+}
+
+// FuzzIdentity checks that without transforms any input, in any write
+// sizes, comes out byte for byte
+func FuzzIdentity(f *testing.F) {
+	for _, input := range fixtures(f) {
+		f.Add(input, uint16(1))
+		f.Add(input, uint16(13))
+	}
+	f.Add([]byte("DELIMITER ;;\nSELECT ';;' ;;\nDELIMITER ;\n"), uint16(2))
+	f.Add([]byte("SELECT '\\"), uint16(1))
+	f.Add([]byte("/* open"), uint16(3))
+	f.Add([]byte("DELIMITER"), uint16(4))
+	f.Add([]byte("--\n#\n-- x\r\n"), uint16(5))
+
+	f.Fuzz(func(t *testing.T, input []byte, chunk uint16) {
+		size := int(chunk)%(2*longLine) + 1
+		if got := run(t, input, size); !bytes.Equal(got, input) {
+			t.Fatalf("chunks of %d: output differs from input\n got: %q\nwant: %q", size, got, input)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+// Package triggers finds the triggers through which restoring a table's
+// rows, or writing to it later, reaches tables whose data a dump leaves out.
+// It works on trigger metadata alone: which table each trigger is on, and
+// which tables its body writes.
+package triggers
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Trigger is a trigger as information_schema.triggers describes it
+type Trigger struct {
+	Name   string
+	Table  string // the table the trigger is on
+	Timing string // e.g. "AFTER INSERT"
+	Body   string // action_statement
+}
+
+// Effect is a table with data in the dump whose writes reach a table
+// without: directly through one trigger, or through triggers on the tables
+// in between
+type Effect struct {
+	Table  string `json:"table"`
+	Target string `json:"target"`
+
+	// Chain lists the triggers from Table to Target in the order they fire
+	Chain []string `json:"chain"`
+
+	// Skipped is set when Target isn't in the dump at all, so the last
+	// trigger fails instead of filling it
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// writePattern finds the table an INSERT, REPLACE, UPDATE or DELETE writes
+var writePattern = regexp.MustCompile("(?i)\\b(?:" +
+	"INSERT(?:\\s+(?:LOW_PRIORITY|DELAYED|HIGH_PRIORITY))?(?:\\s+IGNORE)?(?:\\s+INTO)?|" +
+	"REPLACE(?:\\s+(?:LOW_PRIORITY|DELAYED))?(?:\\s+INTO)?|" +
+	"UPDATE(?:\\s+LOW_PRIORITY)?(?:\\s+IGNORE)?|" +
+	"DELETE(?:\\s+LOW_PRIORITY)?(?:\\s+QUICK)?(?:\\s+IGNORE)?\\s+FROM)" +
+	"\\s+((?:`(?:[^`]|``)+`|\\w+)(?:\\s*\\.\\s*(?:`(?:[^`]|``)+`|\\w+))?)")
+
+// literalPattern matches string literals and comments, which can mention
+// statements without running them
+var literalPattern = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"|--[^\n]*|#[^\n]*|/\*[\s\S]*?\*/`)
+
+// Writes returns the tables of database a trigger body writes, in the
+// order they first appear; tables of other databases are left out
+func Writes(body, database string) []string {
+	body = literalPattern.ReplaceAllString(body, " ")
+
+	var tables []string
+	for _, match := range writePattern.FindAllStringSubmatchIndex(body, -1) {
+		// ON DUPLICATE KEY UPDATE and SELECT ... FOR UPDATE write nothing
+		before := strings.Fields(body[:match[0]])
+		if len(before) > 0 && (strings.EqualFold(before[len(before)-1], "KEY") || strings.EqualFold(before[len(before)-1], "FOR")) {
+			continue
+		}
+		parts := splitName(body[match[2]:match[3]])
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) == 2 {
+			if !strings.EqualFold(parts[0], database) {
+				continue
+			}
+			parts = parts[1:]
+		}
+		if !slices.Contains(tables, parts[0]) {
+			tables = append(tables, parts[0])
+		}
+	}
+	return tables
+}
+
+// splitName splits an optionally qualified, optionally quoted name
+func splitName(name string) []string {
+	var parts []string
+	for len(name) > 0 {
+		name = strings.TrimSpace(name)
+		if strings.HasPrefix(name, "`") {
+			end := 1
+			for end < len(name) {
+				if name[end] == '`' {
+					if end+1 < len(name) && name[end+1] == '`' {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			parts = append(parts, strings.ReplaceAll(name[1:end], "``", "`"))
+			name = name[min(end+1, len(name)):]
+		} else {
+			dot := strings.IndexByte(name, '.')
+			if dot < 0 {
+				dot = len(name)
+			}
+			parts = append(parts, strings.TrimSpace(name[:dot]))
+			name = name[dot:]
+		}
+		name = strings.TrimPrefix(strings.TrimSpace(name), ".")
+	}
+	return parts
+}
+
+// Effects follows the triggers of the tables with data to the excluded
+// and skipped tables they end up writing. Triggers on skipped tables are
+// not in the dump and don't fire; writes pass on through any other table,
+// since its triggers are restored whether or not its data is. Each table
+// and target is reported once, with the shortest chain, ordered by table
+// and target. Table names compare case-insensitively.
+func Effects(all []Trigger, database string, data, excluded, skipped []string) []Effect {
+	key := strings.ToLower
+	names := make(map[string]string)
+	isExcluded := make(map[string]bool, len(excluded))
+	isSkipped := make(map[string]bool, len(skipped))
+	for _, table := range slices.Concat(data, excluded, skipped) {
+		names[key(table)] = table
+	}
+	for _, table := range excluded {
+		isExcluded[key(table)] = true
+	}
+	for _, table := range skipped {
+		isSkipped[key(table)] = true
+	}
+
+	// edges are the writes of each table's triggers
+	type edge struct {
+		trigger string
+		target  string
+	}
+	edges := make(map[string][]edge)
+	for _, trigger := range all {
+		if isSkipped[key(trigger.Table)] {
+			continue
+		}
+		for _, target := range Writes(trigger.Body, database) {
+			// Triggers name tables as they like; report them as listed
+			if name, ok := names[key(target)]; ok {
+				target = name
+			}
+			edges[key(trigger.Table)] = append(edges[key(trigger.Table)], edge{trigger.Name, target})
+		}
+	}
+
+	var effects []Effect
+	for _, table := range slices.Sorted(slices.Values(data)) {
+		if isExcluded[key(table)] || isSkipped[key(table)] {
+			continue
+		}
+
+		// Breadth first, so each target is first reached by its shortest chain
+		type step struct {
+			table string
+			chain []string
+		}
+		seen := map[string]bool{key(table): true}
+		queue := []step{{table: table}}
+		var found []Effect
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, next := range edges[key(current.table)] {
+				if seen[key(next.target)] {
+					continue
+				}
+				seen[key(next.target)] = true
+				chain := append(slices.Clone(current.chain), next.trigger)
+				switch {
+				case isSkipped[key(next.target)]:
+					found = append(found, Effect{Table: table, Target: next.target, Chain: chain, Skipped: true})
+					continue
+				case isExcluded[key(next.target)]:
+					found = append(found, Effect{Table: table, Target: next.target, Chain: chain})
+				}
+				queue = append(queue, step{next.target, chain})
+			}
+		}
+		slices.SortFunc(found, func(a, b Effect) int {
+			return strings.Compare(a.Target, b.Target)
+		})
+		effects = append(effects, found...)
+	}
+	return effects
+}
@@ -0,0 +1,196 @@
+package triggers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWrites(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "insert", body: "INSERT INTO audit_log (id) VALUES (NEW.id)", want: []string{"audit_log"}},
+		{name: "insert without into", body: "insert audit_log values (NEW.id)", want: []string{"audit_log"}},
+		{name: "insert modifiers", body: "INSERT LOW_PRIORITY IGNORE INTO `audit_log` SET id = NEW.id", want: []string{"audit_log"}},
+		{name: "replace", body: "REPLACE DELAYED INTO totals VALUES (1)", want: []string{"totals"}},
+		{name: "update", body: "UPDATE IGNORE counters SET n = n + 1", want: []string{"counters"}},
+		{name: "delete", body: "DELETE QUICK FROM cache WHERE id = OLD.id", want: []string{"cache"}},
+		{
+			name: "several statements in order of appearance",
+			body: "BEGIN\n  UPDATE stats SET n = n + 1;\n  INSERT INTO audit_log VALUES (NEW.id);\n  UPDATE stats SET m = 1;\nEND",
+			want: []string{"stats", "audit_log"},
+		},
+		{name: "quoted name with a backtick", body: "INSERT INTO `odd``name` VALUES (1)", want: []string{"odd`name"}},
+		{name: "same database", body: "INSERT INTO shop.audit_log VALUES (1)", want: []string{"audit_log"}},
+		{name: "same database, quoted and spaced", body: "INSERT INTO `Shop` . `audit log` VALUES (1)", want: []string{"audit log"}},
+		{name: "other database", body: "INSERT INTO archive.audit_log VALUES (1)"},
+		{name: "upsert", body: "INSERT INTO totals VALUES (1) ON DUPLICATE KEY UPDATE n = n + 1", want: []string{"totals"}},
+		{name: "locking read", body: "SELECT n INTO @n FROM counters WHERE id = 1 FOR UPDATE"},
+		{name: "string literal", body: "SET NEW.note = 'INSERT INTO audit_log'"},
+		{name: "comments", body: "-- DELETE FROM cache\n# UPDATE stats\n/* INSERT INTO audit_log */ SET NEW.x = 1"},
+		{name: "no writes", body: "SET NEW.updated_at = NOW()"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Writes(tt.body, "shop"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Writes(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+// on returns an AFTER INSERT trigger on table whose body is the statements
+func on(name, table, body string) Trigger {
+	return Trigger{Name: name, Table: table, Timing: "AFTER INSERT", Body: body}
+}
+
+func TestEffects(t *testing.T) {
+	tests := []struct {
+		name     string
+		triggers []Trigger
+		data     []string
+		excluded []string
+		skipped  []string
+		want     []Effect
+	}{
+		{
+			name:     "no triggers",
+			data:     []string{"users"},
+			excluded: []string{"audit_log"},
+		},
+		{
+			name:     "direct",
+			triggers: []Trigger{on("users_ai", "users", "INSERT INTO audit_log VALUES (NEW.id)")},
+			data:     []string{"users"},
+			excluded: []string{"audit_log"},
+			want:     []Effect{{Table: "users", Target: "audit_log", Chain: []string{"users_ai"}}},
+		},
+		{
+			name:     "writes to tables with data",
+			triggers: []Trigger{on("orders_ai", "orders", "UPDATE users SET orders = orders + 1")},
+			data:     []string{"users", "orders"},
+			excluded: []string{"audit_log"},
+		},
+		{
+			name: "one trigger writing several tables",
+			triggers: []Trigger{on("orders_ai", "orders",
+				"BEGIN INSERT INTO order_events VALUES (NEW.id); UPDATE stats SET n = n + 1; UPDATE users SET x = 1; END")},
+			data:     []string{"orders", "users"},
+			excluded: []string{"stats", "order_events"},
+			want: []Effect{
+				{Table: "orders", Target: "order_events", Chain: []string{"orders_ai"}},
+				{Table: "orders", Target: "stats", Chain: []string{"orders_ai"}},
+			},
+		},
+		{
+			name: "chained through a table with data",
+			triggers: []Trigger{
+				on("orders_ai", "orders", "INSERT INTO order_log VALUES (NEW.id)"),
+				on("order_log_ai", "order_log", "INSERT INTO audit_log VALUES (NEW.id)"),
+			},
+			data:     []string{"orders", "order_log"},
+			excluded: []string{"audit_log"},
+			want: []Effect{
+				{Table: "order_log", Target: "audit_log", Chain: []string{"order_log_ai"}},
+				{Table: "orders", Target: "audit_log", Chain: []string{"orders_ai", "order_log_ai"}},
+			},
+		},
+		{
+			// The excluded table's triggers are restored, so writes to it
+			// pass on
+			name: "chained through an excluded table",
+			triggers: []Trigger{
+				on("users_ai", "users", "INSERT INTO audit_log VALUES (NEW.id)"),
+				on("audit_log_ai", "audit_log", "UPDATE audit_stats SET n = n + 1"),
+			},
+			data:     []string{"users"},
+			excluded: []string{"audit_log", "audit_stats"},
+			want: []Effect{
+				{Table: "users", Target: "audit_log", Chain: []string{"users_ai"}},
+				{Table: "users", Target: "audit_stats", Chain: []string{"users_ai", "audit_log_ai"}},
+			},
+		},
+		{
+			name: "skipped target ends the chain",
+			triggers: []Trigger{
+				on("users_ai", "users", "INSERT INTO legacy_log VALUES (NEW.id)"),
+				on("legacy_log_ai", "legacy_log", "INSERT INTO audit_log VALUES (NEW.id)"),
+			},
+			data:     []string{"users"},
+			excluded: []string{"audit_log"},
+			skipped:  []string{"legacy_log"},
+			want:     []Effect{{Table: "users", Target: "legacy_log", Chain: []string{"users_ai"}, Skipped: true}},
+		},
+		{
+			name:     "triggers on skipped tables aren't in the dump",
+			triggers: []Trigger{on("legacy_ai", "legacy", "INSERT INTO audit_log VALUES (NEW.id)")},
+			data:     []string{"users"},
+			excluded: []string{"audit_log"},
+			skipped:  []string{"legacy"},
+		},
+		{
+			name: "shortest chain wins",
+			triggers: []Trigger{
+				on("a_ai", "a", "INSERT INTO b VALUES (1); INSERT INTO audit_log VALUES (1)"),
+				on("b_ai", "b", "INSERT INTO audit_log VALUES (1)"),
+			},
+			data:     []string{"a", "b"},
+			excluded: []string{"audit_log"},
+			want: []Effect{
+				{Table: "a", Target: "audit_log", Chain: []string{"a_ai"}},
+				{Table: "b", Target: "audit_log", Chain: []string{"b_ai"}},
+			},
+		},
+		{
+			name: "cycles end",
+			triggers: []Trigger{
+				on("a_ai", "a", "INSERT INTO b VALUES (1)"),
+				on("b_ai", "b", "INSERT INTO a VALUES (1); INSERT INTO audit_log VALUES (1)"),
+			},
+			data:     []string{"a", "b"},
+			excluded: []string{"audit_log"},
+			want: []Effect{
+				{Table: "a", Target: "audit_log", Chain: []string{"a_ai", "b_ai"}},
+				{Table: "b", Target: "audit_log", Chain: []string{"b_ai"}},
+			},
+		},
+		{
+			name:     "names compare case-insensitively, reported as listed",
+			triggers: []Trigger{on("users_ai", "USERS", "INSERT INTO `AUDIT_LOG` VALUES (1)")},
+			data:     []string{"Users"},
+			excluded: []string{"Audit_Log"},
+			want:     []Effect{{Table: "Users", Target: "Audit_Log", Chain: []string{"users_ai"}}},
+		},
+		{
+			name: "several triggers on one table, sorted by table and target",
+			triggers: []Trigger{
+				on("users_ai", "users", "INSERT INTO user_log VALUES (1)"),
+				on("users_au", "users", "INSERT INTO audit_log VALUES (1)"),
+				on("orders_ai", "orders", "INSERT INTO audit_log VALUES (1)"),
+			},
+			data:     []string{"users", "orders"},
+			excluded: []string{"user_log", "audit_log"},
+			want: []Effect{
+				{Table: "orders", Target: "audit_log", Chain: []string{"orders_ai"}},
+				{Table: "users", Target: "audit_log", Chain: []string{"users_au"}},
+				{Table: "users", Target: "user_log", Chain: []string{"users_ai"}},
+			},
+		},
+		{
+			name:     "hidden bodies write nothing known",
+			triggers: []Trigger{{Name: "users_ai", Table: "users", Timing: "AFTER INSERT"}},
+			data:     []string{"users"},
+			excluded: []string{"audit_log"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Effects(tt.triggers, "shop", tt.data, tt.excluded, tt.skipped)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Effects =\n %+v, want\n %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package tuning
+
+import (
+	"compress/gzip"
+	"time"
+)
+
+// Codec is an output compression preset --compression=auto chooses from
+type Codec struct {
+	Name  string
+	Level int // gzip level; 0 writes plain SQL
+}
+
+// Compressed reports whether the codec compresses the output
+func (c Codec) Compressed() bool {
+	return c.Level != 0
+}
+
+// Codecs returns the presets, lightest first. dbdump writes gzip only, so
+// zstd isn't among them.
+func Codecs() []Codec {
+	return []Codec{
+		{Name: "none"},
+		{Name: "gzip-1", Level: gzip.BestSpeed},
+		{Name: "gzip-6", Level: 6},
+		{Name: "gzip-9", Level: gzip.BestCompression},
+	}
+}
+
+// Goal is what --compression=auto optimizes for
+type Goal string
+
+const (
+	GoalTime Goal = "time"
+	GoalSize Goal = "size"
+)
+
+// CodecMeasurement is how a codec did on a sample of the data
+type CodecMeasurement struct {
+	Codec Codec
+
+	// Ratio is the compressed size over the raw size, 1 without compression
+	Ratio float64
+
+	// Rate is the raw bytes compressed per second on one worker; 0 means
+	// compression costs nothing (no compression)
+	Rate float64
+}
+
+// CodecProfile is what a codec is chosen from
+type CodecProfile struct {
+	// Size is the raw SQL the dump is estimated to write
+	Size int64
+
+	// DumpRate is the raw bytes per second the dump produces; 0 when unknown
+	DumpRate float64
+
+	// Workers is how many goroutines compress at once
+	Workers int
+
+	Codecs []CodecMeasurement
+}
+
+// CodecEstimate is the predicted outcome of a dump with a codec
+type CodecEstimate struct {
+	CodecMeasurement
+	Time time.Duration
+	Size int64
+}
+
+// codecTolerance is how far apart two estimates must be to count as
+// different; sampling a few megabytes isn't more precise than that
+const codecTolerance = 0.05
+
+// ChooseCodec estimates the time and size of the dump with each codec and
+// picks the best for the goal. The dump and the compressor work at the same
+// time, so a dump takes as long as the slower of the two: mysqldump at
+// DumpRate, or the compressor at Rate on each of the workers. Estimates
+// within codecTolerance of the best count as equal: for time the smallest
+// output among them wins, for size the fastest. It returns the estimates of
+// all codecs, in profile order, and the index of the chosen one, or -1
+// without codecs.
+func ChooseCodec(p CodecProfile, goal Goal) ([]CodecEstimate, int) {
+	workers := float64(max(p.Workers, 1))
+	size := float64(p.Size)
+
+	estimates := make([]CodecEstimate, len(p.Codecs))
+	for i, measured := range p.Codecs {
+		var seconds float64
+		if p.DumpRate > 0 {
+			seconds = size / p.DumpRate
+		}
+		if measured.Rate > 0 {
+			seconds = max(seconds, size/(measured.Rate*workers))
+		}
+		estimates[i] = CodecEstimate{
+			CodecMeasurement: measured,
+			Time:             time.Duration(seconds * float64(time.Second)),
+			Size:             int64(size * measured.Ratio),
+		}
+	}
+
+	primary := func(e CodecEstimate) float64 { return float64(e.Time) }
+	secondary := func(e CodecEstimate) float64 { return float64(e.Size) }
+	if goal == GoalSize {
+		primary, secondary = secondary, primary
+	}
+
+	best := -1
+	for i, estimate := range estimates {
+		if best < 0 || primary(estimate) < primary(estimates[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return estimates, -1
+	}
+	limit := primary(estimates[best]) * (1 + codecTolerance)
+	chosen := best
+	for i, estimate := range estimates {
+		if primary(estimate) <= limit && secondary(estimate) < secondary(estimates[chosen]) {
+			chosen = i
+		}
+	}
+	return estimates, chosen
+}
+
+// codecChunk is how much of the sample is compressed between checks of the
+// time budget
+const codecChunk = 256 << 10
+
+// MeasureCodec compresses sample with the codec on one goroutine for at
+// most budget, and returns the ratio and rate over what it got through
+func MeasureCodec(sample []byte, codec Codec, budget time.Duration) CodecMeasurement {
+	measured := CodecMeasurement{Codec: codec, Ratio: 1}
+	if !codec.Compressed() || len(sample) == 0 {
+		return measured
+	}
+
+	out := &countingWriter{}
+	gz, _ := gzip.NewWriterLevel(out, codec.Level)
+	start := time.Now()
+	read := 0
+	for read < len(sample) && (read == 0 || time.Since(start) < budget) {
+		end := min(read+codecChunk, len(sample))
+		_, _ = gz.Write(sample[read:end])
+		read = end
+	}
+	_ = gz.Close()
+	elapsed := time.Since(start)
+
+	measured.Ratio = float64(out.n) / float64(read)
+	if elapsed > 0 {
+		measured.Rate = float64(read) / elapsed.Seconds()
+	}
+	return measured
+}
+
+// countingWriter counts and discards what is written to it
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
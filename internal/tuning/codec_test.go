@@ -0,0 +1,157 @@
+package tuning
+
+import (
+	"testing"
+	"time"
+)
+
+const mb = 1e6
+
+// measured returns a measurement of the preset named name
+func measured(name string, ratio, rate float64) CodecMeasurement {
+	for _, codec := range Codecs() {
+		if codec.Name == name {
+			return CodecMeasurement{Codec: codec, Ratio: ratio, Rate: rate}
+		}
+	}
+	panic("no codec " + name)
+}
+
+// typical is how the presets do on ordinary SQL on one core
+func typical() []CodecMeasurement {
+	return []CodecMeasurement{
+		measured("none", 1, 0),
+		measured("gzip-1", 0.30, 100*mb),
+		measured("gzip-6", 0.22, 30*mb),
+		measured("gzip-9", 0.20, 8*mb),
+	}
+}
+
+func TestChooseCodec(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile CodecProfile
+		goal    Goal
+		want    string
+	}{
+		{
+			name:    "slow network: every codec keeps up, the smallest wins",
+			profile: CodecProfile{Size: 1e9, DumpRate: 10 * mb, Workers: 4, Codecs: typical()},
+			goal:    GoalTime,
+			want:    "gzip-9",
+		},
+		{
+			name:    "fast local dump on one core: compressing only slows it",
+			profile: CodecProfile{Size: 1e9, DumpRate: 500 * mb, Workers: 1, Codecs: typical()},
+			goal:    GoalTime,
+			want:    "none",
+		},
+		{
+			name:    "fast local dump, optimizing for size",
+			profile: CodecProfile{Size: 1e9, DumpRate: 500 * mb, Workers: 1, Codecs: typical()},
+			goal:    GoalSize,
+			want:    "gzip-9",
+		},
+		{
+			name:    "only the lightest level keeps up",
+			profile: CodecProfile{Size: 1e9, DumpRate: 50 * mb, Workers: 1, Codecs: typical()},
+			goal:    GoalTime,
+			want:    "gzip-1",
+		},
+		{
+			name:    "workers let a heavier level keep up",
+			profile: CodecProfile{Size: 1e9, DumpRate: 100 * mb, Workers: 4, Codecs: typical()},
+			goal:    GoalTime,
+			want:    "gzip-6",
+		},
+		{
+			name:    "no workers count as one",
+			profile: CodecProfile{Size: 1e9, DumpRate: 50 * mb, Codecs: typical()},
+			goal:    GoalTime,
+			want:    "gzip-1",
+		},
+		{
+			name:    "unknown dump rate: only compression takes time",
+			profile: CodecProfile{Size: 1e9, Workers: 4, Codecs: typical()},
+			goal:    GoalTime,
+			want:    "none",
+		},
+		{
+			name: "slightly slower but within the tolerance, and smaller",
+			profile: CodecProfile{Size: 1e9, DumpRate: 500 * mb, Workers: 1, Codecs: []CodecMeasurement{
+				measured("gzip-1", 0.30, 100*mb),
+				measured("gzip-6", 0.22, 97*mb),
+			}},
+			goal: GoalTime,
+			want: "gzip-6",
+		},
+		{
+			name: "slightly larger but within the tolerance, and faster",
+			profile: CodecProfile{Size: 1e9, DumpRate: 500 * mb, Workers: 1, Codecs: []CodecMeasurement{
+				measured("gzip-6", 0.204, 30*mb),
+				measured("gzip-9", 0.200, 8*mb),
+			}},
+			goal: GoalSize,
+			want: "gzip-6",
+		},
+		{
+			name: "incompressible data",
+			profile: CodecProfile{Size: 1e9, DumpRate: 10 * mb, Workers: 4, Codecs: []CodecMeasurement{
+				measured("none", 1, 0),
+				measured("gzip-1", 1.01, 60*mb),
+				measured("gzip-9", 1.00, 20*mb),
+			}},
+			goal: GoalSize,
+			want: "none",
+		},
+		{
+			name:    "tiny dump",
+			profile: CodecProfile{Size: 0, DumpRate: 10 * mb, Workers: 1, Codecs: typical()},
+			goal:    GoalTime,
+			want:    "none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimates, chosen := ChooseCodec(tt.profile, tt.goal)
+			if len(estimates) != len(tt.profile.Codecs) {
+				t.Fatalf("%d estimates for %d codecs", len(estimates), len(tt.profile.Codecs))
+			}
+			if chosen < 0 {
+				t.Fatalf("nothing chosen, want %s", tt.want)
+			}
+			if got := estimates[chosen].Codec.Name; got != tt.want {
+				t.Errorf("chose %s, want %s; estimates %+v", got, tt.want, estimates)
+			}
+		})
+	}
+}
+
+func TestChooseCodecEstimates(t *testing.T) {
+	profile := CodecProfile{Size: 1e9, DumpRate: 50 * mb, Workers: 2, Codecs: typical()}
+	estimates, _ := ChooseCodec(profile, GoalTime)
+
+	want := []struct {
+		name string
+		time time.Duration
+		size int64
+	}{
+		{"none", 20 * time.Second, 1e9},
+		{"gzip-1", 20 * time.Second, 3e8},         // keeps up with the dump
+		{"gzip-6", 20 * time.Second, 2.2e8},       // 60MB/s on two workers
+		{"gzip-9", 62500 * time.Millisecond, 2e8}, // 16MB/s on two workers
+	}
+	for i, w := range want {
+		got := estimates[i]
+		if got.Codec.Name != w.name || got.Time != w.time || got.Size != w.size {
+			t.Errorf("estimate %d = %s %v %d, want %s %v %d", i, got.Codec.Name, got.Time, got.Size, w.name, w.time, w.size)
+		}
+	}
+}
+
+func TestChooseCodecWithoutCodecs(t *testing.T) {
+	estimates, chosen := ChooseCodec(CodecProfile{Size: 1e9, DumpRate: 50 * mb}, GoalTime)
+	if len(estimates) != 0 || chosen != -1 {
+		t.Errorf("ChooseCodec = %v, %d; want none, -1", estimates, chosen)
+	}
+}
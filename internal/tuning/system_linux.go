@@ -0,0 +1,64 @@
+//go:build linux
+
+package tuning
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskType reads the rotational flag of the block device holding dir from
+// sysfs. Partitions have it on their disk, one directory up.
+func diskType(dir string) Disk {
+	var stat unix.Stat_t
+	if err := unix.Stat(dir, &stat); err != nil {
+		return DiskUnknown
+	}
+	device := fmt.Sprintf("/sys/dev/block/%d:%d", unix.Major(stat.Dev), unix.Minor(stat.Dev))
+	path, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return DiskUnknown
+	}
+	for _, queue := range []string{path, filepath.Dir(path)} {
+		flag, err := os.ReadFile(filepath.Join(queue, "queue", "rotational"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(flag)) == "1" {
+			return DiskRotational
+		}
+		return DiskSolidState
+	}
+	return DiskUnknown
+}
+
+// availableMemory returns MemAvailable from /proc/meminfo
+func availableMemory() int64 {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb << 10
+	}
+	return 0
+}
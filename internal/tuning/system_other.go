@@ -0,0 +1,13 @@
+//go:build !linux
+
+package tuning
+
+// diskType is unknown outside Linux
+func diskType(dir string) Disk {
+	return DiskUnknown
+}
+
+// availableMemory is unknown outside Linux
+func availableMemory() int64 {
+	return 0
+}
@@ -0,0 +1,281 @@
+// Package tuning holds the performance settings of a dump (buffer sizes,
+// compression level and workers, parallel jobs) and picks them from the
+// conditions a dump runs in for --auto-tune
+package tuning
+
+import (
+	"compress/gzip"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Limits of the settings, as validated in configs
+const (
+	MinWriterBuffer = 4 << 10
+	MaxWriterBuffer = 64 << 20
+
+	// mysqldump accepts --net-buffer-length from 4K to 16M
+	MinNetBuffer = 4 << 10
+	MaxNetBuffer = 16 << 20
+
+	MaxCompressionWorkers = 64
+	MaxDumpParallelism    = 64
+)
+
+// processMemory is roughly what one dbdump process and its mysqldump hold
+// besides the writer buffer; dump_parallelism is kept within the available
+// memory at this much per job
+const processMemory = 64 << 20
+
+// workerMemory is roughly what one compression worker holds: a block of
+// input, its compressed copy and the deflate state
+const workerMemory = 4 << 20
+
+// Settings are the performance knobs of a dump. A zero field means the
+// default.
+type Settings struct {
+	// WriterBuffer is the size of the buffer in front of the output file
+	WriterBuffer int64
+
+	// CompressionLevel is the gzip level, 1 (fastest) to 9 (smallest)
+	CompressionLevel int
+
+	// CompressionWorkers compress blocks of the output on this many
+	// goroutines; 1 compresses as the output is written
+	CompressionWorkers int
+
+	// DumpParallelism is how many jobs dbdump run dumps at once, unless
+	// --parallel-jobs is given
+	DumpParallelism int
+
+	// NetBuffer is mysqldump's --net-buffer-length, which also caps the
+	// size of its multi-row INSERT statements
+	NetBuffer int64
+}
+
+// Defaults returns the settings used when neither a config nor
+// --auto-tune sets them
+func Defaults() Settings {
+	return Settings{
+		WriterBuffer:       256 << 10,
+		CompressionLevel:   gzip.DefaultCompression,
+		CompressionWorkers: 1,
+		DumpParallelism:    1,
+		NetBuffer:          1 << 20,
+	}
+}
+
+// Filled returns s with its zero fields set to the defaults
+func (s Settings) Filled() Settings {
+	defaults := Defaults()
+	if s.WriterBuffer == 0 {
+		s.WriterBuffer = defaults.WriterBuffer
+	}
+	if s.CompressionLevel == 0 {
+		s.CompressionLevel = defaults.CompressionLevel
+	}
+	if s.CompressionWorkers == 0 {
+		s.CompressionWorkers = defaults.CompressionWorkers
+	}
+	if s.DumpParallelism == 0 {
+		s.DumpParallelism = defaults.DumpParallelism
+	}
+	if s.NetBuffer == 0 {
+		s.NetBuffer = defaults.NetBuffer
+	}
+	return s
+}
+
+// GzipLevel returns the level for compress/gzip, where the default is -1
+func (s Settings) GzipLevel() int {
+	if s.CompressionLevel == 0 {
+		return gzip.DefaultCompression
+	}
+	return s.CompressionLevel
+}
+
+// String describes the settings for -v
+func (s Settings) String() string {
+	s = s.Filled()
+	level := fmt.Sprintf("%d", s.CompressionLevel)
+	if s.CompressionLevel == gzip.DefaultCompression {
+		level = "default"
+	}
+	return fmt.Sprintf("writer buffer %s, net buffer %s, gzip level %s with %d worker(s), %d parallel job(s)",
+		formatSize(s.WriterBuffer), formatSize(s.NetBuffer), level, s.CompressionWorkers, s.DumpParallelism)
+}
+
+// Validate returns the problems of settings read from a config; zero
+// fields are unset and fine
+func (s Settings) Validate() []string {
+	var problems []string
+	if s.WriterBuffer != 0 && (s.WriterBuffer < MinWriterBuffer || s.WriterBuffer > MaxWriterBuffer) {
+		problems = append(problems, fmt.Sprintf("performance.writer_buffer must be between %s and %s", formatSize(MinWriterBuffer), formatSize(MaxWriterBuffer)))
+	}
+	if s.CompressionLevel != 0 && (s.CompressionLevel < gzip.BestSpeed || s.CompressionLevel > gzip.BestCompression) {
+		problems = append(problems, fmt.Sprintf("performance.compression_level must be between %d and %d, got %d", gzip.BestSpeed, gzip.BestCompression, s.CompressionLevel))
+	}
+	if s.CompressionWorkers < 0 || s.CompressionWorkers > MaxCompressionWorkers {
+		problems = append(problems, fmt.Sprintf("performance.compression_workers must be between 1 and %d, got %d", MaxCompressionWorkers, s.CompressionWorkers))
+	}
+	if s.DumpParallelism < 0 || s.DumpParallelism > MaxDumpParallelism {
+		problems = append(problems, fmt.Sprintf("performance.dump_parallelism must be between 1 and %d, got %d", MaxDumpParallelism, s.DumpParallelism))
+	}
+	if s.NetBuffer != 0 && (s.NetBuffer < MinNetBuffer || s.NetBuffer > MaxNetBuffer) {
+		problems = append(problems, fmt.Sprintf("performance.net_buffer must be between %s and %s", formatSize(MinNetBuffer), formatSize(MaxNetBuffer)))
+	}
+	return problems
+}
+
+// Override returns s with the fields set in overlay
+func (s Settings) Override(overlay Settings) Settings {
+	if overlay.WriterBuffer != 0 {
+		s.WriterBuffer = overlay.WriterBuffer
+	}
+	if overlay.CompressionLevel != 0 {
+		s.CompressionLevel = overlay.CompressionLevel
+	}
+	if overlay.CompressionWorkers != 0 {
+		s.CompressionWorkers = overlay.CompressionWorkers
+	}
+	if overlay.DumpParallelism != 0 {
+		s.DumpParallelism = overlay.DumpParallelism
+	}
+	if overlay.NetBuffer != 0 {
+		s.NetBuffer = overlay.NetBuffer
+	}
+	return s
+}
+
+// Disk is the kind of device the output goes to
+type Disk string
+
+const (
+	DiskUnknown    Disk = ""
+	DiskSolidState Disk = "ssd"
+	DiskRotational Disk = "rotational"
+)
+
+// Conditions are what --auto-tune bases the settings on
+type Conditions struct {
+	// Local is set when the server is on this machine (a loopback host)
+	Local bool
+
+	CPUs int
+
+	// Disk is the device holding the output directory, as far as the
+	// platform tells (only Linux does)
+	Disk Disk
+
+	// AvailableMemory is the memory available for new allocations without
+	// swapping, or 0 when unknown
+	AvailableMemory int64
+
+	// Jobs is how many dumps run at once, sharing the CPUs and memory (the
+	// jobs of dbdump run --parallel-jobs)
+	Jobs int
+}
+
+// Detect returns the conditions of a dump from a server that is local or
+// not, written to dir, running beside jobs-1 others
+func Detect(local bool, dir string, jobs int) Conditions {
+	return Conditions{
+		Local:           local,
+		CPUs:            runtime.NumCPU(),
+		Disk:            diskType(dir),
+		AvailableMemory: availableMemory(),
+		Jobs:            max(jobs, 1),
+	}
+}
+
+// String describes the conditions for -v
+func (c Conditions) String() string {
+	parts := []string{"remote server"}
+	if c.Local {
+		parts[0] = "local server"
+	}
+	parts = append(parts, fmt.Sprintf("%d CPU(s)", c.CPUs))
+	switch c.Disk {
+	case DiskRotational:
+		parts = append(parts, "rotational disk")
+	case DiskSolidState:
+		parts = append(parts, "solid-state disk")
+	}
+	if c.AvailableMemory > 0 {
+		parts = append(parts, formatSize(c.AvailableMemory)+" available")
+	}
+	if c.Jobs > 1 {
+		parts = append(parts, fmt.Sprintf("%d jobs at once", c.Jobs))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Auto picks settings for the conditions:
+//
+//   - mysqldump and the compressor compete for CPUs on a local server, so
+//     compression is light there unless the disk is the bottleneck; over
+//     the network it has the time to compress harder
+//   - compression workers share the CPUs left over between the jobs, one
+//     being kept for mysqldump (or the server, when it is local)
+//   - rotational disks get a larger writer buffer for fewer, longer writes,
+//     and one job at a time so parallel dumps don't fight over the heads
+//   - remote servers get a larger net buffer for fewer round trips
+//   - workers and parallel jobs are cut back to fit the available memory
+func Auto(c Conditions) Settings {
+	cpus, jobs := max(c.CPUs, 1), max(c.Jobs, 1)
+	s := Defaults()
+
+	s.CompressionWorkers = min(max((cpus-1)/jobs, 1), 8)
+	switch {
+	case c.Disk == DiskRotational:
+		s.CompressionLevel = 6
+	case !c.Local:
+		s.CompressionLevel = 6
+		s.NetBuffer = 2 << 20
+	case cpus <= 2:
+		s.CompressionLevel = gzip.BestSpeed
+	default:
+		s.CompressionLevel = 3
+	}
+
+	switch {
+	case c.Disk == DiskRotational:
+		s.WriterBuffer = 4 << 20
+		s.DumpParallelism = 1
+	case c.Local:
+		s.WriterBuffer = 1 << 20
+		s.DumpParallelism = min(max(cpus/2, 1), 4)
+	default:
+		// Remote servers do the work; the client mostly waits
+		s.WriterBuffer = 1 << 20
+		s.DumpParallelism = min(max(cpus, 2), 4)
+	}
+
+	if c.AvailableMemory > 0 {
+		budget := c.AvailableMemory / 2
+		if budget < 1<<30 {
+			s.WriterBuffer = Defaults().WriterBuffer
+		}
+		s.DumpParallelism = int(min(int64(s.DumpParallelism), max(budget/processMemory, 1)))
+		perJob := budget / int64(jobs)
+		s.CompressionWorkers = int(min(int64(s.CompressionWorkers), max(perJob/workerMemory, 1)))
+	}
+	return s
+}
+
+// formatSize formats a byte count in binary units, as configs give them
+// (256KiB, 1MiB), with a decimal when it isn't a whole number of units
+func formatSize(bytes int64) string {
+	size, unit := float64(bytes), "B"
+	for _, next := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		if size < 1024 {
+			break
+		}
+		size, unit = size/1024, next
+	}
+	if size == float64(int64(size)) {
+		return fmt.Sprintf("%d%s", int64(size), unit)
+	}
+	return fmt.Sprintf("%.1f%s", size, unit)
+}
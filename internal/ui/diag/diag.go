@@ -0,0 +1,122 @@
+// Package diag collects the warnings raised during a run, so they can be
+// repeated in one place at the end and turned into a failing exit code
+package diag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Warning is a distinct warning message and how often it was raised
+type Warning struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// Collector records warnings in the order they were first raised, keeping
+// each distinct message once
+type Collector struct {
+	mu       sync.Mutex
+	warnings []Warning
+	index    map[string]int
+}
+
+// NewCollector creates an empty collector
+func NewCollector() *Collector {
+	return &Collector{index: make(map[string]int)}
+}
+
+// Add records a warning and reports whether the message is new
+func (c *Collector) Add(message string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i, ok := c.index[message]; ok {
+		c.warnings[i].Count++
+		return false
+	}
+	c.index[message] = len(c.warnings)
+	c.warnings = append(c.warnings, Warning{Message: message, Count: 1})
+	return true
+}
+
+// Warnings returns a copy of the recorded warnings
+func (c *Collector) Warnings() []Warning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Warning(nil), c.warnings...)
+}
+
+// Count returns the number of distinct warnings
+func (c *Collector) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.warnings)
+}
+
+// Reset forgets all recorded warnings
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.warnings = nil
+	c.index = make(map[string]int)
+}
+
+// Default is the collector used by the package-level functions
+var Default = NewCollector()
+
+// Output is where Warnf prints warnings
+var Output io.Writer = os.Stderr
+
+// OnWarning, if set, is called with each distinct warning the first time
+// Warnf or Record raises it
+var OnWarning func(message string)
+
+// Redact, if set, masks secrets in every warning before it is recorded,
+// printed or passed on
+var Redact func(message string) string
+
+// Record adds a warning to the default collector without printing it, for
+// callers that print the warning themselves
+func Record(message string) {
+	add(message)
+}
+
+// Warnf records a warning and prints it to stderr
+func Warnf(format string, args ...any) {
+	message := redacted(fmt.Sprintf(format, args...))
+	add(message)
+	fmt.Fprintf(Output, "Warning: %s\n", message)
+}
+
+// redacted returns message with Redact applied
+func redacted(message string) string {
+	if Redact == nil {
+		return message
+	}
+	return Redact(message)
+}
+
+// add records a warning in the default collector and passes it on to
+// OnWarning if it is new
+func add(message string) {
+	message = redacted(message)
+	if Default.Add(message) && OnWarning != nil {
+		OnWarning(message)
+	}
+}
+
+// Warnings returns the warnings recorded so far
+func Warnings() []Warning {
+	return Default.Warnings()
+}
+
+// Count returns the number of distinct warnings recorded so far
+func Count() int {
+	return Default.Count()
+}
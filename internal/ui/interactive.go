@@ -1,113 +1,971 @@
 package ui
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/patterns"
+	"github.com/helgesverre/dbdump/internal/sqlident"
 )
 
+// maxCommentWidth is how much of a table comment is shown inline
+const maxCommentWidth = 40
+
+// SelectionOptions contains optional context for the table selector
+type SelectionOptions struct {
+	// Title says what selecting a table does; the dump selector's by default
+	Title string
+
+	// Selected describes the data of the selected tables in the footer,
+	// "of data excluded" by default
+	Selected string
+
+	// Reasons explains per table why it is pre-selected (matching rule, engine)
+	Reasons map[string]string
+
+	// Suggested tables match rules of medium confidence: they are marked
+	// in the list but not selected
+	Suggested map[string]bool
+
+	// SampleRows is the number of rows kept of tables whose data is
+	// sampled rather than excluded when they are selected
+	SampleRows map[string]int
+
+	// FetchColumns loads a table's columns for the detail view; it must
+	// return promptly when ctx is cancelled
+	FetchColumns func(ctx context.Context, table string) ([]database.ColumnInfo, error)
+
+	// FetchRows loads the first rows of a table for the row preview; it
+	// must return promptly when ctx is cancelled
+	FetchRows func(ctx context.Context, table string) (*database.RowPreview, error)
+
+	// GroupDelimiter separates name segments for the grouped view ("_" if empty)
+	GroupDelimiter string
+
+	// Load, when set, reads the tables while the selector is already
+	// running, calling update as information arrives; the last update has
+	// Final set. The tables passed to RunInteractiveSelection are ignored.
+	Load func(ctx context.Context, update func(TableUpdate)) error
+}
+
+// listRow is a line in the table list: a table, or a group header when table is -1
+type listRow struct {
+	group int // index into groups, -1 outside the grouped view
+	table int // index into tables, -1 for a group header
+}
+
+// tableGroup is a prefix group in the grouped view
+type tableGroup struct {
+	prefix string // empty for tables without a shared prefix (no header)
+	tables []int
+}
+
+// columnsMsg delivers the result of a column fetch
+type columnsMsg struct {
+	table   string
+	columns []database.ColumnInfo
+	err     error
+}
+
+// spinnerTickMsg advances the loading spinner
+type spinnerTickMsg struct{}
+
+// Sort orders of the table list, cycled with S
+const (
+	sortSize = iota
+	sortRows
+	sortName
+	sortOrders
+)
+
+// sortNames describes the sort orders in the footer
+var sortNames = [sortOrders]string{"size", "row count", "name"}
+
 // TableSelectionModel represents the interactive table selection UI
 type TableSelectionModel struct {
 	tables   []database.TableInfo
 	selected map[string]bool
 	cursor   int
 	done     bool
+	rows     []listRow
+
+	grouped   bool
+	groups    []tableGroup
+	shown     []tableGroup // groups narrowed by the filter and sorted; rows refer to them
+	collapsed map[string]bool
+
+	// filter narrows the list to tables whose name contains it; typing goes
+	// into it while filtering is set
+	filter    string
+	filtering bool
+	sortBy    int
+
+	height int // terminal rows, 0 when unknown
+	offset int // first row shown when the list is taller than the screen
+
+	options  SelectionOptions
+	expanded bool
+	columns  map[string][]database.ColumnInfo
+	colErrs  map[string]error
+	loading  string
+	parent   context.Context // column and row fetches stop when it is cancelled
+	cancel   context.CancelFunc
+	frame    int
+
+	// previewing shows the highlighted table's first rows instead of its
+	// columns; previews are kept for the session
+	previewing    bool
+	previews      map[string]*database.RowPreview
+	previewErrs   map[string]error
+	previewOffset int
+
+	width int // terminal columns, 0 when unknown
+
+	// While options.Load is reading: reading until the final update,
+	// confirming once the user confirmed before it arrived. touched holds
+	// the tables the user toggled, which keep their state across updates.
+	reading    bool
+	confirming bool
+	aborted    bool
+	loadErr    error
+	touched    map[string]bool
+	status     string
+
+	// reconcile, while set, asks which selection source to follow before
+	// the list is shown
+	reconcile *reconcileState
+
+	// analyzed is set when some tables had their statistics refreshed; the
+	// estimates of the others are then marked as possibly stale
+	analyzed bool
+
+	// twins maps tables to the others whose names differ only by case,
+	// which restore into one table on servers that fold names
+	twins map[string][]string
 }
 
 // NewTableSelectionModel creates a new table selection model
-func NewTableSelectionModel(tables []database.TableInfo, preSelected []string) TableSelectionModel {
+func NewTableSelectionModel(tables []database.TableInfo, preSelected []string, options SelectionOptions) TableSelectionModel {
 	selected := make(map[string]bool)
 	for _, table := range preSelected {
 		selected[table] = true
 	}
 
-	return TableSelectionModel{
-		tables:   tables,
-		selected: selected,
-		cursor:   0,
-		done:     false,
+	m := TableSelectionModel{
+		tables:      tables,
+		selected:    selected,
+		cursor:      0,
+		done:        false,
+		options:     options,
+		collapsed:   make(map[string]bool),
+		columns:     make(map[string][]database.ColumnInfo),
+		colErrs:     make(map[string]error),
+		previews:    make(map[string]*database.RowPreview),
+		previewErrs: make(map[string]error),
+		width:       Term().Width,
+		height:      Term().Height,
+		reading:     options.Load != nil,
+		touched:     make(map[string]bool),
+		twins:       caseTwins(tables),
 	}
+	m.buildRows()
+	return m
 }
 
 // Init initializes the model
 func (m TableSelectionModel) Init() tea.Cmd {
+	if m.reading {
+		return spinnerTick()
+	}
 	return nil
 }
 
 // Update handles messages
 func (m TableSelectionModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m, cmd := m.update(msg)
+	m.scroll()
+	return m, cmd
+}
+
+// update handles a message; Update then scrolls the list to the cursor
+func (m TableSelectionModel) update(msg tea.Msg) (TableSelectionModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.reconcile != nil {
+			return m.reconcileKey(msg)
+		}
+		if m.filtering {
+			if cmd, handled := m.filterKey(msg); handled {
+				return m, cmd
+			}
+		}
+
 		switch msg.String() {
 		case "ctrl+c", "q":
-			m.done = true
+			// Leave without dumping anything
+			m.stopFetch()
+			m.aborted = true
 			return m, tea.Quit
 
-		case "enter":
+		case "c":
+			m.stopFetch()
+			if m.reading {
+				// Exclusions are only final once every rule has been applied
+				m.confirming = true
+				return m, nil
+			}
 			m.done = true
 			return m, tea.Quit
 
+		case "/":
+			m.filtering = true
+
+		case "esc":
+			if m.filter != "" {
+				m.filter = ""
+				return m, m.refresh()
+			}
+
+		case "a", "n":
+			// Select or deselect every listed table, including those in
+			// collapsed groups
+			value := msg.String() == "a"
+			for _, group := range m.listedGroups() {
+				for _, i := range group.tables {
+					m.selected[m.tables[i].Name] = value
+					m.touched[m.tables[i].Name] = true
+				}
+			}
+
+		case "s":
+			m.sortBy = (m.sortBy + 1) % sortOrders
+			return m, m.refresh()
+
+		case "t":
+			// Switch between the flat list and groups by name prefix
+			m.stopFetch()
+			m.toggleGrouped()
+			if m.expanded || m.previewing {
+				return m, m.fetchCurrent()
+			}
+
+		case "enter":
+			if row, ok := m.currentRow(); ok && row.table < 0 {
+				// Expand or collapse a group
+				prefix := m.shown[row.group].prefix
+				m.collapsed[prefix] = !m.collapsed[prefix]
+				m.buildRows()
+				return m, nil
+			}
+
+			// Toggle the detail view with the table's columns
+			m.stopFetch()
+			m.expanded = !m.expanded
+			m.previewing = false
+			if !m.expanded {
+				return m, nil
+			}
+			return m, m.fetchCurrent()
+
+		case "v":
+			// Toggle the preview of the table's first rows
+			m.stopFetch()
+			m.previewing = !m.previewing
+			m.expanded = false
+			m.previewOffset = 0
+			if !m.previewing {
+				return m, nil
+			}
+			return m, m.fetchCurrent()
+
+		case "pgdown", "pgup":
+			if m.previewing {
+				lines := previewHeight / 2
+				if msg.String() == "pgup" {
+					lines = -lines
+				}
+				m.scrollPreview(lines)
+			}
+
 		case "up", "k":
 			if m.cursor > 0 {
 				m.cursor--
+				return m, m.moved()
 			}
 
 		case "down", "j":
-			if m.cursor < len(m.tables)-1 {
+			if m.cursor < len(m.rows)-1 {
 				m.cursor++
+				return m, m.moved()
 			}
 
 		case " ":
-			// Toggle selection
-			table := m.tables[m.cursor].Name
-			m.selected[table] = !m.selected[table]
+			// Toggle selection; on a group header, toggle all of its tables
+			row, ok := m.currentRow()
+			if !ok {
+				break
+			}
+			if row.table >= 0 {
+				table := m.tables[row.table].Name
+				m.selected[table] = !m.selected[table]
+				m.touched[table] = true
+				break
+			}
+			group := m.shown[row.group]
+			value := m.groupState(group) != groupAll
+			for _, i := range group.tables {
+				m.selected[m.tables[i].Name] = value
+				m.touched[m.tables[i].Name] = true
+			}
+		}
+
+	case tableUpdateMsg:
+		m.applyUpdate(TableUpdate(msg))
+		if msg.Final {
+			m.reading = false
+			m.startReconcile(msg.Reconcile)
+			if m.confirming && m.reconcile == nil {
+				m.done = true
+				return m, tea.Quit
+			}
+		}
+
+	case loadFailedMsg:
+		m.loadErr = msg.err
+		m.done = true
+		return m, tea.Quit
+
+	case columnsMsg:
+		if msg.table != m.loading || m.previewing {
+			return m, nil // stale result for a row the user already left
+		}
+		m.loading = ""
+		m.cancel = nil
+		if errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.colErrs[msg.table] = msg.err
+		} else {
+			m.columns[msg.table] = msg.columns
+		}
+
+	case rowsMsg:
+		if msg.table != m.loading || !m.previewing {
+			return m, nil // stale result for a row the user already left
+		}
+		m.loading = ""
+		m.cancel = nil
+		if errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.previewErrs[msg.table] = msg.err
+		} else {
+			m.previews[msg.table] = msg.preview
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case spinnerTickMsg:
+		if m.loading != "" || m.reading {
+			m.frame = (m.frame + 1) % len(Sym().Spinner)
+			return m, spinnerTick()
 		}
 	}
 
 	return m, nil
 }
 
+// buildRows lays out the visible rows for the current view
+func (m *TableSelectionModel) buildRows() {
+	m.rows = m.rows[:0]
+	m.shown = m.shown[:0]
+	if !m.grouped {
+		for _, i := range m.listed(nil) {
+			m.rows = append(m.rows, listRow{group: -1, table: i})
+		}
+	} else {
+		for _, group := range m.groups {
+			tables := m.listed(group.tables)
+			if len(tables) == 0 {
+				continue
+			}
+			g := len(m.shown)
+			m.shown = append(m.shown, tableGroup{prefix: group.prefix, tables: tables})
+			if group.prefix != "" {
+				m.rows = append(m.rows, listRow{group: g, table: -1})
+				if m.collapsed[group.prefix] {
+					continue
+				}
+			}
+			for _, i := range tables {
+				m.rows = append(m.rows, listRow{group: g, table: i})
+			}
+		}
+	}
+	if m.cursor >= len(m.rows) {
+		m.cursor = max(0, len(m.rows)-1)
+	}
+}
+
+// listed returns the tables (indexes into tables, all of them when nil)
+// that match the filter, in the current sort order
+func (m TableSelectionModel) listed(tables []int) []int {
+	if tables == nil {
+		tables = make([]int, len(m.tables))
+		for i := range tables {
+			tables[i] = i
+		}
+	}
+
+	filter := strings.ToLower(m.filter)
+	var result []int
+	for _, i := range tables {
+		if strings.Contains(strings.ToLower(m.tables[i].Name), filter) {
+			result = append(result, i)
+		}
+	}
+
+	// Stable, so tables that compare equal keep the order they were read in
+	slices.SortStableFunc(result, func(a, b int) int {
+		ta, tb := m.tables[a], m.tables[b]
+		switch m.sortBy {
+		case sortRows:
+			return cmp.Compare(tb.RowCount, ta.RowCount)
+		case sortName:
+			return strings.Compare(ta.Name, tb.Name)
+		}
+		return cmp.Compare(tb.TotalSize, ta.TotalSize)
+	})
+	return result
+}
+
+// listedGroups returns the groups of the listed tables; the flat view is a
+// single group
+func (m TableSelectionModel) listedGroups() []tableGroup {
+	if m.grouped {
+		return m.shown
+	}
+	return []tableGroup{{tables: m.listed(nil)}}
+}
+
+// refresh rebuilds the rows after the filter or sort order changed, keeping
+// the cursor on the same table while it is still listed
+func (m *TableSelectionModel) refresh() tea.Cmd {
+	current := -1
+	if row, ok := m.currentRow(); ok {
+		current = row.table
+	}
+	m.buildRows()
+	for i, row := range m.rows {
+		if row.table >= 0 && row.table == current {
+			m.cursor = i
+			return nil
+		}
+	}
+	return m.moved()
+}
+
+// filterKey handles a key while the filter is being typed; keys that don't
+// edit the filter (moving, Ctrl+C) are left to the list
+func (m *TableSelectionModel) filterKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.filtering = false
+	case tea.KeyEsc:
+		m.filtering = false
+		m.filter = ""
+	case tea.KeyBackspace:
+		if m.filter == "" {
+			m.filtering = false
+			return nil, true
+		}
+		runes := []rune(m.filter)
+		m.filter = string(runes[:len(runes)-1])
+	case tea.KeyRunes, tea.KeySpace:
+		m.filter += string(msg.Runes)
+	default:
+		return nil, false
+	}
+	return m.refresh(), true
+}
+
+// scroll moves the viewport so the cursor row is on screen
+func (m *TableSelectionModel) scroll() {
+	height := m.listHeight()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+height {
+		m.offset = m.cursor - height + 1
+	}
+	m.offset = max(0, min(m.offset, len(m.rows)-height))
+}
+
+// listHeight is how many rows of the list fit on screen next to the rest of
+// the view, or all of them when the terminal height is unknown
+func (m TableSelectionModel) listHeight() int {
+	if m.height <= 0 {
+		return max(1, len(m.rows))
+	}
+	// Two lines are kept for the markers of rows above and below
+	used := strings.Count(m.header(), "\n") + strings.Count(m.footer(), "\n") + 2
+	if row, ok := m.currentRow(); ok && row.table >= 0 {
+		used += strings.Count(m.detailView(m.tables[row.table]), "\n")
+	}
+	return max(3, m.height-used)
+}
+
+// toggleGrouped switches views, keeping the cursor on the same table (or its group)
+func (m *TableSelectionModel) toggleGrouped() {
+	current := -1
+	if row, ok := m.currentRow(); ok {
+		current = row.table
+		if current < 0 {
+			current = m.shown[row.group].tables[0]
+		}
+	}
+
+	m.grouped = !m.grouped
+	if m.grouped && m.groups == nil {
+		m.groups = groupTables(m.tables, m.options.GroupDelimiter)
+		// Start collapsed so large prefix sets fit on screen
+		for _, group := range m.groups {
+			if group.prefix != "" {
+				m.collapsed[group.prefix] = true
+			}
+		}
+	}
+	m.buildRows()
+
+	m.cursor = 0
+	for i, row := range m.rows {
+		if row.table == current {
+			m.cursor = i
+			return
+		}
+	}
+	for i, row := range m.rows {
+		if row.table < 0 && m.groupContains(row.group, current) {
+			m.cursor = i
+			return
+		}
+	}
+}
+
+// groupTables groups tables by shared name prefix
+func groupTables(tables []database.TableInfo, delimiter string) []tableGroup {
+	if delimiter == "" {
+		delimiter = "_"
+	}
+
+	names := make([]string, len(tables))
+	index := make(map[string]int, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+		index[table.Name] = i
+	}
+
+	var groups []tableGroup
+	for _, group := range patterns.GroupByPrefix(names, delimiter) {
+		tg := tableGroup{prefix: group.Prefix}
+		for _, name := range group.Tables {
+			tg.tables = append(tg.tables, index[name])
+		}
+		groups = append(groups, tg)
+	}
+	return groups
+}
+
+// groupContains reports whether a shown group contains the table at index table
+func (m TableSelectionModel) groupContains(group, table int) bool {
+	return slices.Contains(m.shown[group].tables, table)
+}
+
+// Selection states of a group
+const (
+	groupNone = iota
+	groupSome
+	groupAll
+)
+
+// groupState reports how many of a group's tables are selected
+func (m TableSelectionModel) groupState(group tableGroup) int {
+	count := 0
+	for _, i := range group.tables {
+		if m.selected[m.tables[i].Name] {
+			count++
+		}
+	}
+	switch count {
+	case 0:
+		return groupNone
+	case len(group.tables):
+		return groupAll
+	}
+	return groupSome
+}
+
+// currentRow returns the row under the cursor
+func (m TableSelectionModel) currentRow() (listRow, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.rows) {
+		return listRow{}, false
+	}
+	return m.rows[m.cursor], true
+}
+
+// moved cancels a fetch for the previous row and starts one for the new row
+// when the detail view or the row preview is open
+func (m *TableSelectionModel) moved() tea.Cmd {
+	m.stopFetch()
+	m.previewOffset = 0
+	if !m.expanded && !m.previewing {
+		return nil
+	}
+	return m.fetchCurrent()
+}
+
+// fetchCurrent starts loading the columns (or, in the row preview, the rows)
+// of the highlighted table unless cached
+func (m *TableSelectionModel) fetchCurrent() tea.Cmd {
+	if m.previewing {
+		return m.fetchRows()
+	}
+	row, ok := m.currentRow()
+	if m.options.FetchColumns == nil || !ok || row.table < 0 {
+		return nil
+	}
+	table := m.tables[row.table].Name
+	if _, ok := m.columns[table]; ok {
+		return nil
+	}
+	delete(m.colErrs, table)
+
+	parent := m.parent
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	m.cancel = cancel
+	m.loading = table
+
+	fetch := m.options.FetchColumns
+	return tea.Batch(func() tea.Msg {
+		columns, err := fetch(ctx, table)
+		return columnsMsg{table: table, columns: columns, err: err}
+	}, spinnerTick())
+}
+
+// stopFetch cancels an in-flight column or row fetch
+func (m *TableSelectionModel) stopFetch() {
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.loading = ""
+}
+
+// spinnerTick schedules the next spinner frame
+func spinnerTick() tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg {
+		return spinnerTickMsg{}
+	})
+}
+
 // View renders the UI
 func (m TableSelectionModel) View() string {
-	if m.done {
+	if m.done || m.aborted {
 		return ""
 	}
+	if m.reconcile != nil {
+		return m.reconcileView()
+	}
 
 	var b strings.Builder
+	sym := Sym()
+	b.WriteString(m.header())
 
-	b.WriteString("\n")
-	b.WriteString("  Select tables to EXCLUDE data from (structure will be preserved)\n")
-	b.WriteString("  Use ↑/↓ or j/k to move, SPACE to toggle, ENTER to confirm\n\n")
+	height := m.listHeight()
+	end := min(len(m.rows), m.offset+height)
+	if m.offset > 0 {
+		fmt.Fprintf(&b, "    %s %d more above\n", sym.Ellipsis, m.offset)
+	}
+	if len(m.rows) == 0 && m.filter != "" {
+		b.WriteString(m.fit(fmt.Sprintf("    No tables match %q", m.filter)) + "\n")
+	}
 
-	for i, table := range m.tables {
+	for i := m.offset; i < end; i++ {
+		row := m.rows[i]
 		cursor := " "
 		if i == m.cursor {
 			cursor = ">"
 		}
 
-		checkbox := "☐"
+		if row.table < 0 {
+			b.WriteString(m.fit(m.groupLine(cursor, m.shown[row.group])) + "\n")
+			continue
+		}
+
+		table := m.tables[row.table]
+		checkbox := sym.Unchecked
 		if m.selected[table.Name] {
-			checkbox = "☑"
+			checkbox = sym.Checked
+		}
+
+		indent := ""
+		if row.group >= 0 && m.shown[row.group].prefix != "" {
+			indent = "    "
 		}
 
-		line := fmt.Sprintf("  %s %s %-30s (%s, %d rows)\n",
+		stats := fmt.Sprintf("%s, %d rows", table.SizeDisplay, table.RowCount)
+		switch {
+		case table.SizeUnknown:
+			stats = "size unavailable"
+		case m.analyzed && !table.Analyzed:
+			// Not refreshed by pre_analyze: the estimate may be stale
+			stats = fmt.Sprintf("~%s, ~%d rows", table.SizeDisplay, table.RowCount)
+		}
+		line := fmt.Sprintf("  %s %s%s %s (%s)",
 			cursor,
+			indent,
 			checkbox,
-			table.Name,
-			table.SizeDisplay,
-			table.RowCount,
+			PadRight(table.Name, 30),
+			stats,
 		)
+		if rows, ok := m.options.SampleRows[table.Name]; ok && m.selected[table.Name] {
+			line += fmt.Sprintf("  sampled (%d rows)", rows)
+		}
+		if m.options.Suggested[table.Name] && !m.selected[table.Name] {
+			line += "  " + sym.Suggested + " suggested"
+		}
+		if len(m.twins[table.Name]) > 0 {
+			line += "  " + sym.Warning + " case"
+		}
+		if table.Comment != "" {
+			line += "  " + Truncate(table.Comment, maxCommentWidth)
+		}
+
+		b.WriteString(m.fit(line) + "\n")
+	}
+	if end < len(m.rows) {
+		fmt.Fprintf(&b, "    %s %d more below\n", sym.Ellipsis, len(m.rows)-end)
+	}
+
+	if row, ok := m.currentRow(); ok && row.table >= 0 {
+		b.WriteString(m.detailView(m.tables[row.table]))
+	}
+
+	b.WriteString(m.footer())
+
+	return b.String()
+}
+
+// header renders the title, the key help and the loading status
+func (m TableSelectionModel) header() string {
+	var b strings.Builder
+
+	b.WriteString("\n")
+	title := m.options.Title
+	if title == "" {
+		title = "Select tables to EXCLUDE data from (structure will be preserved)"
+	}
+	b.WriteString(m.fit("  "+title) + "\n")
+	sym := Sym()
+	arrows := "↑/↓"
+	if !Term().Unicode {
+		arrows = "up/down"
+	}
+	keys := "  Use " + arrows + " or j/k to move, SPACE to toggle"
+	if m.options.FetchColumns != nil {
+		keys += ", ENTER for details"
+	}
+	if m.options.FetchRows != nil {
+		keys += ", V to preview rows"
+	}
+	b.WriteString(m.fit(keys+", T to group by prefix") + "\n")
+	b.WriteString(m.fit("  / to filter, A/N to select/deselect the listed tables, S to sort, C to confirm, Q to cancel") + "\n\n")
+	if m.reading {
+		status := "Reading tables"
+		switch {
+		case m.confirming:
+			status = "Waiting for table sizes and rules before confirming"
+		case m.status != "":
+			status = m.status
+		case len(m.tables) > 0:
+			status = "Reading table sizes"
+		}
+		fmt.Fprintf(&b, "  %s %s%s\n\n", sym.Spinner[m.frame%len(sym.Spinner)], status, sym.Ellipsis)
+	}
+
+	return b.String()
+}
+
+// footer renders how much data the selection excludes, the sort order and
+// the filter
+func (m TableSelectionModel) footer() string {
+	var b strings.Builder
 
-		b.WriteString(line)
+	var count int
+	var size int64
+	for _, table := range m.tables {
+		if m.selected[table.Name] {
+			count++
+			size += table.DataSize
+		}
+	}
+	b.WriteString("\n")
+	selected := m.options.Selected
+	if selected == "" {
+		selected = "of data excluded"
 	}
+	b.WriteString(m.fit(fmt.Sprintf("  %d of %d tables selected, %s %s", count, len(m.tables), database.FormatBytes(size), selected)) + "\n")
 
+	status := "  Sorted by " + sortNames[m.sortBy]
+	if m.filter != "" {
+		listed := 0
+		for _, group := range m.listedGroups() {
+			listed += len(group.tables)
+		}
+		status += fmt.Sprintf(", %d tables match %q", listed, m.filter)
+	}
+	b.WriteString(m.fit(status) + "\n")
+	if m.filtering {
+		b.WriteString(m.fit("  /"+m.filter+"_  (ENTER to keep, ESC to clear)") + "\n")
+	}
 	b.WriteString("\n")
 
 	return b.String()
 }
 
+// groupLine renders a group header with aggregate size and row count
+func (m TableSelectionModel) groupLine(cursor string, group tableGroup) string {
+	sym := Sym()
+	arrow := sym.Expanded
+	if m.collapsed[group.prefix] {
+		arrow = sym.Collapsed
+	}
+
+	checkbox := sym.Unchecked
+	switch m.groupState(group) {
+	case groupAll:
+		checkbox = sym.Checked
+	case groupSome:
+		checkbox = sym.Partial
+	}
+
+	var size, rows int64
+	known := true
+	for _, i := range group.tables {
+		size += m.tables[i].TotalSize
+		rows += m.tables[i].RowCount
+		known = known && !m.tables[i].SizeUnknown
+	}
+
+	stats := fmt.Sprintf("%s, %d rows", database.FormatBytes(size), rows)
+	if !known {
+		stats = "size unavailable"
+	}
+	return fmt.Sprintf("  %s %s %s %s (%d tables, %s)",
+		cursor,
+		arrow,
+		checkbox,
+		PadRight(group.prefix+"*", 30),
+		len(group.tables),
+		stats,
+	)
+}
+
+// detailView renders details for the highlighted table
+func (m TableSelectionModel) detailView(table database.TableInfo) string {
+	sym := Sym()
+	separator := "  " + sym.Separator + "  "
+
+	var b strings.Builder
+	b.WriteString("\n  " + strings.Repeat(sym.Rule, min(60, max(10, m.width-4))) + "\n")
+	header := "  " + table.Name
+	if table.Engine != "" {
+		header += separator + table.Engine
+	}
+	if !table.CreateTime.IsZero() {
+		header += separator + "created " + table.CreateTime.Format("2006-01-02")
+	}
+	if !table.UpdateTime.IsZero() {
+		header += separator + "updated " + table.UpdateTime.Format("2006-01-02 15:04")
+	}
+	b.WriteString(m.fit(header) + "\n")
+	if table.Comment != "" {
+		b.WriteString(m.fit("  Comment: "+table.Comment) + "\n")
+	}
+	switch {
+	case table.Analyzed:
+		b.WriteString(m.fit("  Statistics refreshed with ANALYZE TABLE just now") + "\n")
+	case m.analyzed && !table.SizeUnknown:
+		b.WriteString(m.fit("  Size and rows are estimates from statistics that were not refreshed (~)") + "\n")
+	}
+	if rows, ok := m.options.SampleRows[table.Name]; ok {
+		data := fmt.Sprintf("  Data: sampled (last %d rows)", rows)
+		if !m.selected[table.Name] {
+			data = "  Data: dumped fully (select to sample it)"
+		}
+		b.WriteString(m.fit(data) + "\n")
+	}
+	if twins := m.twins[table.Name]; len(twins) > 0 {
+		b.WriteString(m.fit(fmt.Sprintf("  %s Differs from %s only by case: they collide on servers with lower_case_table_names=1",
+			sym.Warning, strings.Join(twins, ", "))) + "\n")
+	}
+	if reason, ok := m.options.Reasons[table.Name]; ok {
+		label := "  Pre-selected: "
+		switch {
+		case m.selected[table.Name]:
+		case m.options.Suggested[table.Name]:
+			label = "  Suggested: "
+		default:
+			label = "  Note: "
+		}
+		b.WriteString(m.fit(label+reason) + "\n")
+	}
+
+	if m.previewing {
+		return b.String() + m.previewView(table)
+	}
+	if !m.expanded {
+		return b.String()
+	}
+
+	switch {
+	case m.loading == table.Name:
+		fmt.Fprintf(&b, "  %s Loading columns%s\n", sym.Spinner[m.frame%len(sym.Spinner)], sym.Ellipsis)
+	case m.colErrs[table.Name] != nil:
+		b.WriteString(m.fit(fmt.Sprintf("  %s %v", sym.Failure, m.colErrs[table.Name])) + "\n")
+	default:
+		if columns, ok := m.columns[table.Name]; ok {
+			for _, col := range columns {
+				b.WriteString(m.fit("    "+PadRight(col.Name, 30)+" "+columnType(col)) + "\n")
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// fit truncates a line to the terminal width
+func (m TableSelectionModel) fit(line string) string {
+	if m.width <= 0 {
+		return line
+	}
+	return Truncate(line, m.width-1)
+}
+
 // GetSelected returns the list of selected table names
 func (m TableSelectionModel) GetSelected() []string {
 	var selected []string
@@ -119,16 +977,55 @@ func (m TableSelectionModel) GetSelected() []string {
 	return selected
 }
 
-// RunInteractiveSelection runs the interactive table selection
-func RunInteractiveSelection(tables []database.TableInfo, preSelected []string) ([]string, error) {
-	model := NewTableSelectionModel(tables, preSelected)
+// RunInteractiveSelection runs the interactive table selection; the program
+// quits when ctx is cancelled
+func RunInteractiveSelection(ctx context.Context, tables []database.TableInfo, preSelected []string, options SelectionOptions) ([]string, error) {
+	if options.Load != nil {
+		tables, preSelected = nil, nil
+	}
+	model := NewTableSelectionModel(tables, preSelected, options)
+	model.parent = ctx
 
-	p := tea.NewProgram(model)
-	finalModel, err := p.Run()
+	p := tea.NewProgram(model, tea.WithContext(ctx))
+	var finalModel tea.Model
+	var err error
+	if options.Load != nil {
+		finalModel, err = runLoading(ctx, p, options.Load)
+	} else {
+		finalModel, err = p.Run()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to run interactive selection: %w", err)
 	}
 
 	m := finalModel.(TableSelectionModel)
+	switch {
+	case m.loadErr != nil:
+		return nil, m.loadErr
+	case m.aborted:
+		return nil, errSelectionAborted
+	}
 	return m.GetSelected(), nil
 }
+
+// columnType describes a column's type, marking invisible and generated columns
+func columnType(col database.ColumnInfo) string {
+	text := col.Type
+	if col.Generated != "" {
+		text += fmt.Sprintf(" %s AS (%s)", strings.ToUpper(col.Generated), col.Expression)
+	}
+	if col.Invisible {
+		text += " INVISIBLE"
+	}
+	return text
+}
+
+// caseTwins maps each table to the others whose names differ from it only
+// by case
+func caseTwins(tables []database.TableInfo) map[string][]string {
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+	}
+	return sqlident.Twins(names)
+}
@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+// errSelectionAborted is returned when the selector is left with Q or
+// Ctrl+C instead of confirming
+var errSelectionAborted = fmt.Errorf("table selection aborted: %w", context.Canceled)
+
+// TableUpdate is what SelectionOptions.Load knows about the tables so far
+type TableUpdate struct {
+	Tables      []database.TableInfo
+	PreSelected []string
+	Suggested   []string // marked as likely to exclude, but not selected
+	Reasons     map[string]string
+	SampleRows  map[string]int
+
+	// Status, if set, replaces the progress line while reading
+	Status string
+
+	// Final marks the last update: sizes and every rule are applied
+	Final bool
+
+	// Reconcile, on the final update, asks which of two disagreeing
+	// selection sources to follow before the list is shown
+	Reconcile *SelectionReconcile
+}
+
+// tableUpdateMsg delivers a TableUpdate to the model
+type tableUpdateMsg TableUpdate
+
+// loadFailedMsg ends the selector when reading the tables fails
+type loadFailedMsg struct {
+	err error
+}
+
+// applyUpdate replaces the tables with newer information. Tables the user
+// toggled keep their state; the others follow the update's pre-selection.
+func (m *TableSelectionModel) applyUpdate(update TableUpdate) {
+	current := ""
+	if row, ok := m.currentRow(); ok && row.table >= 0 {
+		current = m.tables[row.table].Name
+	}
+
+	preSelected := make(map[string]bool, len(update.PreSelected))
+	for _, table := range update.PreSelected {
+		preSelected[table] = true
+	}
+	selected := make(map[string]bool, len(update.Tables))
+	for _, table := range update.Tables {
+		if m.touched[table.Name] {
+			selected[table.Name] = m.selected[table.Name]
+		} else {
+			selected[table.Name] = preSelected[table.Name]
+		}
+	}
+
+	m.tables = update.Tables
+	m.twins = caseTwins(m.tables)
+	m.selected = selected
+	m.options.Reasons = update.Reasons
+	m.options.Suggested = make(map[string]bool, len(update.Suggested))
+	for _, table := range update.Suggested {
+		m.options.Suggested[table] = true
+	}
+	m.options.SampleRows = update.SampleRows
+	m.status = update.Status
+	m.analyzed = false
+	for _, table := range m.tables {
+		m.analyzed = m.analyzed || table.Analyzed
+	}
+	m.groups = nil
+	if m.grouped {
+		m.groups = groupTables(m.tables, m.options.GroupDelimiter)
+		for _, group := range m.groups {
+			if _, ok := m.collapsed[group.prefix]; !ok && group.prefix != "" {
+				m.collapsed[group.prefix] = true
+			}
+		}
+	}
+	m.buildRows()
+
+	for i, row := range m.rows {
+		if row.table >= 0 && m.tables[row.table].Name == current {
+			m.cursor = i
+			break
+		}
+	}
+}
+
+// runLoading runs the selector while load reads the tables, holding back
+// other output until both are done. A failed load ends the selector with
+// the error in the model's loadErr.
+func runLoading(ctx context.Context, p *tea.Program, load func(context.Context, func(TableUpdate)) error) (tea.Model, error) {
+	release := holdOutput()
+	defer release()
+
+	loadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	loaded := make(chan struct{})
+	go func() {
+		err := load(loadCtx, func(update TableUpdate) {
+			p.Send(tableUpdateMsg(update))
+		})
+		if err != nil {
+			p.Send(loadFailedMsg{err: err})
+		}
+		close(loaded)
+	}()
+
+	finalModel, err := p.Run()
+	cancel()
+	<-loaded
+	return finalModel, err
+}
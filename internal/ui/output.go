@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// heldOutput collects messages printed while a full-screen program owns the
+// terminal, so they appear after it exits instead of tearing its view
+var heldOutput struct {
+	mu     sync.Mutex
+	active bool
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+// consoleWriter writes to stdout or stderr, or holds the output back
+type consoleWriter struct {
+	stderr bool
+}
+
+func (w consoleWriter) Write(p []byte) (int, error) {
+	heldOutput.mu.Lock()
+	defer heldOutput.mu.Unlock()
+
+	switch {
+	case heldOutput.active && w.stderr:
+		return heldOutput.stderr.Write(p)
+	case heldOutput.active:
+		return heldOutput.stdout.Write(p)
+	case w.stderr:
+		return os.Stderr.Write(p)
+	}
+	return os.Stdout.Write(p)
+}
+
+// console is where the Print* functions write
+var console io.Writer = consoleWriter{}
+
+// holdOutput holds back messages and warnings until release is called,
+// which prints them in one go
+func holdOutput() (release func()) {
+	heldOutput.mu.Lock()
+	heldOutput.active = true
+	heldOutput.mu.Unlock()
+
+	warnings := diag.Output
+	diag.Output = consoleWriter{stderr: true}
+
+	return func() {
+		diag.Output = warnings
+
+		heldOutput.mu.Lock()
+		defer heldOutput.mu.Unlock()
+		heldOutput.active = false
+		_, _ = heldOutput.stdout.WriteTo(os.Stdout)
+		_, _ = heldOutput.stderr.WriteTo(os.Stderr)
+	}
+}
@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+// previewHeight is how many lines of a row preview are shown at once
+const previewHeight = 12
+
+// previewValueWidth is how much of a value a row preview shows
+const previewValueWidth = 60
+
+// rowsMsg delivers the result of a row preview fetch
+type rowsMsg struct {
+	table   string
+	preview *database.RowPreview
+	err     error
+}
+
+// fetchRows starts loading the first rows of the highlighted table unless cached
+func (m *TableSelectionModel) fetchRows() tea.Cmd {
+	row, ok := m.currentRow()
+	if m.options.FetchRows == nil || !ok || row.table < 0 {
+		return nil
+	}
+	table := m.tables[row.table].Name
+	if _, ok := m.previews[table]; ok {
+		return nil
+	}
+	delete(m.previewErrs, table)
+
+	parent := m.parent
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	m.cancel = cancel
+	m.loading = table
+
+	fetch := m.options.FetchRows
+	return tea.Batch(func() tea.Msg {
+		preview, err := fetch(ctx, table)
+		return rowsMsg{table: table, preview: preview, err: err}
+	}, spinnerTick())
+}
+
+// scrollPreview moves the row preview by lines, within its length
+func (m *TableSelectionModel) scrollPreview(lines int) {
+	row, ok := m.currentRow()
+	if !ok || row.table < 0 {
+		return
+	}
+	preview, ok := m.previews[m.tables[row.table].Name]
+	if !ok {
+		return
+	}
+	last := max(0, len(previewLines(preview, m.valueWidth()))-previewHeight)
+	m.previewOffset = max(0, min(m.previewOffset+lines, last))
+}
+
+// previewView renders the row preview of the highlighted table
+func (m TableSelectionModel) previewView(table database.TableInfo) string {
+	sym := Sym()
+	var b strings.Builder
+	switch {
+	case m.options.FetchRows == nil:
+		b.WriteString(m.fit("  Row preview is not available") + "\n")
+	case m.loading == table.Name:
+		fmt.Fprintf(&b, "  %s Loading rows%s\n", sym.Spinner[m.frame%len(sym.Spinner)], sym.Ellipsis)
+	case m.previewErrs[table.Name] != nil:
+		b.WriteString(m.fit(fmt.Sprintf("  %s %v", sym.Failure, m.previewErrs[table.Name])) + "\n")
+	default:
+		preview, ok := m.previews[table.Name]
+		if !ok {
+			break
+		}
+		if len(preview.Rows) == 0 {
+			b.WriteString("  No rows\n")
+			break
+		}
+		lines := previewLines(preview, m.valueWidth())
+		offset := max(0, min(m.previewOffset, len(lines)-previewHeight))
+		end := min(len(lines), offset+previewHeight)
+		if offset > 0 {
+			fmt.Fprintf(&b, "    %s %d more lines above (PGUP)\n", sym.Ellipsis, offset)
+		}
+		for _, line := range lines[offset:end] {
+			b.WriteString(m.fit(line) + "\n")
+		}
+		if end < len(lines) {
+			fmt.Fprintf(&b, "    %s %d more lines below (PGDN)\n", sym.Ellipsis, len(lines)-end)
+		}
+	}
+	return b.String()
+}
+
+// valueWidth is how much of each preview value fits next to its column name
+func (m TableSelectionModel) valueWidth() int {
+	if m.width <= 0 {
+		return previewValueWidth
+	}
+	return max(10, min(previewValueWidth, m.width-36))
+}
+
+// previewLines lays out a row preview one column per line, each row under
+// its number, as the mysql client's \G does
+func previewLines(preview *database.RowPreview, width int) []string {
+	var lines []string
+	for r, row := range preview.Rows {
+		lines = append(lines, fmt.Sprintf("  Row %d of %d", r+1, len(preview.Rows)))
+		for c, value := range row {
+			lines = append(lines, "    "+PadRight(Truncate(preview.Columns[c], 30), 30)+" "+formatValue(value, preview.Binary[c], width))
+		}
+	}
+	return lines
+}
+
+// formatValue renders a previewed value on one line of at most width
+// columns: NULL, binary values by their size, times as MySQL writes them
+// and text with control characters replaced
+func formatValue(value any, binary bool, width int) string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		if v.IsZero() {
+			return "0000-00-00 00:00:00"
+		}
+		text = v.Format("2006-01-02 15:04:05.999999")
+	case []byte:
+		if binary || !utf8.Valid(v) {
+			return fmt.Sprintf("<binary, %d bytes>", len(v))
+		}
+		text = string(v)
+	default:
+		text = fmt.Sprint(v)
+	}
+	text = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, text)
+	return Truncate(text, width)
+}
@@ -0,0 +1,138 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  any
+		binary bool
+		width  int
+		want   string
+	}{
+		{name: "NULL", value: nil, width: 20, want: "NULL"},
+		{name: "text", value: []byte("hello"), width: 20, want: "hello"},
+		{name: "empty text", value: []byte{}, width: 20, want: ""},
+		{name: "long text", value: []byte("a rather long description"), width: 10, want: "a rather …"},
+		{name: "control characters", value: []byte("line one\nline\ttwo\x7f"), width: 40, want: "line one line two "},
+		{name: "binary column", value: []byte("PNG"), binary: true, width: 20, want: "<binary, 3 bytes>"},
+		{name: "invalid UTF-8", value: []byte{0xff, 0xfe, 0x00}, width: 20, want: "<binary, 3 bytes>"},
+		{name: "binary wider than the column", value: make([]byte, 1<<20), binary: true, width: 5, want: "<binary, 1048576 bytes>"},
+		{name: "time", value: time.Date(2024, 3, 1, 10, 4, 5, 250000000, time.UTC), width: 40, want: "2024-03-01 10:04:05.25"},
+		{name: "zero time", value: time.Time{}, width: 40, want: "0000-00-00 00:00:00"},
+		{name: "number", value: int64(42), width: 40, want: "42"},
+		{name: "wide runes", value: []byte("注文履歴テーブル"), width: 7, want: "注文履…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTerminal(t, Terminal{Unicode: true})
+			if got := formatValue(tt.value, tt.binary, tt.width); got != tt.want {
+				t.Errorf("formatValue(%v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewLines(t *testing.T) {
+	withTerminal(t, Terminal{Unicode: true})
+	preview := &database.RowPreview{
+		Columns: []string{"id", "avatar", "a_column_name_far_longer_than_thirty_characters"},
+		Binary:  []bool{false, true, false},
+		Rows: [][]any{
+			{int64(1), []byte{1, 2, 3, 4}, []byte("first")},
+			{int64(2), nil, []byte("second row with a long value")},
+		},
+	}
+	want := []string{
+		"  Row 1 of 2",
+		"    id                             1",
+		"    avatar                         <binary, 4 bytes>",
+		"    a_column_name_far_longer_than… first",
+		"  Row 2 of 2",
+		"    id                             2",
+		"    avatar                         NULL",
+		"    a_column_name_far_longer_than… second row…",
+	}
+	if got := previewLines(preview, 11); !reflect.DeepEqual(got, want) {
+		t.Errorf("previewLines() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// fetched runs the fetch of a command returned while opening a preview,
+// leaving out the spinner
+func fetched(t *testing.T, cmd tea.Cmd) tea.Msg {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("no fetch started")
+	}
+	batch, ok := cmd().(tea.BatchMsg)
+	if !ok || len(batch) == 0 {
+		t.Fatalf("fetch command returned %T", cmd())
+	}
+	return batch[0]()
+}
+
+// TestSelectorPreview opens the row preview, checks that a failed read
+// shows in place without changing the selection and that previews are
+// read once per table
+func TestSelectorPreview(t *testing.T) {
+	withTerminal(t, Terminal{Unicode: true})
+	reads := map[string]int{}
+	options := SelectionOptions{
+		FetchRows: func(_ context.Context, table string) (*database.RowPreview, error) {
+			reads[table]++
+			if table == "secrets" {
+				return nil, errors.New("SELECT command denied to user 'dump'@'%' for table 'secrets'")
+			}
+			return &database.RowPreview{Columns: []string{"id"}, Binary: []bool{false}, Rows: [][]any{{int64(7)}}}, nil
+		},
+	}
+	tables := []database.TableInfo{{Name: "users", TotalSize: 2}, {Name: "secrets", TotalSize: 1}}
+	m := NewTableSelectionModel(tables, []string{"users"}, options)
+
+	m, cmd := press(t, m, "v")
+	m, _ = m.update(fetched(t, cmd))
+	if view := m.View(); !strings.Contains(view, "Row 1 of 1") || !strings.Contains(view, "7") {
+		t.Errorf("preview of users not shown:\n%s", view)
+	}
+
+	m, cmd = m.update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.update(fetched(t, cmd))
+	if view := m.View(); !strings.Contains(view, "SELECT command denied") {
+		t.Errorf("error for secrets not shown:\n%s", view)
+	}
+	if got := excluded(m); !reflect.DeepEqual(got, []string{"users"}) {
+		t.Errorf("selection changed to %v", got)
+	}
+
+	// Back to a table previewed before: no second read
+	m, cmd = m.update(tea.KeyMsg{Type: tea.KeyUp})
+	if cmd != nil {
+		t.Errorf("cached preview read again")
+	}
+	if view := m.View(); !strings.Contains(view, "Row 1 of 1") {
+		t.Errorf("cached preview of users not shown:\n%s", view)
+	}
+	if want := map[string]int{"users": 1, "secrets": 1}; !reflect.DeepEqual(reads, want) {
+		t.Errorf("reads = %v, want %v", reads, want)
+	}
+
+	// Closing the preview leaves the selection alone
+	m, _ = press(t, m, "v")
+	if strings.Contains(m.View(), "Row 1 of 1") {
+		t.Errorf("preview still shown after closing it")
+	}
+	if got := excluded(m); !reflect.DeepEqual(got, []string{"users"}) {
+		t.Errorf("selection changed to %v", got)
+	}
+}
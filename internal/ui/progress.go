@@ -7,26 +7,44 @@ import (
 	"github.com/schollz/progressbar/v3"
 )
 
-// ProgressTracker tracks progress during dump operations
+// ProgressTracker tracks progress during dump operations. It draws a bar,
+// or prints plain lines when the progress mode is plain.
 type ProgressTracker struct {
-	bar *progressbar.ProgressBar
+	bar   *progressbar.ProgressBar
+	plain *plainProgress
+}
+
+// themeOptions returns progress bar options matching the terminal's capabilities
+func themeOptions() []progressbar.Option {
+	if Term().Unicode {
+		return []progressbar.Option{progressbar.OptionSpinnerType(14)}
+	}
+	return []progressbar.Option{
+		progressbar.OptionSetTheme(progressbar.ThemeASCII),
+		progressbar.OptionSpinnerType(9),
+	}
 }
 
 // NewProgressTracker creates a new progress tracker
 func NewProgressTracker(description string, max int64) *ProgressTracker {
+	if CurrentProgressMode() == ProgressPlain {
+		return &ProgressTracker{plain: newPlainProgress(description, max, true)}
+	}
+
 	bar := progressbar.NewOptions64(
 		max,
-		progressbar.OptionSetDescription(description),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionSetWidth(40),
-		progressbar.OptionThrottle(100*time.Millisecond),
-		progressbar.OptionShowCount(),
-		progressbar.OptionOnCompletion(func() {
-			fmt.Println()
-		}),
-		progressbar.OptionSpinnerType(14),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetRenderBlankState(true),
+		append([]progressbar.Option{
+			progressbar.OptionSetDescription(description),
+			progressbar.OptionShowBytes(true),
+			progressbar.OptionSetWidth(40),
+			progressbar.OptionThrottle(100 * time.Millisecond),
+			progressbar.OptionShowCount(),
+			progressbar.OptionOnCompletion(func() {
+				fmt.Println()
+			}),
+			progressbar.OptionFullWidth(),
+			progressbar.OptionSetRenderBlankState(true),
+		}, themeOptions()...)...,
 	)
 
 	return &ProgressTracker{bar: bar}
@@ -34,18 +52,23 @@ func NewProgressTracker(description string, max int64) *ProgressTracker {
 
 // NewSimpleProgress creates a simple progress bar without byte display
 func NewSimpleProgress(description string, max int) *ProgressTracker {
+	if CurrentProgressMode() == ProgressPlain {
+		return &ProgressTracker{plain: newPlainProgress(description, int64(max), false)}
+	}
+
 	bar := progressbar.NewOptions(
 		max,
-		progressbar.OptionSetDescription(description),
-		progressbar.OptionSetWidth(40),
-		progressbar.OptionThrottle(100*time.Millisecond),
-		progressbar.OptionShowCount(),
-		progressbar.OptionOnCompletion(func() {
-			fmt.Println()
-		}),
-		progressbar.OptionSpinnerType(14),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetRenderBlankState(true),
+		append([]progressbar.Option{
+			progressbar.OptionSetDescription(description),
+			progressbar.OptionSetWidth(40),
+			progressbar.OptionThrottle(100 * time.Millisecond),
+			progressbar.OptionShowCount(),
+			progressbar.OptionOnCompletion(func() {
+				fmt.Println()
+			}),
+			progressbar.OptionFullWidth(),
+			progressbar.OptionSetRenderBlankState(true),
+		}, themeOptions()...)...,
 	)
 
 	return &ProgressTracker{bar: bar}
@@ -53,46 +76,98 @@ func NewSimpleProgress(description string, max int) *ProgressTracker {
 
 // Add increments the progress bar
 func (p *ProgressTracker) Add(n int) error {
+	if p.plain != nil {
+		p.plain.add(int64(n))
+		return nil
+	}
 	return p.bar.Add(n)
 }
 
 // Add64 increments the progress bar with int64
 func (p *ProgressTracker) Add64(n int64) error {
+	if p.plain != nil {
+		p.plain.add(n)
+		return nil
+	}
 	return p.bar.Add64(n)
 }
 
+// Describe changes the description shown next to the progress bar
+func (p *ProgressTracker) Describe(description string) {
+	if p.plain != nil {
+		p.plain.describe(description)
+		return
+	}
+	p.bar.Describe(description)
+}
+
+// Set64 moves the progress bar to an absolute value
+func (p *ProgressTracker) Set64(n int64) error {
+	if p.plain != nil {
+		p.plain.set(n)
+		return nil
+	}
+	return p.bar.Set64(n)
+}
+
 // Finish completes the progress bar
 func (p *ProgressTracker) Finish() error {
+	if p.plain != nil {
+		p.plain.finish()
+		return nil
+	}
 	return p.bar.Finish()
 }
 
 // Clear clears the progress bar from the screen
 func (p *ProgressTracker) Clear() error {
+	if p.plain != nil {
+		return nil
+	}
 	return p.bar.Clear()
 }
 
 // PrintSummary prints a summary after the dump
-func PrintSummary(outputFile string, excludedCount int, duration time.Duration, fileSize string) {
+func PrintSummary(outputFile string, excludedCount int, duration time.Duration, fileSize, tags string) {
 	fmt.Println()
-	fmt.Printf("✓ Dump complete: %s (%s)\n", outputFile, fileSize)
+	if tags != "" {
+		PrintSuccess(fmt.Sprintf("Dump complete: %s (%s) [%s]", outputFile, fileSize, tags))
+	} else {
+		PrintSuccess(fmt.Sprintf("Dump complete: %s (%s)", outputFile, fileSize))
+	}
 	if excludedCount > 0 {
-		fmt.Printf("✓ Excluded %d table(s) (data only, structure preserved)\n", excludedCount)
+		PrintSuccess(fmt.Sprintf("Excluded %d table(s) (data only, structure preserved)", excludedCount))
 	}
-	fmt.Printf("✓ Duration: %s\n", duration.Round(time.Second))
+	PrintSuccess(fmt.Sprintf("Duration: %s", duration.Round(time.Second)))
 	fmt.Println()
 }
 
 // PrintError prints an error message
 func PrintError(err error) {
-	fmt.Printf("\n✗ Error: %s\n\n", err)
+	fmt.Fprintf(console, "\n%s Error: %s\n\n", colorize(colorRed, Sym().Failure), err)
+}
+
+// PrintFailure prints a failed check
+func PrintFailure(message string) {
+	fmt.Fprintf(console, "%s %s\n", FailureMark(), message)
+}
+
+// SuccessMark returns the (colored) symbol for a passed check
+func SuccessMark() string {
+	return colorize(colorGreen, Sym().Success)
+}
+
+// FailureMark returns the (colored) symbol for a failed check
+func FailureMark() string {
+	return colorize(colorRed, Sym().Failure)
 }
 
 // PrintInfo prints an informational message
 func PrintInfo(message string) {
-	fmt.Printf("ℹ %s\n", message)
+	fmt.Fprintf(console, "%s %s\n", colorize(colorCyan, Sym().Info), message)
 }
 
 // PrintSuccess prints a success message
 func PrintSuccess(message string) {
-	fmt.Printf("✓ %s\n", message)
+	fmt.Fprintf(console, "%s %s\n", SuccessMark(), message)
 }
@@ -0,0 +1,156 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+// ProgressMode selects how progress is shown
+type ProgressMode string
+
+const (
+	ProgressAuto  ProgressMode = "auto"  // bars on a terminal, plain lines otherwise
+	ProgressBar   ProgressMode = "bar"   // redrawn progress bars
+	ProgressPlain ProgressMode = "plain" // one line per update, safe to interleave and log
+	ProgressNone  ProgressMode = "none"  // no progress output
+)
+
+var (
+	progressMode     = ProgressAuto
+	progressInterval = 5 * time.Second
+	progressLabel    string
+
+	// ProgressOutput is where plain progress lines are written
+	ProgressOutput io.Writer = os.Stderr
+)
+
+// ParseProgressMode validates a --progress value
+func ParseProgressMode(s string) (ProgressMode, error) {
+	switch mode := ProgressMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case ProgressAuto, ProgressBar, ProgressPlain, ProgressNone:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown progress mode %q (use auto, bar, plain or none)", s)
+}
+
+// SetProgressMode configures progress output. In plain mode each operation
+// prints at most one line per interval, prefixed with label (typically the
+// database name) so lines from concurrent runs can be told apart. An interval
+// of 0 prints every update.
+func SetProgressMode(mode ProgressMode, interval time.Duration, label string) {
+	progressMode = mode
+	progressInterval = interval
+	progressLabel = label
+}
+
+// CurrentProgressMode returns the progress mode, with auto resolved to bars
+// when stdout is a terminal and plain lines otherwise
+func CurrentProgressMode() ProgressMode {
+	if progressMode != ProgressAuto {
+		return progressMode
+	}
+	if Term().TTY {
+		return ProgressBar
+	}
+	return ProgressPlain
+}
+
+// plainProgress prints progress as throttled single lines, as in
+// "mydb: 42% (1.2 GB/2.9 GB) Restoring wp_posts"
+type plainProgress struct {
+	mu          sync.Mutex
+	label       string
+	description string
+	max         int64
+	current     int64
+	bytes       bool
+	interval    time.Duration
+	printed     time.Time
+	finished    bool
+}
+
+// newPlainProgress creates a plain progress printer for the current settings
+func newPlainProgress(description string, max int64, bytes bool) *plainProgress {
+	return &plainProgress{
+		label:       progressLabel,
+		description: description,
+		max:         max,
+		bytes:       bytes,
+		interval:    progressInterval,
+	}
+}
+
+// set records a new value and prints a line when the interval has passed
+func (p *plainProgress) set(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = n
+	if time.Since(p.printed) >= p.interval {
+		p.print()
+	}
+}
+
+// add advances the value
+func (p *plainProgress) add(n int64) {
+	p.mu.Lock()
+	current := p.current + n
+	p.mu.Unlock()
+	p.set(current)
+}
+
+// describe changes the description used in the next line
+func (p *plainProgress) describe(description string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.description = description
+}
+
+// finish prints the final state once, so the last line shows where the operation ended
+func (p *plainProgress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+	p.finished = true
+	p.print()
+}
+
+// print writes one line; the caller holds the lock
+func (p *plainProgress) print() {
+	p.printed = time.Now()
+
+	var line strings.Builder
+	if p.label != "" {
+		line.WriteString(p.label + ": ")
+	} else if p.description != "" {
+		line.WriteString(p.description + ": ")
+	}
+	if p.max > 0 {
+		fmt.Fprintf(&line, "%d%% ", p.current*100/p.max)
+		fmt.Fprintf(&line, "(%s/%s)", p.format(p.current), p.format(p.max))
+	} else {
+		line.WriteString(p.format(p.current))
+	}
+	if p.label != "" && p.description != "" {
+		line.WriteString(" " + p.description)
+	}
+	line.WriteString("\n")
+
+	// One write per line keeps lines whole when several processes share the terminal
+	_, _ = io.WriteString(ProgressOutput, line.String())
+}
+
+// format renders a progress value
+func (p *plainProgress) format(n int64) string {
+	if p.bytes {
+		return database.FormatBytes(n)
+	}
+	return fmt.Sprintf("%d", n)
+}
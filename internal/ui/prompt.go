@@ -0,0 +1,41 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"golang.org/x/term"
+)
+
+// IsInteractive reports whether stdin is a terminal that can answer prompts
+func IsInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// PrintWarning prints a warning message and records it for the exit summary
+func PrintWarning(message string) {
+	diag.Record(message)
+	fmt.Fprintf(console, "%s %s\n", colorize(colorYellow, Sym().Warning), message)
+}
+
+// PrintWarningSummary lists each distinct warning once, with a count for
+// warnings that were raised more than once
+func PrintWarningSummary(warnings []diag.Warning) {
+	if len(warnings) == 0 {
+		return
+	}
+	noun := "warnings"
+	if len(warnings) == 1 {
+		noun = "warning"
+	}
+	fmt.Println()
+	fmt.Printf("%s Completed with %d %s:\n", colorize(colorYellow, Sym().Warning), len(warnings), noun)
+	for _, warning := range warnings {
+		if warning.Count > 1 {
+			fmt.Printf("  - %s (x%d)\n", warning.Message, warning.Count)
+		} else {
+			fmt.Printf("  - %s\n", warning.Message)
+		}
+	}
+}
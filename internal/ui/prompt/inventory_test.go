@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// stdinReader matches code that reads answers from the terminal: the ways
+// a prompt can be written without this package
+var stdinReader = regexp.MustCompile(`os\.Stdin|fmt\.Scan|fmt\.Fscan|\[y/N\]|\[Y/n\]|term\.ReadPassword`)
+
+// stdinUses are the places outside this package allowed to touch stdin,
+// none of which asks a question
+var stdinUses = map[string]string{
+	"internal/ui/prompt.go":     "checks whether stdin is a terminal",
+	"internal/config/config.go": "reads a config given as -c - from stdin",
+}
+
+// TestNoPromptsOutsidePackage fails when code outside this package reads
+// from stdin, so a new prompt can't bypass --yes and --assume
+func TestNoPromptsOutsidePackage(t *testing.T) {
+	root := filepath.Join("..", "..", "..")
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		t.Fatalf("module root not found: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			if rel == "internal/ui/prompt" || rel != "." && strings.HasPrefix(entry.Name(), ".") || entry.Name() == "testdata" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(rel, ".go") || strings.HasSuffix(rel, "_test.go") {
+			return nil
+		}
+
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for i, line := range strings.Split(string(source), "\n") {
+			if !stdinReader.MatchString(line) {
+				continue
+			}
+			if _, ok := stdinUses[rel]; ok {
+				seen[rel] = true
+				continue
+			}
+			t.Errorf("%s:%d reads from the terminal outside internal/ui/prompt: %s", rel, i+1, strings.TrimSpace(line))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep the allowlist honest
+	for rel := range stdinUses {
+		if !seen[rel] {
+			t.Errorf("%s is allowed to use stdin but no longer does; remove it from stdinUses", rel)
+		}
+	}
+}
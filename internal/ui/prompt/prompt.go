@@ -0,0 +1,172 @@
+// Package prompt asks every question dbdump puts to the user. Answers given
+// up front with --yes (the safe answers) or --assume are applied without
+// asking, and noted in the output; without them, a prompt fails when stdin
+// is not a terminal.
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+	"github.com/helgesverre/dbdump/internal/ui"
+)
+
+// Keys of the prompts, as given to --assume
+const (
+	PruneDelete       = "prune-delete"
+	StopReplica       = "stop-replica"
+	RestoreTables     = "restore-tables"
+	RestoreSameSource = "restore-same-source"
+	NewTables         = "new-tables"
+	ShowSecrets       = "show-secrets"
+	Gitignore         = "gitignore"
+	Reconcile         = "reconcile"
+)
+
+// Answers of yes/no prompts
+const (
+	Yes = "yes"
+	No  = "no"
+)
+
+// keys lists every prompt, for validating --assume
+var keys = []string{PruneDelete, StopReplica, RestoreTables, RestoreSameSource, NewTables, ShowSecrets, Gitignore, Reconcile}
+
+// spec is how a prompt can be answered up front
+type spec struct {
+	// answers are those --assume accepts
+	answers []string
+
+	// safe is the answer --yes gives. Prompts that delete data, replace
+	// it or stop replication have none: --yes leaves them to --assume or
+	// their own flag.
+	safe string
+}
+
+var yesNo = []string{Yes, No}
+
+// specs are the prompts by key. The answers of reconcile are the sides of
+// a selection conflict; its safe answer depends on the config, so callers
+// pass it to Assumed.
+var specs = map[string]spec{
+	PruneDelete:       {answers: yesNo},
+	StopReplica:       {answers: yesNo},
+	RestoreTables:     {answers: yesNo},
+	RestoreSameSource: {answers: yesNo},
+	NewTables:         {answers: yesNo, safe: Yes},
+	ShowSecrets:       {answers: yesNo, safe: No},
+	Gitignore:         {answers: yesNo, safe: Yes},
+	Reconcile:         {answers: []string{"config", "project-config", "saved"}},
+}
+
+// Answers given up front: --yes gives the safe answer to every prompt that
+// has one, and --assume answers single prompts, taking precedence
+var (
+	yes     bool
+	assumed = make(map[string]string)
+)
+
+// Keys returns the keys of all prompts
+func Keys() []string {
+	return slices.Clone(keys)
+}
+
+// Safe returns the answer --yes gives a prompt, or "" when --yes doesn't
+// answer it
+func Safe(key string) string {
+	return specs[key].safe
+}
+
+// Configure applies --yes and the key=value answers of --assume
+func Configure(assumeYes bool, assume []string) error {
+	yes = assumeYes
+	clear(assumed)
+
+	var problems []string
+	for _, value := range assume {
+		key, answer, ok := strings.Cut(value, "=")
+		spec, known := specs[key]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%q: expected key=answer", value))
+			continue
+		case !known:
+			problems = append(problems, fmt.Sprintf("%q: unknown prompt (known: %s)", key, strings.Join(keys, ", ")))
+			continue
+		}
+		answer = strings.ToLower(answer)
+		switch answer {
+		case "y":
+			answer = Yes
+		case "n":
+			answer = No
+		}
+		if !slices.Contains(spec.answers, answer) {
+			problems = append(problems, fmt.Sprintf("%q: answer %q is not one of %s", key, answer, strings.Join(spec.answers, ", ")))
+			continue
+		}
+		assumed[key] = answer
+	}
+	if len(problems) > 0 {
+		return &dberrors.ErrConfigInvalid{Source: "--assume", Problems: problems}
+	}
+	return nil
+}
+
+// Assumed returns the answer given up front to a prompt and prints it: the
+// one given with --assume, or safe with --yes. It returns false when the
+// prompt must be asked. Prompts asked by a screen of their own use it, or
+// Answer where nothing may be printed; Confirm asks the others.
+func Assumed(key, question, safe string) (string, bool) {
+	answer, flag, ok := Answer(key, safe)
+	if ok {
+		ui.PrintInfo(fmt.Sprintf("%s %s (%s)", question, answer, flag))
+	}
+	return answer, ok
+}
+
+// Answer is Assumed without printing: it returns the answer given up front
+// and the flag that gave it, for prompts asked while a screen is drawn
+// (the reconciliation in the table selector)
+func Answer(key, safe string) (answer, flag string, ok bool) {
+	if answer, ok := assumed[key]; ok {
+		return answer, "--assume " + key, true
+	}
+	if yes && safe != "" {
+		return safe, "--yes", true
+	}
+	return "", "", false
+}
+
+// Confirm asks a yes/no question and returns true only for an explicit yes.
+// An answer given with --assume, or the prompt's safe answer with --yes, is
+// printed and used instead. --yes doesn't answer prompts without a safe
+// answer, and a stdin that is not a terminal is an error; both name the
+// --assume answer to pass.
+func Confirm(key, question string) (bool, error) {
+	if answer, ok := Assumed(key, question, Safe(key)); ok {
+		return answer == Yes, nil
+	}
+	if yes {
+		return false, fmt.Errorf("--yes doesn't answer %q, which can't be undone: answer it with --assume %s=yes", question, key)
+	}
+	if !ui.IsInteractive() {
+		return false, fmt.Errorf("cannot ask %q: stdin is not a terminal (answer with --assume %s=yes|no)", question, key)
+	}
+
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("failed to read answer: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
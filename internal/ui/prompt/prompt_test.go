@@ -0,0 +1,144 @@
+package prompt
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/dberrors"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		name    string
+		assume  []string
+		want    map[string]string
+		problem string
+	}{
+		{name: "none", want: map[string]string{}},
+		{name: "yes and no", assume: []string{"prune-delete=yes", "gitignore=N"}, want: map[string]string{PruneDelete: Yes, Gitignore: No}},
+		{name: "short yes", assume: []string{"stop-replica=y"}, want: map[string]string{StopReplica: Yes}},
+		{name: "reconcile side", assume: []string{"reconcile=saved"}, want: map[string]string{Reconcile: "saved"}},
+		{name: "reconcile project config", assume: []string{"reconcile=project-config"}, want: map[string]string{Reconcile: "project-config"}},
+		{name: "last wins", assume: []string{"gitignore=yes", "gitignore=no"}, want: map[string]string{Gitignore: No}},
+		{name: "missing answer", assume: []string{"gitignore"}, problem: "expected key=answer"},
+		{name: "unknown key", assume: []string{"overwrite=yes"}, problem: "unknown prompt"},
+		{name: "bad yes/no answer", assume: []string{"prune-delete=maybe"}, problem: `answer "maybe" is not one of yes, no`},
+		{name: "reconcile takes sides, not yes", assume: []string{"reconcile=yes"}, problem: "not one of config, project-config, saved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Configure(false, tt.assume)
+			if tt.problem != "" {
+				var invalid *dberrors.ErrConfigInvalid
+				if !errors.As(err, &invalid) {
+					t.Fatalf("Configure(%q) = %v, want ErrConfigInvalid", tt.assume, err)
+				}
+				if !strings.Contains(strings.Join(invalid.Problems, "\n"), tt.problem) {
+					t.Fatalf("problems %q don't mention %q", invalid.Problems, tt.problem)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Configure(%q) = %v", tt.assume, err)
+			}
+			if len(assumed) != len(tt.want) {
+				t.Fatalf("assumed = %v, want %v", assumed, tt.want)
+			}
+			for key, answer := range tt.want {
+				if assumed[key] != answer {
+					t.Errorf("assumed[%s] = %q, want %q", key, assumed[key], answer)
+				}
+			}
+		})
+	}
+}
+
+func TestConfirmWithYes(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    bool
+		refused bool
+	}{
+		{key: PruneDelete, refused: true},
+		{key: StopReplica, refused: true},
+		{key: RestoreTables, refused: true},
+		{key: RestoreSameSource, refused: true},
+		{key: NewTables, want: true},
+		{key: ShowSecrets, want: false},
+		{key: Gitignore, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if err := Configure(true, nil); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Confirm(tt.key, "Go on?")
+			if tt.refused {
+				if err == nil || got {
+					t.Fatalf("Confirm(%s) with --yes = %v, %v; want a refusal", tt.key, got, err)
+				}
+				if !strings.Contains(err.Error(), "--assume "+tt.key+"=yes") {
+					t.Errorf("refusal %q doesn't name --assume %s=yes", err, tt.key)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("Confirm(%s) with --yes = %v, %v; want %v", tt.key, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssumeWinsOverYes(t *testing.T) {
+	if err := Configure(true, []string{"prune-delete=yes", "gitignore=no"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Confirm(PruneDelete, "Delete?"); err != nil || !ok {
+		t.Errorf("Confirm(prune-delete) = %v, %v; want true from --assume", ok, err)
+	}
+	if ok, err := Confirm(Gitignore, "Add?"); err != nil || ok {
+		t.Errorf("Confirm(gitignore) = %v, %v; want false from --assume", ok, err)
+	}
+}
+
+func TestAssumed(t *testing.T) {
+	if err := Configure(false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if answer, ok := Assumed(Reconcile, "Follow?", "config"); ok {
+		t.Errorf("Assumed without flags = %q, want it to be asked", answer)
+	}
+
+	if err := Configure(true, nil); err != nil {
+		t.Fatal(err)
+	}
+	if answer, ok := Assumed(Reconcile, "Follow?", "saved"); !ok || answer != "saved" {
+		t.Errorf("Assumed with --yes = %q, %v; want the safe answer", answer, ok)
+	}
+	if answer, ok := Assumed(Reconcile, "Follow?", ""); ok {
+		t.Errorf("Assumed with --yes and no safe answer = %q, want it to be asked", answer)
+	}
+
+	if err := Configure(true, []string{"reconcile=config"}); err != nil {
+		t.Fatal(err)
+	}
+	if answer, ok := Assumed(Reconcile, "Follow?", "saved"); !ok || answer != "config" {
+		t.Errorf("Assumed with --assume = %q, %v; want config", answer, ok)
+	}
+}
+
+func TestEveryKeyHasASpec(t *testing.T) {
+	for _, key := range Keys() {
+		if len(specs[key].answers) == 0 {
+			t.Errorf("prompt %s accepts no answers", key)
+		}
+		if safe := specs[key].safe; safe != "" && !slices.Contains(specs[key].answers, safe) {
+			t.Errorf("safe answer %q of %s is not one it accepts", safe, key)
+		}
+	}
+	if len(specs) != len(Keys()) {
+		t.Errorf("%d specs for %d keys", len(specs), len(Keys()))
+	}
+}
@@ -0,0 +1,138 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/helgesverre/dbdump/internal/patterns"
+)
+
+// SelectionReconcile asks, in the table selector, which side to follow for
+// the tables two selection sources disagree about: one side wholesale, or
+// a pick per table
+type SelectionReconcile struct {
+	First, Second string // names of the sources
+	Conflicts     []patterns.SelectionConflict
+}
+
+// reconcileState is a reconciliation being answered; picking is the index
+// of the conflict asked about per table, or -1 while choosing a side
+type reconcileState struct {
+	SelectionReconcile
+	picking int
+	choices map[string]bool // table -> exclude data
+}
+
+// startReconcile shows the reconciliation before the list
+func (m *TableSelectionModel) startReconcile(reconcile *SelectionReconcile) {
+	if reconcile == nil || len(reconcile.Conflicts) == 0 {
+		return
+	}
+	m.reconcile = &reconcileState{
+		SelectionReconcile: *reconcile,
+		picking:            -1,
+		choices:            make(map[string]bool, len(reconcile.Conflicts)),
+	}
+}
+
+// reconcileKey answers the reconciliation: 1 or 2 follows a side, P picks
+// per table with E (exclude data) and D (dump data)
+func (m TableSelectionModel) reconcileKey(msg tea.KeyMsg) (TableSelectionModel, tea.Cmd) {
+	r := m.reconcile
+	switch key := strings.ToLower(msg.String()); {
+	case key == "ctrl+c" || key == "q":
+		m.aborted = true
+		return m, tea.Quit
+
+	case r.picking < 0 && (key == "1" || key == "2"):
+		winner := r.First
+		if key == "2" {
+			winner = r.Second
+		}
+		for _, conflict := range r.Conflicts {
+			r.choices[conflict.Table] = conflict.ExcludedBy == winner
+		}
+		return m.resolveReconcile()
+
+	case r.picking < 0 && key == "p":
+		r.picking = 0
+
+	case r.picking >= 0 && (key == "e" || key == "d"):
+		r.choices[r.Conflicts[r.picking].Table] = key == "e"
+		r.picking++
+		if r.picking == len(r.Conflicts) {
+			return m.resolveReconcile()
+		}
+	}
+	return m, nil
+}
+
+// resolveReconcile applies the choices as if the user had toggled the
+// tables, and goes on to the list, or quits when the user had already
+// confirmed. The reconciliation comes with the final update, so reading is
+// over by then.
+func (m TableSelectionModel) resolveReconcile() (TableSelectionModel, tea.Cmd) {
+	for table, exclude := range m.reconcile.choices {
+		m.selected[table] = exclude
+		m.touched[table] = true
+	}
+	m.reconcile = nil
+	if m.confirming {
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// reconcileView lists what each source wants for the tables they disagree
+// about and asks which to follow
+func (m TableSelectionModel) reconcileView() string {
+	r := m.reconcile
+	sym := Sym()
+
+	nameWidth := len("Table")
+	for _, conflict := range r.Conflicts {
+		nameWidth = max(nameWidth, DisplayWidth(conflict.Table))
+	}
+	nameWidth = min(nameWidth, max(12, LineWidth(100)-40))
+
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(m.fit(fmt.Sprintf("  %s The %s and the %s disagree about %d tables:", sym.Warning, r.First, r.Second, len(r.Conflicts))) + "\n\n")
+	b.WriteString(m.fit(fmt.Sprintf("    %s  %-16s %-16s", PadRight("Table", nameWidth), r.First, r.Second)) + "\n")
+	for i, conflict := range r.Conflicts {
+		cursor := " "
+		if i == r.picking {
+			cursor = ">"
+		}
+		line := fmt.Sprintf("  %s %s  %-16s %-16s", cursor, PadRight(Truncate(conflict.Table, nameWidth), nameWidth),
+			intent(conflict, r.First), intent(conflict, r.Second))
+		if exclude, ok := r.choices[conflict.Table]; ok {
+			chosen := "dump data"
+			if exclude {
+				chosen = "exclude data"
+			}
+			line += "  " + sym.Success + " " + chosen
+		}
+		b.WriteString(m.fit(line) + "\n")
+	}
+	b.WriteString("\n")
+
+	if r.picking < 0 {
+		b.WriteString(m.fit(fmt.Sprintf("  Follow the [1] %s, [2] %s, or [P]ick per table? (Q to cancel)", r.First, r.Second)) + "\n")
+	} else {
+		conflict := r.Conflicts[r.picking]
+		b.WriteString(m.fit(fmt.Sprintf("  %s: [E]xclude data (%s) or [D]ump data (%s)?", conflict.Table, conflict.ExcludedBy, conflict.DumpedBy)) + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// intent describes what a source wants for a conflicting table
+func intent(conflict patterns.SelectionConflict, source string) string {
+	if conflict.ExcludedBy == source {
+		return "exclude data"
+	}
+	return "dump data"
+}
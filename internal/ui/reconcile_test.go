@@ -0,0 +1,155 @@
+package ui
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/patterns"
+)
+
+// reconcileTables are the tables of the reconciliation tests: the config
+// excludes logs and cache, the saved selection sessions and cache
+var reconcileTables = []database.TableInfo{
+	{Name: "cache"}, {Name: "logs"}, {Name: "sessions"}, {Name: "users"},
+}
+
+func reconcileUpdate() TableUpdate {
+	return TableUpdate{
+		Tables:      reconcileTables,
+		PreSelected: []string{"cache", "logs"},
+		Final:       true,
+		Reconcile: &SelectionReconcile{
+			First:  "config",
+			Second: "saved selection",
+			Conflicts: patterns.DiffSelections([]string{"cache", "logs", "sessions", "users"},
+				patterns.SelectionSource{Name: "config", Excluded: []string{"cache", "logs"}},
+				patterns.SelectionSource{Name: "saved selection", Excluded: []string{"cache", "sessions"}}),
+		},
+	}
+}
+
+// loadingModel returns a selector still reading its tables
+func loadingModel() TableSelectionModel {
+	return NewTableSelectionModel(nil, nil, SelectionOptions{
+		Load: func(context.Context, func(TableUpdate)) error { return nil },
+	})
+}
+
+// press sends keys to the model one by one
+func press(t *testing.T, m TableSelectionModel, keys ...string) (TableSelectionModel, tea.Cmd) {
+	t.Helper()
+	var cmd tea.Cmd
+	for _, key := range keys {
+		m, cmd = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	}
+	return m, cmd
+}
+
+// excluded returns the tables selected in the model, sorted
+func excluded(m TableSelectionModel) []string {
+	var tables []string
+	for table, selected := range m.selected {
+		if selected {
+			tables = append(tables, table)
+		}
+	}
+	slices.Sort(tables)
+	return tables
+}
+
+func TestReconcileScreen(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want []string
+	}{
+		{name: "follow the config", keys: []string{"1"}, want: []string{"cache", "logs"}},
+		{name: "follow the saved selection", keys: []string{"2"}, want: []string{"cache", "sessions"}},
+		{name: "pick per table", keys: []string{"p", "e", "e"}, want: []string{"cache", "logs", "sessions"}},
+		{name: "pick per table, upper case", keys: []string{"P", "D", "D"}, want: []string{"cache"}},
+		{name: "unknown keys are ignored", keys: []string{"x", "e", "2"}, want: []string{"cache", "sessions"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := loadingModel().update(tableUpdateMsg(reconcileUpdate()))
+			if m.reconcile == nil {
+				t.Fatal("final update with conflicts didn't open the reconciliation")
+			}
+			if view := m.View(); !strings.Contains(view, "disagree about 2 tables") {
+				t.Errorf("View() doesn't show the reconciliation:\n%s", view)
+			}
+
+			m, cmd := press(t, m, tt.keys...)
+			if m.reconcile != nil {
+				t.Fatalf("reconciliation still open after %q", tt.keys)
+			}
+			if cmd != nil || m.done {
+				t.Error("answering the reconciliation left the selector; it should show the list")
+			}
+			if got := excluded(m); !slices.Equal(got, tt.want) {
+				t.Errorf("excluded = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileAfterConfirming(t *testing.T) {
+	m := loadingModel()
+	m, _ = press(t, m, "c")
+	if !m.confirming {
+		t.Fatal("confirming while reading didn't wait for the final update")
+	}
+
+	m, cmd := m.update(tableUpdateMsg(reconcileUpdate()))
+	if cmd != nil || m.done {
+		t.Fatal("the selector quit before the reconciliation was answered")
+	}
+	m, cmd = press(t, m, "2")
+	if cmd == nil || !m.done {
+		t.Fatal("answering after confirming didn't finish the selection")
+	}
+	if got, want := excluded(m), []string{"cache", "sessions"}; !slices.Equal(got, want) {
+		t.Errorf("excluded = %v, want %v", got, want)
+	}
+}
+
+func TestReconcileOverridesToggles(t *testing.T) {
+	m := loadingModel()
+	m, _ = m.update(tableUpdateMsg{Tables: reconcileTables, PreSelected: []string{"cache", "logs"}})
+	// Untick logs before the rules are final
+	m.cursor = slices.IndexFunc(m.rows, func(row listRow) bool {
+		return row.table >= 0 && m.tables[row.table].Name == "logs"
+	})
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeySpace})
+	if m.selected["logs"] || !m.touched["logs"] {
+		t.Fatal("space didn't untick logs")
+	}
+
+	m, _ = m.update(tableUpdateMsg(reconcileUpdate()))
+	m, _ = press(t, m, "1")
+	// The reconciliation is an explicit answer, so it wins over the toggle
+	if got, want := excluded(m), []string{"cache", "logs"}; !slices.Equal(got, want) {
+		t.Errorf("excluded = %v, want %v", got, want)
+	}
+}
+
+func TestReconcileQuit(t *testing.T) {
+	m, _ := loadingModel().update(tableUpdateMsg(reconcileUpdate()))
+	m, cmd := press(t, m, "q")
+	if !m.aborted || cmd == nil {
+		t.Error("q on the reconciliation didn't cancel the selection")
+	}
+}
+
+func TestNoReconcileWithoutConflicts(t *testing.T) {
+	update := reconcileUpdate()
+	update.Reconcile.Conflicts = nil
+	m, _ := loadingModel().update(tableUpdateMsg(update))
+	if m.reconcile != nil {
+		t.Error("a reconciliation without conflicts was opened")
+	}
+}
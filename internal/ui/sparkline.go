@@ -0,0 +1,48 @@
+package ui
+
+// sparkLevels are the bar glyphs of a sparkline, lowest first
+var (
+	unicodeSparkLevels = []rune("▁▂▃▄▅▆▇█")
+	asciiSparkLevels   = []rune("_.-=#")
+)
+
+// Sparkline renders values as a row of bars scaled between their minimum
+// and maximum, using ASCII bars when the terminal can't show Unicode
+func Sparkline(values []int64) string {
+	if Term().Unicode {
+		return RenderSparkline(values, unicodeSparkLevels)
+	}
+	return RenderSparkline(values, asciiSparkLevels)
+}
+
+// RenderSparkline renders one glyph per value from levels (lowest first).
+// A flat series (including a single value) is drawn at the lowest level if
+// it is zero and at the middle level otherwise, so growth from nothing still
+// stands out.
+func RenderSparkline(values []int64, levels []rune) string {
+	if len(values) == 0 || len(levels) == 0 {
+		return ""
+	}
+
+	lo, hi := values[0], values[0]
+	for _, v := range values[1:] {
+		lo = min(lo, v)
+		hi = max(hi, v)
+	}
+
+	out := make([]rune, len(values))
+	top := len(levels) - 1
+	for i, v := range values {
+		switch {
+		case lo == hi && v == 0:
+			out[i] = levels[0]
+		case lo == hi:
+			out[i] = levels[top/2]
+		default:
+			// Scale in floating point: sizes near the int64 range would overflow
+			level := int((float64(v) - float64(lo)) / (float64(hi) - float64(lo)) * float64(top))
+			out[i] = levels[min(max(level, 0), top)]
+		}
+	}
+	return string(out)
+}
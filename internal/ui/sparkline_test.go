@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"math"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRenderSparkline(t *testing.T) {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	tests := []struct {
+		name   string
+		values []int64
+		levels []rune
+		want   string
+	}{
+		{name: "no values", values: nil, levels: levels, want: ""},
+		{name: "no levels", values: []int64{1, 2}, levels: nil, want: ""},
+		{name: "single zero", values: []int64{0}, levels: levels, want: "▁"},
+		{name: "single value", values: []int64{4096}, levels: levels, want: "▄"},
+		{name: "all zeros", values: []int64{0, 0, 0, 0}, levels: levels, want: "▁▁▁▁"},
+		{name: "flat", values: []int64{7, 7, 7}, levels: levels, want: "▄▄▄"},
+		{name: "ramp", values: []int64{0, 1, 2, 3, 4, 5, 6, 7}, levels: levels, want: "▁▂▃▄▅▆▇█"},
+		{name: "falling", values: []int64{70, 35, 0}, levels: levels, want: "█▄▁"},
+		{name: "growth from nothing", values: []int64{0, 0, 100}, levels: levels, want: "▁▁█"},
+		{name: "huge outlier", values: []int64{10, 12, 11, 1 << 40, 13}, levels: levels, want: "▁▁▁█▁"},
+		{name: "outlier low", values: []int64{1000, 1001, 0, 1002}, levels: levels, want: "▇▇▁█"},
+		{name: "near the int64 range", values: []int64{math.MaxInt64 - 2, math.MaxInt64, 0}, levels: levels, want: "██▁"},
+		{name: "two levels", values: []int64{1, 2, 3}, levels: []rune("_#"), want: "__#"},
+		{name: "one level", values: []int64{1, 5}, levels: []rune("#"), want: "##"},
+		{name: "ascii", values: []int64{0, 25, 50, 75, 100}, levels: asciiSparkLevels, want: "_.-=#"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RenderSparkline(tt.values, tt.levels)
+			if got != tt.want {
+				t.Errorf("RenderSparkline(%v) = %q, want %q", tt.values, got, tt.want)
+			}
+			if len(tt.levels) > 0 && utf8.RuneCountInString(got) != len(tt.values) {
+				t.Errorf("%d glyphs for %d values", utf8.RuneCountInString(got), len(tt.values))
+			}
+		})
+	}
+}
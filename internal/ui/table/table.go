@@ -0,0 +1,273 @@
+// Package table renders rows of text as an aligned table, CSV or JSON, so
+// commands describe their columns once and get every format
+package table
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/mattn/go-runewidth"
+)
+
+// Format is an output format
+type Format string
+
+const (
+	Text Format = "table"
+	CSV  Format = "csv"
+	JSON Format = "json"
+)
+
+// ParseFormat validates a --format value
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(s))); format {
+	case Text, CSV, JSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q (use table, csv or json)", s)
+}
+
+// Align is the alignment of a column in text output
+type Align int
+
+const (
+	Left Align = iota
+	Right
+)
+
+// Column describes a column of the table
+type Column struct {
+	Title string
+	Key   string // JSON field and CSV header; derived from Title when empty
+	Align Align
+
+	// MinWidth and MaxWidth bound the width in text output, which otherwise
+	// fits the widest cell; 0 means no bound. Wider cells are truncated.
+	MinWidth int
+	MaxWidth int
+
+	// Flex columns give way, down to MinWidth, when the table is wider
+	// than the terminal
+	Flex bool
+}
+
+// key returns the column's JSON and CSV name
+func (c Column) key() string {
+	if c.Key != "" {
+		return c.Key
+	}
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(c.Title)), " ", "_")
+}
+
+// Table collects rows for rendering
+type Table struct {
+	Columns []Column
+
+	// LineWidth is the width text output must fit by shrinking Flex
+	// columns; 0 uses the terminal width (or 100 when unknown)
+	LineWidth int
+
+	rows   [][]string
+	totals []string
+}
+
+// New returns a table with the given columns
+func New(columns ...Column) *Table {
+	return &Table{Columns: columns}
+}
+
+// Row adds a row; missing cells are empty and extra cells are ignored
+func (t *Table) Row(cells ...string) {
+	t.rows = append(t.rows, t.normalize(cells))
+}
+
+// Totals sets a totals row, rendered after the others
+func (t *Table) Totals(cells ...string) {
+	t.totals = t.normalize(cells)
+}
+
+// Len returns the number of rows, not counting totals
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// normalize pads or cuts cells to the number of columns
+func (t *Table) normalize(cells []string) []string {
+	row := make([]string, len(t.Columns))
+	copy(row, cells)
+	return row
+}
+
+// Render writes the table in the given format
+func (t *Table) Render(w io.Writer, format Format) error {
+	switch format {
+	case CSV:
+		return t.renderCSV(w)
+	case JSON:
+		return t.renderJSON(w)
+	default:
+		return t.renderText(w)
+	}
+}
+
+// widths returns the text width of each column
+func (t *Table) widths() []int {
+	widths := make([]int, len(t.Columns))
+	for i, column := range t.Columns {
+		widths[i] = runewidth.StringWidth(column.Title)
+		for _, row := range t.rows {
+			widths[i] = max(widths[i], runewidth.StringWidth(row[i]))
+		}
+		if t.totals != nil {
+			widths[i] = max(widths[i], runewidth.StringWidth(t.totals[i]))
+		}
+		widths[i] = max(widths[i], column.MinWidth)
+		if column.MaxWidth > 0 {
+			widths[i] = min(widths[i], column.MaxWidth)
+		}
+	}
+
+	lineWidth := t.LineWidth
+	if lineWidth <= 0 {
+		lineWidth = ui.LineWidth(100)
+	}
+	over := total(widths) - lineWidth
+	for i, column := range t.Columns {
+		if over <= 0 {
+			break
+		}
+		if !column.Flex {
+			continue
+		}
+		give := min(over, widths[i]-max(column.MinWidth, runewidth.StringWidth(column.Title)))
+		if give > 0 {
+			widths[i] -= give
+			over -= give
+		}
+	}
+	return widths
+}
+
+// total returns the width of a text line with the given column widths
+func total(widths []int) int {
+	sum := 0
+	for _, width := range widths {
+		sum += width
+	}
+	return sum + 2*max(0, len(widths)-1)
+}
+
+// renderText writes the aligned table with a rule under the header
+func (t *Table) renderText(w io.Writer) error {
+	widths := t.widths()
+	titles := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		titles[i] = column.Title
+	}
+
+	var b strings.Builder
+	t.line(&b, titles, widths)
+	b.WriteString(strings.Repeat("-", total(widths)) + "\n")
+	for _, row := range t.rows {
+		t.line(&b, row, widths)
+	}
+	if t.totals != nil {
+		b.WriteString(strings.Repeat("-", total(widths)) + "\n")
+		t.line(&b, t.totals, widths)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// line writes one row of text output without trailing spaces
+func (t *Table) line(b *strings.Builder, cells []string, widths []int) {
+	var line strings.Builder
+	for i, cell := range cells {
+		if i > 0 {
+			line.WriteString("  ")
+		}
+		cell = ui.Truncate(cell, widths[i])
+		if t.Columns[i].Align == Right {
+			line.WriteString(runewidth.FillLeft(cell, widths[i]))
+		} else {
+			line.WriteString(runewidth.FillRight(cell, widths[i]))
+		}
+	}
+	b.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+}
+
+// renderCSV writes a header and one record per row, totals last
+func (t *Table) renderCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		header[i] = column.key()
+	}
+	records := append([][]string{header}, t.rows...)
+	if t.totals != nil {
+		records = append(records, t.totals)
+	}
+	return writer.WriteAll(records)
+}
+
+// record is a row encoded as a JSON object with fields in column order
+type record struct {
+	keys, cells []string
+}
+
+// MarshalJSON implements json.Marshaler
+func (r *record) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(r.cells[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// renderJSON writes {"rows": [...], "totals": {...}} with cells keyed by
+// column; cells are the strings shown in text output
+func (t *Table) renderJSON(w io.Writer) error {
+	keys := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		keys[i] = column.key()
+	}
+	object := func(row []string) *record {
+		return &record{keys: keys, cells: row}
+	}
+
+	out := struct {
+		Rows   []*record `json:"rows"`
+		Totals *record   `json:"totals,omitempty"`
+	}{Rows: make([]*record, 0, len(t.rows))}
+	for _, row := range t.rows {
+		out.Rows = append(out.Rows, object(row))
+	}
+	if t.totals != nil {
+		out.Totals = object(t.totals)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
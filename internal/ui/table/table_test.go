@@ -0,0 +1,220 @@
+package table
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/mattn/go-runewidth"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files of testdata")
+
+// tables returns a table of tables as the list command shows it, with wide
+// names that misaligned under Printf padding
+func tables() *Table {
+	t := New(
+		Column{Title: "Table", Flex: true},
+		Column{Title: "Rows", Align: Right},
+		Column{Title: "Size", Align: Right},
+		Column{Title: "Status", Key: "state"},
+	)
+	t.LineWidth = 80
+	t.Row("users", "1,204", "2.1 MB", "full")
+	t.Row("注文履歴", "58,310", "41.0 MB", "full")
+	t.Row("用户_logs", "9", "16.0 KB", "excluded")
+	t.Row("🚀_launches", "3", "16.0 KB", "sampled (2 rows)")
+	t.Row("café", "12", "8.0 KB") // a missing cell is empty
+	t.Row("a,\"quoted\" name", "0", "0 B", "full", "extra cell")
+	t.Totals("6 tables", "59,538", "59.3 MB")
+	return t
+}
+
+// TestRenderGolden compares each format with the .golden files; run with
+// -update to rewrite them after checking the diff
+func TestRenderGolden(t *testing.T) {
+	for _, format := range []Format{Text, CSV, JSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var out bytes.Buffer
+			if err := tables().Render(&out, format); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", string(format)+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output differs from %s\n got:\n%s\nwant:\n%s", golden, out.Bytes(), want)
+			}
+		})
+	}
+}
+
+// columnStarts returns the display column each cell of a text line starts
+// at, given the text of the cells
+func columnStarts(t *testing.T, line string, cells []string) []int {
+	t.Helper()
+	var starts []int
+	rest := line
+	offset := 0
+	for _, cell := range cells {
+		i := strings.Index(rest, cell)
+		if i < 0 {
+			t.Fatalf("line %q lacks %q", line, cell)
+		}
+		starts = append(starts, offset+runewidth.StringWidth(rest[:i]))
+		offset += runewidth.StringWidth(rest[:i+len(cell)])
+		rest = rest[i+len(cell):]
+	}
+	return starts
+}
+
+// TestWideCharacters checks that CJK, emoji and combining characters take
+// their display width, so the columns after them line up
+func TestWideCharacters(t *testing.T) {
+	names := []string{"users", "注文履歴", "用户_logs", "🚀_launches", "📦", "cafe\u0301", "ｆｕｌｌｗｉｄｔｈ"}
+	tbl := New(Column{Title: "Table"}, Column{Title: "Rows", Align: Right}, Column{Title: "Status"})
+	tbl.LineWidth = 200
+	for i, name := range names {
+		tbl.Row(name, strings.Repeat("9", i+1), "ok")
+	}
+	var out bytes.Buffer
+	if err := tbl.Render(&out, Text); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(names)+2 {
+		t.Fatalf("%d lines, want %d:\n%s", len(lines), len(names)+2, out.String())
+	}
+
+	header := columnStarts(t, lines[0], []string{"Table", "Rows", "Status"})
+	// The widest name takes 18 columns, and the widest count 7
+	if end := header[1] + len("Rows"); end != 18+2+7 {
+		t.Errorf("Rows ends at column %d, want 27\n%s", end, out.String())
+	}
+	for i, name := range names {
+		line := lines[i+2]
+		rows := strings.Repeat("9", i+1)
+		starts := columnStarts(t, line, []string{name, rows, "ok"})
+		// Rows is right-aligned: its cells end where the title does
+		if end := starts[1] + len(rows); end != header[1]+len("Rows") {
+			t.Errorf("%s: rows end at column %d, want %d\n%s", name, end, header[1]+len("Rows"), out.String())
+		}
+		if starts[2] != header[2] {
+			t.Errorf("%s: status starts at column %d, want %d\n%s", name, starts[2], header[2], out.String())
+		}
+	}
+	if rule := lines[1]; len(rule) != header[2]+len("Status") {
+		t.Errorf("rule is %d columns wide, want %d", len(rule), header[2]+len("Status"))
+	}
+}
+
+// TestWidths checks how columns shrink to fit MaxWidth and the line width
+func TestWidths(t *testing.T) {
+	ellipsis := ui.Sym().Ellipsis
+	tests := []struct {
+		name      string
+		columns   []Column
+		lineWidth int
+		cells     []string
+		want      []int
+	}{
+		{
+			name:      "fits",
+			columns:   []Column{{Title: "Table", Flex: true}, {Title: "Size"}},
+			lineWidth: 80,
+			cells:     []string{"注文履歴", "1 MB"},
+			want:      []int{8, 4},
+		},
+		{
+			name:      "minimum width",
+			columns:   []Column{{Title: "T", MinWidth: 6}, {Title: "Size"}},
+			lineWidth: 80,
+			cells:     []string{"ab", "1 MB"},
+			want:      []int{6, 4},
+		},
+		{
+			name:      "maximum width",
+			columns:   []Column{{Title: "Table", MaxWidth: 6}, {Title: "Size"}},
+			lineWidth: 80,
+			cells:     []string{"a_very_long_name", "1 MB"},
+			want:      []int{6, 4},
+		},
+		{
+			name:      "flex gives way",
+			columns:   []Column{{Title: "Table", Flex: true}, {Title: "Size"}},
+			lineWidth: 16,
+			cells:     []string{"a_very_long_name", "1 MB"},
+			want:      []int{10, 4},
+		},
+		{
+			name:      "flex stops at its title",
+			columns:   []Column{{Title: "Table", Flex: true}, {Title: "Size"}},
+			lineWidth: 4,
+			cells:     []string{"a_very_long_name", "1 MB"},
+			want:      []int{5, 4},
+		},
+		{
+			name:      "only flex columns give way",
+			columns:   []Column{{Title: "Table"}, {Title: "Note", Flex: true, MinWidth: 6}},
+			lineWidth: 20,
+			cells:     []string{"a_very_long_name", "a long note"},
+			want:      []int{16, 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tbl := New(tt.columns...)
+			tbl.LineWidth = tt.lineWidth
+			tbl.Row(tt.cells...)
+			got := tbl.widths()
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("widths = %v, want %v", got, tt.want)
+			}
+
+			var out bytes.Buffer
+			if err := tbl.Render(&out, Text); err != nil {
+				t.Fatal(err)
+			}
+			row := strings.Split(out.String(), "\n")[2]
+			if cut := ui.Truncate(tt.cells[0], got[0]); cut != tt.cells[0] && !strings.HasPrefix(row, strings.TrimSuffix(cut, ellipsis)) {
+				t.Errorf("row %q doesn't start with the truncated cell %q", row, cut)
+			}
+			if width := runewidth.StringWidth(strings.TrimRight(row, " ")); width > total(got) {
+				t.Errorf("row %q is %d columns wide, more than %d", row, width, total(got))
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Format
+		wantErr bool
+	}{
+		{"table", Text, false},
+		{"CSV", CSV, false},
+		{" json ", JSON, false},
+		{"yaml", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package ui
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+// Terminal describes what the terminal that output goes to supports
+type Terminal struct {
+	TTY     bool // stdout is a terminal
+	Width   int  // columns, 0 when unknown
+	Height  int  // rows, 0 when unknown
+	Color   bool // ANSI colors may be used
+	Unicode bool // symbols and box drawing characters render correctly
+}
+
+var (
+	terminalOnce sync.Once
+	terminal     Terminal
+)
+
+// Term returns the capabilities of stdout, detected on first use
+func Term() Terminal {
+	terminalOnce.Do(func() {
+		terminal = DetectTerminal(int(os.Stdout.Fd()), os.Getenv)
+	})
+	return terminal
+}
+
+// DetectTerminal determines terminal capabilities for a file descriptor.
+// NO_COLOR disables colors, CLICOLOR_FORCE enables them even when output is
+// not a terminal (for CI logs), TERM=dumb or a non-UTF-8 locale switches to
+// ASCII, and COLUMNS is used when the width can't be queried.
+func DetectTerminal(fd int, getenv func(string) string) Terminal {
+	t := Terminal{TTY: term.IsTerminal(fd)}
+
+	if t.TTY {
+		if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+			t.Width, t.Height = width, height
+		}
+	}
+	if t.Width == 0 {
+		if columns, err := strconv.Atoi(getenv("COLUMNS")); err == nil && columns > 0 {
+			t.Width = columns
+		}
+	}
+
+	dumb := getenv("TERM") == "dumb"
+	t.Unicode = !dumb && utf8Locale(getenv)
+
+	t.Color = t.TTY && !dumb
+	if force := getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		t.Color = true
+	}
+	if getenv("NO_COLOR") != "" {
+		t.Color = false
+	}
+
+	return t
+}
+
+// utf8Locale reports whether the locale allows UTF-8 output; an unset
+// locale is assumed to be UTF-8, as on most modern systems
+func utf8Locale(getenv func(string) string) bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := getenv(name); value != "" {
+			if value == "C" || value == "POSIX" {
+				return false
+			}
+			lower := strings.ToLower(value)
+			return strings.Contains(lower, "utf-8") || strings.Contains(lower, "utf8")
+		}
+	}
+	return true
+}
+
+// Symbols are the glyphs used in output
+type Symbols struct {
+	Success   string
+	Failure   string
+	Warning   string
+	Info      string
+	Checked   string
+	Unchecked string
+	Partial   string
+	Suggested string
+	Collapsed string
+	Expanded  string
+	Rule      string
+	Separator string
+	Ellipsis  string
+	Spinner   []string
+}
+
+var unicodeSymbols = Symbols{
+	Success:   "✓",
+	Failure:   "✗",
+	Warning:   "⚠",
+	Info:      "ℹ",
+	Checked:   "☑",
+	Unchecked: "☐",
+	Partial:   "◩",
+	Suggested: "◇",
+	Collapsed: "▸",
+	Expanded:  "▾",
+	Rule:      "─",
+	Separator: "·",
+	Ellipsis:  "…",
+	Spinner:   []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
+}
+
+var asciiSymbols = Symbols{
+	Success:   "+",
+	Failure:   "x",
+	Warning:   "!",
+	Info:      "i",
+	Checked:   "[x]",
+	Unchecked: "[ ]",
+	Partial:   "[-]",
+	Suggested: "?",
+	Collapsed: "+",
+	Expanded:  "-",
+	Rule:      "-",
+	Separator: "|",
+	Ellipsis:  "...",
+	Spinner:   []string{"|", "/", "-", "\\"},
+}
+
+// Sym returns the symbols suitable for the terminal
+func Sym() Symbols {
+	if Term().Unicode {
+		return unicodeSymbols
+	}
+	return asciiSymbols
+}
+
+// ANSI color codes
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+	colorCyan   = "36"
+)
+
+// colorize wraps s in an ANSI color when the terminal supports colors
+func colorize(color, s string) string {
+	if !Term().Color {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
+
+// Truncate shortens s to at most width display columns, ending with an
+// ellipsis when cut; a width of 0 or less leaves s unchanged
+func Truncate(s string, width int) string {
+	if width <= 0 || runewidth.StringWidth(s) <= width {
+		return s
+	}
+	ellipsis := Sym().Ellipsis
+	if width <= runewidth.StringWidth(ellipsis) {
+		return runewidth.Truncate(s, width, "")
+	}
+	return runewidth.Truncate(s, width, ellipsis)
+}
+
+// DisplayWidth returns the number of terminal columns s occupies
+func DisplayWidth(s string) int {
+	return runewidth.StringWidth(s)
+}
+
+// PadRight pads s with spaces to width display columns
+func PadRight(s string, width int) string {
+	return runewidth.FillRight(s, width)
+}
+
+// LineWidth returns the width available for a line of output, or fallback
+// when the terminal width is unknown
+func LineWidth(fallback int) int {
+	if width := Term().Width; width > 0 {
+		return width
+	}
+	return fallback
+}
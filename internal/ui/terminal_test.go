@@ -0,0 +1,176 @@
+package ui
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/helgesverre/dbdump/internal/database"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+	"github.com/mattn/go-runewidth"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files of testdata")
+
+// withTerminal makes Term return term until the test ends
+func withTerminal(t *testing.T, term Terminal) {
+	t.Helper()
+	saved := Term()
+	terminal = term
+	t.Cleanup(func() { terminal = saved })
+}
+
+// checkGolden compares got with testdata/name.golden; run with -update to
+// rewrite the file after checking the diff
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s\n got:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
+
+func TestDetectTerminal(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Terminal
+	}{
+		{name: "pipe", want: Terminal{Unicode: true}},
+		{name: "columns", env: map[string]string{"COLUMNS": "132"}, want: Terminal{Width: 132, Unicode: true}},
+		{name: "bad columns", env: map[string]string{"COLUMNS": "wide"}, want: Terminal{Unicode: true}},
+		{name: "forced color", env: map[string]string{"CLICOLOR_FORCE": "1"}, want: Terminal{Color: true, Unicode: true}},
+		{name: "force disabled", env: map[string]string{"CLICOLOR_FORCE": "0"}, want: Terminal{Unicode: true}},
+		{name: "NO_COLOR wins", env: map[string]string{"CLICOLOR_FORCE": "1", "NO_COLOR": "1"}, want: Terminal{Unicode: true}},
+		{name: "dumb", env: map[string]string{"TERM": "dumb", "CLICOLOR_FORCE": "1"}, want: Terminal{Color: true}},
+		{name: "C locale", env: map[string]string{"LANG": "C"}, want: Terminal{}},
+		{name: "UTF-8 locale", env: map[string]string{"LANG": "C", "LC_CTYPE": "en_US.UTF-8"}, want: Terminal{Unicode: true}},
+		{name: "LC_ALL first", env: map[string]string{"LC_ALL": "POSIX", "LANG": "en_US.utf8"}, want: Terminal{}},
+		{name: "latin1 locale", env: map[string]string{"LANG": "de_DE.ISO-8859-1"}, want: Terminal{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// -1 is never a terminal, as with output piped to a file
+			got := DetectTerminal(-1, func(name string) string { return tt.env[name] })
+			if got != tt.want {
+				t.Errorf("DetectTerminal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		width   int
+		unicode bool
+		want    string
+	}{
+		{name: "fits", s: "users", width: 5, unicode: true, want: "users"},
+		{name: "no width", s: "users", width: 0, unicode: true, want: "users"},
+		{name: "cut", s: "order_items", width: 6, unicode: true, want: "order…"},
+		{name: "ascii", s: "order_items", width: 6, want: "ord..."},
+		{name: "narrower than the ellipsis", s: "order_items", width: 2, want: "or"},
+		{name: "wide runes", s: "注文履歴テーブル", width: 7, unicode: true, want: "注文履…"},
+		{name: "wide rune on the edge", s: "注文履歴", width: 6, unicode: true, want: "注文…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTerminal(t, Terminal{Unicode: tt.unicode})
+			got := Truncate(tt.s, tt.width)
+			if got != tt.want {
+				t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+			}
+			if tt.width > 0 && runewidth.StringWidth(got) > tt.width {
+				t.Errorf("Truncate(%q, %d) is %d columns wide", tt.s, tt.width, runewidth.StringWidth(got))
+			}
+		})
+	}
+}
+
+// selectorTables are the tables of the selector snapshots, with names and
+// comments long enough to be cut on narrow terminals
+var selectorTables = []database.TableInfo{
+	{Name: "users", RowCount: 1204, DataSize: 2 << 20, TotalSize: 3 << 20, SizeDisplay: "3.0 MB", Engine: "InnoDB"},
+	{Name: "order_items", RowCount: 58310, DataSize: 40 << 20, TotalSize: 41 << 20, SizeDisplay: "41.0 MB", Engine: "InnoDB",
+		Comment: "Line items of every order, one row per product and quantity"},
+	{Name: "注文履歴", RowCount: 9, DataSize: 16 << 10, TotalSize: 16 << 10, SizeDisplay: "16.0 KB", Engine: "InnoDB"},
+	{Name: "sessions", RowCount: 880000, DataSize: 300 << 20, TotalSize: 310 << 20, SizeDisplay: "310.0 MB", Engine: "InnoDB",
+		CreateTime: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+}
+
+// TestSelectorSnapshots renders the table selector at common terminal
+// widths, with Unicode and in ASCII
+func TestSelectorSnapshots(t *testing.T) {
+	for _, unicode := range []bool{true, false} {
+		for _, width := range []int{60, 100, 200} {
+			name := fmt.Sprintf("selector-%d", width)
+			if !unicode {
+				name += "-ascii"
+			}
+			t.Run(name, func(t *testing.T) {
+				withTerminal(t, Terminal{Unicode: unicode})
+				m := NewTableSelectionModel(selectorTables, []string{"sessions"}, SelectionOptions{
+					Reasons: map[string]string{"sessions": "matches the exclude pattern *sessions*"},
+				})
+				m, _ = m.update(tea.WindowSizeMsg{Width: width, Height: 40})
+				m, _ = press(t, m, "j")
+
+				view := m.View()
+				for _, line := range strings.Split(view, "\n") {
+					if runewidth.StringWidth(line) >= width {
+						t.Errorf("line is %d columns wide: %q", runewidth.StringWidth(line), line)
+					}
+				}
+				checkGolden(t, name, []byte(view))
+			})
+		}
+	}
+}
+
+// TestSummarySnapshots renders the summary after a dump with each symbol
+// set and with forced colors
+func TestSummarySnapshots(t *testing.T) {
+	tests := []struct {
+		name string
+		term Terminal
+	}{
+		{name: "summary", term: Terminal{Unicode: true}},
+		{name: "summary-ascii", term: Terminal{}},
+		{name: "summary-color", term: Terminal{Unicode: true, Color: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTerminal(t, tt.term)
+			saved := console
+			defer func() {
+				console = saved
+				diag.Default.Reset()
+			}()
+			var out bytes.Buffer
+			console = &out
+
+			captureStdout(t, func() {
+				PrintSummary("shop-2024-03-01.sql.gz", 3, 83*time.Second, "41.2 MB", "nightly")
+				PrintWarning("2 tables changed while they were dumped")
+			})
+			checkGolden(t, tt.name, out.Bytes())
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+// SlowestTables returns up to n table timings, slowest first
+func SlowestTables(timings []database.TableTiming, n int) []database.TableTiming {
+	sorted := make([]database.TableTiming, len(timings))
+	copy(sorted, timings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Duration != sorted[j].Duration {
+			return sorted[i].Duration > sorted[j].Duration
+		}
+		return sorted[i].Bytes > sorted[j].Bytes
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// PrintTimingBreakdown prints the phase durations and the n slowest tables.
+// Without per-table timings only the phases are shown, with a note saying so.
+func PrintTimingBreakdown(timings []database.TableTiming, structure, data time.Duration, n int) {
+	fmt.Printf("Phases: structure %s, data %s\n", FormatDuration(structure), FormatDuration(data))
+
+	if len(timings) == 0 {
+		fmt.Println("Per-table timing is not available for this dump (no table data was written); only phase timing is shown")
+		fmt.Println()
+		return
+	}
+
+	slowest := SlowestTables(timings, n)
+	fmt.Printf("\nTop %d slowest tables (data phase):\n", len(slowest))
+	fmt.Printf("  %-40s %10s %7s %12s\n", "Table", "Time", "%", "Size")
+	fmt.Println("  " + strings.Repeat("-", 72))
+	for _, timing := range slowest {
+		share := 0.0
+		if data > 0 {
+			share = float64(timing.Duration) / float64(data) * 100
+		}
+		fmt.Printf("  %-40s %10s %6.1f%% %12s\n",
+			timing.Table,
+			FormatDuration(timing.Duration),
+			share,
+			database.FormatBytes(timing.Bytes))
+	}
+	fmt.Println("  (times are measured as output arrives and are approximate)")
+	fmt.Println()
+}
+
+// FormatDuration rounds a duration for display, keeping sub-second precision for short ones
+func FormatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
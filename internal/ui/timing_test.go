@@ -0,0 +1,145 @@
+package ui
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/database"
+)
+
+// captureStdout returns what fn writes to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = saved
+	}()
+	fn()
+	_ = w.Close()
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output)
+}
+
+func TestSlowestTables(t *testing.T) {
+	timings := []database.TableTiming{
+		{Table: "users", Duration: 2 * time.Second, Bytes: 4096},
+		{Table: "orders", Duration: 9 * time.Second, Bytes: 1 << 20},
+		{Table: "tags", Duration: 2 * time.Second, Bytes: 8192},
+		{Table: "logs", Duration: 5 * time.Second, Bytes: 100},
+		{Table: "empty_a", Duration: 0},
+		{Table: "empty_b", Duration: 0},
+	}
+	tests := []struct {
+		name string
+		n    int
+		want []string
+	}{
+		{name: "all", n: 10, want: []string{"orders", "logs", "tags", "users", "empty_a", "empty_b"}},
+		{name: "top three", n: 3, want: []string{"orders", "logs", "tags"}},
+		{name: "ties broken by size", n: 4, want: []string{"orders", "logs", "tags", "users"}},
+		{name: "none", n: 0, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, timing := range SlowestTables(timings, tt.n) {
+				got = append(got, timing.Table)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SlowestTables(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+
+	if timings[0].Table != "users" || timings[1].Table != "orders" {
+		t.Errorf("SlowestTables reordered its input: %v", timings)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 0, want: "0s"},
+		{d: 1234 * time.Microsecond, want: "1ms"},
+		{d: 999*time.Millisecond + 400*time.Microsecond, want: "999ms"},
+		{d: 1234 * time.Millisecond, want: "1.2s"},
+		{d: 90*time.Second + 460*time.Millisecond, want: "1m30.5s"},
+		{d: 2*time.Hour + 3*time.Second, want: "2h0m3s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatDuration(tt.d); got != tt.want {
+				t.Errorf("FormatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintTimingBreakdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		timings []database.TableTiming
+		data    time.Duration
+		n       int
+		want    string
+	}{
+		{
+			name: "phase timing only",
+			data: 3 * time.Second,
+			n:    10,
+			want: "Phases: structure 250ms, data 3s\n" +
+				"Per-table timing is not available for this dump (no table data was written); only phase timing is shown\n\n",
+		},
+		{
+			name: "slowest tables",
+			timings: []database.TableTiming{
+				{Table: "users", Duration: time.Second, Bytes: 2048},
+				{Table: "orders", Duration: 3 * time.Second, Bytes: 3 << 20},
+				{Table: "tags", Duration: 500 * time.Millisecond, Bytes: 512},
+			},
+			data: 4 * time.Second,
+			n:    2,
+			want: "Phases: structure 250ms, data 4s\n" +
+				"\nTop 2 slowest tables (data phase):\n" +
+				"  Table                                          Time       %         Size\n" +
+				"  ------------------------------------------------------------------------\n" +
+				"  orders                                           3s   75.0%       3.0 MB\n" +
+				"  users                                            1s   25.0%       2.0 KB\n" +
+				"  (times are measured as output arrives and are approximate)\n\n",
+		},
+		{
+			name:    "no data phase",
+			timings: []database.TableTiming{{Table: "users", Bytes: 10}},
+			n:       10,
+			want: "Phases: structure 250ms, data 0s\n" +
+				"\nTop 1 slowest tables (data phase):\n" +
+				"  Table                                          Time       %         Size\n" +
+				"  ------------------------------------------------------------------------\n" +
+				"  users                                            0s    0.0%         10 B\n" +
+				"  (times are measured as output arrives and are approximate)\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := captureStdout(t, func() {
+				PrintTimingBreakdown(tt.timings, 250*time.Millisecond, tt.data, tt.n)
+			})
+			if got != tt.want {
+				t.Errorf("PrintTimingBreakdown() printed\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
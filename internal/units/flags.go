@@ -0,0 +1,66 @@
+package units
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size is a flag value for a byte size. It keeps the text it was set from,
+// so the value can be written back as the user gave it (e.g. into a plan).
+type Size struct {
+	Bytes int64
+	text  string
+}
+
+// Set implements pflag.Value
+func (s *Size) Set(text string) error {
+	bytes, err := ParseBytes(text)
+	if err != nil {
+		return err
+	}
+	s.Bytes, s.text = bytes, strings.TrimSpace(text)
+	return nil
+}
+
+// String implements pflag.Value
+func (s *Size) String() string {
+	if s.text == "" && s.Bytes != 0 {
+		return strconv.FormatInt(s.Bytes, 10)
+	}
+	return s.text
+}
+
+// Type implements pflag.Value; it is shown as the argument in --help
+func (s *Size) Type() string {
+	return "size"
+}
+
+// Duration is a flag value for a duration that also accepts d and w units
+type Duration struct {
+	Value time.Duration
+}
+
+// Set implements pflag.Value
+func (d *Duration) Set(text string) error {
+	value, err := ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	d.Value = value
+	return nil
+}
+
+// String implements pflag.Value; zero is "0", so --help doesn't show it as
+// a default
+func (d *Duration) String() string {
+	if d.Value == 0 {
+		return "0"
+	}
+	return d.Value.String()
+}
+
+// Type implements pflag.Value; it is shown as the argument in --help
+func (d *Duration) Type() string {
+	return "duration"
+}
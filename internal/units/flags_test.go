@@ -0,0 +1,71 @@
+package units
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestSizeFlag(t *testing.T) {
+	var size Size
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Var(&size, "max-file-size", "split the output into files of at most this size")
+
+	if size.String() != "" {
+		t.Errorf("unset String() = %q, want empty so --help shows no default", size.String())
+	}
+	if err := flags.Parse([]string{"--max-file-size", " 1.5GiB "}); err != nil {
+		t.Fatal(err)
+	}
+	if size.Bytes != 3<<29 || size.String() != "1.5GiB" {
+		t.Errorf("Size = %d, %q; want %d, %q", size.Bytes, size.String(), 3<<29, "1.5GiB")
+	}
+
+	err := flags.Parse([]string{"--max-file-size=1,5GB"})
+	if err == nil || !strings.Contains(err.Error(), "--max-file-size") || !strings.Contains(err.Error(), "decimal separator") {
+		t.Errorf("error %v doesn't name the flag and the problem", err)
+	}
+	if size.Bytes != 3<<29 {
+		t.Errorf("a rejected value changed the size to %d", size.Bytes)
+	}
+
+	if got := (&Size{Bytes: 4096}).String(); got != "4096" {
+		t.Errorf("String() of a size set in code = %q, want 4096", got)
+	}
+	if !strings.Contains(flags.FlagUsages(), "--max-file-size size") {
+		t.Errorf("--help doesn't show the size argument:\n%s", flags.FlagUsages())
+	}
+}
+
+func TestDurationFlag(t *testing.T) {
+	keepWithin := Duration{}
+	timeout := Duration{Value: 10 * time.Minute}
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Var(&keepWithin, "keep-within", "keep every backup newer than this")
+	flags.Var(&timeout, "timeout", "give up after this long")
+
+	usage := flags.FlagUsages()
+	if !strings.Contains(usage, "--keep-within duration") || !strings.Contains(usage, `(default 10m0s)`) {
+		t.Errorf("--help doesn't show the duration argument and default:\n%s", usage)
+	}
+	if strings.Contains(usage, "default 0") {
+		t.Errorf("--help shows a zero default:\n%s", usage)
+	}
+
+	if err := flags.Parse([]string{"--keep-within", "1w2d", "--timeout=90s"}); err != nil {
+		t.Fatal(err)
+	}
+	if keepWithin.Value != 9*24*time.Hour || timeout.Value != 90*time.Second {
+		t.Errorf("parsed %v, %v", keepWithin.Value, timeout.Value)
+	}
+
+	err := flags.Parse([]string{"--keep-within=-7d"})
+	if err == nil || !strings.Contains(err.Error(), "--keep-within") || !strings.Contains(err.Error(), "negative") {
+		t.Errorf("error %v doesn't name the flag and the problem", err)
+	}
+	if keepWithin.Value != 9*24*time.Hour {
+		t.Errorf("a rejected value changed the duration to %v", keepWithin.Value)
+	}
+}
@@ -0,0 +1,142 @@
+// Package units parses human-friendly sizes ("500MB", "1.5GiB") and durations
+// ("90m", "2h30m", "7d") and provides flag values for them
+package units
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Byte multipliers: KB, MB, ... are decimal, KiB, MiB, ... binary, and the
+// bare letters binary like MySQL's own options (--max-allowed-packet=1G)
+var byteUnits = map[string]int64{
+	"": 1, "b": 1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12, "pb": 1e15,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40, "pib": 1 << 50,
+	"k": 1 << 10, "m": 1 << 20, "g": 1 << 30, "t": 1 << 40, "p": 1 << 50,
+}
+
+// sizePattern splits a size into its number and unit
+var sizePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([A-Za-z]*)$`)
+
+// ParseBytes parses a size such as "512", "500MB", "1.5GiB" or "2g". Units
+// are case-insensitive; decimal commas, negative values and fractions of a
+// byte are rejected.
+func ParseBytes(s string) (int64, error) {
+	text := strings.TrimSpace(s)
+	if err := checkNumberText(s, text, "size"); err != nil {
+		return 0, err
+	}
+
+	match := sizePattern.FindStringSubmatch(text)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %q (use a number with an optional unit, e.g. 500MB or 1.5GiB)", s)
+	}
+	multiplier, ok := byteUnits[strings.ToLower(match[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q (use B, KB, MB, GB, TB or KiB, MiB, GiB, TiB)", s, match[2])
+	}
+
+	if multiplier == 1 {
+		if strings.Contains(match[1], ".") {
+			return 0, fmt.Errorf("invalid size %q: a byte count can't have a fraction", s)
+		}
+		n, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid size %q: too large", s)
+		}
+		return n, nil
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	bytes := value * float64(multiplier)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return int64(bytes), nil
+}
+
+// durationUnits are the units time.ParseDuration doesn't know
+var durationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// durationPart matches one number and unit of a duration
+var durationPart = regexp.MustCompile(`([0-9]+(?:\.[0-9]+)?)(ns|us|µs|μs|ms|s|m|h|d|w)`)
+
+// ParseDuration parses a duration like time.ParseDuration, adding d (24h)
+// and w (7d) units: "90m", "2h30m", "7d", "1w2d". Negative values, decimal
+// commas and numbers without a unit (other than 0) are rejected.
+func ParseDuration(s string) (time.Duration, error) {
+	text := strings.TrimSpace(s)
+	if err := checkNumberText(s, text, "duration"); err != nil {
+		return 0, err
+	}
+	if text == "0" {
+		return 0, nil
+	}
+
+	parts := durationPart.FindAllStringSubmatchIndex(text, -1)
+	end := 0
+	for _, part := range parts {
+		if part[0] != end {
+			break
+		}
+		end = part[1]
+	}
+	if len(parts) == 0 || end != len(text) {
+		if strings.ToLower(text) != text {
+			return 0, fmt.Errorf("invalid duration %q: units are lowercase (m is minutes, and months aren't supported)", s)
+		}
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			return 0, fmt.Errorf("invalid duration %q: missing unit (e.g. 90s, 2h30m or 7d)", s)
+		}
+		return 0, fmt.Errorf("invalid duration %q (use units ns, us, ms, s, m, h, d or w, e.g. 2h30m or 7d)", s)
+	}
+
+	var total time.Duration
+	for _, part := range parts {
+		number, unit := text[part[2]:part[3]], text[part[4]:part[5]]
+		var d time.Duration
+		if multiplier, ok := durationUnits[unit]; ok {
+			value, err := strconv.ParseFloat(number, 64)
+			if err != nil || value*float64(multiplier) >= math.MaxInt64 {
+				return 0, fmt.Errorf("invalid duration %q: too large", s)
+			}
+			d = time.Duration(value * float64(multiplier))
+		} else {
+			var err error
+			d, err = time.ParseDuration(number + unit)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q: too large", s)
+			}
+		}
+		if total > math.MaxInt64-d {
+			return 0, fmt.Errorf("invalid duration %q: too large", s)
+		}
+		total += d
+	}
+	return total, nil
+}
+
+// checkNumberText rejects the mistakes worth a specific message: empty
+// values, negative values and locale-style decimal commas
+func checkNumberText(s, text, kind string) error {
+	switch {
+	case text == "":
+		return fmt.Errorf("empty %s", kind)
+	case strings.HasPrefix(text, "-"):
+		return fmt.Errorf("invalid %s %q: must not be negative", kind, s)
+	case strings.Contains(text, ","):
+		return fmt.Errorf("invalid %s %q: use a dot as the decimal separator and no thousands separators", kind, s)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package units
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr string
+	}{
+		{in: "0", want: 0},
+		{in: "512", want: 512},
+		{in: "512B", want: 512},
+		{in: "512b", want: 512},
+		{in: "1KB", want: 1000},
+		{in: "1kb", want: 1000},
+		{in: "1Kb", want: 1000},
+		{in: "500MB", want: 500_000_000},
+		{in: "1GB", want: 1_000_000_000},
+		{in: "2TB", want: 2_000_000_000_000},
+		{in: "1PB", want: 1_000_000_000_000_000},
+		{in: "1KiB", want: 1024},
+		{in: "1kib", want: 1024},
+		{in: "1MiB", want: 1 << 20},
+		{in: "1.5GiB", want: 3 << 29},
+		{in: "1TiB", want: 1 << 40},
+		{in: "1PiB", want: 1 << 50},
+		{in: "8PiB", want: 1 << 53},
+		{in: "1k", want: 1024},
+		{in: "2g", want: 2 << 30},
+		{in: "2G", want: 2 << 30},
+		{in: "1M", want: 1 << 20},
+		{in: "1T", want: 1 << 40},
+		{in: "0.5MB", want: 500_000},
+		{in: "4000MB", want: 4_000_000_000},
+		{in: "  100MB  ", want: 100_000_000},
+		{in: "100 MB", want: 100_000_000},
+		{in: "9223372036854775807", want: 1<<63 - 1},
+
+		{in: "", wantErr: "empty size"},
+		{in: "   ", wantErr: "empty size"},
+		{in: "-1", wantErr: "must not be negative"},
+		{in: "-5MB", wantErr: "must not be negative"},
+		{in: "1,5GB", wantErr: "use a dot as the decimal separator"},
+		{in: "1,000", wantErr: "no thousands separators"},
+		{in: "1.5", wantErr: "can't have a fraction"},
+		{in: "1.5B", wantErr: "can't have a fraction"},
+		{in: "10XB", wantErr: `unknown unit "XB"`},
+		{in: "10mbit", wantErr: "unknown unit"},
+		{in: "MB", wantErr: "use a number with an optional unit"},
+		{in: "1.5.2MB", wantErr: "use a number with an optional unit"},
+		{in: ".5MB", wantErr: "use a number with an optional unit"},
+		{in: "5.MB", wantErr: "use a number with an optional unit"},
+		{in: "+5MB", wantErr: "use a number with an optional unit"},
+		{in: "1e6", wantErr: "use a number with an optional unit"},
+		{in: "5 M B", wantErr: "use a number with an optional unit"},
+		{in: "9223372036854775808", wantErr: "too large"},
+		{in: "10000PB", wantErr: "too large"},
+		{in: "8192PiB", wantErr: "too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseBytes(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseBytes(%q) = %d, %v; want an error containing %q", tt.in, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("ParseBytes(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	const day = 24 * time.Hour
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr string
+	}{
+		{in: "0", want: 0},
+		{in: "0s", want: 0},
+		{in: "90s", want: 90 * time.Second},
+		{in: "90m", want: 90 * time.Minute},
+		{in: "2h30m", want: 150 * time.Minute},
+		{in: "1.5h", want: 90 * time.Minute},
+		{in: "500ms", want: 500 * time.Millisecond},
+		{in: "10us", want: 10 * time.Microsecond},
+		{in: "10µs", want: 10 * time.Microsecond},
+		{in: "100ns", want: 100},
+		{in: "7d", want: 7 * day},
+		{in: "1.5d", want: 36 * time.Hour},
+		{in: "2w", want: 14 * day},
+		{in: "1w2d", want: 9 * day},
+		{in: "1d12h", want: 36 * time.Hour},
+		{in: "1d1h1m1s", want: day + time.Hour + time.Minute + time.Second},
+		{in: " 7d ", want: 7 * day},
+		{in: "2562047h", want: 2562047 * time.Hour},
+
+		{in: "", wantErr: "empty duration"},
+		{in: "-1h", wantErr: "must not be negative"},
+		{in: "-7d", wantErr: "must not be negative"},
+		{in: "1,5h", wantErr: "use a dot as the decimal separator"},
+		{in: "1,5d", wantErr: "use a dot as the decimal separator"},
+		{in: "90", wantErr: "missing unit"},
+		{in: "1.5", wantErr: "missing unit"},
+		{in: "7D", wantErr: "units are lowercase"},
+		{in: "1M", wantErr: "months aren't supported"},
+		{in: "2H30M", wantErr: "units are lowercase"},
+		{in: "1y", wantErr: "use units ns, us, ms, s, m, h, d or w"},
+		{in: "1h 30m", wantErr: "use units"},
+		{in: "h", wantErr: "use units"},
+		{in: "1h30", wantErr: "use units"},
+		{in: "x1h", wantErr: "use units"},
+		{in: "+1h", wantErr: "use units"},
+		{in: "1000000w", wantErr: "too large"},
+		{in: "2562048h", wantErr: "too large"},
+		{in: "106751d1000h", wantErr: "too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDuration(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseDuration(%q) = %v, %v; want an error containing %q", tt.in, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("ParseDuration(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseDurationMatchesStdlib checks that values time.ParseDuration
+// accepts (other than negative ones) parse the same
+func TestParseDurationMatchesStdlib(t *testing.T) {
+	for _, in := range []string{"1ns", "1.5us", "3ms", "2.25s", "45m", "1h15m30s", "100h", "1h0m0.5s"} {
+		want, err := time.ParseDuration(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ParseDuration(in); err != nil || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v; time.ParseDuration = %v", in, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// TableCount is how many runs excluded a table
+type TableCount struct {
+	Table string `json:"table"`
+	Runs  int    `json:"runs"`
+}
+
+// Summary aggregates the records of a usage directory
+type Summary struct {
+	Files          int            `json:"files"`
+	Runs           int            `json:"runs"`
+	Failed         int            `json:"failed"`
+	Interrupted    int            `json:"interrupted"`
+	Databases      int            `json:"databases"`
+	FailureRate    float64        `json:"failure_rate"`
+	MedianDuration time.Duration  `json:"median_duration_ns"`
+	MedianFileSize int64          `json:"median_file_size"`
+	TopExcluded    []TableCount   `json:"top_excluded"`
+	Versions       map[string]int `json:"versions"`
+	First          time.Time      `json:"first,omitempty"`
+	Last           time.Time      `json:"last,omitempty"`
+
+	// Skipped counts lines that could not be parsed
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// Aggregate reads every per-user file in dir and summarizes the runs,
+// listing the top most excluded tables
+func Aggregate(dir string, top int) (*Summary, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no usage files (*.jsonl) found in %s", dir)
+	}
+
+	summary := &Summary{Files: len(paths), Versions: make(map[string]int)}
+	var durations []time.Duration
+	var sizes []int64
+	databases := make(map[string]bool)
+	excluded := make(map[string]int)
+
+	for _, path := range paths {
+		records, skipped, err := readRecords(path)
+		if err != nil {
+			return nil, err
+		}
+		summary.Skipped += skipped
+
+		for _, record := range records {
+			summary.Runs++
+			summary.Versions[record.Version]++
+			databases[record.Database] = true
+			if summary.First.IsZero() || record.Time.Before(summary.First) {
+				summary.First = record.Time
+			}
+			if record.Time.After(summary.Last) {
+				summary.Last = record.Time
+			}
+
+			switch record.Outcome {
+			case OutcomeFailed:
+				summary.Failed++
+				continue
+			case OutcomeInterrupted:
+				summary.Interrupted++
+				continue
+			}
+			durations = append(durations, time.Duration(record.DurationMillis)*time.Millisecond)
+			sizes = append(sizes, record.FileSize)
+			for _, table := range record.ExcludedTables {
+				excluded[table]++
+			}
+		}
+	}
+
+	summary.Databases = len(databases)
+	if summary.Runs > 0 {
+		summary.FailureRate = float64(summary.Failed) / float64(summary.Runs)
+	}
+	summary.MedianDuration = median(durations)
+	summary.MedianFileSize = median(sizes)
+
+	for table, runs := range excluded {
+		summary.TopExcluded = append(summary.TopExcluded, TableCount{Table: table, Runs: runs})
+	}
+	sort.Slice(summary.TopExcluded, func(a, b int) bool {
+		if summary.TopExcluded[a].Runs != summary.TopExcluded[b].Runs {
+			return summary.TopExcluded[a].Runs > summary.TopExcluded[b].Runs
+		}
+		return summary.TopExcluded[a].Table < summary.TopExcluded[b].Table
+	})
+	if top > 0 && len(summary.TopExcluded) > top {
+		summary.TopExcluded = summary.TopExcluded[:top]
+	}
+
+	return summary, nil
+}
+
+// readRecords reads a usage file, counting lines that can't be parsed
+func readRecords(path string) ([]Record, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read usage file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var records []Record
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			skipped++
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read usage file %s: %w", path, err)
+	}
+	return records, skipped, nil
+}
+
+// median returns the middle value (the mean of the two middle values for an
+// even count), or zero for no values
+func median[T time.Duration | int64](values []T) T {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]T(nil), values...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,190 @@
+package usage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeUsage writes the lines of each per-user file into a new directory
+func writeUsage(t *testing.T, files map[string][]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, lines := range files {
+		content := strings.Join(lines, "\n")
+		if len(lines) > 0 {
+			content += "\n"
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// line encodes a record as a usage file line
+func line(t *testing.T, record Record) string {
+	t.Helper()
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestAggregate(t *testing.T) {
+	day := time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)
+	run := func(hour int, db, outcome string, seconds, size int64, excluded ...string) Record {
+		return Record{
+			Time: day.Add(time.Duration(hour) * time.Hour), Version: "1.4.0", Database: db, Outcome: outcome,
+			DurationMillis: seconds * 1000, FileSize: size, ExcludedTables: excluded,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		files map[string][]string
+		top   int
+		want  Summary
+	}{
+		{
+			name: "one run",
+			files: map[string][]string{
+				"a.jsonl": {line(t, run(9, "db1", OutcomeOK, 60, 100, "sessions"))},
+			},
+			want: Summary{
+				Files: 1, Runs: 1, Databases: 1,
+				MedianDuration: time.Minute, MedianFileSize: 100,
+				TopExcluded: []TableCount{{Table: "sessions", Runs: 1}},
+				Versions:    map[string]int{"1.4.0": 1},
+				First:       day.Add(9 * time.Hour), Last: day.Add(9 * time.Hour),
+			},
+		},
+		{
+			name: "team",
+			files: map[string][]string{
+				"alice.jsonl": {
+					line(t, run(9, "db1", OutcomeOK, 60, 100, "sessions", "cache")),
+					line(t, run(10, "db1", OutcomeOK, 120, 300, "sessions")),
+				},
+				"bob.jsonl": {
+					line(t, run(8, "db2", OutcomeOK, 30, 200, "cache", "sessions", "jobs")),
+					line(t, run(11, "db1", OutcomeFailed, 5, 0, "audit_log")),
+					line(t, run(12, "db2", OutcomeInterrupted, 15, 0)),
+				},
+			},
+			want: Summary{
+				Files: 2, Runs: 5, Failed: 1, Interrupted: 1, Databases: 2, FailureRate: 0.2,
+				// Failed and interrupted runs count in neither median nor exclusions
+				MedianDuration: time.Minute, MedianFileSize: 200,
+				TopExcluded: []TableCount{{Table: "sessions", Runs: 3}, {Table: "cache", Runs: 2}, {Table: "jobs", Runs: 1}},
+				Versions:    map[string]int{"1.4.0": 5},
+				First:       day.Add(8 * time.Hour), Last: day.Add(12 * time.Hour),
+			},
+		},
+		{
+			name: "even count takes the mean of the middle",
+			files: map[string][]string{
+				"a.jsonl": {
+					line(t, run(1, "db1", OutcomeOK, 10, 100)),
+					line(t, run(2, "db1", OutcomeOK, 40, 400)),
+					line(t, run(3, "db1", OutcomeOK, 20, 200)),
+					line(t, run(4, "db1", OutcomeOK, 30, 300)),
+				},
+			},
+			want: Summary{
+				Files: 1, Runs: 4, Databases: 1,
+				MedianDuration: 25 * time.Second, MedianFileSize: 250,
+				Versions: map[string]int{"1.4.0": 4},
+				First:    day.Add(time.Hour), Last: day.Add(4 * time.Hour),
+			},
+		},
+		{
+			name: "top tables, ties by name",
+			files: map[string][]string{
+				"a.jsonl": {
+					line(t, run(1, "db1", OutcomeOK, 1, 1, "zeta", "alpha", "mid")),
+					line(t, run(2, "db1", OutcomeOK, 1, 1, "zeta", "alpha")),
+				},
+			},
+			top: 2,
+			want: Summary{
+				Files: 1, Runs: 2, Databases: 1,
+				MedianDuration: time.Second, MedianFileSize: 1,
+				TopExcluded: []TableCount{{Table: "alpha", Runs: 2}, {Table: "zeta", Runs: 2}},
+				Versions:    map[string]int{"1.4.0": 2},
+				First:       day.Add(time.Hour), Last: day.Add(2 * time.Hour),
+			},
+		},
+		{
+			name: "unparsable and empty lines",
+			files: map[string][]string{
+				"a.jsonl": {"", line(t, run(1, "db1", OutcomeFailed, 1, 0)), "{truncated", "not json", ""},
+				"b.jsonl": {},
+			},
+			want: Summary{
+				Files: 2, Runs: 1, Failed: 1, Databases: 1, FailureRate: 1,
+				Versions: map[string]int{"1.4.0": 1},
+				First:    day.Add(time.Hour), Last: day.Add(time.Hour),
+				Skipped: 2,
+			},
+		},
+		{
+			name: "other files are ignored",
+			files: map[string][]string{
+				"a.jsonl":    {line(t, run(1, "db1", OutcomeOK, 2, 10))},
+				"notes.txt":  {"not a usage file"},
+				"a.jsonl.gz": {"compressed"},
+			},
+			want: Summary{
+				Files: 1, Runs: 1, Databases: 1,
+				MedianDuration: 2 * time.Second, MedianFileSize: 10,
+				Versions: map[string]int{"1.4.0": 1},
+				First:    day.Add(time.Hour), Last: day.Add(time.Hour),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Aggregate(writeUsage(t, tt.files), tt.top)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Aggregate() = %+v\nwant %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateNoFiles(t *testing.T) {
+	dir := writeUsage(t, map[string][]string{"notes.txt": {"x"}})
+	if _, err := Aggregate(dir, 10); err == nil || !strings.Contains(err.Error(), "no usage files") {
+		t.Errorf("Aggregate() = %v, want an error about missing usage files", err)
+	}
+}
+
+// TestAggregateAppended aggregates records written by Append
+func TestAggregateAppended(t *testing.T) {
+	a, _ := NewAnonymizer("team-key")
+	dir := t.TempDir()
+	for i, user := range []string{"alice", "bob", "alice"} {
+		record := Record{Version: "1.4.0", Database: a.Database("db.internal", 3306, "shop"), Outcome: OutcomeOK, DurationMillis: int64(i+1) * 1000}
+		if err := Append(a.UserFile(dir, user, "laptop"), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary, err := Aggregate(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Files != 2 || summary.Runs != 3 || summary.Databases != 1 || summary.MedianDuration != 2*time.Second {
+		t.Errorf("Aggregate() = %+v, want 2 files, 3 runs of 1 database and a median of 2s", summary)
+	}
+}
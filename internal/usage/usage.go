@@ -0,0 +1,90 @@
+// Package usage writes anonymized records of dump runs to a shared
+// directory and aggregates them, so a team can see how its dumps behave
+// without anything leaving the network. Nothing is written unless a
+// directory is configured.
+package usage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/fileutil"
+)
+
+// Run outcomes
+const (
+	OutcomeOK          = "ok"
+	OutcomeFailed      = "failed"
+	OutcomeInterrupted = "interrupted"
+)
+
+// hashLength is how many hex characters of an HMAC are kept; enough to tell
+// databases and users apart, too few to be worth brute-forcing
+const hashLength = 16
+
+// Record is one dump run. Identities are HMACs; table names are kept so
+// exclusions can be compared across the team.
+type Record struct {
+	Time           time.Time `json:"time"`
+	Version        string    `json:"version"`
+	Database       string    `json:"database"`
+	Outcome        string    `json:"outcome"`
+	DurationMillis int64     `json:"duration_ms"`
+	FileSize       int64     `json:"file_size,omitempty"`
+	Tables         int       `json:"tables,omitempty"`
+	ExcludedTables []string  `json:"excluded_tables,omitempty"`
+}
+
+// Anonymizer hashes identities with a key shared by the team, so the same
+// database gets the same ID on every machine but can't be recovered without
+// the key
+type Anonymizer struct {
+	key []byte
+}
+
+// NewAnonymizer creates an anonymizer; the key must not be empty
+func NewAnonymizer(key string) (*Anonymizer, error) {
+	if key == "" {
+		return nil, fmt.Errorf("an anonymization key is required")
+	}
+	return &Anonymizer{key: []byte(key)}, nil
+}
+
+// ID returns the anonymized identity of the given parts
+func (a *Anonymizer) ID(parts ...string) string {
+	mac := hmac.New(sha256.New, a.key)
+	for _, part := range parts {
+		// Length-prefix the parts so ("ab", "c") and ("a", "bc") differ
+		fmt.Fprintf(mac, "%d:%s;", len(part), part)
+	}
+	return hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// Database returns the anonymized identity of a database
+func (a *Anonymizer) Database(host string, port int, database string) string {
+	return a.ID("database", host, fmt.Sprint(port), database)
+}
+
+// UserFile returns the per-user file in dir that records are appended to,
+// named after the anonymized user so files from different people don't
+// collide on a shared folder
+func (a *Anonymizer) UserFile(dir, user, machine string) string {
+	return filepath.Join(dir, a.ID("user", user, machine)+".jsonl")
+}
+
+// Append writes a record as one JSON line to path
+func Append(path string, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage record: %w", err)
+	}
+	if err := fileutil.AppendFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write usage record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hashPattern is what an anonymized identity looks like
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+func TestNewAnonymizer(t *testing.T) {
+	if _, err := NewAnonymizer(""); err == nil {
+		t.Error("NewAnonymizer(\"\") succeeded, want an error")
+	}
+	if _, err := NewAnonymizer("team-key"); err != nil {
+		t.Errorf("NewAnonymizer() = %v", err)
+	}
+}
+
+func TestAnonymizerID(t *testing.T) {
+	team, _ := NewAnonymizer("team-key")
+	other, _ := NewAnonymizer("other-key")
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		// HMAC-SHA256 of "8:database;11:db.internal;4:3306;4:shop;", computed
+		// independently, so records stay comparable across versions
+		{name: "database", got: team.Database("db.internal", 3306, "shop"), want: "f6b1cb7bf7354c15"},
+		{name: "user", got: team.ID("user", "alice", "laptop"), want: "e4cc92780a03704a"},
+		{name: "other key", got: other.Database("db.internal", 3306, "shop"), want: "d8f4bb6236b1e236"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("ID = %s, want %s", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnonymizerDistinct(t *testing.T) {
+	a, _ := NewAnonymizer("team-key")
+
+	// Every identity differs from every other
+	ids := map[string]string{
+		"database":          a.Database("db.internal", 3306, "shop"),
+		"other port":        a.Database("db.internal", 3307, "shop"),
+		"other host":        a.Database("db.internal2", 3306, "shop"),
+		"other database":    a.Database("db.internal", 3306, "shop2"),
+		"moved boundary":    a.ID("ab", "c"),
+		"moved boundary 2":  a.ID("a", "bc"),
+		"joined":            a.ID("abc"),
+		"separator in part": a.ID("a;1:b"),
+		"two parts":         a.ID("a", "b"),
+		"no parts":          a.ID(),
+		"empty part":        a.ID(""),
+		"user":              a.ID("user", "shop", "laptop"),
+		"database as user":  a.ID("database", "shop", "laptop"),
+	}
+	seen := make(map[string]string)
+	for name, id := range ids {
+		if !hashPattern.MatchString(id) {
+			t.Errorf("%s: ID %q isn't 16 hex characters", name, id)
+		}
+		if other, ok := seen[id]; ok {
+			t.Errorf("%s and %s share the ID %s", name, other, id)
+		}
+		seen[id] = name
+	}
+
+	// The same identity hashes the same every time and on every anonymizer
+	// with the key
+	again, _ := NewAnonymizer("team-key")
+	if got := again.Database("db.internal", 3306, "shop"); got != ids["database"] {
+		t.Errorf("Database() = %s on a second anonymizer, want %s", got, ids["database"])
+	}
+}
+
+func TestUserFile(t *testing.T) {
+	a, _ := NewAnonymizer("team-key")
+	dir := filepath.Join("mnt", "shared", "dbdump")
+
+	path := a.UserFile(dir, "alice", "laptop")
+	if want := filepath.Join(dir, "e4cc92780a03704a.jsonl"); path != want {
+		t.Errorf("UserFile() = %s, want %s", path, want)
+	}
+	if strings.Contains(path, "alice") || strings.Contains(path, "laptop") {
+		t.Errorf("UserFile() = %s reveals the user", path)
+	}
+	if other := a.UserFile(dir, "alice", "desktop"); other == path {
+		t.Errorf("two machines of a user share %s", path)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	a, _ := NewAnonymizer("team-key")
+	path := a.UserFile(t.TempDir(), "alice", "laptop")
+
+	records := []Record{
+		{
+			Time:           time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC),
+			Version:        "1.4.0",
+			Database:       a.Database("db.internal", 3306, "shop"),
+			Outcome:        OutcomeOK,
+			DurationMillis: 81000,
+			FileSize:       1 << 30,
+			Tables:         42,
+			ExcludedTables: []string{"sessions", "cache"},
+		},
+		{
+			Time:           time.Date(2026, 5, 4, 11, 0, 0, 0, time.UTC),
+			Version:        "1.4.0",
+			Database:       a.Database("db.internal", 3306, "shop"),
+			Outcome:        OutcomeFailed,
+			DurationMillis: 1200,
+		},
+	}
+	for _, record := range records {
+		if err := Append(path, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	var lines []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, record)
+	}
+	if !reflect.DeepEqual(lines, records) {
+		t.Errorf("file holds %+v, want %+v", lines, records)
+	}
+
+	// Empty fields are left out of the line
+	data, _ := os.ReadFile(path)
+	second := strings.Split(strings.TrimSpace(string(data)), "\n")[1]
+	for _, field := range []string{"file_size", "tables", "excluded_tables"} {
+		if strings.Contains(second, `"`+field+`"`) {
+			t.Errorf("failed run's line %s has %s", second, field)
+		}
+	}
+}
+
+func TestAppendUnwritable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "user.jsonl")
+	if err := Append(path, Record{Outcome: OutcomeOK}); err == nil || !strings.Contains(err.Error(), "failed to write usage record") {
+		t.Errorf("Append() = %v, want a write error", err)
+	}
+}
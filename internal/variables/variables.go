@@ -0,0 +1,193 @@
+// Package variables lists the server variables that decide whether a dump
+// restores the way it was taken, and how a difference between the source
+// and the target server shows. The dump records them from the source;
+// restore compares them with the target.
+package variables
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Variable is a global server variable recorded with each dump
+type Variable struct {
+	Name string
+
+	// Missing is the value assumed when a server doesn't have the variable
+	// (an older version)
+	Missing string
+
+	// Problem returns the likely symptom of restoring a dump taken with the
+	// source value onto a target with the other, or "" when that difference
+	// doesn't matter
+	Problem func(source, target string) string
+}
+
+// Variables are the recorded variables, in the order they are shown
+var Variables = []Variable{
+	{Name: "sql_mode", Problem: sqlModeProblem},
+	{Name: "explicit_defaults_for_timestamp", Missing: "OFF", Problem: func(source, target string) string {
+		if on(target) {
+			return "TIMESTAMP columns declared without NULL or DEFAULT are nullable with no default on the target, so the application's INSERTs that relied on the implicit CURRENT_TIMESTAMP store NULL"
+		}
+		return "the first TIMESTAMP column declared without NULL or DEFAULT in a new table gets DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP on the target, and NULL is stored as the current time"
+	}},
+	{Name: "character_set_server", Problem: func(source, target string) string {
+		return fmt.Sprintf("databases and tables created without a character set get %s instead of %s; joining or comparing them with the restored columns can fail with \"Illegal mix of collations\"", target, source)
+	}},
+	{Name: "collation_server", Problem: func(source, target string) string {
+		return fmt.Sprintf("databases and tables created without a collation get %s instead of %s, which sorts and compares strings differently", target, source)
+	}},
+	{Name: "foreign_key_checks", Missing: "ON", Problem: func(source, target string) string {
+		if on(target) {
+			return "the dump restores with foreign key checks off, but afterwards the target enforces foreign keys the source didn't, so writes the application could make before can fail"
+		}
+		return "the target doesn't check foreign keys by default, so the application can write rows the source would have rejected"
+	}},
+	{Name: "innodb_strict_mode", Missing: "OFF", Problem: func(source, target string) string {
+		if on(target) {
+			return "CREATE TABLE statements the source accepted with a warning (row size too large, unsupported ROW_FORMAT or KEY_BLOCK_SIZE) fail on the target"
+		}
+		return ""
+	}},
+	{Name: "sql_require_primary_key", Missing: "OFF", Problem: func(source, target string) string {
+		if on(target) {
+			return "tables without a primary key fail to create on the target (ERROR 3750)"
+		}
+		return ""
+	}},
+	{Name: "log_bin_trust_function_creators", Missing: "OFF", Problem: func(source, target string) string {
+		if on(source) && !on(target) {
+			return "with binary logging on the target, creating the dump's triggers fails with ERROR 1419 unless the restoring user has SUPER"
+		}
+		return ""
+	}},
+}
+
+// Names returns the names of the recorded variables
+func Names() []string {
+	names := make([]string, len(Variables))
+	for i, variable := range Variables {
+		names[i] = variable.Name
+	}
+	return names
+}
+
+// Fill returns the values read from a server with the assumed value of
+// each variable it doesn't have; variables without one are left out
+func Fill(values map[string]string) map[string]string {
+	filled := make(map[string]string, len(Variables))
+	for _, variable := range Variables {
+		if value, ok := values[variable.Name]; ok {
+			filled[variable.Name] = value
+		} else if variable.Missing != "" {
+			filled[variable.Name] = variable.Missing
+		}
+	}
+	return filled
+}
+
+// Difference is a variable whose value on the target differs from the
+// source's in a way that shows
+type Difference struct {
+	Name    string
+	Source  string
+	Target  string
+	Symptom string
+}
+
+// Compare returns the meaningful differences between the variables of the
+// source, as recorded, and of the target. Variables missing on either side
+// are not compared.
+func Compare(source, target map[string]string) []Difference {
+	var differences []Difference
+	for _, variable := range Variables {
+		from, ok := source[variable.Name]
+		if !ok {
+			continue
+		}
+		to, ok := target[variable.Name]
+		if !ok || same(from, to) {
+			continue
+		}
+		if symptom := variable.Problem(from, to); symptom != "" {
+			differences = append(differences, Difference{Name: variable.Name, Source: from, Target: to, Symptom: symptom})
+		}
+	}
+	return differences
+}
+
+// same reports whether two values are the same, reading 1 and 0 as ON and OFF
+func same(a, b string) bool {
+	return normalize(a) == normalize(b)
+}
+
+// normalize upper-cases a value and spells booleans as ON and OFF
+func normalize(value string) string {
+	switch value = strings.ToUpper(strings.TrimSpace(value)); value {
+	case "1", "TRUE":
+		return "ON"
+	case "0", "FALSE":
+		return "OFF"
+	}
+	return value
+}
+
+// on reports whether a boolean variable is set
+func on(value string) bool {
+	return normalize(value) == "ON"
+}
+
+// Strict and related sql_mode flags, and what adding one on the target does
+var addedModes = []struct {
+	modes   []string
+	symptom string
+}{
+	{[]string{"STRICT_TRANS_TABLES", "STRICT_ALL_TABLES"}, "the application's writes of values the source truncated or adjusted with a warning fail"},
+	{[]string{"NO_ZERO_DATE", "NO_ZERO_IN_DATE"}, "zero dates restore (the dump sets its own sql_mode) but the application can't write them any more"},
+	{[]string{"ERROR_FOR_DIVISION_BY_ZERO"}, "division by zero in the application's writes fails instead of storing NULL"},
+	{[]string{"ONLY_FULL_GROUP_BY"}, "queries selecting columns outside their GROUP BY, and views built on them, fail"},
+	{[]string{"ANSI_QUOTES"}, "double-quoted strings in queries, views and triggers are read as identifiers"},
+	{[]string{"PIPES_AS_CONCAT"}, "|| concatenates strings instead of being a logical OR"},
+}
+
+// sqlModeProblem describes the sql_mode flags the target adds, and the
+// strictness it drops
+func sqlModeProblem(source, target string) string {
+	from, to := modes(source), modes(target)
+	var symptoms []string
+	for _, added := range addedModes {
+		had := slices.ContainsFunc(added.modes, func(mode string) bool { return slices.Contains(from, mode) })
+		has := slices.ContainsFunc(added.modes, func(mode string) bool { return slices.Contains(to, mode) })
+		switch {
+		case has && !had:
+			symptoms = append(symptoms, "the target adds "+strings.Join(intersect(added.modes, to), ",")+": "+added.symptom)
+		case had && !has && added.modes[0] == "STRICT_TRANS_TABLES":
+			symptoms = append(symptoms, "the target is not strict: invalid values the source rejected are silently truncated or adjusted")
+		}
+	}
+	return strings.Join(symptoms, "; ")
+}
+
+// modes splits a sql_mode value
+func modes(value string) []string {
+	var flags []string
+	for _, flag := range strings.Split(strings.ToUpper(value), ",") {
+		if flag = strings.TrimSpace(flag); flag != "" {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+// intersect returns the flags of want found in have
+func intersect(want, have []string) []string {
+	var found []string
+	for _, flag := range want {
+		if slices.Contains(have, flag) {
+			found = append(found, flag)
+		}
+	}
+	return found
+}
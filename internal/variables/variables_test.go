@@ -0,0 +1,179 @@
+package variables
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	const strict = "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION"
+
+	tests := []struct {
+		name     string
+		source   map[string]string
+		target   map[string]string
+		want     []string // the names of the differences
+		symptoms []string // substrings of the symptoms, in order
+	}{
+		// sql_mode
+		{
+			name:   "same sql_mode",
+			source: map[string]string{"sql_mode": strict},
+			target: map[string]string{"sql_mode": strict},
+		},
+		{
+			name:   "sql_mode in another order and case",
+			source: map[string]string{"sql_mode": "STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION"},
+			target: map[string]string{"sql_mode": "no_engine_substitution, strict_trans_tables"},
+		},
+		{
+			name:     "target adds strict and group by",
+			source:   map[string]string{"sql_mode": "NO_ENGINE_SUBSTITUTION"},
+			target:   map[string]string{"sql_mode": strict},
+			want:     []string{"sql_mode"},
+			symptoms: []string{"adds STRICT_TRANS_TABLES:", "adds NO_ZERO_DATE,NO_ZERO_IN_DATE:", "adds ERROR_FOR_DIVISION_BY_ZERO:", "adds ONLY_FULL_GROUP_BY:"},
+		},
+		{
+			name:     "either strict flag is strict",
+			source:   map[string]string{"sql_mode": "STRICT_ALL_TABLES"},
+			target:   map[string]string{"sql_mode": "STRICT_TRANS_TABLES,ANSI_QUOTES"},
+			want:     []string{"sql_mode"},
+			symptoms: []string{"adds ANSI_QUOTES: double-quoted strings"},
+		},
+		{
+			name:     "target drops strict",
+			source:   map[string]string{"sql_mode": strict},
+			target:   map[string]string{"sql_mode": "NO_ENGINE_SUBSTITUTION"},
+			want:     []string{"sql_mode"},
+			symptoms: []string{"the target is not strict"},
+		},
+		{
+			name:   "target drops other flags",
+			source: map[string]string{"sql_mode": "ONLY_FULL_GROUP_BY,NO_ZERO_DATE,PIPES_AS_CONCAT"},
+			target: map[string]string{"sql_mode": ""},
+		},
+		{
+			name:   "flags that don't show",
+			source: map[string]string{"sql_mode": ""},
+			target: map[string]string{"sql_mode": "NO_ENGINE_SUBSTITUTION,NO_AUTO_VALUE_ON_ZERO"},
+		},
+
+		// explicit_defaults_for_timestamp
+		{
+			name:     "explicit defaults turned on",
+			source:   map[string]string{"explicit_defaults_for_timestamp": "OFF"},
+			target:   map[string]string{"explicit_defaults_for_timestamp": "ON"},
+			want:     []string{"explicit_defaults_for_timestamp"},
+			symptoms: []string{"store NULL"},
+		},
+		{
+			name:     "explicit defaults turned off",
+			source:   map[string]string{"explicit_defaults_for_timestamp": "ON"},
+			target:   map[string]string{"explicit_defaults_for_timestamp": "0"},
+			want:     []string{"explicit_defaults_for_timestamp"},
+			symptoms: []string{"DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"},
+		},
+		{
+			name:   "explicit defaults spelled as a number",
+			source: map[string]string{"explicit_defaults_for_timestamp": "1"},
+			target: map[string]string{"explicit_defaults_for_timestamp": "on"},
+		},
+		{
+			name:   "explicit defaults missing on the target",
+			source: map[string]string{"explicit_defaults_for_timestamp": "ON"},
+			target: map[string]string{},
+		},
+
+		// character_set_server
+		{
+			name:     "character set differs",
+			source:   map[string]string{"character_set_server": "utf8mb4"},
+			target:   map[string]string{"character_set_server": "latin1"},
+			want:     []string{"character_set_server"},
+			symptoms: []string{"get latin1 instead of utf8mb4", "Illegal mix of collations"},
+		},
+		{
+			name:   "character set in another case",
+			source: map[string]string{"character_set_server": "utf8mb4"},
+			target: map[string]string{"character_set_server": "UTF8MB4"},
+		},
+		{
+			name:   "character set missing in the recording",
+			source: map[string]string{},
+			target: map[string]string{"character_set_server": "latin1"},
+		},
+
+		// several at once come out in the order of Variables
+		{
+			name: "shown in order",
+			source: map[string]string{
+				"character_set_server":            "utf8mb4",
+				"explicit_defaults_for_timestamp": "OFF",
+				"sql_mode":                        "",
+				"sql_require_primary_key":         "OFF",
+			},
+			target: map[string]string{
+				"character_set_server":            "latin1",
+				"explicit_defaults_for_timestamp": "ON",
+				"sql_mode":                        "STRICT_TRANS_TABLES",
+				"sql_require_primary_key":         "ON",
+			},
+			want: []string{"sql_mode", "explicit_defaults_for_timestamp", "character_set_server", "sql_require_primary_key"},
+		},
+		{
+			name:   "differences that don't matter",
+			source: map[string]string{"sql_require_primary_key": "ON", "innodb_strict_mode": "ON", "log_bin_trust_function_creators": "OFF"},
+			target: map[string]string{"sql_require_primary_key": "OFF", "innodb_strict_mode": "OFF", "log_bin_trust_function_creators": "ON"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			differences := Compare(tt.source, tt.target)
+			var names []string
+			for _, difference := range differences {
+				names = append(names, difference.Name)
+				if difference.Source != tt.source[difference.Name] || difference.Target != tt.target[difference.Name] {
+					t.Errorf("%s: %q → %q, want %q → %q", difference.Name, difference.Source, difference.Target, tt.source[difference.Name], tt.target[difference.Name])
+				}
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("differences in %v, want %v", names, tt.want)
+			}
+			if len(tt.symptoms) == 0 {
+				return
+			}
+			symptom := differences[0].Symptom
+			at := 0
+			for _, want := range tt.symptoms {
+				i := strings.Index(symptom[at:], want)
+				if i < 0 {
+					t.Fatalf("symptom %q lacks %q after byte %d", symptom, want, at)
+				}
+				at += i + len(want)
+			}
+		})
+	}
+}
+
+func TestFill(t *testing.T) {
+	// An old server without most of the variables
+	got := Fill(map[string]string{"sql_mode": "", "character_set_server": "latin1", "unrelated": "x"})
+	want := map[string]string{
+		"sql_mode":                        "",
+		"character_set_server":            "latin1",
+		"explicit_defaults_for_timestamp": "OFF",
+		"foreign_key_checks":              "ON",
+		"innodb_strict_mode":              "OFF",
+		"sql_require_primary_key":         "OFF",
+		"log_bin_trust_function_creators": "OFF",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Fill() = %v, want %v", got, want)
+	}
+
+	if names := Names(); len(names) != len(Variables) || names[0] != "sql_mode" {
+		t.Errorf("Names() = %v", names)
+	}
+}
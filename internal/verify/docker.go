@@ -0,0 +1,194 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckDocker verifies that the docker CLI is installed and the daemon is reachable
+func CheckDocker() error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("docker not found in PATH")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "docker", "info", "--format", "{{.ServerVersion}}")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("docker daemon is not reachable: %s", msg)
+	}
+
+	return nil
+}
+
+var versionPattern = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// ImageForServer picks a container image matching the source server version
+// MySQL maps to mysql:<major>.<minor> and MariaDB to mariadb:<major>.<minor>
+func ImageForServer(serverVersion string) (string, error) {
+	match := versionPattern.FindStringSubmatch(serverVersion)
+	if match == nil {
+		return "", fmt.Errorf("cannot determine image for server version %q (use --verify-image)", serverVersion)
+	}
+
+	repository := "mysql"
+	if strings.Contains(strings.ToLower(serverVersion), "mariadb") {
+		repository = "mariadb"
+	}
+
+	return fmt.Sprintf("%s:%s.%s", repository, match[1], match[2]), nil
+}
+
+// container is an ephemeral database server started for verification
+type container struct {
+	id     string
+	client string
+}
+
+// startContainer starts a throwaway server with an empty root password and
+// the given database (none if empty); extra are more docker run options
+func startContainer(ctx context.Context, image, database string, extra ...string) (*container, error) {
+	args := []string{
+		"run", "-d", "--rm",
+		"-e", "MYSQL_ALLOW_EMPTY_PASSWORD=yes",
+		"-e", "MARIADB_ALLOW_EMPTY_ROOT_PASSWORD=yes",
+	}
+	if database != "" {
+		args = append(args, "-e", "MYSQL_DATABASE="+database)
+	}
+	args = append(append(args, extra...), image)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to start %s container: %s", image, strings.TrimSpace(stderr.String()))
+	}
+
+	client := "mysql"
+	if strings.HasPrefix(image, "mariadb") {
+		client = "mariadb"
+	}
+
+	return &container{id: strings.TrimSpace(stdout.String()), client: client}, nil
+}
+
+// waitReady polls the server over TCP until it accepts queries
+// The official images run a socket-only server during initialization, so a
+// successful TCP query means the final server is up
+func (c *container) waitReady(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := c.exec(ctx, nil, nil, "-e", "SELECT 1"); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("container did not become ready within %s", timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// exec runs the database client inside the container
+func (c *container) exec(ctx context.Context, stdin *countingPipe, stdout *bytes.Buffer, clientArgs ...string) error {
+	args := []string{"exec"}
+	if stdin != nil {
+		args = append(args, "-i")
+	}
+	args = append(args, c.id, c.client, "-uroot", "-h127.0.0.1")
+	args = append(args, clientArgs...)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
+// Server is a throwaway database server reachable from this host as root
+// with an empty password
+type Server struct {
+	Image string
+	Host  string
+	Port  int
+
+	container *container
+}
+
+// StartServer starts a server from image on a free local port and waits up
+// to timeout for it to accept queries
+func StartServer(ctx context.Context, image string, timeout time.Duration) (*Server, error) {
+	c, err := startContainer(ctx, image, "", "-p", "127.0.0.1::3306")
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{Image: image, Host: "127.0.0.1", container: c}
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", "port", c.id, "3306/tcp")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		_ = c.remove()
+		return nil, fmt.Errorf("failed to read the port of the %s container: %w", image, err)
+	}
+	// docker port prints one "127.0.0.1:49153" line per binding
+	address, _, _ := strings.Cut(strings.TrimSpace(stdout.String()), "\n")
+	portText := address[strings.LastIndexByte(address, ':')+1:]
+	if server.Port, err = strconv.Atoi(portText); err != nil {
+		_ = c.remove()
+		return nil, fmt.Errorf("unexpected port of the %s container: %q", image, stdout.String())
+	}
+
+	if err := c.waitReady(ctx, timeout); err != nil {
+		_ = c.remove()
+		return nil, err
+	}
+	return server, nil
+}
+
+// Remove stops and removes the server
+func (s *Server) Remove() error {
+	return s.container.remove()
+}
+
+// remove stops and removes the container
+func (c *container) remove() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := exec.CommandContext(ctx, "docker", "rm", "-f", c.id).Run(); err != nil {
+		return fmt.Errorf("failed to remove container %s: %w", c.id, err)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helgesverre/dbdump/internal/dumpfile"
+	"github.com/helgesverre/dbdump/internal/metadata"
+	"github.com/helgesverre/dbdump/internal/sqlident"
+	"github.com/helgesverre/dbdump/internal/ui"
+	"github.com/helgesverre/dbdump/internal/ui/diag"
+)
+
+// RestoreOptions contains options for a restore verification
+type RestoreOptions struct {
+	DumpFile     string
+	Metadata     *metadata.Metadata
+	Image        string
+	ShowProgress bool
+	ReadyTimeout time.Duration
+}
+
+// TableResult is the outcome of comparing one sampled table. Changed
+// tables were written to during the dump and are not compared.
+type TableResult struct {
+	Name             string
+	ExpectedRows     int64
+	ActualRows       int64
+	ExpectedChecksum int64
+	ActualChecksum   int64
+	Changed          bool
+}
+
+// OK reports whether the restored table matches the source
+func (r TableResult) OK() bool {
+	return r.ExpectedRows == r.ActualRows && r.ExpectedChecksum == r.ActualChecksum
+}
+
+// RestoreResult contains the result of a restore verification
+type RestoreResult struct {
+	Image    string
+	Duration time.Duration
+	Tables   []TableResult
+}
+
+// Failed returns the sampled tables that did not match, leaving out those
+// that changed during the dump
+func (r *RestoreResult) Failed() []TableResult {
+	var failed []TableResult
+	for _, t := range r.Tables {
+		if !t.OK() && !t.Changed {
+			failed = append(failed, t)
+		}
+	}
+	return failed
+}
+
+// RestoreError describes a failure while replaying the dump
+type RestoreError struct {
+	Line      int
+	Table     string
+	Statement string
+	Message   string
+}
+
+func (e *RestoreError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("restore failed: %s", e.Message)
+	}
+	msg := fmt.Sprintf("restore failed at line %d", e.Line)
+	if e.Table != "" {
+		msg += fmt.Sprintf(" (table %s)", e.Table)
+	}
+	msg += ": " + e.Message
+	if e.Statement != "" {
+		msg += "\n  statement: " + e.Statement
+	}
+	return msg
+}
+
+// VerifyRestore replays the dump into an ephemeral container and compares the
+// sampled tables against the checksums recorded in the metadata sidecar
+func VerifyRestore(ctx context.Context, opts RestoreOptions) (*RestoreResult, error) {
+	startTime := time.Now()
+	meta := opts.Metadata
+
+	image := opts.Image
+	if image == "" {
+		var err error
+		image, err = ImageForServer(meta.Source.ServerVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Starting %s container for restore verification", image))
+	c, err := startContainer(ctx, image, meta.Source.Database)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := c.remove(); err != nil {
+			diag.Warnf("%v", err)
+		}
+	}()
+
+	timeout := opts.ReadyTimeout
+	if timeout == 0 {
+		timeout = 3 * time.Minute
+	}
+	if err := c.waitReady(ctx, timeout); err != nil {
+		return nil, err
+	}
+
+	if err := replay(ctx, c, opts); err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{Image: image}
+	for _, expected := range meta.Checksums {
+		actual, err := checksumInContainer(ctx, c, meta.Source.Database, expected.Name)
+		if err != nil {
+			return nil, err
+		}
+		actual.ExpectedRows = expected.RowCount
+		actual.ExpectedChecksum = expected.Checksum
+		actual.Changed = expected.Changed
+		result.Tables = append(result.Tables, actual)
+	}
+
+	result.Duration = time.Since(startTime)
+	return result, nil
+}
+
+// countingPipe feeds the dump file to the client while advancing the progress bar
+type countingPipe struct {
+	reader   io.Reader
+	progress *ui.ProgressTracker
+}
+
+func (p *countingPipe) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	if p.progress != nil && n > 0 {
+		_ = p.progress.Add(n)
+	}
+	return n, err
+}
+
+// replay pipes the dump file into the client inside the container
+func replay(ctx context.Context, c *container, opts RestoreOptions) error {
+	// dumpfile reads split dumps as one stream
+	file, err := dumpfile.Open(opts.DumpFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	pipe := &countingPipe{reader: bufio.NewReaderSize(file, 256*1024)}
+	if opts.ShowProgress {
+		pipe.progress = ui.NewProgressTracker("Replaying dump", file.Size)
+		defer func() {
+			_ = pipe.progress.Finish()
+		}()
+	}
+
+	if err := c.exec(ctx, pipe, nil, opts.Metadata.Source.Database); err != nil {
+		return describeFailure(opts.DumpFile, err.Error())
+	}
+
+	return nil
+}
+
+var clientErrorPattern = regexp.MustCompile(`ERROR \d+ \(\w+\) at line (\d+)`)
+
+// describeFailure turns a client error into a RestoreError pointing at the offending statement
+func describeFailure(dumpFile, message string) error {
+	restoreErr := &RestoreError{Message: message}
+
+	match := clientErrorPattern.FindStringSubmatch(message)
+	if match == nil {
+		return restoreErr
+	}
+
+	line, err := strconv.Atoi(match[1])
+	if err != nil {
+		return restoreErr
+	}
+	restoreErr.Line = line
+	restoreErr.Statement, restoreErr.Table = statementAtLine(dumpFile, line)
+
+	return restoreErr
+}
+
+var statementTablePattern = regexp.MustCompile("(?i)^(?:INSERT INTO|CREATE TABLE|DROP TABLE IF EXISTS|LOCK TABLES|ALTER TABLE) `([^`]+)`")
+
+// statementAtLine returns the (truncated) statement on a line and the table it belongs to
+func statementAtLine(dumpFile string, target int) (string, string) {
+	file, err := dumpfile.Open(dumpFile)
+	if err != nil {
+		return "", ""
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	reader := bufio.NewReaderSize(file, 256*1024)
+	table := ""
+	for line := 1; ; line++ {
+		text, err := reader.ReadString('\n')
+		if match := statementTablePattern.FindStringSubmatch(text); match != nil {
+			table = match[1]
+		}
+		if line == target {
+			return truncate(strings.TrimSpace(text), 200), table
+		}
+		if err != nil {
+			return "", table
+		}
+	}
+}
+
+// checksumInContainer fetches the row count and checksum of a table in the restored database
+func checksumInContainer(ctx context.Context, c *container, database, table string) (TableResult, error) {
+	quoted := sqlident.Quote(table)
+	query := fmt.Sprintf("CHECKSUM TABLE %s; SELECT COUNT(*) FROM %s;", quoted, quoted)
+
+	var stdout bytes.Buffer
+	if err := c.exec(ctx, nil, &stdout, "-N", "-B", "-e", query, database); err != nil {
+		return TableResult{}, fmt.Errorf("failed to checksum restored table %s: %w", table, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		return TableResult{}, fmt.Errorf("unexpected checksum output for table %s: %q", table, stdout.String())
+	}
+
+	result := TableResult{Name: table}
+	fields := strings.Fields(lines[0])
+	if len(fields) == 2 && fields[1] != "NULL" {
+		result.ActualChecksum, _ = strconv.ParseInt(fields[1], 10, 64)
+	}
+	rows, err := strconv.ParseInt(strings.TrimSpace(lines[1]), 10, 64)
+	if err != nil {
+		return TableResult{}, fmt.Errorf("unexpected row count for table %s: %q", table, lines[1])
+	}
+	result.ActualRows = rows
+
+	return result, nil
+}
+
+// truncate shortens a string to max characters
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
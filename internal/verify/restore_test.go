@@ -0,0 +1,22 @@
+package verify
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRestoreResultFailed(t *testing.T) {
+	result := &RestoreResult{Tables: []TableResult{
+		{Name: "countries", ExpectedRows: 250, ActualRows: 250, ExpectedChecksum: 111, ActualChecksum: 111},
+		{Name: "orders", ExpectedRows: 1000, ActualRows: 999, ExpectedChecksum: 222, ActualChecksum: 222},
+		{Name: "users", ExpectedRows: 40, ActualRows: 40, ExpectedChecksum: 333, ActualChecksum: 334},
+		{Name: "sessions", ExpectedRows: 5, ActualRows: 7, ExpectedChecksum: 444, ActualChecksum: 555, Changed: true},
+	}}
+	var names []string
+	for _, table := range result.Failed() {
+		names = append(names, table.Name)
+	}
+	if want := []string{"orders", "users"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Failed() = %v, want %v", names, want)
+	}
+}
@@ -216,6 +216,19 @@ test_cli_features() {
     "
 }
 
+test_selftest() {
+    local db_name="$1"
+    local port="$2"
+
+    log_info "Running dbdump selftest on $db_name..."
+
+    # Test 14: Full pipeline on the shared fixture (internal/selftest/fixture.sql)
+    run_test "Selftest (dump, verify, restore, compare)" "
+        export DBDUMP_MYSQL_PWD=testpass123
+        ./bin/dbdump selftest -H 127.0.0.1 -P $port -u root
+    "
+}
+
 # Main execution
 echo "========================================"
 echo "dbdump Integration Test Suite"
@@ -271,6 +284,7 @@ for db_name in "${DB_NAMES[@]}"; do
     test_data_integrity "$db_name" "$db_port"
     test_exclusion_logic "$db_name" "$db_port"
     test_cli_features "$db_name" "$db_port"
+    test_selftest "$db_name" "$db_port"
     
     echo ""
 done